@@ -19,31 +19,56 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/base/files/delete/{uuid}": {
-            "delete": {
-                "description": "Deletes a file by its File UUID",
+        "/admin/api-keys": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Creates a key for the X-API-Key header of the routes with the apikey middleware (ROUTE_MIDDLEWARE). Once a key exists, the files and images groups (/v2, /base) require a key even without ROUTE_MIDDLEWARE, unless API_KEYS_OPTIONAL is set or the group has auth or jwt. The key is returned only in this response, the server keeps its SHA-256. The name (letters, digits, '.', '_', '-', up to 64 characters) is the client in usage statistics and ADMISSION_PRIORITIES and stays taken after the key is revoked. Requires admin Basic auth.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "01 - Base (internal)"
+                    "04 - System"
                 ],
-                "summary": "Delete a file",
+                "summary": "Create an API key",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "File UUID",
-                        "name": "uuid",
-                        "in": "path",
+                        "description": "Name of the key",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.APIKeyRequest"
+                        },
                         "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "File deleted successfully",
+                    "201": {
+                        "description": "Created key",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.APIKeyCreatedResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid name",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Name already used",
                         "schema": {
                             "type": "string"
                         }
@@ -55,48 +80,31 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/base/files/info/{uuid}": {
+            },
             "get": {
-                "description": "Get detailed information about a file",
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Lists all API keys including the revoked ones, without the keys themselves. Requires admin Basic auth.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "01 - Base (internal)"
-                ],
-                "summary": "Get file info",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "File UUID",
-                        "name": "uuid",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "boolean",
-                        "description": "Include base64 content",
-                        "name": "extended",
-                        "in": "query"
-                    }
+                    "04 - System"
                 ],
+                "summary": "List API keys",
+                "parameters": [],
                 "responses": {
                     "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/service.FileInfo"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
+                        "description": "API keys",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.APIKeyListResponse"
                         }
                     },
-                    "404": {
-                        "description": "File not found",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "string"
                         }
@@ -110,46 +118,51 @@ const docTemplate = `{
                 }
             }
         },
-        "/base/files/old/info/{cumulus_id}": {
-            "get": {
-                "description": "Get detailed information about a file by its old Cumulus ID",
+        "/admin/api-keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Revokes the key and returns it. On this node it stops working immediately, on other nodes sharing the database within 30 seconds. The record stays, so its name remains in the usage statistics. Requires admin Basic auth.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "01 - Base (internal)"
+                    "04 - System"
                 ],
-                "summary": "Get file info by old ID",
+                "summary": "Revoke an API key",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Cumulus ID",
-                        "name": "cumulus_id",
+                        "description": "Key ID",
+                        "name": "id",
                         "in": "path",
+                        "type": "integer",
                         "required": true
-                    },
-                    {
-                        "type": "boolean",
-                        "description": "Include base64 content",
-                        "name": "extended",
-                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Revoked key",
                         "schema": {
-                            "$ref": "#/definitions/service.FileInfo"
+                            "$ref": "#/definitions/storage.APIKey"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "File not found",
+                        "description": "Key not found",
                         "schema": {
                             "type": "string"
                         }
@@ -163,40 +176,62 @@ const docTemplate = `{
                 }
             }
         },
-        "/base/files/old/{cumulus_id}": {
+        "/admin/api/activity": {
             "get": {
-                "description": "Downloads a file by its old Cumulus ID",
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Returns uploads, downloads and deletes per hour with their bytes, the most active clients and the most uploaded/downloaded file types, for the overview page of the admin UI. Counted from the requests the server handles (like the access log): an upload counts every stored file including dedup hits and linked conditional uploads, a download every 200/206 GET of file or image content (not cluster redirects or 304), a delete every successful delete request. The client is the API key name, the token client or \"anonymous\". Data is kept in memory since server start, for at most 7 days. Requires admin Basic auth.",
                 "produces": [
-                    "application/octet-stream"
+                    "application/json"
                 ],
                 "tags": [
-                    "01 - Base (internal)"
+                    "04 - System"
                 ],
-                "summary": "Download a file by old ID",
+                "summary": "Get recent activity",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Old Cumulus ID",
-                        "name": "cumulus_id",
-                        "in": "path",
-                        "required": true
+                        "description": "Last hours (default 24, max 168)",
+                        "name": "hours",
+                        "in": "query",
+                        "type": "integer"
+                    },
+                    {
+                        "description": "Only this client",
+                        "name": "client",
+                        "in": "query",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Only this MIME type, or a prefix ending with / (e.g. image/)",
+                        "name": "type",
+                        "in": "query",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Length of topClients and topFileTypes (default 10, max 100)",
+                        "name": "limit",
+                        "in": "query",
+                        "type": "integer"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "File content",
+                        "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/api.ActivityReport"
                         }
                     },
-                    "404": {
-                        "description": "File not found",
+                    "400": {
+                        "description": "Invalid hours or limit",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "string"
                         }
@@ -204,51 +239,27 @@ const docTemplate = `{
                 }
             }
         },
-        "/base/files/upload": {
-            "post": {
-                "description": "Uploads a file to the storage",
-                "consumes": [
-                    "multipart/form-data"
-                ],
-                "produces": [
-                    "application/json"
-                ],
+        "/base/files/delete/{uuid}": {
+            "delete": {
+                "description": "Deletes a file by its File UUID",
                 "tags": [
                     "01 - Base (internal)"
                 ],
-                "summary": "Upload a file",
+                "summary": "Delete a file",
                 "parameters": [
-                    {
-                        "type": "file",
-                        "description": "File to upload",
-                        "name": "file",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Tags like array of string or coma separated strings",
-                        "name": "tags",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Legacy ID",
-                        "name": "old_cumulus_id",
-                        "in": "formData"
-                    },
                     {
                         "type": "string",
-                        "description": "Validity period (e.g. '1 day', '2 months')",
-                        "name": "validity",
-                        "in": "formData"
+                        "description": "File UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "File uploaded successfully, returns file UUID",
+                    "200": {
+                        "description": "File deleted successfully",
                         "schema": {
-                            "$ref": "#/definitions/api.UploadResponse"
+                            "type": "string"
                         }
                     },
                     "400": {
@@ -257,8 +268,8 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "413": {
-                        "description": "File too large",
+                    "423": {
+                        "description": "File is under legal hold",
                         "schema": {
                             "type": "string"
                         }
@@ -272,16 +283,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/base/files/{uuid}": {
+        "/base/files/info/{uuid}": {
             "get": {
-                "description": "Downloads a file by its UUID",
+                "description": "Get detailed information about a file",
                 "produces": [
-                    "application/octet-stream"
+                    "application/json"
                 ],
                 "tags": [
                     "01 - Base (internal)"
                 ],
-                "summary": "Download a file",
+                "summary": "Get file info",
                 "parameters": [
                     {
                         "type": "string",
@@ -289,13 +300,37 @@ const docTemplate = `{
                         "name": "uuid",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include base64 content (up to EXTENDED_INFO_MAX_SIZE)",
+                        "name": "extended",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "'stream' redirects (303) to the download URL instead of embedding the content",
+                        "name": "content",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "File content",
+                        "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/service.FileInfo"
+                        }
+                    },
+                    "307": {
+                        "description": "File stored on another cluster node (CLUSTER_NODES), Location points to it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
                         }
                     },
                     "404": {
@@ -304,6 +339,18 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "410": {
+                        "description": "Extended info of an expired file (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "Content larger than EXTENDED_INFO_MAX_SIZE, use the download URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -313,32 +360,47 @@ const docTemplate = `{
                 }
             }
         },
-        "/health": {
+        "/base/files/old/by-label/{label}": {
             "get": {
-                "description": "Returns OK if service is healthy",
+                "description": "Lists all unexpired (or pinned) files carrying the label as a plain array, like the old Cumulus label listing. Labels of migrated files are tags, so this is the same set as /v2/files/old/by-label/{label}, but without paging. The array is streamed; if reading the records fails midway the response ends with invalid JSON.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "04 - System"
+                    "01 - Base (internal)"
+                ],
+                "summary": "List files by label",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Label (tag)",
+                        "name": "label",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Health check",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.LabelFileEntry"
                             }
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
-        "/system/compact": {
+        "/base/files/old/exists": {
             "post": {
-                "description": "Starts asynchronous compaction of a specific volume or all volumes",
+                "description": "Returns which old Cumulus IDs exist, with file UUID, BLAKE2b-256 content hash and size (max 10000 IDs per request)",
                 "consumes": [
                     "application/json"
                 ],
@@ -346,158 +408,71 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "04 - System"
+                    "01 - Base (internal)"
                 ],
-                "summary": "Compact volume",
+                "summary": "Bulk check old IDs",
                 "parameters": [
                     {
-                        "description": "Compact request (volumeId: int or 'all': true)",
+                        "description": "Old Cumulus IDs",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/api.OldIDExistsRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Accepted",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/api.OldIDExistsResponse"
                         }
-                    }
-                }
-            }
-        },
-        "/system/integrity": {
-            "get": {
-                "description": "Checks integrity of storage (blobs vs files). Use ?deep=true for physical verification",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "04 - System"
-                ],
-                "summary": "Check storage integrity",
-                "parameters": [
-                    {
-                        "type": "boolean",
-                        "description": "Perform deep integrity check (verifies physical files)",
-                        "name": "deep",
-                        "in": "query"
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    }
-                }
-            }
-        },
-        "/system/jobs": {
-            "get": {
-                "description": "Returns list of all jobs or specific job details",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "04 - System"
-                ],
-                "summary": "Get jobs status",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Job ID",
-                        "name": "id",
-                        "in": "query"
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    }
-                }
-            }
-        },
-        "/system/stats": {
-            "get": {
-                "description": "Returns statistics about storage, blobs, files, and deduplication",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "04 - System"
-                ],
-                "summary": "Get system statistics",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "type": "string"
                         }
-                    }
-                }
-            }
-        },
-        "/system/volumes": {
-            "get": {
-                "description": "Returns list of all volumes with their statistics",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "04 - System"
-                ],
-                "summary": "Get volume list",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "object",
-                                "additionalProperties": true
-                            }
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "/v2/files/info/{uuid}": {
+        "/base/files/old/info/{cumulus_id}": {
             "get": {
-                "description": "Get detailed information about a file",
+                "description": "Get detailed information about a file by its old Cumulus ID",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "02 - Files"
+                    "01 - Base (internal)"
                 ],
-                "summary": "Get file info",
+                "summary": "Get file info by old ID",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "File UUID",
-                        "name": "uuid",
+                        "type": "integer",
+                        "description": "Cumulus ID",
+                        "name": "cumulus_id",
                         "in": "path",
                         "required": true
                     },
                     {
                         "type": "boolean",
-                        "description": "Include base64 content",
+                        "description": "Include base64 content (up to EXTENDED_INFO_MAX_SIZE)",
                         "name": "extended",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "'stream' redirects (303) to the download URL instead of embedding the content",
+                        "name": "content",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -519,6 +494,18 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "410": {
+                        "description": "Extended info of an expired file (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "Content larger than EXTENDED_INFO_MAX_SIZE, use the download URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -528,40 +515,52 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/files/old/info/{cumulus_id}": {
+        "/base/files/old/{cumulus_id}": {
             "get": {
-                "description": "Get detailed information about a file by its old Cumulus ID",
+                "description": "Downloads a file by its old Cumulus ID. The ETag is the BLAKE2b-256 hash of the content; If-None-Match with it gets 304 Not Modified. HEAD returns the headers (Content-Length, Content-Type, Content-Disposition, ETag) from the metadata without reading the stored content.",
                 "produces": [
-                    "application/json"
+                    "application/octet-stream"
                 ],
                 "tags": [
-                    "02 - Files"
+                    "01 - Base (internal)"
                 ],
-                "summary": "Get file info by old Cumulus ID",
+                "summary": "Download a file by old ID",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Old CumulusID",
+                        "description": "Old Cumulus ID",
                         "name": "cumulus_id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "type": "boolean",
-                        "description": "Include base64 content",
-                        "name": "extended",
-                        "in": "query"
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached copy (quoted content hash); 304 when it matches",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "File content",
                         "schema": {
-                            "$ref": "#/definitions/service.FileInfo"
+                            "type": "file"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "206": {
+                        "description": "Partial content (Range)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified (If-None-Match matches the ETag)",
                         "schema": {
                             "type": "string"
                         }
@@ -572,6 +571,18 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -579,25 +590,35 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/v2/files/old/{cumulus_id}": {
-            "get": {
-                "description": "Downloads a file by its old CumulusID",
+            },
+            "head": {
+                "description": "Downloads a file by its old Cumulus ID. The ETag is the BLAKE2b-256 hash of the content; If-None-Match with it gets 304 Not Modified. HEAD returns the headers (Content-Length, Content-Type, Content-Disposition, ETag) from the metadata without reading the stored content.",
                 "produces": [
                     "application/octet-stream"
                 ],
                 "tags": [
-                    "02 - Files"
+                    "01 - Base (internal)"
                 ],
-                "summary": "Download a file by old CumulusID",
+                "summary": "Download a file by old ID",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Old CumulusID",
+                        "description": "Old Cumulus ID",
                         "name": "cumulus_id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached copy (quoted content hash); 304 when it matches",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -607,12 +628,36 @@ const docTemplate = `{
                             "type": "file"
                         }
                     },
+                    "206": {
+                        "description": "Partial content (Range)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified (If-None-Match matches the ETag)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "404": {
                         "description": "File not found",
                         "schema": {
                             "type": "string"
                         }
                     },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -622,9 +667,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/files/upload": {
+        "/base/files/upload": {
             "post": {
-                "description": "Uploads a file to the storage",
+                "description": "Uploads a file to the storage. Several \"file\" parts are stored concurrently and answered with an array of BatchUploadResult in the order of the files (201 if all were stored, 207 otherwise); old_cumulus_id is then given once per file, content_type once or once per file.",
                 "consumes": [
                     "multipart/form-data"
                 ],
@@ -632,7 +677,7 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "02 - Files"
+                    "01 - Base (internal)"
                 ],
                 "summary": "Upload a file",
                 "parameters": [
@@ -657,16 +702,99 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Validity period (e.g. '1 day', '2 months')",
+                        "description": "When old_cumulus_id belongs to another file: 'reject' (409, default) or 'supersede' (move the ID to the new file)",
+                        "name": "on_conflict",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Content-Disposition of downloads: 'inline' or 'attachment' (default: by MIME type)",
+                        "name": "disposition",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Validity period (e.g. '1 day', '2 weeks', '1 year'), within VALIDITY_MIN..VALIDITY_MAX",
                         "name": "validity",
                         "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Absolute expiry time (RFC 3339), instead of validity",
+                        "name": "expires_at",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Original MIME type, used when content detection yields generic binary",
+                        "name": "content_type",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Original creation time (RFC 3339, 'YYYY-MM-DD HH:MM:SS' or unix seconds); requires admin Basic auth",
+                        "name": "created_at",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Quoted BLAKE2b-256 hash of the content; if already stored and in scope (admin Basic auth, or tags a live file of the content carries), the body is not read",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "With If-None-Match: link the stored content under this filename (old_cumulus_id, on_conflict, disposition, validity, expires_at, tags also from query)",
+                        "name": "filename",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include hash, sizes, dedup flag, detected MIME type and expiry in the response",
+                        "name": "verbose",
+                        "in": "query"
                     }
                 ],
                 "responses": {
+                    "200": {
+                        "description": "Content already stored, file linked without upload (If-None-Match + filename)",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadResponse"
+                        },
+                        "headers": {
+                            "X-Cumulus-Blob-Id": {
+                                "type": "string",
+                                "description": "ID of the blob holding the content"
+                            },
+                            "X-Cumulus-Dedup": {
+                                "type": "string",
+                                "description": "hit when the content was already stored, miss otherwise"
+                            }
+                        }
+                    },
                     "201": {
                         "description": "File uploaded successfully, returns file UUID",
                         "schema": {
                             "$ref": "#/definitions/api.UploadResponse"
+                        },
+                        "headers": {
+                            "X-Cumulus-Blob-Id": {
+                                "type": "string",
+                                "description": "ID of the blob holding the content"
+                            },
+                            "X-Cumulus-Dedup": {
+                                "type": "string",
+                                "description": "hit when the content was already stored, miss otherwise"
+                            }
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-file upload with at least one failed file",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.BatchUploadResult"
+                            }
                         }
                     },
                     "400": {
@@ -675,55 +803,48 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "413": {
-                        "description": "File too large",
+                    "403": {
+                        "description": "created_at without admin credentials",
                         "schema": {
                             "type": "string"
                         }
                     },
+                    "409": {
+                        "description": "Content already stored (If-None-Match without filename)",
+                        "schema": {
+                            "$ref": "#/definitions/api.ExistingBlobResponse"
+                        },
+                        "headers": {
+                            "X-Cumulus-Blob-Id": {
+                                "type": "string",
+                                "description": "ID of the blob holding the content"
+                            },
+                            "X-Cumulus-Dedup": {
+                                "type": "string",
+                                "description": "hit when the content was already stored, miss otherwise"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "File larger than MAX_UPLOAD_FILE_SIZE, maxSize is the limit",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadTooLargeResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "type": "string"
                         }
-                    }
-                }
-            }
-        },
-        "/v2/files/{uuid}": {
-            "get": {
-                "description": "Downloads a file by its UUID",
-                "produces": [
-                    "application/octet-stream"
-                ],
-                "tags": [
-                    "02 - Files"
-                ],
-                "summary": "Download a file",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "File UUID",
-                        "name": "uuid",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "File content",
-                        "schema": {
-                            "type": "file"
-                        }
                     },
-                    "404": {
-                        "description": "File not found",
+                    "422": {
+                        "description": "Upload rejected by the upload hook (UPLOAD_HOOK_URL), body is its reason",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "503": {
+                        "description": "Upload hook unavailable (UPLOAD_HOOK_FAIL_OPEN off)",
                         "schema": {
                             "type": "string"
                         }
@@ -731,17 +852,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/images/{uuid}": {
+        "/base/files/{uuid}": {
             "get": {
-                "description": "Downloads original image or resized variant (thumb, sm, md, lg). For PDF files, generates thumbnail.",
+                "description": "Downloads a file by its UUID. The ETag is the BLAKE2b-256 hash of the content; If-None-Match with it gets 304 Not Modified. HEAD returns the headers (Content-Length, Content-Type, Content-Disposition, ETag) from the metadata without reading the stored content.",
                 "produces": [
-                    "image/jpeg",
-                    "image/png"
+                    "application/octet-stream"
                 ],
                 "tags": [
-                    "03 - Images"
+                    "01 - Base (internal)"
                 ],
-                "summary": "Get image or image variant",
+                "summary": "Download a file",
                 "parameters": [
                     {
                         "type": "string",
@@ -749,17 +869,41 @@ const docTemplate = `{
                         "name": "uuid",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached copy (quoted content hash); 304 when it matches",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Image content",
+                        "description": "File content",
                         "schema": {
                             "type": "file"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "206": {
+                        "description": "Partial content (Range)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified (If-None-Match matches the ETag)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "307": {
+                        "description": "File stored on another cluster node (CLUSTER_NODES), Location points to it",
                         "schema": {
                             "type": "string"
                         }
@@ -770,8 +914,14 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "415": {
-                        "description": "Not an image or PDF",
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
                         "schema": {
                             "type": "string"
                         }
@@ -783,19 +933,16 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/v2/images/{uuid}/lg": {
-            "get": {
-                "description": "Downloads original image or resized variant (thumb, sm, md, lg). For PDF files, generates thumbnail.",
+            },
+            "head": {
+                "description": "Downloads a file by its UUID. The ETag is the BLAKE2b-256 hash of the content; If-None-Match with it gets 304 Not Modified. HEAD returns the headers (Content-Length, Content-Type, Content-Disposition, ETag) from the metadata without reading the stored content.",
                 "produces": [
-                    "image/jpeg",
-                    "image/png"
+                    "application/octet-stream"
                 ],
                 "tags": [
-                    "03 - Images"
+                    "01 - Base (internal)"
                 ],
-                "summary": "Get image or image variant",
+                "summary": "Download a file",
                 "parameters": [
                     {
                         "type": "string",
@@ -803,17 +950,41 @@ const docTemplate = `{
                         "name": "uuid",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached copy (quoted content hash); 304 when it matches",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Image content",
+                        "description": "File content",
                         "schema": {
                             "type": "file"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "206": {
+                        "description": "Partial content (Range)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified (If-None-Match matches the ETag)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "307": {
+                        "description": "File stored on another cluster node (CLUSTER_NODES), Location points to it",
                         "schema": {
                             "type": "string"
                         }
@@ -824,8 +995,14 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "415": {
-                        "description": "Not an image or PDF",
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
                         "schema": {
                             "type": "string"
                         }
@@ -839,53 +1016,95 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/images/{uuid}/md": {
+        "/health": {
             "get": {
-                "description": "Downloads original image or resized variant (thumb, sm, md, lg). For PDF files, generates thumbnail.",
+                "description": "Returns OK if service is healthy. A read-only replica (READ_ONLY) adds \"mode\": \"read-only\".",
                 "produces": [
-                    "image/jpeg",
-                    "image/png"
+                    "application/json"
                 ],
                 "tags": [
-                    "03 - Images"
+                    "04 - System"
                 ],
-                "summary": "Get image or image variant",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "File UUID",
-                        "name": "uuid",
-                        "in": "path",
-                        "required": true
+                "summary": "Health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
+                }
+            }
+        },
+        "/metrics/alerts.yaml": {
+            "get": {
+                "description": "Returns a Prometheus rule file with recommended alerts generated from the configuration of this instance: volume fragmentation, free disk space and disk full forecast of the data directories (FORECAST_WARNING_DAYS), failed and slow database transactions (SQLite or PostgreSQL), error rate and latency over the SLO (SLO_ERROR_RATE, SLO_LATENCY_P99, SLO_WINDOW), and, when configured, replica divergence and verification lag (REPLICA_VERIFY_INTERVAL) and upload hook failures (UPLOAD_HOOK_URL). Save it as a rule file of the Prometheus scraping /metrics (rule_files) and adjust the thresholds as needed.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "04 - System"
                 ],
+                "summary": "Prometheus alerting rules",
                 "responses": {
                     "200": {
-                        "description": "Image content",
+                        "description": "Prometheus rule file (YAML)",
                         "schema": {
-                            "type": "file"
+                            "type": "string"
                         }
-                    },
-                    "400": {
-                        "description": "Bad Request",
+                    }
+                }
+            }
+        },
+        "/openapi.json": {
+            "get": {
+                "description": "Complete OpenAPI 3.0 document of the API converted from the Swagger 2.0 annotations (also served at /docs/doc.json), with the admin Basic auth scheme and the plain-text error responses, for client code generators.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "OpenAPI 3 document",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
-                    "404": {
-                        "description": "File not found",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "type": "string"
                         }
-                    },
-                    "415": {
-                        "description": "Not an image or PDF",
+                    }
+                }
+            }
+        },
+        "/system/backup": {
+            "get": {
+                "description": "Returns the S3 bucket and prefix of the scheduled metadata backup (BACKUP_S3_BUCKET, every BACKUP_S3_INTERVAL) and the result of its last run. Each run uploads a copy of the SQLite database and the part of the metadata log appended since the previous run, then a manifest with the SHA-256 of every object, and deletes snapshots older than BACKUP_S3_RETENTION. Latest is null before the first run.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Get metadata backup status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.BackupStatus"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "404": {
+                        "description": "Metadata backup is not enabled",
                         "schema": {
                             "type": "string"
                         }
@@ -893,53 +1112,69 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/images/{uuid}/sm": {
-            "get": {
-                "description": "Downloads original image or resized variant (thumb, sm, md, lg). For PDF files, generates thumbnail.",
+        "/system/blobs/{id}/move": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Copies the stored blob unchanged (verified against its CRC) into an existing open volume with enough space and points the blob to the copy in one transaction; the old copy becomes deleted space. All files sharing the blob follow it. Requires admin Basic auth.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
-                    "image/jpeg",
-                    "image/png"
+                    "application/json"
                 ],
                 "tags": [
-                    "03 - Images"
+                    "04 - System"
                 ],
-                "summary": "Get image or image variant",
+                "summary": "Move a blob to another volume",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "File UUID",
-                        "name": "uuid",
+                        "type": "integer",
+                        "description": "Blob ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Target volume",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.FileMoveRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Image content",
+                        "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/service.BlobRewriteResult"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid blob ID or blob is already in the target volume",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "File not found",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "415": {
-                        "description": "Not an image or PDF",
+                    "404": {
+                        "description": "Blob not found",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "409": {
+                        "description": "Volume not open, full, or blob moved concurrently",
                         "schema": {
                             "type": "string"
                         }
@@ -947,47 +1182,66 @@ const docTemplate = `{
                 }
             }
         },
-        "/v2/images/{uuid}/thumb": {
+        "/system/blobs/{id}/verify": {
             "get": {
-                "description": "Downloads original image or resized variant (thumb, sm, md, lg). For PDF files, generates thumbnail.",
+                "description": "Reads the blob from its volume as a stream and checks the header, the CRC footer and (after decompression) the content hash and size. Damaged data is reported in the result with ok=false, not as an HTTP error.",
                 "produces": [
-                    "image/jpeg",
-                    "image/png"
+                    "application/json"
                 ],
                 "tags": [
-                    "03 - Images"
+                    "04 - System"
                 ],
-                "summary": "Get image or image variant",
+                "summary": "Verify a blob",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "File UUID",
-                        "name": "uuid",
+                        "type": "integer",
+                        "description": "Blob ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Image content",
+                        "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/service.BlobVerifyResult"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid blob ID",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "File not found",
+                        "description": "Blob not found",
                         "schema": {
                             "type": "string"
                         }
+                    }
+                }
+            }
+        },
+        "/system/cluster": {
+            "get": {
+                "description": "Lists the other nodes of the cluster with their health, the volume IDs they hold and how old the known state is. Nodes exchange this report as heartbeats every CLUSTER_HEARTBEAT_INTERVAL, so nodes known only to a peer are listed too (via). Status: up (fresh state from its heartbeat or through a peer), suspect (heartbeats to it fail but peers still get fresh state), down (no fresh state for 3 intervals).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Cluster membership",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ClusterResponse"
+                        }
                     },
-                    "415": {
-                        "description": "Not an image or PDF",
+                    "404": {
+                        "description": "Cluster mode is not enabled (CLUSTER_NODES)",
                         "schema": {
                             "type": "string"
                         }
@@ -1000,21 +1254,6253 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
+        },
+        "/system/compact": {
+            "post": {
+                "description": "Starts asynchronous compaction of a specific volume or all volumes",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Compact volume",
+                "parameters": [
+                    {
+                        "description": "Compact request (volumeId: int or 'all': true)",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/system/compression": {
+            "get": {
+                "description": "Returns raw vs stored bytes of committed blobs per MIME category (image, document, text, ...) with the saved percentage and the share of blobs stored uncompressed (incompressible or below MINIMAL_COMPRESSION), plus the total. Categories are ordered by raw size. Each blob is counted once regardless of how many files share it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Compression effectiveness per category",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.CompressionReport"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/dedup": {
+            "get": {
+                "description": "Returns logical bytes (raw size of every file) vs unique bytes (raw size of the distinct blobs the files reference) and stored bytes per tag, ordered by the saved bytes, plus the total of all files. Whole-file deduplication only finds identical files: tags with many large files but little saving (e.g. versions of documents) are where chunk-level deduplication would pay off. A blob shared by files of several tags counts in each of them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Deduplication effectiveness per tag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive tag prefix",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max number of tags (default 50, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.DedupReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/files/hashes": {
+            "post": {
+                "description": "Returns the stored BLAKE2b-256 content hashes of up to 1000 files in one call; IDs without a file are left out. Used to compare a replica without downloading the content. Requires admin Basic auth.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Get hashes of many files",
+                "parameters": [
+                    {
+                        "description": "File IDs",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.FileHashesRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.FileHashesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or more than 1000 IDs",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ]
+            }
+        },
+        "/system/files/held": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Lists the files under legal hold, most recent hold first. Requires admin Basic auth.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "List legal holds",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Max number of holds (default 100, max 10000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.LegalHoldListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/files/pinned": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Lists pinned files, most recently pinned first. Requires admin Basic auth.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "List pinned files",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Max number of files (default 100, max 10000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PinnedListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/files/type-mismatches": {
+            "get": {
+                "description": "Lists the files whose content type detected by signature at upload conflicts with the filename extension (e.g. a .pdf that is a ZIP), newest first – typically broken exports of a partner. expected is the MIME type of the extension, detected that of the content. Generic binary content, unknown extensions and formats sharing a container (ZIP and Office documents, OLE, MP4 and QuickTime) are not flagged. The flag is also in the file info as type_mismatch. Requires admin Basic auth.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "List files with an extension/content type mismatch",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Max number of files (default 100, max 10000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.TypeMismatchListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ]
+            }
+        },
+        "/system/files/{id}/hold": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Deleting the file (DELETE /base/files/delete/{uuid}), purging its tag (/system/purge) and removing it by expiry cleanup fail with 423 Locked until the hold is released; an expired held file is kept. Holding a held file again updates a non-empty reason and keeps held_at. Requires admin Basic auth.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Place a file under legal hold",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reason of the hold",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.LegalHoldRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/storage.LegalHold"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/files/{id}/move": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Copies the stored blob unchanged (verified against its CRC) into an existing open volume with enough space; the old copy becomes deleted space. Requires admin Basic auth.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Move file blob to another volume",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Target volume",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.FileMoveRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.BlobRewriteResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Blob is already in the target volume",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Volume not open, full, or blob moved concurrently",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/files/{id}/pin": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Exempts the file from expiry: the cleanup job keeps it and reads never answer 410 (EXPIRED_ACCESS=deny). expires_at stays stored and applies again after unpinning. Pinning a pinned file keeps the original pinned_at. Requires admin Basic auth.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Pin a file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/storage.File"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/files/{id}/recompress": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Rewrites the blob of the file with the given compression (auto applies MINIMAL_COMPRESSION, empty = server USE_COMPRESS). The content hash is verified first; the old copy becomes deleted space reclaimed by compaction. Requires admin Basic auth.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Re-compress file content",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Compression (auto, zstd, gzip, none)",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.FileRecompressRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.BlobRewriteResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid compression",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Blob moved concurrently",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/files/{id}/redetect": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Runs file type detection on the stored content again and updates the type of the blob (shared by all files with the same content). A generic binary result never replaces a more specific type. Requires admin Basic auth.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Re-detect file type",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.FileTypeChange"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/files/{id}/release": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Lifts the legal hold of the file and returns the released hold; the file can be deleted again and an expired file is removed by the next cleanup. Requires admin Basic auth.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Release a legal hold",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/storage.LegalHold"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not held",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/files/{id}/unpin": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Removes the pin; the file expires by its expires_at again (an expiry in the past means the next cleanup removes it). Requires admin Basic auth.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Unpin a file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/storage.File"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/files/{id}/variants": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Renders all image variants (thumb, sm, md, lg) of an image or PDF and reports the result of each. Variants are produced on request and not stored, so this verifies they can be served. Requires admin Basic auth.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Regenerate image variants",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.VariantResult"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "File is not an image or PDF",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/forecast": {
+            "get": {
+                "description": "Estimates days until full per data directory from the growth of used space over the window (least-squares fit of the disk usage history sampled every STATS_HISTORY_INTERVAL, kept 90 days). Status is \"critical\" under 7 days, \"warning\" under FORECAST_WARNING_DAYS (default 30) and \"unknown\" with less than an hour of history; the top-level status is the worst one. Directories with shrinking or constant usage have no daysUntilFull.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Get disk capacity forecast",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "History used for the trend, e.g. 14d or 36h (default 7d, 1h to 90d)",
+                        "name": "window",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ForecastReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid window",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/heal": {
+            "get": {
+                "description": "Lists blobs whose volume file was missing when a download read them, most recently seen first. source says where the last read was served from: volume_dir (READ_FALLBACK_DIR), replica (READ_FALLBACK_URL) or none (the download failed). A blob leaves the list once it is moved to another volume (POST /system/blobs/{id}/move) or deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "List blobs waiting for heal",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Max number of blobs (default 100, max 10000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.HealListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/integrity": {
+            "get": {
+                "description": "Checks integrity of storage (blobs vs files). Use ?deep=true for physical verification",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Check storage integrity",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Perform deep integrity check (verifies physical files)",
+                        "name": "deep",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/system/jobs": {
+            "get": {
+                "description": "Returns list of all jobs or specific job details. progress and error of takeout and purge jobs are shown only with admin Basic auth.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Get jobs status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/system/purge": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Starts a job that hard-deletes all files carrying the tag, including pinned and expired ones. Blobs left without a file are freed and their volumes compacted, so the content is removed from disk; blobs shared by deduplication with files outside the purge stay. The records of the files in the metadata log are redacted (name and tags removed) or removed by auditPolicy. The job in /system/jobs?id= completes with a deletion report signed with PURGE_REPORT_SIGNING_KEY (HMAC-SHA256 of its SHA-256 digest), stored as a file tagged purge-report (reportFileId); the report is shown only with admin Basic auth. A file under legal hold fails the purge with 423 before anything is deleted (or the job, when the hold was placed meanwhile). A job with errors fails, its report lists them. With dryRun the files are only listed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Purge files by tag (right to be forgotten)",
+                "parameters": [
+                    {
+                        "description": "Tag to purge",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.PurgeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "jobId of the purge",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Missing tag or unknown audit policy",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "423": {
+                        "description": "A file carrying the tag is under legal hold",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/redetect": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Starts an asynchronous job that re-runs file type detection on the content of all blobs whose current type matches the filter (e.g. category binary). A generic binary result never replaces a more specific type and blobs whose type changed meanwhile are skipped. With dryRun nothing is updated. The summary is the job result in /system/jobs. Requires admin Basic auth.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Re-detect file types in bulk",
+                "parameters": [
+                    {
+                        "description": "Filter",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.FileTypeRedetectRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/replication": {
+            "get": {
+                "description": "Returns the result of the last scheduled comparison with the replica (READ_FALLBACK_URL, every REPLICA_VERIFY_INTERVAL): file and blob counts and the content hashes of randomly sampled files older than REPLICA_VERIFY_MIN_AGE. Status is diverged when a divergence exceeds REPLICA_VERIFY_MAX_COUNT_DIVERGENCE or REPLICA_VERIFY_MAX_SAMPLE_DIVERGENCE, error when the replica could not be compared. Requires admin Basic auth.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Get latest replica verification",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReplicaVerifyReport"
+                        }
+                    },
+                    "404": {
+                        "description": "Replica verification is not enabled or has not run yet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ]
+            }
+        },
+        "/system/reports/latest": {
+            "get": {
+                "description": "Returns the most recent stored consistency report: quick integrity counts (orphaned, missing and zombie blobs), volume reconciliation (missing, truncated and untracked volume files) and the overall status (ok, warning, error).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Get latest consistency report",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "No report yet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/slo": {
+            "get": {
+                "description": "Returns p50/p95/p99 latencies and the 5xx error rate per endpoint over a rolling window (SLO_WINDOW, default 15m) from internal histograms, so deployments without Prometheus get health insight. Endpoints over SLO_LATENCY_P99 or SLO_ERROR_RATE (with at least 20 requests) are listed in alerts and the status is \"alert\". Data is kept in memory since server start.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Get latency and error rate SLO report",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Shorter window, e.g. 5m (at most SLO_WINDOW)",
+                        "name": "window",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SLOReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid window",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/slow-queries": {
+            "get": {
+                "description": "Returns the last SLOW_QUERY_LOG_SIZE metadata queries that took at least SLOW_QUERY_THRESHOLD, most recent first, with the normalized SQL text and the number of arguments (their values are not kept). The duration includes waiting for a free database connection, so SQLite stalls behind a long write show up here; statements inside write transactions are reported as a whole in db_tx_duration_seconds instead. The buffer is kept in memory and starts empty on restart.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "List slow metadata queries",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.SlowQueriesResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Slow query log is not enabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/stats": {
+            "get": {
+                "description": "Returns statistics about storage, blobs, files, and deduplication",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Get system statistics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/system/takeout": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Starts a job that exports all unexpired files carrying any of the tags as one tar archive, for data portability requests: metadata.json (file info of every file and its path in the archive) followed by the content as files/\u003cfile ID\u003e_\u003cname\u003e. The archive is stored as a temporary file (tags 'takeout' and 'temporary', validity TEMP_FILE_VALIDITY unless given); when the job in /system/jobs?id= completes, its progress holds file_id and download_url (shown only with admin Basic auth).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Export files by tags (takeout)",
+                "parameters": [
+                    {
+                        "description": "Tags to export",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.TakeoutRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "jobId of the export",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Missing tags or invalid validity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/system/usage": {
+            "get": {
+                "description": "Returns request body bytes and request counts per endpoint and client since server start (for chargeback). Use ?client= to filter.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Get ingress usage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client (API key name) filter",
+                        "name": "client",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/system/usage/keys": {
+            "get": {
+                "description": "Returns requests, request/response body bytes and error responses (4xx/5xx) per client (API key name) and calendar month (UTC). Counters survive restarts; the last few seconds may still be in memory and are included. Requires admin Basic auth.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Get usage per API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Month (YYYY-MM), default all months",
+                        "name": "month",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client (API key name) filter",
+                        "name": "client",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid month",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ]
+            }
+        },
+        "/system/volumes": {
+            "get": {
+                "description": "Returns list of all volumes with their statistics and state (open, sealed, compacting, read-only, missing)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Get volume list",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only volumes in this state (e.g. 'sealed' for erasure coding/tiering candidates)",
+                        "name": "state",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/system/volumes/state": {
+            "post": {
+                "description": "Seals, reopens or marks a volume read-only. Sealed and read-only volumes never receive new blobs; sealed volumes can still be compacted. The states compacting and missing are managed by the server. Requires admin Basic auth.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Set volume state",
+                "parameters": [
+                    {
+                        "description": "Volume and new state (open, sealed, read-only)",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.VolumeStateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.VolumeStateRequest"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Volume is compacting or missing",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ]
+            }
+        },
+        "/system/volumes/{id}/drain": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    }
+                ],
+                "description": "Starts an asynchronous job that moves all committed blobs of the volume into other volumes (toVolumeId, or any open volume with space), e.g. to get data off a failing disk without compacting every volume. Each blob is copied unchanged, verified against its CRC and relocated in one transaction, so reads keep working during the drain. An open volume is sealed first and stays sealed; with compact it is compacted afterwards, which removes it when no blob is left. Failed blobs are listed in the summary (the job result in /system/jobs). Requires admin Basic auth.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "04 - System"
+                ],
+                "summary": "Drain a volume",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Volume ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Target volume and options",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.VolumeDrainRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Volume or target volume not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/blobs/lookup": {
+            "post": {
+                "description": "Sync clients send the hashes (BLAKE2b-256, hex) of their local files and learn which are already stored, so that only the missing ones are uploaded and the rest linked with If-None-Match. stored and size reflect the content. File UUIDs are download capabilities, so fileIds (the live files referencing the content) are listed only for a scoped lookup: with tags only files carrying any of the tags, with admin Basic auth all files; otherwise fileIds is left out.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Look up stored content by hash",
+                "parameters": [
+                    {
+                        "description": "Hashes to look up and tags to scope the file IDs",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.BlobLookupRequest"
+                        },
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Lookups in request order",
+                        "schema": {
+                            "$ref": "#/definitions/api.BlobLookupResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/archive.tar": {
+            "get": {
+                "description": "Streams all unexpired files carrying the tag as an uncompressed tar archive. Entries are named \"\u003cfile ID\u003e_\u003cname\u003e\" and carry the file's creation time. The archive is written incrementally; if reading a file fails midway the response ends without the end-of-archive marker, so the client sees a truncated archive.",
+                "produces": [
+                    "application/x-tar"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Download files by tag as TAR",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tag",
+                        "name": "tag",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tar archive",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing tag",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/info/{uuid}": {
+            "get": {
+                "description": "Get detailed information about a file",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Get file info",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include base64 content (up to EXTENDED_INFO_MAX_SIZE)",
+                        "name": "extended",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "'stream' redirects (303) to the download URL instead of embedding the content",
+                        "name": "content",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.FileInfo"
+                        }
+                    },
+                    "307": {
+                        "description": "File stored on another cluster node (CLUSTER_NODES), Location points to it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "Extended info of an expired file (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "Content larger than EXTENDED_INFO_MAX_SIZE, use the download URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/list": {
+            "get": {
+                "description": "Lists unexpired (or pinned) files carrying the tag, ordered by ID. Page through the list by passing next_after as ?after=; the last page has no next_after. Used by the export-dir tool.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "List files by tag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tag",
+                        "name": "tag",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Return files with ID greater than this (next_after of the previous page)",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max number of files (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.FileListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing tag or invalid limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/old/by-label/{label}": {
+            "get": {
+                "description": "Same as GET /v2/files/list?tag={label}, under the old Cumulus path for legacy consumers: unexpired (or pinned) files carrying the label, ordered by ID. Page through the list by passing next_after as ?after=.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "List files by label",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Label (tag)",
+                        "name": "label",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Return files with ID greater than this (next_after of the previous page)",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max number of files (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.FileListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/old/info/{cumulus_id}": {
+            "get": {
+                "description": "Get detailed information about a file by its old Cumulus ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Get file info by old Cumulus ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Old CumulusID",
+                        "name": "cumulus_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include base64 content (up to EXTENDED_INFO_MAX_SIZE)",
+                        "name": "extended",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "'stream' redirects (303) to the download URL instead of embedding the content",
+                        "name": "content",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.FileInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "Extended info of an expired file (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "Content larger than EXTENDED_INFO_MAX_SIZE, use the download URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/old/{cumulus_id}": {
+            "get": {
+                "description": "Downloads a file by its old CumulusID. The ETag is the BLAKE2b-256 hash of the content; If-None-Match with it gets 304 Not Modified. HEAD returns the headers (Content-Length, Content-Type, Content-Disposition, ETag) from the metadata without reading the stored content.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Download a file by old CumulusID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Old CumulusID",
+                        "name": "cumulus_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached copy (quoted content hash); 304 when it matches",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial content (Range)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified (If-None-Match matches the ETag)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "Downloads a file by its old CumulusID. The ETag is the BLAKE2b-256 hash of the content; If-None-Match with it gets 304 Not Modified. HEAD returns the headers (Content-Length, Content-Type, Content-Disposition, ETag) from the metadata without reading the stored content.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Download a file by old CumulusID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Old CumulusID",
+                        "name": "cumulus_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached copy (quoted content hash); 304 when it matches",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial content (Range)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified (If-None-Match matches the ETag)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/prefetch": {
+            "post": {
+                "description": "Starts an asynchronous job that prepares up to 10000 files for a batch of downloads (ML, reporting pipelines), so the pipeline doesn't pay cold latency per file: the stored content of each file is read once into the page cache, and a file whose volume file is missing is copied from the cold tier (READ_FALLBACK_DIR) into a local volume, which also heals it. Files sharing content are read once. On a writable node a bearer token needs the write scope, since rehydration writes volumes. At most 4 jobs run at a time, another request gets 429 with Retry-After. The job is polled with GET /v2/files/prefetch/{id}; its summary (warmed, rehydrated, notFound, failed, bytesRead) is the progress of the finished job. On a read-only replica files are only read, a file with a missing volume file counts as failed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Prefetch files",
+                "parameters": [
+                    {
+                        "description": "File IDs",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.PrefetchRequest"
+                        },
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PrefetchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, no IDs or more than 10000 IDs",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many prefetch jobs running",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Bearer token without the write scope on a writable node",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/prefetch/{id}": {
+            "get": {
+                "description": "Returns a prefetch job started by POST /v2/files/prefetch, like /system/jobs but limited to prefetch jobs so pipelines need no access to /system. progress holds the summary JSON once the job is completed. Jobs are kept for an hour after they finish.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Get prefetch job",
+                "parameters": [
+                    {
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "type": "string",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Job"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/tags": {
+            "post": {
+                "description": "Adds and/or removes tags on a list of files (fileIds) or on all files matching a filter, in one transaction.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Batch tag files",
+                "parameters": [
+                    {
+                        "description": "Files (fileIds or filter) and tags to add/remove",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.BatchTagRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.BatchTagResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/tmp": {
+            "get": {
+                "description": "Lists unexpired files tagged 'temporary' (uploaded through POST /v2/files/tmp or tagged by hand), ordered by ID. Page through the list by passing next_after as ?after=.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "List temporary files",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Return files with ID greater than this (next_after of the previous page)",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max number of files (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.FileListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Uploads a file like /v2/files/upload, for transient content such as exports. Without validity the file expires after TEMP_FILE_VALIDITY (default '1 day'); the tag 'temporary' is always added. Accepts the same fields, batch uploads and If-None-Match as /v2/files/upload.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Upload a temporary file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "File to upload",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Additional tags like array of string or coma separated strings",
+                        "name": "tags",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Validity period (e.g. '1 day', '2 weeks'); default TEMP_FILE_VALIDITY",
+                        "name": "validity",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Absolute expiry time (RFC 3339), instead of validity",
+                        "name": "expires_at",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Legacy ID",
+                        "name": "old_cumulus_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Content-Disposition of downloads: 'inline' or 'attachment' (default: by MIME type)",
+                        "name": "disposition",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Quoted BLAKE2b-256 hash of the content; if already stored and in scope (admin Basic auth, or tags a live file of the content carries), the body is not read",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "With If-None-Match: link the stored content under this filename",
+                        "name": "filename",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include hash, sizes, dedup flag, detected MIME type and expiry in the response",
+                        "name": "verbose",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Content already stored, file linked without upload (If-None-Match + filename)",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "File uploaded successfully, returns file UUID",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Content already stored (If-None-Match without filename)",
+                        "schema": {
+                            "$ref": "#/definitions/api.ExistingBlobResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "File larger than MAX_UPLOAD_FILE_SIZE, maxSize is the limit",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadTooLargeResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Upload rejected by the upload hook (UPLOAD_HOOK_URL), body is its reason",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Upload hook unavailable (UPLOAD_HOOK_FAIL_OPEN off)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/upload": {
+            "post": {
+                "description": "Uploads a file to the storage",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Upload a file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "File to upload",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tags like array of string or coma separated strings",
+                        "name": "tags",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Legacy ID",
+                        "name": "old_cumulus_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "When old_cumulus_id belongs to another file: 'reject' (409, default) or 'supersede' (move the ID to the new file)",
+                        "name": "on_conflict",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Content-Disposition of downloads: 'inline' or 'attachment' (default: by MIME type)",
+                        "name": "disposition",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Validity period (e.g. '1 day', '2 weeks', '1 year'), within VALIDITY_MIN..VALIDITY_MAX",
+                        "name": "validity",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Absolute expiry time (RFC 3339), instead of validity",
+                        "name": "expires_at",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Original MIME type, used when content detection yields generic binary",
+                        "name": "content_type",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Original creation time (RFC 3339, 'YYYY-MM-DD HH:MM:SS' or unix seconds); requires admin Basic auth",
+                        "name": "created_at",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Quoted BLAKE2b-256 hash of the content; if already stored and in scope (admin Basic auth, or tags a live file of the content carries), the body is not read",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "With If-None-Match: link the stored content under this filename (old_cumulus_id, on_conflict, disposition, validity, expires_at, tags also from query)",
+                        "name": "filename",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include hash, sizes, dedup flag, detected MIME type and expiry in the response",
+                        "name": "verbose",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Content already stored, file linked without upload (If-None-Match + filename)",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadResponse"
+                        },
+                        "headers": {
+                            "X-Cumulus-Blob-Id": {
+                                "type": "string",
+                                "description": "ID of the blob holding the content"
+                            },
+                            "X-Cumulus-Dedup": {
+                                "type": "string",
+                                "description": "hit when the content was already stored, miss otherwise"
+                            }
+                        }
+                    },
+                    "201": {
+                        "description": "File uploaded successfully, returns file UUID",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadResponse"
+                        },
+                        "headers": {
+                            "X-Cumulus-Blob-Id": {
+                                "type": "string",
+                                "description": "ID of the blob holding the content"
+                            },
+                            "X-Cumulus-Dedup": {
+                                "type": "string",
+                                "description": "hit when the content was already stored, miss otherwise"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "created_at without admin credentials",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Content already stored (If-None-Match without filename)",
+                        "schema": {
+                            "$ref": "#/definitions/api.ExistingBlobResponse"
+                        },
+                        "headers": {
+                            "X-Cumulus-Blob-Id": {
+                                "type": "string",
+                                "description": "ID of the blob holding the content"
+                            },
+                            "X-Cumulus-Dedup": {
+                                "type": "string",
+                                "description": "hit when the content was already stored, miss otherwise"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "File larger than MAX_UPLOAD_FILE_SIZE, maxSize is the limit",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadTooLargeResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Upload rejected by the upload hook (UPLOAD_HOOK_URL), body is its reason",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Upload hook unavailable (UPLOAD_HOOK_FAIL_OPEN off)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Stores the request body as one file, without multipart encoding. The length does not have to be known in advance: with Transfer-Encoding: chunked the content is processed as it arrives and the upload fails with 413 as soon as it exceeds MAX_UPLOAD_FILE_SIZE; a Content-Length above the limit is refused before the body is read (also before 100 Continue). The filename and the options of the multipart upload are query parameters; the Content-Type header plays the role of the part Content-Type. If-None-Match works as with POST.",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Upload a file as the raw request body",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filename of the stored file",
+                        "name": "filename",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tags like array of string or coma separated strings",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Legacy ID",
+                        "name": "old_cumulus_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "When old_cumulus_id belongs to another file: 'reject' (409, default) or 'supersede' (move the ID to the new file)",
+                        "name": "on_conflict",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Content-Disposition of downloads: 'inline' or 'attachment' (default: by MIME type)",
+                        "name": "disposition",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Validity period (e.g. '1 day', '2 weeks', '1 year'), within VALIDITY_MIN..VALIDITY_MAX",
+                        "name": "validity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Absolute expiry time (RFC 3339), instead of validity",
+                        "name": "expires_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Original MIME type, used when content detection yields generic binary (wins over the Content-Type header)",
+                        "name": "content_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Original creation time (RFC 3339, 'YYYY-MM-DD HH:MM:SS' or unix seconds); requires admin Basic auth",
+                        "name": "created_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Quoted BLAKE2b-256 hash of the content; if already stored and in scope (admin Basic auth, or tags a live file of the content carries), the body is not read",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include hash, sizes, dedup flag, detected MIME type and expiry in the response",
+                        "name": "verbose",
+                        "in": "query"
+                    },
+                    {
+                        "description": "File content",
+                        "name": "file",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "File uploaded successfully, returns file UUID",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadResponse"
+                        },
+                        "headers": {
+                            "X-Cumulus-Blob-Id": {
+                                "type": "string",
+                                "description": "ID of the blob holding the content"
+                            },
+                            "X-Cumulus-Dedup": {
+                                "type": "string",
+                                "description": "hit when the content was already stored, miss otherwise"
+                            }
+                        }
+                    },
+                    "200": {
+                        "description": "Content already stored, file linked without upload (If-None-Match + filename)",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadResponse"
+                        },
+                        "headers": {
+                            "X-Cumulus-Blob-Id": {
+                                "type": "string",
+                                "description": "ID of the blob holding the content"
+                            },
+                            "X-Cumulus-Dedup": {
+                                "type": "string",
+                                "description": "hit when the content was already stored, miss otherwise"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "created_at without admin credentials",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Content already stored (If-None-Match without filename)",
+                        "schema": {
+                            "$ref": "#/definitions/api.ExistingBlobResponse"
+                        },
+                        "headers": {
+                            "X-Cumulus-Blob-Id": {
+                                "type": "string",
+                                "description": "ID of the blob holding the content"
+                            },
+                            "X-Cumulus-Dedup": {
+                                "type": "string",
+                                "description": "hit when the content was already stored, miss otherwise"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "File larger than MAX_UPLOAD_FILE_SIZE, maxSize is the limit",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadTooLargeResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Upload rejected by the upload hook (UPLOAD_HOOK_URL), body is its reason",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Upload hook unavailable (UPLOAD_HOOK_FAIL_OPEN off)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/validate": {
+            "post": {
+                "description": "Returns whether an upload with the given name, size, content type, hash and upload options would be accepted, so clients can skip large uploads that would fail. Checks the upload size limit (413), the content_type, disposition, validity and expiresAt values (400) and an old_cumulus_id taken by other content with on_conflict=reject (409). With a hash of already stored content, dedup is true and the upload can be replaced by If-None-Match. Nothing is stored or reserved.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Validate an upload",
+                "parameters": [
+                    {
+                        "description": "Planned upload",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadValidationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadValidationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/{name}": {
+            "put": {
+                "description": "Stores the request body under the filename in the path, like PUT /v2/files/upload but with the options as headers: X-Tags, X-Validity, X-Expires-At, X-Old-Cumulus-Id, X-On-Conflict, X-Disposition and X-Created-At (query parameters of the same meaning are accepted too, a header wins). The length does not have to be known in advance (Transfer-Encoding: chunked). The filename is the last path segment, percent-encoded; a file named \"upload\" has to use PUT /v2/files/upload?filename=upload.",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Upload a file as the raw request body, metadata in headers",
+                "parameters": [
+                    {
+                        "description": "Filename of the stored file",
+                        "name": "name",
+                        "in": "path",
+                        "type": "string",
+                        "required": true
+                    },
+                    {
+                        "description": "Tags like array of string or coma separated strings",
+                        "name": "X-Tags",
+                        "in": "header",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Validity period (e.g. '1 day', '2 weeks', '1 year'), within VALIDITY_MIN..VALIDITY_MAX",
+                        "name": "X-Validity",
+                        "in": "header",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Absolute expiry time (RFC 3339), instead of X-Validity",
+                        "name": "X-Expires-At",
+                        "in": "header",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Legacy ID",
+                        "name": "X-Old-Cumulus-Id",
+                        "in": "header",
+                        "type": "integer"
+                    },
+                    {
+                        "description": "When X-Old-Cumulus-Id belongs to another file: 'reject' (409, default) or 'supersede' (move the ID to the new file)",
+                        "name": "X-On-Conflict",
+                        "in": "header",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Content-Disposition of downloads: 'inline' or 'attachment' (default: by MIME type)",
+                        "name": "X-Disposition",
+                        "in": "header",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Original creation time (RFC 3339, 'YYYY-MM-DD HH:MM:SS' or unix seconds); requires admin Basic auth",
+                        "name": "X-Created-At",
+                        "in": "header",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Original MIME type, used when content detection yields generic binary",
+                        "name": "Content-Type",
+                        "in": "header",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Quoted BLAKE2b-256 hash of the content; if already stored and in scope (admin Basic auth, or tags a live file of the content carries), the body is not read and the file is linked",
+                        "name": "If-None-Match",
+                        "in": "header",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Include hash, sizes, dedup flag, detected MIME type and expiry in the response",
+                        "name": "verbose",
+                        "in": "query",
+                        "type": "boolean"
+                    },
+                    {
+                        "description": "File content",
+                        "name": "file",
+                        "in": "body",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "File uploaded successfully, returns file UUID",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadResponse"
+                        },
+                        "headers": {
+                            "X-Cumulus-Blob-Id": {
+                                "type": "string",
+                                "description": "ID of the blob holding the content"
+                            },
+                            "X-Cumulus-Dedup": {
+                                "type": "string",
+                                "description": "hit when the content was already stored, miss otherwise"
+                            }
+                        }
+                    },
+                    "200": {
+                        "description": "Content already stored, file linked without upload (If-None-Match)",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadResponse"
+                        },
+                        "headers": {
+                            "X-Cumulus-Blob-Id": {
+                                "type": "string",
+                                "description": "ID of the blob holding the content"
+                            },
+                            "X-Cumulus-Dedup": {
+                                "type": "string",
+                                "description": "hit when the content was already stored, miss otherwise"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "X-Created-At without admin credentials",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "X-Old-Cumulus-Id belongs to another file (X-On-Conflict: reject)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "File larger than MAX_UPLOAD_FILE_SIZE, maxSize is the limit",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadTooLargeResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Upload rejected by the upload hook (UPLOAD_HOOK_URL), body is its reason",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Upload hook unavailable (UPLOAD_HOOK_FAIL_OPEN off)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/{uuid}": {
+            "get": {
+                "description": "Downloads a file by its UUID. The Cache-Control header is set by the DOWNLOAD_CACHE_CONTROL rules for the MIME type (none by default). The ETag is the BLAKE2b-256 hash of the content; If-None-Match with it gets 304 Not Modified. HEAD returns the headers (Content-Length, Content-Type, Content-Disposition, ETag) from the metadata without reading the stored content.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Download a file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached copy (quoted content hash); 304 when it matches",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry (unix seconds) of a presigned URL, see POST /v2/files/{uuid}/presign",
+                        "name": "expires",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of a presigned URL; a valid one skips auth",
+                        "name": "sig",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial content (Range)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified (If-None-Match matches the ETag)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "307": {
+                        "description": "File stored on another cluster node (CLUSTER_NODES), Location points to it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired signature (sig)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "Downloads a file by its UUID. The Cache-Control header is set by the DOWNLOAD_CACHE_CONTROL rules for the MIME type (none by default). The ETag is the BLAKE2b-256 hash of the content; If-None-Match with it gets 304 Not Modified. HEAD returns the headers (Content-Length, Content-Type, Content-Disposition, ETag) from the metadata without reading the stored content.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Download a file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached copy (quoted content hash); 304 when it matches",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry (unix seconds) of a presigned URL, see POST /v2/files/{uuid}/presign",
+                        "name": "expires",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of a presigned URL; a valid one skips auth",
+                        "name": "sig",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial content (Range)",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified (If-None-Match matches the ETag)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "307": {
+                        "description": "File stored on another cluster node (CLUSTER_NODES), Location points to it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired signature (sig)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "416": {
+                        "description": "Range not satisfiable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/{uuid}/hash": {
+            "get": {
+                "description": "Returns the stored BLAKE2b-256 hash (and optionally SHA-256) so copies can be verified without downloading the content",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Get file hash",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also compute SHA-256 (reads the content on the server)",
+                        "name": "sha256",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.FileHash"
+                        }
+                    },
+                    "307": {
+                        "description": "File stored on another cluster node (CLUSTER_NODES), Location points to it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/files/{uuid}/presign": {
+            "post": {
+                "description": "Returns a download URL of the file signed with DOWNLOAD_SIGNING_KEY that works without credentials (also when the files routes require auth) until it expires, so the file can be shared with external users. Only GET/HEAD of the signed path is allowed; an invalid or expired signature gets 403. The URL is relative, prefix it with the public address of the server.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Create a signed download URL",
+                "parameters": [
+                    {
+                        "description": "File UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "type": "string",
+                        "required": true
+                    },
+                    {
+                        "description": "Lifetime of the URL as a Go duration (e.g. 1h, 72h), default 24h, at most PRESIGNED_URL_MAX_TTL",
+                        "name": "ttl",
+                        "in": "query",
+                        "type": "string"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Signed URL",
+                        "schema": {
+                            "$ref": "#/definitions/api.PresignResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ttl",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found or presigned URLs disabled (DOWNLOAD_SIGNING_KEY not set)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/images/old/{cumulus_id}": {
+            "get": {
+                "description": "Same as /v2/images/{uuid} for legacy consumers that only know the old CumulusID. The ETag contains the current file UUID, so a superseded ID is revalidated.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png"
+                ],
+                "tags": [
+                    "03 - Images"
+                ],
+                "summary": "Get image or image variant by old CumulusID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Old CumulusID",
+                        "name": "cumulus_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 signature of the URL path (IMAGE_SIGNING_KEY), required for {width}x{height}",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: page number (default 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: render resolution 36-300 (default by variant size)",
+                        "name": "dpi",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Image content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid signature",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Not an image or PDF",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Image over IMAGE_MAX_INPUT_SIZE or IMAGE_MAX_PIXELS",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Processing timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/images/old/{cumulus_id}/lg": {
+            "get": {
+                "description": "Same as /v2/images/{uuid} for legacy consumers that only know the old CumulusID. The ETag contains the current file UUID, so a superseded ID is revalidated.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png"
+                ],
+                "tags": [
+                    "03 - Images"
+                ],
+                "summary": "Get image or image variant by old CumulusID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Old CumulusID",
+                        "name": "cumulus_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 signature of the URL path (IMAGE_SIGNING_KEY), required for {width}x{height}",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: page number (default 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: render resolution 36-300 (default by variant size)",
+                        "name": "dpi",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Image content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid signature",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Not an image or PDF",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Image over IMAGE_MAX_INPUT_SIZE or IMAGE_MAX_PIXELS",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Processing timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/images/old/{cumulus_id}/md": {
+            "get": {
+                "description": "Same as /v2/images/{uuid} for legacy consumers that only know the old CumulusID. The ETag contains the current file UUID, so a superseded ID is revalidated.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png"
+                ],
+                "tags": [
+                    "03 - Images"
+                ],
+                "summary": "Get image or image variant by old CumulusID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Old CumulusID",
+                        "name": "cumulus_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 signature of the URL path (IMAGE_SIGNING_KEY), required for {width}x{height}",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: page number (default 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: render resolution 36-300 (default by variant size)",
+                        "name": "dpi",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Image content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid signature",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Not an image or PDF",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Image over IMAGE_MAX_INPUT_SIZE or IMAGE_MAX_PIXELS",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Processing timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/images/old/{cumulus_id}/sm": {
+            "get": {
+                "description": "Same as /v2/images/{uuid} for legacy consumers that only know the old CumulusID. The ETag contains the current file UUID, so a superseded ID is revalidated.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png"
+                ],
+                "tags": [
+                    "03 - Images"
+                ],
+                "summary": "Get image or image variant by old CumulusID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Old CumulusID",
+                        "name": "cumulus_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 signature of the URL path (IMAGE_SIGNING_KEY), required for {width}x{height}",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: page number (default 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: render resolution 36-300 (default by variant size)",
+                        "name": "dpi",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Image content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid signature",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Not an image or PDF",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Image over IMAGE_MAX_INPUT_SIZE or IMAGE_MAX_PIXELS",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Processing timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/images/old/{cumulus_id}/thumb": {
+            "get": {
+                "description": "Same as /v2/images/{uuid} for legacy consumers that only know the old CumulusID. The ETag contains the current file UUID, so a superseded ID is revalidated.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png"
+                ],
+                "tags": [
+                    "03 - Images"
+                ],
+                "summary": "Get image or image variant by old CumulusID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Old CumulusID",
+                        "name": "cumulus_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 signature of the URL path (IMAGE_SIGNING_KEY), required for {width}x{height}",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: page number (default 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: render resolution 36-300 (default by variant size)",
+                        "name": "dpi",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Image content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid signature",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Not an image or PDF",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Image over IMAGE_MAX_INPUT_SIZE or IMAGE_MAX_PIXELS",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Processing timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/images/{uuid}": {
+            "get": {
+                "description": "Downloads original image or resized variant (thumb, sm, md, lg). For PDF files, generates thumbnail.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png"
+                ],
+                "tags": [
+                    "03 - Images"
+                ],
+                "summary": "Get image or image variant",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 signature of the URL path (IMAGE_SIGNING_KEY), required for {width}x{height}",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: page number (default 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: render resolution 36-300 (default by variant size)",
+                        "name": "dpi",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Image content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "307": {
+                        "description": "File stored on another cluster node (CLUSTER_NODES), Location points to it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid signature",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Not an image or PDF",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Image over IMAGE_MAX_INPUT_SIZE or IMAGE_MAX_PIXELS",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Processing timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/images/{uuid}/lg": {
+            "get": {
+                "description": "Downloads original image or resized variant (thumb, sm, md, lg). For PDF files, generates thumbnail.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png"
+                ],
+                "tags": [
+                    "03 - Images"
+                ],
+                "summary": "Get image or image variant",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 signature of the URL path (IMAGE_SIGNING_KEY), required for {width}x{height}",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: page number (default 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: render resolution 36-300 (default by variant size)",
+                        "name": "dpi",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Image content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "307": {
+                        "description": "File stored on another cluster node (CLUSTER_NODES), Location points to it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid signature",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Not an image or PDF",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Image over IMAGE_MAX_INPUT_SIZE or IMAGE_MAX_PIXELS",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Processing timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/images/{uuid}/md": {
+            "get": {
+                "description": "Downloads original image or resized variant (thumb, sm, md, lg). For PDF files, generates thumbnail.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png"
+                ],
+                "tags": [
+                    "03 - Images"
+                ],
+                "summary": "Get image or image variant",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 signature of the URL path (IMAGE_SIGNING_KEY), required for {width}x{height}",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: page number (default 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: render resolution 36-300 (default by variant size)",
+                        "name": "dpi",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Image content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "307": {
+                        "description": "File stored on another cluster node (CLUSTER_NODES), Location points to it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid signature",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Not an image or PDF",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Image over IMAGE_MAX_INPUT_SIZE or IMAGE_MAX_PIXELS",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Processing timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/images/{uuid}/sm": {
+            "get": {
+                "description": "Downloads original image or resized variant (thumb, sm, md, lg). For PDF files, generates thumbnail.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png"
+                ],
+                "tags": [
+                    "03 - Images"
+                ],
+                "summary": "Get image or image variant",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 signature of the URL path (IMAGE_SIGNING_KEY), required for {width}x{height}",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: page number (default 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: render resolution 36-300 (default by variant size)",
+                        "name": "dpi",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Image content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "307": {
+                        "description": "File stored on another cluster node (CLUSTER_NODES), Location points to it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid signature",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Not an image or PDF",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Image over IMAGE_MAX_INPUT_SIZE or IMAGE_MAX_PIXELS",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Processing timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/images/{uuid}/thumb": {
+            "get": {
+                "description": "Downloads original image or resized variant (thumb, sm, md, lg). For PDF files, generates thumbnail.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png"
+                ],
+                "tags": [
+                    "03 - Images"
+                ],
+                "summary": "Get image or image variant",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File UUID",
+                        "name": "uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 signature of the URL path (IMAGE_SIGNING_KEY), required for {width}x{height}",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: page number (default 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "PDF preview: render resolution 36-300 (default by variant size)",
+                        "name": "dpi",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Image content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "307": {
+                        "description": "File stored on another cluster node (CLUSTER_NODES), Location points to it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid signature",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "File expired (EXPIRED_ACCESS=deny)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Not an image or PDF",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Image over IMAGE_MAX_INPUT_SIZE or IMAGE_MAX_PIXELS",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Processing timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/policy": {
+            "get": {
+                "description": "Returns the limits uploads are checked against, so clients can validate files and build upload forms without hardcoding them: maximum size (MAX_UPLOAD_FILE_SIZE), accepted content types, disposition and on_conflict values, validity bounds and units (VALIDITY_MIN, VALIDITY_MAX, VALIDITY_UNITS, TEMP_FILE_VALIDITY), compression behavior (USE_COMPRESS, MINIMAL_COMPRESSION) and image processing limits. uploadHook tells that an external service (UPLOAD_HOOK_URL) may refuse uploads beyond these limits. The policy changes only with a restart. Use POST /v2/files/validate to check a concrete upload.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Upload policy",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadPolicy"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/tags": {
+            "get": {
+                "description": "Returns tags with the number of files carrying them, ordered by count. Tags are not indexed, every call scans all files - cache the result for autocomplete.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "List tags",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive tag prefix",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max number of tags (default 50, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "tags: [{tag, count}], totalTags, taggedFiles",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/uploads": {
+            "post": {
+                "description": "Creates an upload session for a file of Upload-Length bytes (at most MAX_UPLOAD_FILE_SIZE). The content is then sent in any number of PATCH requests to the Location, each continuing at the Upload-Offset the server reports; after a broken connection the client asks HEAD for the offset and continues from there instead of restarting. The filename and the options of the upload are query parameters, checked now and applied when the last chunk arrives (validity counts from the creation of the session). Sessions are kept UPLOAD_SESSION_TTL after their last chunk.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Start a resumable upload",
+                "parameters": [
+                    {
+                        "description": "Size of the file in bytes",
+                        "name": "Upload-Length",
+                        "in": "header",
+                        "type": "integer",
+                        "required": true
+                    },
+                    {
+                        "description": "Filename of the stored file",
+                        "name": "filename",
+                        "in": "query",
+                        "type": "string",
+                        "required": true
+                    },
+                    {
+                        "description": "Tags like array of string or coma separated strings",
+                        "name": "tags",
+                        "in": "query",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Legacy ID",
+                        "name": "old_cumulus_id",
+                        "in": "query",
+                        "type": "integer"
+                    },
+                    {
+                        "description": "When old_cumulus_id belongs to another file: 'reject' (409, default) or 'supersede' (move the ID to the new file)",
+                        "name": "on_conflict",
+                        "in": "query",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Content-Disposition of downloads: 'inline' or 'attachment' (default: by MIME type)",
+                        "name": "disposition",
+                        "in": "query",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Validity period (e.g. '1 day', '2 weeks', '1 year'), within VALIDITY_MIN..VALIDITY_MAX",
+                        "name": "validity",
+                        "in": "query",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Absolute expiry time (RFC 3339), instead of validity",
+                        "name": "expires_at",
+                        "in": "query",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Original MIME type, used when content detection yields generic binary",
+                        "name": "content_type",
+                        "in": "query",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Original creation time (RFC 3339, 'YYYY-MM-DD HH:MM:SS' or unix seconds); requires admin Basic auth",
+                        "name": "created_at",
+                        "in": "query",
+                        "type": "string"
+                    },
+                    {
+                        "description": "Include hash, sizes, dedup flag, detected MIME type and expiry in the final response",
+                        "name": "verbose",
+                        "in": "query",
+                        "type": "boolean"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Session created, Location is its URL",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadSession"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "created_at without admin credentials",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Resumable uploads are disabled (UPLOAD_SESSION_TTL=0)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "File larger than MAX_UPLOAD_FILE_SIZE, maxSize is the limit",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadTooLargeResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v2/uploads/{id}": {
+            "patch": {
+                "description": "Appends the request body to the upload session. Upload-Offset must equal the number of bytes the server has (HEAD), otherwise 409 reports the current offset in Upload-Offset. Bytes received before a connection broke are kept. Answers 204 with the new Upload-Offset; the chunk that completes the file stores it like a normal upload and answers 201 with the upload response, which also stays in the session. If storing fails (e.g. 422 from the upload hook), an empty PATCH at the full offset retries it.",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Upload a chunk of a resumable upload",
+                "parameters": [
+                    {
+                        "description": "Upload session ID",
+                        "name": "id",
+                        "in": "path",
+                        "type": "string",
+                        "required": true
+                    },
+                    {
+                        "description": "Offset the chunk starts at",
+                        "name": "Upload-Offset",
+                        "in": "header",
+                        "type": "integer",
+                        "required": true
+                    },
+                    {
+                        "description": "Chunk content",
+                        "name": "chunk",
+                        "in": "body",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Last chunk received, file stored",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadResponse"
+                        },
+                        "headers": {
+                            "X-Cumulus-Blob-Id": {
+                                "type": "string",
+                                "description": "ID of the blob holding the content"
+                            },
+                            "X-Cumulus-Dedup": {
+                                "type": "string",
+                                "description": "hit when the content was already stored, miss otherwise"
+                            }
+                        }
+                    },
+                    "204": {
+                        "description": "Chunk stored, Upload-Offset is the new offset",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing Upload-Offset or chunk interrupted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Upload session not found or expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Upload-Offset does not match or the upload is already complete",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "Chunk exceeds Upload-Length",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Upload rejected by the upload hook (UPLOAD_HOOK_URL), body is its reason",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "423": {
+                        "description": "Another request is writing to the session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "get": {
+                "description": "Returns the received offset and, once complete, the upload response. HEAD returns only the Upload-Offset, Upload-Length and Upload-Expires headers – the way to find where to continue after a broken connection.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Get the state of a resumable upload",
+                "parameters": [
+                    {
+                        "description": "Upload session ID",
+                        "name": "id",
+                        "in": "path",
+                        "type": "string",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Upload-Offset, Upload-Length and Upload-Expires headers; body only with GET",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadSession"
+                        }
+                    },
+                    "404": {
+                        "description": "Upload session not found or expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "Returns the received offset and, once complete, the upload response. HEAD returns only the Upload-Offset, Upload-Length and Upload-Expires headers – the way to find where to continue after a broken connection.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Get the state of a resumable upload",
+                "parameters": [
+                    {
+                        "description": "Upload session ID",
+                        "name": "id",
+                        "in": "path",
+                        "type": "string",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Upload-Offset, Upload-Length and Upload-Expires headers; body only with GET",
+                        "schema": {
+                            "$ref": "#/definitions/api.UploadSession"
+                        }
+                    },
+                    "404": {
+                        "description": "Upload session not found or expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes the upload session and the content received so far. A file already stored by the session is not affected.",
+                "tags": [
+                    "02 - Files"
+                ],
+                "summary": "Abort a resumable upload",
+                "parameters": [
+                    {
+                        "description": "Upload session ID",
+                        "name": "id",
+                        "in": "path",
+                        "type": "string",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Session deleted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Upload session not found or expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "423": {
+                        "description": "Another request is writing to the session",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
     "definitions": {
+        "api.APIKeyCreatedResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "key": {
+                    "type": "string",
+                    "example": "ck_3fA9xQm1VbT0k2Lr8sYwZpN4hJc6dEgU7iOaF5tRq1M"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "billing-app"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "ck_3fA9x"
+                },
+                "revokedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.APIKeyListResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.APIKey"
+                    }
+                }
+            }
+        },
+        "api.APIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "billing-app"
+                }
+            }
+        },
+        "api.ActivityClient": {
+            "type": "object",
+            "properties": {
+                "client": {
+                    "type": "string",
+                    "example": "gallery"
+                },
+                "deletes": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "downloadBytes": {
+                    "type": "integer",
+                    "example": 1468006400
+                },
+                "downloads": {
+                    "type": "integer",
+                    "example": 3400
+                },
+                "uploadBytes": {
+                    "type": "integer",
+                    "example": 73400320
+                },
+                "uploads": {
+                    "type": "integer",
+                    "example": 120
+                }
+            }
+        },
+        "api.ActivityCounts": {
+            "type": "object",
+            "properties": {
+                "deletes": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "downloadBytes": {
+                    "type": "integer",
+                    "example": 1468006400
+                },
+                "downloads": {
+                    "type": "integer",
+                    "example": 3400
+                },
+                "uploadBytes": {
+                    "type": "integer",
+                    "example": 73400320
+                },
+                "uploads": {
+                    "type": "integer",
+                    "example": 120
+                }
+            }
+        },
+        "api.ActivityFileType": {
+            "type": "object",
+            "properties": {
+                "deletes": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "downloadBytes": {
+                    "type": "integer",
+                    "example": 1468006400
+                },
+                "downloads": {
+                    "type": "integer",
+                    "example": 3400
+                },
+                "mimeType": {
+                    "type": "string",
+                    "example": "image/jpeg"
+                },
+                "uploadBytes": {
+                    "type": "integer",
+                    "example": 73400320
+                },
+                "uploads": {
+                    "type": "integer",
+                    "example": 120
+                }
+            }
+        },
+        "api.ActivityHour": {
+            "type": "object",
+            "properties": {
+                "deletes": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "downloadBytes": {
+                    "type": "integer",
+                    "example": 1468006400
+                },
+                "downloads": {
+                    "type": "integer",
+                    "example": 3400
+                },
+                "hour": {
+                    "type": "string",
+                    "example": "2026-01-12T10:00:00Z"
+                },
+                "uploadBytes": {
+                    "type": "integer",
+                    "example": 73400320
+                },
+                "uploads": {
+                    "type": "integer",
+                    "example": 120
+                }
+            }
+        },
+        "api.ActivityReport": {
+            "type": "object",
+            "properties": {
+                "hours": {
+                    "type": "integer",
+                    "example": 24
+                },
+                "since": {
+                    "type": "string"
+                },
+                "timeline": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ActivityHour"
+                    }
+                },
+                "topClients": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ActivityClient"
+                    }
+                },
+                "topFileTypes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ActivityFileType"
+                    }
+                },
+                "total": {
+                    "$ref": "#/definitions/api.ActivityCounts"
+                }
+            }
+        },
+        "api.BackupReport": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "databaseBytes": {
+                    "type": "integer",
+                    "example": 73400320
+                },
+                "durationMs": {
+                    "type": "integer",
+                    "example": 5200
+                },
+                "error": {
+                    "type": "string"
+                },
+                "logBytes": {
+                    "description": "new metadata log bytes uploaded by the run",
+                    "type": "integer",
+                    "example": 184320
+                },
+                "manifest": {
+                    "description": "key of the written manifest",
+                    "type": "string"
+                },
+                "snapshotsDeleted": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "status": {
+                    "description": "ok, error",
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "api.BackupStatus": {
+            "type": "object",
+            "properties": {
+                "bucket": {
+                    "type": "string",
+                    "example": "cumulus-backup"
+                },
+                "interval": {
+                    "type": "string",
+                    "example": "1h0m0s"
+                },
+                "latest": {
+                    "description": "null before the first run",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.BackupReport"
+                        }
+                    ]
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "cumulus3/node-a"
+                },
+                "retention": {
+                    "type": "string",
+                    "example": "168h0m0s"
+                }
+            }
+        },
+        "api.BatchTagFilter": {
+            "type": "object",
+            "properties": {
+                "oldIdFrom": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "oldIdTo": {
+                    "type": "integer",
+                    "example": 100000
+                },
+                "tag": {
+                    "type": "string",
+                    "example": "migrated"
+                }
+            }
+        },
+        "api.BatchTagRequest": {
+            "type": "object",
+            "properties": {
+                "add": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "archive"
+                    ]
+                },
+                "fileIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "filter": {
+                    "$ref": "#/definitions/api.BatchTagFilter"
+                },
+                "remove": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "inbox"
+                    ]
+                }
+            }
+        },
+        "api.BatchTagResponse": {
+            "type": "object",
+            "properties": {
+                "matched": {
+                    "type": "integer",
+                    "example": 1000
+                },
+                "notFound": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "unchanged": {
+                    "type": "integer",
+                    "example": 10
+                },
+                "updated": {
+                    "type": "integer",
+                    "example": 990
+                }
+            }
+        },
+        "api.BatchUploadResult": {
+            "type": "object",
+            "properties": {
+                "cumulusID": {
+                    "type": "string",
+                    "example": "123456"
+                },
+                "dedup": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "error": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "fileID": {
+                    "type": "string",
+                    "example": "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"
+                },
+                "filename": {
+                    "type": "string",
+                    "example": "photo.jpg"
+                },
+                "hash": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "mime_type": {
+                    "type": "string",
+                    "example": "image/jpeg"
+                },
+                "size_compressed": {
+                    "type": "integer",
+                    "example": 524288
+                },
+                "size_raw": {
+                    "type": "integer",
+                    "example": 1048576
+                },
+                "status": {
+                    "description": "HTTP status the file would get as a single upload",
+                    "type": "integer",
+                    "example": 201
+                }
+            }
+        },
+        "api.BlobLookupRequest": {
+            "type": "object",
+            "properties": {
+                "hashes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                    ]
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "invoices"
+                    ]
+                }
+            }
+        },
+        "api.BlobLookupResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.BlobLookup"
+                    }
+                },
+                "stored": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.ClusterNodeStatus": {
+            "type": "object",
+            "properties": {
+                "heartbeatAt": {
+                    "description": "last heartbeat answered by the node itself",
+                    "type": "string"
+                },
+                "lastError": {
+                    "description": "error of the last heartbeat, empty after success",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "node-b"
+                },
+                "replicationLagSeconds": {
+                    "type": "number",
+                    "example": 2.5
+                },
+                "seenAt": {
+                    "description": "last time this node received a newer state",
+                    "type": "string"
+                },
+                "stateAt": {
+                    "description": "when the node produced the state (its clock)",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "up"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "http://10.0.0.2:8800"
+                },
+                "via": {
+                    "description": "node the state came through, empty = directly",
+                    "type": "string"
+                },
+                "volumes": {
+                    "description": "volume IDs the node holds blobs in",
+                    "type": "string",
+                    "example": "1-4,7"
+                }
+            }
+        },
+        "api.ClusterResponse": {
+            "type": "object",
+            "properties": {
+                "nodes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ClusterNodeStatus"
+                    }
+                },
+                "self": {
+                    "$ref": "#/definitions/api.ClusterNodeStatus"
+                }
+            }
+        },
+        "api.CompressionCategory": {
+            "type": "object",
+            "properties": {
+                "blobs": {
+                    "type": "integer",
+                    "example": 1200
+                },
+                "category": {
+                    "type": "string",
+                    "example": "image"
+                },
+                "rawBytes": {
+                    "type": "integer",
+                    "example": 524288000
+                },
+                "savedPercent": {
+                    "description": "1 - stored/raw, in %",
+                    "type": "number",
+                    "example": 1
+                },
+                "storedBytes": {
+                    "type": "integer",
+                    "example": 519045120
+                },
+                "uncompressedBlobs": {
+                    "description": "stored with compression none",
+                    "type": "integer",
+                    "example": 1100
+                },
+                "uncompressedPercent": {
+                    "description": "uncompressed blobs of all blobs, in %",
+                    "type": "number",
+                    "example": 91.67
+                }
+            }
+        },
+        "api.CompressionPolicy": {
+            "type": "object",
+            "properties": {
+                "minSavedPercent": {
+                    "type": "number",
+                    "example": 10,
+                    "description": "auto: compressed only when saving at least this"
+                },
+                "mode": {
+                    "description": "USE_COMPRESS",
+                    "type": "string",
+                    "enum": [
+                        "auto",
+                        "zstd",
+                        "gzip",
+                        "none"
+                    ],
+                    "example": "auto"
+                },
+                "sampleSize": {
+                    "type": "integer",
+                    "example": 1048576,
+                    "description": "auto: bytes compressed on trial"
+                },
+                "storedAsIs": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "description": "auto: already compressed types, \"*\" ends a prefix"
+                }
+            }
+        },
+        "api.CompressionReport": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.CompressionCategory"
+                    }
+                },
+                "total": {
+                    "$ref": "#/definitions/api.CompressionCategory"
+                }
+            }
+        },
+        "api.DedupReport": {
+            "type": "object",
+            "properties": {
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DedupTag"
+                    }
+                },
+                "total": {
+                    "description": "all files, tagged or not",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.DedupTag"
+                        }
+                    ]
+                }
+            }
+        },
+        "api.DedupTag": {
+            "type": "object",
+            "properties": {
+                "avgFileBytes": {
+                    "description": "logical / files",
+                    "type": "integer",
+                    "example": 1048576
+                },
+                "blobs": {
+                    "description": "distinct contents",
+                    "type": "integer",
+                    "example": 1200
+                },
+                "dedupRatio": {
+                    "description": "logical / unique",
+                    "type": "number",
+                    "example": 2.5
+                },
+                "files": {
+                    "type": "integer",
+                    "example": 3000
+                },
+                "logicalBytes": {
+                    "description": "raw size of every file",
+                    "type": "integer",
+                    "example": 3145728000
+                },
+                "savedBytes": {
+                    "description": "logical - unique",
+                    "type": "integer",
+                    "example": 1887436800
+                },
+                "savedPercent": {
+                    "description": "1 - unique/logical, in %",
+                    "type": "number",
+                    "example": 60
+                },
+                "storedBytes": {
+                    "description": "after compression",
+                    "type": "integer",
+                    "example": 943718400
+                },
+                "tag": {
+                    "type": "string",
+                    "example": "invoice"
+                },
+                "uniqueBytes": {
+                    "description": "raw size of the distinct contents",
+                    "type": "integer",
+                    "example": 1258291200
+                }
+            }
+        },
+        "api.DirForecast": {
+            "type": "object",
+            "properties": {
+                "daysUntilFull": {
+                    "type": "number"
+                },
+                "dir": {
+                    "type": "string",
+                    "example": "data"
+                },
+                "freeBytes": {
+                    "type": "integer"
+                },
+                "fullAt": {
+                    "type": "string"
+                },
+                "growthBytesPerDay": {
+                    "description": "negative when usage is shrinking",
+                    "type": "integer"
+                },
+                "historySince": {
+                    "type": "string"
+                },
+                "lastSample": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/data"
+                },
+                "samples": {
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "ok | warning | critical | unknown",
+                    "type": "string",
+                    "example": "ok"
+                },
+                "totalBytes": {
+                    "type": "integer"
+                },
+                "usedPercent": {
+                    "type": "number"
+                }
+            }
+        },
+        "api.ExistingBlobResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "content already stored"
+                },
+                "hash": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "hint": {
+                    "type": "string",
+                    "example": "repeat the request with ?filename=\u003cname\u003e to link the stored content"
+                },
+                "mimeType": {
+                    "type": "string",
+                    "example": "image/jpeg"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 1048576
+                }
+            }
+        },
+        "api.FileHashesRequest": {
+            "type": "object",
+            "properties": {
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.FileHashesResponse": {
+            "type": "object",
+            "properties": {
+                "hashes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.FileListResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.File"
+                    }
+                },
+                "next_after": {
+                    "description": "pass as ?after= for the next page",
+                    "type": "string",
+                    "example": "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"
+                }
+            }
+        },
+        "api.FileMoveRequest": {
+            "type": "object",
+            "properties": {
+                "volumeId": {
+                    "type": "integer",
+                    "example": 7
+                }
+            }
+        },
+        "api.FileRecompressRequest": {
+            "type": "object",
+            "properties": {
+                "compression": {
+                    "description": "auto, zstd, gzip or none",
+                    "type": "string",
+                    "example": "zstd"
+                }
+            }
+        },
+        "api.FileTypeRedetectRequest": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string",
+                    "example": "binary"
+                },
+                "dryRun": {
+                    "type": "boolean"
+                },
+                "mimeType": {
+                    "type": "string",
+                    "example": "application/octet-stream"
+                }
+            }
+        },
+        "api.ForecastReport": {
+            "type": "object",
+            "properties": {
+                "dirs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DirForecast"
+                    }
+                },
+                "status": {
+                    "description": "worst status of the directories, unknown without history",
+                    "type": "string",
+                    "example": "ok"
+                },
+                "warningDays": {
+                    "type": "integer",
+                    "example": 30
+                },
+                "window": {
+                    "type": "string",
+                    "example": "168h0m0s"
+                }
+            }
+        },
+        "api.HealListResponse": {
+            "type": "object",
+            "properties": {
+                "blobs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.BlobHeal"
+                    }
+                },
+                "count": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "api.ImageLimits": {
+            "type": "object",
+            "properties": {
+                "maxInputSize": {
+                    "type": "integer",
+                    "example": 52428800,
+                    "description": "0 = unlimited"
+                },
+                "maxPixels": {
+                    "type": "integer",
+                    "example": 50000000,
+                    "description": "0 = unlimited"
+                }
+            }
+        },
+        "api.Job": {
+            "type": "object",
+            "properties": {
+                "completedAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "progress": {
+                    "type": "string"
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/api.JobStatus"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "volumeId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.JobStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "running",
+                "completed",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "JobStatusPending",
+                "JobStatusRunning",
+                "JobStatusCompleted",
+                "JobStatusFailed"
+            ]
+        },
+        "api.LabelFileEntry": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "example": "2024-01-01T10:00:00Z"
+                },
+                "cumulusID": {
+                    "description": "missing for files uploaded without an old ID",
+                    "type": "integer",
+                    "example": 1001
+                },
+                "fileID": {
+                    "type": "string",
+                    "example": "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"
+                },
+                "filename": {
+                    "type": "string",
+                    "example": "invoice.pdf"
+                }
+            }
+        },
+        "api.LegalHoldListResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "holds": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.LegalHold"
+                    }
+                }
+            }
+        },
+        "api.LegalHoldRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "case 2026-117"
+                }
+            }
+        },
+        "api.OldIDExistsEntry": {
+            "type": "object",
+            "properties": {
+                "fileID": {
+                    "type": "string",
+                    "example": "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"
+                },
+                "hash": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 1048576
+                }
+            }
+        },
+        "api.OldIDExistsRequest": {
+            "type": "object",
+            "properties": {
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1001,
+                        1002,
+                        1003
+                    ]
+                }
+            }
+        },
+        "api.OldIDExistsResponse": {
+            "type": "object",
+            "properties": {
+                "found": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/api.OldIDExistsEntry"
+                    }
+                },
+                "missing": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "api.PinnedListResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.File"
+                    }
+                }
+            }
+        },
+        "api.PrefetchRequest": {
+            "type": "object",
+            "properties": {
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.PrefetchResponse": {
+            "type": "object",
+            "properties": {
+                "jobId": {
+                    "type": "string",
+                    "example": "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Prefetch of 250 files started"
+                }
+            }
+        },
+        "api.PresignResponse": {
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "type": "string",
+                    "example": "2026-01-01T00:00:00Z"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "/v2/files/550e8400-e29b-41d4-a716-446655440000?expires=1767225600\u0026sig=Qm9n..."
+                }
+            }
+        },
+        "api.PurgeRequest": {
+            "type": "object",
+            "properties": {
+                "auditPolicy": {
+                    "description": "metadata log records of the files, default PURGE_AUDIT_POLICY",
+                    "type": "string",
+                    "enum": [
+                        "redact",
+                        "remove"
+                    ]
+                },
+                "dryRun": {
+                    "description": "only list the files",
+                    "type": "boolean"
+                },
+                "tag": {
+                    "description": "all files carrying the tag are deleted",
+                    "type": "string",
+                    "example": "customer-42"
+                }
+            }
+        },
+        "api.ReplicaCountCheck": {
+            "type": "object",
+            "properties": {
+                "divergence": {
+                    "type": "number",
+                    "example": 0.0001
+                },
+                "local": {
+                    "type": "integer",
+                    "example": 120000
+                },
+                "replica": {
+                    "type": "integer",
+                    "example": 119990
+                }
+            }
+        },
+        "api.ReplicaSampleCheck": {
+            "type": "object",
+            "properties": {
+                "divergence": {
+                    "type": "number",
+                    "example": 0.01
+                },
+                "mismatched": {
+                    "description": "files with different content on the replica",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "missing": {
+                    "description": "files the replica does not have",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 100
+                }
+            }
+        },
+        "api.ReplicaVerifyReport": {
+            "type": "object",
+            "properties": {
+                "blobs": {
+                    "$ref": "#/definitions/api.ReplicaCountCheck"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "durationMs": {
+                    "type": "integer",
+                    "example": 840
+                },
+                "error": {
+                    "type": "string"
+                },
+                "files": {
+                    "$ref": "#/definitions/api.ReplicaCountCheck"
+                },
+                "replica": {
+                    "type": "string",
+                    "example": "http://replica:8800"
+                },
+                "sample": {
+                    "$ref": "#/definitions/api.ReplicaSampleCheck"
+                },
+                "status": {
+                    "description": "ok, diverged, error",
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "api.SLOAlert": {
+            "type": "object",
+            "properties": {
+                "kind": {
+                    "description": "latency | errors",
+                    "type": "string"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "target": {
+                    "type": "number"
+                },
+                "value": {
+                    "type": "number"
+                }
+            }
+        },
+        "api.SLOEndpoint": {
+            "type": "object",
+            "properties": {
+                "errorRate": {
+                    "type": "number"
+                },
+                "errors": {
+                    "type": "integer"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "p50Ms": {
+                    "type": "number"
+                },
+                "p95Ms": {
+                    "type": "number"
+                },
+                "p99Ms": {
+                    "type": "number"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "requests": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.SLOReport": {
+            "type": "object",
+            "properties": {
+                "alerts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SLOAlert"
+                    }
+                },
+                "endpoints": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SLOEndpoint"
+                    }
+                },
+                "errorRateTarget": {
+                    "type": "number"
+                },
+                "latencyTargetP99Ms": {
+                    "type": "number"
+                },
+                "since": {
+                    "description": "start of data collection (server start)",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "ok | alert",
+                    "type": "string"
+                },
+                "window": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.SlowQueriesResponse": {
+            "type": "object",
+            "properties": {
+                "queries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.SlowQuery"
+                    }
+                },
+                "thresholdMs": {
+                    "type": "number"
+                },
+                "total": {
+                    "description": "slow queries since start, including those dropped from the buffer",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.TakeoutRequest": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "absolute expiry (RFC 3339), instead of validity",
+                    "type": "string"
+                },
+                "tags": {
+                    "description": "files carrying any of the tags are exported",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "tenant-42"
+                    ]
+                },
+                "validity": {
+                    "description": "how long the archive stays downloadable, default TEMP_FILE_VALIDITY",
+                    "type": "string",
+                    "example": "7 days"
+                }
+            }
+        },
+        "api.TypeMismatchListResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "mismatches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.TypeMismatch"
+                    }
+                },
+                "total": {
+                    "description": "all flagged files",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "api.UploadPolicy": {
+            "type": "object",
+            "properties": {
+                "allowedTypes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "*/*"
+                    ]
+                },
+                "compression": {
+                    "$ref": "#/definitions/api.CompressionPolicy"
+                },
+                "dispositions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "inline",
+                        "attachment"
+                    ],
+                    "description": "without one it follows the MIME type"
+                },
+                "images": {
+                    "$ref": "#/definitions/api.ImageLimits"
+                },
+                "maxSize": {
+                    "type": "integer",
+                    "example": 52428800,
+                    "description": "bytes of one upload request"
+                },
+                "onConflict": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "reject",
+                        "supersede"
+                    ],
+                    "description": "the first one is the default"
+                },
+                "uploadHook": {
+                    "type": "boolean",
+                    "description": "UploadHook: uploads are also checked by an external policy service, which may refuse them with 422"
+                },
+                "validity": {
+                    "$ref": "#/definitions/api.ValidityLimits"
+                }
+            }
+        },
         "api.UploadResponse": {
             "type": "object",
             "properties": {
-                "fileID": {
+                "cumulusID": {
+                    "type": "string",
+                    "example": "123456"
+                },
+                "dedup": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "fileID": {
+                    "type": "string",
+                    "example": "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"
+                },
+                "hash": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "image": {
+                    "description": "size of an uploaded image",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.ImageInfo"
+                        }
+                    ]
+                },
+                "mime_type": {
+                    "type": "string",
+                    "example": "image/jpeg"
+                },
+                "size_compressed": {
+                    "type": "integer",
+                    "example": 524288
+                },
+                "size_raw": {
+                    "type": "integer",
+                    "example": 1048576
+                }
+            }
+        },
+        "api.UploadSession": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "description": "removed when not continued until then",
+                    "type": "string"
+                },
+                "filename": {
+                    "type": "string",
+                    "example": "scan.tiff"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "0b9d3f52-7a43-4d7e-9c1e-2f6f0c8f4a11"
+                },
+                "length": {
+                    "type": "integer",
+                    "example": 734003200
+                },
+                "offset": {
+                    "description": "bytes received",
+                    "type": "integer",
+                    "example": 268435456
+                },
+                "result": {
+                    "description": "the stored file once the upload is complete",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.UploadResponse"
+                        }
+                    ]
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.UploadTooLargeResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "File too large"
+                },
+                "maxSize": {
+                    "type": "integer",
+                    "example": 52428800
+                }
+            }
+        },
+        "api.UploadValidationProblem": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "size"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "file is larger than the upload limit"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 413
+                }
+            }
+        },
+        "api.UploadValidationRequest": {
+            "type": "object",
+            "properties": {
+                "contentType": {
+                    "type": "string",
+                    "example": "application/pdf"
+                },
+                "disposition": {
+                    "type": "string",
+                    "example": "inline"
+                },
+                "expiresAt": {
+                    "description": "RFC 3339, instead of validity",
+                    "type": "string",
+                    "example": "2026-01-31T12:00:00Z"
+                },
+                "hash": {
+                    "description": "BLAKE2b-256 hex",
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "invoice.pdf"
+                },
+                "oldCumulusId": {
+                    "type": "integer",
+                    "example": 123456
+                },
+                "onConflict": {
+                    "type": "string",
+                    "example": "reject"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 1048576
+                },
+                "validity": {
+                    "type": "string",
+                    "example": "1 month"
+                }
+            }
+        },
+        "api.UploadValidationResponse": {
+            "type": "object",
+            "properties": {
+                "accepted": {
+                    "type": "boolean"
+                },
+                "dedup": {
+                    "description": "Dedup: the content is already stored, an upload with If-None-Match: \"\u003chash\u003e\" and\n?filename= links it without sending the body (the size limit does not apply then)",
+                    "type": "boolean"
+                },
+                "hint": {
+                    "type": "string"
+                },
+                "maxSize": {
+                    "type": "integer",
+                    "example": 52428800
+                },
+                "mimeType": {
+                    "type": "string",
+                    "example": "application/pdf"
+                },
+                "problems": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.UploadValidationProblem"
+                    }
+                }
+            }
+        },
+        "api.ValidityLimits": {
+            "type": "object",
+            "properties": {
+                "max": {
+                    "type": "string",
+                    "example": "1 year"
+                },
+                "maxSeconds": {
+                    "type": "integer",
+                    "example": 31536000
+                },
+                "min": {
+                    "type": "string",
+                    "example": "1 day"
+                },
+                "minSeconds": {
+                    "type": "integer",
+                    "example": 86400
+                },
+                "tempDefault": {
+                    "type": "string",
+                    "example": "1 day",
+                    "description": "validity of /v2/files/tmp uploads without one"
+                },
+                "units": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "hour",
+                        "day",
+                        "week",
+                        "month",
+                        "year"
+                    ],
+                    "description": "units accepted in validity (\"2 weeks\")"
+                }
+            }
+        },
+        "api.VariantResult": {
+            "type": "object",
+            "properties": {
+                "durationMs": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "mimeType": {
+                    "type": "string"
+                },
+                "ok": {
+                    "type": "boolean"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "variant": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.VolumeDrainRequest": {
+            "type": "object",
+            "properties": {
+                "compact": {
+                    "description": "compact the drained volume (removes it when empty)",
+                    "type": "boolean"
+                },
+                "toVolumeId": {
+                    "description": "0 or missing = any open volume with space",
+                    "type": "integer",
+                    "example": 7
+                }
+            }
+        },
+        "api.VolumeStateRequest": {
+            "type": "object",
+            "properties": {
+                "state": {
                     "type": "string",
-                    "example": "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"
+                    "example": "sealed"
+                },
+                "volumeId": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "service.BlobRewriteResult": {
+            "type": "object",
+            "properties": {
+                "blobId": {
+                    "type": "integer"
+                },
+                "compressionAfter": {
+                    "type": "string"
+                },
+                "compressionBefore": {
+                    "type": "string"
+                },
+                "fileId": {
+                    "description": "empty when moved by blob ID` + "`" + `",
+                    "type": "string"
+                },
+                "fromVolumeId": {
+                    "type": "integer"
+                },
+                "sizeAfter": {
+                    "type": "integer"
+                },
+                "sizeBefore": {
+                    "description": "stored (compressed) size",
+                    "type": "integer"
+                },
+                "toVolumeId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.BlobVerifyChecks": {
+            "type": "object",
+            "properties": {
+                "crc": {
+                    "description": "CRC32 of the stored data matches the footer",
+                    "type": "string"
+                },
+                "hash": {
+                    "description": "BLAKE2b of the decompressed content matches blobs.hash",
+                    "type": "string"
+                },
+                "header": {
+                    "description": "magic, blob ID, size and compression match the metadata",
+                    "type": "string"
+                },
+                "size": {
+                    "description": "decompressed size matches blobs.size_raw",
+                    "type": "string"
+                }
+            }
+        },
+        "service.BlobVerifyResult": {
+            "type": "object",
+            "properties": {
+                "blobId": {
+                    "type": "integer"
+                },
+                "checks": {
+                    "$ref": "#/definitions/service.BlobVerifyChecks"
+                },
+                "compression": {
+                    "type": "string"
+                },
+                "durationMs": {
+                    "type": "integer"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "ok": {
+                    "type": "boolean"
+                },
+                "sizeCompressed": {
+                    "type": "integer"
+                },
+                "sizeRaw": {
+                    "type": "integer"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "volumeId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.FileHash": {
+            "type": "object",
+            "properties": {
+                "blake2b": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
                 }
             }
         },
         "service.FileInfo": {
             "type": "object",
             "properties": {
+                "attributed_size": {
+                    "description": "this file's share of the stored blob: size_compressed / ref_count",
+                    "type": "integer"
+                },
                 "blob_id": {
                     "type": "integer"
                 },
@@ -1031,6 +7517,9 @@ const docTemplate = `{
                 "created_at": {
                     "type": "string"
                 },
+                "disposition": {
+                    "type": "string"
+                },
                 "expires_at": {
                     "type": "string"
                 },
@@ -1040,6 +7529,18 @@ const docTemplate = `{
                 "id": {
                     "type": "string"
                 },
+                "image": {
+                    "description": "images only",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.ImageInfo"
+                        }
+                    ]
+                },
+                "legal_hold": {
+                    "description": "deletes and expiry fail until released by an admin",
+                    "type": "boolean"
+                },
                 "mime_type": {
                     "type": "string"
                 },
@@ -1049,6 +7550,13 @@ const docTemplate = `{
                 "old_cumulus_id": {
                     "type": "integer"
                 },
+                "pinned_at": {
+                    "type": "string"
+                },
+                "ref_count": {
+                    "description": "file records sharing the blob (deduplication)",
+                    "type": "integer"
+                },
                 "size_compressed": {
                     "type": "integer"
                 },
@@ -1063,10 +7571,270 @@ const docTemplate = `{
                     "items": {
                         "type": "string"
                     }
+                },
+                "type_mismatch": {
+                    "description": "MIME type of the filename extension when the content is of another type",
+                    "type": "string"
+                }
+            }
+        },
+        "service.FileTypeChange": {
+            "type": "object",
+            "properties": {
+                "after": {
+                    "$ref": "#/definitions/service.FileTypeInfo"
+                },
+                "before": {
+                    "$ref": "#/definitions/service.FileTypeInfo"
+                },
+                "blobId": {
+                    "type": "integer"
+                },
+                "changed": {
+                    "type": "boolean"
+                },
+                "fileId": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.FileTypeInfo": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "mimeType": {
+                    "type": "string"
+                },
+                "subtype": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.ImageInfo": {
+            "type": "object",
+            "properties": {
+                "blurhash": {
+                    "description": "https://blurha.sh, of the displayed image",
+                    "type": "string",
+                    "example": "LEHV6nWB2yk8pyo0adR*.7kCMdnj"
+                },
+                "color": {
+                    "description": "average color",
+                    "type": "string",
+                    "example": "#7a8b9c"
+                },
+                "display_height": {
+                    "type": "integer",
+                    "example": 4032
+                },
+                "display_width": {
+                    "description": "width after applying the orientation",
+                    "type": "integer",
+                    "example": 3024
+                },
+                "height": {
+                    "type": "integer",
+                    "example": 3024
+                },
+                "orientation": {
+                    "description": "EXIF orientation 1-8",
+                    "type": "integer",
+                    "example": 6
+                },
+                "width": {
+                    "type": "integer",
+                    "example": 4032
+                }
+            }
+        },
+        "storage.APIKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "example": "billing-app"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "ck_3fA9x"
+                },
+                "revokedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "storage.BlobHeal": {
+            "type": "object",
+            "properties": {
+                "blobId": {
+                    "type": "integer",
+                    "example": 912
+                },
+                "error": {
+                    "type": "string"
+                },
+                "firstSeenAt": {
+                    "type": "string"
+                },
+                "hits": {
+                    "description": "failed reads from the volume since first seen",
+                    "type": "integer",
+                    "example": 4
+                },
+                "lastSeenAt": {
+                    "type": "string"
+                },
+                "source": {
+                    "description": "where the last read was served from, see HealSource*",
+                    "type": "string",
+                    "example": "volume_dir"
+                },
+                "volumeId": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "storage.BlobLookup": {
+            "type": "object",
+            "properties": {
+                "fileIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "hash": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 1048576
+                },
+                "stored": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "storage.File": {
+            "type": "object",
+            "properties": {
+                "blob_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "disposition": {
+                    "description": "inline/attachment, \"\" = by MIME type",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "old_cumulus_id": {
+                    "type": "integer"
+                },
+                "pinned_at": {
+                    "description": "pinned files are never removed by expiry cleanup",
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "string"
+                }
+            }
+        },
+        "storage.LegalHold": {
+            "type": "object",
+            "properties": {
+                "file_id": {
+                    "type": "string"
+                },
+                "held_at": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "case 2026-117"
+                }
+            }
+        },
+        "storage.SlowQuery": {
+            "type": "object",
+            "properties": {
+                "args": {
+                    "description": "number of arguments; their values are not kept",
+                    "type": "integer"
+                },
+                "durationMs": {
+                    "type": "number"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "op": {
+                    "description": "exec | query | query_row",
+                    "type": "string"
+                },
+                "query": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "storage.TypeMismatch": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "detected": {
+                    "description": "MIME type of the content",
+                    "type": "string",
+                    "example": "application/zip"
+                },
+                "expected": {
+                    "description": "MIME type of the extension",
+                    "type": "string",
+                    "example": "application/pdf"
+                },
+                "file_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "invoice.pdf"
+                },
+                "old_cumulus_id": {
+                    "type": "integer"
                 }
             }
         }
     },
+    "securityDefinitions": {
+        "BasicAuth": {
+            "type": "basic"
+        }
+    },
     "tags": [
         {
             "description": "Internal endpoints for backward compatibility with old Cumulus ID system",
@@ -46,6 +46,11 @@ func printUsage() {
 	fmt.Println("  compact-tool volumes list                    - List all volumes and their fragmentation")
 	fmt.Println("  compact-tool volumes compact <id>            - Compact specific volume by ID")
 	fmt.Println("  compact-tool volumes compact-all [--threshold 20] - Compact all volumes with fragmentation >= threshold%")
+	fmt.Println("  compact-tool volumes manifest <id> [--out file] - Write the checksum manifest of a volume (JSON)")
+	fmt.Println("  compact-tool volumes verify-manifest <file> [--deep] - Check the local volume file against a manifest")
+	fmt.Println("  compact-tool volumes diff-manifest <a> <b>   - Compare manifests of two replicas")
+	fmt.Println("  compact-tool volumes upgrade <id>            - Rewrite a legacy volume_N.dat as volume_%08d.dat")
+	fmt.Println("  compact-tool volumes upgrade-all [--dry-run] - Upgrade all volumes with a legacy name")
 	fmt.Println("  compact-tool db vacuum                       - Perform database VACUUM (SQLite only)")
 	fmt.Println("  compact-tool help                            - Show this help")
 	fmt.Println()
@@ -54,16 +59,18 @@ func printUsage() {
 	fmt.Println("  DB_SQLITE_PATH   - Path to SQLite database (default: ./data/database/cumulus3.db)")
 	fmt.Println("  PG_DATABASE_URL  - PostgreSQL connection URL (required if DATABASE_TYPE=postgresql)")
 	fmt.Println("  DATA_DIR  - Path to volume directory (default: ./data/volumes)")
+	fmt.Println("  MANIFEST_SIGNING_KEY - HMAC key signing manifests and checking their signatures")
 	fmt.Println()
 	fmt.Println("Notes:")
 	fmt.Println("  - Volume compaction can run while server is running (per-volume locking)")
 	fmt.Println("  - Database VACUUM is only available for SQLite (requires downtime)")
 	fmt.Println("  - Compaction requires free disk space equal to volume size")
+	fmt.Println("  - Upgrade drops deleted blobs like compaction and requires the same free space")
 }
 
 func handleVolumesCommand() {
 	if len(os.Args) < 3 {
-		fmt.Println("Error: volumes command requires subcommand (list, compact, compact-all)")
+		fmt.Println("Error: volumes command requires subcommand (list, compact, compact-all, manifest, verify-manifest, diff-manifest, upgrade, upgrade-all)")
 		os.Exit(1)
 	}
 
@@ -89,6 +96,10 @@ func handleVolumesCommand() {
 		threshold := flags.Float64("threshold", 20.0, "Minimum fragmentation percentage to compact")
 		flags.Parse(os.Args[3:])
 		compactAllVolumes(*threshold)
+	case "manifest", "verify-manifest", "diff-manifest":
+		handleManifestCommand(subcommand)
+	case "upgrade", "upgrade-all":
+		handleUpgradeCommand(subcommand)
 	default:
 		fmt.Printf("Unknown volumes subcommand: %s\n", subcommand)
 		os.Exit(1)
@@ -192,6 +203,8 @@ func listVolumes() {
 			volumePath = filepath.Join(dataDir, fmt.Sprintf("volume_%d.dat", vol.ID))
 			if _, err := os.Stat(volumePath); os.IsNotExist(err) {
 				status = "MISSING"
+			} else {
+				status = "LEGACY" // compact-tool volumes upgrade
 			}
 		}
 
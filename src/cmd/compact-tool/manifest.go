@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+)
+
+// Manifesty svazků (blob, offset, velikost, CRC, hash) pro ověření replik a off-site záloh
+// bez přenosu dat: "manifest" na primárním uzlu, "verify-manifest" u kopie, "diff-manifest"
+// pro porovnání manifestů dvou replik.
+
+func handleManifestCommand(subcommand string) {
+	switch subcommand {
+	case "manifest":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: compact-tool volumes manifest <id> [--out file]")
+			os.Exit(1)
+		}
+		volumeID, err := strconv.ParseInt(os.Args[3], 10, 64)
+		if err != nil {
+			fmt.Printf("Error: invalid volume ID: %v\n", err)
+			os.Exit(1)
+		}
+		flags := flag.NewFlagSet("manifest", flag.ExitOnError)
+		out := flags.String("out", "", "Write the manifest to the file instead of stdout")
+		flags.Parse(os.Args[4:])
+		writeManifest(volumeID, *out)
+	case "verify-manifest":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: compact-tool volumes verify-manifest <manifest.json> [--deep]")
+			os.Exit(1)
+		}
+		flags := flag.NewFlagSet("verify-manifest", flag.ExitOnError)
+		deep := flags.Bool("deep", false, "Also compute the CRC of the blob data (reads the whole volume)")
+		flags.Parse(os.Args[4:])
+		verifyManifest(os.Args[3], *deep)
+	case "diff-manifest":
+		if len(os.Args) < 5 {
+			fmt.Println("Usage: compact-tool volumes diff-manifest <a.json> <b.json>")
+			os.Exit(1)
+		}
+		diffManifests(os.Args[3], os.Args[4])
+	}
+}
+
+func manifestSigningKey() []byte {
+	return []byte(os.Getenv("MANIFEST_SIGNING_KEY"))
+}
+
+func writeManifest(volumeID int64, out string) {
+	dbType, dsn, dataDir := getConfig()
+
+	store := storage.NewStore(dataDir, 100*1024*1024)
+	metaStore, err := storage.NewMetadataSQL(dbType, dsn)
+	if err != nil {
+		fmt.Printf("Error opening metadata store: %v\n", err)
+		os.Exit(1)
+	}
+	defer metaStore.Close()
+
+	manifest, err := store.BuildVolumeManifest(volumeID, metaStore)
+	if err != nil {
+		fmt.Printf("Error building manifest: %v\n", err)
+		os.Exit(1)
+	}
+	manifest.Sign(manifestSigningKey())
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		fmt.Printf("Error encoding manifest: %v\n", err)
+		os.Exit(1)
+	}
+
+	if out == "" {
+		os.Stdout.Write(append(data, '\n'))
+		return
+	}
+	if err := os.WriteFile(out, append(data, '\n'), 0644); err != nil {
+		fmt.Printf("Error writing manifest: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Manifest of volume %d written to %s: %d blobs, %s, digest %s\n",
+		volumeID, out, manifest.Blobs, formatBytes(manifest.Bytes), manifest.Digest)
+	if manifest.Signature == "" {
+		fmt.Println("  (not signed, set MANIFEST_SIGNING_KEY to sign it)")
+	}
+	for _, e := range manifest.Errors {
+		fmt.Printf("  ✗ %s\n", e)
+	}
+}
+
+func readManifest(path string) *storage.VolumeManifest {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error reading manifest: %v\n", err)
+		os.Exit(1)
+	}
+	var manifest storage.VolumeManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		fmt.Printf("Error parsing manifest %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	return &manifest
+}
+
+// checkManifestIntegrity exits when the manifest was modified or its signature is wrong
+func checkManifestIntegrity(path string, manifest *storage.VolumeManifest) {
+	key := manifestSigningKey()
+	if err := manifest.VerifyIntegrity(key); err != nil {
+		if errors.Is(err, storage.ErrManifestSignature) {
+			fmt.Printf("✗ %s: signature does not match MANIFEST_SIGNING_KEY\n", path)
+		} else {
+			fmt.Printf("✗ %s: %v\n", path, err)
+		}
+		os.Exit(1)
+	}
+	if len(key) == 0 && manifest.Signature != "" {
+		fmt.Printf("  %s is signed, set MANIFEST_SIGNING_KEY to check the signature\n", path)
+	}
+}
+
+func verifyManifest(path string, deep bool) {
+	manifest := readManifest(path)
+	checkManifestIntegrity(path, manifest)
+
+	_, _, dataDir := getConfig()
+	store := storage.NewStore(dataDir, 100*1024*1024)
+	volumePath, err := store.VolumePath(manifest.VolumeID)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Verifying %s against %s (%d blobs, deep=%v)...\n", volumePath, path, manifest.Blobs, deep)
+	check, err := storage.VerifyVolumeFile(volumePath, manifest, deep)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, p := range check.Problems {
+		fmt.Printf("  ✗ %s\n", p)
+	}
+	if len(check.Problems) > 0 {
+		fmt.Printf("✗ %d of %d blobs don't match the manifest\n", len(check.Problems), check.Checked)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ All %d blobs match the manifest (digest %s)\n", check.Checked, manifest.Digest)
+}
+
+func diffManifests(pathA, pathB string) {
+	a, b := readManifest(pathA), readManifest(pathB)
+	checkManifestIntegrity(pathA, a)
+	checkManifestIntegrity(pathB, b)
+
+	if a.Digest == b.Digest {
+		fmt.Printf("✓ Manifests are identical (volume %d, %d blobs, digest %s)\n", a.VolumeID, a.Blobs, a.Digest)
+		return
+	}
+	diffs := storage.DiffManifests(a, b)
+	for _, d := range diffs {
+		fmt.Printf("  ✗ %s\n", d)
+	}
+	fmt.Printf("✗ Manifests differ: %d differences\n", len(diffs))
+	os.Exit(1)
+}
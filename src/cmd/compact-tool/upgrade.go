@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+)
+
+// Upgrade svazků se starým názvem volume_N.dat na volume_%08d.dat: "upgrade" pro jeden svazek,
+// "upgrade-all" pro všechny, které ve složce ještě zbyly.
+
+func handleUpgradeCommand(subcommand string) {
+	switch subcommand {
+	case "upgrade":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: compact-tool volumes upgrade <id>")
+			os.Exit(1)
+		}
+		volumeID, err := strconv.ParseInt(os.Args[3], 10, 64)
+		if err != nil {
+			fmt.Printf("Error: invalid volume ID: %v\n", err)
+			os.Exit(1)
+		}
+		upgradeVolumes([]int64{volumeID})
+	case "upgrade-all":
+		flags := flag.NewFlagSet("upgrade-all", flag.ExitOnError)
+		dryRun := flags.Bool("dry-run", false, "Only list the volumes with a legacy name")
+		flags.Parse(os.Args[3:])
+
+		_, _, dataDir := getConfig()
+		ids, err := storage.NewStore(dataDir, 100*1024*1024).LegacyVolumeIDs()
+		if err != nil {
+			fmt.Printf("Error listing volumes: %v\n", err)
+			os.Exit(1)
+		}
+		if len(ids) == 0 {
+			fmt.Println("No volumes with a legacy name found.")
+			return
+		}
+		fmt.Printf("Found %d volume(s) with a legacy name: %v\n\n", len(ids), ids)
+		if *dryRun {
+			return
+		}
+		upgradeVolumes(ids)
+	}
+}
+
+func upgradeVolumes(ids []int64) {
+	dbType, dsn, dataDir := getConfig()
+
+	store := storage.NewStore(dataDir, 100*1024*1024) // Size doesn't matter for the upgrade
+	metaStore, err := storage.NewMetadataSQL(dbType, dsn)
+	if err != nil {
+		fmt.Printf("Error opening metadata store: %v\n", err)
+		os.Exit(1)
+	}
+	defer metaStore.Close()
+
+	failCount := 0
+	for i, id := range ids {
+		fmt.Printf("[%d/%d] Upgrading volume %d...\n", i+1, len(ids), id)
+		res, err := store.UpgradeLegacyVolume(id, metaStore)
+		if err != nil {
+			fmt.Printf("  ✗ Error: %v\n\n", err)
+			failCount++
+			continue
+		}
+		if res.Completed {
+			fmt.Printf("  ✓ Finished an interrupted upgrade, legacy files removed\n\n")
+			continue
+		}
+		fmt.Printf("  ✓ %d blobs, %s → %s (%s)\n\n", res.Blobs, formatBytes(res.OldSize), formatBytes(res.NewSize), res.NewPath)
+	}
+
+	fmt.Println("─────────────────────────────────────────────────────────────────────────")
+	fmt.Printf("Summary: %d succeeded, %d failed\n", len(ids)-failCount, failCount)
+	fmt.Println("─────────────────────────────────────────────────────────────────────────")
+	if failCount > 0 {
+		os.Exit(1)
+	}
+}
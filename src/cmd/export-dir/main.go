@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"golang.org/x/crypto/blake2b"
+)
+
+// export-dir downloads all files carrying a tag through the API into a plain directory tree
+// under their original names. Files imported by import-dir go back to their directory (tag
+// "dir:<path>"). Every exported file is recorded in a CSV manifest; files already listed there
+// are skipped, so an interrupted export can be run again.
+
+// dirTagPrefix prefixes the tag carrying the relative directory of an imported file
+const dirTagPrefix = "dir:"
+
+// listPageSize is the number of files requested per /v2/files/list page (the server maximum)
+const listPageSize = 1000
+
+var manifestHeader = []string{"path", "file_id", "name", "size", "hash", "created_at"}
+
+// fileList is the body of GET /v2/files/list
+type fileList struct {
+	Files     []storage.File `json:"files"`
+	NextAfter string         `json:"next_after"`
+}
+
+type exportJob struct {
+	file storage.File
+	rel  string // slash-separated path relative to -dest
+}
+
+type exportStats struct {
+	exported, failed, skipped atomic.Int64
+	bytes                     atomic.Int64
+}
+
+type apiClient struct {
+	http       *http.Client
+	baseURL    string
+	user, pass string
+}
+
+func main() {
+	godotenv.Load()
+
+	tag := flag.String("tag", "", "Export files carrying this tag (required)")
+	destDir := flag.String("dest", "", "Target directory (required)")
+	apiHost := flag.String("api-host", "localhost", "Cumulus API host")
+	apiPort := flag.Int("api-port", 8080, "Cumulus API port")
+	user := flag.String("user", os.Getenv("ADMIN_USERNAME"), "Basic auth username, if the files routes require auth")
+	pass := flag.String("pass", os.Getenv("ADMIN_PASSWORD"), "Basic auth password")
+	manifestPath := flag.String("manifest", "", "CSV manifest of exported files (default <dest>/manifest.csv; used to resume)")
+	match := flag.String("match", "", "Export only files whose name matches this glob (e.g. '*.pdf')")
+	dirTags := flag.Bool("dir-tags", true, "Restore the directory of files imported by import-dir (tag dir:<path>)")
+	workers := flag.Int("workers", 4, "Number of files downloaded in parallel")
+	dryRun := flag.Bool("dry-run", false, "Only list files that would be exported")
+	flag.Parse()
+
+	if *tag == "" || *destDir == "" || *workers < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if _, err := path.Match(*match, ""); err != nil {
+		log.Fatalf("Invalid -match pattern: %v", err)
+	}
+	if *manifestPath == "" {
+		*manifestPath = filepath.Join(*destDir, "manifest.csv")
+	}
+
+	client := &apiClient{
+		http:    &http.Client{Timeout: 30 * time.Minute},
+		baseURL: fmt.Sprintf("http://%s:%d", *apiHost, *apiPort),
+		user:    *user,
+		pass:    *pass,
+	}
+
+	fmt.Println("📦 Cumulus3 Directory Export")
+	fmt.Println("============================")
+	fmt.Printf("Tag: %s\n", *tag)
+	fmt.Printf("API: %s\n", client.baseURL)
+	fmt.Printf("Target directory: %s\n\n", *destDir)
+
+	done, used, err := readManifest(*manifestPath)
+	if err != nil {
+		log.Fatalf("Failed to read manifest: %v", err)
+	}
+
+	var manifest *os.File
+	var writer *csv.Writer
+	var writerMu sync.Mutex
+	if !*dryRun {
+		if err := os.MkdirAll(*destDir, 0755); err != nil {
+			log.Fatalf("Failed to create target directory: %v", err)
+		}
+		manifest, err = openManifest(*manifestPath)
+		if err != nil {
+			log.Fatalf("Failed to open manifest: %v", err)
+		}
+		defer manifest.Close()
+		writer = csv.NewWriter(manifest)
+	}
+
+	var stats exportStats
+	start := time.Now()
+	jobs := make(chan exportJob)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				size, hash, err := client.download(job.file, filepath.Join(*destDir, filepath.FromSlash(job.rel)))
+				if err != nil {
+					log.Printf("⚠️  %s (%s): %v", job.rel, job.file.ID, err)
+					stats.failed.Add(1)
+					continue
+				}
+				writerMu.Lock()
+				writer.Write([]string{job.rel, job.file.ID, job.file.Name, strconv.FormatInt(size, 10), hash, job.file.CreatedAt.UTC().Format(time.RFC3339)})
+				writer.Flush()
+				err = writer.Error()
+				writerMu.Unlock()
+				if err != nil {
+					log.Fatalf("Failed to write manifest: %v", err)
+				}
+				stats.bytes.Add(size)
+				if n := stats.exported.Add(1); n%1000 == 0 {
+					fmt.Printf("   %d files exported\n", n)
+				}
+			}
+		}()
+	}
+
+	// Paths are assigned here, in listing order, so collisions resolve the same way on a rerun
+	after := ""
+	for {
+		page, err := client.list(*tag, after)
+		if err != nil {
+			log.Fatalf("Failed to list files: %v", err)
+		}
+		for _, file := range page.Files {
+			if done[file.ID] {
+				stats.skipped.Add(1)
+				continue
+			}
+			if *match != "" {
+				if ok, _ := path.Match(*match, file.Name); !ok {
+					continue
+				}
+			}
+			rel := exportPath(file, *dirTags, used, *destDir)
+			used[rel] = true
+			if *dryRun {
+				fmt.Println(rel)
+				continue
+			}
+			jobs <- exportJob{file: file, rel: rel}
+		}
+		if page.NextAfter == "" {
+			break
+		}
+		after = page.NextAfter
+	}
+	close(jobs)
+	wg.Wait()
+
+	if *dryRun {
+		return
+	}
+	fmt.Printf("\n✅ Exported %d files (%s) in %s, %d failed, %d skipped\n",
+		stats.exported.Load(), formatBytes(stats.bytes.Load()),
+		time.Since(start).Round(time.Second), stats.failed.Load(), stats.skipped.Load())
+	fmt.Printf("Manifest: %s\n", *manifestPath)
+	if stats.failed.Load() > 0 {
+		os.Exit(1)
+	}
+}
+
+// exportPath returns the slash-separated target path of a file: its import directory (if any)
+// and its original name. A path already taken by another file, in the manifest or on disk, gets
+// the file ID as prefix like the entries of /v2/files/archive.tar.
+func exportPath(file storage.File, dirTags bool, used map[string]bool, destDir string) string {
+	name := safeName(file.Name)
+	if name == "" {
+		name = file.ID
+	}
+	dir := ""
+	if dirTags {
+		dir = fileDir(file)
+	}
+	rel := path.Join(dir, name)
+	if used[rel] {
+		return path.Join(dir, file.ID+"_"+name)
+	}
+	if _, err := os.Lstat(filepath.Join(destDir, filepath.FromSlash(rel))); err == nil {
+		return path.Join(dir, file.ID+"_"+name)
+	}
+	return rel
+}
+
+// fileDir returns the directory from the dir:<path> tag, "" without one or when it would leave
+// the target directory
+func fileDir(file storage.File) string {
+	for _, tag := range storage.TagsFromJSON(file.Tags) {
+		if !strings.HasPrefix(tag, dirTagPrefix) {
+			continue
+		}
+		dir := path.Clean(strings.TrimPrefix(tag, dirTagPrefix))
+		if dir == "." || path.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+			return ""
+		}
+		return dir
+	}
+	return ""
+}
+
+// safeName makes a stored file name usable as a single path element
+func safeName(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_", "\x00", "").Replace(name)
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// list returns one page of files carrying tag
+func (c *apiClient) list(tag, after string) (fileList, error) {
+	var page fileList
+	q := url.Values{"tag": {tag}, "limit": {strconv.Itoa(listPageSize)}}
+	if after != "" {
+		q.Set("after", after)
+	}
+	resp, err := c.get("/v2/files/list?" + q.Encode())
+	if err != nil {
+		return page, err
+	}
+	defer resp.Body.Close()
+	return page, json.NewDecoder(resp.Body).Decode(&page)
+}
+
+// download streams a file into target through a temporary file, sets its modification time to
+// the creation time of the file and returns its size and BLAKE2b-256 hash
+func (c *apiClient) download(file storage.File, target string) (int64, string, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, "", err
+	}
+	resp, err := c.get("/v2/files/" + url.PathEscape(file.ID))
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	tmp := target + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, "", err
+	}
+	hasher, _ := blake2b.New256(nil)
+	size, err := io.Copy(io.MultiWriter(f, hasher), resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, "", err
+	}
+	if !file.CreatedAt.IsZero() {
+		os.Chtimes(target, file.CreatedAt, file.CreatedAt)
+	}
+	return size, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// get sends an authenticated GET request and fails on a non-200 response
+func (c *apiClient) get(uri string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.pass)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: HTTP %d: %s", uri, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// readManifest returns IDs of files exported by a previous run and the paths they took
+func readManifest(path string) (map[string]bool, map[string]bool, error) {
+	done := make(map[string]bool)
+	used := make(map[string]bool)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return done, used, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = len(manifestHeader)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if record[0] == manifestHeader[0] {
+			continue
+		}
+		used[record[0]] = true
+		done[record[1]] = true
+	}
+	return done, used, nil
+}
+
+// openManifest opens the manifest for appending and writes the header into a new file
+func openManifest(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if stat.Size() == 0 {
+		w := csv.NewWriter(f)
+		w.Write(manifestHeader)
+		w.Flush()
+		if err := w.Error(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
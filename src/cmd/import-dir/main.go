@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// import-dir uploads a plain directory tree through the service layer (dedup and compression as on
+// upload). The file name becomes the name of the record and its directory, relative to -src, the
+// tag "dir:<path>", so the tree can be exported again. Every imported file is recorded in a CSV
+// state file; files already listed there are skipped, so an interrupted import can be run again.
+
+// dirTagPrefix prefixes the tag carrying the relative directory of an imported file
+const dirTagPrefix = "dir:"
+
+var stateHeader = []string{"path", "file_id", "old_cumulus_id", "dedup", "size"}
+
+type importStats struct {
+	imported, dedup, failed atomic.Int64
+	bytes                   atomic.Int64
+}
+
+func main() {
+	godotenv.Load()
+
+	srcDir := flag.String("src", "", "Directory tree to import (required)")
+	dataDir := flag.String("data-dir", envOrDefault("DATA_DIR", "./data"), "Data directory of the volume server (volumes, files_metadata.bin)")
+	statePath := flag.String("state", "import-dir-state.csv", "CSV state file of imported files (used to resume)")
+	tags := flag.String("tags", "", "Comma-separated tags added to every imported file")
+	dirTags := flag.Bool("dir-tags", true, "Tag files with their relative directory (dir:<path>)")
+	workers := flag.Int("workers", 4, "Number of files stored in parallel")
+	skipHidden := flag.Bool("skip-hidden", true, "Skip files and directories starting with a dot")
+	dryRun := flag.Bool("dry-run", false, "Only list files that would be imported")
+	flag.Parse()
+
+	if *srcDir == "" || *workers < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	paths, err := scanDir(*srcDir, *skipHidden)
+	if err != nil {
+		log.Fatalf("Failed to scan %s: %v", *srcDir, err)
+	}
+	done, err := readState(*statePath)
+	if err != nil {
+		log.Fatalf("Failed to read state file: %v", err)
+	}
+	var todo []string
+	for _, rel := range paths {
+		if !done[rel] {
+			todo = append(todo, rel)
+		}
+	}
+
+	fmt.Println("📂 Cumulus3 Directory Import")
+	fmt.Println("============================")
+	fmt.Printf("Source: %s (%d files, %d already imported)\n", *srcDir, len(paths), len(paths)-len(todo))
+	fmt.Printf("Target data directory: %s\n\n", *dataDir)
+
+	if *dryRun {
+		for _, rel := range todo {
+			fmt.Println(rel)
+		}
+		return
+	}
+
+	dbType, dsn := getDatabaseConfig()
+	meta, err := storage.NewMetadataSQL(dbType, dsn)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer meta.Close()
+
+	maxDataFileSize := int64(10 << 20) // same default as the volume server
+	if val := os.Getenv("DATA_FILE_SIZE"); val != "" {
+		if s, err := utils.ParseBytes(val); err == nil {
+			maxDataFileSize = s
+		}
+	}
+	compressionMode := envOrDefault("USE_COMPRESS", "Auto")
+	minCompressionRatio := 10.0
+	if val := os.Getenv("MINIMAL_COMPRESSION"); val != "" {
+		if v, err := strconv.ParseFloat(strings.TrimSuffix(val, "%"), 64); err == nil {
+			minCompressionRatio = v
+		}
+	}
+
+	store := storage.NewStore(*dataDir, maxDataFileSize)
+	fileService := service.NewFileService(store, meta, storage.NewMetadataLogger(*dataDir), compressionMode, minCompressionRatio)
+
+	state, err := openState(*statePath)
+	if err != nil {
+		log.Fatalf("Failed to open state file: %v", err)
+	}
+	defer state.Close()
+	writer := csv.NewWriter(state)
+	var writerMu sync.Mutex
+
+	var commonTags []string
+	for _, tag := range strings.Split(*tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			commonTags = append(commonTags, tag)
+		}
+	}
+
+	var stats importStats
+	start := time.Now()
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range jobs {
+				fileTags := commonTags
+				if dir := path.Dir(rel); *dirTags && dir != "." {
+					fileTags = append(append([]string{}, commonTags...), dirTagPrefix+dir)
+				}
+				id, oldID, isDedup, size, err := importFile(fileService, filepath.Join(*srcDir, filepath.FromSlash(rel)), storage.TagsToJSON(fileTags))
+				if err != nil {
+					log.Printf("⚠️  %s: %v", rel, err)
+					stats.failed.Add(1)
+					continue
+				}
+				writerMu.Lock()
+				writer.Write([]string{rel, id, strconv.FormatInt(oldID, 10), strconv.FormatBool(isDedup), strconv.FormatInt(size, 10)})
+				writer.Flush()
+				err = writer.Error()
+				writerMu.Unlock()
+				if err != nil {
+					log.Fatalf("Failed to write state file: %v", err)
+				}
+				stats.bytes.Add(size)
+				if isDedup {
+					stats.dedup.Add(1)
+				}
+				if n := stats.imported.Add(1); n%1000 == 0 {
+					fmt.Printf("   %d/%d files imported\n", n, len(todo))
+				}
+			}
+		}()
+	}
+	for _, rel := range todo {
+		jobs <- rel
+	}
+	close(jobs)
+	wg.Wait()
+
+	fmt.Printf("\n✅ Imported %d files (%d deduplicated, %s) in %s, %d failed, %d skipped\n",
+		stats.imported.Load(), stats.dedup.Load(), formatBytes(stats.bytes.Load()),
+		time.Since(start).Round(time.Second), stats.failed.Load(), len(paths)-len(todo))
+	fmt.Printf("State file: %s\n", *statePath)
+	if stats.failed.Load() > 0 {
+		os.Exit(1)
+	}
+}
+
+// importFile stores one file; its modification time becomes the creation time
+func importFile(fileService *service.FileService, fullPath, tags string) (string, int64, bool, int64, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return "", 0, false, 0, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return "", 0, false, 0, err
+	}
+	createdAt := stat.ModTime()
+	id, oldID, isDedup, err := fileService.UploadFileWithDedup(f, filepath.Base(fullPath), "", nil, nil, &createdAt, tags, service.DispositionAuto, service.OldIDConflictReject)
+	return id, oldID, isDedup, stat.Size(), err
+}
+
+// scanDir returns slash-separated paths of regular files under dir, optionally without hidden
+// files and directories
+func scanDir(dir string, skipHidden bool) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if skipHidden && p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	return paths, err
+}
+
+// readState returns paths already imported by a previous run
+func readState(path string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = len(stateHeader)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record[0] == stateHeader[0] {
+			continue
+		}
+		done[record[0]] = true
+	}
+	return done, nil
+}
+
+// openState opens the state file for appending and writes the header into a new file
+func openState(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if stat.Size() == 0 {
+		w := csv.NewWriter(f)
+		w.Write(stateHeader)
+		w.Flush()
+		if err := w.Error(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func getDatabaseConfig() (dbType, dsn string) {
+	dbType = envOrDefault("DATABASE_TYPE", "sqlite")
+	switch dbType {
+	case "sqlite":
+		dbPath := envOrDefault("DB_SQLITE_PATH", "./data/database/cumulus3.db")
+		if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+			log.Fatalf("Failed to create database directory: %v", err)
+		}
+		dsn = fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_sync=NORMAL", dbPath)
+	case "postgresql":
+		dsn = os.Getenv("PG_DATABASE_URL")
+		if dsn == "" {
+			log.Fatal("PG_DATABASE_URL is required when DATABASE_TYPE=postgresql")
+		}
+	default:
+		log.Fatalf("Unsupported DATABASE_TYPE: %s (use 'sqlite' or 'postgresql')", dbType)
+	}
+	return dbType, dsn
+}
+
+func envOrDefault(key, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return def
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// legacy-import migrates the old store layout – one plain file per name in the data
+// directory, written by Store.WriteFile – into volumes and the blobs/files schema.
+// Every imported file is recorded in a CSV manifest (legacy path -> new file ID); files
+// already listed there are skipped, so an interrupted import can simply be run again.
+
+var manifestHeader = []string{"legacy_path", "file_id", "old_cumulus_id", "dedup"}
+
+func main() {
+	godotenv.Load()
+
+	srcDir := flag.String("src", "", "Legacy data directory with plain files (required)")
+	dataDir := flag.String("data-dir", envOrDefault("DATA_DIR", "./data"), "Data directory of the volume server (volumes, files_metadata.bin)")
+	manifestPath := flag.String("manifest", "legacy-import-manifest.csv", "CSV manifest of imported files (used to resume)")
+	tag := flag.String("tag", "", "Optional tag added to every imported file")
+	dryRun := flag.Bool("dry-run", false, "Only list files that would be imported")
+	flag.Parse()
+
+	if *srcDir == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	paths, err := scanLegacyFiles(*srcDir)
+	if err != nil {
+		log.Fatalf("Failed to scan %s: %v", *srcDir, err)
+	}
+	done, err := readManifest(*manifestPath)
+	if err != nil {
+		log.Fatalf("Failed to read manifest: %v", err)
+	}
+
+	fmt.Println("📦 Cumulus3 Legacy Import")
+	fmt.Println("=========================")
+	fmt.Printf("Source: %s (%d files, %d already imported)\n", *srcDir, len(paths), len(done))
+	fmt.Printf("Target data directory: %s\n\n", *dataDir)
+
+	if *dryRun {
+		for _, rel := range paths {
+			if !done[rel] {
+				fmt.Println(rel)
+			}
+		}
+		return
+	}
+
+	tags := ""
+	if *tag != "" {
+		tags = mustTagsJSON(*tag)
+	}
+
+	dbType, dsn := getDatabaseConfig()
+	meta, err := storage.NewMetadataSQL(dbType, dsn)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer meta.Close()
+
+	maxDataFileSize := int64(10 << 20) // same default as the volume server
+	if val := os.Getenv("DATA_FILE_SIZE"); val != "" {
+		if s, err := utils.ParseBytes(val); err == nil {
+			maxDataFileSize = s
+		}
+	}
+	compressionMode := envOrDefault("USE_COMPRESS", "Auto")
+	minCompressionRatio := 10.0
+	if val := os.Getenv("MINIMAL_COMPRESSION"); val != "" {
+		if v, err := strconv.ParseFloat(strings.TrimSuffix(val, "%"), 64); err == nil {
+			minCompressionRatio = v
+		}
+	}
+
+	store := storage.NewStore(*dataDir, maxDataFileSize)
+	fileService := service.NewFileService(store, meta, storage.NewMetadataLogger(*dataDir), compressionMode, minCompressionRatio)
+
+	manifest, err := openManifest(*manifestPath)
+	if err != nil {
+		log.Fatalf("Failed to open manifest: %v", err)
+	}
+	defer manifest.Close()
+	writer := csv.NewWriter(manifest)
+
+	var imported, dedup, failed int
+	for _, rel := range paths {
+		if done[rel] {
+			continue
+		}
+		id, oldID, isDedup, err := importFile(fileService, filepath.Join(*srcDir, filepath.FromSlash(rel)), tags)
+		if err != nil {
+			log.Printf("⚠️  %s: %v", rel, err)
+			failed++
+			continue
+		}
+		writer.Write([]string{rel, id, strconv.FormatInt(oldID, 10), strconv.FormatBool(isDedup)})
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			log.Fatalf("Failed to write manifest: %v", err)
+		}
+		imported++
+		if isDedup {
+			dedup++
+		}
+	}
+
+	fmt.Printf("\n✅ Imported %d files (%d deduplicated), %d failed, %d skipped\n", imported, dedup, failed, len(done))
+	fmt.Printf("Manifest: %s\n", *manifestPath)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// importFile stores one legacy file; its modification time becomes the creation time
+func importFile(fileService *service.FileService, path, tags string) (string, int64, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, false, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return "", 0, false, err
+	}
+	createdAt := stat.ModTime()
+	return fileService.UploadFileWithDedup(f, filepath.Base(path), "", nil, nil, &createdAt, tags, service.DispositionAuto, service.OldIDConflictReject)
+}
+
+// scanLegacyFiles returns slash-separated paths of regular files under dir, leaving out files
+// of the current layout (volumes, metadata log, SQLite database) in case dir is shared.
+func scanLegacyFiles(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || isCurrentLayoutFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	return paths, err
+}
+
+func isCurrentLayoutFile(name string) bool {
+	if name == format.MetadataLogName {
+		return true
+	}
+	if strings.HasPrefix(name, "volume_") {
+		for _, ext := range []string{".dat", ".meta", ".dat.compact"} {
+			if strings.HasSuffix(name, ext) {
+				return true
+			}
+		}
+	}
+	for _, ext := range []string{".db", ".db-wal", ".db-shm"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// readManifest returns legacy paths already imported by a previous run
+func readManifest(path string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = len(manifestHeader)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record[0] == manifestHeader[0] {
+			continue
+		}
+		done[record[0]] = true
+	}
+	return done, nil
+}
+
+// openManifest opens the manifest for appending and writes the header into a new file
+func openManifest(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if stat.Size() == 0 {
+		w := csv.NewWriter(f)
+		w.Write(manifestHeader)
+		w.Flush()
+		if err := w.Error(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func mustTagsJSON(tag string) string {
+	data, err := json.Marshal([]string{tag})
+	if err != nil {
+		log.Fatalf("Invalid tag: %v", err)
+	}
+	return string(data)
+}
+
+func getDatabaseConfig() (dbType, dsn string) {
+	dbType = envOrDefault("DATABASE_TYPE", "sqlite")
+	switch dbType {
+	case "sqlite":
+		dbPath := envOrDefault("DB_SQLITE_PATH", "./data/database/cumulus3.db")
+		if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+			log.Fatalf("Failed to create database directory: %v", err)
+		}
+		dsn = fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_sync=NORMAL", dbPath)
+	case "postgresql":
+		dsn = os.Getenv("PG_DATABASE_URL")
+		if dsn == "" {
+			log.Fatal("PG_DATABASE_URL is required when DATABASE_TYPE=postgresql")
+		}
+	default:
+		log.Fatalf("Unsupported DATABASE_TYPE: %s (use 'sqlite' or 'postgresql')", dbType)
+	}
+	return dbType, dsn
+}
+
+func envOrDefault(key, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return def
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"compress/bzip2"
-	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
@@ -11,17 +10,20 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/blake2b"
 )
 
 type MigrationFile struct {
@@ -30,6 +32,20 @@ type MigrationFile struct {
 	RawID       int64
 	Tags        string
 	ContentType string
+	CreatedAt   string // original creation time as returned by MySQL ("" = unknown)
+}
+
+// adminAuth holds Basic auth credentials for admin-only upload fields (created_at)
+type adminAuth struct {
+	user string
+	pass string
+}
+
+// ExistingFile is one entry of the /base/files/old/exists response
+type ExistingFile struct {
+	FileID string `json:"fileID"`
+	Hash   string `json:"hash"`
+	Size   int64  `json:"size"`
 }
 
 type TestMismatch struct {
@@ -67,7 +83,14 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  -api-host string\n")
 		fmt.Fprintf(os.Stderr, "        Cumulus API server host/IP (default: localhost)\n")
 		fmt.Fprintf(os.Stderr, "  -api-port int\n")
-		fmt.Fprintf(os.Stderr, "        Cumulus API server port (default: 8080)\n\n")
+		fmt.Fprintf(os.Stderr, "        Cumulus API server port (default: 8080)\n")
+		fmt.Fprintf(os.Stderr, "  -admin-user string\n")
+		fmt.Fprintf(os.Stderr, "        Cumulus admin username, required to preserve created_at (default: $ADMIN_USERNAME)\n")
+		fmt.Fprintf(os.Stderr, "  -admin-pass string\n")
+		fmt.Fprintf(os.Stderr, "        Cumulus admin password (default: $ADMIN_PASSWORD)\n\n")
+		fmt.Fprintf(os.Stderr, "Source Options:\n")
+		fmt.Fprintf(os.Stderr, "  -created-column string\n")
+		fmt.Fprintf(os.Stderr, "        Source column with the original creation time, empty = do not preserve (default: f.created_at)\n\n")
 		fmt.Fprintf(os.Stderr, "Performance Options:\n")
 		fmt.Fprintf(os.Stderr, "  -workers int\n")
 		fmt.Fprintf(os.Stderr, "        Number of parallel workers for migration (default: 10)\n")
@@ -75,6 +98,18 @@ func main() {
 		fmt.Fprintf(os.Stderr, "        Maximum number of files to migrate (0 = no limit, default: 0)\n")
 		fmt.Fprintf(os.Stderr, "  -reverse\n")
 		fmt.Fprintf(os.Stderr, "        Process files from newest to oldest (by ID DESC); useful for incremental top-up migrations\n\n")
+		fmt.Fprintf(os.Stderr, "Rate Options:\n")
+		fmt.Fprintf(os.Stderr, "  -max-mbps float\n")
+		fmt.Fprintf(os.Stderr, "        Upload bandwidth limit in megabits per second, shared by all workers (0 = unlimited, default: 0)\n")
+		fmt.Fprintf(os.Stderr, "  -requests-per-second float\n")
+		fmt.Fprintf(os.Stderr, "        Maximum number of files processed per second (0 = unlimited, default: 0)\n")
+		fmt.Fprintf(os.Stderr, "  -window string\n")
+		fmt.Fprintf(os.Stderr, "        Daily time window (local time) in which migration runs, e.g. 22:00-06:00; outside it workers pause\n\n")
+		fmt.Fprintf(os.Stderr, "Test Options:\n")
+		fmt.Fprintf(os.Stderr, "  -test-only\n")
+		fmt.Fprintf(os.Stderr, "        Compare old and new Cumulus (content hash) without migration\n")
+		fmt.Fprintf(os.Stderr, "  -verify-batch int\n")
+		fmt.Fprintf(os.Stderr, "        Number of IDs checked per /base/files/old/exists request in test mode (default: 1000)\n\n")
 		fmt.Fprintf(os.Stderr, "Examples:\n")
 		fmt.Fprintf(os.Stderr, "  %s -db-host 192.168.1.100 -db-user cumulus -db-name cumulus_old -files-path /mnt/files\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -db-host localhost -db-user root -db-pass secret -db-name cumulus \\\n", os.Args[0])
@@ -92,10 +127,17 @@ func main() {
 	// New flags for API
 	apiHost := flag.String("api-host", "localhost", "Cumulus API host IP")
 	apiPort := flag.Int("api-port", 8080, "Cumulus API port")
+	adminUser := flag.String("admin-user", os.Getenv("ADMIN_USERNAME"), "Cumulus admin username (for created_at)")
+	adminPass := flag.String("admin-pass", os.Getenv("ADMIN_PASSWORD"), "Cumulus admin password (for created_at)")
+	createdColumn := flag.String("created-column", "f.created_at", "Source column with the original creation time (empty = now)")
 	workers := flag.Int("workers", 10, "Number of parallel workers")
 	limit := flag.Int("limit", 0, "Maximum number of files to migrate (0 = no limit)")
 	reverse := flag.Bool("reverse", false, "Process files from newest to oldest (ID DESC)")
 	testOnly := flag.Bool("test-only", false, "Test mode: compare old and new Cumulus without migration")
+	maxMbps := flag.Float64("max-mbps", 0, "Upload bandwidth limit in Mbit/s (0 = unlimited)")
+	requestsPerSecond := flag.Float64("requests-per-second", 0, "Maximum files per second (0 = unlimited)")
+	windowFlag := flag.String("window", "", "Daily time window HH:MM-HH:MM (local time) in which migration runs")
+	verifyBatch := flag.Int("verify-batch", 1000, "Number of IDs per bulk existence check in test mode")
 
 	flag.Parse()
 
@@ -104,6 +146,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	window, err := parseTimeWindow(*windowFlag)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	rate := newThrottle(*requestsPerSecond, *maxMbps, window)
+	// Zdrojovou DB nezatěžovat mimo povolené okno
+	rate.waitWindow()
+
 	// Connect to Source MySQL
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", *dbUser, *dbPass, *dbHost, *dbPort, *dbName)
 	db, err := sql.Open("mysql", dsn)
@@ -128,6 +178,10 @@ func main() {
 	if *limit > 0 {
 		limitClause = fmt.Sprintf("LIMIT %d", *limit)
 	}
+	createdSelect := "NULL"
+	if *createdColumn != "" {
+		createdSelect = *createdColumn
+	}
 	query := fmt.Sprintf(`
         SELECT
             f.id,
@@ -135,7 +189,8 @@ func main() {
             f.filename,
             rf.id as raw_id,
             rf.file_type as raw_file_type,
-            group_concat(l.label) as labels
+            group_concat(l.label) as labels,
+            %s as created_at
         FROM filenames f
             LEFT JOIN raw_files rf ON rf.id = f.files_id
             LEFT JOIN link_filenames_labels lfl ON lfl.filename_id = f.id
@@ -144,7 +199,7 @@ func main() {
         group by f.id
         ORDER BY f.id %s
         %s;
-    `, orderDir, limitClause)
+    `, createdSelect, orderDir, limitClause)
 
 	rows, err := db.Query(query)
 	if err != nil {
@@ -160,8 +215,9 @@ func main() {
 		var rawID int64
 		var rawFileType sql.NullString
 		var labels sql.NullString
+		var createdAt sql.NullString
 
-		if err := rows.Scan(&fID, &filesID, &filename, &rawID, &rawFileType, &labels); err != nil {
+		if err := rows.Scan(&fID, &filesID, &filename, &rawID, &rawFileType, &labels, &createdAt); err != nil {
 			log.Printf("Error scanning row: %v", err)
 			continue
 		}
@@ -182,6 +238,7 @@ func main() {
 			RawID:       rawID,
 			Tags:        tags,
 			ContentType: contentType,
+			CreatedAt:   createdAt.String,
 		})
 	}
 	rows.Close()
@@ -192,6 +249,9 @@ func main() {
 	} else {
 		log.Printf("Loaded %d files to migrate. Starting migration with %d workers...", len(filesToMigrate), *workers)
 	}
+	if *maxMbps > 0 || *requestsPerSecond > 0 || window != nil {
+		log.Printf("Rate limits: max-mbps=%g, requests-per-second=%g, window=%s", *maxMbps, *requestsPerSecond, *windowFlag)
+	}
 
 	// Create HTTP client with connection pooling
 	httpClient := &http.Client{
@@ -203,6 +263,21 @@ func main() {
 		},
 	}
 
+	// In test mode, fetch existence + hashes of all IDs in a few bulk requests
+	var existing map[int64]ExistingFile
+	if *testOnly {
+		existing, err = fetchExistingFiles(httpClient, *apiHost, *apiPort, filesToMigrate, *verifyBatch)
+		if err != nil {
+			log.Fatalf("Error checking existing files: %v", err)
+		}
+		log.Printf("Found %d of %d files in new Cumulus", len(existing), len(filesToMigrate))
+	}
+
+	auth := adminAuth{user: *adminUser, pass: *adminPass}
+	if *createdColumn != "" && !*testOnly && auth.user == "" {
+		log.Printf("WARNING: -admin-user not set, original created_at will not be preserved")
+	}
+
 	// Parallel processing
 	var (
 		successCount int64
@@ -219,8 +294,9 @@ func main() {
 		go func(workerID int) {
 			defer wg.Done()
 			for mFile := range jobs {
+				rate.waitTurn()
 				if *testOnly {
-					if mismatch := testFile(httpClient, *apiHost, *apiPort, *filesPath, mFile); mismatch != nil {
+					if mismatch := testFile(*filesPath, mFile, existing); mismatch != nil {
 						mismatchMux.Lock()
 						mismatches = append(mismatches, *mismatch)
 						mismatchMux.Unlock()
@@ -231,7 +307,7 @@ func main() {
 						atomic.AddInt64(&successCount, 1)
 					}
 				} else {
-					if err := migrateFile(httpClient, apiURL, *filesPath, mFile); err != nil {
+					if err := migrateFile(httpClient, apiURL, *filesPath, mFile, auth, rate); err != nil {
 						log.Printf("[Worker %d] ERROR: %s (ID: %d) - %v", workerID, mFile.Filename, mFile.FID, err)
 						atomic.AddInt64(&errorCount, 1)
 					} else {
@@ -285,7 +361,7 @@ func getInputFileName(id int64) string {
 }
 
 // migrateFile migrates a single file via API
-func migrateFile(client *http.Client, apiURL, filesPath string, mFile MigrationFile) error {
+func migrateFile(client *http.Client, apiURL, filesPath string, mFile MigrationFile, auth adminAuth, rate *throttle) error {
 	// Calculate source file path
 	roundedID := roundToThousands(mFile.RawID)
 	inputFileName := getInputFileName(mFile.RawID)
@@ -296,41 +372,67 @@ func migrateFile(client *http.Client, apiURL, filesPath string, mFile MigrationF
 		return fmt.Errorf("source file not found: %s", fullPath)
 	}
 
-	// Read and decompress file
 	file, err := os.Open(fullPath)
 	if err != nil {
 		return fmt.Errorf("error opening file: %w", err)
 	}
 	defer file.Close()
 
-	bz2Reader := bzip2.NewReader(file)
+	// Stream: bz2 -> multipart -> HTTP body, without holding the content in memory
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeMigrationForm(writer, bzip2.NewReader(file), mFile, auth))
+	}()
+	defer pr.Close() // unblocks the writer goroutine when the request fails early
 
-	// Read decompressed content into memory
-	decompressedData, err := io.ReadAll(bz2Reader)
+	// Create request
+	req, err := http.NewRequest("POST", apiURL, rate.body(pr))
 	if err != nil {
-		return fmt.Errorf("error decompressing file: %w", err)
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if auth.user != "" {
+		req.SetBasicAuth(auth.user, auth.pass)
 	}
 
-	// Prepare multipart form
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	// Add file
-	cleanFilename := filepath.Base(mFile.Filename)
-	part, err := writer.CreateFormFile("file", cleanFilename)
+	// Send request
+	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("error creating form file: %w", err)
+		return fmt.Errorf("error sending request: %w", err)
 	}
+	defer resp.Body.Close()
 
-	if _, err := io.Copy(part, bytes.NewReader(decompressedData)); err != nil {
-		return fmt.Errorf("error writing file data: %w", err)
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 
+	return nil
+}
+
+// writeMigrationForm writes the metadata fields and the decompressed file into the multipart writer.
+// Fields go first so the server sees them before the (possibly large) file part.
+func writeMigrationForm(writer *multipart.Writer, content io.Reader, mFile MigrationFile, auth adminAuth) error {
 	// Add old_cumulus_id
 	if err := writer.WriteField("old_cumulus_id", strconv.FormatInt(mFile.FID, 10)); err != nil {
 		return fmt.Errorf("error writing old_cumulus_id: %w", err)
 	}
 
+	// Original content type – used by the API when magic-byte detection yields generic binary
+	if contentType := mapContentType(mFile.ContentType); contentType != "" {
+		if err := writer.WriteField("content_type", contentType); err != nil {
+			return fmt.Errorf("error writing content_type: %w", err)
+		}
+	}
+
+	// Original creation time (admin-only field on the API side)
+	if mFile.CreatedAt != "" && auth.user != "" {
+		if err := writer.WriteField("created_at", mFile.CreatedAt); err != nil {
+			return fmt.Errorf("error writing created_at: %w", err)
+		}
+	}
+
 	// Add tags if present
 	if mFile.Tags != "" {
 		if err := writer.WriteField("tags", mFile.Tags); err != nil {
@@ -338,131 +440,132 @@ func migrateFile(client *http.Client, apiURL, filesPath string, mFile MigrationF
 		}
 	}
 
-	writer.Close()
-
-	// Create request
-	req, err := http.NewRequest("POST", apiURL, body)
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
-	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	// Send request
-	resp, err := client.Do(req)
+	// Add file
+	part, err := writer.CreateFormFile("file", filepath.Base(mFile.Filename))
 	if err != nil {
-		return fmt.Errorf("error sending request: %w", err)
+		return fmt.Errorf("error creating form file: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	if _, err := io.Copy(part, content); err != nil {
+		return fmt.Errorf("error decompressing file: %w", err)
 	}
 
-	return nil
+	return writer.Close()
 }
 
-// testFile compares file from old Cumulus with new Cumulus via API
-func testFile(client *http.Client, apiHost string, apiPort int, filesPath string, mFile MigrationFile) *TestMismatch {
-	// Load and decompress old file
-	roundedID := roundToThousands(mFile.RawID)
-	inputFileName := getInputFileName(mFile.RawID)
-	fullPath := filepath.Join(filesPath, fmt.Sprintf("%d", roundedID), inputFileName)
+// fetchExistingFiles looks up all old IDs via POST /base/files/old/exists in batches
+func fetchExistingFiles(client *http.Client, apiHost string, apiPort int, files []MigrationFile, batchSize int) (map[int64]ExistingFile, error) {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	apiURL := fmt.Sprintf("http://%s:%d/base/files/old/exists", apiHost, apiPort)
+	existing := make(map[int64]ExistingFile, len(files))
 
-	// Check if source file exists
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		return &TestMismatch{
-			CumulusID: mFile.FID,
-			Filename:  mFile.Filename,
-			Status:    "missing",
-			Error:     fmt.Sprintf("source file not found: %s", fullPath),
+	for start := 0; start < len(files); start += batchSize {
+		end := min(start+batchSize, len(files))
+		ids := make([]int64, 0, end-start)
+		for _, f := range files[start:end] {
+			ids = append(ids, f.FID)
 		}
-	}
 
-	// Read and decompress file
-	file, err := os.Open(fullPath)
-	if err != nil {
-		return &TestMismatch{
-			CumulusID: mFile.FID,
-			Filename:  mFile.Filename,
-			Status:    "missing",
-			Error:     fmt.Sprintf("error opening file: %v", err),
+		payload, err := json.Marshal(map[string]interface{}{"ids": ids})
+		if err != nil {
+			return nil, fmt.Errorf("error encoding request: %w", err)
+		}
+		resp, err := client.Post(apiURL, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("API error: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(bodyBytes))
 		}
-	}
-	defer file.Close()
 
-	bz2Reader := bzip2.NewReader(file)
-	oldData, err := io.ReadAll(bz2Reader)
-	if err != nil {
-		return &TestMismatch{
-			CumulusID: mFile.FID,
-			Filename:  mFile.Filename,
-			Status:    "missing",
-			Error:     fmt.Sprintf("error decompressing file: %v", err),
+		var result struct {
+			Found map[string]ExistingFile `json:"found"`
 		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding API response: %w", err)
+		}
+		for key, f := range result.Found {
+			id, err := strconv.ParseInt(key, 10, 64)
+			if err != nil {
+				continue
+			}
+			existing[id] = f
+		}
+		log.Printf("Checked %d/%d IDs", end, len(files))
 	}
+	return existing, nil
+}
 
-	// Calculate old file hash
-	oldHash := calculateHash(oldData)
-
-	// Call API to get file from new Cumulus
-	apiURL := fmt.Sprintf("http://%s:%d/base/files/old/%d", apiHost, apiPort, mFile.FID)
-	resp, err := client.Get(apiURL)
-	if err != nil {
-		return &TestMismatch{
-			CumulusID: mFile.FID,
-			Filename:  mFile.Filename,
-			Status:    "missing",
-			OldHash:   oldHash,
-			Error:     fmt.Sprintf("API error: %v", err),
+// mapContentType converts raw_file_type from the old Cumulus to a MIME type.
+// Values are either MIME types already or bare extensions (e.g. "pdf", ".docx").
+func mapContentType(raw string) string {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "" {
+		return ""
+	}
+	if strings.Contains(raw, "/") {
+		if mediaType, _, err := mime.ParseMediaType(raw); err == nil {
+			return mediaType
 		}
+		return ""
 	}
-	defer resp.Body.Close()
+	return mime.TypeByExtension("." + strings.TrimPrefix(raw, "."))
+}
 
-	// Check if file exists in new Cumulus
-	if resp.StatusCode == http.StatusNotFound {
+// testFile compares file from old Cumulus with the hash reported by new Cumulus
+func testFile(filesPath string, mFile MigrationFile, existing map[int64]ExistingFile) *TestMismatch {
+	newFile, ok := existing[mFile.FID]
+	if !ok {
 		return &TestMismatch{
 			CumulusID: mFile.FID,
 			Filename:  mFile.Filename,
 			Status:    "missing",
-			OldHash:   oldHash,
 			Error:     "file not found in new Cumulus",
 		}
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	// Load and decompress old file
+	roundedID := roundToThousands(mFile.RawID)
+	inputFileName := getInputFileName(mFile.RawID)
+	fullPath := filepath.Join(filesPath, fmt.Sprintf("%d", roundedID), inputFileName)
+
+	file, err := os.Open(fullPath)
+	if err != nil {
 		return &TestMismatch{
 			CumulusID: mFile.FID,
 			Filename:  mFile.Filename,
 			Status:    "missing",
-			OldHash:   oldHash,
-			Error:     fmt.Sprintf("API returned status %d", resp.StatusCode),
+			NewHash:   newFile.Hash,
+			Error:     fmt.Sprintf("error opening source file: %v", err),
 		}
 	}
+	defer file.Close()
 
-	// Read new file data
-	newData, err := io.ReadAll(resp.Body)
+	// Calculate old file hash (same BLAKE2b-256 as Cumulus3 dedup)
+	oldHash, err := calculateHash(bzip2.NewReader(file))
 	if err != nil {
 		return &TestMismatch{
 			CumulusID: mFile.FID,
 			Filename:  mFile.Filename,
 			Status:    "missing",
-			OldHash:   oldHash,
-			Error:     fmt.Sprintf("error reading API response: %v", err),
+			NewHash:   newFile.Hash,
+			Error:     fmt.Sprintf("error decompressing file: %v", err),
 		}
 	}
 
-	// Calculate new file hash
-	newHash := calculateHash(newData)
-
 	// Compare hashes
-	if oldHash != newHash {
+	if oldHash != newFile.Hash {
 		return &TestMismatch{
 			CumulusID: mFile.FID,
 			Filename:  mFile.Filename,
 			Status:    "hash_mismatch",
 			OldHash:   oldHash,
-			NewHash:   newHash,
+			NewHash:   newFile.Hash,
 		}
 	}
 
@@ -470,10 +573,13 @@ func testFile(client *http.Client, apiHost string, apiPort int, filesPath string
 	return nil
 }
 
-// calculateHash computes SHA-256 hash of data
-func calculateHash(data []byte) string {
-	hash := sha256.Sum256(data)
-	return hex.EncodeToString(hash[:])
+// calculateHash computes BLAKE2b-256 hash of the stream
+func calculateHash(r io.Reader) (string, error) {
+	hasher, _ := blake2b.New256(nil)
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
 // saveMismatchesToJSON saves mismatches to a JSON file
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// limiter spaces out events to a fixed rate shared by all workers (no bursts).
+// A nil limiter never waits.
+type limiter struct {
+	mu   sync.Mutex
+	rate float64 // units per second
+	next time.Time
+}
+
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &limiter{rate: rate}
+}
+
+// wait blocks until n units may be consumed
+func (l *limiter) wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// throttledReader limits the upload bandwidth of a request body
+type throttledReader struct {
+	r io.Reader
+	l *limiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// Malé bloky, aby limit platil plynule i pro velké soubory
+	if len(p) > 32*1024 {
+		p = p[:32*1024]
+	}
+	n, err := t.r.Read(p)
+	t.l.wait(n)
+	return n, err
+}
+
+// timeWindow is a daily window (local time) in which migration may run, e.g. 22:00-06:00
+type timeWindow struct {
+	start time.Duration // offset from midnight
+	end   time.Duration
+}
+
+// parseTimeWindow parses "HH:MM-HH:MM"; the window may cross midnight. Empty string = no window.
+func parseTimeWindow(value string) (*timeWindow, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", value)
+	}
+	var offsets [2]time.Duration
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", value, err)
+		}
+		offsets[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	if offsets[0] == offsets[1] {
+		return nil, fmt.Errorf("invalid window %q: start equals end", value)
+	}
+	return &timeWindow{start: offsets[0], end: offsets[1]}, nil
+}
+
+func (w *timeWindow) String() string {
+	format := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return format(w.start) + "-" + format(w.end)
+}
+
+// untilOpen returns how long to wait from t until the window opens (0 = open now)
+func (w *timeWindow) untilOpen(t time.Time) time.Duration {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+
+	var open bool
+	if w.start < w.end {
+		open = offset >= w.start && offset < w.end
+	} else {
+		open = offset >= w.start || offset < w.end
+	}
+	if open {
+		return 0
+	}
+
+	next := midnight.Add(w.start)
+	if !next.After(t) {
+		next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()).Add(w.start)
+	}
+	return next.Sub(t)
+}
+
+// throttle bundles the rate controls shared by all workers
+type throttle struct {
+	requests  *limiter
+	bandwidth *limiter
+	window    *timeWindow
+
+	mu         sync.Mutex
+	pausedNote time.Time // avoid logging the pause from every worker
+}
+
+func newThrottle(requestsPerSecond, maxMbps float64, window *timeWindow) *throttle {
+	return &throttle{
+		requests:  newLimiter(requestsPerSecond),
+		bandwidth: newLimiter(maxMbps * 1000 * 1000 / 8),
+		window:    window,
+	}
+}
+
+// waitWindow blocks until the migration window is open
+func (t *throttle) waitWindow() {
+	if t.window == nil {
+		return
+	}
+	for {
+		delay := t.window.untilOpen(time.Now())
+		if delay == 0 {
+			return
+		}
+		t.mu.Lock()
+		if time.Since(t.pausedNote) > time.Hour {
+			t.pausedNote = time.Now()
+			log.Printf("Outside migration window %s, pausing for %s", t.window, delay.Round(time.Second))
+		}
+		t.mu.Unlock()
+		// Spát po kratších úsecích kvůli změnám času (DST, NTP)
+		time.Sleep(min(delay, time.Minute))
+	}
+}
+
+// waitTurn blocks until the window is open and the request rate allows another request
+func (t *throttle) waitTurn() {
+	t.waitWindow()
+	t.requests.wait(1)
+}
+
+// body wraps a request body with the bandwidth limit
+func (t *throttle) body(r io.Reader) io.Reader {
+	if t.bandwidth == nil {
+		return r
+	}
+	return &throttledReader{r: r, l: t.bandwidth}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"compress/gzip"
-	"encoding/binary"
 	"flag"
 	"fmt"
 	"io"
@@ -11,11 +10,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/klauspost/compress/zstd"
 	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
 	"github.com/pmalasek/cumulus3/src/internal/utils"
 )
 
@@ -29,24 +28,35 @@ type BlobInfo struct {
 	Hash           string
 }
 
-type FileInfo struct {
-	ID           string
-	Name         string
-	BlobID       int64
-	OldCumulusID *int64
-	ExpiresAt    *int64
-	CreatedAt    int64
-	Tags         string
-}
-
 func main() {
 	// Load .env if exists
 	godotenv.Load()
 
 	dataDir := flag.String("data-dir", "./data/volumes", "Path to data directory with volume files")
 	dbPath := flag.String("db-path", "", "Path to output database file (SQLite only)")
+	deepDetect := flag.Bool("deep-detect", false, "Decompress the start of compressed blobs for file type detection (slower, accurate types)")
 	flag.Parse()
 
+	// Same sample size as the server (FILE_DETECTION_SAMPLE_SIZE)
+	sampleSize := utils.DefaultDetectionSampleSize
+	if val := os.Getenv("FILE_DETECTION_SAMPLE_SIZE"); val != "" {
+		size, err := utils.ParseBytes(val)
+		if err != nil || size <= 0 || size > utils.MaxDetectionSampleSize {
+			log.Fatalf("Invalid FILE_DETECTION_SAMPLE_SIZE: %s (max 16MB)", val)
+		}
+		sampleSize = size
+	}
+
+	// Same file type detectors as the server
+	if err := utils.EnableFallbackDetection(os.Getenv("FILE_DETECTION_FALLBACK")); err != nil {
+		log.Fatalf("Invalid FILE_DETECTION_FALLBACK: %v", err)
+	}
+	if path := os.Getenv("FILE_DETECTORS_CONFIG"); path != "" {
+		if err := utils.LoadDetectorConfig(path); err != nil {
+			log.Fatalf("Failed to load FILE_DETECTORS_CONFIG: %v", err)
+		}
+	}
+
 	// Get database type from environment
 	dbType := os.Getenv("DATABASE_TYPE")
 	if dbType == "" {
@@ -106,7 +116,8 @@ func main() {
 	fmt.Println("===================================")
 	fmt.Printf("Data directory: %s\n", *dataDir)
 	fmt.Printf("Database type: %s\n", dbType)
-	fmt.Printf("Output: %s\n\n", outputDesc)
+	fmt.Printf("Output: %s\n", outputDesc)
+	fmt.Printf("Type detection: %d bytes, deep detect: %v\n\n", sampleSize, *deepDetect)
 
 	// Initialize database
 	fmt.Println("📊 Initializing database schema...")
@@ -126,18 +137,18 @@ func main() {
 
 	// Read files metadata
 	fmt.Println("\n📂 Reading files metadata...")
-	allFiles, err := readFilesMetadata(filepath.Join(filepath.Dir(*dataDir), "database", "files_metadata.bin"))
+	allFiles, err := readFilesMetadata(filepath.Join(filepath.Dir(*dataDir), "database", format.MetadataLogName))
 	if err != nil {
-		allFiles, err = readFilesMetadata(filepath.Join(*dataDir, "files_metadata.bin"))
+		allFiles, err = readFilesMetadata(filepath.Join(*dataDir, format.MetadataLogName))
 		if err != nil {
-			log.Printf("⚠️  Warning: Failed to read files_metadata.bin: %v", err)
-			allFiles = []FileInfo{}
+			log.Printf("⚠️  Warning: Failed to read %s: %v", format.MetadataLogName, err)
+			allFiles = []storage.File{}
 		}
 	}
 
 	// Deduplicate files: Keep only the LATEST record for each blob_id+name combination
 	// files_metadata.bin is append-only, so later records represent re-uploads
-	fileMap := make(map[string]FileInfo) // key: "blob_id:name"
+	fileMap := make(map[string]storage.File) // key: "blob_id:name"
 	for _, file := range allFiles {
 		key := fmt.Sprintf("%d:%s", file.BlobID, file.Name)
 		// Always overwrite with latest record (last one wins)
@@ -145,7 +156,7 @@ func main() {
 	}
 
 	// Convert map back to slice
-	files := make([]FileInfo, 0, len(fileMap))
+	files := make([]storage.File, 0, len(fileMap))
 	for _, file := range fileMap {
 		files = append(files, file)
 	}
@@ -160,7 +171,7 @@ func main() {
 	blobCount := 0
 	skippedDuplicates := 0
 	for _, blob := range blobs {
-		mimeType, category, subtype := detectBlobType(*dataDir, blob)
+		mimeType, category, subtype := detectBlobType(*dataDir, blob, sampleSize, *deepDetect)
 
 		fileTypeID, err := meta.GetOrCreateFileType(mimeType, category, subtype)
 		if err != nil {
@@ -179,12 +190,7 @@ func main() {
 			continue
 		}
 
-		compAlg := "none"
-		if blob.CompAlg == 1 {
-			compAlg = "gzip"
-		} else if blob.CompAlg == 2 {
-			compAlg = "zstd"
-		}
+		compAlg := format.CompressionName(blob.CompAlg)
 
 		err = meta.UpdateBlobLocation(blob.ID, blob.VolumeID, blob.Offset, blob.SizeRaw, blob.SizeCompressed, compAlg, fileTypeID)
 		if err != nil {
@@ -209,31 +215,30 @@ func main() {
 		existingBlobs[blob.ID] = true
 	}
 
+	// old_cumulus_id přesunuté uploadem s on_conflict=supersede patří poslednímu záznamu v logu
+	oldIDHolder := make(map[int64]int)
+	for i, file := range files {
+		if file.OldCumulusID != nil && existingBlobs[file.BlobID] {
+			oldIDHolder[*file.OldCumulusID] = i
+		}
+	}
+
 	// Insert files
 	fmt.Println("  → Inserting files...")
 	fileCount := 0
 	skippedOrphaned := 0
-	for _, file := range files {
+	superseded := 0
+	for i, file := range files {
 		// Skip files referencing non-existent blobs (orphaned after deletions/compaction)
 		if !existingBlobs[file.BlobID] {
 			skippedOrphaned++
 			continue
 		}
-		var expiresAt *time.Time
-		if file.ExpiresAt != nil {
-			t := time.Unix(*file.ExpiresAt, 0)
-			expiresAt = &t
+		if file.OldCumulusID != nil && oldIDHolder[*file.OldCumulusID] != i {
+			file.OldCumulusID = nil
+			superseded++
 		}
-
-		err := meta.SaveFile(storage.File{
-			ID:           file.ID,
-			Name:         file.Name,
-			BlobID:       file.BlobID,
-			OldCumulusID: file.OldCumulusID,
-			ExpiresAt:    expiresAt,
-			CreatedAt:    time.Unix(file.CreatedAt, 0),
-			Tags:         file.Tags,
-		})
+		err := meta.SaveFile(file)
 		if err != nil {
 			log.Printf("Warning: Failed to save file %s: %v", file.ID, err)
 			continue
@@ -248,6 +253,9 @@ func main() {
 	if skippedOrphaned > 0 {
 		fmt.Printf(" (skipped %d orphaned)", skippedOrphaned)
 	}
+	if superseded > 0 {
+		fmt.Printf(" (%d lost a superseded old_cumulus_id)", superseded)
+	}
 	fmt.Println("                    ")
 
 	// Update volumes table
@@ -325,7 +333,7 @@ func scanVolumes(dir string) ([]BlobInfo, map[int64]int64, error) {
 				blobs = append(blobs, volumeBlobs...)
 				totalSize := int64(0)
 				for _, blob := range volumeBlobs {
-					totalSize += int64(storage.HeaderSize) + blob.SizeCompressed + int64(storage.FooterSize)
+					totalSize += format.BlobTotalSize(blob.SizeCompressed)
 				}
 				volumeSizes[volumeID] = totalSize
 				continue
@@ -343,7 +351,7 @@ func scanVolumes(dir string) ([]BlobInfo, map[int64]int64, error) {
 
 		totalSize := int64(0)
 		for _, blob := range volumeBlobs {
-			totalSize += int64(storage.HeaderSize) + blob.SizeCompressed + int64(storage.FooterSize)
+			totalSize += format.BlobTotalSize(blob.SizeCompressed)
 		}
 		volumeSizes[volumeID] = totalSize
 	}
@@ -359,8 +367,7 @@ func readMetaFile(metaPath, datPath string, volumeID int64) ([]BlobInfo, error)
 	defer f.Close()
 
 	blobs := []BlobInfo{}
-	recordSize := 29
-	buf := make([]byte, recordSize)
+	buf := make([]byte, format.MetaRecordSize)
 
 	for {
 		if _, err := io.ReadFull(f, buf); err != nil {
@@ -370,10 +377,11 @@ func readMetaFile(metaPath, datPath string, volumeID int64) ([]BlobInfo, error)
 			return nil, err
 		}
 
-		blobID := int64(binary.BigEndian.Uint64(buf[0:8]))
-		offset := int64(binary.BigEndian.Uint64(buf[8:16]))
-		size := int64(binary.BigEndian.Uint64(buf[16:24]))
-		compAlg := buf[24]
+		rec, err := format.DecodeMetaRecord(buf)
+		if err != nil {
+			return nil, err
+		}
+		blobID, offset, size, compAlg := rec.BlobID, rec.Offset, rec.Size, rec.CompAlg
 
 		hash := fmt.Sprintf("blob_%d", blobID)
 
@@ -406,7 +414,7 @@ func scanDatFile(file string, volumeID int64) ([]BlobInfo, error) {
 	defer f.Close()
 
 	blobs := []BlobInfo{}
-	header := make([]byte, storage.HeaderSize)
+	header := make([]byte, format.HeaderSize)
 
 	for {
 		offset, _ := f.Seek(0, io.SeekCurrent)
@@ -418,14 +426,11 @@ func scanDatFile(file string, volumeID int64) ([]BlobInfo, error) {
 			return blobs, nil
 		}
 
-		magic := binary.BigEndian.Uint32(header[0:4])
-		if magic != uint32(storage.MagicBytes) {
+		h, err := format.DecodeHeader(header)
+		if err != nil {
 			break
 		}
-
-		compAlg := header[5]
-		size := int64(binary.BigEndian.Uint64(header[6:14]))
-		blobID := int64(binary.BigEndian.Uint64(header[14:22]))
+		compAlg, size, blobID := h.CompAlg, h.Size, h.BlobID
 
 		hash := fmt.Sprintf("blob_%d", blobID)
 
@@ -446,7 +451,7 @@ func scanDatFile(file string, volumeID int64) ([]BlobInfo, error) {
 			Hash:           hash,
 		})
 
-		if _, err := f.Seek(size+int64(storage.FooterSize), io.SeekCurrent); err != nil {
+		if _, err := f.Seek(size+format.FooterSize, io.SeekCurrent); err != nil {
 			break
 		}
 	}
@@ -462,7 +467,7 @@ func calculateRawSize(datPath string, offset, sizeCompressed int64, compAlg uint
 	defer f.Close()
 
 	// Seek to data (skip header)
-	if _, err := f.Seek(offset+int64(storage.HeaderSize), io.SeekStart); err != nil {
+	if _, err := f.Seek(offset+format.HeaderSize, io.SeekStart); err != nil {
 		return 0, err
 	}
 
@@ -474,9 +479,9 @@ func calculateRawSize(datPath string, offset, sizeCompressed int64, compAlg uint
 
 	// Decompress based on algorithm
 	switch compAlg {
-	case 0: // none
+	case format.CompNone:
 		return sizeCompressed, nil
-	case 1: // gzip
+	case format.CompGzip:
 		gr, err := gzip.NewReader(bytes.NewReader(compressedData))
 		if err != nil {
 			return 0, err
@@ -497,7 +502,7 @@ func calculateRawSize(datPath string, offset, sizeCompressed int64, compAlg uint
 			}
 		}
 		return rawSize, nil
-	case 2: // zstd
+	case format.CompZstd:
 		zr, err := zstd.NewReader(bytes.NewReader(compressedData))
 		if err != nil {
 			return 0, err
@@ -523,88 +528,21 @@ func calculateRawSize(datPath string, offset, sizeCompressed int64, compAlg uint
 	}
 }
 
-func readFilesMetadata(path string) ([]FileInfo, error) {
-	f, err := os.Open(path)
+// readFilesMetadata reads all records of the recovery log (shared codec with the server logger)
+func readFilesMetadata(path string) ([]storage.File, error) {
+	files, corrupt, err := storage.ReadLogFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-
-	files := []FileInfo{}
-
-	for {
-		lenBuf := make([]byte, 4)
-		if _, err := io.ReadFull(f, lenBuf); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, err
-		}
-		recordLen := binary.BigEndian.Uint32(lenBuf)
-
-		record := make([]byte, recordLen)
-		if _, err := io.ReadFull(f, record); err != nil {
-			return nil, err
-		}
-
-		cursor := 0
-
-		idLen := binary.BigEndian.Uint16(record[cursor : cursor+2])
-		cursor += 2
-
-		id := string(record[cursor : cursor+int(idLen)])
-		cursor += int(idLen)
-
-		blobID := int64(binary.BigEndian.Uint64(record[cursor : cursor+8]))
-		cursor += 8
-
-		createdAt := int64(binary.BigEndian.Uint64(record[cursor : cursor+8]))
-		cursor += 8
-
-		flags := record[cursor]
-		cursor += 1
-
-		var oldCumulusID *int64
-		var expiresAt *int64
-		var tags string
-
-		if flags&(1<<0) != 0 {
-			val := int64(binary.BigEndian.Uint64(record[cursor : cursor+8]))
-			oldCumulusID = &val
-			cursor += 8
-		}
-		if flags&(1<<1) != 0 {
-			val := int64(binary.BigEndian.Uint64(record[cursor : cursor+8]))
-			expiresAt = &val
-			cursor += 8
-		}
-		if flags&(1<<2) != 0 {
-			tagsLen := binary.BigEndian.Uint16(record[cursor : cursor+2])
-			cursor += 2
-			tags = string(record[cursor : cursor+int(tagsLen)])
-			cursor += int(tagsLen)
-		}
-
-		nameLen := binary.BigEndian.Uint16(record[cursor : cursor+2])
-		cursor += 2
-
-		name := string(record[cursor : cursor+int(nameLen)])
-
-		files = append(files, FileInfo{
-			ID:           id,
-			Name:         name,
-			BlobID:       blobID,
-			OldCumulusID: oldCumulusID,
-			ExpiresAt:    expiresAt,
-			CreatedAt:    createdAt,
-			Tags:         tags,
-		})
+	if corrupt > 0 {
+		log.Printf("⚠️  Warning: Skipped %d corrupt or incomplete records in %s", corrupt, path)
 	}
-
 	return files, nil
 }
 
-func detectBlobType(dataDir string, blob BlobInfo) (string, string, string) {
+// detectBlobType detects the type from the first sampleSize bytes of a blob. The data of
+// compressed blobs is detected as stored unless deep is set, then it is decompressed first.
+func detectBlobType(dataDir string, blob BlobInfo, sampleSize int64, deep bool) (string, string, string) {
 	volumePath := filepath.Join(dataDir, fmt.Sprintf("volume_%08d.dat", blob.VolumeID))
 	f, err := os.Open(volumePath)
 	if err != nil {
@@ -612,21 +550,33 @@ func detectBlobType(dataDir string, blob BlobInfo) (string, string, string) {
 	}
 	defer f.Close()
 
-	if _, err := f.Seek(blob.Offset+int64(storage.HeaderSize), io.SeekStart); err != nil {
-		return "application/octet-stream", "binary", ""
+	data := io.NewSectionReader(f, blob.Offset+format.HeaderSize, blob.SizeCompressed)
+	var content io.Reader = data
+	if deep {
+		switch blob.CompAlg {
+		case format.CompGzip:
+			gr, err := gzip.NewReader(data)
+			if err != nil {
+				return "application/octet-stream", "binary", ""
+			}
+			defer gr.Close()
+			content = gr
+		case format.CompZstd:
+			zr, err := zstd.NewReader(data)
+			if err != nil {
+				return "application/octet-stream", "binary", ""
+			}
+			defer zr.Close()
+			content = zr
+		}
 	}
 
-	sampleSize := int64(512)
-	if blob.SizeCompressed < sampleSize {
-		sampleSize = blob.SizeCompressed
-	}
 	sample := make([]byte, sampleSize)
-	if _, err := io.ReadFull(f, sample); err != nil {
+	n, err := io.ReadFull(content, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return "application/octet-stream", "binary", ""
 	}
 
-	// For now, just detect from raw/compressed data
-	// Full decompression would be too slow for rebuild
-	result := utils.DetectFileType(sample)
+	result := utils.DetectFileType(sample[:n])
 	return result.ContentType, result.Type, result.Subtype
 }
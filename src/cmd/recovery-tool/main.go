@@ -2,16 +2,21 @@ package main
 
 import (
 	"compress/gzip"
-	"encoding/binary"
+	"encoding/csv"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/klauspost/compress/zstd"
 	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
 )
 
 // BlobLocation drží informaci, kde najít data pro dané BlobID
@@ -22,15 +27,28 @@ type BlobLocation struct {
 	CompAlg        uint8
 }
 
+// Způsoby pojmenování obnovených souborů
+const (
+	layoutPrefix = "prefix" // <fileID>_<název>
+	layoutDirs   = "dirs"   // <fileID>/<název>
+)
+
+// manifestName je CSV s mapováním obnovených cest na ID souborů
+const manifestName = "manifest.csv"
+
 func main() {
 	dataPath := flag.String("src", "./data", "Cesta ke zdrojovým datům (kde jsou volume_*.dat a files_metadata.bin)")
 	restorePath := flag.String("dst", "./restored", "Cesta, kam se mají obnovit soubory")
+	layout := flag.String("layout", layoutPrefix, "Pojmenování souborů: 'prefix' (<fileID>_<název>) nebo 'dirs' (<fileID>/<název>)")
 	flag.Parse()
 
 	if *dataPath == "" || *restorePath == "" {
 		flag.Usage()
 		os.Exit(1)
 	}
+	if *layout != layoutPrefix && *layout != layoutDirs {
+		log.Fatalf("Neplatný -layout '%s' (povoleno: %s, %s)", *layout, layoutPrefix, layoutDirs)
+	}
 
 	fmt.Println("🔍 Začínám analýzu volume souborů...")
 	blobMap, err := scanVolumes(*dataPath)
@@ -40,12 +58,12 @@ func main() {
 	fmt.Printf("✅ Nalezeno %d unikátních blobů.\n", len(blobMap))
 
 	fmt.Println("📂 Začínám obnovu souborů z files_metadata.bin...")
-	count, err := restoreFiles(*dataPath, *restorePath, blobMap)
+	count, err := restoreFiles(*dataPath, *restorePath, *layout, blobMap)
 	if err != nil {
 		log.Fatalf("Chyba při obnově: %v", err)
 	}
 
-	fmt.Printf("🎉 Hotovo! Obnoveno %d souborů do '%s'.\n", count, *restorePath)
+	fmt.Printf("🎉 Hotovo! Obnoveno %d souborů do '%s' (manifest: %s).\n", count, *restorePath, manifestName)
 }
 
 // scanVolumes projde všechny .dat soubory a zaindexuje bloby
@@ -85,8 +103,7 @@ func scanMetaFile(volPath, metaPath string, index map[int64]BlobLocation) error
 	}
 	defer f.Close()
 
-	recordSize := 29 // BlobID(8) + Offset(8) + Size(8) + Comp(1) + CRC(4)
-	buf := make([]byte, recordSize)
+	buf := make([]byte, format.MetaRecordSize)
 
 	for {
 		if _, err := io.ReadFull(f, buf); err != nil {
@@ -96,26 +113,18 @@ func scanMetaFile(volPath, metaPath string, index map[int64]BlobLocation) error
 			return err
 		}
 
-		blobID := int64(binary.BigEndian.Uint64(buf[0:8]))
-		offset := int64(binary.BigEndian.Uint64(buf[8:16]))
-		size := int64(binary.BigEndian.Uint64(buf[16:24]))
-		compAlg := buf[24]
-		// crc := binary.BigEndian.Uint32(buf[25:29])
-
-		// Offset v meta souboru ukazuje na začátek hlavičky v .dat souboru.
-		// Pro čtení dat potřebujeme přeskočit hlavičku (HeaderSize).
-		// Ale pozor: Store.WriteBlob vrací offset začátku hlavičky.
-		// A naše struktura BlobLocation očekává offset začátku DAT.
-		// Takže musíme přičíst HeaderSize.
-
-		// HeaderSize musíme importovat nebo definovat. Zde natvrdo 22 (4+1+1+8+8).
-		const HeaderSize = 22
+		rec, err := format.DecodeMetaRecord(buf)
+		if err != nil {
+			return err
+		}
 
-		index[blobID] = BlobLocation{
+		// Offset v meta souboru ukazuje na začátek hlavičky v .dat souboru,
+		// BlobLocation očekává offset začátku DAT.
+		index[rec.BlobID] = BlobLocation{
 			VolumePath:     volPath,
-			Offset:         offset + int64(HeaderSize),
-			SizeCompressed: size,
-			CompAlg:        compAlg,
+			Offset:         rec.DataOffset(),
+			SizeCompressed: rec.Size,
+			CompAlg:        rec.CompAlg,
 		}
 	}
 	return nil
@@ -135,7 +144,7 @@ func scanDatFile(file string, index map[int64]BlobLocation) {
 		offset, _ := f.Seek(0, io.SeekCurrent)
 
 		// Čteme hlavičku
-		header := make([]byte, storage.HeaderSize)
+		header := make([]byte, format.HeaderSize)
 		if _, err := io.ReadFull(f, header); err != nil {
 			if err == io.EOF {
 				break // Konec souboru
@@ -144,35 +153,32 @@ func scanDatFile(file string, index map[int64]BlobLocation) {
 			break
 		}
 
-		magic := binary.BigEndian.Uint32(header[0:4])
-		if magic != uint32(storage.MagicBytes) {
-			log.Printf("Chyba: Neplatný magic number na offsetu %d v %s. Přeskakuji zbytek souboru.", offset, file)
+		h, err := format.DecodeHeader(header)
+		if err != nil {
+			log.Printf("Chyba: Neplatná hlavička na offsetu %d v %s (%v). Přeskakuji zbytek souboru.", offset, file, err)
 			break
 		}
 
-		// ver := header[4]
-		compAlg := header[5]
-		size := int64(binary.BigEndian.Uint64(header[6:14]))
-		blobID := int64(binary.BigEndian.Uint64(header[14:22]))
-
 		// Uložíme do indexu (offset ukazuje na začátek dat, tj. za hlavičkou)
-		index[blobID] = BlobLocation{
+		index[h.BlobID] = BlobLocation{
 			VolumePath:     file,
-			Offset:         offset + int64(storage.HeaderSize),
-			SizeCompressed: size,
-			CompAlg:        compAlg,
+			Offset:         offset + format.HeaderSize,
+			SizeCompressed: h.Size,
+			CompAlg:        h.CompAlg,
 		}
 
 		// Přeskočíme data a patičku
-		if _, err := f.Seek(size+int64(storage.FooterSize), io.SeekCurrent); err != nil {
+		if _, err := f.Seek(h.Size+format.FooterSize, io.SeekCurrent); err != nil {
 			break
 		}
 	}
 }
 
-// restoreFiles čte files_metadata.bin a obnovuje soubory
-func restoreFiles(srcDir, dstDir string, blobIndex map[int64]BlobLocation) (int, error) {
-	logPath := filepath.Join(srcDir, "files_metadata.bin")
+// restoreFiles čte files_metadata.bin a obnovuje soubory.
+// Výstupní cesty obsahují fileID, takže soubory se stejným názvem se nepřepisují;
+// mapování cest na fileID, tagy a časy se zapisuje do manifest.csv.
+func restoreFiles(srcDir, dstDir, layout string, blobIndex map[int64]BlobLocation) (int, error) {
+	logPath := filepath.Join(srcDir, format.MetadataLogName)
 	if _, err := os.Stat(logPath); os.IsNotExist(err) {
 		// Fallback to old name
 		logPathLegacy := filepath.Join(srcDir, "files.bin")
@@ -192,83 +198,96 @@ func restoreFiles(srcDir, dstDir string, blobIndex map[int64]BlobLocation) (int,
 		return 0, err
 	}
 
+	manifestFile, err := os.Create(filepath.Join(dstDir, manifestName))
+	if err != nil {
+		return 0, fmt.Errorf("nelze vytvořit manifest: %w", err)
+	}
+	defer manifestFile.Close()
+	manifest := csv.NewWriter(manifestFile)
+	defer manifest.Flush()
+	manifest.Write([]string{"path", "file_id", "blob_id", "old_cumulus_id", "original_name", "tags", "created_at", "expires_at"})
+
 	restoredCount := 0
 	decoder, _ := zstd.NewReader(nil)
 	defer decoder.Close()
 
+	reader := format.NewLogReader(f)
 	for {
-		// 1. Přečíst délku záznamu
-		lenBuf := make([]byte, 4)
-		if _, err := io.ReadFull(f, lenBuf); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return restoredCount, err
-		}
-		recordLen := binary.BigEndian.Uint32(lenBuf)
-
-		// 2. Přečíst celý záznam
-		record := make([]byte, recordLen)
-		if _, err := io.ReadFull(f, record); err != nil {
-			return restoredCount, err
+		rec, err := reader.Next()
+		if err == io.EOF {
+			break
 		}
-
-		// 3. Parsovat záznam (reverzní inženýrství logger.go)
-		// ID Len (2)
-		idLen := binary.BigEndian.Uint16(record[0:2])
-		// ID (idLen)
-		// id := string(record[2 : 2+idLen])
-		cursor := 2 + int(idLen)
-
-		// BlobID (8)
-		blobID := int64(binary.BigEndian.Uint64(record[cursor : cursor+8]))
-		cursor += 8
-
-		// CreatedAt (8)
-		cursor += 8
-
-		// Flags (1)
-		flags := record[cursor]
-		cursor += 1
-
-		// Optional fields based on flags
-		if flags&(1<<0) != 0 { // OldCumulusID
-			cursor += 8
+		if errors.Is(err, format.ErrCorruptLogRecord) {
+			log.Printf("❌ Chyba: Poškozený záznam v %s: %v", logPath, err)
+			continue
 		}
-		if flags&(1<<1) != 0 { // ExpiresAt
-			cursor += 8
+		if err == io.ErrUnexpectedEOF {
+			log.Printf("⚠️  Poslední záznam v %s je neúplný (přerušený zápis), končím.", logPath)
+			break
 		}
-		if flags&(1<<2) != 0 { // Tags
-			tagsLen := binary.BigEndian.Uint16(record[cursor : cursor+2])
-			cursor += 2 + int(tagsLen)
+		if err != nil {
+			return restoredCount, err
 		}
 
-		// Name Len (2)
-		nameLen := binary.BigEndian.Uint16(record[cursor : cursor+2])
-		cursor += 2
-
-		// Name
-		filename := string(record[cursor : cursor+int(nameLen)])
-
-		// 4. Obnovit soubor
-		loc, exists := blobIndex[blobID]
+		// Obnovit soubor
+		loc, exists := blobIndex[rec.BlobID]
 		if !exists {
-			log.Printf("❌ Chyba: BlobID %d pro soubor '%s' nebyl nalezen ve volumech.", blobID, filename)
+			log.Printf("❌ Chyba: BlobID %d pro soubor '%s' nebyl nalezen ve volumech.", rec.BlobID, rec.Name)
 			continue
 		}
 
-		if err := extractFile(dstDir, filename, loc, decoder); err != nil {
-			log.Printf("❌ Chyba při extrakci '%s': %v", filename, err)
-		} else {
-			// fmt.Printf("Obnoven: %s\n", filename)
-			restoredCount++
+		relPath := restoredPath(layout, rec)
+		if err := extractFile(filepath.Join(dstDir, relPath), loc, decoder); err != nil {
+			log.Printf("❌ Chyba při extrakci '%s': %v", rec.Name, err)
+			continue
+		}
+		restoredCount++
+
+		if err := manifest.Write(manifestRow(relPath, rec)); err != nil {
+			return restoredCount, fmt.Errorf("chyba zápisu manifestu: %w", err)
 		}
 	}
 
-	return restoredCount, nil
+	manifest.Flush()
+	return restoredCount, manifest.Error()
+}
+
+// restoredPath vrací relativní cestu obnoveného souboru; obsahuje fileID, takže je unikátní
+func restoredPath(layout string, rec format.LogRecord) string {
+	name := filepath.Base(rec.Name) // ochrana proti "../" v názvu
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		name = "file"
+	}
+	if layout == layoutDirs {
+		return filepath.Join(rec.ID, name)
+	}
+	return rec.ID + "_" + name
 }
 
-func extractFile(dstDir, filename string, loc BlobLocation, zstdDecoder *zstd.Decoder) error {
+// manifestRow vrací řádek manifest.csv pro obnovený soubor
+func manifestRow(relPath string, rec format.LogRecord) []string {
+	oldID := ""
+	if rec.OldCumulusID != nil {
+		oldID = strconv.FormatInt(*rec.OldCumulusID, 10)
+	}
+	expiresAt := ""
+	if rec.ExpiresAt != nil {
+		expiresAt = rec.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	tags := strings.Join(storage.TagsFromJSON(rec.Tags), ",")
+	return []string{
+		filepath.ToSlash(relPath),
+		rec.ID,
+		strconv.FormatInt(rec.BlobID, 10),
+		oldID,
+		rec.Name,
+		tags,
+		rec.CreatedAt.UTC().Format(time.RFC3339),
+		expiresAt,
+	}
+}
+
+func extractFile(outPath string, loc BlobLocation, zstdDecoder *zstd.Decoder) error {
 	// Otevřít volume
 	vol, err := os.Open(loc.VolumePath)
 	if err != nil {
@@ -285,8 +304,6 @@ func extractFile(dstDir, filename string, loc BlobLocation, zstdDecoder *zstd.De
 	limitReader := io.LimitReader(vol, loc.SizeCompressed)
 
 	// Připravit výstupní soubor
-	outPath := filepath.Join(dstDir, filename)
-
 	// Zajistit existenci složky
 	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
 		return err
@@ -300,16 +317,16 @@ func extractFile(dstDir, filename string, loc BlobLocation, zstdDecoder *zstd.De
 
 	// Dekomprese
 	switch loc.CompAlg {
-	case 0: // None
+	case format.CompNone:
 		_, err = io.Copy(outFile, limitReader)
-	case 1: // Gzip
+	case format.CompGzip:
 		gz, err := gzip.NewReader(limitReader)
 		if err != nil {
 			return err
 		}
 		defer gz.Close()
 		_, err = io.Copy(outFile, gz)
-	case 2: // Zstd
+	case format.CompZstd:
 		if err := zstdDecoder.Reset(limitReader); err != nil {
 			return err
 		}
@@ -247,8 +247,21 @@ func (m *migrator) migrateFileTypes() (int64, error) {
 }
 
 func (m *migrator) migrateBlobs() (int64, error) {
+	// Databáze starší než rozměry obrázků je nemají (migraci dělá až server)
+	imageCols := "NULL, NULL, NULL"
+	if ok, err := sqliteHasColumn(m.src, "blobs", "image_width"); err != nil {
+		return 0, err
+	} else if ok {
+		imageCols = "image_width, image_height, image_orientation"
+	}
+	placeholderCols := "NULL, NULL"
+	if ok, err := sqliteHasColumn(m.src, "blobs", "image_blurhash"); err != nil {
+		return 0, err
+	} else if ok {
+		placeholderCols = "image_blurhash, image_color"
+	}
 	rows, err := m.src.Query(`
-		SELECT id, hash, volume_id, blob_offset, size_raw, size_compressed, compression_alg, file_type_id
+		SELECT id, hash, volume_id, blob_offset, size_raw, size_compressed, compression_alg, file_type_id, ` + imageCols + `, ` + placeholderCols + `
 		FROM blobs
 		ORDER BY id`)
 	if err != nil {
@@ -257,23 +270,38 @@ func (m *migrator) migrateBlobs() (int64, error) {
 	defer rows.Close()
 
 	insertSQL := `
-		INSERT INTO blobs (id, hash, volume_id, blob_offset, size_raw, size_compressed, compression_alg, file_type_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+		INSERT INTO blobs (id, hash, volume_id, blob_offset, size_raw, size_compressed, compression_alg, file_type_id, image_width, image_height, image_orientation, image_blurhash, image_color)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
 	return m.copyRows("blobs", rows, insertSQL, func(stmt *sql.Stmt) error {
 		var id int64
 		var hash, compressionAlg sql.NullString
 		var volumeID, blobOffset, sizeRaw, sizeCompressed, fileTypeID sql.NullInt64
-		if err := rows.Scan(&id, &hash, &volumeID, &blobOffset, &sizeRaw, &sizeCompressed, &compressionAlg, &fileTypeID); err != nil {
+		var imageWidth, imageHeight, imageOrientation sql.NullInt64
+		var imageBlurhash, imageColor sql.NullString
+		if err := rows.Scan(&id, &hash, &volumeID, &blobOffset, &sizeRaw, &sizeCompressed, &compressionAlg, &fileTypeID, &imageWidth, &imageHeight, &imageOrientation, &imageBlurhash, &imageColor); err != nil {
 			return err
 		}
-		_, err := stmt.Exec(id, hash, volumeID, blobOffset, sizeRaw, sizeCompressed, compressionAlg, fileTypeID)
+		_, err := stmt.Exec(id, hash, volumeID, blobOffset, sizeRaw, sizeCompressed, compressionAlg, fileTypeID, imageWidth, imageHeight, imageOrientation, imageBlurhash, imageColor)
 		return err
 	})
 }
 
 func (m *migrator) migrateFiles() (int64, error) {
+	// Databáze starší než sloupce disposition a pinned_at je nemají (migraci dělá až server)
+	dispositionCol := "NULL"
+	if ok, err := sqliteHasColumn(m.src, "files", "disposition"); err != nil {
+		return 0, err
+	} else if ok {
+		dispositionCol = "disposition"
+	}
+	pinnedAtCol := "NULL"
+	if ok, err := sqliteHasColumn(m.src, "files", "pinned_at"); err != nil {
+		return 0, err
+	} else if ok {
+		pinnedAtCol = "pinned_at"
+	}
 	rows, err := m.src.Query(`
-		SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags
+		SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags, ` + dispositionCol + `, ` + pinnedAtCol + `
 		FROM files
 		ORDER BY id`)
 	if err != nil {
@@ -282,15 +310,15 @@ func (m *migrator) migrateFiles() (int64, error) {
 	defer rows.Close()
 
 	insertSQL := `
-		INSERT INTO files (id, name, blob_id, old_cumulus_id, expires_at, created_at, tags)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		INSERT INTO files (id, name, blob_id, old_cumulus_id, expires_at, created_at, tags, disposition, pinned_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 	return m.copyRows("files", rows, insertSQL, func(stmt *sql.Stmt) error {
 		var id string
-		var name, tags sql.NullString
+		var name, tags, disposition sql.NullString
 		var blobID, oldCumulusID sql.NullInt64
-		var expiresAtRaw, createdAtRaw any
+		var expiresAtRaw, createdAtRaw, pinnedAtRaw any
 
-		if err := rows.Scan(&id, &name, &blobID, &oldCumulusID, &expiresAtRaw, &createdAtRaw, &tags); err != nil {
+		if err := rows.Scan(&id, &name, &blobID, &oldCumulusID, &expiresAtRaw, &createdAtRaw, &tags, &disposition, &pinnedAtRaw); err != nil {
 			return err
 		}
 
@@ -302,12 +330,23 @@ func (m *migrator) migrateFiles() (int64, error) {
 		if err != nil {
 			return fmt.Errorf("created_at for file %s: %w", id, err)
 		}
+		pinnedAt, err := normalizeTimeValue(pinnedAtRaw, true)
+		if err != nil {
+			return fmt.Errorf("pinned_at for file %s: %w", id, err)
+		}
 
-		_, err = stmt.Exec(id, name, blobID, oldCumulusID, expiresAt, createdAt, tags)
+		_, err = stmt.Exec(id, name, blobID, oldCumulusID, expiresAt, createdAt, tags, disposition, pinnedAt)
 		return err
 	})
 }
 
+// sqliteHasColumn reports whether a table of the source database has the column
+func sqliteHasColumn(db *sql.DB, table, column string) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	return n > 0, err
+}
+
 func (m *migrator) migrateVolumes() (int64, error) {
 	rows, err := m.src.Query(`SELECT id, size_total, size_deleted FROM volumes ORDER BY id`)
 	if err != nil {
@@ -4,15 +4,20 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/pmalasek/cumulus3/docs"
 	"github.com/pmalasek/cumulus3/src/internal/api"
+	"github.com/pmalasek/cumulus3/src/internal/images"
 	"github.com/pmalasek/cumulus3/src/internal/service"
 	"github.com/pmalasek/cumulus3/src/internal/storage"
 	"github.com/pmalasek/cumulus3/src/internal/utils"
@@ -39,6 +44,8 @@ import (
 
 // @BasePath /
 
+// @securityDefinitions.basic BasicAuth
+
 // printStartupConfiguration prints all configuration parameters at startup
 func printStartupConfiguration() {
 	utils.Info("CONFIG", "=== Startup Configuration ===")
@@ -50,12 +57,26 @@ func printStartupConfiguration() {
 			strings.Contains(lowerKey, "passwd") ||
 			strings.Contains(lowerKey, "secret") ||
 			strings.Contains(lowerKey, "token") ||
-			strings.Contains(lowerKey, "key") && !strings.Contains(lowerKey, "key_path") {
+			strings.Contains(lowerKey, "key") && !strings.Contains(lowerKey, "key_path") && !strings.HasPrefix(lowerKey, "key_") {
 			if value != "" {
 				return "********"
 			}
 			return ""
 		}
+		if strings.HasSuffix(key, "_URL") {
+			if u, err := url.Parse(value); err == nil {
+				return u.Redacted()
+			}
+		}
+		if key == "CLUSTER_NODES" {
+			if nodes, err := api.ParseClusterNodes(value); err == nil {
+				parts := make([]string, len(nodes))
+				for i, n := range nodes {
+					parts[i] = n.Name + "=" + n.URL.Redacted()
+				}
+				return strings.Join(parts, ",")
+			}
+		}
 		return value
 	}
 
@@ -65,17 +86,105 @@ func printStartupConfiguration() {
 		"DB_SQLITE_PATH",
 		"PG_DATABASE_URL",
 		"DATA_DIR",
+		"READ_ONLY",
 		"DATA_FILE_SIZE",
+		"VOLUME_PREALLOCATE",
 		"MAX_UPLOAD_FILE_SIZE",
 		"SERVER_PORT",
 		"SERVER_ADDRESS",
 		"USE_COMPRESS",
 		"MINIMAL_COMPRESSION",
+		"COMPRESSION_SAMPLE_SIZE",
 		"SWAGGER_HOST",
 		"LOG_LEVEL",
 		"CLEANUP_INTERVAL",
 		"PENDING_BLOB_CLEANUP_INTERVAL",
 		"PENDING_BLOB_MAX_AGE",
+		"DOWNLOAD_ACCEL_MODE",
+		"DOWNLOAD_ACCEL_PREFIX",
+		"DOWNLOAD_ACCEL_MIN_SIZE",
+		"BATCH_UPLOAD_CONCURRENCY",
+		"DOWNLOAD_CACHE_CONTROL",
+		"IMAGE_SIGNING_KEY",
+		"DOWNLOAD_SIGNING_KEY",
+		"PRESIGNED_URL_MAX_TTL",
+		"PDF_RENDER_TIMEOUT",
+		"PDF_RENDER_MAX_MEMORY",
+		"IMAGE_MAX_INPUT_SIZE",
+		"IMAGE_MAX_PIXELS",
+		"IMAGE_PROCESS_TIMEOUT",
+		"IMAGE_PLACEHOLDERS",
+		"SLO_WINDOW",
+		"SLO_LATENCY_P99",
+		"SLO_ERROR_RATE",
+		"STATS_HISTORY_INTERVAL",
+		"FORECAST_WARNING_DAYS",
+		"SLOW_QUERY_THRESHOLD",
+		"SLOW_QUERY_LOG_SIZE",
+		"ROUTE_MIDDLEWARE",
+		"CORS_ALLOWED_ORIGINS",
+		"API_KEYS_OPTIONAL",
+		"RATE_LIMIT",
+		"RATE_LIMIT_BURST",
+		"RATE_LIMIT_TRUST_PROXY",
+		"KEY_RATE_LIMIT",
+		"KEY_RATE_LIMIT_BURST",
+		"KEY_RATE_LIMITS",
+		"KEY_MAX_CONCURRENT_UPLOADS",
+		"ADMISSION_MAX_INFLIGHT",
+		"ADMISSION_MAX_TEMP_USAGE",
+		"ADMISSION_MAX_DB_WAIT",
+		"ADMISSION_PRIORITIES",
+		"ADMISSION_RETRY_AFTER",
+		"JWT_SECRET",
+		"JWT_PUBLIC_KEY_FILE",
+		"JWT_ISSUER",
+		"JWT_AUDIENCE",
+		"EXTENDED_INFO_MAX_SIZE",
+		"READ_FALLBACK_DIR",
+		"READ_FALLBACK_URL",
+		"READ_FALLBACK_TIMEOUT",
+		"UPLOAD_HOOK_URL",
+		"UPLOAD_HOOK_TIMEOUT",
+		"UPLOAD_HOOK_FAIL_OPEN",
+		"NODE_ID",
+		"CLUSTER_NODES",
+		"CLUSTER_MODE",
+		"CLUSTER_TIMEOUT",
+		"CLUSTER_HEARTBEAT_INTERVAL",
+		"EXPIRED_ACCESS",
+		"EXPIRED_ACCESS_GRACE",
+		"TEMP_FILE_VALIDITY",
+		"UPLOAD_SESSION_TTL",
+		"VALIDITY_MIN",
+		"VALIDITY_MAX",
+		"VALIDITY_UNITS",
+		"USAGE_FLUSH_INTERVAL",
+		"VOLUME_SIZE_FLUSH_INTERVAL",
+		"PPROF_ADDR",
+		"STARTUP_LOG_REPLAY",
+		"STARTUP_LOG_REPLAY_MARGIN",
+		"CONSISTENCY_REPORT_TIME",
+		"CONSISTENCY_REPORT_WEBHOOK",
+		"REPLICA_VERIFY_INTERVAL",
+		"REPLICA_VERIFY_SAMPLE",
+		"REPLICA_VERIFY_MIN_AGE",
+		"REPLICA_VERIFY_MAX_COUNT_DIVERGENCE",
+		"REPLICA_VERIFY_MAX_SAMPLE_DIVERGENCE",
+		"REPLICA_VERIFY_WEBHOOK",
+		"BACKUP_S3_BUCKET",
+		"BACKUP_S3_ENDPOINT",
+		"BACKUP_S3_REGION",
+		"BACKUP_S3_ACCESS_KEY",
+		"BACKUP_S3_SECRET_KEY",
+		"BACKUP_S3_PREFIX",
+		"BACKUP_S3_INTERVAL",
+		"BACKUP_S3_RETENTION",
+		"PURGE_AUDIT_POLICY",
+		"PURGE_REPORT_SIGNING_KEY",
+		"FILE_DETECTION_FALLBACK",
+		"FILE_DETECTORS_CONFIG",
+		"FILE_DETECTION_SAMPLE_SIZE",
 	}
 
 	for _, param := range configParams {
@@ -110,7 +219,13 @@ func main() {
 		dbType = "sqlite" // Default to SQLite for backward compatibility
 	}
 
-	var dsn string
+	// Read-only replika: snapshot DB a kopie volumů, obsluhuje jen čtení (zápisy 405, bez úklidů)
+	readOnly, _ := strconv.ParseBool(os.Getenv("READ_ONLY"))
+	if readOnly {
+		utils.Info("STARTUP", "Read-only replica mode: mutating endpoints return 405, background writers are disabled")
+	}
+
+	var dsn, sqliteDir string
 	switch dbType {
 	case "sqlite":
 		dbPath := os.Getenv("DB_SQLITE_PATH")
@@ -119,10 +234,18 @@ func main() {
 		}
 		// Create database directory
 		dbDir := filepath.Dir(dbPath)
+		sqliteDir = dbDir
 		if err := os.MkdirAll(dbDir, 0755); err != nil {
 			panic("Nelze vytvořit adresář pro DB: " + err.Error())
 		}
 		dsn = fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_sync=NORMAL", dbPath)
+		if readOnly {
+			// Snapshot se nemění ani režimem žurnálu; _query_only odmítne každý zápis
+			if _, err := os.Stat(dbPath); err != nil {
+				panic("READ_ONLY: databáze nenalezena: " + err.Error())
+			}
+			dsn = fmt.Sprintf("file:%s?_busy_timeout=5000&_query_only=1", dbPath)
+		}
 		utils.Info("DATABASE", "Using SQLite database: %s", dbPath)
 
 	case "postgresql":
@@ -163,19 +286,111 @@ func main() {
 	}
 
 	// Start Metadata DB
-	metaStore, err := storage.NewMetadataSQL(dbType, dsn)
+	openMetadata := storage.NewMetadataSQL
+	if readOnly {
+		openMetadata = storage.NewMetadataSQLReadOnly
+	}
+	metaStore, err := openMetadata(dbType, dsn)
 	if err != nil {
 		panic("Nelze otevřít DB: " + err.Error())
 	}
 	// Důležité: Zavřít DB při ukončení programu
 	defer metaStore.Close()
+	metaStore.SetTxObserver(api.RecordDBTx)
+
+	// Log pomalých dotazů do metadat pro /system/slow-queries (SLOW_QUERY_THRESHOLD=0 vypne)
+	slowQueryThreshold := 200 * time.Millisecond
+	if val := os.Getenv("SLOW_QUERY_THRESHOLD"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			slowQueryThreshold = d
+		} else {
+			utils.Warn("CONFIG", "Invalid SLOW_QUERY_THRESHOLD format '%s', using default 200ms", val)
+		}
+	}
+	slowQueryLogSize := 100
+	if val := os.Getenv("SLOW_QUERY_LOG_SIZE"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			slowQueryLogSize = n
+		} else {
+			utils.Warn("CONFIG", "Invalid SLOW_QUERY_LOG_SIZE '%s', using default 100", val)
+		}
+	}
+	var slowQueries *storage.SlowQueryLog
+	if slowQueryThreshold > 0 {
+		slowQueries = storage.NewSlowQueryLog(slowQueryThreshold, slowQueryLogSize)
+		metaStore.SetSlowQueryLog(slowQueries)
+	}
 
 	// Inicializace File Storage
 	fileStore := storage.NewStore(dataDir, maxDataFileSize)
+	fileStore.Preallocate = os.Getenv("VOLUME_PREALLOCATE") == "true"
+	api.RegisterVolumeLockMetrics(fileStore.VolumeLockStats)
+	if legacy, err := fileStore.LegacyVolumeIDs(); err == nil && len(legacy) > 0 {
+		utils.Warn("STORAGE", "%d volume(s) still use the legacy volume_N.dat name %v, run 'compact-tool volumes upgrade-all'", len(legacy), legacy)
+	}
 
 	// Inicializace Metadata Loggeru (pro disaster recovery)
 	metaLogger := storage.NewMetadataLogger(dataDir)
 
+	// Dohnání souborů z files_metadata.bin, které v DB chybí (např. DB obnovená ze zálohy)
+	if os.Getenv("STARTUP_LOG_REPLAY") == "true" && !readOnly {
+		replayMargin := 5 * time.Minute
+		if val := os.Getenv("STARTUP_LOG_REPLAY_MARGIN"); val != "" {
+			if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+				replayMargin = d
+			} else {
+				utils.Warn("CONFIG", "Invalid STARTUP_LOG_REPLAY_MARGIN '%s', using default %v", val, replayMargin)
+			}
+		}
+		utils.Info("STARTUP", "Replaying metadata log %s (margin %v)", metaLogger.LogPath, replayMargin)
+		res, err := storage.ReplayMetadataLog(metaLogger.LogPath, metaStore, replayMargin)
+		if err != nil {
+			utils.Error("STARTUP", "Metadata log replay failed: %v", err)
+		} else {
+			utils.Info("STARTUP", "Metadata log replay: since=%v, scanned=%d, candidates=%d, inserted=%d, existing=%d, expired=%d, missing_blob=%d, failed=%d, corrupt=%d",
+				res.Since, res.Scanned, res.Candidates, res.Inserted, res.Existing, res.Expired, res.MissingBlob, res.Failed, res.Corrupt)
+			if res.MissingBlob > 0 {
+				utils.Warn("STARTUP", "%d logged files reference blobs unknown to the database; run rebuild-db to recover them", res.MissingBlob)
+			}
+		}
+	}
+
+	if !readOnly {
+		// Volumes whose .dat file disappeared must not be chosen for writes
+		if missing, err := fileStore.SyncVolumeStates(metaStore); err != nil {
+			utils.Error("STARTUP", "Failed to sync volume states: %v", err)
+		} else if len(missing) > 0 {
+			utils.Warn("STARTUP", "Volume files missing, volumes marked as missing: %v", missing)
+		}
+		// Zápisy jdou na append_offset z DB; po pádu může být soubor delší než záznam v DB
+		if err := fileStore.SyncAppendOffsets(metaStore); err != nil {
+			utils.Error("STARTUP", "Failed to sync volume append offsets: %v", err)
+		}
+	}
+
+	// Velikosti volume se při zápisu blobu jen sčítají v paměti a do DB jdou dávkově
+	// (méně zápisů do SQLite v cestě uploadu); po pádu je obnoví SyncAppendOffsets
+	volumeFlushInterval := time.Second
+	if val := os.Getenv("VOLUME_SIZE_FLUSH_INTERVAL"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			volumeFlushInterval = d
+		} else {
+			utils.Warn("CONFIG", "Invalid VOLUME_SIZE_FLUSH_INTERVAL format '%s', using default 1s", val)
+		}
+	}
+	if volumeFlushInterval > 0 && !readOnly {
+		metaStore.EnableVolumeAppendBatching()
+		go func() {
+			ticker := time.NewTicker(volumeFlushInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := metaStore.FlushVolumeAppends(); err != nil {
+					utils.Error("STORAGE", "Failed to flush volume sizes: %v", err)
+				}
+			}
+		}()
+	}
+
 	// Start metrics updater
 	go func() {
 		ticker := time.NewTicker(15 * time.Second)
@@ -190,6 +405,19 @@ func main() {
 		}
 	}()
 
+	// Per-key usage counters are kept in memory and persisted periodically
+	usageFlushInterval := time.Minute
+	if val := os.Getenv("USAGE_FLUSH_INTERVAL"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			usageFlushInterval = d
+		} else {
+			utils.Warn("CONFIG", "Invalid USAGE_FLUSH_INTERVAL format '%s', using default 1m", val)
+		}
+	}
+	if !readOnly {
+		api.StartKeyUsageFlusher(metaStore, usageFlushInterval)
+	}
+
 	// Start expired temporary files cleanup
 	cleanupIntervalStr := os.Getenv("CLEANUP_INTERVAL")
 	if cleanupIntervalStr == "" {
@@ -222,62 +450,65 @@ func main() {
 		pendingBlobMaxAge = 30 * time.Minute
 	}
 
-	go func() {
-		// Delay first run to avoid startup overhead
-		time.Sleep(2 * time.Minute)
+	// Úklidy mažou soubory a bloby, na read-only replice je dělá primární uzel
+	if !readOnly {
+		go func() {
+			// Delay first run to avoid startup overhead
+			time.Sleep(2 * time.Minute)
 
-		ticker := time.NewTicker(pendingCleanupInterval)
-		defer ticker.Stop()
+			ticker := time.NewTicker(pendingCleanupInterval)
+			defer ticker.Stop()
 
-		utils.Info("CLEANUP", "Stale pending blob cleanup scheduled every %v (max age: %v)", pendingCleanupInterval, pendingBlobMaxAge)
+			utils.Info("CLEANUP", "Stale pending blob cleanup scheduled every %v (max age: %v)", pendingCleanupInterval, pendingBlobMaxAge)
 
-		for {
-			utils.Info("CLEANUP", "Starting cleanup of stale pending blobs")
-			deletedCount, totalStale, err := metaStore.CleanupStalePendingBlobs(pendingBlobMaxAge)
-			if err != nil {
-				utils.Error("CLEANUP", "Error cleaning up stale pending blobs: %v", err)
-			} else if totalStale == 0 {
-				utils.Info("CLEANUP", "No stale pending blobs found")
-			} else if deletedCount == totalStale {
-				utils.Info("CLEANUP", "Successfully cleaned up %d stale pending blob(s)", deletedCount)
-			} else if deletedCount > 0 {
-				utils.Warn("CLEANUP", "Cleaned up %d of %d stale pending blobs (%d failed)", deletedCount, totalStale, totalStale-deletedCount)
-			} else {
-				utils.Error("CLEANUP", "Found %d stale pending blobs but all deletions failed", totalStale)
+			for {
+				utils.Info("CLEANUP", "Starting cleanup of stale pending blobs")
+				deletedCount, totalStale, err := metaStore.CleanupStalePendingBlobs(pendingBlobMaxAge)
+				if err != nil {
+					utils.Error("CLEANUP", "Error cleaning up stale pending blobs: %v", err)
+				} else if totalStale == 0 {
+					utils.Info("CLEANUP", "No stale pending blobs found")
+				} else if deletedCount == totalStale {
+					utils.Info("CLEANUP", "Successfully cleaned up %d stale pending blob(s)", deletedCount)
+				} else if deletedCount > 0 {
+					utils.Warn("CLEANUP", "Cleaned up %d of %d stale pending blobs (%d failed)", deletedCount, totalStale, totalStale-deletedCount)
+				} else {
+					utils.Error("CLEANUP", "Found %d stale pending blobs but all deletions failed", totalStale)
+				}
+
+				<-ticker.C
 			}
+		}()
 
-			<-ticker.C
-		}
-	}()
+		go func() {
+			// Run first cleanup after 1 minute to avoid startup overhead
+			time.Sleep(1 * time.Minute)
 
-	go func() {
-		// Run first cleanup after 1 minute to avoid startup overhead
-		time.Sleep(1 * time.Minute)
+			ticker := time.NewTicker(cleanupInterval)
+			defer ticker.Stop()
 
-		ticker := time.NewTicker(cleanupInterval)
-		defer ticker.Stop()
+			utils.Info("CLEANUP", "Expired temporary files cleanup scheduled every %v", cleanupInterval)
 
-		utils.Info("CLEANUP", "Expired temporary files cleanup scheduled every %v", cleanupInterval)
+			// Run cleanup immediately on first iteration
+			for {
+				utils.Info("CLEANUP", "Starting cleanup of expired temporary files")
+				deletedCount, totalExpired, _, err := metaStore.CleanupExpiredTemporaryFiles()
+				if err != nil {
+					utils.Error("CLEANUP", "Error cleaning up expired files: %v", err)
+				} else if totalExpired == 0 {
+					utils.Info("CLEANUP", "No expired temporary files found")
+				} else if deletedCount == totalExpired {
+					utils.Info("CLEANUP", "Successfully cleaned up %d expired temporary file(s)", deletedCount)
+				} else if deletedCount > 0 {
+					utils.Warn("CLEANUP", "Cleaned up %d of %d expired temporary files (%d failed)", deletedCount, totalExpired, totalExpired-deletedCount)
+				} else {
+					utils.Error("CLEANUP", "Found %d expired files but all deletions failed", totalExpired)
+				}
 
-		// Run cleanup immediately on first iteration
-		for {
-			utils.Info("CLEANUP", "Starting cleanup of expired temporary files")
-			deletedCount, totalExpired, _, err := metaStore.CleanupExpiredTemporaryFiles()
-			if err != nil {
-				utils.Error("CLEANUP", "Error cleaning up expired files: %v", err)
-			} else if totalExpired == 0 {
-				utils.Info("CLEANUP", "No expired temporary files found")
-			} else if deletedCount == totalExpired {
-				utils.Info("CLEANUP", "Successfully cleaned up %d expired temporary file(s)", deletedCount)
-			} else if deletedCount > 0 {
-				utils.Warn("CLEANUP", "Cleaned up %d of %d expired temporary files (%d failed)", deletedCount, totalExpired, totalExpired-deletedCount)
-			} else {
-				utils.Error("CLEANUP", "Found %d expired files but all deletions failed", totalExpired)
+				<-ticker.C
 			}
-
-			<-ticker.C
-		}
-	}()
+		}()
+	}
 
 	// 4. Inicializace API serveru (teď už mu budeme posílat i metaStore!)
 	// Pozor: Zde musíme upravit strukturu Server v api/handlers.go (viz další krok)
@@ -297,11 +528,603 @@ func main() {
 		}
 	}
 
+	// Detekce typů souborů: fallback (vestavěné signatury / libmagic) a vlastní detektory
+	if err := utils.EnableFallbackDetection(os.Getenv("FILE_DETECTION_FALLBACK")); err != nil {
+		panic("Neplatná hodnota FILE_DETECTION_FALLBACK: " + err.Error())
+	}
+	if path := os.Getenv("FILE_DETECTORS_CONFIG"); path != "" {
+		if err := utils.LoadDetectorConfig(path); err != nil {
+			panic("Nelze načíst FILE_DETECTORS_CONFIG: " + err.Error())
+		}
+	}
+	api.RegisterDetectorMetrics(utils.DefaultDetectors.Stats)
+
 	fileService := service.NewFileService(fileStore, metaStore, metaLogger, compressionMode, minCompressionRatio)
+	if val := os.Getenv("EXTENDED_INFO_MAX_SIZE"); val != "" {
+		if s, err := utils.ParseBytes(val); err == nil && s >= 0 {
+			fileService.ExtendedInfoMaxSize = s
+		} else {
+			utils.Warn("CONFIG", "Invalid EXTENDED_INFO_MAX_SIZE format: %v, using default", val)
+		}
+	}
+	if val := os.Getenv("COMPRESSION_SAMPLE_SIZE"); val != "" {
+		if s, err := utils.ParseBytes(val); err == nil && s > 0 && s <= service.MaxCompressionSampleSize {
+			fileService.CompressionSampleSize = s
+		} else {
+			utils.Warn("CONFIG", "Invalid COMPRESSION_SAMPLE_SIZE: %v (max 64MB), using default 1MB", val)
+		}
+	}
+	if val := os.Getenv("FILE_DETECTION_SAMPLE_SIZE"); val != "" {
+		if s, err := utils.ParseBytes(val); err == nil && s > 0 && s <= utils.MaxDetectionSampleSize {
+			fileService.DetectionSampleSize = s
+		} else {
+			utils.Warn("CONFIG", "Invalid FILE_DETECTION_SAMPLE_SIZE: %v (max 16MB), using default 12000", val)
+		}
+	}
+	if fileService.ExtendedInfoMaxSize == 0 {
+		utils.Info("CONFIG", "Extended file info (base64 content) disabled")
+	}
+
+	// Čtení blobů ze ztracených volume souborů: kopie svazků (cold tier) nebo replika
+	readFallbackTimeout := service.DefaultReadFallbackTimeout
+	if val := os.Getenv("READ_FALLBACK_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			readFallbackTimeout = d
+		} else {
+			utils.Warn("CONFIG", "Invalid READ_FALLBACK_TIMEOUT format '%s', using default 30s", val)
+		}
+	}
+	fileService.ReadFallback = service.NewReadFallback(os.Getenv("READ_FALLBACK_DIR"), os.Getenv("READ_FALLBACK_URL"), readFallbackTimeout)
+
+	// Synchronní schválení uploadu externí službou před uložením
+	uploadHookTimeout := service.DefaultUploadHookTimeout
+	if val := os.Getenv("UPLOAD_HOOK_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			uploadHookTimeout = d
+		} else {
+			utils.Warn("CONFIG", "Invalid UPLOAD_HOOK_TIMEOUT format '%s', using default 5s", val)
+		}
+	}
+	uploadHookFailOpen, _ := strconv.ParseBool(os.Getenv("UPLOAD_HOOK_FAIL_OPEN"))
+	fileService.UploadHook = service.NewUploadHook(os.Getenv("UPLOAD_HOOK_URL"), uploadHookTimeout, uploadHookFailOpen)
+	if fileService.UploadHook != nil {
+		fileService.UploadHook.Observer = api.RecordUploadHook
+		utils.Info("CONFIG", "Upload hook enabled: timeout=%s, fail_open=%v", uploadHookTimeout, uploadHookFailOpen)
+	}
+
+	// Čtení souborů po expiraci (do úklidu je cleanup ještě nesmazal)
+	expiredAccess, err := service.ParseExpiredAccessPolicy(os.Getenv("EXPIRED_ACCESS"))
+	if err != nil {
+		panic("Neplatná hodnota EXPIRED_ACCESS: " + err.Error())
+	}
+	fileService.ExpiredAccess = expiredAccess
+	if val := os.Getenv("EXPIRED_ACCESS_GRACE"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			fileService.ExpiredGracePeriod = d
+		} else {
+			utils.Warn("CONFIG", "Invalid EXPIRED_ACCESS_GRACE format '%s', using no grace period", val)
+		}
+	}
+	fileService.ImagePlaceholders = os.Getenv("IMAGE_PLACEHOLDERS") != "false"
+
+	// Offload velkých nekomprimovaných downloadů na nginx/lighttpd
+	accelMode := api.ParseAccelMode(os.Getenv("DOWNLOAD_ACCEL_MODE"))
+	accelPrefix := os.Getenv("DOWNLOAD_ACCEL_PREFIX")
+	if accelPrefix == "" {
+		accelPrefix = "/_cumulus_volumes/"
+	}
+	var accelMinSize int64 = 1 << 20 // Default 1MB
+	if val := os.Getenv("DOWNLOAD_ACCEL_MIN_SIZE"); val != "" {
+		if s, err := utils.ParseBytes(val); err == nil {
+			accelMinSize = s
+		} else {
+			utils.Warn("CONFIG", "Invalid DOWNLOAD_ACCEL_MIN_SIZE format: %v, using default", err)
+		}
+	}
+	if accelMode != api.AccelModeOff {
+		utils.Info("CONFIG", "Download offload enabled: mode=%s, prefix=%s, min_size=%d", accelMode, accelPrefix, accelMinSize)
+	}
+
+	batchUploadConcurrency := api.DefaultBatchUploadConcurrency
+	if val := os.Getenv("BATCH_UPLOAD_CONCURRENCY"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			batchUploadConcurrency = n
+		} else {
+			utils.Warn("CONFIG", "Invalid BATCH_UPLOAD_CONCURRENCY: %v, using default %d", val, batchUploadConcurrency)
+		}
+	}
+
+	// Limity pdftoppm pro náhledy PDF
+	if val := os.Getenv("PDF_RENDER_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			images.PDFRenderTimeout = d
+		} else {
+			utils.Warn("CONFIG", "Invalid PDF_RENDER_TIMEOUT format '%s', using default 30s", val)
+		}
+	}
+	if val := os.Getenv("PDF_RENDER_MAX_MEMORY"); val != "" {
+		if s, err := utils.ParseBytes(val); err == nil && s >= 0 {
+			images.PDFRenderMaxMemory = s
+		} else {
+			utils.Warn("CONFIG", "Invalid PDF_RENDER_MAX_MEMORY format '%s', using default 512MB", val)
+		}
+	}
+
+	// Ochrana zpracování obrázků (0 = bez limitu)
+	if val := os.Getenv("IMAGE_MAX_INPUT_SIZE"); val != "" {
+		if s, err := utils.ParseBytes(val); err == nil && s >= 0 {
+			images.MaxImageInputSize = s
+		} else {
+			utils.Warn("CONFIG", "Invalid IMAGE_MAX_INPUT_SIZE format '%s', using default 50MB", val)
+		}
+	}
+	if val := os.Getenv("IMAGE_MAX_PIXELS"); val != "" {
+		if n, err := strconv.ParseInt(val, 10, 64); err == nil && n >= 0 {
+			images.MaxImagePixels = n
+		} else {
+			utils.Warn("CONFIG", "Invalid IMAGE_MAX_PIXELS '%s', using default 50000000", val)
+		}
+	}
+	if val := os.Getenv("IMAGE_PROCESS_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			images.ImageProcessTimeout = d
+		} else {
+			utils.Warn("CONFIG", "Invalid IMAGE_PROCESS_TIMEOUT format '%s', using default 30s", val)
+		}
+	}
+
+	// Okno a cíle pro /system/slo
+	sloWindow, sloLatency, sloErrorRate := api.DefaultSLOWindow, api.DefaultSLOLatencyP99, api.DefaultSLOErrorRate
+	if val := os.Getenv("SLO_WINDOW"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= time.Minute && d <= 24*time.Hour {
+			sloWindow = d
+		} else {
+			utils.Warn("CONFIG", "Invalid SLO_WINDOW '%s' (1m-24h), using default 15m", val)
+		}
+	}
+	if val := os.Getenv("SLO_LATENCY_P99"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			sloLatency = d
+		} else {
+			utils.Warn("CONFIG", "Invalid SLO_LATENCY_P99 format '%s', using default 2s", val)
+		}
+	}
+	if val := os.Getenv("SLO_ERROR_RATE"); val != "" {
+		if v, err := strconv.ParseFloat(strings.TrimSuffix(val, "%"), 64); err == nil && v >= 0 && v <= 100 {
+			sloErrorRate = v / 100
+		} else {
+			utils.Warn("CONFIG", "Invalid SLO_ERROR_RATE '%s', using default 1%%", val)
+		}
+	}
+	api.ConfigureSLO(sloWindow, sloLatency, sloErrorRate)
+
+	// Historie obsazenosti disku pro /system/forecast
+	statsInterval := time.Hour
+	if val := os.Getenv("STATS_HISTORY_INTERVAL"); val != "" && val != "off" {
+		if d, err := time.ParseDuration(val); err == nil && d >= time.Minute {
+			statsInterval = d
+		} else {
+			utils.Warn("CONFIG", "Invalid STATS_HISTORY_INTERVAL '%s' (at least 1m or off), using default 1h", val)
+		}
+	}
+	statsDirs := map[string]string{"data": dataDir}
+	if sqliteDir != "" {
+		statsDirs["database"] = sqliteDir
+	}
+	if os.Getenv("STATS_HISTORY_INTERVAL") != "off" && !readOnly {
+		api.StartDiskUsageSampler(metaStore, statsDirs, statsInterval)
+	}
+	api.RegisterDiskMetrics(statsDirs)
+	alertRules := api.AlertRulesConfig{DatabaseType: dbType}
+	for name := range statsDirs {
+		alertRules.Dirs = append(alertRules.Dirs, name)
+	}
+	slices.Sort(alertRules.Dirs)
+	forecastWarningDays := 30
+	if val := os.Getenv("FORECAST_WARNING_DAYS"); val != "" {
+		if v, err := strconv.Atoi(val); err == nil && v > 0 {
+			forecastWarningDays = v
+		} else {
+			utils.Warn("CONFIG", "Invalid FORECAST_WARNING_DAYS '%s', using default 30", val)
+		}
+	}
+
+	// Povolený rozsah platnosti uploadů (validity / expires_at)
+	validityPolicy, err := utils.NewValidityPolicy(os.Getenv("VALIDITY_MIN"), os.Getenv("VALIDITY_MAX"), os.Getenv("VALIDITY_UNITS"))
+	if err != nil {
+		panic("Neplatná hodnota VALIDITY_MIN/VALIDITY_MAX/VALIDITY_UNITS: " + err.Error())
+	}
+
+	// Výchozí platnost dočasných souborů (POST /v2/files/tmp)
+	tempFileValidity := api.DefaultTempFileValidity
+	if val := os.Getenv("TEMP_FILE_VALIDITY"); val != "" {
+		if _, err := validityPolicy.Parse(val); err == nil {
+			tempFileValidity = val
+		} else {
+			utils.Warn("CONFIG", "Invalid TEMP_FILE_VALIDITY '%s' (%v), using default %s", val, err, api.DefaultTempFileValidity)
+		}
+	}
+
+	// Co se při purge stane se záznamy smazaných souborů v metadata logu
+	purgeAuditPolicy := service.PurgeAuditRedact
+	if val := os.Getenv("PURGE_AUDIT_POLICY"); val != "" {
+		if val != service.PurgeAuditRedact && val != service.PurgeAuditRemove {
+			panic("Neplatná hodnota PURGE_AUDIT_POLICY: " + val + " (redact, remove)")
+		}
+		purgeAuditPolicy = val
+	}
+
+	// Cache-Control downloadů podle MIME typu (bez pravidel se hlavička neposílá)
+	cacheControl, err := api.ParseCacheControlPolicy(os.Getenv("DOWNLOAD_CACHE_CONTROL"))
+	if err != nil {
+		panic("Neplatná hodnota DOWNLOAD_CACHE_CONTROL: " + err.Error())
+	}
+
+	// Volitelné middlewary podle skupin cest (log, cors, ratelimit, auth, apikey, jwt, keylimit, admission)
+	routeMiddleware, err := api.ParseRouteMiddleware(os.Getenv("ROUTE_MIDDLEWARE"))
+	if err != nil {
+		panic("Neplatná hodnota ROUTE_MIDDLEWARE: " + err.Error())
+	}
+	// JWT bearer tokeny (middleware jwt): HS* se sdíleným tajemstvím a/nebo RS* s veřejným klíčem SSO
+	var jwtAuth *api.JWTAuth
+	jwtConfig := api.JWTConfig{
+		Secret:   []byte(os.Getenv("JWT_SECRET")),
+		Issuer:   os.Getenv("JWT_ISSUER"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+	}
+	if path := os.Getenv("JWT_PUBLIC_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			panic("Nelze načíst JWT_PUBLIC_KEY_FILE: " + err.Error())
+		}
+		if jwtConfig.PublicKey, err = api.ParseRSAPublicKeyPEM(data); err != nil {
+			panic("Neplatný JWT_PUBLIC_KEY_FILE: " + err.Error())
+		}
+	}
+	if len(jwtConfig.Secret) > 0 || jwtConfig.PublicKey != nil {
+		jwtAuth, _ = api.NewJWTAuth(jwtConfig)
+		utils.Info("CONFIG", "JWT bearer tokens: issuer=%q, audience=%q, hmac=%v, rsa=%v", jwtConfig.Issuer, jwtConfig.Audience, len(jwtConfig.Secret) > 0, jwtConfig.PublicKey != nil)
+	} else if routeMiddleware.Uses(api.MiddlewareJWT) {
+		panic("ROUTE_MIDDLEWARE používá jwt, ale není nastaven JWT_SECRET ani JWT_PUBLIC_KEY_FILE")
+	}
+	// Skupiny files a images vyžadují API klíč, jakmile existuje první (API_KEYS_OPTIONAL=true to vypne)
+	apiKeysOptional, _ := strconv.ParseBool(os.Getenv("API_KEYS_OPTIONAL"))
+	var corsOrigins []string
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			corsOrigins = append(corsOrigins, origin)
+		}
+	}
+	var rateLimiter *api.RateLimiter
+	if val := os.Getenv("RATE_LIMIT"); val != "" {
+		if rate, err := strconv.ParseFloat(val, 64); err == nil && rate > 0 {
+			burst := 0 // default: rate rounded up
+			if val := os.Getenv("RATE_LIMIT_BURST"); val != "" {
+				if n, err := strconv.Atoi(val); err == nil && n > 0 {
+					burst = n
+				} else {
+					utils.Warn("CONFIG", "Invalid RATE_LIMIT_BURST '%s', using RATE_LIMIT rounded up", val)
+				}
+			}
+			trustProxy, _ := strconv.ParseBool(os.Getenv("RATE_LIMIT_TRUST_PROXY"))
+			rateLimiter = api.NewRateLimiter(rate, burst, trustProxy)
+		} else {
+			utils.Warn("CONFIG", "Invalid RATE_LIMIT '%s', rate limiting disabled", val)
+		}
+	}
+	// Limity podle klienta (API klíč, klient tokenu, jinak IP): požadavky za sekundu a souběžné uploady
+	var keyRateLimit api.KeyRateLimitConfig
+	if val := os.Getenv("KEY_RATE_LIMIT"); val != "" {
+		if rate, err := strconv.ParseFloat(val, 64); err == nil && rate >= 0 {
+			keyRateLimit.Rate = rate
+		} else {
+			utils.Warn("CONFIG", "Invalid KEY_RATE_LIMIT '%s', request limit disabled", val)
+		}
+	}
+	if val := os.Getenv("KEY_RATE_LIMIT_BURST"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			keyRateLimit.Burst = n
+		} else {
+			utils.Warn("CONFIG", "Invalid KEY_RATE_LIMIT_BURST '%s', using the rate rounded up", val)
+		}
+	}
+	keyRateLimit.Rates, err = api.ParseKeyRateLimits(os.Getenv("KEY_RATE_LIMITS"))
+	if err != nil {
+		panic("Neplatná hodnota KEY_RATE_LIMITS: " + err.Error())
+	}
+	if val := os.Getenv("KEY_MAX_CONCURRENT_UPLOADS"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+			keyRateLimit.MaxUploads = n
+		} else {
+			utils.Warn("CONFIG", "Invalid KEY_MAX_CONCURRENT_UPLOADS '%s', limit disabled", val)
+		}
+	}
+	var keyRateLimiter *api.KeyRateLimiter
+	if keyRateLimit.Rate > 0 || len(keyRateLimit.Rates) > 0 || keyRateLimit.MaxUploads > 0 {
+		keyRateLimit.TrustForwarded, _ = strconv.ParseBool(os.Getenv("RATE_LIMIT_TRUST_PROXY"))
+		keyRateLimiter = api.NewKeyRateLimiter(keyRateLimit)
+	}
+	// Admission control: při přetížení odmítá nejdřív požadavky klientů s nízkou prioritou (503)
+	var admissionLimits api.AdmissionLimits
+	if val := os.Getenv("ADMISSION_MAX_INFLIGHT"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+			admissionLimits.MaxInFlight = n
+		} else {
+			utils.Warn("CONFIG", "Invalid ADMISSION_MAX_INFLIGHT '%s', limit disabled", val)
+		}
+	}
+	if val := os.Getenv("ADMISSION_MAX_TEMP_USAGE"); val != "" {
+		if pct, err := strconv.ParseFloat(strings.TrimSuffix(val, "%"), 64); err == nil && pct > 0 && pct <= 100 {
+			admissionLimits.MaxTempUsage = pct / 100
+		} else {
+			utils.Warn("CONFIG", "Invalid ADMISSION_MAX_TEMP_USAGE '%s' (percent 1-100), limit disabled", val)
+		}
+	}
+	if val := os.Getenv("ADMISSION_MAX_DB_WAIT"); val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil && f >= 0 {
+			admissionLimits.MaxDBWait = f
+		} else {
+			utils.Warn("CONFIG", "Invalid ADMISSION_MAX_DB_WAIT '%s', limit disabled", val)
+		}
+	}
+	admissionPriorities, err := api.ParseAdmissionPriorities(os.Getenv("ADMISSION_PRIORITIES"))
+	if err != nil {
+		panic("Neplatná hodnota ADMISSION_PRIORITIES: " + err.Error())
+	}
+	var admission *api.AdmissionController
+	if admissionLimits.MaxInFlight > 0 || admissionLimits.MaxTempUsage > 0 || admissionLimits.MaxDBWait > 0 {
+		retryAfter := api.DefaultAdmissionRetryAfter
+		if val := os.Getenv("ADMISSION_RETRY_AFTER"); val != "" {
+			if d, err := time.ParseDuration(val); err == nil && d > 0 {
+				retryAfter = d
+			} else {
+				utils.Warn("CONFIG", "Invalid ADMISSION_RETRY_AFTER format '%s', using default 5s", val)
+			}
+		}
+		admission = api.NewAdmissionController(admissionLimits, admissionPriorities, retryAfter, metaStore.PoolStats)
+		admission.Start(time.Second)
+	}
+
+	// Cluster: GET požadavky na soubory, které tento uzel nemá, jdou na uzel, který je má
+	clusterNodes, err := api.ParseClusterNodes(os.Getenv("CLUSTER_NODES"))
+	if err != nil {
+		panic("Neplatná hodnota CLUSTER_NODES: " + err.Error())
+	}
+	clusterMode, err := api.ParseClusterMode(os.Getenv("CLUSTER_MODE"))
+	if err != nil {
+		panic("Neplatná hodnota CLUSTER_MODE: " + err.Error())
+	}
+	clusterTimeout := api.DefaultClusterTimeout
+	if val := os.Getenv("CLUSTER_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			clusterTimeout = d
+		} else {
+			utils.Warn("CONFIG", "Invalid CLUSTER_TIMEOUT format '%s', using default 5s", val)
+		}
+	}
+	nodeID := os.Getenv("NODE_ID")
+	if nodeID == "" {
+		nodeID, _ = os.Hostname()
+	}
+	cluster := api.NewCluster(nodeID, clusterNodes, clusterMode, clusterTimeout)
+	if cluster != nil {
+		utils.Info("CONFIG", "Cluster mode: node=%s, peers=%d, mode=%s", nodeID, len(clusterNodes), clusterMode)
+		heartbeatInterval := api.DefaultClusterHeartbeatInterval
+		if val := os.Getenv("CLUSTER_HEARTBEAT_INTERVAL"); val != "" {
+			if d, err := time.ParseDuration(val); err == nil && d > 0 {
+				heartbeatInterval = d
+			} else {
+				utils.Warn("CONFIG", "Invalid CLUSTER_HEARTBEAT_INTERVAL format '%s', using default 10s", val)
+			}
+		}
+		if !readOnly {
+			cluster.StartHeartbeats(metaStore, heartbeatInterval)
+		}
+	}
+
+	for group, mws := range routeMiddleware {
+		utils.Info("CONFIG", "Route group %s: middleware %s", group, strings.Join(mws, ","))
+		if slices.Contains(mws, api.MiddlewareCORS) && len(corsOrigins) == 0 {
+			utils.Warn("CONFIG", "Route group %s has cors but CORS_ALLOWED_ORIGINS is empty, no origin is allowed", group)
+		}
+		if slices.Contains(mws, api.MiddlewareRateLimit) && rateLimiter == nil {
+			utils.Warn("CONFIG", "Route group %s has ratelimit but RATE_LIMIT is not set, requests are not limited", group)
+		}
+		if slices.Contains(mws, api.MiddlewareKeyLimit) && keyRateLimiter == nil {
+			utils.Warn("CONFIG", "Route group %s has keylimit but no KEY_RATE_LIMIT* or KEY_MAX_CONCURRENT_UPLOADS is set, requests are not limited", group)
+		}
+		if slices.Contains(mws, api.MiddlewareAdmission) && admission == nil {
+			utils.Warn("CONFIG", "Route group %s has admission but no ADMISSION_MAX_* limit is set, requests are never shed", group)
+		}
+	}
+
+	// Denní report konzistence (integrita, volumy, zombie bloby)
+	reportTime := os.Getenv("CONSISTENCY_REPORT_TIME")
+	if reportTime == "" {
+		reportTime = "03:00"
+	}
+	if reportTime != "off" && !readOnly {
+		reportCfg := api.ConsistencyReportConfig{
+			PendingMaxAge: pendingBlobMaxAge,
+			WebhookURL:    os.Getenv("CONSISTENCY_REPORT_WEBHOOK"),
+		}
+		if t, err := time.Parse("15:04", reportTime); err == nil {
+			reportCfg.Hour, reportCfg.Minute = t.Hour(), t.Minute()
+		} else {
+			utils.Warn("CONFIG", "Invalid CONSISTENCY_REPORT_TIME format '%s', using default 03:00", reportTime)
+			reportCfg.Hour = 3
+		}
+		api.StartConsistencyReporter(fileService, reportCfg)
+	}
+
+	// Ověřování repliky: počty souborů a blobů a hashe vzorku souborů proti READ_FALLBACK_URL
+	var replicaVerifier *api.ReplicaVerifier
+	if val := os.Getenv("REPLICA_VERIFY_INTERVAL"); val != "" {
+		interval, err := time.ParseDuration(val)
+		switch {
+		case err != nil || interval <= 0:
+			utils.Warn("CONFIG", "Invalid REPLICA_VERIFY_INTERVAL format '%s', replica verification disabled", val)
+		case os.Getenv("READ_FALLBACK_URL") == "":
+			utils.Warn("CONFIG", "REPLICA_VERIFY_INTERVAL is set but READ_FALLBACK_URL is not, replica verification disabled")
+		default:
+			verifyCfg := api.ReplicaVerifyConfig{
+				ReplicaURL:         os.Getenv("READ_FALLBACK_URL"),
+				Interval:           interval,
+				SampleSize:         api.DefaultReplicaVerifySample,
+				MinAge:             api.DefaultReplicaVerifyMinAge,
+				MaxCountDivergence: 0.01,
+				WebhookURL:         os.Getenv("REPLICA_VERIFY_WEBHOOK"),
+			}
+			if val := os.Getenv("REPLICA_VERIFY_SAMPLE"); val != "" {
+				if n, err := strconv.Atoi(val); err == nil && n > 0 {
+					verifyCfg.SampleSize = n
+				} else {
+					utils.Warn("CONFIG", "Invalid REPLICA_VERIFY_SAMPLE '%s', using default %d", val, api.DefaultReplicaVerifySample)
+				}
+			}
+			if val := os.Getenv("REPLICA_VERIFY_MIN_AGE"); val != "" {
+				if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+					verifyCfg.MinAge = d
+				} else {
+					utils.Warn("CONFIG", "Invalid REPLICA_VERIFY_MIN_AGE format '%s', using default 10m", val)
+				}
+			}
+			if val := os.Getenv("REPLICA_VERIFY_MAX_COUNT_DIVERGENCE"); val != "" {
+				if pct, err := strconv.ParseFloat(strings.TrimSuffix(val, "%"), 64); err == nil && pct >= 0 && pct <= 100 {
+					verifyCfg.MaxCountDivergence = pct / 100
+				} else {
+					utils.Warn("CONFIG", "Invalid REPLICA_VERIFY_MAX_COUNT_DIVERGENCE '%s' (percent 0-100), using default 1%%", val)
+				}
+			}
+			if val := os.Getenv("REPLICA_VERIFY_MAX_SAMPLE_DIVERGENCE"); val != "" {
+				if pct, err := strconv.ParseFloat(strings.TrimSuffix(val, "%"), 64); err == nil && pct >= 0 && pct <= 100 {
+					verifyCfg.MaxSampleDivergence = pct / 100
+				} else {
+					utils.Warn("CONFIG", "Invalid REPLICA_VERIFY_MAX_SAMPLE_DIVERGENCE '%s' (percent 0-100), using default 0%%", val)
+				}
+			}
+			replicaVerifier = api.NewReplicaVerifier(metaStore, verifyCfg)
+			replicaVerifier.Start()
+		}
+	}
+
+	// Zálohy metadat do S3: kopie SQLite DB a nové části metadata logu s manifestem kontrolních součtů
+	// (read-only replika nezálohuje, snapshot pochází z primárního uzlu)
+	var backupPusher *api.BackupPusher
+	if bucket := os.Getenv("BACKUP_S3_BUCKET"); bucket != "" && !readOnly {
+		region := os.Getenv("BACKUP_S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		endpoint := os.Getenv("BACKUP_S3_ENDPOINT")
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+		backupCfg := api.BackupConfig{
+			S3: &storage.S3Client{
+				Endpoint:  endpoint,
+				Bucket:    bucket,
+				Region:    region,
+				AccessKey: os.Getenv("BACKUP_S3_ACCESS_KEY"),
+				SecretKey: os.Getenv("BACKUP_S3_SECRET_KEY"),
+				Client:    &http.Client{Timeout: 30 * time.Minute},
+			},
+			Prefix:       os.Getenv("BACKUP_S3_PREFIX"),
+			Interval:     api.DefaultBackupInterval,
+			Retention:    api.DefaultBackupRetention,
+			DatabaseType: dbType,
+			TempDir:      dataDir,
+			Node:         nodeID,
+		}
+		if backupCfg.Prefix == "" {
+			backupCfg.Prefix = "cumulus3/" + nodeID
+		}
+		if val := os.Getenv("BACKUP_S3_INTERVAL"); val != "" {
+			if d, err := time.ParseDuration(val); err == nil && d >= time.Minute {
+				backupCfg.Interval = d
+			} else {
+				utils.Warn("CONFIG", "Invalid BACKUP_S3_INTERVAL '%s' (at least 1m), using default 1h", val)
+			}
+		}
+		if val := os.Getenv("BACKUP_S3_RETENTION"); val != "" {
+			if d, err := time.ParseDuration(val); err == nil && d > 0 {
+				backupCfg.Retention = d
+			} else {
+				utils.Warn("CONFIG", "Invalid BACKUP_S3_RETENTION format '%s', using default 168h", val)
+			}
+		}
+		if backupCfg.S3.AccessKey == "" || backupCfg.S3.SecretKey == "" {
+			utils.Warn("CONFIG", "BACKUP_S3_BUCKET is set but BACKUP_S3_ACCESS_KEY or BACKUP_S3_SECRET_KEY is not, metadata backup disabled")
+		} else {
+			backupPusher = api.NewBackupPusher(metaStore, metaLogger, backupCfg)
+			backupPusher.Start()
+			utils.Info("CONFIG", "Metadata backup: s3://%s/%s every %s, retention %s", bucket, backupCfg.Prefix, backupCfg.Interval, backupCfg.Retention)
+		}
+	}
+
+	// Navazující uploady /v2/uploads: části v DATA_DIR/uploads, session se drží TTL od poslední části (0 vypne)
+	var uploadSessions *api.UploadSessions
+	uploadSessionTTL := api.DefaultUploadSessionTTL
+	if val := os.Getenv("UPLOAD_SESSION_TTL"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			uploadSessionTTL = d
+		} else {
+			utils.Warn("CONFIG", "Invalid UPLOAD_SESSION_TTL format '%s', using default 24h", val)
+		}
+	}
+	if uploadSessionTTL > 0 && !readOnly {
+		var err error
+		uploadSessions, err = api.NewUploadSessions(filepath.Join(dataDir, "uploads"), uploadSessionTTL)
+		if err != nil {
+			utils.Error("CONFIG", "Failed to create upload session directory, resumable uploads disabled: %v", err)
+		} else {
+			uploadSessions.Start()
+		}
+	}
+
+	presignMaxTTL := api.DefaultPresignMaxTTL
+	if val := os.Getenv("PRESIGNED_URL_MAX_TTL"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			presignMaxTTL = d
+		} else {
+			utils.Warn("CONFIG", "Invalid PRESIGNED_URL_MAX_TTL format '%s', using default %s", val, api.DefaultPresignMaxTTL)
+		}
+	}
 
 	srv := &api.Server{
 		FileService:   fileService,
 		MaxUploadSize: maxUploadSize,
+		AccelMode:     accelMode,
+		AccelPrefix:   accelPrefix,
+		AccelMinSize:  accelMinSize,
+
+		BatchUploadConcurrency: batchUploadConcurrency,
+		DownloadCacheControl:   cacheControl,
+		ImageSigningKey:        []byte(os.Getenv("IMAGE_SIGNING_KEY")),
+		DownloadSigningKey:     []byte(os.Getenv("DOWNLOAD_SIGNING_KEY")),
+		PresignMaxTTL:          presignMaxTTL,
+		ForecastWarningDays:    forecastWarningDays,
+		ManifestSigningKey:     []byte(os.Getenv("MANIFEST_SIGNING_KEY")),
+		PurgeAuditPolicy:       purgeAuditPolicy,
+		PurgeReportSigningKey:  []byte(os.Getenv("PURGE_REPORT_SIGNING_KEY")),
+		TempFileValidity:       tempFileValidity,
+		ValidityPolicy:         validityPolicy,
+
+		RouteMiddleware:    routeMiddleware,
+		CORSAllowedOrigins: corsOrigins,
+		RateLimiter:        rateLimiter,
+		APIKeys:            api.NewAPIKeys(metaStore),
+		APIKeysOptional:    apiKeysOptional,
+		JWT:                jwtAuth,
+		KeyRateLimiter:     keyRateLimiter,
+		Admission:          admission,
+		Cluster:            cluster,
+		AlertRules:         alertRules,
+		ReplicaVerifier:    replicaVerifier,
+		Backup:             backupPusher,
+		SlowQueries:        slowQueries,
+		UploadSessions:     uploadSessions,
+		ReadOnly:           readOnly,
 	}
 
 	// Nastavení Swagger host (můžete nastavit přes SWAGGER_HOST env)
@@ -322,6 +1145,31 @@ func main() {
 	handler := srv.Routes()
 
 	serverAddr := os.Getenv("SERVER_ADDRESS") + ":" + port
+	// Profilování (pprof) jen na samostatném listeneru, typicky 127.0.0.1:6060
+	if pprofAddr := os.Getenv("PPROF_ADDR"); pprofAddr != "" {
+		go func() {
+			utils.Info("STARTUP", "pprof listening on %s/debug/pprof/", pprofAddr)
+			if err := http.ListenAndServe(pprofAddr, api.PprofHandler()); err != nil {
+				utils.Error("STARTUP", "pprof listener failed: %v", err)
+			}
+		}()
+	}
+
+	// Při ukončení zapsat, co čeká v paměti (velikosti volume, počítadla klíčů)
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		utils.Info("SHUTDOWN", "Flushing pending volume sizes and usage counters")
+		if err := metaStore.FlushVolumeAppends(); err != nil {
+			utils.Error("SHUTDOWN", "Failed to flush volume sizes: %v", err)
+		}
+		if err := api.FlushKeyUsage(metaStore); err != nil {
+			utils.Error("SHUTDOWN", "Failed to persist API key usage: %v", err)
+		}
+		os.Exit(0)
+	}()
+
 	utils.Info("STARTUP", "🚀 Server listening on %s", serverAddr)
 	http.ListenAndServe(serverAddr, handler)
 }
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+)
+
+// Supported values of Server.AccelMode
+const (
+	AccelModeOff      = "off"
+	AccelModeNginx    = "nginx"
+	AccelModeLighttpd = "lighttpd"
+)
+
+// ParseAccelMode normalizes the DOWNLOAD_ACCEL_MODE value.
+// Unknown values disable the offload.
+func ParseAccelMode(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case AccelModeNginx, "x-accel-redirect":
+		return AccelModeNginx
+	case AccelModeLighttpd, "x-sendfile", "apache":
+		return AccelModeLighttpd
+	default:
+		return AccelModeOff
+	}
+}
+
+// tryAccelRedirect hands the download of a plain (uncompressed) blob over to the
+// fronting web server. The response carries only headers; the web server reads
+// the byte range directly from the volume file.
+// A request whose If-None-Match lists the blob hash is answered with 304 directly.
+// Range requests are never offloaded: the redirect already selects the blob's byte
+// range in the volume file, so the client's Range (and If-Range) would be lost.
+// Returns false when the blob has to be served by the Go process.
+// mutable marks downloads by old Cumulus ID (see CacheControlPolicy.For).
+func (s *Server) tryAccelRedirect(w http.ResponseWriter, r *http.Request, loc *service.BlobLocation, mutable bool) bool {
+	if s.AccelMode == "" || s.AccelMode == AccelModeOff {
+		return false
+	}
+	// Rozsah v rámci blobu servíruje Go (http.ServeContent vyhodnotí i If-Range)
+	if r.Header.Get("Range") != "" {
+		return false
+	}
+	if loc.CompressionAlg != "none" && loc.CompressionAlg != "" {
+		return false
+	}
+	if loc.SizeRaw <= 0 || loc.SizeRaw < s.AccelMinSize {
+		return false
+	}
+
+	// Validace cache odpoví 304 sama, proxy nemusí soubor ani otevírat
+	if loc.Hash != "" && etagMatches(r, loc.Hash) {
+		s.setDownloadCacheControl(w, loc.MimeType, mutable)
+		w.Header().Set("ETag", `"`+loc.Hash+`"`)
+		writeNotModified(w)
+		return true
+	}
+
+	start := loc.DataOffset
+	end := loc.DataOffset + loc.SizeRaw - 1
+
+	switch s.AccelMode {
+	case AccelModeNginx:
+		prefix := s.AccelPrefix
+		if prefix == "" {
+			prefix = "/_cumulus_volumes/"
+		}
+		if !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		w.Header().Set("X-Accel-Redirect", fmt.Sprintf("%s%s?start=%d&end=%d",
+			prefix, url.PathEscape(filepath.Base(loc.VolumePath)), start, end))
+	case AccelModeLighttpd:
+		w.Header().Set("X-Sendfile2", fmt.Sprintf("%s %d-%d", (&url.URL{Path: loc.VolumePath}).EscapedPath(), start, end))
+	default:
+		return false
+	}
+
+	w.Header().Set("Content-Type", loc.MimeType)
+	w.Header().Set("Content-Disposition", contentDisposition(loc.Disposition, loc.MimeType, loc.Filename))
+	s.setDownloadCacheControl(w, loc.MimeType, mutable)
+	if loc.Hash != "" {
+		w.Header().Set("ETag", `"`+loc.Hash+`"`)
+	}
+	w.WriteHeader(http.StatusOK)
+	RecordAccelRedirect(s.AccelMode)
+	return true
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+)
+
+func TestAccelRedirectSkipsRange(t *testing.T) {
+	s := &Server{AccelMode: AccelModeNginx}
+	loc := &service.BlobLocation{
+		Filename:       "a.bin",
+		MimeType:       "application/octet-stream",
+		VolumePath:     "/data/volume_00000001.dat",
+		DataOffset:     100,
+		SizeRaw:        1000,
+		CompressionAlg: "none",
+		Hash:           "abc",
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"full download", nil, true},
+		{"range", map[string]string{"Range": "bytes=0-99"}, false},
+		{"if-range", map[string]string{"Range": "bytes=500-", "If-Range": `"abc"`}, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/v2/files/x", nil)
+		for k, v := range tt.headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		if got := s.tryAccelRedirect(w, r, loc, false); got != tt.want {
+			t.Errorf("%s: offloaded %v, want %v", tt.name, got, tt.want)
+		}
+		if redirect := w.Header().Get("X-Accel-Redirect"); (redirect != "") != tt.want {
+			t.Errorf("%s: X-Accel-Redirect %q", tt.name, redirect)
+		}
+	}
+}
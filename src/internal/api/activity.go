@@ -0,0 +1,307 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Přehled aktivity pro úvodní stránku adminu: uploady, downloady a mazání po hodinách, nejaktivnější
+// klienti a typy souborů. Počítá se z proudu požadavků v MetricsMiddleware (stejné požadavky jako
+// access log), drží se v paměti od startu serveru po dobu activityHours.
+
+const (
+	activityHours           = 7 * 24 // hourly buckets kept
+	defaultActivityHours    = 24
+	defaultActivityTopLimit = 10
+	maxActivityTopLimit     = 100
+	maxActivityKeys         = 1000 // client/type pairs per hour, further pairs are counted as "other"
+)
+
+// activityDownloadRoutes are the routes returning file content
+var activityDownloadRoutes = []string{
+	"GET /base/files/{uuid}",
+	"GET /base/files/old/{cumulus_id}",
+	"GET /v2/files/{uuid}",
+	"GET /v2/files/old/{cumulus_id}",
+	"GET /v2/files/archive.tar",
+	"GET /v2/images/{uuid}",
+	"GET /v2/images/{uuid}/{variant}",
+	"GET /v2/images/old/{cumulus_id}",
+	"GET /v2/images/old/{cumulus_id}/{variant}",
+}
+
+// activityDeleteRoutes are the routes deleting a file
+var activityDeleteRoutes = []string{
+	"DELETE /base/files/delete/{uuid}",
+	"POST /base/files/delete/{uuid}",
+}
+
+type activityKey struct {
+	client   string
+	mimeType string // "" for deletes
+}
+
+type activityCounts struct {
+	uploads       uint64
+	downloads     uint64
+	deletes       uint64
+	uploadBytes   int64
+	downloadBytes int64
+}
+
+func (c *activityCounts) add(o *activityCounts) {
+	c.uploads += o.uploads
+	c.downloads += o.downloads
+	c.deletes += o.deletes
+	c.uploadBytes += o.uploadBytes
+	c.downloadBytes += o.downloadBytes
+}
+
+type activityHour struct {
+	hour   int64
+	counts map[activityKey]*activityCounts
+}
+
+// activityTracker keeps a ring of hourly counters per client and file type
+type activityTracker struct {
+	mu    sync.Mutex
+	since time.Time
+	hours []activityHour
+}
+
+var globalActivity = newActivityTracker()
+
+func newActivityTracker() *activityTracker {
+	return &activityTracker{since: time.Now(), hours: make([]activityHour, activityHours)}
+}
+
+// record counts a finished request: the files it stored (uploads, a batch counts each file),
+// a successful download or a delete
+func (t *activityTracker) record(now time.Time, r *http.Request, client string, status int, contentType string, uploads []string, bytesIn, bytesOut int64) {
+	var counts activityCounts
+	mimeType := ""
+	switch {
+	case len(uploads) > 0:
+		// Typy jednotlivých souborů se počítají zvlášť, bajty připadnou prvnímu
+		for i, uploaded := range uploads {
+			c := activityCounts{uploads: 1}
+			if i == 0 {
+				c.uploadBytes = bytesIn
+			}
+			t.add(now, activityKey{client: client, mimeType: uploaded}, &c)
+		}
+		return
+	case (status == http.StatusOK || status == http.StatusPartialContent) && slices.Contains(activityDownloadRoutes, r.Pattern) && r.Method == http.MethodGet:
+		counts = activityCounts{downloads: 1, downloadBytes: bytesOut}
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			mimeType = mediaType
+		}
+	case status >= 200 && status < 300 && slices.Contains(activityDeleteRoutes, r.Pattern):
+		counts = activityCounts{deletes: 1}
+	default:
+		return
+	}
+	t.add(now, activityKey{client: client, mimeType: mimeType}, &counts)
+}
+
+func (t *activityTracker) add(now time.Time, key activityKey, counts *activityCounts) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hour := now.Unix() / 3600
+	h := &t.hours[hour%int64(len(t.hours))]
+	if h.hour != hour || h.counts == nil {
+		*h = activityHour{hour: hour, counts: make(map[activityKey]*activityCounts)}
+	}
+	c, ok := h.counts[key]
+	if !ok {
+		if len(h.counts) >= maxActivityKeys {
+			key = activityKey{client: "other", mimeType: "other"}
+			c, ok = h.counts[key]
+		}
+		if !ok {
+			c = &activityCounts{}
+			h.counts[key] = c
+		}
+	}
+	c.add(counts)
+}
+
+// ActivityFilter selects the requests of the activity report
+type ActivityFilter struct {
+	Hours    int    // last hours, at most activityHours
+	Client   string // exact client, "" = all
+	MimeType string // MIME type or prefix ending with "/" (e.g. "image/"), "" = all
+	Limit    int    // length of the top lists
+}
+
+func (f ActivityFilter) matches(key activityKey) bool {
+	if f.Client != "" && key.client != f.Client {
+		return false
+	}
+	if f.MimeType == "" {
+		return true
+	}
+	if strings.HasSuffix(f.MimeType, "/") {
+		return strings.HasPrefix(key.mimeType, f.MimeType)
+	}
+	return key.mimeType == f.MimeType
+}
+
+// ActivityCounts are the operations of an hour, client or file type
+type ActivityCounts struct {
+	Uploads       uint64 `json:"uploads" example:"120"`
+	Downloads     uint64 `json:"downloads" example:"3400"`
+	Deletes       uint64 `json:"deletes" example:"5"`
+	UploadBytes   int64  `json:"uploadBytes" example:"73400320"`
+	DownloadBytes int64  `json:"downloadBytes" example:"1468006400"`
+}
+
+func newActivityCounts(c *activityCounts) ActivityCounts {
+	return ActivityCounts{Uploads: c.uploads, Downloads: c.downloads, Deletes: c.deletes, UploadBytes: c.uploadBytes, DownloadBytes: c.downloadBytes}
+}
+
+// ActivityHour is the activity of one hour
+type ActivityHour struct {
+	Hour time.Time `json:"hour" example:"2026-01-12T10:00:00Z"` // start of the hour, UTC
+	ActivityCounts
+}
+
+// ActivityClient is the activity of one client (API key name, token client or anonymous)
+type ActivityClient struct {
+	Client string `json:"client" example:"gallery"`
+	ActivityCounts
+}
+
+// ActivityFileType is the activity of one MIME type (uploads and downloads)
+type ActivityFileType struct {
+	MimeType string `json:"mimeType" example:"image/jpeg"`
+	ActivityCounts
+}
+
+// ActivityReport is the body of GET /admin/api/activity
+type ActivityReport struct {
+	Since        time.Time          `json:"since"` // start of data collection (server start)
+	Hours        int                `json:"hours" example:"24"`
+	Total        ActivityCounts     `json:"total"`
+	Timeline     []ActivityHour     `json:"timeline"` // every hour of the range, oldest first
+	TopClients   []ActivityClient   `json:"topClients"`
+	TopFileTypes []ActivityFileType `json:"topFileTypes"`
+}
+
+// report aggregates the hours of the filter; top lists are ordered by the number of operations
+func (t *activityTracker) report(now time.Time, f ActivityFilter) ActivityReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	nowHour := now.Unix() / 3600
+	report := ActivityReport{Since: t.since, Hours: f.Hours, Timeline: make([]ActivityHour, 0, f.Hours)}
+	var total activityCounts
+	clients := map[string]*activityCounts{}
+	types := map[string]*activityCounts{}
+	for hour := nowHour - int64(f.Hours) + 1; hour <= nowHour; hour++ {
+		var sum activityCounts
+		h := &t.hours[hour%int64(len(t.hours))]
+		if h.hour == hour {
+			for key, c := range h.counts {
+				if !f.matches(key) {
+					continue
+				}
+				sum.add(c)
+				addActivity(clients, key.client, c)
+				if key.mimeType != "" {
+					addActivity(types, key.mimeType, c)
+				}
+			}
+		}
+		total.add(&sum)
+		report.Timeline = append(report.Timeline, ActivityHour{Hour: time.Unix(hour*3600, 0).UTC(), ActivityCounts: newActivityCounts(&sum)})
+	}
+	report.Total = newActivityCounts(&total)
+
+	report.TopClients = []ActivityClient{}
+	for _, name := range topActivity(clients, f.Limit) {
+		report.TopClients = append(report.TopClients, ActivityClient{Client: name, ActivityCounts: newActivityCounts(clients[name])})
+	}
+	report.TopFileTypes = []ActivityFileType{}
+	for _, name := range topActivity(types, f.Limit) {
+		report.TopFileTypes = append(report.TopFileTypes, ActivityFileType{MimeType: name, ActivityCounts: newActivityCounts(types[name])})
+	}
+	return report
+}
+
+func addActivity(m map[string]*activityCounts, name string, c *activityCounts) {
+	sum, ok := m[name]
+	if !ok {
+		sum = &activityCounts{}
+		m[name] = sum
+	}
+	sum.add(c)
+}
+
+// topActivity returns up to limit names with the most operations
+func topActivity(m map[string]*activityCounts, limit int) []string {
+	ops := func(c *activityCounts) uint64 { return c.uploads + c.downloads + c.deletes }
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := ops(m[names[i]]), ops(m[names[j]])
+		if a != b {
+			return a > b
+		}
+		return names[i] < names[j]
+	})
+	return names[:min(limit, len(names))]
+}
+
+// HandleAdminActivity returns the recent activity for the admin overview
+// @Summary Get recent activity
+// @Description Returns uploads, downloads and deletes per hour with their bytes, the most active clients and the most uploaded/downloaded file types, for the overview page of the admin UI. Counted from the requests the server handles (like the access log): an upload counts every stored file including dedup hits and linked conditional uploads, a download every 200/206 GET of file or image content (not cluster redirects or 304), a delete every successful delete request. The client is the API key name, the token client or "anonymous". Data is kept in memory since server start, for at most 7 days. Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Param hours query int false "Last hours (default 24, max 168)"
+// @Param client query string false "Only this client"
+// @Param type query string false "Only this MIME type, or a prefix ending with / (e.g. image/)"
+// @Param limit query int false "Length of topClients and topFileTypes (default 10, max 100)"
+// @Success 200 {object} ActivityReport
+// @Failure 400 {string} string "Invalid hours or limit"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Router /admin/api/activity [get]
+func (s *Server) HandleAdminActivity(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := ActivityFilter{
+		Hours:    defaultActivityHours,
+		Client:   query.Get("client"),
+		MimeType: strings.ToLower(query.Get("type")),
+		Limit:    defaultActivityTopLimit,
+	}
+	if val := query.Get("hours"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 || n > activityHours {
+			http.Error(w, "Invalid hours: expected 1 to 168", http.StatusBadRequest)
+			return
+		}
+		filter.Hours = n
+	}
+	if val := query.Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(n, maxActivityTopLimit)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(globalActivity.report(time.Now(), filter))
+}
@@ -23,7 +23,7 @@ func (s *Server) HandleAdmin(w http.ResponseWriter, r *http.Request) {
 
 // HandleAdminIcons serves favicon and icon files
 func (s *Server) HandleAdminIcons(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/admin/icons/")
+	path := r.PathValue("name")
 	content, err := staticFiles.ReadFile("static/icons/" + path)
 	if err != nil {
 		http.NotFound(w, r)
@@ -64,6 +64,14 @@ func AdminAuthMiddleware(username, password string, next http.Handler) http.Hand
 	})
 }
 
+// isAdminRequest reports whether the request carries valid admin Basic auth credentials.
+// Used for upload fields reserved to administration/migration (e.g. created_at).
+func isAdminRequest(r *http.Request) bool {
+	username, password := GetAdminCredentials()
+	user, pass, ok := r.BasicAuth()
+	return ok && user == username && pass == password
+}
+
 func GetAdminCredentials() (string, string) {
 	username := os.Getenv("ADMIN_USERNAME")
 	if username == "" {
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// Priorities of admission control; lower priorities are shed first
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high" // never shed
+)
+
+// admissionShedAt is the pressure from which requests of a priority are rejected; pressure 1
+// means one of the soft limits is reached, low priority traffic goes first
+var admissionShedAt = map[string]float64{PriorityLow: 0.75, PriorityNormal: 1}
+
+// DefaultAdmissionRetryAfter is the Retry-After of shed requests without ADMISSION_RETRY_AFTER
+const DefaultAdmissionRetryAfter = 5 * time.Second
+
+// AdmissionLimits are the soft limits of admission control; zero disables a limit
+type AdmissionLimits struct {
+	MaxInFlight  int     // requests in progress in route groups with the admission middleware
+	TempDir      string  // directory of upload temp files
+	MaxTempUsage float64 // used share (0-1) of the filesystem holding TempDir
+	MaxDBWait    float64 // seconds per second spent waiting for a database connection (SQLite busy)
+}
+
+// AdmissionController sheds requests by priority when the node is under pressure: too many
+// requests in progress, a full temp filesystem or a busy database. Rejected requests get 503
+// with Retry-After, so well-behaved clients back off before the node falls over.
+type AdmissionController struct {
+	limits     AdmissionLimits
+	priorities map[string]string // client (API key name, "admin", "anonymous") -> priority
+	retryAfter time.Duration
+	dbStats    func() sql.DBStats
+
+	inFlight atomic.Int64
+
+	mu         sync.Mutex
+	tempUsage  float64 // sampled by Start
+	dbWait     float64
+	lastWait   time.Duration
+	lastSample time.Time
+}
+
+// NewAdmissionController creates a controller; dbStats reports the database connection pool
+// (MetadataSQL.PoolStats)
+func NewAdmissionController(limits AdmissionLimits, priorities map[string]string, retryAfter time.Duration, dbStats func() sql.DBStats) *AdmissionController {
+	if retryAfter <= 0 {
+		retryAfter = DefaultAdmissionRetryAfter
+	}
+	if limits.TempDir == "" {
+		limits.TempDir = os.TempDir()
+	}
+	return &AdmissionController{
+		limits:     limits,
+		priorities: priorities,
+		retryAfter: retryAfter,
+		dbStats:    dbStats,
+		lastSample: time.Now(),
+	}
+}
+
+// ParseAdmissionPriorities parses "client=priority" pairs separated by commas, e.g.
+// "batch-import=low, admin=high"
+func ParseAdmissionPriorities(value string) (map[string]string, error) {
+	priorities := map[string]string{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		client, priority, ok := strings.Cut(part, "=")
+		client, priority = strings.TrimSpace(client), strings.ToLower(strings.TrimSpace(priority))
+		if !ok || client == "" {
+			return nil, fmt.Errorf("%q: expected client=priority", part)
+		}
+		switch priority {
+		case PriorityLow, PriorityNormal, PriorityHigh:
+			priorities[client] = priority
+		default:
+			return nil, fmt.Errorf("client %s: unknown priority %q (use low, normal, high)", client, priority)
+		}
+	}
+	return priorities, nil
+}
+
+// Start samples the temp filesystem and the database wait time every interval
+func (a *AdmissionController) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			a.sample(time.Now())
+		}
+	}()
+}
+
+func (a *AdmissionController) sample(now time.Time) {
+	var tempUsage float64
+	if a.limits.MaxTempUsage > 0 {
+		if total, free, err := storage.DiskUsage(a.limits.TempDir); err == nil && total > 0 {
+			tempUsage = 1 - float64(free)/float64(total)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tempUsage = tempUsage
+	if a.dbStats != nil {
+		wait := a.dbStats().WaitDuration
+		if elapsed := now.Sub(a.lastSample); elapsed > 0 {
+			a.dbWait = (wait - a.lastWait).Seconds() / elapsed.Seconds()
+		}
+		a.lastWait = wait
+	}
+	a.lastSample = now
+	pressure, _ := a.pressureLocked(a.inFlight.Load())
+	admissionPressure.Set(pressure)
+}
+
+// pressure returns the highest ratio of a measured value to its limit and which limit it is
+func (a *AdmissionController) pressure(inFlight int64) (float64, string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.pressureLocked(inFlight)
+}
+
+func (a *AdmissionController) pressureLocked(inFlight int64) (float64, string) {
+	pressure, reason := 0.0, ""
+	check := func(value, limit float64, name string) {
+		if limit > 0 && value/limit > pressure {
+			pressure, reason = value/limit, name
+		}
+	}
+	check(float64(inFlight), float64(a.limits.MaxInFlight), "inflight")
+	check(a.tempUsage, a.limits.MaxTempUsage, "temp_dir")
+	check(a.dbWait, a.limits.MaxDBWait, "db_busy")
+	return pressure, reason
+}
+
+// priority returns the priority of the request's client. Admin requests are high unless
+// ADMISSION_PRIORITIES says otherwise; unknown clients are normal.
+func (a *AdmissionController) priority(r *http.Request) string {
+	client := anonymousClient
+	if u, ok := r.Context().Value(usageCtxKey{}).(*requestUsage); ok {
+		client = u.clientName()
+	}
+	if client == anonymousClient && isAdminRequest(r) {
+		client = "admin"
+	}
+	if p, ok := a.priorities[client]; ok {
+		return p
+	}
+	if client == "admin" {
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+// Middleware rejects requests with 503 and Retry-After when the pressure reaches the shedding
+// level of their priority. A nil controller (no ADMISSION_* limit set) lets all requests through.
+func (a *AdmissionController) Middleware(group string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if a == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			priority := a.priority(r)
+			inFlight := a.inFlight.Add(1)
+			defer a.inFlight.Add(-1)
+
+			if shedAt, ok := admissionShedAt[priority]; ok {
+				if pressure, reason := a.pressure(inFlight); pressure >= shedAt {
+					RecordAdmissionRejected(group, priority, reason)
+					utils.Warn("ADMISSION", "Shed %s %s, priority=%s, reason=%s, pressure=%.2f", r.Method, r.URL.Path, priority, reason, pressure)
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(a.retryAfter.Seconds()))))
+					http.Error(w, "Service Unavailable: server is overloaded, retry later", http.StatusServiceUnavailable)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Thresholds of the generated alert rules that don't follow from the configuration
+const (
+	alertFragmentationWarning = 0.2 // deleted share of the volumes, as compact-tool compact-all --threshold 20
+	alertDiskFreeWarning      = 0.15
+	alertDiskFreeCritical     = 0.05
+	alertForecastRange        = "6h" // free space trend of the disk full forecast
+)
+
+// AlertRulesConfig is the configuration of /metrics/alerts.yaml the server doesn't hold otherwise;
+// forecast days, replica verification, upload hook and SLO come from the server itself
+type AlertRulesConfig struct {
+	DatabaseType string   // sqlite | postgresql
+	Dirs         []string // dir labels of disk_free_bytes (data, database), see RegisterDiskMetrics
+}
+
+// alertRule is one Prometheus alerting rule
+type alertRule struct {
+	Alert       string
+	Expr        string
+	For         string
+	Severity    string // warning | critical
+	Summary     string
+	Description string
+}
+
+// alertRuleGroup is a named group of alerting rules
+type alertRuleGroup struct {
+	Name  string
+	Rules []alertRule
+}
+
+// alertRuleGroups builds the recommended rules for the configuration. Thresholds taken from the
+// configuration (SLO, forecast, replica verification interval) are the configured values.
+func (s *Server) alertRuleGroups() []alertRuleGroup {
+	cfg := s.AlertRules
+	forecastDays := s.ForecastWarningDays
+	if forecastDays <= 0 {
+		forecastDays = defaultForecastWarnDays
+	}
+
+	storageRules := []alertRule{
+		{
+			Alert:       "CumulusVolumeFragmentation",
+			Expr:        fmt.Sprintf("storage_deleted_bytes_total / storage_bytes_total > %g", alertFragmentationWarning),
+			For:         "1h",
+			Severity:    "warning",
+			Summary:     "Volumes of {{ $labels.instance }} are fragmented",
+			Description: fmt.Sprintf("{{ $value | humanizePercentage }} of the volume space is deleted data (over %g%%). Compact the volumes with POST /system/compact or compact-tool volumes compact-all.", alertFragmentationWarning*100),
+		},
+	}
+	if len(cfg.Dirs) > 0 {
+		dirs := strings.Join(cfg.Dirs, "|")
+		storageRules = append(storageRules,
+			alertRule{
+				Alert:       "CumulusDiskFreeLow",
+				Expr:        fmt.Sprintf(`disk_free_bytes{dir=~"%s"} / disk_size_bytes{dir=~"%s"} < %g`, dirs, dirs, alertDiskFreeWarning),
+				For:         "10m",
+				Severity:    "warning",
+				Summary:     "Disk of the {{ $labels.dir }} directory of {{ $labels.instance }} is filling up",
+				Description: fmt.Sprintf("Only {{ $value | humanizePercentage }} of the disk is free (under %g%%). See GET /system/forecast.", alertDiskFreeWarning*100),
+			},
+			alertRule{
+				Alert:       "CumulusDiskFreeCritical",
+				Expr:        fmt.Sprintf(`disk_free_bytes{dir=~"%s"} / disk_size_bytes{dir=~"%s"} < %g`, dirs, dirs, alertDiskFreeCritical),
+				For:         "5m",
+				Severity:    "critical",
+				Summary:     "Disk of the {{ $labels.dir }} directory of {{ $labels.instance }} is almost full",
+				Description: fmt.Sprintf("Only {{ $value | humanizePercentage }} of the disk is free (under %g%%); uploads will fail when it is full.", alertDiskFreeCritical*100),
+			},
+			alertRule{
+				Alert:       "CumulusDiskFullForecast",
+				Expr:        fmt.Sprintf(`predict_linear(disk_free_bytes{dir=~"%s"}[%s], %d * 86400) < 0`, dirs, alertForecastRange, forecastDays),
+				For:         "1h",
+				Severity:    "warning",
+				Summary:     "Disk of the {{ $labels.dir }} directory of {{ $labels.instance }} will be full soon",
+				Description: fmt.Sprintf("At the growth of the last %s the disk is full within %d days (FORECAST_WARNING_DAYS).", alertForecastRange, forecastDays),
+			},
+		)
+	}
+
+	database := "PostgreSQL"
+	if cfg.DatabaseType == "" || cfg.DatabaseType == "sqlite" {
+		database = "SQLite"
+	}
+	databaseRules := []alertRule{
+		{
+			Alert:       "CumulusDatabaseErrors",
+			Expr:        `sum by (instance, op) (rate(db_tx_duration_seconds_count{result="rollback"}[5m])) > 0`,
+			For:         "10m",
+			Severity:    "warning",
+			Summary:     fmt.Sprintf("%s transactions of {{ $labels.instance }} fail", database),
+			Description: fmt.Sprintf("{{ $value | humanize }}/s %s transactions ({{ $labels.op }}) are rolled back; check the ERROR lines of the server log.", database),
+		},
+	}
+	if database == "SQLite" {
+		databaseRules = append(databaseRules, alertRule{
+			Alert:       "CumulusDatabaseSlowTransactions",
+			Expr:        `histogram_quantile(0.99, sum by (instance, le) (rate(db_tx_duration_seconds_bucket[5m]))) > 1`,
+			For:         "10m",
+			Severity:    "warning",
+			Summary:     "SQLite write transactions of {{ $labels.instance }} are slow",
+			Description: "99th percentile of write transactions is {{ $value | humanizeDuration }}; they hold the SQLite write lock and delay uploads.",
+		})
+	}
+
+	sloWindow := formatPromDuration(globalSLO.window)
+	groups := []alertRuleGroup{
+		{Name: "cumulus3-storage", Rules: storageRules},
+		{Name: "cumulus3-database", Rules: databaseRules},
+		{Name: "cumulus3-slo", Rules: []alertRule{
+			{
+				Alert:       "CumulusHighErrorRate",
+				Expr:        fmt.Sprintf(`sum by (instance) (rate(http_requests_total{status=~"5.."}[%s])) / sum by (instance) (rate(http_requests_total[%s])) > %g`, sloWindow, sloWindow, globalSLO.errorRate),
+				For:         "5m",
+				Severity:    "critical",
+				Summary:     "{{ $labels.instance }} fails requests",
+				Description: fmt.Sprintf("{{ $value | humanizePercentage }} of requests end with 5xx (SLO_ERROR_RATE %g%%). See GET /system/slo.", globalSLO.errorRate*100),
+			},
+			{
+				Alert:       "CumulusHighLatency",
+				Expr:        fmt.Sprintf(`histogram_quantile(0.99, sum by (instance, path, le) (rate(http_request_duration_seconds_bucket[%s]))) > %g`, sloWindow, globalSLO.latencyP99.Seconds()),
+				For:         "10m",
+				Severity:    "warning",
+				Summary:     "{{ $labels.path }} of {{ $labels.instance }} is slow",
+				Description: fmt.Sprintf("99th percentile latency is {{ $value | humanizeDuration }} (SLO_LATENCY_P99 %s).", globalSLO.latencyP99),
+			},
+		}},
+	}
+
+	if s.ReplicaVerifier != nil {
+		interval := s.ReplicaVerifier.cfg.Interval
+		lag := 3 * interval.Seconds()
+		groups = append(groups, alertRuleGroup{Name: "cumulus3-replication", Rules: []alertRule{
+			{
+				Alert:       "CumulusReplicaDiverged",
+				Expr:        "replication_diverged == 1",
+				For:         "5m",
+				Severity:    "warning",
+				Summary:     "Replica of {{ $labels.instance }} diverged",
+				Description: "The last replica verification exceeded a divergence threshold or failed. See GET /system/replication.",
+			},
+			{
+				Alert:       "CumulusReplicationLag",
+				Expr:        fmt.Sprintf("time() - replication_verify_last_success_timestamp_seconds > %g and time() - process_start_time_seconds > %g", lag, lag),
+				For:         "5m",
+				Severity:    "critical",
+				Summary:     "Replica of {{ $labels.instance }} was not compared for a long time",
+				Description: fmt.Sprintf("No replica verification succeeded for three REPLICA_VERIFY_INTERVAL (%s); the replica is unreachable or its state is unknown.", 3*interval),
+			},
+		}})
+	}
+
+	if s.Backup != nil {
+		interval := s.Backup.cfg.Interval
+		lag := 3 * interval.Seconds()
+		groups = append(groups, alertRuleGroup{Name: "cumulus3-backup", Rules: []alertRule{
+			{
+				Alert:       "CumulusBackupStale",
+				Expr:        fmt.Sprintf("time() - backup_last_success_timestamp_seconds > %g and time() - process_start_time_seconds > %g", lag, lag),
+				For:         "5m",
+				Severity:    "critical",
+				Summary:     "Metadata of {{ $labels.instance }} was not backed up for a long time",
+				Description: fmt.Sprintf("No metadata backup reached the S3 bucket for three BACKUP_S3_INTERVAL (%s); the metadata of the node is not recoverable if its disks are lost. See GET /system/backup.", 3*interval),
+			},
+		}})
+	}
+
+	if s.FileService.UploadHook != nil {
+		groups = append(groups, alertRuleGroup{Name: "cumulus3-upload-hook", Rules: []alertRule{
+			{
+				Alert:       "CumulusUploadHookErrors",
+				Expr:        `sum by (instance) (rate(upload_hook_duration_seconds_count{result="error"}[5m])) > 0`,
+				For:         "5m",
+				Severity:    "critical",
+				Summary:     "Upload hook of {{ $labels.instance }} is unavailable",
+				Description: "Calls of UPLOAD_HOOK_URL fail; uploads are refused with 503 (or accepted unchecked with UPLOAD_HOOK_FAIL_OPEN).",
+			},
+		}})
+	}
+	return groups
+}
+
+// formatPromDuration formats d as a Prometheus range duration ("15m", "90s")
+func formatPromDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", int64(d.Seconds()))
+}
+
+// writeAlertRules renders the groups as a Prometheus rule file
+func writeAlertRules(b *strings.Builder, groups []alertRuleGroup) {
+	b.WriteString("groups:\n")
+	for _, g := range groups {
+		fmt.Fprintf(b, "  - name: %s\n    rules:\n", g.Name)
+		for _, r := range g.Rules {
+			fmt.Fprintf(b, "      - alert: %s\n", r.Alert)
+			fmt.Fprintf(b, "        expr: %s\n", strconv.Quote(r.Expr))
+			fmt.Fprintf(b, "        for: %s\n", r.For)
+			fmt.Fprintf(b, "        labels:\n          severity: %s\n", r.Severity)
+			fmt.Fprintf(b, "        annotations:\n          summary: %s\n          description: %s\n", strconv.Quote(r.Summary), strconv.Quote(r.Description))
+		}
+	}
+}
+
+// HandleMetricsAlerts returns recommended Prometheus alerting rules
+// @Summary Prometheus alerting rules
+// @Description Returns a Prometheus rule file with recommended alerts generated from the configuration of this instance: volume fragmentation, free disk space and disk full forecast of the data directories (FORECAST_WARNING_DAYS), failed and slow database transactions (SQLite or PostgreSQL), error rate and latency over the SLO (SLO_ERROR_RATE, SLO_LATENCY_P99, SLO_WINDOW), and, when configured, replica divergence and verification lag (REPLICA_VERIFY_INTERVAL) and upload hook failures (UPLOAD_HOOK_URL). Save it as a rule file of the Prometheus scraping /metrics (rule_files) and adjust the thresholds as needed.
+// @Tags 04 - System
+// @Produce plain
+// @Success 200 {string} string "Prometheus rule file (YAML)"
+// @Router /metrics/alerts.yaml [get]
+func (s *Server) HandleMetricsAlerts(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Cumulus3 alerting rules generated from the instance configuration at %s\n", time.Now().UTC().Format(time.RFC3339))
+	writeAlertRules(&b, s.alertRuleGroups())
+	w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// API klíče: middleware "apikey" (ROUTE_MIDDLEWARE) vyžaduje hlavičku X-API-Key s platným klíčem
+// z tabulky api_keys nebo admin Basic auth. Jméno klíče je klient pro usage a admission control.
+// V DB je jen SHA-256 klíče, samotný klíč se ukáže jednou při vytvoření.
+// Skupiny files a images (/v2, /base) klíč vyžadují i bez ROUTE_MIDDLEWARE, jakmile existuje první
+// aktivní klíč – do té doby zůstává API otevřené jako dřív (vypne API_KEYS_OPTIONAL).
+
+// APIKeyHeader carries the API key of a request
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyCacheTTL is how long a valid key is trusted without asking the database: a key revoked
+// through another node sharing the database stops working here within it
+const apiKeyCacheTTL = 30 * time.Second
+
+// apiKeyDefaultGroups require an API key once one exists, unless their ROUTE_MIDDLEWARE has its
+// own access control (auth, apikey, jwt) or API_KEYS_OPTIONAL is set
+var apiKeyDefaultGroups = []string{RouteGroupFiles, RouteGroupImages}
+
+// apiKeyNamePattern: the name is a usage/metrics label, "anonymous" and "admin" are reserved
+var apiKeyNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// APIKeys authenticates requests by their X-API-Key (see the apikey middleware)
+type APIKeys struct {
+	meta *storage.MetadataSQL
+
+	mu          sync.Mutex
+	cache       map[string]cachedAPIKey // by key hash
+	active      bool                    // an unrevoked key exists (default apikey groups)
+	activeUntil time.Time               // active is trusted until then
+}
+
+type cachedAPIKey struct {
+	name  string
+	until time.Time
+}
+
+// NewAPIKeys creates the API key authenticator over the api_keys table
+func NewAPIKeys(meta *storage.MetadataSQL) *APIKeys {
+	return &APIKeys{meta: meta, cache: make(map[string]cachedAPIKey)}
+}
+
+// hashAPIKey returns the stored form of a key (SHA-256, hex)
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateAPIKey returns a new random key "ck_<43 base64url characters>" and its display prefix
+func generateAPIKey() (key, prefix string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	key = "ck_" + base64.RawURLEncoding.EncodeToString(buf)
+	return key, key[:8], nil
+}
+
+// lookup returns the name of an active key, "" for an unknown or revoked one (and always with
+// a nil APIKeys)
+func (k *APIKeys) lookup(key string) (string, error) {
+	if k == nil {
+		return "", nil
+	}
+	hash := hashAPIKey(key)
+	now := time.Now()
+	k.mu.Lock()
+	cached, ok := k.cache[hash]
+	k.mu.Unlock()
+	if ok && now.Before(cached.until) {
+		return cached.name, nil
+	}
+
+	stored, err := k.meta.GetActiveAPIKey(hash)
+	if err != nil {
+		return "", err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if stored == nil {
+		delete(k.cache, hash)
+		return "", nil
+	}
+	k.cache[hash] = cachedAPIKey{name: stored.Name, until: now.Add(apiKeyCacheTTL)}
+	return stored.Name, nil
+}
+
+// forget drops a revoked key from the cache, on this node it stops working immediately
+func (k *APIKeys) forget(name string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for hash, cached := range k.cache {
+		if cached.name == name {
+			delete(k.cache, hash)
+		}
+	}
+	k.activeUntil = time.Time{} // the last active key may be gone
+}
+
+// created records a new key, on this node the default apikey groups require keys immediately
+func (k *APIKeys) created() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.active, k.activeUntil = true, time.Now().Add(apiKeyCacheTTL)
+}
+
+// anyActive reports whether an unrevoked key exists, cached like the keys themselves
+func (k *APIKeys) anyActive() (bool, error) {
+	now := time.Now()
+	k.mu.Lock()
+	if now.Before(k.activeUntil) {
+		defer k.mu.Unlock()
+		return k.active, nil
+	}
+	k.mu.Unlock()
+
+	active, err := k.meta.HasActiveAPIKeys()
+	if err != nil {
+		return false, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.active, k.activeUntil = active, now.Add(apiKeyCacheTTL)
+	return active, nil
+}
+
+// Middleware lets through requests with an active X-API-Key, recording the key name as the client
+// of the request, and requests with admin Basic auth; others get 401
+func (k *APIKeys) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(APIKeyHeader)
+			if key == "" {
+				if isAdminRequest(r) {
+					next.ServeHTTP(w, r)
+					return
+				}
+				writeAPIKeyUnauthorized(w, "Missing API key ("+APIKeyHeader+" header)")
+				return
+			}
+			name, err := k.lookup(key)
+			if err != nil {
+				utils.Error("AUTH", "API key lookup failed: remote=%s, error=%v", r.RemoteAddr, err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if name == "" {
+				utils.Info("AUTH", "Invalid API key: path=%s, remote=%s", r.URL.Path, r.RemoteAddr)
+				writeAPIKeyUnauthorized(w, "Invalid API key")
+				return
+			}
+			setRequestClient(r, name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// DefaultMiddleware is the apikey middleware of the default groups (apiKeyDefaultGroups): requests
+// pass without a key while no key exists, afterwards it works like Middleware
+func (k *APIKeys) DefaultMiddleware() Middleware {
+	required := k.Middleware()
+	return func(next http.Handler) http.Handler {
+		withKey := required(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			active, err := k.anyActive()
+			if err != nil {
+				utils.Error("AUTH", "API key check failed: remote=%s, error=%v", r.RemoteAddr, err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if !active {
+				next.ServeHTTP(w, r)
+				return
+			}
+			withKey.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeAPIKeyUnauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `APIKey realm="Cumulus3"`)
+	http.Error(w, msg, http.StatusUnauthorized)
+}
+
+// APIKeyRequest is the body of POST /admin/api-keys
+type APIKeyRequest struct {
+	Name string `json:"name" example:"billing-app"`
+}
+
+// APIKeyCreatedResponse is a new API key; Key is not stored and cannot be shown again
+type APIKeyCreatedResponse struct {
+	storage.APIKey
+	Key string `json:"key" example:"ck_3fA9xQm1VbT0k2Lr8sYwZpN4hJc6dEgU7iOaF5tRq1M"`
+}
+
+// APIKeyListResponse is the body of GET /admin/api-keys
+type APIKeyListResponse struct {
+	Count int              `json:"count" example:"1"`
+	Keys  []storage.APIKey `json:"keys"`
+}
+
+// HandleAdminAPIKeyCreate creates an API key
+// @Summary Create an API key
+// @Description Creates a key for the X-API-Key header of the routes with the apikey middleware (ROUTE_MIDDLEWARE). Once a key exists, the files and images groups (/v2, /base) require a key even without ROUTE_MIDDLEWARE, unless API_KEYS_OPTIONAL is set or the group has auth or jwt. The key is returned only in this response, the server keeps its SHA-256. The name (letters, digits, '.', '_', '-', up to 64 characters) is the client in usage statistics and ADMISSION_PRIORITIES and stays taken after the key is revoked. Requires admin Basic auth.
+// @Tags 04 - System
+// @Accept json
+// @Produce json
+// @Param request body APIKeyRequest true "Name of the key"
+// @Success 201 {object} APIKeyCreatedResponse "Created key"
+// @Failure 400 {string} string "Invalid name"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 409 {string} string "Name already used"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/api-keys [post]
+func (s *Server) HandleAdminAPIKeyCreate(w http.ResponseWriter, r *http.Request) {
+	var req APIKeyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !apiKeyNamePattern.MatchString(req.Name) || req.Name == anonymousClient || req.Name == "admin" {
+		http.Error(w, "Invalid name: use letters, digits, '.', '_' and '-' (up to 64 characters); anonymous and admin are reserved", http.StatusBadRequest)
+		return
+	}
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		utils.Error("ADMIN", "Generating API key failed: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	stored, err := s.FileService.MetaStore.CreateAPIKey(req.Name, hashAPIKey(key), prefix)
+	if errors.Is(err, storage.ErrAPIKeyNameTaken) {
+		http.Error(w, "Name already used", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		utils.Error("ADMIN", "Creating API key failed: name=%s, error=%v", req.Name, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if s.APIKeys != nil {
+		s.APIKeys.created()
+	}
+	utils.Info("ADMIN", "API key created: id=%d, name=%s, prefix=%s, remote=%s", stored.ID, stored.Name, stored.Prefix, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(APIKeyCreatedResponse{APIKey: *stored, Key: key})
+}
+
+// HandleAdminAPIKeys lists API keys
+// @Summary List API keys
+// @Description Lists all API keys including the revoked ones, without the keys themselves. Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Success 200 {object} APIKeyListResponse "API keys"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/api-keys [get]
+func (s *Server) HandleAdminAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.FileService.MetaStore.ListAPIKeys()
+	if err != nil {
+		utils.Error("ADMIN", "Listing API keys failed: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIKeyListResponse{Count: len(keys), Keys: keys})
+}
+
+// HandleAdminAPIKeyRevoke revokes an API key
+// @Summary Revoke an API key
+// @Description Revokes the key and returns it. On this node it stops working immediately, on other nodes sharing the database within 30 seconds. The record stays, so its name remains in the usage statistics. Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Param id path int true "Key ID"
+// @Success 200 {object} storage.APIKey "Revoked key"
+// @Failure 400 {string} string "Invalid ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 404 {string} string "Key not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /admin/api-keys/{id} [delete]
+func (s *Server) HandleAdminAPIKeyRevoke(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt64(r, "id")
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	key, err := s.FileService.MetaStore.RevokeAPIKey(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.Error("ADMIN", "Revoking API key failed: id=%d, error=%v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if s.APIKeys != nil {
+		s.APIKeys.forget(key.Name)
+	}
+	utils.Info("ADMIN", "API key revoked: id=%d, name=%s, remote=%s", key.ID, key.Name, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
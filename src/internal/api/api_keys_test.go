@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+)
+
+const testAPIKey = "ck_3fA9xQm1VbT0k2Lr8sYwZpN4hJc6dEgU7iOaF5tRq1M"
+
+func newTestMetadataSQL(t *testing.T) *storage.MetadataSQL {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000", filepath.Join(t.TempDir(), "test.db"))
+	m, err := storage.NewMetadataSQL("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("NewMetadataSQL: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// serveWithKey sends a request through the middleware; key "" sends no X-API-Key. It returns the
+// status and the client recorded for usage.
+func serveWithKey(mw Middleware, key string, admin bool) (int, string) {
+	var client string
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client = r.Context().Value(usageCtxKey{}).(*requestUsage).clientName()
+	}))
+	r, _ := withRequestUsage(httptest.NewRequest(http.MethodGet, "/v2/files/list", nil))
+	if key != "" {
+		r.Header.Set(APIKeyHeader, key)
+	}
+	if admin {
+		r.SetBasicAuth("admin", "admin")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code, client
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "admin")
+	m := newTestMetadataSQL(t)
+	stored, err := m.CreateAPIKey("billing", hashAPIKey(testAPIKey), testAPIKey[:8])
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	keys := NewAPIKeys(m)
+	mw := keys.Middleware()
+
+	if code, _ := serveWithKey(mw, "", false); code != http.StatusUnauthorized {
+		t.Errorf("missing key: status %d, want 401", code)
+	}
+	// Klíč se stejným prefixem, ale jiným hashem
+	if code, _ := serveWithKey(mw, testAPIKey[:len(testAPIKey)-1]+"N", false); code != http.StatusUnauthorized {
+		t.Errorf("key with another hash: status %d, want 401", code)
+	}
+	if code, client := serveWithKey(mw, testAPIKey, false); code != http.StatusOK || client != "billing" {
+		t.Errorf("valid key: status %d, client %q, want 200 billing", code, client)
+	}
+	if code, _ := serveWithKey(mw, "", true); code != http.StatusOK {
+		t.Errorf("admin Basic auth: status %d, want 200", code)
+	}
+
+	// Revokace na jiném uzlu: klíč z cache platí dál do apiKeyCacheTTL
+	if _, err := m.RevokeAPIKey(stored.ID); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if code, _ := serveWithKey(mw, testAPIKey, false); code != http.StatusOK {
+		t.Errorf("key revoked elsewhere, within the cache TTL: status %d, want 200", code)
+	}
+	// Revokace na tomto uzlu (forget) platí hned
+	keys.forget("billing")
+	if code, _ := serveWithKey(mw, testAPIKey, false); code != http.StatusUnauthorized {
+		t.Errorf("revoked key: status %d, want 401", code)
+	}
+}
+
+func TestAPIKeyDefaultMiddleware(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	keys := NewAPIKeys(m)
+	mw := keys.DefaultMiddleware()
+
+	if code, client := serveWithKey(mw, "", false); code != http.StatusOK || client != anonymousClient {
+		t.Errorf("no key exists: status %d, client %q, want 200 anonymous", code, client)
+	}
+	stored, err := m.CreateAPIKey("billing", hashAPIKey(testAPIKey), testAPIKey[:8])
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	keys.created()
+	if code, _ := serveWithKey(mw, "", false); code != http.StatusUnauthorized {
+		t.Errorf("missing key once a key exists: status %d, want 401", code)
+	}
+	if code, client := serveWithKey(mw, testAPIKey, false); code != http.StatusOK || client != "billing" {
+		t.Errorf("valid key: status %d, client %q, want 200 billing", code, client)
+	}
+
+	if _, err := m.RevokeAPIKey(stored.ID); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	keys.forget("billing")
+	if code, _ := serveWithKey(mw, "", false); code != http.StatusOK {
+		t.Errorf("last key revoked: status %d, want 200", code)
+	}
+}
+
+func TestDefaultAPIKeyGroups(t *testing.T) {
+	rm, err := ParseRouteMiddleware("files=auth; images=ratelimit")
+	if err != nil {
+		t.Fatalf("ParseRouteMiddleware: %v", err)
+	}
+	s := &Server{RouteMiddleware: rm, APIKeys: NewAPIKeys(nil)}
+	for group, want := range map[string]bool{
+		RouteGroupFiles:  false, // vlastní auth
+		RouteGroupImages: true,
+		RouteGroupPublic: false,
+		RouteGroupSystem: false,
+	} {
+		if got := s.defaultAPIKey(group); got != want {
+			t.Errorf("defaultAPIKey(%s) = %v, want %v", group, got, want)
+		}
+	}
+	s.APIKeysOptional = true
+	if s.defaultAPIKey(RouteGroupImages) {
+		t.Error("defaultAPIKey with API_KEYS_OPTIONAL")
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// HandleV2ArchiveTar streams files with a tag as a tar archive
+// @Summary Download files by tag as TAR
+// @Description Streams all unexpired files carrying the tag as an uncompressed tar archive. Entries are named "<file ID>_<name>" and carry the file's creation time. The archive is written incrementally; if reading a file fails midway the response ends without the end-of-archive marker, so the client sees a truncated archive.
+// @Tags 02 - Files
+// @Produce application/x-tar
+// @Param tag query string true "Tag"
+// @Success 200 {file} file "Tar archive"
+// @Failure 400 {string} string "Missing tag"
+// @Router /v2/files/archive.tar [get]
+func (s *Server) HandleV2ArchiveTar(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get("tag")
+	if tag == "" {
+		http.Error(w, "Missing tag", http.StatusBadRequest)
+		return
+	}
+
+	filename := tag + ".tar"
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s", filename, url.PathEscape(filename)))
+
+	result, err := s.FileService.WriteTagArchive(w, tag)
+	if err != nil {
+		// Hlavičky už jsou odeslané, klient dostane useknutý archiv
+		utils.Error("ARCHIVE", "Tar archive aborted: tag=%s, files=%d, error=%v", tag, result.Files, err)
+		return
+	}
+	utils.Info("ARCHIVE", "Tar archive sent: tag=%s, files=%d, bytes=%d", tag, result.Files, result.Bytes)
+}
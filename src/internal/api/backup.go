@@ -0,0 +1,438 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// Statuses of a backup run
+const (
+	BackupStatusOK    = "ok"
+	BackupStatusError = "error"
+)
+
+// Defaults of the metadata backup
+const (
+	DefaultBackupInterval  = time.Hour
+	DefaultBackupRetention = 7 * 24 * time.Hour
+	backupTimeFormat       = "20060102T150405Z"
+)
+
+// BackupConfig configures the scheduled push of the metadata to S3 (BACKUP_S3_*). Objects of a
+// node live under Prefix:
+//
+//	snapshots/<time>/metadata.db     copy of the SQLite database
+//	snapshots/<time>/manifest.json   objects of the backup with sizes and SHA-256, written last
+//	log/<generation>/<offset>.seg    new bytes of the metadata log since the previous run
+//	latest.json                      copy of the newest manifest
+type BackupConfig struct {
+	S3           *storage.S3Client
+	Prefix       string        // key prefix of this node, without a trailing slash
+	Interval     time.Duration // time between runs
+	Retention    time.Duration // older snapshots are deleted, the newest one is always kept
+	DatabaseType string        // sqlite | postgresql, PostgreSQL is backed up by its own tools
+	TempDir      string        // the SQLite copy is written here before the upload
+	Node         string
+}
+
+// BackupObject is an uploaded object with its size and SHA-256
+type BackupObject struct {
+	Key    string `json:"key"`
+	Offset int64  `json:"offset,omitempty"` // log segments: position in the metadata log
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// BackupManifest lists the objects of one backup. The metadata log is the concatenation of the
+// segments, its SHA-256 is LogSHA256.
+type BackupManifest struct {
+	Node          string         `json:"node"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	DatabaseType  string         `json:"databaseType"`
+	Database      *BackupObject  `json:"database,omitempty"` // nil for PostgreSQL
+	LogGeneration string         `json:"logGeneration"`      // a rewrite of the log (purge) starts a new one
+	LogSize       int64          `json:"logSize"`
+	LogSHA256     string         `json:"logSha256"`
+	LogSegments   []BackupObject `json:"logSegments"`
+}
+
+// BackupReport is the result of one backup run
+type BackupReport struct {
+	CreatedAt        time.Time `json:"createdAt"`
+	DurationMs       int64     `json:"durationMs" example:"5200"`
+	Status           string    `json:"status" example:"ok"` // ok, error
+	Manifest         string    `json:"manifest,omitempty"`  // key of the written manifest
+	DatabaseBytes    int64     `json:"databaseBytes" example:"73400320"`
+	LogBytes         int64     `json:"logBytes" example:"184320"` // new metadata log bytes uploaded by the run
+	SnapshotsDeleted int       `json:"snapshotsDeleted" example:"1"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// BackupStatus is the response of /system/backup
+type BackupStatus struct {
+	Bucket    string        `json:"bucket" example:"cumulus-backup"`
+	Prefix    string        `json:"prefix" example:"cumulus3/node-a"`
+	Interval  string        `json:"interval" example:"1h0m0s"`
+	Retention string        `json:"retention" example:"168h0m0s"`
+	Latest    *BackupReport `json:"latest"` // null before the first run
+}
+
+// backupLogState is what of the metadata log the bucket already holds
+type backupLogState struct {
+	generation string
+	rewrites   uint64 // MetadataLogger rewrites the generation started at
+	size       int64
+	hash       hash.Hash // SHA-256 of the log up to size
+	segments   []BackupObject
+}
+
+// BackupPusher periodically pushes a copy of the SQLite database and the new part of the
+// metadata log to S3, with a manifest of SHA-256 checksums, and deletes snapshots older than
+// the retention. The metadata of a node whose disks are lost can be restored from the bucket.
+type BackupPusher struct {
+	cfg    BackupConfig
+	meta   *storage.MetadataSQL
+	logger *storage.MetadataLogger
+
+	runMu sync.Mutex      // one run at a time
+	log   *backupLogState // nil until the first run resumes a generation or starts one
+
+	mu     sync.Mutex
+	latest *BackupReport
+}
+
+// NewBackupPusher creates a pusher; Start schedules the runs
+func NewBackupPusher(meta *storage.MetadataSQL, logger *storage.MetadataLogger, cfg BackupConfig) *BackupPusher {
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	return &BackupPusher{cfg: cfg, meta: meta, logger: logger}
+}
+
+// Start runs the backup every interval; each run is visible in /system/jobs
+func (b *BackupPusher) Start() {
+	go func() {
+		ticker := time.NewTicker(b.cfg.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			b.Run()
+		}
+	}()
+}
+
+// Latest returns the report of the last run, nil before the first one
+func (b *BackupPusher) Latest() *BackupReport {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.latest
+}
+
+// Run pushes one backup and applies the retention
+func (b *BackupPusher) Run() *BackupReport {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+
+	job := globalJobManager.CreateJob("backup", nil)
+	globalJobManager.UpdateJob(job.ID, JobStatusRunning, "Pushing metadata backup to S3", nil)
+
+	start := time.Now()
+	report := &BackupReport{CreatedAt: start.UTC()}
+	if err := b.push(report, start.UTC()); err != nil {
+		report.Status = BackupStatusError
+		report.Error = err.Error()
+	} else {
+		report.Status = BackupStatusOK
+	}
+	report.DurationMs = time.Since(start).Milliseconds()
+
+	b.mu.Lock()
+	b.latest = report
+	b.mu.Unlock()
+	RecordBackup(report)
+
+	data, _ := json.Marshal(report)
+	if report.Status == BackupStatusError {
+		utils.Error("BACKUP", "Metadata backup failed: %s", report.Error)
+		globalJobManager.UpdateJob(job.ID, JobStatusFailed, string(data), fmt.Errorf("%s", report.Error))
+	} else {
+		utils.Info("BACKUP", "Metadata backup pushed: manifest=%s, database=%d B, log=+%d B, deleted snapshots=%d",
+			report.Manifest, report.DatabaseBytes, report.LogBytes, report.SnapshotsDeleted)
+		globalJobManager.UpdateJob(job.ID, JobStatusCompleted, string(data), nil)
+	}
+	return report
+}
+
+func (b *BackupPusher) push(report *BackupReport, now time.Time) error {
+	dir := b.cfg.Prefix + "/snapshots/" + now.Format(backupTimeFormat) + "/"
+	manifest := BackupManifest{Node: b.cfg.Node, CreatedAt: now, DatabaseType: b.cfg.DatabaseType}
+
+	if b.cfg.DatabaseType == "sqlite" {
+		obj, err := b.pushDatabase(dir + "metadata.db")
+		if err != nil {
+			return fmt.Errorf("database: %w", err)
+		}
+		manifest.Database = &obj
+		report.DatabaseBytes = obj.Size
+	}
+
+	uploaded, err := b.pushLog(now)
+	if err != nil {
+		return fmt.Errorf("metadata log: %w", err)
+	}
+	report.LogBytes = uploaded
+	manifest.LogGeneration = b.log.generation
+	manifest.LogSize = b.log.size
+	manifest.LogSHA256 = hex.EncodeToString(b.log.hash.Sum(nil))
+	manifest.LogSegments = append([]BackupObject{}, b.log.segments...)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	for _, key := range []string{dir + "manifest.json", b.cfg.Prefix + "/latest.json"} {
+		if err := b.cfg.S3.PutObject(key, bytes.NewReader(data), int64(len(data)), hex.EncodeToString(sum[:])); err != nil {
+			return fmt.Errorf("manifest: %w", err)
+		}
+	}
+	report.Manifest = dir + "manifest.json"
+
+	report.SnapshotsDeleted, err = b.applyRetention(now)
+	if err != nil {
+		return fmt.Errorf("retention: %w", err)
+	}
+	return nil
+}
+
+// pushDatabase uploads a consistent copy of the SQLite database
+func (b *BackupPusher) pushDatabase(key string) (BackupObject, error) {
+	tmp := filepath.Join(b.cfg.TempDir, fmt.Sprintf(".backup-%d.db", time.Now().UnixNano()))
+	defer os.Remove(tmp)
+	if err := b.meta.BackupSQLite(tmp); err != nil {
+		return BackupObject{}, err
+	}
+	f, err := os.Open(tmp)
+	if err != nil {
+		return BackupObject{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return BackupObject{}, err
+	}
+	return b.putSection(key, f, 0, info.Size(), nil)
+}
+
+// pushLog uploads the bytes appended to the metadata log since the previous run as a new segment
+// of the current generation and returns their count
+func (b *BackupPusher) pushLog(now time.Time) (int64, error) {
+	size, rewrites, err := b.logger.Snapshot()
+	if err != nil {
+		return 0, err
+	}
+	if b.log == nil {
+		b.log = b.resumeLog(size)
+	}
+	if b.log == nil || b.log.rewrites != rewrites || size < b.log.size {
+		// Log byl přepsán (purge) nebo ho bucket nezná: nová generace od začátku souboru
+		b.log = &backupLogState{generation: now.Format(backupTimeFormat), rewrites: rewrites, hash: sha256.New()}
+	}
+	if size == b.log.size {
+		return 0, nil
+	}
+
+	f, err := os.Open(b.logger.LogPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	next := cloneSHA256(b.log.hash)
+	key := fmt.Sprintf("%s/log/%s/%020d.seg", b.cfg.Prefix, b.log.generation, b.log.size)
+	obj, err := b.putSection(key, f, b.log.size, size-b.log.size, next)
+	if err != nil {
+		return 0, err
+	}
+	if _, after, err := b.logger.Snapshot(); err != nil || after != rewrites {
+		b.log = nil
+		return 0, fmt.Errorf("log rewritten during the upload, retrying with a new generation next run")
+	}
+	obj.Offset = b.log.size
+	b.log.segments = append(b.log.segments, obj)
+	b.log.size = size
+	b.log.hash = next
+	return obj.Size, nil
+}
+
+// resumeLog continues the generation of the newest manifest in the bucket when the local log
+// still starts with the same bytes (restart of the server); nil starts a new generation
+func (b *BackupPusher) resumeLog(size int64) *backupLogState {
+	manifest, err := b.readManifest(b.cfg.Prefix + "/latest.json")
+	if err != nil {
+		if !storage.IsS3NotFound(err) {
+			utils.Warn("BACKUP", "Cannot read the latest manifest, starting a new log generation: %v", err)
+		}
+		return nil
+	}
+	if manifest.LogGeneration == "" || manifest.LogSize > size {
+		return nil
+	}
+	f, err := os.Open(b.logger.LogPath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, manifest.LogSize); err != nil || hex.EncodeToString(h.Sum(nil)) != manifest.LogSHA256 {
+		return nil
+	}
+	_, rewrites, _ := b.logger.Snapshot()
+	utils.Info("BACKUP", "Resuming metadata log generation %s at %d B", manifest.LogGeneration, manifest.LogSize)
+	return &backupLogState{generation: manifest.LogGeneration, rewrites: rewrites, size: manifest.LogSize, hash: h, segments: manifest.LogSegments}
+}
+
+// applyRetention deletes snapshots older than the retention (keeping the newest one) and the log
+// generations no remaining snapshot refers to
+func (b *BackupPusher) applyRetention(now time.Time) (int, error) {
+	base := b.cfg.Prefix + "/snapshots/"
+	listing, err := b.cfg.S3.List(base, "/")
+	if err != nil {
+		return 0, err
+	}
+	snapshots := listing.Prefixes
+	sort.Strings(snapshots) // the time format sorts chronologically
+
+	keep := map[string]bool{b.log.generation: true}
+	unsure := false // a kept manifest couldn't be read, its generation is unknown
+	deleted := 0
+	for i, dir := range snapshots {
+		created, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(dir, base), "/"))
+		if err != nil {
+			continue // not written by the backup
+		}
+		if i < len(snapshots)-1 && now.Sub(created) > b.cfg.Retention {
+			if err := b.deletePrefix(dir); err != nil {
+				return deleted, err
+			}
+			deleted++
+			continue
+		}
+		manifest, err := b.readManifest(dir + "manifest.json")
+		switch {
+		case err == nil:
+			keep[manifest.LogGeneration] = true
+		case !storage.IsS3NotFound(err):
+			unsure = true
+		}
+	}
+	if unsure {
+		return deleted, nil
+	}
+
+	generations, err := b.cfg.S3.List(b.cfg.Prefix+"/log/", "/")
+	if err != nil {
+		return deleted, err
+	}
+	for _, dir := range generations.Prefixes {
+		generation := strings.TrimSuffix(strings.TrimPrefix(dir, b.cfg.Prefix+"/log/"), "/")
+		if !keep[generation] {
+			if err := b.deletePrefix(dir); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	return deleted, nil
+}
+
+func (b *BackupPusher) deletePrefix(prefix string) error {
+	listing, err := b.cfg.S3.List(prefix, "")
+	if err != nil {
+		return err
+	}
+	for _, key := range listing.Keys {
+		if err := b.cfg.S3.DeleteObject(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BackupPusher) readManifest(key string) (*BackupManifest, error) {
+	body, err := b.cfg.S3.GetObject(key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var manifest BackupManifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return &manifest, nil
+}
+
+// putSection uploads n bytes of r from off under key; the bytes are also written to also
+func (b *BackupPusher) putSection(key string, r io.ReaderAt, off, n int64, also io.Writer) (BackupObject, error) {
+	h := sha256.New()
+	w := io.Writer(h)
+	if also != nil {
+		w = io.MultiWriter(h, also)
+	}
+	if _, err := io.Copy(w, io.NewSectionReader(r, off, n)); err != nil {
+		return BackupObject{}, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if err := b.cfg.S3.PutObject(key, io.NewSectionReader(r, off, n), n, sum); err != nil {
+		return BackupObject{}, fmt.Errorf("%s: %w", key, err)
+	}
+	return BackupObject{Key: key, Size: n, SHA256: sum}, nil
+}
+
+// cloneSHA256 copies the state of a SHA-256 hash, so a failed upload doesn't advance the log hash
+func cloneSHA256(h hash.Hash) hash.Hash {
+	c := sha256.New()
+	state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err == nil {
+		err = c.(encoding.BinaryUnmarshaler).UnmarshalBinary(state)
+	}
+	if err != nil {
+		panic("sha256 state copy: " + err.Error())
+	}
+	return c
+}
+
+// HandleSystemBackup returns the configuration and the last run of the metadata backup
+// @Summary Get metadata backup status
+// @Description Returns the S3 bucket and prefix of the scheduled metadata backup (BACKUP_S3_BUCKET, every BACKUP_S3_INTERVAL) and the result of its last run. Each run uploads a copy of the SQLite database and the part of the metadata log appended since the previous run, then a manifest with the SHA-256 of every object, and deletes snapshots older than BACKUP_S3_RETENTION. Latest is null before the first run.
+// @Tags 04 - System
+// @Produce json
+// @Success 200 {object} BackupStatus
+// @Failure 404 {string} string "Metadata backup is not enabled"
+// @Router /system/backup [get]
+func (s *Server) HandleSystemBackup(w http.ResponseWriter, r *http.Request) {
+	if s.Backup == nil {
+		http.Error(w, "Metadata backup is not enabled", http.StatusNotFound)
+		return
+	}
+	cfg := s.Backup.cfg
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BackupStatus{
+		Bucket:    cfg.S3.Bucket,
+		Prefix:    cfg.Prefix,
+		Interval:  cfg.Interval.String(),
+		Retention: cfg.Retention.String(),
+		Latest:    s.Backup.Latest(),
+	})
+}
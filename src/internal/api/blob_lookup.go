@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// maxBlobLookupHashes limits one POST /v2/blobs/lookup request
+const maxBlobLookupHashes = 10000
+
+// BlobLookupRequest is the body of POST /v2/blobs/lookup
+type BlobLookupRequest struct {
+	Hashes []string `json:"hashes" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Tags   []string `json:"tags,omitempty" example:"invoices"`
+}
+
+// BlobLookupResponse lists the lookups in the order of the requested hashes
+type BlobLookupResponse struct {
+	Count   int                  `json:"count" example:"2"`
+	Stored  int                  `json:"stored" example:"1"`
+	Results []storage.BlobLookup `json:"results"`
+}
+
+// HandleV2BlobLookup tells which of the given content hashes are already stored
+// @Summary Look up stored content by hash
+// @Description Sync clients send the hashes (BLAKE2b-256, hex) of their local files and learn which are already stored, so that only the missing ones are uploaded and the rest linked with If-None-Match. stored and size reflect the content. File UUIDs are download capabilities, so fileIds (the live files referencing the content) are listed only for a scoped lookup: with tags only files carrying any of the tags, with admin Basic auth all files; otherwise fileIds is left out.
+// @Tags 02 - Files
+// @Accept json
+// @Produce json
+// @Param body body BlobLookupRequest true "Hashes to look up and tags to scope the file IDs"
+// @Success 200 {object} BlobLookupResponse "Lookups in request order"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v2/blobs/lookup [post]
+func (s *Server) HandleV2BlobLookup(w http.ResponseWriter, r *http.Request) {
+	var req BlobLookupRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Hashes) == 0 {
+		http.Error(w, "hashes must contain at least one hash", http.StatusBadRequest)
+		return
+	}
+	if len(req.Hashes) > maxBlobLookupHashes {
+		http.Error(w, fmt.Sprintf("Too many hashes (max %d)", maxBlobLookupHashes), http.StatusBadRequest)
+		return
+	}
+
+	hashes := make([]string, len(req.Hashes))
+	for i, value := range req.Hashes {
+		if hashes[i] = parseHashPrecondition(value); hashes[i] == "" {
+			http.Error(w, fmt.Sprintf("Invalid hash %q: expected 64 hex characters", value), http.StatusBadRequest)
+			return
+		}
+	}
+	tags := parseTagValues(req.Tags)
+	// ID souborů jen v rozsahu tagů volajícího (nebo adminovi), UUID souboru stačí ke stažení
+	withFiles := len(tags) > 0 || isAdminRequest(r)
+
+	results, err := s.FileService.MetaStore.LookupBlobsByHash(hashes, tags, withFiles)
+	if err != nil {
+		utils.Error("LOOKUP", "Blob lookup failed: hashes=%d, tags=%v, error=%v", len(hashes), tags, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	resp := BlobLookupResponse{Count: len(results), Results: results}
+	for _, res := range results {
+		if res.Stored {
+			resp.Stored++
+		}
+	}
+	utils.Info("LOOKUP", "Blob lookup: hashes=%d, stored=%d, tags=%v, files=%t, remote=%s", resp.Count, resp.Stored, tags, withFiles, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
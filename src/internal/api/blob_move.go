@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// VolumeDrainRequest is the body of POST /system/volumes/{id}/drain
+type VolumeDrainRequest struct {
+	ToVolumeID int64 `json:"toVolumeId" example:"7"` // 0 or missing = any open volume with space
+	Compact    bool  `json:"compact"`                // compact the drained volume (removes it when empty)
+}
+
+// HandleSystemBlobMove moves one blob to another volume
+// @Summary Move a blob to another volume
+// @Description Copies the stored blob unchanged (verified against its CRC) into an existing open volume with enough space and points the blob to the copy in one transaction; the old copy becomes deleted space. All files sharing the blob follow it. Requires admin Basic auth.
+// @Tags 04 - System
+// @Accept json
+// @Produce json
+// @Param id path int true "Blob ID"
+// @Param body body FileMoveRequest true "Target volume"
+// @Success 200 {object} service.BlobRewriteResult
+// @Failure 400 {string} string "Invalid blob ID or blob is already in the target volume"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 404 {string} string "Blob not found"
+// @Failure 409 {string} string "Volume not open, full, or blob moved concurrently"
+// @Router /system/blobs/{id}/move [post]
+func (s *Server) HandleSystemBlobMove(w http.ResponseWriter, r *http.Request) {
+	blobID, err := pathInt64(r, "id")
+	if err != nil || blobID <= 0 {
+		http.Error(w, "Invalid blob ID", http.StatusBadRequest)
+		return
+	}
+	var req FileMoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.VolumeID <= 0 {
+		http.Error(w, "volumeId is required", http.StatusBadRequest)
+		return
+	}
+	result, err := s.FileService.MoveBlob(blobID, req.VolumeID)
+	writeFileOpResult(w, "move", fmt.Sprintf("blob_id=%d", blobID), result, err)
+}
+
+// HandleSystemVolumeDrain starts moving all blobs out of a volume
+// @Summary Drain a volume
+// @Description Starts an asynchronous job that moves all committed blobs of the volume into other volumes (toVolumeId, or any open volume with space), e.g. to get data off a failing disk without compacting every volume. Each blob is copied unchanged, verified against its CRC and relocated in one transaction, so reads keep working during the drain. An open volume is sealed first and stays sealed; with compact it is compacted afterwards, which removes it when no blob is left. Failed blobs are listed in the summary (the job result in /system/jobs). Requires admin Basic auth.
+// @Tags 04 - System
+// @Accept json
+// @Produce json
+// @Param id path int true "Volume ID"
+// @Param body body VolumeDrainRequest false "Target volume and options"
+// @Success 202 {object} map[string]interface{}
+// @Failure 400 {string} string "Invalid request"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 404 {string} string "Volume or target volume not found"
+// @Router /system/volumes/{id}/drain [post]
+func (s *Server) HandleSystemVolumeDrain(w http.ResponseWriter, r *http.Request) {
+	volumeID, err := pathInt64(r, "id")
+	if err != nil || volumeID <= 0 {
+		http.Error(w, "Invalid volume ID", http.StatusBadRequest)
+		return
+	}
+	var req VolumeDrainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); (err != nil && err != io.EOF) || req.ToVolumeID < 0 {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.ToVolumeID == volumeID {
+		http.Error(w, "toVolumeId must differ from the drained volume", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.FileService.Store.VolumePath(volumeID); err != nil {
+		http.Error(w, "Volume not found", http.StatusNotFound)
+		return
+	}
+	if req.ToVolumeID > 0 {
+		if _, err := s.FileService.Store.VolumePath(req.ToVolumeID); err != nil {
+			http.Error(w, "Target volume not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	job := globalJobManager.CreateJob("drain", &volumeID)
+	go func() {
+		globalJobManager.UpdateJob(job.ID, JobStatusRunning, fmt.Sprintf("Draining volume %d", volumeID), nil)
+
+		summary, err := s.FileService.DrainVolume(volumeID, req.ToVolumeID, func(p service.VolumeDrainSummary) {
+			globalJobManager.UpdateJob(job.ID, JobStatusRunning,
+				fmt.Sprintf("Checked %d blobs, %d moved, %d failed", p.Scanned, p.Moved, p.Failed), nil)
+		})
+		data, _ := json.Marshal(summary)
+		if err != nil {
+			utils.Error("ADMIN", "Drain of volume %d failed: %v", volumeID, err)
+			globalJobManager.UpdateJob(job.ID, JobStatusFailed, string(data), err)
+			return
+		}
+		utils.Info("ADMIN", "Volume %d drained: scanned=%d, moved=%d, bytes=%d, skipped=%d, failed=%d",
+			volumeID, summary.Scanned, summary.Moved, summary.BytesMoved, summary.Skipped, summary.Failed)
+
+		if req.Compact && summary.Failed == 0 {
+			globalJobManager.UpdateJob(job.ID, JobStatusRunning, fmt.Sprintf("Compacting drained volume %d", volumeID), nil)
+			if err := s.FileService.Store.CompactVolume(volumeID, s.FileService.MetaStore); err != nil {
+				utils.Error("COMPACT", "Failed to compact drained volume %d: %v", volumeID, err)
+				globalJobManager.UpdateJob(job.ID, JobStatusFailed, string(data), err)
+				return
+			}
+		}
+		globalJobManager.UpdateJob(job.ID, JobStatusCompleted, string(data), nil)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobId":   job.ID,
+		"message": fmt.Sprintf("Drain of volume %d started", volumeID),
+	})
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// HandleSystemBlobVerify verifies one blob on disk
+// @Summary Verify a blob
+// @Description Reads the blob from its volume as a stream and checks the header, the CRC footer and (after decompression) the content hash and size. Damaged data is reported in the result with ok=false, not as an HTTP error.
+// @Tags 04 - System
+// @Produce json
+// @Param id path int true "Blob ID"
+// @Success 200 {object} service.BlobVerifyResult
+// @Failure 400 {string} string "Invalid blob ID"
+// @Failure 404 {string} string "Blob not found"
+// @Router /system/blobs/{id}/verify [get]
+func (s *Server) HandleSystemBlobVerify(w http.ResponseWriter, r *http.Request) {
+	blobID, err := pathInt64(r, "id")
+	if err != nil || blobID <= 0 {
+		http.Error(w, "Invalid blob ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.FileService.VerifyBlob(blobID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			http.Error(w, "Blob not found", http.StatusNotFound)
+			return
+		}
+		utils.Error("SYSTEM", "Blob verification failed: blob_id=%d, error=%v", blobID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !result.OK {
+		utils.Warn("SYSTEM", "Blob %d failed verification: %s", blobID, strings.Join(result.Errors, "; "))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// labelPageSize is the number of file records loaded at once while listing a label
+const labelPageSize = 1000
+
+// LabelFileEntry is one file of GET /base/files/old/by-label/{label}
+type LabelFileEntry struct {
+	CumulusID *int64    `json:"cumulusID,omitempty" example:"1001"` // missing for files uploaded without an old ID
+	FileID    string    `json:"fileID" example:"xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"`
+	Filename  string    `json:"filename" example:"invoice.pdf"`
+	CreatedAt time.Time `json:"createdAt" example:"2024-01-01T10:00:00Z"`
+}
+
+// HandleBaseFilesByLabel lists all files with a label (tag) for legacy consumers
+// @Summary List files by label
+// @Description Lists all unexpired (or pinned) files carrying the label as a plain array, like the old Cumulus label listing. Labels of migrated files are tags, so this is the same set as /v2/files/old/by-label/{label}, but without paging. The array is streamed; if reading the records fails midway the response ends with invalid JSON.
+// @Tags 01 - Base (internal)
+// @Produce json
+// @Param label path string true "Label (tag)"
+// @Success 200 {array} LabelFileEntry
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /base/files/old/by-label/{label} [get]
+func (s *Server) HandleBaseFilesByLabel(w http.ResponseWriter, r *http.Request) {
+	label := r.PathValue("label")
+
+	files, err := s.FileService.MetaStore.ListFilesByTag(label, "", labelPageSize)
+	if err != nil {
+		utils.Error("TAGS", "Failed to list files by label: label=%s, error=%v", label, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("["))
+	enc := json.NewEncoder(w)
+	count := 0
+	for {
+		for _, f := range files {
+			if count > 0 {
+				w.Write([]byte(","))
+			}
+			enc.Encode(LabelFileEntry{CumulusID: f.OldCumulusID, FileID: f.ID, Filename: f.Name, CreatedAt: f.CreatedAt})
+			count++
+		}
+		if len(files) < labelPageSize {
+			break
+		}
+		if files, err = s.FileService.MetaStore.ListFilesByTag(label, files[len(files)-1].ID, labelPageSize); err != nil {
+			// Hlavičky už jsou odeslané, klient dostane useknuté pole
+			utils.Error("TAGS", "Label listing aborted: label=%s, files=%d, error=%v", label, count, err)
+			return
+		}
+	}
+	w.Write([]byte("]\n"))
+}
+
+// HandleV2FilesByLabel lists files with a label (tag) page by page
+// @Summary List files by label
+// @Description Same as GET /v2/files/list?tag={label}, under the old Cumulus path for legacy consumers: unexpired (or pinned) files carrying the label, ordered by ID. Page through the list by passing next_after as ?after=.
+// @Tags 02 - Files
+// @Produce json
+// @Param label path string true "Label (tag)"
+// @Param after query string false "Return files with ID greater than this (next_after of the previous page)"
+// @Param limit query int false "Max number of files (default 100, max 1000)"
+// @Success 200 {object} FileListResponse
+// @Failure 400 {string} string "Invalid limit"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v2/files/old/by-label/{label} [get]
+func (s *Server) HandleV2FilesByLabel(w http.ResponseWriter, r *http.Request) {
+	s.writeTagFileList(w, r, r.PathValue("label"))
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CacheControlRule sets the Cache-Control header of downloads whose MIME type matches Pattern:
+// an exact type ("application/pdf"), a top-level type ("image/*") or "*" for everything
+type CacheControlRule struct {
+	Pattern string
+	Value   string
+}
+
+// CacheControlPolicy are the Cache-Control rules of plain file downloads (DOWNLOAD_CACHE_CONTROL),
+// the first matching rule wins. Without a matching rule no Cache-Control header is sent.
+type CacheControlPolicy []CacheControlRule
+
+// ParseCacheControlPolicy parses rules separated by ";", each "pattern=value", e.g.
+//
+//	image/*=public, max-age=31536000, immutable; application/pdf=no-store; *=private, no-cache
+func ParseCacheControlPolicy(value string) (CacheControlPolicy, error) {
+	var policy CacheControlPolicy
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pattern, header, ok := strings.Cut(part, "=")
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		header = strings.TrimSpace(header)
+		if !ok || header == "" {
+			return nil, fmt.Errorf("rule %q: expected pattern=value", part)
+		}
+		if pattern != "*" && !strings.Contains(pattern, "/") {
+			return nil, fmt.Errorf("rule %q: pattern must be a MIME type, type/* or *", part)
+		}
+		policy = append(policy, CacheControlRule{Pattern: pattern, Value: header})
+	}
+	return policy, nil
+}
+
+// For returns the Cache-Control value for a download of the given MIME type, "" if no rule
+// matches. Content under an old Cumulus ID can change (on_conflict=supersede moves the ID to
+// another file), so with mutable the "immutable" directive is left out.
+func (p CacheControlPolicy) For(mimeType string, mutable bool) string {
+	mimeType = strings.ToLower(mimeType)
+	for _, rule := range p {
+		if !rule.matches(mimeType) {
+			continue
+		}
+		if !mutable {
+			return rule.Value
+		}
+		var directives []string
+		for _, d := range strings.Split(rule.Value, ",") {
+			if d = strings.TrimSpace(d); d != "" && !strings.EqualFold(d, "immutable") {
+				directives = append(directives, d)
+			}
+		}
+		return strings.Join(directives, ", ")
+	}
+	return ""
+}
+
+func (r CacheControlRule) matches(mimeType string) bool {
+	switch {
+	case r.Pattern == "*":
+		return true
+	case strings.HasSuffix(r.Pattern, "/*"):
+		return strings.HasPrefix(mimeType, strings.TrimSuffix(r.Pattern, "*"))
+	default:
+		return mimeType == r.Pattern
+	}
+}
+
+// setDownloadCacheControl sets the configured Cache-Control header of a file download
+func (s *Server) setDownloadCacheControl(w http.ResponseWriter, mimeType string, mutable bool) {
+	if value := s.DownloadCacheControl.For(mimeType, mutable); value != "" {
+		w.Header().Set("Cache-Control", value)
+	}
+}
@@ -0,0 +1,255 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// Forwarding modes of GET requests for files owned by another node
+const (
+	ClusterModeRedirect = "redirect" // 307 to the owning node, the client fetches it itself
+	ClusterModeProxy    = "proxy"    // this node streams the response of the owning node
+)
+
+// ClusterForwardedHeader marks requests forwarded by a cluster node; they are never forwarded
+// again, so a file missing everywhere ends with 404 instead of a loop
+const ClusterForwardedHeader = "X-Cumulus-Forwarded-By"
+
+// DefaultClusterTimeout bounds the owner lookup on one node
+const DefaultClusterTimeout = 5 * time.Second
+
+const (
+	clusterOwnerTTL       = 10 * time.Minute // files do not move between nodes, only get deleted
+	clusterOwnerCacheSize = 10000
+)
+
+// ClusterNode is another Cumulus3 node of the cluster
+type ClusterNode struct {
+	Name string
+	URL  *url.URL // base URL; user:password@ is used for the owner lookup and proxying
+}
+
+// Cluster forwards GET requests for files this node does not have to the node that owns them,
+// so clients can ask any node for any UUID. Every node has its own metadata database; the owner
+// is found by asking the other nodes for the file info and remembered for a while.
+type Cluster struct {
+	Self   string // name of this node, sent in ClusterForwardedHeader
+	Nodes  []ClusterNode
+	Mode   string // ClusterModeRedirect or ClusterModeProxy
+	client *http.Client
+
+	heartbeatInterval time.Duration // set by StartHeartbeats, see cluster_membership.go
+
+	mu     sync.Mutex
+	owners map[string]clusterOwner // file ID -> owning node
+}
+
+type clusterOwner struct {
+	node    *ClusterNode
+	expires time.Time
+}
+
+// NewCluster returns nil when there are no other nodes (cluster mode disabled)
+func NewCluster(self string, nodes []ClusterNode, mode string, timeout time.Duration) *Cluster {
+	if len(nodes) == 0 {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = DefaultClusterTimeout
+	}
+	return &Cluster{
+		Self:   self,
+		Nodes:  nodes,
+		Mode:   mode,
+		client: &http.Client{Timeout: timeout},
+		owners: make(map[string]clusterOwner),
+	}
+}
+
+// ParseClusterNodes parses "name=url" pairs separated by commas, e.g.
+// "node-b=http://10.0.0.2:8800, node-c=http://10.0.0.3:8800"
+func ParseClusterNodes(value string) ([]ClusterNode, error) {
+	var nodes []ClusterNode
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, rawURL, ok := strings.Cut(part, "=")
+		name, rawURL = strings.TrimSpace(name), strings.TrimSpace(rawURL)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%q: expected name=url", part)
+		}
+		u, err := url.Parse(strings.TrimSuffix(rawURL, "/"))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("node %s: invalid URL %q", name, rawURL)
+		}
+		nodes = append(nodes, ClusterNode{Name: name, URL: u})
+	}
+	return nodes, nil
+}
+
+// ParseClusterMode validates CLUSTER_MODE; empty means redirect
+func ParseClusterMode(value string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(value)); mode {
+	case "", ClusterModeRedirect:
+		return ClusterModeRedirect, nil
+	case ClusterModeProxy:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown mode %q (use redirect, proxy)", value)
+	}
+}
+
+// Forward sends a GET request for a file that is not in the local metadata to the node that owns
+// it. Returns false when the request was not handled (not a cluster, already forwarded, request
+// by old ID, or no node has the file) and the caller answers 404 itself.
+func (c *Cluster) Forward(w http.ResponseWriter, r *http.Request, fileID string) bool {
+	// old Cumulus IDs are assigned per node, only UUIDs identify a file across the cluster
+	if c == nil || r.Method != http.MethodGet || r.PathValue("uuid") != fileID || r.Header.Get(ClusterForwardedHeader) != "" {
+		return false
+	}
+	node, err := c.owner(r, fileID)
+	if err != nil {
+		utils.Warn("CLUSTER", "Owner lookup failed: file_id=%s, error=%v", fileID, err)
+	}
+	if node == nil {
+		RecordClusterForward("", "not_found")
+		return false
+	}
+
+	target := *node.URL
+	target.Path += r.URL.Path
+	target.RawQuery = r.URL.RawQuery
+	if c.Mode == ClusterModeProxy {
+		utils.Info("CLUSTER", "Proxying %s to node %s, remote=%s", r.URL.Path, node.Name, r.RemoteAddr)
+		RecordClusterForward(node.Name, ClusterModeProxy)
+		c.proxy(node, &target).ServeHTTP(w, r)
+		return true
+	}
+	target.User = nil
+	utils.Info("CLUSTER", "Redirecting %s to node %s, remote=%s", r.URL.Path, node.Name, r.RemoteAddr)
+	RecordClusterForward(node.Name, ClusterModeRedirect)
+	http.Redirect(w, r, target.String(), http.StatusTemporaryRedirect)
+	return true
+}
+
+// owner returns the node that has the file, or nil when none has it
+func (c *Cluster) owner(r *http.Request, fileID string) (*ClusterNode, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if o, ok := c.owners[fileID]; ok && now.Before(o.expires) {
+		c.mu.Unlock()
+		return o.node, nil
+	}
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	type result struct {
+		node *ClusterNode
+		err  error
+	}
+	results := make(chan result, len(c.Nodes))
+	for i := range c.Nodes {
+		node := &c.Nodes[i]
+		go func() {
+			found, err := c.hasFile(ctx, node, fileID, r.Header)
+			if !found {
+				node = nil
+			}
+			results <- result{node, err}
+		}()
+	}
+
+	var errs []string
+	for range c.Nodes {
+		res := <-results
+		if res.err != nil {
+			errs = append(errs, res.err.Error())
+		}
+		if res.node != nil {
+			c.remember(fileID, res.node, now)
+			return res.node, nil
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil, nil
+}
+
+// hasFile asks a node for the file info; the client's Authorization and X-API-Key are passed on
+// unless the node URL carries its own credentials
+func (c *Cluster) hasFile(ctx context.Context, node *ClusterNode, fileID string, client http.Header) (bool, error) {
+	u := *node.URL
+	u.User = nil
+	u.Path += "/v2/files/info/" + url.PathEscape(fileID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	c.setForwardHeaders(req, node, client)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("node %s: %w", node.Name, err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusGone:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("node %s: status %d", node.Name, resp.StatusCode)
+	}
+}
+
+func (c *Cluster) remember(fileID string, node *ClusterNode, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.owners) >= clusterOwnerCacheSize {
+		clear(c.owners)
+	}
+	c.owners[fileID] = clusterOwner{node: node, expires: now.Add(clusterOwnerTTL)}
+}
+
+// proxy streams the request to the owning node
+func (c *Cluster) proxy(node *ClusterNode, target *url.URL) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = target
+			pr.Out.URL.User = nil
+			pr.Out.Host = target.Host
+			pr.SetXForwarded()
+			c.setForwardHeaders(pr.Out, node, pr.In.Header)
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			utils.Warn("CLUSTER", "Proxy to node %s failed: path=%s, error=%v", node.Name, r.URL.Path, err)
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		},
+	}
+}
+
+func (c *Cluster) setForwardHeaders(req *http.Request, node *ClusterNode, client http.Header) {
+	req.Header.Set(ClusterForwardedHeader, c.Self)
+	if node.URL.User != nil {
+		password, _ := node.URL.User.Password()
+		req.SetBasicAuth(node.URL.User.Username(), password)
+		req.Header.Del(APIKeyHeader)
+		return
+	}
+	for _, name := range []string{"Authorization", APIKeyHeader} {
+		if value := client.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+}
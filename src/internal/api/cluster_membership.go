@@ -0,0 +1,245 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// DefaultClusterHeartbeatInterval is how often the other nodes are asked for their state
+const DefaultClusterHeartbeatInterval = 10 * time.Second
+
+// Health of a cluster node in GET /system/cluster
+const (
+	ClusterStatusSelf    = "self"
+	ClusterStatusUp      = "up"      // fresh state, from its heartbeat or through another node
+	ClusterStatusSuspect = "suspect" // heartbeats to it fail, but other nodes still get fresh state from it
+	ClusterStatusDown    = "down"    // no fresh state for 3 heartbeat intervals
+)
+
+// ClusterNodeStatus is a cluster node with its health; replicationLagSeconds is the age of the
+// state this node has about it (how long ago the node itself produced it)
+type ClusterNodeStatus struct {
+	storage.ClusterMember
+	Status                string   `json:"status" example:"up"`
+	ReplicationLagSeconds *float64 `json:"replicationLagSeconds,omitempty" example:"2.5"`
+}
+
+// ClusterResponse is the body of GET /system/cluster; nodes answer heartbeats with it too, so
+// every node learns about the nodes its peers know (gossip)
+type ClusterResponse struct {
+	Self  ClusterNodeStatus   `json:"self"`
+	Nodes []ClusterNodeStatus `json:"nodes"`
+}
+
+// StartHeartbeats asks the other nodes for their state every interval and stores it in the
+// cluster_nodes table, together with the nodes they know about
+func (c *Cluster) StartHeartbeats(meta *storage.MetadataSQL, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultClusterHeartbeatInterval
+	}
+	c.heartbeatInterval = interval
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := c.heartbeat(meta, time.Now()); err != nil {
+				utils.Error("CLUSTER", "Heartbeat failed: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func (c *Cluster) heartbeat(meta *storage.MetadataSQL, now time.Time) error {
+	members, err := meta.ListClusterMembers()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]storage.ClusterMember, len(members))
+	for _, m := range members {
+		known[m.Name] = m
+	}
+
+	reports := make([]*ClusterResponse, len(c.Nodes))
+	errs := make([]error, len(c.Nodes))
+	var wg sync.WaitGroup
+	for i := range c.Nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i], errs[i] = c.fetchState(&c.Nodes[i])
+		}()
+	}
+	wg.Wait()
+
+	for i := range c.Nodes {
+		node := &c.Nodes[i]
+		member := known[node.Name]
+		member.Name, member.URL = node.Name, node.URL.Redacted()
+		if errs[i] != nil {
+			if member.LastError == "" {
+				utils.Warn("CLUSTER", "Node %s does not answer heartbeats: %v", node.Name, errs[i])
+			}
+			member.LastError = errs[i].Error()
+			if err := meta.SaveClusterMember(member); err != nil {
+				return err
+			}
+			known[node.Name] = member
+			continue
+		}
+
+		state := reports[i].Self.ClusterMember
+		member.Volumes, member.StateAt = state.Volumes, state.StateAt
+		member.HeartbeatAt, member.SeenAt = &now, now
+		member.Via, member.LastError = "", ""
+		if err := meta.SaveClusterMember(member); err != nil {
+			return err
+		}
+		known[node.Name] = member
+
+		// gossip: newer states of nodes the peer knows about
+		for _, g := range reports[i].Nodes {
+			if g.Name == c.Self || g.Name == node.Name {
+				continue
+			}
+			cur, ok := known[g.Name]
+			if ok && !g.StateAt.After(cur.StateAt) {
+				continue
+			}
+			if !ok {
+				cur = storage.ClusterMember{Name: g.Name, URL: g.URL}
+				utils.Info("CLUSTER", "Learned about node %s from node %s", g.Name, node.Name)
+			}
+			cur.Volumes, cur.StateAt = g.Volumes, g.StateAt
+			cur.SeenAt, cur.Via = now, node.Name
+			if err := meta.SaveClusterMember(cur); err != nil {
+				return err
+			}
+			known[g.Name] = cur
+		}
+	}
+	return nil
+}
+
+// fetchState asks a node for GET /system/cluster
+func (c *Cluster) fetchState(node *ClusterNode) (*ClusterResponse, error) {
+	u := *node.URL
+	u.User = nil
+	u.Path += "/system/cluster"
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setForwardHeaders(req, node, nil)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var report ClusterResponse
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &report, nil
+}
+
+// state returns the cluster as this node sees it
+func (c *Cluster) state(meta *storage.MetadataSQL, now time.Time) (ClusterResponse, error) {
+	volumeIDs, err := meta.GetDistinctVolumeIDs()
+	if err != nil {
+		return ClusterResponse{}, err
+	}
+	members, err := meta.ListClusterMembers()
+	if err != nil {
+		return ClusterResponse{}, err
+	}
+
+	resp := ClusterResponse{
+		Self: ClusterNodeStatus{
+			ClusterMember: storage.ClusterMember{Name: c.Self, Volumes: volumeRanges(volumeIDs), StateAt: now, SeenAt: now},
+			Status:        ClusterStatusSelf,
+		},
+		Nodes: make([]ClusterNodeStatus, 0, len(members)),
+	}
+	for _, m := range members {
+		if m.Name == c.Self {
+			continue
+		}
+		status := ClusterNodeStatus{ClusterMember: m, Status: c.memberStatus(m, now)}
+		if !m.StateAt.IsZero() {
+			lag := now.Sub(m.StateAt).Seconds()
+			status.ReplicationLagSeconds = &lag
+		}
+		resp.Nodes = append(resp.Nodes, status)
+	}
+	return resp, nil
+}
+
+func (c *Cluster) memberStatus(m storage.ClusterMember, now time.Time) string {
+	fresh := 3 * c.heartbeatInterval
+	if fresh <= 0 {
+		fresh = 3 * DefaultClusterHeartbeatInterval
+	}
+	switch {
+	case m.SeenAt.IsZero() || now.Sub(m.SeenAt) > fresh:
+		return ClusterStatusDown
+	case m.LastError != "":
+		return ClusterStatusSuspect
+	default:
+		return ClusterStatusUp
+	}
+}
+
+// volumeRanges formats sorted volume IDs as ranges, e.g. "1-4,7"
+func volumeRanges(ids []int64) string {
+	var parts []string
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.FormatInt(ids[i], 10))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", ids[i], ids[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// HandleSystemCluster reports the cluster membership
+// @Summary Cluster membership
+// @Description Lists the other nodes of the cluster with their health, the volume IDs they hold and how old the known state is. Nodes exchange this report as heartbeats every CLUSTER_HEARTBEAT_INTERVAL, so nodes known only to a peer are listed too (via). Status: up (fresh state from its heartbeat or through a peer), suspect (heartbeats to it fail but peers still get fresh state), down (no fresh state for 3 intervals).
+// @Tags 04 - System
+// @Produce json
+// @Success 200 {object} ClusterResponse
+// @Failure 404 {string} string "Cluster mode is not enabled (CLUSTER_NODES)"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /system/cluster [get]
+func (s *Server) HandleSystemCluster(w http.ResponseWriter, r *http.Request) {
+	if s.Cluster == nil {
+		http.Error(w, "Cluster mode is not enabled (CLUSTER_NODES)", http.StatusNotFound)
+		return
+	}
+	resp, err := s.Cluster.state(s.FileService.MetaStore, time.Now().UTC())
+	if err != nil {
+		utils.Error("SYSTEM", "Failed to read cluster state: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// CompressionCategory is the compression effectiveness of one file type category
+type CompressionCategory struct {
+	Category            string  `json:"category" example:"image"`
+	Blobs               int64   `json:"blobs" example:"1200"`
+	RawBytes            int64   `json:"rawBytes" example:"524288000"`
+	StoredBytes         int64   `json:"storedBytes" example:"519045120"`
+	SavedPercent        float64 `json:"savedPercent" example:"1"`            // 1 - stored/raw, in %
+	UncompressedBlobs   int64   `json:"uncompressedBlobs" example:"1100"`    // stored with compression none
+	UncompressedPercent float64 `json:"uncompressedPercent" example:"91.67"` // uncompressed blobs of all blobs, in %
+}
+
+// CompressionReport is the body of GET /system/compression
+type CompressionReport struct {
+	Categories []CompressionCategory `json:"categories"`
+	Total      CompressionCategory   `json:"total"`
+}
+
+func newCompressionCategory(s storage.CompressionCategoryStats) CompressionCategory {
+	c := CompressionCategory{
+		Category:          s.Category,
+		Blobs:             s.Blobs,
+		RawBytes:          s.RawBytes,
+		StoredBytes:       s.StoredBytes,
+		UncompressedBlobs: s.UncompressedBlobs,
+	}
+	if s.RawBytes > 0 {
+		c.SavedPercent = (1.0 - float64(s.StoredBytes)/float64(s.RawBytes)) * 100
+	}
+	if s.Blobs > 0 {
+		c.UncompressedPercent = float64(s.UncompressedBlobs) / float64(s.Blobs) * 100
+	}
+	return c
+}
+
+// HandleSystemCompression reports compression effectiveness per file type category
+// @Summary Compression effectiveness per category
+// @Description Returns raw vs stored bytes of committed blobs per MIME category (image, document, text, ...) with the saved percentage and the share of blobs stored uncompressed (incompressible or below MINIMAL_COMPRESSION), plus the total. Categories are ordered by raw size. Each blob is counted once regardless of how many files share it.
+// @Tags 04 - System
+// @Produce json
+// @Success 200 {object} CompressionReport
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /system/compression [get]
+func (s *Server) HandleSystemCompression(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.FileService.MetaStore.GetCompressionStats()
+	if err != nil {
+		utils.Error("SYSTEM", "Failed to get compression stats: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	report := CompressionReport{Categories: make([]CompressionCategory, 0, len(stats))}
+	total := storage.CompressionCategoryStats{Category: "total"}
+	for _, c := range stats {
+		report.Categories = append(report.Categories, newCompressionCategory(c))
+		total.Blobs += c.Blobs
+		total.RawBytes += c.RawBytes
+		total.StoredBytes += c.StoredBytes
+		total.UncompressedBlobs += c.UncompressedBlobs
+	}
+	report.Total = newCompressionCategory(total)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
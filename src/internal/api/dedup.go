@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+const (
+	defaultDedupTagLimit = 50
+	maxDedupTagLimit     = 1000
+)
+
+// DedupTag is the deduplication effectiveness of the files carrying one tag
+type DedupTag struct {
+	Tag          string  `json:"tag" example:"invoice"`
+	Files        int64   `json:"files" example:"3000"`
+	Blobs        int64   `json:"blobs" example:"1200"`              // distinct contents
+	LogicalBytes int64   `json:"logicalBytes" example:"3145728000"` // raw size of every file
+	UniqueBytes  int64   `json:"uniqueBytes" example:"1258291200"`  // raw size of the distinct contents
+	StoredBytes  int64   `json:"storedBytes" example:"943718400"`   // after compression
+	SavedBytes   int64   `json:"savedBytes" example:"1887436800"`   // logical - unique
+	SavedPercent float64 `json:"savedPercent" example:"60"`         // 1 - unique/logical, in %
+	DedupRatio   float64 `json:"dedupRatio" example:"2.5"`          // logical / unique
+	AvgFileBytes int64   `json:"avgFileBytes" example:"1048576"`    // logical / files
+}
+
+// DedupReport is the body of GET /system/dedup
+type DedupReport struct {
+	Tags  []DedupTag `json:"tags"`
+	Total DedupTag   `json:"total"` // all files, tagged or not
+}
+
+func newDedupTag(s storage.DedupStats) DedupTag {
+	d := DedupTag{
+		Tag:          s.Tag,
+		Files:        s.Files,
+		Blobs:        s.Blobs,
+		LogicalBytes: s.LogicalBytes,
+		UniqueBytes:  s.UniqueBytes,
+		StoredBytes:  s.StoredBytes,
+		SavedBytes:   s.LogicalBytes - s.UniqueBytes,
+	}
+	if s.LogicalBytes > 0 {
+		d.SavedPercent = (1.0 - float64(s.UniqueBytes)/float64(s.LogicalBytes)) * 100
+	}
+	if s.UniqueBytes > 0 {
+		d.DedupRatio = float64(s.LogicalBytes) / float64(s.UniqueBytes)
+	}
+	if s.Files > 0 {
+		d.AvgFileBytes = s.LogicalBytes / s.Files
+	}
+	return d
+}
+
+// HandleSystemDedup reports deduplication effectiveness per tag
+// @Summary Deduplication effectiveness per tag
+// @Description Returns logical bytes (raw size of every file) vs unique bytes (raw size of the distinct blobs the files reference) and stored bytes per tag, ordered by the saved bytes, plus the total of all files. Whole-file deduplication only finds identical files: tags with many large files but little saving (e.g. versions of documents) are where chunk-level deduplication would pay off. A blob shared by files of several tags counts in each of them.
+// @Tags 04 - System
+// @Produce json
+// @Param prefix query string false "Case-insensitive tag prefix"
+// @Param limit query int false "Max number of tags (default 50, max 1000)"
+// @Success 200 {object} DedupReport
+// @Failure 400 {string} string "Invalid limit"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /system/dedup [get]
+func (s *Server) HandleSystemDedup(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDedupTagLimit
+	if val := r.URL.Query().Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxDedupTagLimit)
+	}
+	prefix := r.URL.Query().Get("prefix")
+
+	stats, err := s.FileService.MetaStore.GetDedupStatsByTag(prefix, limit)
+	if err != nil {
+		utils.Error("SYSTEM", "Failed to get dedup stats: prefix=%s, error=%v", prefix, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	total, err := s.FileService.MetaStore.GetDedupTotals()
+	if err != nil {
+		utils.Error("SYSTEM", "Failed to get dedup totals: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	report := DedupReport{Tags: make([]DedupTag, 0, len(stats)), Total: newDedupTag(total)}
+	for _, d := range stats {
+		report.Tags = append(report.Tags, newDedupTag(d))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
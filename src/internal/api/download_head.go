@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// HEAD na download odpovídá jen z metadat – blob se nečte, klient tak levně zjistí existenci a velikost.
+// ETag downloadu je hash obsahu (BLAKE2b-256), If-None-Match se stejným hashem dostane 304.
+// Vzor "GET" v ServeMux zachytí i HEAD, download handlery ho sem přesměrují.
+
+// setDownloadHeaders sets the headers shared by GET and HEAD of a download
+func (s *Server) setDownloadHeaders(w http.ResponseWriter, dl *service.FileDownload, byOldID bool) {
+	w.Header().Set("Content-Type", dl.MimeType)
+	w.Header().Set("Content-Disposition", contentDisposition(dl.Disposition, dl.MimeType, dl.Filename))
+	s.setDownloadCacheControl(w, dl.MimeType, byOldID)
+	if dl.Hash != "" {
+		w.Header().Set("ETag", `"`+dl.Hash+`"`)
+	}
+}
+
+// acceptRanges is the Accept-Ranges header of a download: only content of a local volume answers
+// Range requests, content from the read fallback is sent whole
+func acceptRanges(dl *service.FileDownload) string {
+	if dl.Ranges {
+		return "bytes"
+	}
+	return "none"
+}
+
+// etagMatches reports whether the If-None-Match header of r lists the ETag of content with hash
+// (weak comparison, "*" matches any stored file)
+func etagMatches(r *http.Request, hash string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || hash == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == `"`+hash+`"` {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified answers a download whose cached copy is current; the ETag and Cache-Control
+// set before stay, the content headers are dropped (RFC 9110, 15.4.5)
+func writeNotModified(w http.ResponseWriter) {
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	RecordDownloadNotModified()
+}
+
+// writeDownloadHead answers a HEAD request with the headers a GET of the whole file would send
+func (s *Server) writeDownloadHead(w http.ResponseWriter, r *http.Request, dl *service.FileDownload, byOldID bool) {
+	s.setDownloadHeaders(w, dl, byOldID)
+	if etagMatches(r, dl.Hash) {
+		writeNotModified(w)
+		return
+	}
+	w.Header().Set("Accept-Ranges", acceptRanges(dl))
+	w.Header().Set("Content-Length", strconv.FormatInt(dl.SizeRaw, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// headDownload answers HEAD of a download by UUID
+func (s *Server) headDownload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("uuid")
+	dl, err := s.FileService.StatFile(id)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			if s.Cluster.Forward(w, r, id) {
+				return
+			}
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrExpired) {
+			writeExpired(w, "download")
+			return
+		}
+		utils.Info("DOWNLOAD", "HEAD ERROR: file_id=%s, remote=%s, error=%v", id, r.RemoteAddr, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.writeDownloadHead(w, r, dl, false)
+}
+
+// headDownloadByOldID answers HEAD of a download by old Cumulus ID
+func (s *Server) headDownloadByOldID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathInt64(r, "cumulus_id")
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+	dl, err := s.FileService.StatFileByOldID(id)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrExpired) {
+			writeExpired(w, "download")
+			return
+		}
+		utils.Info("DOWNLOAD_OLD_ID", "HEAD ERROR: old_id=%d, remote=%s, error=%v", id, r.RemoteAddr, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.writeDownloadHead(w, r, dl, true)
+}
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/images"
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// FileRecompressRequest is the body of POST /system/files/{id}/recompress
+type FileRecompressRequest struct {
+	Compression string `json:"compression" example:"zstd"` // auto, zstd, gzip or none
+}
+
+// FileMoveRequest is the body of POST /system/files/{id}/move and /system/blobs/{id}/move
+type FileMoveRequest struct {
+	VolumeID int64 `json:"volumeId" example:"7"`
+}
+
+// FileTypeRedetectRequest is the body of POST /system/redetect; empty filter fields match all blobs
+type FileTypeRedetectRequest struct {
+	Category string `json:"category" example:"binary"`
+	MimeType string `json:"mimeType" example:"application/octet-stream"`
+	DryRun   bool   `json:"dryRun"`
+}
+
+// VariantResult is the outcome of rendering one image variant
+type VariantResult struct {
+	Variant    string `json:"variant"`
+	OK         bool   `json:"ok"`
+	MimeType   string `json:"mimeType,omitempty"`
+	Size       int    `json:"size,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+var imageVariants = []struct {
+	name string
+	size images.ImageSize
+}{
+	{"thumb", images.SizeThumb},
+	{"sm", images.SizeSm},
+	{"md", images.SizeMd},
+	{"lg", images.SizeLg},
+}
+
+// writeFileOpResult writes the JSON result of a file or blob operation or maps its error to a
+// status; target identifies the file or blob in the log, e.g. "file_id=..."
+func writeFileOpResult(w http.ResponseWriter, op, target string, result any, err error) {
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrExpired):
+			status = http.StatusGone
+		case errors.Is(err, service.ErrBlobAlreadyInVolume), errors.Is(err, errUnsupportedVariantSource):
+			status = http.StatusBadRequest
+		case errors.Is(err, storage.ErrVolumeNotWritable), errors.Is(err, storage.ErrVolumeFull), errors.Is(err, storage.ErrBlobMoved),
+			errors.Is(err, service.ErrFileTypeChanged):
+			status = http.StatusConflict
+		}
+		if status == http.StatusInternalServerError {
+			utils.Error("ADMIN", "Operation %s failed: %s, error=%v", op, target, err)
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+var errUnsupportedVariantSource = errors.New("file is not an image or PDF")
+
+// renderFileVariants renders all image variants of the file. Variants are not stored, they are
+// produced on request; this checks that each of them can be produced with the current type.
+func (s *Server) renderFileVariants(fileID string) ([]VariantResult, error) {
+	rc, dl, err := s.FileService.DownloadFile(fileID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	isPDF := images.IsPDFMimeType(dl.MimeType)
+	if !isPDF && !images.IsImageMimeType(dl.MimeType) {
+		return nil, errUnsupportedVariantSource
+	}
+
+	results := make([]VariantResult, 0, len(imageVariants))
+	for _, v := range imageVariants {
+		start := time.Now()
+		var out []byte
+		var outMime string
+		if isPDF {
+			out, err = images.GeneratePDFThumbnail(data, v.size)
+			outMime = "image/jpeg"
+		} else {
+			out, err = images.ResizeImage(data, dl.MimeType, v.size)
+			outMime = images.GetOutputMimeType(dl.MimeType)
+		}
+		res := VariantResult{Variant: v.name, OK: err == nil, DurationMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			res.Error = err.Error()
+			utils.Warn("ADMIN", "Variant %s of file %s failed: %v", v.name, fileID, err)
+		} else {
+			res.MimeType = outMime
+			res.Size = len(out)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// HandleSystemFileRedetect re-detects the type of a file
+// @Summary Re-detect file type
+// @Description Runs file type detection on the stored content again and updates the type of the blob (shared by all files with the same content). A generic binary result never replaces a more specific type. Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Param id path string true "File UUID"
+// @Success 200 {object} service.FileTypeChange
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 404 {string} string "File not found"
+// @Router /system/files/{id}/redetect [post]
+func (s *Server) HandleSystemFileRedetect(w http.ResponseWriter, r *http.Request) {
+	fileID := r.PathValue("id")
+	result, err := s.FileService.RedetectFileType(fileID)
+	writeFileOpResult(w, "redetect", "file_id="+fileID, result, err)
+}
+
+// HandleSystemFileRecompress rewrites the blob of a file with another compression
+// @Summary Re-compress file content
+// @Description Rewrites the blob of the file with the given compression (auto applies MINIMAL_COMPRESSION, empty = server USE_COMPRESS). The content hash is verified first; the old copy becomes deleted space reclaimed by compaction. Requires admin Basic auth.
+// @Tags 04 - System
+// @Accept json
+// @Produce json
+// @Param id path string true "File UUID"
+// @Param body body FileRecompressRequest false "Compression (auto, zstd, gzip, none)"
+// @Success 200 {object} service.BlobRewriteResult
+// @Failure 400 {string} string "Invalid compression"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 404 {string} string "File not found"
+// @Failure 409 {string} string "Blob moved concurrently"
+// @Router /system/files/{id}/recompress [post]
+func (s *Server) HandleSystemFileRecompress(w http.ResponseWriter, r *http.Request) {
+	fileID := r.PathValue("id")
+	var req FileRecompressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	switch strings.ToLower(req.Compression) {
+	case "":
+		req.Compression = s.FileService.CompressionMode
+	case "auto", "zstd", "gzip", "none":
+	default:
+		http.Error(w, "compression must be auto, zstd, gzip or none", http.StatusBadRequest)
+		return
+	}
+	result, err := s.FileService.RecompressFile(fileID, req.Compression)
+	writeFileOpResult(w, "recompress", "file_id="+fileID, result, err)
+}
+
+// HandleSystemFileMove moves the blob of a file to another volume
+// @Summary Move file blob to another volume
+// @Description Copies the stored blob unchanged (verified against its CRC) into an existing open volume with enough space; the old copy becomes deleted space. Requires admin Basic auth.
+// @Tags 04 - System
+// @Accept json
+// @Produce json
+// @Param id path string true "File UUID"
+// @Param body body FileMoveRequest true "Target volume"
+// @Success 200 {object} service.BlobRewriteResult
+// @Failure 400 {string} string "Blob is already in the target volume"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 404 {string} string "File not found"
+// @Failure 409 {string} string "Volume not open, full, or blob moved concurrently"
+// @Router /system/files/{id}/move [post]
+func (s *Server) HandleSystemFileMove(w http.ResponseWriter, r *http.Request) {
+	fileID := r.PathValue("id")
+	var req FileMoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.VolumeID <= 0 {
+		http.Error(w, "volumeId is required", http.StatusBadRequest)
+		return
+	}
+	result, err := s.FileService.MoveFileBlob(fileID, req.VolumeID)
+	writeFileOpResult(w, "move", "file_id="+fileID, result, err)
+}
+
+// HandleSystemFileVariants renders all image variants of a file
+// @Summary Regenerate image variants
+// @Description Renders all image variants (thumb, sm, md, lg) of an image or PDF and reports the result of each. Variants are produced on request and not stored, so this verifies they can be served. Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Param id path string true "File UUID"
+// @Success 200 {array} VariantResult
+// @Failure 400 {string} string "File is not an image or PDF"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 404 {string} string "File not found"
+// @Router /system/files/{id}/variants [post]
+func (s *Server) HandleSystemFileVariants(w http.ResponseWriter, r *http.Request) {
+	fileID := r.PathValue("id")
+	result, err := s.renderFileVariants(fileID)
+	writeFileOpResult(w, "variants", "file_id="+fileID, result, err)
+}
+
+// HandleSystemRedetect starts bulk re-detection of file types
+// @Summary Re-detect file types in bulk
+// @Description Starts an asynchronous job that re-runs file type detection on the content of all blobs whose current type matches the filter (e.g. category binary). A generic binary result never replaces a more specific type and blobs whose type changed meanwhile are skipped. With dryRun nothing is updated. The summary is the job result in /system/jobs. Requires admin Basic auth.
+// @Tags 04 - System
+// @Accept json
+// @Produce json
+// @Param body body FileTypeRedetectRequest true "Filter"
+// @Success 202 {object} map[string]interface{}
+// @Failure 400 {string} string "Invalid request"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Router /system/redetect [post]
+func (s *Server) HandleSystemRedetect(w http.ResponseWriter, r *http.Request) {
+	var req FileTypeRedetectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	filter := storage.FileTypeFilter{Category: req.Category, MimeType: req.MimeType}
+
+	job := globalJobManager.CreateJob("redetect", nil)
+	go func() {
+		globalJobManager.UpdateJob(job.ID, JobStatusRunning, "Starting file type re-detection", nil)
+
+		summary, err := s.FileService.RedetectFileTypes(filter, req.DryRun, func(p service.RedetectSummary) {
+			globalJobManager.UpdateJob(job.ID, JobStatusRunning,
+				fmt.Sprintf("Checked %d blobs, %d changed, %d failed", p.Scanned, p.Changed, p.Failed), nil)
+		})
+		data, _ := json.Marshal(summary)
+		if err != nil {
+			utils.Error("ADMIN", "File type re-detection failed: %v", err)
+			globalJobManager.UpdateJob(job.ID, JobStatusFailed, string(data), err)
+			return
+		}
+		utils.Info("ADMIN", "File type re-detection finished: scanned=%d, changed=%d, skipped=%d, failed=%d, dry_run=%v",
+			summary.Scanned, summary.Changed, summary.Skipped, summary.Failed, req.DryRun)
+		globalJobManager.UpdateJob(job.ID, JobStatusCompleted, string(data), nil)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobId":   job.ID,
+		"message": "File type re-detection started",
+	})
+}
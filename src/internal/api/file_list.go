@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+const (
+	defaultFileListLimit = 100
+	maxFileListLimit     = 1000
+)
+
+// FileListResponse is the body of the paged file listings (GET /v2/files/list, GET /v2/files/tmp)
+type FileListResponse struct {
+	Count     int            `json:"count" example:"1"`
+	Files     []storage.File `json:"files"`
+	NextAfter string         `json:"next_after,omitempty" example:"xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"` // pass as ?after= for the next page
+}
+
+// HandleV2FileList lists files with a tag
+// @Summary List files by tag
+// @Description Lists unexpired (or pinned) files carrying the tag, ordered by ID. Page through the list by passing next_after as ?after=; the last page has no next_after. Used by the export-dir tool.
+// @Tags 02 - Files
+// @Produce json
+// @Param tag query string true "Tag"
+// @Param after query string false "Return files with ID greater than this (next_after of the previous page)"
+// @Param limit query int false "Max number of files (default 100, max 1000)"
+// @Success 200 {object} FileListResponse
+// @Failure 400 {string} string "Missing tag or invalid limit"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v2/files/list [get]
+func (s *Server) HandleV2FileList(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get("tag")
+	if tag == "" {
+		http.Error(w, "Missing tag", http.StatusBadRequest)
+		return
+	}
+	s.writeTagFileList(w, r, tag)
+}
+
+// writeTagFileList writes one page of files carrying tag, paged by ?after= and ?limit=
+func (s *Server) writeTagFileList(w http.ResponseWriter, r *http.Request, tag string) {
+	limit := defaultFileListLimit
+	if val := r.URL.Query().Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxFileListLimit)
+	}
+	after := r.URL.Query().Get("after")
+
+	files, err := s.FileService.MetaStore.ListFilesByTag(tag, after, limit)
+	if err != nil {
+		utils.Error("TAGS", "Failed to list files: tag=%s, after=%s, error=%v", tag, after, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := FileListResponse{Count: len(files), Files: files}
+	if resp.Files == nil {
+		resp.Files = []storage.File{}
+	}
+	if len(files) == limit {
+		resp.NextAfter = files[len(files)-1].ID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
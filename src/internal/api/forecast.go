@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// Odhad zaplnění disku z historie obsazenosti (storage_stats_history) – lineární trend
+// obsazeného místa za posledních N dní, pro upozornění v admin UI dřív, než dojde místo.
+
+const (
+	defaultForecastWindow   = 7 * 24 * time.Hour
+	defaultForecastWarnDays = 30
+	forecastCriticalDays    = 7
+	forecastMinSpan         = time.Hour // shorter history gives no usable trend
+)
+
+// StartDiskUsageSampler records the disk usage of the named directories now and then every
+// interval (STATS_HISTORY_INTERVAL)
+func StartDiskUsageSampler(meta *storage.MetadataSQL, dirs map[string]string, interval time.Duration) {
+	sample := func() {
+		now := time.Now()
+		for name, path := range dirs {
+			total, free, err := storage.DiskUsage(path)
+			if err != nil {
+				utils.Warn("STATS_HISTORY", "Disk usage of %s (%s) not available: %v", name, path, err)
+				continue
+			}
+			s := storage.DiskUsageSample{Time: now, Dir: name, Path: path, TotalBytes: total, FreeBytes: free}
+			if err := meta.SaveDiskUsageSample(s); err != nil {
+				utils.Error("STATS_HISTORY", "Failed to save disk usage of %s: %v", name, err)
+			}
+		}
+	}
+	go func() {
+		sample()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			sample()
+		}
+	}()
+}
+
+// DirForecast is the fill forecast of one data directory
+type DirForecast struct {
+	Dir            string     `json:"dir" example:"data"`
+	Path           string     `json:"path" example:"/data"`
+	Status         string     `json:"status" example:"ok"` // ok | warning | critical | unknown
+	TotalBytes     int64      `json:"totalBytes"`
+	FreeBytes      int64      `json:"freeBytes"`
+	UsedPercent    float64    `json:"usedPercent"`
+	GrowthPerDay   int64      `json:"growthBytesPerDay"` // negative when usage is shrinking
+	DaysUntilFull  *float64   `json:"daysUntilFull,omitempty"`
+	FullAt         *time.Time `json:"fullAt,omitempty"`
+	Samples        int        `json:"samples"`
+	HistorySince   time.Time  `json:"historySince"`
+	LastSampleTime time.Time  `json:"lastSample"`
+}
+
+// ForecastReport is the response of /system/forecast
+type ForecastReport struct {
+	Status      string        `json:"status" example:"ok"` // worst status of the directories, unknown without history
+	Window      string        `json:"window" example:"168h0m0s"`
+	WarningDays int           `json:"warningDays" example:"30"`
+	Dirs        []DirForecast `json:"dirs"`
+}
+
+var forecastSeverity = map[string]int{"unknown": 0, "ok": 1, "warning": 2, "critical": 3}
+
+// forecastDir fits a least-squares line through the used bytes of the samples (oldest first)
+func forecastDir(samples []storage.DiskUsageSample, warningDays int) DirForecast {
+	first, last := samples[0], samples[len(samples)-1]
+	f := DirForecast{
+		Dir:            last.Dir,
+		Path:           last.Path,
+		Status:         "unknown",
+		TotalBytes:     last.TotalBytes,
+		FreeBytes:      last.FreeBytes,
+		Samples:        len(samples),
+		HistorySince:   first.Time,
+		LastSampleTime: last.Time,
+	}
+	if last.TotalBytes > 0 {
+		f.UsedPercent = math.Round(float64(last.TotalBytes-last.FreeBytes)/float64(last.TotalBytes)*1000) / 10
+	}
+	if len(samples) < 2 || last.Time.Sub(first.Time) < forecastMinSpan {
+		return f
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	n := float64(len(samples))
+	for _, s := range samples {
+		x := s.Time.Sub(first.Time).Hours() / 24
+		y := float64(s.TotalBytes - s.FreeBytes)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return f
+	}
+	growth := (n*sumXY - sumX*sumY) / denom
+	f.GrowthPerDay = int64(math.Round(growth))
+
+	f.Status = "ok"
+	if last.FreeBytes <= 0 {
+		days := 0.0
+		f.DaysUntilFull = &days
+		f.Status = "critical"
+		return f
+	}
+	if growth <= 0 {
+		return f
+	}
+	days := math.Round(float64(last.FreeBytes)/growth*10) / 10
+	fullAt := last.Time.Add(time.Duration(float64(last.FreeBytes) / growth * float64(24*time.Hour))).UTC()
+	f.DaysUntilFull = &days
+	f.FullAt = &fullAt
+	switch {
+	case days < forecastCriticalDays:
+		f.Status = "critical"
+	case days < float64(warningDays):
+		f.Status = "warning"
+	}
+	return f
+}
+
+// parseForecastWindow accepts whole days ("14d") or a Go duration ("36h")
+func parseForecastWindow(val string) (time.Duration, bool) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(val, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, false
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(val); err != nil {
+			return 0, false
+		}
+	}
+	return d, d >= forecastMinSpan && d <= storage.StatsHistoryRetention
+}
+
+// HandleSystemForecast estimates when the data directories run out of space
+// @Summary Get disk capacity forecast
+// @Description Estimates days until full per data directory from the growth of used space over the window (least-squares fit of the disk usage history sampled every STATS_HISTORY_INTERVAL, kept 90 days). Status is "critical" under 7 days, "warning" under FORECAST_WARNING_DAYS (default 30) and "unknown" with less than an hour of history; the top-level status is the worst one. Directories with shrinking or constant usage have no daysUntilFull.
+// @Tags 04 - System
+// @Produce json
+// @Param window query string false "History used for the trend, e.g. 14d or 36h (default 7d, 1h to 90d)"
+// @Success 200 {object} ForecastReport
+// @Failure 400 {string} string "Invalid window"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /system/forecast [get]
+func (s *Server) HandleSystemForecast(w http.ResponseWriter, r *http.Request) {
+	window := defaultForecastWindow
+	if val := r.URL.Query().Get("window"); val != "" {
+		d, ok := parseForecastWindow(val)
+		if !ok {
+			http.Error(w, "Invalid window, use e.g. 14d or 36h (1h to 90d)", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+	warningDays := s.ForecastWarningDays
+	if warningDays <= 0 {
+		warningDays = defaultForecastWarnDays
+	}
+
+	samples, err := s.FileService.MetaStore.GetDiskUsageHistory(time.Now().Add(-window))
+	if err != nil {
+		utils.Error("FORECAST", "Failed to load disk usage history: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	byDir := map[string][]storage.DiskUsageSample{}
+	for _, sample := range samples {
+		byDir[sample.Dir] = append(byDir[sample.Dir], sample)
+	}
+
+	report := ForecastReport{Status: "unknown", Window: window.String(), WarningDays: warningDays, Dirs: []DirForecast{}}
+	for _, dirSamples := range byDir {
+		report.Dirs = append(report.Dirs, forecastDir(dirSamples, warningDays))
+	}
+	sort.Slice(report.Dirs, func(i, j int) bool { return report.Dirs[i].Dir < report.Dirs[j].Dir })
+	for _, d := range report.Dirs {
+		if forecastSeverity[d.Status] > forecastSeverity[report.Status] {
+			report.Status = d.Status
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
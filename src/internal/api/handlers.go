@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"path/filepath"
@@ -25,82 +27,292 @@ import (
 type Server struct {
 	FileService   *service.FileService
 	MaxUploadSize int64
+
+	// Download offload to the fronting web server (see accel.go)
+	AccelMode    string // off | nginx | lighttpd
+	AccelPrefix  string // nginx internal location, e.g. /_cumulus_volumes/
+	AccelMinSize int64  // smaller blobs are served by the Go process
+
+	BatchUploadConcurrency int // files of a multi-file upload stored in parallel (see upload_batch.go)
+
+	DownloadCacheControl CacheControlPolicy // Cache-Control of /v2/files downloads, see cache_control.go
+
+	ImageSigningKey []byte // enables signed custom image dimensions, see image_signing.go
+
+	DownloadSigningKey []byte        // enables presigned download URLs, see presign.go
+	PresignMaxTTL      time.Duration // longest lifetime of a presigned URL, zero = DefaultPresignMaxTTL
+
+	ForecastWarningDays int // /system/forecast warns below this many days until full (see forecast.go)
+
+	ManifestSigningKey    []byte // signs volume manifests, see manifest.go
+	PurgeAuditPolicy      string // default audit policy of /system/purge, see purge.go
+	PurgeReportSigningKey []byte // signs purge reports
+
+	TempFileValidity string               // default validity of /v2/files/tmp uploads, see upload_temp.go
+	ValidityPolicy   utils.ValidityPolicy // bounds of validity/expires_at, zero = utils.DefaultValidityPolicy (see upload_expiry.go)
+
+	// Middlewares per route group (see middleware.go)
+	RouteMiddleware    RouteMiddleware
+	CORSAllowedOrigins []string
+	RateLimiter        *RateLimiter         // nil = ratelimit middleware lets everything through
+	APIKeys            *APIKeys             // keys of the apikey middleware, nil = only admin Basic auth passes (see api_keys.go)
+	APIKeysOptional    bool                 // API_KEYS_OPTIONAL: files and images require a key only with apikey in ROUTE_MIDDLEWARE
+	JWT                *JWTAuth             // bearer token validation of the jwt middleware, nil = tokens refused (see jwt.go)
+	KeyRateLimiter     *KeyRateLimiter      // nil = keylimit middleware lets everything through (see key_rate_limit.go)
+	Admission          *AdmissionController // nil = admission middleware lets everything through
+
+	Cluster *Cluster // forwards GET requests for files owned by other nodes, nil = single node (see cluster.go)
+
+	AlertRules AlertRulesConfig // configuration the rules of /metrics/alerts.yaml are generated from (see alerts.go)
+
+	ReplicaVerifier *ReplicaVerifier // latest comparison with the replica for /system/replication, nil = disabled (see replica_verify.go)
+
+	Backup *BackupPusher // scheduled metadata backup to S3 for /system/backup, nil = disabled (see backup.go)
+
+	SlowQueries *storage.SlowQueryLog // metadata queries over SLOW_QUERY_THRESHOLD for /system/slow-queries, nil = disabled (see slow_queries.go)
+
+	UploadSessions *UploadSessions // resumable uploads of /v2/uploads, nil = disabled (see upload_session.go)
+
+	ReadOnly bool // read-only replica: mutating routes answer 405 (see read_only.go)
 }
 
 // UploadResponse represents the response from file upload
+// (fields after CumulusID are filled only with ?verbose=1)
 type UploadResponse struct {
 	FileID    string `json:"fileID" example:"xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"`
 	CumulusID string `json:"cumulusID" example:"123456"`
+
+	Hash           string             `json:"hash,omitempty" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	SizeRaw        *int64             `json:"size_raw,omitempty" example:"1048576"`
+	SizeCompressed *int64             `json:"size_compressed,omitempty" example:"524288"`
+	Dedup          *bool              `json:"dedup,omitempty" example:"false"`
+	MimeType       string             `json:"mime_type,omitempty" example:"image/jpeg"`
+	Image          *service.ImageInfo `json:"image,omitempty"` // size of an uploaded image
+	ExpiresAt      *time.Time         `json:"expires_at,omitempty"`
+}
+
+// parseVerbose reads the optional ?verbose= flag of upload endpoints
+func parseVerbose(r *http.Request) (bool, error) {
+	val := r.URL.Query().Get("verbose")
+	if val == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(val)
+}
+
+// Hlavičky odpovědi uploadu jednoho souboru: zda obsah už byl uložen a ve kterém blobu je
+const (
+	DedupHeader  = "X-Cumulus-Dedup"   // "hit" when the content was already stored, "miss" otherwise
+	BlobIDHeader = "X-Cumulus-Blob-Id" // ID of the blob holding the content
+)
+
+// setDedupHeaders sets the dedup headers of a single-file upload response
+func setDedupHeaders(w http.ResponseWriter, res service.UploadResult) {
+	dedup := "miss"
+	if res.Dedup {
+		dedup = "hit"
+	}
+	w.Header().Set(DedupHeader, dedup)
+	w.Header().Set(BlobIDHeader, strconv.FormatInt(res.BlobID, 10))
 }
 
-// Routes vytvoří router a zaregistruje cesty
+// newUploadResponse builds the upload response; with verbose it adds the stored blob details so
+// clients don't need a follow-up info call. A failed lookup only logs – the upload itself succeeded.
+func (s *Server) newUploadResponse(res service.UploadResult, verbose bool) UploadResponse {
+	resp := UploadResponse{
+		FileID:    res.FileID,
+		CumulusID: fmt.Sprintf("%d", res.OldCumulusID),
+	}
+	if !verbose {
+		return resp
+	}
+
+	info, err := s.FileService.GetFileInfo(res.FileID, false)
+	if err != nil {
+		utils.Warn("UPLOAD", "Verbose response: file info lookup failed: file_id=%s, error=%v", res.FileID, err)
+		return resp
+	}
+	resp.Hash = info.Hash
+	resp.SizeRaw = &info.SizeRaw
+	resp.SizeCompressed = &info.SizeCompressed
+	resp.Dedup = &res.Dedup
+	resp.MimeType = info.MimeType
+	resp.Image = info.Image
+	resp.ExpiresAt = info.ExpiresAt
+	return resp
+}
+
+// Routes vytvoří router a zaregistruje cesty. Vzory jsou ve tvaru "METODA /cesta/{param}" (Go 1.22
+// ServeMux): konkrétnější vzor vyhrává nad obecnějším (/base/files/delete/{uuid} vs /base/files/{uuid}),
+// parametry handlery čtou přes r.PathValue a na jinou metodu mux odpoví 405 s hlavičkou Allow.
+// Každá skupina cest má vlastní řetězec middlewarů (ROUTE_MIDDLEWARE), metriky a recovery obalují vše.
+// Na read-only replice (READ_ONLY) zapisující cesty odpovídají 405.
 func (s *Server) Routes() http.Handler {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/health", s.HandleHealth)
-	mux.Handle("/metrics", promhttp.Handler())
-
-	mux.HandleFunc("/base/files/old/", s.HandleBaseDownloadByOldID)
-	mux.HandleFunc("/base/files/old/info/", s.HandleBaseFileInfoByOldID)
-	mux.HandleFunc("/base/files/delete/", s.HandleBaseDelete)
-	mux.HandleFunc("/base/files/delete", s.HandleBaseDelete)
-	mux.HandleFunc("/base/files/", s.HandleBaseDownload)
-	mux.HandleFunc("/base/files/upload/", s.HandleBaseUpload)
-	mux.HandleFunc("/base/files/upload", s.HandleBaseUpload)
-	mux.HandleFunc("/base/files/info/", s.HandleBaseFileInfo)
-
-	mux.HandleFunc("/v2/files/upload/", s.HandleV2Upload)
-	mux.HandleFunc("/v2/files/upload", s.HandleV2Upload)
-	mux.HandleFunc("/v2/files/", s.HandleV2Download)
-	mux.HandleFunc("/v2/files/info/", s.HandleV2FileInfo)
-	mux.HandleFunc("/v2/files/old/", s.HandleV2DownloadByOldID)
-	mux.HandleFunc("/v2/files/old/info/", s.HandleV2FileInfoByOldID)
-
-	mux.HandleFunc("/v2/images/", s.HandleV2Image)
-
-	mux.HandleFunc("/docs/", httpSwagger.WrapHandler)
+	routes := newRouteTable(s.ReadOnly)
+
+	public := s.newRouteGroup(routes, RouteGroupPublic)
+	public.handleFunc("GET /health", s.HandleHealth)
+	public.handle("GET /metrics", promhttp.Handler())
+	public.handleFunc("GET /metrics/alerts.yaml", s.HandleMetricsAlerts)
+	public.handleFunc("GET /docs/", httpSwagger.WrapHandler)
+	public.handleFunc("GET /openapi.json", s.HandleOpenAPI)
+	public.handleFunc("GET /admin/icons/{name}", s.HandleAdminIcons)
+
+	files := s.newRouteGroup(routes, RouteGroupFiles)
+	files.handleFunc("GET /base/files/{uuid}", s.HandleBaseDownload)
+	files.handleFunc("GET /base/files/info/{uuid}", s.HandleBaseFileInfo)
+	files.handleFunc("GET /base/files/old/{cumulus_id}", s.HandleBaseDownloadByOldID)
+	files.handleFunc("GET /base/files/old/info/{cumulus_id}", s.HandleBaseFileInfoByOldID)
+	files.handleFunc("POST /base/files/old/exists", s.HandleBaseOldIDsExist)
+	files.handleFunc("GET /base/files/old/by-label/{label}", s.HandleBaseFilesByLabel)
+	files.handleFunc("DELETE /base/files/delete/{uuid}", s.HandleBaseDelete)
+	files.handleFunc("POST /base/files/delete/{uuid}", s.HandleBaseDelete)
+	files.handleFunc("POST /base/files/upload", s.HandleBaseUpload)
+	files.handleFunc("POST /base/files/upload/{$}", s.HandleBaseUpload)
+
+	files.handleFunc("POST /v2/files/upload", s.HandleV2Upload)
+	files.handleFunc("POST /v2/files/upload/{$}", s.HandleV2Upload)
+	files.handleFunc("PUT /v2/files/upload", s.HandleV2RawUpload)
+	files.handleFunc("PUT /v2/files/{name}", s.HandleV2RawUploadNamed)
+	files.handleFunc("POST /v2/uploads", s.HandleV2UploadSessionCreate)
+	files.handleFunc("PATCH /v2/uploads/{id}", s.HandleV2UploadSessionPatch)
+	files.handleFunc("GET /v2/uploads/{id}", s.HandleV2UploadSession)
+	files.handleFunc("DELETE /v2/uploads/{id}", s.HandleV2UploadSessionDelete)
+	files.handleFunc("GET /v2/files/{uuid}", s.HandleV2Download)
+	// /v2/files/{uuid}/hash nejde zaregistrovat přímo, kolidoval by s /v2/files/info/{uuid}
+	// (oba odpovídají /v2/files/info/hash) – handler sám ověří poslední segment
+	files.handleFunc("GET /v2/files/{uuid}/{sub}", s.HandleV2FileHash)
+	files.handleFunc("POST /v2/files/{uuid}/presign", s.HandleV2Presign)
+	files.handleFunc("GET /v2/files/info/{uuid}", s.HandleV2FileInfo)
+	files.handleFunc("GET /v2/files/old/{cumulus_id}", s.HandleV2DownloadByOldID)
+	files.handleFunc("GET /v2/files/old/info/{cumulus_id}", s.HandleV2FileInfoByOldID)
+	files.handleFunc("GET /v2/files/old/by-label/{label}", s.HandleV2FilesByLabel)
+	files.handleFunc("POST /v2/files/validate", s.HandleV2ValidateUpload)
+	files.handleFunc("GET /v2/policy", s.HandleV2Policy)
+	files.handleFunc("POST /v2/files/tags", s.HandleV2BatchTags)
+	files.handleFunc("POST /v2/blobs/lookup", s.HandleV2BlobLookup)
+	files.handleFunc("POST /v2/files/prefetch", s.HandleV2Prefetch)
+	files.handleFunc("GET /v2/files/prefetch/{id}", s.HandleV2PrefetchJob)
+	files.handleFunc("GET /v2/files/archive.tar", s.HandleV2ArchiveTar)
+	files.handleFunc("POST /v2/files/tmp", s.HandleV2TempUpload)
+	files.handleFunc("GET /v2/files/tmp", s.HandleV2TempFiles)
+	files.handleFunc("GET /v2/files/list", s.HandleV2FileList)
+	files.handleFunc("GET /v2/tags", s.HandleV2Tags)
+
+	images := s.newRouteGroup(routes, RouteGroupImages)
+	images.handleFunc("GET /v2/images/{uuid}", s.HandleV2Image)
+	images.handleFunc("GET /v2/images/{uuid}/{variant}", s.HandleV2Image)
+	images.handleFunc("GET /v2/images/old/{cumulus_id}", s.HandleV2ImageByOldID)
+	images.handleFunc("GET /v2/images/old/{cumulus_id}/{variant}", s.HandleV2ImageByOldID)
 
 	// System API endpoints
-	mux.HandleFunc("/system/stats", s.HandleSystemStats)
-	mux.HandleFunc("/system/volumes", s.HandleSystemVolumes)
-	mux.HandleFunc("/system/compact", s.HandleSystemCompact)
-	mux.HandleFunc("/system/jobs", s.HandleSystemJobs)
-	mux.HandleFunc("/system/integrity", s.HandleSystemIntegrity)
-
-	// Admin UI (protected with basic auth)
-	username, password := GetAdminCredentials()
-	mux.Handle("/admin", AdminAuthMiddleware(username, password, http.HandlerFunc(s.HandleAdmin)))
-	mux.Handle("/admin/script.js", AdminAuthMiddleware(username, password, http.HandlerFunc(s.HandleAdminScript)))
-	mux.HandleFunc("/admin/icons/", s.HandleAdminIcons)
+	system := s.newRouteGroup(routes, RouteGroupSystem)
+	system.handleFunc("GET /system/stats", s.HandleSystemStats)
+	system.handleFunc("GET /system/volumes", s.HandleSystemVolumes)
+	system.handleFunc("GET /system/volumes/{id}/manifest", s.HandleSystemVolumeManifest)
+	system.handleFunc("POST /system/compact", s.HandleSystemCompact)
+	system.handleFunc("GET /system/jobs", s.HandleSystemJobs)
+	system.handleFunc("GET /system/integrity", s.HandleSystemIntegrity)
+	system.handleFunc("GET /system/usage", s.HandleSystemUsage)
+	system.handleFunc("GET /system/blobs/{id}/verify", s.HandleSystemBlobVerify)
+	system.handleFunc("GET /system/reports/latest", s.HandleSystemReportsLatest)
+	system.handleFunc("GET /system/slo", s.HandleSystemSLO)
+	system.handleFunc("GET /system/forecast", s.HandleSystemForecast)
+	system.handleFunc("GET /system/heal", s.HandleSystemHeal)
+	system.handleFunc("GET /system/compression", s.HandleSystemCompression)
+	system.handleFunc("GET /system/dedup", s.HandleSystemDedup)
+	system.handleFunc("GET /system/cluster", s.HandleSystemCluster)
+	system.handleFunc("GET /system/slow-queries", s.HandleSystemSlowQueries)
+	system.handleFunc("GET /system/backup", s.HandleSystemBackup)
+
+	// Admin UI a operace nad soubory (vždy za Basic auth)
+	admin := s.newRouteGroup(routes, RouteGroupAdmin)
+	admin.handleFunc("GET /admin", s.HandleAdmin)
+	admin.handleFunc("GET /admin/script.js", s.HandleAdminScript)
+	admin.handleFunc("GET /admin/api/activity", s.HandleAdminActivity)
+	admin.handleFunc("POST /system/files/{id}/redetect", s.HandleSystemFileRedetect)
+	admin.handleFunc("POST /system/files/{id}/recompress", s.HandleSystemFileRecompress)
+	admin.handleFunc("POST /system/files/{id}/move", s.HandleSystemFileMove)
+	admin.handleFunc("POST /system/files/{id}/variants", s.HandleSystemFileVariants)
+	admin.handleFunc("POST /system/files/{id}/pin", s.HandleSystemFilePin)
+	admin.handleFunc("POST /system/files/{id}/unpin", s.HandleSystemFileUnpin)
+	admin.handleFunc("GET /system/files/pinned", s.HandleSystemPinnedFiles)
+	admin.handleFunc("POST /system/files/{id}/hold", s.HandleSystemFileHold)
+	admin.handleFunc("POST /system/files/{id}/release", s.HandleSystemFileRelease)
+	admin.handleFunc("GET /system/files/held", s.HandleSystemLegalHolds)
+	admin.handleFunc("GET /system/files/type-mismatches", s.HandleSystemTypeMismatches)
+	admin.handleFunc("POST /system/redetect", s.HandleSystemRedetect)
+	admin.handleFunc("POST /system/blobs/{id}/move", s.HandleSystemBlobMove)
+	admin.handleFunc("POST /system/volumes/state", s.HandleSystemVolumeState)
+	admin.handleFunc("POST /system/files/hashes", s.HandleSystemFileHashes)
+	admin.handleFunc("GET /system/replication", s.HandleSystemReplication)
+	admin.handleFunc("POST /system/volumes/{id}/drain", s.HandleSystemVolumeDrain)
+	admin.handleFunc("POST /system/takeout", s.HandleSystemTakeout)
+	admin.handleFunc("POST /system/purge", s.HandleSystemPurge)
+	admin.handleFunc("GET /admin/api-keys", s.HandleAdminAPIKeys)
+	admin.handleFunc("POST /admin/api-keys", s.HandleAdminAPIKeyCreate)
+	admin.handleFunc("DELETE /admin/api-keys/{id}", s.HandleAdminAPIKeyRevoke)
+	admin.handleFunc("GET /system/usage/keys", s.HandleSystemUsageKeys)
+
+	return Chain(MetricsMiddleware, RecoveryMiddleware)(routes)
+}
 
-	// Wrap with metrics middleware
-	return MetricsMiddleware(mux)
+// pathInt64 reads a numeric path parameter (e.g. {cumulus_id})
+func pathInt64(r *http.Request, name string) (int64, error) {
+	return strconv.ParseInt(r.PathValue(name), 10, 64)
 }
 
 // **********************************************************************************************************
 
 func (s *Server) HandleUploadFunc(w http.ResponseWriter, r *http.Request) {
+	s.handleUpload(w, r, uploadScope{})
+}
+
+// handleUpload stores the uploaded file(s); scope adds the defaults of a dedicated upload endpoint
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request, scope uploadScope) {
 	timer := prometheus.NewTimer(uploadDuration)
 	defer timer.ObserveDuration()
 
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	verbose, err := parseVerbose(r)
+	if err != nil {
+		http.Error(w, "Invalid verbose parameter", http.StatusBadRequest)
 		return
 	}
 
+	// Sync-style klienti mohou poslat If-None-Match s hashem obsahu a ušetřit upload
+	if hash := parseHashPrecondition(r.Header.Get("If-None-Match")); hash != "" {
+		if s.handleConditionalUpload(w, r, hash, verbose, scope) {
+			return
+		}
+	}
+
 	r.Body = http.MaxBytesReader(w, r.Body, s.MaxUploadSize)
 	if err := r.ParseMultipartForm(s.MaxUploadSize); err != nil {
+		if isUploadTooLarge(err) {
+			s.writeUploadTooLarge(w, r, "multipart")
+			return
+		}
 		utils.Info("UPLOAD", "Failed to parse form from %s: %v", r.RemoteAddr, err)
-		http.Error(w, "File too large or invalid form", http.StatusBadRequest)
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
 		return
 	}
 
-	file, header, err := r.FormFile("file")
-	if err != nil {
-		utils.Info("UPLOAD", "Error retrieving file from %s: %v", r.RemoteAddr, err)
+	parts := r.MultipartForm.File["file"]
+	if len(parts) == 0 {
+		utils.Info("UPLOAD", "Error retrieving file from %s: no file part", r.RemoteAddr)
 		http.Error(w, "Error retrieving file", http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
+
+	opts, ok := s.parseUploadOptions(w, r, scope)
+	if !ok {
+		return
+	}
+	if len(parts) > 1 {
+		s.handleBatchUpload(w, r, parts, opts, verbose)
+		return
+	}
+	header := parts[0]
 
 	// Process optional fields
 	var oldCumulusID *int64
@@ -113,186 +325,365 @@ func (s *Server) HandleUploadFunc(w http.ResponseWriter, r *http.Request) {
 		utils.Info("UPLOAD", "No old_cumulus_id provided by %s", r.RemoteAddr)
 	}
 
-	var expiresAt *time.Time
-	if val := r.FormValue("validity"); val != "" {
-		exp, err := utils.ParseValidity(val)
+	// Explicit content_type field (e.g. original type from migration) wins over the part header;
+	// it is only used when magic-byte detection yields generic binary.
+	contentType := header.Header.Get("Content-Type")
+	if val := r.FormValue("content_type"); val != "" {
+		mediaType, err := parseContentTypeField(val)
 		if err != nil {
-			http.Error(w, "Invalid validity format: "+err.Error(), http.StatusBadRequest)
+			http.Error(w, "Invalid content_type", http.StatusBadRequest)
 			return
 		}
-		expiresAt = &exp
+		contentType = mediaType
+	}
+
+	res, err := s.storeUploadedPart(r, header, oldCumulusID, contentType, opts)
+	if err != nil {
+		status, msg := uploadErrorStatus(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setDedupHeaders(w, res)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s.newUploadResponse(res, verbose))
+}
+
+// uploadOptions are the form fields of an upload request shared by all its files
+type uploadOptions struct {
+	onConflict  service.OldIDConflictMode
+	disposition string
+	expiresAt   *time.Time
+	createdAt   *time.Time
+	tags        string // JSON array
+}
+
+// parseUploadOptions parses the shared upload form fields; on error it writes the response
+func (s *Server) parseUploadOptions(w http.ResponseWriter, r *http.Request, scope uploadScope) (uploadOptions, bool) {
+	var opts uploadOptions
+	var err error
+
+	opts.onConflict, err = service.ParseOldIDConflictMode(r.FormValue("on_conflict"))
+	if err != nil {
+		http.Error(w, "Invalid on_conflict: "+err.Error(), http.StatusBadRequest)
+		return opts, false
+	}
+	opts.disposition, err = service.ParseDisposition(r.FormValue("disposition"))
+	if err != nil {
+		http.Error(w, "Invalid disposition: "+err.Error(), http.StatusBadRequest)
+		return opts, false
+	}
+
+	opts.expiresAt, err = s.parseExpiry(r.FormValue("validity"), r.FormValue("expires_at"), scope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return opts, false
+	}
+
+	// created_at (původní datum vzniku) smí nastavit jen admin/migrace
+	if val := r.FormValue("created_at"); val != "" {
+		if !isAdminRequest(r) {
+			utils.Warn("UPLOAD", "created_at rejected: missing admin credentials, remote=%s", r.RemoteAddr)
+			http.Error(w, "created_at requires admin credentials", http.StatusForbidden)
+			return opts, false
+		}
+		ts, err := parseCreatedAt(val)
+		if err != nil {
+			http.Error(w, "Invalid created_at format: "+err.Error(), http.StatusBadRequest)
+			return opts, false
+		}
+		opts.createdAt = &ts
 	}
 
 	// Process tags – each form value may itself contain comma-separated tags
 	// (legacy client support). Tags are stored as a JSON array to allow arbitrary
 	// characters (including commas) in tag values.
-	var tags []string
-	if values, ok := r.Form["tags"]; ok {
-		for _, v := range values {
-			for _, part := range strings.Split(v, ",") {
-				trimmed := strings.TrimSpace(part)
-				if trimmed != "" {
-					tags = append(tags, trimmed)
-				}
-			}
-		}
+	opts.tags = storage.TagsToJSON(scope.withTags(parseTagValues(r.Form["tags"])))
+	return opts, true
+}
+
+// parseContentTypeField validates the content_type form field and returns its media type
+func parseContentTypeField(val string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(val)
+	if err == nil && !strings.Contains(mediaType, "/") {
+		err = fmt.Errorf("not a MIME type: %s", val)
 	}
-	tagsStr := storage.TagsToJSON(tags)
+	return mediaType, err
+}
 
-	cleanFilename := filepath.Base(header.Filename)
+// storeUploadedPart stores one file part of an upload request and records the upload metrics
+func (s *Server) storeUploadedPart(r *http.Request, header *multipart.FileHeader, oldCumulusID *int64, contentType string, opts uploadOptions) (service.UploadResult, error) {
+	file, err := header.Open()
+	if err != nil {
+		utils.Info("UPLOAD", "Error retrieving file from %s: %v", r.RemoteAddr, err)
+		return service.UploadResult{}, err
+	}
+	defer file.Close()
+	return s.storeUploadedFile(r, file, filepath.Base(header.Filename), header.Size, oldCumulusID, contentType, opts)
+}
+
+// storeUploadedFile stores the content read from file and records the upload metrics; size is
+// only logged (-1 when unknown, e.g. a chunked raw upload)
+func (s *Server) storeUploadedFile(r *http.Request, file io.Reader, cleanFilename string, size int64, oldCumulusID *int64, contentType string, opts uploadOptions) (service.UploadResult, error) {
 	utils.Info("UPLOAD", "Starting upload: filename=%s, content_type=%s, size=%d, old_id=%v, expires=%v, tags=%s, remote=%s",
-		cleanFilename, header.Header.Get("Content-Type"), header.Size, oldCumulusID, expiresAt, tagsStr, r.RemoteAddr)
+		cleanFilename, contentType, size, oldCumulusID, opts.expiresAt, opts.tags, r.RemoteAddr)
 
 	// Determine file type for metrics
-	contentType := header.Header.Get("Content-Type")
 	fileTypeLabel := "unknown"
 	if parts := strings.Split(contentType, "/"); len(parts) > 0 {
 		fileTypeLabel = parts[0]
 	}
 
 	// Call FileService
-	fileID, assignedOldID, isDedup, err := s.FileService.UploadFileWithDedup(file, cleanFilename, contentType, oldCumulusID, expiresAt, tagsStr)
+	body := &countingReadCloser{ReadCloser: io.NopCloser(file)}
+	res, err := s.FileService.UploadFileWithResult(body, cleanFilename, contentType, oldCumulusID, opts.expiresAt, opts.createdAt, opts.tags, opts.disposition, opts.onConflict)
 	if err != nil {
 		uploadOpsTotal.WithLabelValues("error", fileTypeLabel).Inc()
 		utils.Info("UPLOAD", "ERROR: filename=%s, remote=%s, error=%v", cleanFilename, r.RemoteAddr, err)
-		if errors.Is(err, service.ErrOldCumulusIDConflict) {
-			http.Error(w, "Conflict: old_cumulus_id already assigned to a different file", http.StatusConflict)
-		} else {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		}
-		return
+		return service.UploadResult{}, err
 	}
 
 	uploadOpsTotal.WithLabelValues("success", fileTypeLabel).Inc()
-	RecordBlobBytesWritten(header.Size)
-	if isDedup {
+	RecordBlobBytesWritten(body.n.Load())
+	if res.Dedup {
 		dedupHitsTotal.Inc()
 	}
-	utils.Info("UPLOAD", "SUCCESS: filename=%s, file_id=%s, dedup=%v, remote=%s", cleanFilename, fileID, isDedup, r.RemoteAddr)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(UploadResponse{
-		FileID:    fileID,
-		CumulusID: fmt.Sprintf("%d", assignedOldID),
-	})
+	recordUploadedFile(r, res.MimeType)
+	utils.Info("UPLOAD", "SUCCESS: filename=%s, file_id=%s, dedup=%v, remote=%s", cleanFilename, res.FileID, res.Dedup, r.RemoteAddr)
+	return res, nil
 }
 
-func (s *Server) HandleDownloadFunc(w http.ResponseWriter, r *http.Request, path string) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (s *Server) HandleDownloadFunc(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		s.headDownload(w, r)
 		return
 	}
-
-	// Extract ID from URL
-	// URL is /v2/files/{id}
-	id := strings.TrimPrefix(r.URL.Path, path)
-	if id == "" || id == "/" {
-		utils.Info("DOWNLOAD", "Missing file ID from %s", r.RemoteAddr)
-		http.Error(w, "Missing file ID", http.StatusBadRequest)
-		return
-	}
-
+	id := r.PathValue("uuid")
 	utils.Info("DOWNLOAD", "Requesting file_id=%s, remote=%s", id, r.RemoteAddr)
-	rc, sizeRaw, filename, mimeType, err := s.FileService.DownloadFile(id)
+	if s.AccelMode != "" && s.AccelMode != AccelModeOff {
+		if loc, err := s.FileService.LocateFile(id); err == nil && s.tryAccelRedirect(w, r, loc, false) {
+			recordExpiredServed("download", loc.ExpiresAt)
+			utils.Info("DOWNLOAD", "ACCEL: file_id=%s, filename=%s, size=%d, mode=%s, remote=%s", id, loc.Filename, loc.SizeRaw, s.AccelMode, r.RemoteAddr)
+			return
+		}
+	}
+	rc, dl, err := s.FileService.DownloadFile(id)
 	if err != nil {
 		if errors.Is(err, service.ErrNotFound) {
+			if s.Cluster.Forward(w, r, id) {
+				return
+			}
 			utils.Info("DOWNLOAD", "File not found: file_id=%s, remote=%s", id, r.RemoteAddr)
 			http.Error(w, "File not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, service.ErrExpired) {
+			utils.Info("DOWNLOAD", "File expired: file_id=%s, remote=%s", id, r.RemoteAddr)
+			writeExpired(w, "download")
+			return
+		}
 		utils.Info("DOWNLOAD", "ERROR: file_id=%s, remote=%s, error=%v", id, r.RemoteAddr, err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 	defer rc.Close()
 
-	w.Header().Set("Content-Type", mimeType)
-	encodedFilename := url.PathEscape(filename)
-
-	// Determine disposition based on mime type
-	disposition := "attachment"
-	if strings.HasPrefix(mimeType, "image/") ||
-		strings.HasPrefix(mimeType, "video/") ||
-		strings.HasPrefix(mimeType, "audio/") ||
-		mimeType == "application/pdf" ||
-		mimeType == "text/plain" {
-		disposition = "inline"
+	s.setDownloadHeaders(w, dl, false)
+	if etagMatches(r, dl.Hash) {
+		writeNotModified(w)
+		return
 	}
-
-	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=\"%s\"; filename*=UTF-8''%s", disposition, filename, encodedFilename))
-	w.Header().Set("Content-Length", strconv.FormatInt(sizeRaw, 10))
-	n, _ := io.Copy(w, rc)
+	n, err := serveDownload(w, r, rc, dl)
 	RecordBlobBytesRead(int(n))
-	utils.Info("DOWNLOAD", "SUCCESS: file_id=%s, filename=%s, size=%d, mime=%s, remote=%s", id, filename, sizeRaw, mimeType, r.RemoteAddr)
+	if err != nil {
+		// Hlavičky už odešly: klient pozná chybu podle spojení ukončeného před Content-Length
+		utils.Error("DOWNLOAD", "Streaming failed: file_id=%s, sent=%d of %d, remote=%s, error=%v", id, n, dl.SizeRaw, r.RemoteAddr, err)
+		return
+	}
+	recordExpiredServed("download", dl.ExpiresAt)
+	utils.Info("DOWNLOAD", "SUCCESS: file_id=%s, filename=%s, size=%d, mime=%s, remote=%s", id, dl.Filename, dl.SizeRaw, dl.MimeType, r.RemoteAddr)
 }
 
-func (s *Server) HandleDownloadByOldIDFunc(w http.ResponseWriter, r *http.Request, path string) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (s *Server) HandleDownloadByOldIDFunc(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		s.headDownloadByOldID(w, r)
 		return
 	}
 	utils.Info("TEMP_DOWNLOAD_OLD_ID", "Handler invoked from %s", r.URL.Path)
-	idStr := strings.TrimPrefix(r.URL.Path, path)
-	if idStr == "" || idStr == "/" {
-		http.Error(w, "Missing file ID", http.StatusBadRequest)
-		return
-	}
-
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	id, err := pathInt64(r, "cumulus_id")
 	if err != nil {
-		utils.Info("DOWNLOAD_OLD_ID", "Invalid ID format: id=%s, remote=%s, error=%v", idStr, r.RemoteAddr, err)
+		utils.Info("DOWNLOAD_OLD_ID", "Invalid ID format: id=%s, remote=%s, error=%v", r.PathValue("cumulus_id"), r.RemoteAddr, err)
 		http.Error(w, "Invalid file ID", http.StatusBadRequest)
 		return
 	}
 
 	utils.Info("DOWNLOAD_OLD_ID", "Requesting old_id=%d, remote=%s", id, r.RemoteAddr)
-	rc, sizeRaw, filename, mimeType, err := s.FileService.DownloadFileByOldID(id)
+	if s.AccelMode != "" && s.AccelMode != AccelModeOff {
+		if loc, err := s.FileService.LocateFileByOldID(id); err == nil && s.tryAccelRedirect(w, r, loc, true) {
+			recordExpiredServed("download", loc.ExpiresAt)
+			utils.Info("DOWNLOAD_OLD_ID", "ACCEL: old_id=%d, filename=%s, size=%d, mode=%s, remote=%s", id, loc.Filename, loc.SizeRaw, s.AccelMode, r.RemoteAddr)
+			return
+		}
+	}
+	rc, dl, err := s.FileService.DownloadFileByOldID(id)
 	if err != nil {
 		if errors.Is(err, service.ErrNotFound) {
 			utils.Info("DOWNLOAD_OLD_ID", "File not found: old_id=%d, remote=%s", id, r.RemoteAddr)
 			http.Error(w, "File not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, service.ErrExpired) {
+			utils.Info("DOWNLOAD_OLD_ID", "File expired: old_id=%d, remote=%s", id, r.RemoteAddr)
+			writeExpired(w, "download")
+			return
+		}
 		utils.Info("DOWNLOAD_OLD_ID", "ERROR: old_id=%d, remote=%s, error=%v", id, r.RemoteAddr, err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 	defer rc.Close()
 
-	w.Header().Set("Content-Type", mimeType)
-	encodedFilename := url.PathEscape(filename)
+	s.setDownloadHeaders(w, dl, true)
+	if etagMatches(r, dl.Hash) {
+		writeNotModified(w)
+		return
+	}
+	n, err := serveDownload(w, r, rc, dl)
+	RecordBlobBytesRead(int(n))
+	if err != nil {
+		// Hlavičky už odešly: klient pozná chybu podle spojení ukončeného před Content-Length
+		utils.Error("DOWNLOAD_OLD_ID", "Streaming failed: old_id=%d, sent=%d of %d, remote=%s, error=%v", id, n, dl.SizeRaw, r.RemoteAddr, err)
+		return
+	}
+	recordExpiredServed("download", dl.ExpiresAt)
+	utils.Info("DOWNLOAD_OLD_ID", "SUCCESS: old_id=%d, filename=%s, size=%d, mime=%s, remote=%s", id, dl.Filename, dl.SizeRaw, dl.MimeType, r.RemoteAddr)
+}
 
-	// Determine disposition based on mime type
-	disposition := "attachment"
-	if strings.HasPrefix(mimeType, "image/") ||
-		strings.HasPrefix(mimeType, "video/") ||
-		strings.HasPrefix(mimeType, "audio/") ||
-		mimeType == "application/pdf" ||
-		mimeType == "text/plain" {
-		disposition = "inline"
+// serveDownload writes the file content. Content of a local volume (dl.Ranges) is seekable and served
+// with http.ServeContent, which answers Range and If-Range (Accept-Ranges: bytes, 206, 416); content
+// from the read fallback is sent whole. Returns the bytes read and the read error, the response
+// may already be sent partly then.
+func serveDownload(w http.ResponseWriter, r *http.Request, rc io.ReadCloser, dl *service.FileDownload) (int64, error) {
+	rs, ok := rc.(io.ReadSeeker)
+	if !ok || !dl.Ranges {
+		w.Header().Set("Accept-Ranges", "none")
+		w.Header().Set("Content-Length", strconv.FormatInt(dl.SizeRaw, 10))
+		return io.Copy(w, rc)
 	}
+	content := &countingReadSeeker{ReadSeeker: rs}
+	http.ServeContent(w, r, "", time.Time{}, content)
+	return content.n, content.err
+}
 
-	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=\"%s\"; filename*=UTF-8''%s", disposition, filename, encodedFilename))
-	w.Header().Set("Content-Length", strconv.FormatInt(sizeRaw, 10))
-	n, _ := io.Copy(w, rc)
-	RecordBlobBytesRead(int(n))
-	utils.Info("DOWNLOAD_OLD_ID", "SUCCESS: old_id=%d, filename=%s, size=%d, mime=%s, remote=%s", id, filename, sizeRaw, mimeType, r.RemoteAddr)
+// countingReadSeeker counts the bytes read and keeps the read error, which http.ServeContent drops
+type countingReadSeeker struct {
+	io.ReadSeeker
+	n   int64
+	err error
 }
 
-func (s *Server) HandleFileInfoFunc(w http.ResponseWriter, r *http.Request, path string) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+func (c *countingReadSeeker) Read(p []byte) (int, error) {
+	n, err := c.ReadSeeker.Read(p)
+	c.n += int64(n)
+	if err != nil && err != io.EOF {
+		c.err = err
 	}
+	return n, err
+}
+
+// parseCreatedAt parses an original creation timestamp: RFC 3339, MySQL DATETIME
+// ("2006-01-02 15:04:05", local time) or unix seconds. Future timestamps are rejected.
+func parseCreatedAt(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	var ts time.Time
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		ts = t
+	} else if t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local); err == nil {
+		ts = t
+	} else if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		ts = time.Unix(sec, 0)
+	} else {
+		return time.Time{}, fmt.Errorf("use RFC 3339, 'YYYY-MM-DD HH:MM:SS' or unix seconds")
+	}
+	if ts.After(time.Now().Add(time.Hour)) {
+		return time.Time{}, fmt.Errorf("created_at is in the future")
+	}
+	return ts, nil
+}
 
-	fileID := strings.TrimPrefix(r.URL.Path, path)
-	if fileID == "" || fileID == "/" {
-		utils.Info("FILE_INFO", "Missing file ID from %s", r.RemoteAddr)
-		http.Error(w, "Missing file ID", http.StatusBadRequest)
+// parseTagValues splits tag form values; each value may itself contain
+// comma-separated tags (legacy client support).
+func parseTagValues(values []string) []string {
+	var tags []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			trimmed := strings.TrimSpace(part)
+			if trimmed != "" {
+				tags = append(tags, trimmed)
+			}
+		}
+	}
+	return tags
+}
+
+// writeExpired answers a read of a file denied by the expiry policy (EXPIRED_ACCESS=deny)
+func writeExpired(w http.ResponseWriter, kind string) {
+	RecordExpiredAccess(kind, true)
+	http.Error(w, "File expired", http.StatusGone)
+}
+
+// recordExpiredServed counts a successful read of a file past its expiry (allowed or in the grace period)
+func recordExpiredServed(kind string, expiresAt *time.Time) {
+	if service.IsExpired(expiresAt) {
+		RecordExpiredAccess(kind, false)
+	}
+}
+
+// contentDisposition builds the Content-Disposition header for a download.
+// A disposition stored with the file wins; otherwise types the browser can display are served
+// inline, everything else as attachment.
+func contentDisposition(stored, mimeType, filename string) string {
+	disposition := stored
+	if disposition == service.DispositionAuto {
+		disposition = service.DispositionAttachment
+		if strings.HasPrefix(mimeType, "image/") ||
+			strings.HasPrefix(mimeType, "video/") ||
+			strings.HasPrefix(mimeType, "audio/") ||
+			mimeType == "application/pdf" ||
+			mimeType == "text/plain" {
+			disposition = service.DispositionInline
+		}
+	}
+	return fmt.Sprintf("%s; filename=\"%s\"; filename*=UTF-8''%s", disposition, filename, url.PathEscape(filename))
+}
+
+func (s *Server) HandleFileHashFunc(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("uuid")
+	withSHA256 := r.URL.Query().Get("sha256") == "true"
+	hash, err := s.FileService.GetFileHash(id, withSHA256)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			if s.Cluster.Forward(w, r, id) {
+				return
+			}
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		utils.Info("FILE_HASH", "ERROR: file_id=%s, remote=%s, error=%v", id, r.RemoteAddr, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hash)
+}
+
+// downloadPath is the download prefix of the same API (e.g. /v2/files/), used for ?content=stream
+// and the Location of 413 responses.
+func (s *Server) HandleFileInfoFunc(w http.ResponseWriter, r *http.Request, downloadPath string) {
+	fileID := r.PathValue("uuid")
+
 	extendedStr := r.URL.Query().Get("extended")
 	extended := false
 	if extendedStr != "" {
@@ -304,36 +695,53 @@ func (s *Server) HandleFileInfoFunc(w http.ResponseWriter, r *http.Request, path
 		}
 	}
 
+	// ?content=stream: obsah nepatří do JSONu, klient dostane odkaz na download
+	if r.URL.Query().Get("content") == "stream" {
+		http.Redirect(w, r, downloadPath+url.PathEscape(fileID), http.StatusSeeOther)
+		return
+	}
+
 	info, err := s.FileService.GetFileInfo(fileID, extended)
 	if err != nil {
+		if errors.Is(err, service.ErrContentTooLarge) {
+			utils.Info("FILE_INFO", "Extended info refused: %v, remote=%s", err, r.RemoteAddr)
+			writeContentTooLarge(w, downloadPath+url.PathEscape(fileID))
+			return
+		}
 		if errors.Is(err, service.ErrNotFound) {
+			if s.Cluster.Forward(w, r, fileID) {
+				return
+			}
 			utils.Info("FILE_INFO", "File not found: file_id=%s, remote=%s", fileID, r.RemoteAddr)
 			http.Error(w, "File not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, service.ErrExpired) {
+			utils.Info("FILE_INFO", "Extended info of expired file refused: file_id=%s, remote=%s", fileID, r.RemoteAddr)
+			writeExpired(w, "info")
+			return
+		}
 		utils.Info("FILE_INFO", "ERROR: file_id=%s, remote=%s, error=%v", fileID, r.RemoteAddr, err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
+	if extended {
+		recordExpiredServed("info", info.ExpiresAt)
+	}
 	utils.Info("FILE_INFO", "SUCCESS: file_id=%s, extended=%v, remote=%s", fileID, extended, r.RemoteAddr)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(info)
 }
 
-func (s *Server) HandleFileInfoByOldIDFunc(w http.ResponseWriter, r *http.Request, path string) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	idStr := strings.TrimPrefix(r.URL.Path, path)
-	if idStr == "" || idStr == "/" {
-		http.Error(w, "Missing file ID", http.StatusBadRequest)
-		return
-	}
+// writeContentTooLarge answers an extended info request whose content exceeds the configured cap
+func writeContentTooLarge(w http.ResponseWriter, downloadURL string) {
+	w.Header().Set("Location", downloadURL)
+	http.Error(w, "Content too large for extended info, download it from "+downloadURL+" (or use ?content=stream)", http.StatusRequestEntityTooLarge)
+}
 
-	id, err := strconv.ParseInt(idStr, 10, 64)
+func (s *Server) HandleFileInfoByOldIDFunc(w http.ResponseWriter, r *http.Request, downloadPath string) {
+	id, err := pathInt64(r, "cumulus_id")
 	if err != nil {
 		http.Error(w, "Invalid file ID", http.StatusBadRequest)
 		return
@@ -350,35 +758,46 @@ func (s *Server) HandleFileInfoByOldIDFunc(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
+	// ?content=stream: obsah nepatří do JSONu, klient dostane odkaz na download
+	if r.URL.Query().Get("content") == "stream" {
+		http.Redirect(w, r, downloadPath+strconv.FormatInt(id, 10), http.StatusSeeOther)
+		return
+	}
+
 	info, err := s.FileService.GetFileInfoByOldID(id, extended)
 	if err != nil {
+		if errors.Is(err, service.ErrContentTooLarge) {
+			writeContentTooLarge(w, downloadPath+strconv.FormatInt(id, 10))
+			return
+		}
 		if errors.Is(err, service.ErrNotFound) {
 			http.Error(w, "File not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, service.ErrExpired) {
+			writeExpired(w, "info")
+			return
+		}
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	if extended {
+		recordExpiredServed("info", info.ExpiresAt)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(info)
 }
 
-func (s *Server) HandleDeleteFunc(w http.ResponseWriter, r *http.Request, path string) {
-	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	id := strings.TrimPrefix(r.URL.Path, path)
-	if id == "" {
-		utils.Info("DELETE", "Missing file ID from %s", r.RemoteAddr)
-		http.Error(w, "File ID is required", http.StatusBadRequest)
-		return
-	}
-
+func (s *Server) HandleDeleteFunc(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("uuid")
 	utils.Info("DELETE", "Deleting file_id=%s, remote=%s", id, r.RemoteAddr)
 	err := s.FileService.DeleteFile(id)
+	if errors.Is(err, storage.ErrLegalHold) {
+		utils.Info("DELETE", "REFUSED: file_id=%s, remote=%s, error=%v", id, r.RemoteAddr, err)
+		http.Error(w, "File is under legal hold", http.StatusLocked)
+		return
+	}
 	if err != nil {
 		utils.Info("DELETE", "ERROR: file_id=%s, remote=%s, error=%v", id, r.RemoteAddr, err)
 		http.Error(w, "Error deleting file", http.StatusInternalServerError)
@@ -390,86 +809,190 @@ func (s *Server) HandleDeleteFunc(w http.ResponseWriter, r *http.Request, path s
 	w.Write([]byte("File deleted successfully"))
 }
 
-func (s *Server) HandleImageFunc(w http.ResponseWriter, r *http.Request, path string) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// Cache-Control obrázků: obsah pod UUID se nemění, staré ID lze přesunout na jiný soubor
+// (on_conflict=supersede), proto bez immutable a s kratší dobou – ETag obsahuje UUID
+const (
+	imageCacheControl      = "public, max-age=2592000, immutable" // 30 dní
+	imageCacheControlOldID = "public, max-age=86400"              // 1 den
+)
+
+func (s *Server) HandleImageFunc(w http.ResponseWriter, r *http.Request) {
+	// /v2/images/{uuid} nebo /v2/images/{uuid}/{variant}
+	uuid, variant := r.PathValue("uuid"), r.PathValue("variant")
+	size, ok := s.parseImageVariant(w, r, uuid, variant)
+	if !ok {
 		return
 	}
+	s.serveImage(w, r, uuid, variant, size, imageCacheControl)
+}
 
-	// Parse URL: /v2/images/{uuid} nebo /v2/images/{uuid}/{variant}
-	urlPath := strings.TrimPrefix(r.URL.Path, path)
-	parts := strings.Split(urlPath, "/")
-
-	if len(parts) < 1 || parts[0] == "" {
-		utils.Info("IMAGE", "Missing UUID from %s", r.RemoteAddr)
-		http.Error(w, "Missing file UUID", http.StatusBadRequest)
+// HandleImageByOldIDFunc serves an image or its variant by the old Cumulus ID
+// (/v2/images/old/{cumulus_id}[/variant]) through the same pipeline as HandleImageFunc.
+func (s *Server) HandleImageByOldIDFunc(w http.ResponseWriter, r *http.Request) {
+	idStr, variant := r.PathValue("cumulus_id"), r.PathValue("variant")
+	oldID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		utils.Info("IMAGE_OLD_ID", "Invalid ID format: id=%s, remote=%s, error=%v", idStr, r.RemoteAddr, err)
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
 		return
 	}
-
-	uuid := parts[0]
-	variant := ""
-	if len(parts) > 1 {
-		variant = parts[1]
+	size, ok := s.parseImageVariant(w, r, idStr, variant)
+	if !ok {
+		return
 	}
 
-	// ETag pro cache - kombinace uuid a varianty
-	etag := fmt.Sprintf(`"%s-%s"`, uuid, variant)
-
-	// Kontrola If-None-Match pro 304 Not Modified
-	if match := r.Header.Get("If-None-Match"); match == etag {
-		w.WriteHeader(http.StatusNotModified)
+	uuid, err := s.FileService.ResolveOldID(oldID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			utils.Info("IMAGE_OLD_ID", "File not found: old_id=%d, remote=%s", oldID, r.RemoteAddr)
+			RecordImageRequest(variant, "unknown", "not_found")
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		utils.Info("IMAGE_OLD_ID", "ERROR resolving: old_id=%d, remote=%s, error=%v", oldID, r.RemoteAddr, err)
+		RecordImageRequest(variant, "unknown", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	utils.Info("IMAGE_OLD_ID", "Resolved: old_id=%d, uuid=%s, variant=%s, remote=%s", oldID, uuid, variant, r.RemoteAddr)
+	s.serveImage(w, r, uuid, variant, size, imageCacheControlOldID)
+}
 
-	// Validace varianty
-	var size *images.ImageSize
+// parseImageVariant validates the variant; on error it writes the response.
+// Custom dimensions ({width}x{height}) need a valid signature, see image_signing.go.
+func (s *Server) parseImageVariant(w http.ResponseWriter, r *http.Request, id, variant string) (*images.ImageSize, bool) {
 	switch variant {
 	case "":
 		// Originální obrázek, žádný resize
-		size = nil
+		return nil, true
 	case "thumb":
-		size = &images.SizeThumb
+		return &images.SizeThumb, true
 	case "sm":
-		size = &images.SizeSm
+		return &images.SizeSm, true
 	case "md":
-		size = &images.SizeMd
+		return &images.SizeMd, true
 	case "lg":
-		size = &images.SizeLg
-	default:
-		utils.Info("IMAGE", "Invalid variant: uuid=%s, variant=%s, remote=%s", uuid, variant, r.RemoteAddr)
-		http.Error(w, "Invalid variant. Use: thumb, sm, md, lg", http.StatusBadRequest)
+		return &images.SizeLg, true
+	}
+	if size, ok := parseCustomImageSize(variant); ok && len(s.ImageSigningKey) > 0 {
+		if !s.validImageSignature(r) {
+			utils.Warn("IMAGE", "Invalid signature: id=%s, variant=%s, remote=%s", id, variant, r.RemoteAddr)
+			RecordImageRequest(variant, "unknown", "forbidden")
+			http.Error(w, "Invalid signature", http.StatusForbidden)
+			return nil, false
+		}
+		return &size, true
+	}
+	utils.Info("IMAGE", "Invalid variant: id=%s, variant=%s, remote=%s", id, variant, r.RemoteAddr)
+	RecordImageRequest("invalid", "unknown", "bad_request")
+	http.Error(w, "Invalid variant. Use: thumb, sm, md, lg", http.StatusBadRequest)
+	return nil, false
+}
+
+// parsePDFOptions reads ?page= and ?dpi= of PDF previews
+func parsePDFOptions(r *http.Request) (images.PDFOptions, error) {
+	var opts images.PDFOptions
+	q := r.URL.Query()
+	if val := q.Get("page"); val != "" {
+		page, err := strconv.Atoi(val)
+		if err != nil || page < 1 {
+			return opts, errors.New("Invalid page")
+		}
+		opts.Page = page
+	}
+	if val := q.Get("dpi"); val != "" {
+		dpi, err := strconv.Atoi(val)
+		if err != nil || dpi < images.MinPDFDPI || dpi > images.MaxPDFDPI {
+			return opts, fmt.Errorf("Invalid dpi, use %d-%d", images.MinPDFDPI, images.MaxPDFDPI)
+		}
+		opts.DPI = dpi
+	}
+	return opts, nil
+}
+
+// imageErrorStatus maps an image processing error to the response status and metric label:
+// 422 for inputs over the size/pixel limits, 504 when processing timed out
+func imageErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, images.ErrImageTooLarge):
+		return http.StatusUnprocessableEntity, "too_large"
+	case errors.Is(err, images.ErrProcessingTimeout):
+		return http.StatusGatewayTimeout, "timeout"
+	}
+	return http.StatusInternalServerError, "error"
+}
+
+// serveImage returns the original image (size nil) or a resized variant / PDF thumbnail
+func (s *Server) serveImage(w http.ResponseWriter, r *http.Request, uuid, variant string, size *images.ImageSize, cacheControl string) {
+	pdfOpts, err := parsePDFOptions(r)
+	if err != nil {
+		RecordImageRequest(variant, "unknown", "bad_request")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// ETag pro cache - kombinace uuid a varianty (u PDF i stránky a DPI)
+	etag := fmt.Sprintf(`"%s-%s"`, uuid, variant)
+	if pdfOpts != (images.PDFOptions{}) {
+		etag = fmt.Sprintf(`"%s-%s-p%d-r%d"`, uuid, variant, pdfOpts.Page, pdfOpts.DPI)
+	}
+
+	// Kontrola If-None-Match pro 304 Not Modified
+	if match := r.Header.Get("If-None-Match"); match == etag {
+		RecordImageRequest(variant, "unknown", "not_modified")
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	utils.Info("IMAGE", "Requesting: uuid=%s, variant=%s, remote=%s", uuid, variant, r.RemoteAddr)
 
 	// Stáhneme originální soubor
-	rc, _, filename, mimeType, err := s.FileService.DownloadFile(uuid)
+	rc, dl, err := s.FileService.DownloadFile(uuid)
 	if err != nil {
 		if errors.Is(err, service.ErrNotFound) {
+			if s.Cluster.Forward(w, r, uuid) {
+				return
+			}
 			utils.Info("IMAGE", "File not found: uuid=%s, remote=%s", uuid, r.RemoteAddr)
+			RecordImageRequest(variant, "unknown", "not_found")
 			http.Error(w, "File not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, service.ErrExpired) {
+			utils.Info("IMAGE", "File expired: uuid=%s, remote=%s", uuid, r.RemoteAddr)
+			RecordImageRequest(variant, "unknown", "expired")
+			writeExpired(w, "image")
+			return
+		}
 		utils.Info("IMAGE", "ERROR downloading: uuid=%s, remote=%s, error=%v", uuid, r.RemoteAddr, err)
+		RecordImageRequest(variant, "unknown", "error")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 	defer rc.Close()
+	filename, mimeType := dl.Filename, dl.MimeType
 	// Image processing requires the full content in memory
 	data, err := io.ReadAll(rc)
 	if err != nil {
 		utils.Info("IMAGE", "ERROR reading file: uuid=%s, remote=%s, error=%v", uuid, r.RemoteAddr, err)
+		RecordImageRequest(variant, "unknown", "error")
 		http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordExpiredServed("image", dl.ExpiresAt)
 
 	// Kontrola, zda je to obrázek nebo PDF
 	isImage := images.IsImageMimeType(mimeType)
 	isPDF := images.IsPDFMimeType(mimeType)
 
+	source := "image"
+	if isPDF {
+		source = "pdf"
+	}
+
 	if !isImage && !isPDF {
 		utils.Info("IMAGE", "Not an image or PDF: uuid=%s, mime=%s, remote=%s", uuid, mimeType, r.RemoteAddr)
+		RecordImageRequest(variant, "other", "unsupported")
 		http.Error(w, "File is not an image or PDF", http.StatusUnsupportedMediaType)
 		return
 	}
@@ -477,24 +1000,37 @@ func (s *Server) HandleImageFunc(w http.ResponseWriter, r *http.Request, path st
 	// Pokud není specifikována varianta, vrátíme originální soubor
 	if size == nil {
 		utils.Info("IMAGE", "Returning original: uuid=%s, size=%d, remote=%s", uuid, len(data), r.RemoteAddr)
-		// Cache headers - originály jsou immutable (UUID se nemění)
-		w.Header().Set("Cache-Control", "public, max-age=2592000, immutable") // 30 dní
+		w.Header().Set("Cache-Control", cacheControl)
 		w.Header().Set("ETag", etag)
 		w.Header().Set("Content-Type", mimeType)
 		encodedFilename := url.PathEscape(filename)
 		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"; filename*=UTF-8''%s", filename, encodedFilename))
 		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 		w.Write(data)
+		RecordImageRequest(variant, source, "ok")
 		return
 	}
 
+	inputSize := len(data)
+	processingStart := time.Now()
+
 	// Pro PDF s variantou musíme vygenerovat náhled
 	if isPDF {
-		utils.Info("IMAGE", "Generating PDF thumbnail: uuid=%s, variant=%s, size=%dx%d", uuid, variant, size.Width, size.Height)
-		thumbnail, err := images.GeneratePDFThumbnail(data, *size)
+		utils.Info("IMAGE", "Generating PDF thumbnail: uuid=%s, variant=%s, size=%dx%d, page=%d, dpi=%d", uuid, variant, size.Width, size.Height, pdfOpts.Page, pdfOpts.DPI)
+		thumbnail, err := images.GeneratePDFPreview(data, *size, pdfOpts)
+		if errors.Is(err, images.ErrPDFPage) {
+			RecordImageRequest(variant, source, "bad_request")
+			http.Error(w, "Page out of range", http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			utils.Info("IMAGE", "ERROR generating PDF thumbnail: uuid=%s, remote=%s, error=%v", uuid, r.RemoteAddr, err)
-			http.Error(w, "Failed to generate PDF thumbnail: "+err.Error(), http.StatusInternalServerError)
+			if errors.Is(err, images.ErrPdftoppm) {
+				pdftoppmFailuresTotal.Inc()
+			}
+			status, label := imageErrorStatus(err)
+			RecordImageRequest(variant, source, label)
+			http.Error(w, "Failed to generate PDF thumbnail: "+err.Error(), status)
 			return
 		}
 
@@ -507,7 +1043,9 @@ func (s *Server) HandleImageFunc(w http.ResponseWriter, r *http.Request, path st
 		resized, err := images.ResizeImage(data, mimeType, *size)
 		if err != nil {
 			utils.Info("IMAGE", "ERROR resizing: uuid=%s, remote=%s, error=%v", uuid, r.RemoteAddr, err)
-			http.Error(w, "Failed to resize image: "+err.Error(), http.StatusInternalServerError)
+			status, label := imageErrorStatus(err)
+			RecordImageRequest(variant, source, label)
+			http.Error(w, "Failed to resize image: "+err.Error(), status)
 			return
 		}
 
@@ -516,24 +1054,30 @@ func (s *Server) HandleImageFunc(w http.ResponseWriter, r *http.Request, path st
 		utils.Info("IMAGE", "SUCCESS resized: uuid=%s, variant=%s, size=%d, remote=%s", uuid, variant, len(data), r.RemoteAddr)
 	}
 
+	RecordImageProcessing(variant, source, time.Since(processingStart).Seconds(), inputSize, len(data))
+
 	// Nastavíme hlavičky a vrátíme obrázek
-	// Cache headers - varianty jsou immutable (UUID + varianta se nemění)
-	w.Header().Set("Cache-Control", "public, max-age=2592000, immutable") // 30 dní
+	w.Header().Set("Cache-Control", cacheControl)
 	w.Header().Set("ETag", etag)
 	w.Header().Set("Content-Type", mimeType)
 	encodedFilename := url.PathEscape(filename)
 	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"; filename*=UTF-8''%s", filename, encodedFilename))
 	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 	w.Write(data)
+	RecordImageRequest(variant, source, "ok")
 }
 
 func (s *Server) HandleHealthFunc(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
+	health := map[string]string{
 		"status":  "ok",
 		"service": "cumulus3",
-	})
+	}
+	if s.ReadOnly {
+		health["mode"] = "read-only"
+	}
+	json.NewEncoder(w).Encode(health)
 }
 
 // **********************************************************************************************************
@@ -544,16 +1088,90 @@ func (s *Server) HandleHealthFunc(w http.ResponseWriter, r *http.Request) {
 
 // BaseHandleDownloadByOldID downloads a file by its old Cumulus ID
 // @Summary Download a file by old ID
-// @Description Downloads a file by its old Cumulus ID
+// @Description Downloads a file by its old Cumulus ID. The ETag is the BLAKE2b-256 hash of the content; If-None-Match with it gets 304 Not Modified. HEAD returns the headers (Content-Length, Content-Type, Content-Disposition, ETag) from the metadata without reading the stored content.
 // @Tags 01 - Base (internal)
 // @Produce octet-stream
 // @Param cumulus_id path int true "Old Cumulus ID"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Param If-None-Match header string false "ETag of a cached copy (quoted content hash); 304 when it matches"
 // @Success 200 {file} file "File content"
+// @Success 206 {file} file "Partial content (Range)"
+// @Success 304 {string} string "Not Modified (If-None-Match matches the ETag)"
 // @Failure 404 {string} string "File not found"
+// @Failure 410 {string} string "File expired (EXPIRED_ACCESS=deny)"
+// @Failure 416 {string} string "Range not satisfiable"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /base/files/old/{cumulus_id} [get]
+// @Router /base/files/old/{cumulus_id} [head]
 func (s *Server) HandleBaseDownloadByOldID(w http.ResponseWriter, r *http.Request) {
-	s.HandleDownloadByOldIDFunc(w, r, "/base/files/old/")
+	s.HandleDownloadByOldIDFunc(w, r)
+}
+
+const maxOldIDLookup = 10000
+
+// OldIDExistsRequest is the body of POST /base/files/old/exists
+type OldIDExistsRequest struct {
+	IDs []int64 `json:"ids" example:"1001,1002,1003"`
+}
+
+// OldIDExistsEntry describes an existing file found by old Cumulus ID
+type OldIDExistsEntry struct {
+	FileID string `json:"fileID" example:"xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"`
+	Hash   string `json:"hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Size   int64  `json:"size" example:"1048576"`
+}
+
+// OldIDExistsResponse lists which old Cumulus IDs exist
+type OldIDExistsResponse struct {
+	Found   map[string]OldIDExistsEntry `json:"found"`
+	Missing []int64                     `json:"missing"`
+}
+
+// HandleBaseOldIDsExist checks many old Cumulus IDs at once
+// @Summary Bulk check old IDs
+// @Description Returns which old Cumulus IDs exist, with file UUID, BLAKE2b-256 content hash and size (max 10000 IDs per request)
+// @Tags 01 - Base (internal)
+// @Accept json
+// @Produce json
+// @Param body body OldIDExistsRequest true "Old Cumulus IDs"
+// @Success 200 {object} OldIDExistsResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /base/files/old/exists [post]
+func (s *Server) HandleBaseOldIDsExist(w http.ResponseWriter, r *http.Request) {
+	var req OldIDExistsRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > maxOldIDLookup {
+		http.Error(w, fmt.Sprintf("Too many ids (max %d)", maxOldIDLookup), http.StatusBadRequest)
+		return
+	}
+
+	found, err := s.FileService.MetaStore.GetFilesByOldIDs(req.IDs)
+	if err != nil {
+		utils.Error("OLD_ID_EXISTS", "Lookup failed: ids=%d, error=%v", len(req.IDs), err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := OldIDExistsResponse{
+		Found:   make(map[string]OldIDExistsEntry, len(found)),
+		Missing: []int64{},
+	}
+	for _, id := range req.IDs {
+		e, ok := found[id]
+		if !ok {
+			resp.Missing = append(resp.Missing, id)
+			continue
+		}
+		resp.Found[strconv.FormatInt(id, 10)] = OldIDExistsEntry{FileID: e.FileID, Hash: e.Hash, Size: e.Size}
+	}
+	utils.Info("OLD_ID_EXISTS", "Checked %d ids: found=%d, missing=%d, remote=%s", len(req.IDs), len(resp.Found), len(resp.Missing), r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 // HandleFileInfoByOldID retrieves file information by old Cumulus ID
@@ -562,14 +1180,17 @@ func (s *Server) HandleBaseDownloadByOldID(w http.ResponseWriter, r *http.Reques
 // @Tags 01 - Base (internal)
 // @Produce json
 // @Param cumulus_id path int true "Cumulus ID"
-// @Param extended query boolean false "Include base64 content"
+// @Param extended query boolean false "Include base64 content (up to EXTENDED_INFO_MAX_SIZE)"
+// @Param content query string false "'stream' redirects (303) to the download URL instead of embedding the content"
 // @Success 200 {object} service.FileInfo
 // @Failure 400 {string} string "Bad Request"
 // @Failure 404 {string} string "File not found"
+// @Failure 410 {string} string "Extended info of an expired file (EXPIRED_ACCESS=deny)"
+// @Failure 413 {string} string "Content larger than EXTENDED_INFO_MAX_SIZE, use the download URL"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /base/files/old/info/{cumulus_id} [get]
 func (s *Server) HandleBaseFileInfoByOldID(w http.ResponseWriter, r *http.Request) {
-	s.HandleFileInfoByOldIDFunc(w, r, "/base/files/old/info/")
+	s.HandleFileInfoByOldIDFunc(w, r, "/base/files/old/")
 }
 
 // HandleDelete deletes a file
@@ -579,26 +1200,43 @@ func (s *Server) HandleBaseFileInfoByOldID(w http.ResponseWriter, r *http.Reques
 // @Param uuid path string true "File UUID"
 // @Success 200 {string} string "File deleted successfully"
 // @Failure 400 {string} string "Bad Request"
+// @Failure 423 {string} string "File is under legal hold"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /base/files/delete/{uuid} [delete]
 func (s *Server) HandleBaseDelete(w http.ResponseWriter, r *http.Request) {
-	s.HandleDeleteFunc(w, r, "/base/files/")
+	s.HandleDeleteFunc(w, r)
 }
 
 // HandleUpload uploads a file and saves metadata
 // @Summary Upload a file
-// @Description Uploads a file to the storage
+// @Description Uploads a file to the storage. Several "file" parts are stored concurrently and answered with an array of BatchUploadResult in the order of the files (201 if all were stored, 207 otherwise); old_cumulus_id is then given once per file, content_type once or once per file.
 // @Tags 01 - Base (internal)
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "File to upload"
 // @Param tags formData string false "Tags like array of string or coma separated strings"
 // @Param old_cumulus_id formData int false "Legacy ID"
-// @Param validity formData string false "Validity period (e.g. '1 day', '2 months')"
+// @Param on_conflict formData string false "When old_cumulus_id belongs to another file: 'reject' (409, default) or 'supersede' (move the ID to the new file)"
+// @Param disposition formData string false "Content-Disposition of downloads: 'inline' or 'attachment' (default: by MIME type)"
+// @Param validity formData string false "Validity period (e.g. '1 day', '2 weeks', '1 year'), within VALIDITY_MIN..VALIDITY_MAX"
+// @Param expires_at formData string false "Absolute expiry time (RFC 3339), instead of validity"
+// @Param content_type formData string false "Original MIME type, used when content detection yields generic binary"
+// @Param created_at formData string false "Original creation time (RFC 3339, 'YYYY-MM-DD HH:MM:SS' or unix seconds); requires admin Basic auth"
+// @Param If-None-Match header string false "Quoted BLAKE2b-256 hash of the content; if already stored and in scope (admin Basic auth, or tags a live file of the content carries), the body is not read"
+// @Param filename query string false "With If-None-Match: link the stored content under this filename (old_cumulus_id, on_conflict, disposition, validity, expires_at, tags also from query)"
+// @Param verbose query boolean false "Include hash, sizes, dedup flag, detected MIME type and expiry in the response"
 // @Success 201 {object} UploadResponse "File uploaded successfully, returns file UUID"
+// @Success 200 {object} UploadResponse "Content already stored, file linked without upload (If-None-Match + filename)"
+// @Success 207 {array} BatchUploadResult "Multi-file upload with at least one failed file"
+// @Header 200,201,409 {string} X-Cumulus-Dedup "hit when the content was already stored, miss otherwise"
+// @Header 200,201,409 {string} X-Cumulus-Blob-Id "ID of the blob holding the content"
 // @Failure 400 {string} string "Bad Request"
-// @Failure 413 {string} string "File too large"
+// @Failure 403 {string} string "created_at without admin credentials"
+// @Failure 409 {object} ExistingBlobResponse "Content already stored (If-None-Match without filename)"
+// @Failure 413 {object} UploadTooLargeResponse "File larger than MAX_UPLOAD_FILE_SIZE, maxSize is the limit"
+// @Failure 422 {string} string "Upload rejected by the upload hook (UPLOAD_HOOK_URL), body is its reason"
 // @Failure 500 {string} string "Internal Server Error"
+// @Failure 503 {string} string "Upload hook unavailable (UPLOAD_HOOK_FAIL_OPEN off)"
 // @Router /base/files/upload [post]
 func (s *Server) HandleBaseUpload(w http.ResponseWriter, r *http.Request) {
 	utils.Info("MAIN", "Upload ... ")
@@ -607,16 +1245,24 @@ func (s *Server) HandleBaseUpload(w http.ResponseWriter, r *http.Request) {
 
 // HandleDownload downloads a file
 // @Summary Download a file
-// @Description Downloads a file by its UUID
+// @Description Downloads a file by its UUID. The ETag is the BLAKE2b-256 hash of the content; If-None-Match with it gets 304 Not Modified. HEAD returns the headers (Content-Length, Content-Type, Content-Disposition, ETag) from the metadata without reading the stored content.
 // @Tags 01 - Base (internal)
 // @Produce octet-stream
 // @Param uuid path string true "File UUID"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Param If-None-Match header string false "ETag of a cached copy (quoted content hash); 304 when it matches"
 // @Success 200 {file} file "File content"
+// @Success 206 {file} file "Partial content (Range)"
+// @Success 304 {string} string "Not Modified (If-None-Match matches the ETag)"
+// @Success 307 {string} string "File stored on another cluster node (CLUSTER_NODES), Location points to it"
 // @Failure 404 {string} string "File not found"
+// @Failure 410 {string} string "File expired (EXPIRED_ACCESS=deny)"
+// @Failure 416 {string} string "Range not satisfiable"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /base/files/{uuid} [get]
+// @Router /base/files/{uuid} [head]
 func (s *Server) HandleBaseDownload(w http.ResponseWriter, r *http.Request) {
-	s.HandleDownloadFunc(w, r, "/base/files/")
+	s.HandleDownloadFunc(w, r)
 }
 
 // HandleFileInfo retrieves file information
@@ -625,14 +1271,18 @@ func (s *Server) HandleBaseDownload(w http.ResponseWriter, r *http.Request) {
 // @Tags 01 - Base (internal)
 // @Produce json
 // @Param uuid path string true "File UUID"
-// @Param extended query boolean false "Include base64 content"
+// @Param extended query boolean false "Include base64 content (up to EXTENDED_INFO_MAX_SIZE)"
+// @Param content query string false "'stream' redirects (303) to the download URL instead of embedding the content"
 // @Success 200 {object} service.FileInfo
+// @Success 307 {string} string "File stored on another cluster node (CLUSTER_NODES), Location points to it"
 // @Failure 400 {string} string "Bad Request"
 // @Failure 404 {string} string "File not found"
+// @Failure 410 {string} string "Extended info of an expired file (EXPIRED_ACCESS=deny)"
+// @Failure 413 {string} string "Content larger than EXTENDED_INFO_MAX_SIZE, use the download URL"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /base/files/info/{uuid} [get]
 func (s *Server) HandleBaseFileInfo(w http.ResponseWriter, r *http.Request) {
-	s.HandleFileInfoFunc(w, r, "/base/files/info/")
+	s.HandleFileInfoFunc(w, r, "/base/files/")
 }
 
 // **********************************************************************************************************
@@ -648,11 +1298,26 @@ func (s *Server) HandleBaseFileInfo(w http.ResponseWriter, r *http.Request) {
 // @Param file formData file true "File to upload"
 // @Param tags formData string false "Tags like array of string or coma separated strings"
 // @Param old_cumulus_id formData int false "Legacy ID"
-// @Param validity formData string false "Validity period (e.g. '1 day', '2 months')"
+// @Param on_conflict formData string false "When old_cumulus_id belongs to another file: 'reject' (409, default) or 'supersede' (move the ID to the new file)"
+// @Param disposition formData string false "Content-Disposition of downloads: 'inline' or 'attachment' (default: by MIME type)"
+// @Param validity formData string false "Validity period (e.g. '1 day', '2 weeks', '1 year'), within VALIDITY_MIN..VALIDITY_MAX"
+// @Param expires_at formData string false "Absolute expiry time (RFC 3339), instead of validity"
+// @Param content_type formData string false "Original MIME type, used when content detection yields generic binary"
+// @Param created_at formData string false "Original creation time (RFC 3339, 'YYYY-MM-DD HH:MM:SS' or unix seconds); requires admin Basic auth"
+// @Param If-None-Match header string false "Quoted BLAKE2b-256 hash of the content; if already stored and in scope (admin Basic auth, or tags a live file of the content carries), the body is not read"
+// @Param filename query string false "With If-None-Match: link the stored content under this filename (old_cumulus_id, on_conflict, disposition, validity, expires_at, tags also from query)"
+// @Param verbose query boolean false "Include hash, sizes, dedup flag, detected MIME type and expiry in the response"
 // @Success 201 {object} UploadResponse "File uploaded successfully, returns file UUID"
+// @Success 200 {object} UploadResponse "Content already stored, file linked without upload (If-None-Match + filename)"
+// @Header 200,201,409 {string} X-Cumulus-Dedup "hit when the content was already stored, miss otherwise"
+// @Header 200,201,409 {string} X-Cumulus-Blob-Id "ID of the blob holding the content"
 // @Failure 400 {string} string "Bad Request"
-// @Failure 413 {string} string "File too large"
+// @Failure 403 {string} string "created_at without admin credentials"
+// @Failure 409 {object} ExistingBlobResponse "Content already stored (If-None-Match without filename)"
+// @Failure 413 {object} UploadTooLargeResponse "File larger than MAX_UPLOAD_FILE_SIZE, maxSize is the limit"
+// @Failure 422 {string} string "Upload rejected by the upload hook (UPLOAD_HOOK_URL), body is its reason"
 // @Failure 500 {string} string "Internal Server Error"
+// @Failure 503 {string} string "Upload hook unavailable (UPLOAD_HOOK_FAIL_OPEN off)"
 // @Router /v2/files/upload [post]
 func (s *Server) HandleV2Upload(w http.ResponseWriter, r *http.Request) {
 	s.HandleUploadFunc(w, r)
@@ -660,16 +1325,48 @@ func (s *Server) HandleV2Upload(w http.ResponseWriter, r *http.Request) {
 
 // HandleV2Download downloads a file
 // @Summary Download a file
-// @Description Downloads a file by its UUID
+// @Description Downloads a file by its UUID. The Cache-Control header is set by the DOWNLOAD_CACHE_CONTROL rules for the MIME type (none by default). The ETag is the BLAKE2b-256 hash of the content; If-None-Match with it gets 304 Not Modified. HEAD returns the headers (Content-Length, Content-Type, Content-Disposition, ETag) from the metadata without reading the stored content.
 // @Tags 02 - Files
 // @Produce octet-stream
 // @Param uuid path string true "File UUID"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Param If-None-Match header string false "ETag of a cached copy (quoted content hash); 304 when it matches"
+// @Param expires query int false "Expiry (unix seconds) of a presigned URL, see POST /v2/files/{uuid}/presign"
+// @Param sig query string false "Signature of a presigned URL; a valid one skips auth"
 // @Success 200 {file} file "File content"
+// @Success 206 {file} file "Partial content (Range)"
+// @Success 304 {string} string "Not Modified (If-None-Match matches the ETag)"
+// @Success 307 {string} string "File stored on another cluster node (CLUSTER_NODES), Location points to it"
+// @Failure 403 {string} string "Invalid or expired signature (sig)"
 // @Failure 404 {string} string "File not found"
+// @Failure 410 {string} string "File expired (EXPIRED_ACCESS=deny)"
+// @Failure 416 {string} string "Range not satisfiable"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /v2/files/{uuid} [get]
+// @Router /v2/files/{uuid} [head]
 func (s *Server) HandleV2Download(w http.ResponseWriter, r *http.Request) {
-	s.HandleDownloadFunc(w, r, "/v2/files/")
+	s.HandleDownloadFunc(w, r)
+}
+
+// HandleV2FileHash returns content hashes of a file
+// @Summary Get file hash
+// @Description Returns the stored BLAKE2b-256 hash (and optionally SHA-256) so copies can be verified without downloading the content
+// @Tags 02 - Files
+// @Produce json
+// @Param uuid path string true "File UUID"
+// @Param sha256 query boolean false "Also compute SHA-256 (reads the content on the server)"
+// @Success 200 {object} service.FileHash
+// @Success 307 {string} string "File stored on another cluster node (CLUSTER_NODES), Location points to it"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "File not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v2/files/{uuid}/hash [get]
+func (s *Server) HandleV2FileHash(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("sub") != "hash" {
+		http.NotFound(w, r)
+		return
+	}
+	s.HandleFileHashFunc(w, r)
 }
 
 // HandleV2FileInfo retrieves file information
@@ -678,14 +1375,18 @@ func (s *Server) HandleV2Download(w http.ResponseWriter, r *http.Request) {
 // @Tags 02 - Files
 // @Produce json
 // @Param uuid path string true "File UUID"
-// @Param extended query boolean false "Include base64 content"
+// @Param extended query boolean false "Include base64 content (up to EXTENDED_INFO_MAX_SIZE)"
+// @Param content query string false "'stream' redirects (303) to the download URL instead of embedding the content"
 // @Success 200 {object} service.FileInfo
+// @Success 307 {string} string "File stored on another cluster node (CLUSTER_NODES), Location points to it"
 // @Failure 400 {string} string "Bad Request"
 // @Failure 404 {string} string "File not found"
+// @Failure 410 {string} string "Extended info of an expired file (EXPIRED_ACCESS=deny)"
+// @Failure 413 {string} string "Content larger than EXTENDED_INFO_MAX_SIZE, use the download URL"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /v2/files/info/{uuid} [get]
 func (s *Server) HandleV2FileInfo(w http.ResponseWriter, r *http.Request) {
-	s.HandleFileInfoFunc(w, r, "/v2/files/info/")
+	s.HandleFileInfoFunc(w, r, "/v2/files/")
 }
 
 // HandleImage zpracuje požadavky na obrázky a jejich varianty
@@ -694,33 +1395,76 @@ func (s *Server) HandleV2FileInfo(w http.ResponseWriter, r *http.Request) {
 // @Tags 03 - Images
 // @Produce image/jpeg,image/png
 // @Param uuid path string true "File UUID"
-// @Param variant path string false "Image variant: thumb, sm, md, lg (optional for original)"
+// @Param variant path string false "Image variant: thumb, sm, md, lg (optional for original), or {width}x{height} with sig"
+// @Param sig query string false "HMAC-SHA256 signature of the URL path (IMAGE_SIGNING_KEY), required for {width}x{height}"
+// @Param page query int false "PDF preview: page number (default 1)"
+// @Param dpi query int false "PDF preview: render resolution 36-300 (default by variant size)"
 // @Success 200 {file} file "Image content"
+// @Success 307 {string} string "File stored on another cluster node (CLUSTER_NODES), Location points to it"
 // @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Invalid signature"
 // @Failure 404 {string} string "File not found"
+// @Failure 410 {string} string "File expired (EXPIRED_ACCESS=deny)"
 // @Failure 415 {string} string "Not an image or PDF"
+// @Failure 422 {string} string "Image over IMAGE_MAX_INPUT_SIZE or IMAGE_MAX_PIXELS"
 // @Failure 500 {string} string "Internal Server Error"
+// @Failure 504 {string} string "Processing timed out"
 // @Router /v2/images/{uuid} [get]
 // @Router /v2/images/{uuid}/thumb [get]
 // @Router /v2/images/{uuid}/sm [get]
 // @Router /v2/images/{uuid}/md [get]
 // @Router /v2/images/{uuid}/lg [get]
 func (s *Server) HandleV2Image(w http.ResponseWriter, r *http.Request) {
-	s.HandleImageFunc(w, r, "/v2/images/")
+	s.HandleImageFunc(w, r)
+}
+
+// HandleV2ImageByOldID serves images and their variants by the old CumulusID
+// @Summary Get image or image variant by old CumulusID
+// @Description Same as /v2/images/{uuid} for legacy consumers that only know the old CumulusID. The ETag contains the current file UUID, so a superseded ID is revalidated.
+// @Tags 03 - Images
+// @Produce image/jpeg,image/png
+// @Param cumulus_id path int true "Old CumulusID"
+// @Param variant path string false "Image variant: thumb, sm, md, lg (optional for original), or {width}x{height} with sig"
+// @Param sig query string false "HMAC-SHA256 signature of the URL path (IMAGE_SIGNING_KEY), required for {width}x{height}"
+// @Param page query int false "PDF preview: page number (default 1)"
+// @Param dpi query int false "PDF preview: render resolution 36-300 (default by variant size)"
+// @Success 200 {file} file "Image content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Invalid signature"
+// @Failure 404 {string} string "File not found"
+// @Failure 410 {string} string "File expired (EXPIRED_ACCESS=deny)"
+// @Failure 415 {string} string "Not an image or PDF"
+// @Failure 422 {string} string "Image over IMAGE_MAX_INPUT_SIZE or IMAGE_MAX_PIXELS"
+// @Failure 500 {string} string "Internal Server Error"
+// @Failure 504 {string} string "Processing timed out"
+// @Router /v2/images/old/{cumulus_id} [get]
+// @Router /v2/images/old/{cumulus_id}/thumb [get]
+// @Router /v2/images/old/{cumulus_id}/sm [get]
+// @Router /v2/images/old/{cumulus_id}/md [get]
+// @Router /v2/images/old/{cumulus_id}/lg [get]
+func (s *Server) HandleV2ImageByOldID(w http.ResponseWriter, r *http.Request) {
+	s.HandleImageByOldIDFunc(w, r)
 }
 
 // HandleV2DownloadByOldID downloads a file by its old CumulusID
 // @Summary Download a file by old CumulusID
-// @Description Downloads a file by its old CumulusID
+// @Description Downloads a file by its old CumulusID. The ETag is the BLAKE2b-256 hash of the content; If-None-Match with it gets 304 Not Modified. HEAD returns the headers (Content-Length, Content-Type, Content-Disposition, ETag) from the metadata without reading the stored content.
 // @Tags 02 - Files
 // @Produce octet-stream
 // @Param cumulus_id path int true "Old CumulusID"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Param If-None-Match header string false "ETag of a cached copy (quoted content hash); 304 when it matches"
 // @Success 200 {file} file "File content"
+// @Success 206 {file} file "Partial content (Range)"
+// @Success 304 {string} string "Not Modified (If-None-Match matches the ETag)"
 // @Failure 404 {string} string "File not found"
+// @Failure 410 {string} string "File expired (EXPIRED_ACCESS=deny)"
+// @Failure 416 {string} string "Range not satisfiable"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /v2/files/old/{cumulus_id} [get]
+// @Router /v2/files/old/{cumulus_id} [head]
 func (s *Server) HandleV2DownloadByOldID(w http.ResponseWriter, r *http.Request) {
-	s.HandleDownloadByOldIDFunc(w, r, "/v2/files/old/")
+	s.HandleDownloadByOldIDFunc(w, r)
 }
 
 // HandleFileInfo retrieves file information
@@ -729,19 +1473,22 @@ func (s *Server) HandleV2DownloadByOldID(w http.ResponseWriter, r *http.Request)
 // @Tags 02 - Files
 // @Produce json
 // @Param cumulus_id path int true "Old CumulusID"
-// @Param extended query boolean false "Include base64 content"
+// @Param extended query boolean false "Include base64 content (up to EXTENDED_INFO_MAX_SIZE)"
+// @Param content query string false "'stream' redirects (303) to the download URL instead of embedding the content"
 // @Success 200 {object} service.FileInfo
 // @Failure 400 {string} string "Bad Request"
 // @Failure 404 {string} string "File not found"
+// @Failure 410 {string} string "Extended info of an expired file (EXPIRED_ACCESS=deny)"
+// @Failure 413 {string} string "Content larger than EXTENDED_INFO_MAX_SIZE, use the download URL"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /v2/files/old/info/{cumulus_id} [get]
 func (s *Server) HandleV2FileInfoByOldID(w http.ResponseWriter, r *http.Request) {
-	s.HandleFileInfoByOldIDFunc(w, r, "/v2/files/old/info/")
+	s.HandleFileInfoByOldIDFunc(w, r, "/v2/files/old/")
 }
 
 // HandleHealth returns service health status
 // @Summary Health check
-// @Description Returns OK if service is healthy
+// @Description Returns OK if service is healthy. A read-only replica (READ_ONLY) adds "mode": "read-only".
 // @Tags 04 - System
 // @Produce json
 // @Success 200 {object} map[string]string
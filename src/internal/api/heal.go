@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+const (
+	defaultHealListLimit = 100
+	maxHealListLimit     = 10000
+)
+
+// HealListResponse is the body of GET /system/heal
+type HealListResponse struct {
+	Count int                `json:"count" example:"1"`
+	Blobs []storage.BlobHeal `json:"blobs"`
+}
+
+// HandleSystemHeal lists blobs tagged for heal
+// @Summary List blobs waiting for heal
+// @Description Lists blobs whose volume file was missing when a download read them, most recently seen first. source says where the last read was served from: volume_dir (READ_FALLBACK_DIR), replica (READ_FALLBACK_URL) or none (the download failed). A blob leaves the list once it is moved to another volume (POST /system/blobs/{id}/move) or deleted.
+// @Tags 04 - System
+// @Produce json
+// @Param limit query int false "Max number of blobs (default 100, max 10000)"
+// @Success 200 {object} HealListResponse
+// @Failure 400 {string} string "Invalid limit"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /system/heal [get]
+func (s *Server) HandleSystemHeal(w http.ResponseWriter, r *http.Request) {
+	limit := defaultHealListLimit
+	if val := r.URL.Query().Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxHealListLimit)
+	}
+
+	blobs, err := s.FileService.MetaStore.ListBlobHeal(limit)
+	if err != nil {
+		utils.Error("SYSTEM", "Failed to list blobs for heal: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HealListResponse{Count: len(blobs), Blobs: blobs})
+}
@@ -0,0 +1,300 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// JWT bearer tokeny: middleware "jwt" (ROUTE_MIDDLEWARE) ověří Authorization: Bearer <token> podpisem
+// sdíleným tajemstvím (HS256/384/512) nebo veřejným klíčem SSO (RS256/384/512), vydavatele, publikum,
+// platnost a scope podle metody požadavku. Požadavek bez tokenu jde dál na apikey/admin Basic auth.
+
+// Scopes a token needs for requests: GET/HEAD and read-only POST routes read, DELETE and the POST
+// delete route delete, other requests write
+const (
+	JWTScopeRead   = "read"
+	JWTScopeWrite  = "write"
+	JWTScopeDelete = "delete"
+)
+
+// jwtLeeway tolerates clock skew between the token issuer and this node for exp and nbf
+const jwtLeeway = time.Minute
+
+// JWTConfig configures the validation of bearer tokens; Secret, PublicKey or both must be set
+type JWTConfig struct {
+	Secret    []byte         // HS256/384/512 (JWT_SECRET)
+	PublicKey *rsa.PublicKey // RS256/384/512 (JWT_PUBLIC_KEY_FILE)
+	Issuer    string         // required iss, "" = any (JWT_ISSUER)
+	Audience  string         // required in aud, "" = any (JWT_AUDIENCE)
+}
+
+// JWTAuth authenticates requests by their bearer token (see the jwt middleware)
+type JWTAuth struct {
+	cfg JWTConfig
+}
+
+// NewJWTAuth creates the bearer token validator
+func NewJWTAuth(cfg JWTConfig) (*JWTAuth, error) {
+	if len(cfg.Secret) == 0 && cfg.PublicKey == nil {
+		return nil, errors.New("JWT_SECRET or JWT_PUBLIC_KEY_FILE is required")
+	}
+	return &JWTAuth{cfg: cfg}, nil
+}
+
+// ParseRSAPublicKeyPEM reads an RSA public key from PEM ("PUBLIC KEY", "RSA PUBLIC KEY" or a certificate)
+func ParseRSAPublicKeyPEM(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T, only RSA is supported", key)
+	}
+	return rsaKey, nil
+}
+
+// JWTClaims are the claims of a token used by Cumulus3
+type JWTClaims struct {
+	Issuer    string        `json:"iss"`
+	Subject   string        `json:"sub"`
+	Audience  jwtStringList `json:"aud"`
+	ExpiresAt *float64      `json:"exp"`
+	NotBefore *float64      `json:"nbf"`
+	ClientID  string        `json:"client_id"`
+	AZP       string        `json:"azp"`
+	Scope     string        `json:"scope"` // OAuth 2: space-separated
+	SCP       jwtStringList `json:"scp"`   // Azure AD, Okta: list or space-separated
+}
+
+// jwtStringList decodes a JSON string or array of strings
+type jwtStringList []string
+
+func (l *jwtStringList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*l = strings.Fields(one)
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*l = many
+	return nil
+}
+
+// Client is the usage and admission client of the token: client_id, azp or sub
+func (c *JWTClaims) Client() string {
+	for _, client := range []string{c.ClientID, c.AZP, c.Subject} {
+		if client != "" {
+			return client
+		}
+	}
+	return anonymousClient
+}
+
+// HasScope reports whether the token grants the scope
+func (c *JWTClaims) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(c.Scope), scope) || slices.Contains(c.SCP, scope)
+}
+
+// Verify checks the signature and claims of a compact JWS token at now
+func (j *JWTAuth) Verify(token string, now time.Time) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+	if err := j.verifySignature(header.Alg, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims JWTClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("token has no exp")
+	}
+	if now.Add(-jwtLeeway).After(time.Unix(int64(*claims.ExpiresAt), 0)) {
+		return nil, errors.New("token expired")
+	}
+	if claims.NotBefore != nil && now.Add(jwtLeeway).Before(time.Unix(int64(*claims.NotBefore), 0)) {
+		return nil, errors.New("token not valid yet")
+	}
+	if j.cfg.Issuer != "" && claims.Issuer != j.cfg.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if j.cfg.Audience != "" && !slices.Contains(claims.Audience, j.cfg.Audience) {
+		return nil, errors.New("token is not issued for this audience")
+	}
+	return &claims, nil
+}
+
+// verifySignature checks sig of the signing input with the algorithm of the token header; the
+// algorithm must match a configured key, "none" and unknown algorithms are refused
+func (j *JWTAuth) verifySignature(alg, input string, sig []byte) error {
+	var newHash func() hash.Hash
+	var cryptoHash crypto.Hash
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		newHash, cryptoHash = sha256.New, crypto.SHA256
+	case "384":
+		newHash, cryptoHash = sha512.New384, crypto.SHA384
+	case "512":
+		newHash, cryptoHash = sha512.New, crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	switch {
+	case strings.HasPrefix(alg, "HS") && len(j.cfg.Secret) > 0:
+		mac := hmac.New(newHash, j.cfg.Secret)
+		mac.Write([]byte(input))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case strings.HasPrefix(alg, "RS") && j.cfg.PublicKey != nil:
+		h := cryptoHash.New()
+		h.Write([]byte(input))
+		if rsa.VerifyPKCS1v15(j.cfg.PublicKey, cryptoHash, h.Sum(nil), sig) != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %q", alg)
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// requiredJWTScope returns the scope a request needs; read-only POST routes (read_only.go) need read
+func requiredJWTScope(r *http.Request) string {
+	switch {
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return JWTScopeRead
+	case r.Method == http.MethodPost && slices.Contains(readOnlyPOSTRoutes, r.Pattern):
+		return JWTScopeRead
+	case r.Method == http.MethodDelete || strings.HasPrefix(r.URL.Path, "/base/files/delete/"):
+		return JWTScopeDelete
+	}
+	return JWTScopeWrite
+}
+
+// jwtClaimsKey is the context key of the claims of a verified bearer token
+type jwtClaimsKey struct{}
+
+// requestJWTClaims returns the claims of the bearer token the request passed with, nil without one
+func requestJWTClaims(r *http.Request) *JWTClaims {
+	claims, _ := r.Context().Value(jwtClaimsKey{}).(*JWTClaims)
+	return claims
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header, "" without one
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Middleware lets through requests with a valid bearer token granting the scope of the request,
+// recording the client of the token; an invalid token gets 401, a missing scope 403. Requests
+// without a token go to fallback (the apikey middleware), with a nil fallback only admin Basic auth
+// passes. A nil JWTAuth refuses every token.
+func (j *JWTAuth) Middleware(fallback Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		var other http.Handler
+		if fallback != nil {
+			other = fallback(next)
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" {
+				switch {
+				case other != nil:
+					other.ServeHTTP(w, r)
+				case isAdminRequest(r):
+					next.ServeHTTP(w, r)
+				default:
+					w.Header().Set("WWW-Authenticate", `Bearer realm="Cumulus3"`)
+					http.Error(w, "Missing bearer token", http.StatusUnauthorized)
+				}
+				return
+			}
+			if j == nil {
+				writeBearerError(w, http.StatusUnauthorized, `error="invalid_token"`, "Bearer tokens are not configured")
+				return
+			}
+			claims, err := j.Verify(token, time.Now())
+			if err != nil {
+				utils.Info("AUTH", "Invalid bearer token: path=%s, remote=%s, error=%v", r.URL.Path, r.RemoteAddr, err)
+				writeBearerError(w, http.StatusUnauthorized, `error="invalid_token"`, "Invalid bearer token")
+				return
+			}
+			scope := requiredJWTScope(r)
+			if !claims.HasScope(scope) {
+				utils.Info("AUTH", "Bearer token without scope %s: client=%s, path=%s, remote=%s", scope, claims.Client(), r.URL.Path, r.RemoteAddr)
+				writeBearerError(w, http.StatusForbidden, `error="insufficient_scope", scope="`+scope+`"`, "Token lacks scope "+scope)
+				return
+			}
+			setRequestClient(r, claims.Client())
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), jwtClaimsKey{}, claims)))
+		})
+	}
+}
+
+func writeBearerError(w http.ResponseWriter, status int, params, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="Cumulus3", `+params)
+	http.Error(w, msg, status)
+}
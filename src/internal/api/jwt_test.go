@@ -0,0 +1,185 @@
+package api
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var jwtTestSecret = []byte("test-secret")
+
+// jwtTestToken builds a compact token with the header alg and claims; sign returns the signature
+// of the signing input
+func jwtTestToken(t *testing.T, alg string, claims map[string]any, sign func(input string) []byte) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return input + "." + base64.RawURLEncoding.EncodeToString(sign(input))
+}
+
+func signHS256(secret []byte) func(string) []byte {
+	return func(input string) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(input))
+		return mac.Sum(nil)
+	}
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey) func(string) []byte {
+	return func(input string) []byte {
+		sum := sha256.Sum256([]byte(input))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		if err != nil {
+			t.Fatalf("SignPKCS1v15: %v", err)
+		}
+		return sig
+	}
+}
+
+func TestJWTVerifyAlgorithm(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	now := time.Now()
+	claims := map[string]any{"sub": "app", "exp": now.Add(time.Hour).Unix()}
+	hsOnly, _ := NewJWTAuth(JWTConfig{Secret: jwtTestSecret})
+	rsOnly, _ := NewJWTAuth(JWTConfig{PublicKey: &key.PublicKey})
+	both, _ := NewJWTAuth(JWTConfig{Secret: jwtTestSecret, PublicKey: &key.PublicKey})
+
+	hsToken := jwtTestToken(t, "HS256", claims, signHS256(jwtTestSecret))
+	rsToken := jwtTestToken(t, "RS256", claims, signRS256(t, key))
+	// Záměna algoritmu: HS256 podepsaný veřejným klíčem RSA jako sdíleným tajemstvím
+	pubDER, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+	confused := jwtTestToken(t, "HS256", claims, signHS256(pubPEM))
+	unsigned := jwtTestToken(t, "none", claims, func(string) []byte { return nil })
+
+	tests := []struct {
+		name  string
+		auth  *JWTAuth
+		token string
+		ok    bool
+	}{
+		{"HS token, HS key", hsOnly, hsToken, true},
+		{"RS token, RS key", rsOnly, rsToken, true},
+		{"HS and RS keys", both, rsToken, true},
+		{"HS token, RS key", rsOnly, hsToken, false},
+		{"RS token, HS key", hsOnly, rsToken, false},
+		{"HS signed with the RSA public key", rsOnly, confused, false},
+		{"alg none, HS key", hsOnly, unsigned, false},
+		{"alg none, both keys", both, unsigned, false},
+		{"HS token, other secret", hsOnly, jwtTestToken(t, "HS256", claims, signHS256([]byte("other"))), false},
+		{"tampered claims", hsOnly, strings.Replace(hsToken, ".", ".e30", 1), false},
+	}
+	for _, tt := range tests {
+		_, err := tt.auth.Verify(tt.token, now)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Verify error %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestJWTVerifyClaims(t *testing.T) {
+	auth, _ := NewJWTAuth(JWTConfig{Secret: jwtTestSecret, Issuer: "https://sso.example.com", Audience: "cumulus3"})
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{"iss": "https://sso.example.com", "aud": "cumulus3", "sub": "app", "exp": now.Add(time.Hour).Unix()}
+	}
+
+	tests := []struct {
+		name   string
+		change func(map[string]any)
+		ok     bool
+	}{
+		{"valid", func(map[string]any) {}, true},
+		{"missing exp", func(c map[string]any) { delete(c, "exp") }, false},
+		{"expired", func(c map[string]any) { c["exp"] = now.Add(-2 * jwtLeeway).Unix() }, false},
+		{"expired within leeway", func(c map[string]any) { c["exp"] = now.Add(-jwtLeeway / 2).Unix() }, true},
+		{"nbf in the future", func(c map[string]any) { c["nbf"] = now.Add(2 * jwtLeeway).Unix() }, false},
+		{"nbf within leeway", func(c map[string]any) { c["nbf"] = now.Add(jwtLeeway / 2).Unix() }, true},
+		{"nbf in the past", func(c map[string]any) { c["nbf"] = now.Add(-time.Hour).Unix() }, true},
+		{"other issuer", func(c map[string]any) { c["iss"] = "https://evil.example.com" }, false},
+		{"missing issuer", func(c map[string]any) { delete(c, "iss") }, false},
+		{"other audience", func(c map[string]any) { c["aud"] = "other" }, false},
+		{"audience list", func(c map[string]any) { c["aud"] = []string{"other", "cumulus3"} }, true},
+		{"audience list without us", func(c map[string]any) { c["aud"] = []string{"other"} }, false},
+		{"missing audience", func(c map[string]any) { delete(c, "aud") }, false},
+	}
+	for _, tt := range tests {
+		claims := valid()
+		tt.change(claims)
+		_, err := auth.Verify(jwtTestToken(t, "HS256", claims, signHS256(jwtTestSecret)), now)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Verify error %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestRequiredJWTScope(t *testing.T) {
+	tests := []struct {
+		method, pattern, path, want string
+	}{
+		{http.MethodGet, "GET /v2/files/{uuid}", "/v2/files/x", JWTScopeRead},
+		{http.MethodHead, "GET /v2/files/{uuid}", "/v2/files/x", JWTScopeRead},
+		{http.MethodPost, "POST /v2/blobs/lookup", "/v2/blobs/lookup", JWTScopeRead},
+		{http.MethodPost, "POST /v2/files/{uuid}/presign", "/v2/files/x/presign", JWTScopeRead},
+		{http.MethodPost, "POST /v2/files/prefetch", "/v2/files/prefetch", JWTScopeRead},
+		{http.MethodDelete, "DELETE /base/files/delete/{uuid}", "/base/files/delete/x", JWTScopeDelete},
+		{http.MethodPost, "POST /base/files/delete/{uuid}", "/base/files/delete/x", JWTScopeDelete},
+		{http.MethodDelete, "DELETE /v2/uploads/{id}", "/v2/uploads/x", JWTScopeDelete},
+		{http.MethodPost, "POST /v2/files/upload", "/v2/files/upload", JWTScopeWrite},
+		{http.MethodPut, "PUT /v2/files/{name}", "/v2/files/a.txt", JWTScopeWrite},
+		{http.MethodPatch, "PATCH /v2/uploads/{id}", "/v2/uploads/x", JWTScopeWrite},
+		{http.MethodPost, "POST /v2/files/tags", "/v2/files/tags", JWTScopeWrite},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		r.Pattern = tt.pattern
+		if got := requiredJWTScope(r); got != tt.want {
+			t.Errorf("%s %s: scope %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestJWTMiddlewareScope(t *testing.T) {
+	auth, _ := NewJWTAuth(JWTConfig{Secret: jwtTestSecret})
+	h := auth.Middleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	token := jwtTestToken(t, "HS256", map[string]any{"client_id": "reports", "scope": "read", "exp": time.Now().Add(time.Hour).Unix()}, signHS256(jwtTestSecret))
+
+	tests := []struct {
+		method, token string
+		want          int
+	}{
+		{http.MethodGet, token, http.StatusOK},
+		{http.MethodDelete, token, http.StatusForbidden},
+		{http.MethodGet, token + "x", http.StatusUnauthorized},
+		{http.MethodGet, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/v2/files/x", nil)
+		if tt.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s with token %v: status %d, want %d", tt.method, tt.token != "", w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// Limity podle klienta: middleware "keylimit" běží až po apikey/jwt, takže zná jméno klíče nebo
+// klienta tokenu; anonymní požadavky se počítají podle IP. Chrání SQLite metadata před jedním
+// agresivním klientem – počtem požadavků za sekundu i počtem současně běžících uploadů.
+
+// uploadRoutes are the routes storing file content (concurrent upload limit)
+var uploadRoutes = []string{
+	"POST /base/files/upload",
+	"POST /base/files/upload/{$}",
+	"POST /v2/files/upload",
+	"POST /v2/files/upload/{$}",
+	"PUT /v2/files/upload",
+	"PUT /v2/files/{name}",
+	"PATCH /v2/uploads/{id}",
+	"POST /v2/files/tmp",
+}
+
+// KeyRateLimitConfig configures the keylimit middleware
+type KeyRateLimitConfig struct {
+	Rate           float64            // requests per second per client, 0 = unlimited (KEY_RATE_LIMIT)
+	Burst          int                // bucket size, 0 = Rate rounded up (KEY_RATE_LIMIT_BURST)
+	Rates          map[string]float64 // rates of single clients, 0 = unlimited (KEY_RATE_LIMITS)
+	MaxUploads     int                // concurrent uploads per client, 0 = unlimited (KEY_MAX_CONCURRENT_UPLOADS)
+	TrustForwarded bool               // client IP of anonymous requests from X-Forwarded-For / X-Real-IP
+}
+
+// KeyRateLimiter limits requests and concurrent uploads per client (API key name, token client) or,
+// for anonymous requests, per client IP
+type KeyRateLimiter struct {
+	cfg       KeyRateLimitConfig
+	requests  *RateLimiter            // buckets of clients without their own rate, nil = unlimited
+	overrides map[string]*RateLimiter // KEY_RATE_LIMITS, nil value = unlimited
+
+	mu      sync.Mutex
+	uploads map[string]int // uploads in progress per client
+}
+
+// NewKeyRateLimiter creates the per-client limiter
+func NewKeyRateLimiter(cfg KeyRateLimitConfig) *KeyRateLimiter {
+	l := &KeyRateLimiter{cfg: cfg, overrides: make(map[string]*RateLimiter), uploads: make(map[string]int)}
+	if cfg.Rate > 0 {
+		l.requests = NewRateLimiter(cfg.Rate, cfg.Burst, cfg.TrustForwarded)
+	}
+	for client, rate := range cfg.Rates {
+		l.overrides[client] = nil
+		if rate > 0 {
+			l.overrides[client] = NewRateLimiter(rate, cfg.Burst, cfg.TrustForwarded)
+		}
+	}
+	return l
+}
+
+// ParseKeyRateLimits parses per-client rates "client=rate, ...", e.g. "importer=200, gallery=0"
+// (0 = unlimited)
+func ParseKeyRateLimits(value string) (map[string]float64, error) {
+	rates := map[string]float64{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		client, val, ok := strings.Cut(part, "=")
+		client = strings.TrimSpace(client)
+		if !ok || client == "" {
+			return nil, fmt.Errorf("%q: expected client=rate", part)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("client %s: invalid rate %q", client, strings.TrimSpace(val))
+		}
+		rates[client] = rate
+	}
+	return rates, nil
+}
+
+// clientKey returns the client name of an identified request ("admin" for admin credentials, as in
+// admission control), otherwise "ip:<client IP>"
+func (l *KeyRateLimiter) clientKey(r *http.Request) (key string, identified bool) {
+	if u, ok := r.Context().Value(usageCtxKey{}).(*requestUsage); ok {
+		if client := u.clientName(); client != anonymousClient {
+			return client, true
+		}
+	}
+	if isAdminRequest(r) {
+		return "admin", true
+	}
+	return "ip:" + requestIP(r, l.cfg.TrustForwarded), false
+}
+
+// allow takes a request token of the client
+func (l *KeyRateLimiter) allow(key string, identified bool, now time.Time) (bool, time.Duration) {
+	limiter := l.requests
+	if identified {
+		if override, ok := l.overrides[key]; ok {
+			limiter = override
+		}
+	}
+	if limiter == nil {
+		return true, 0
+	}
+	return limiter.allow(key, now)
+}
+
+// acquireUpload takes an upload slot of the client, false when all are in use
+func (l *KeyRateLimiter) acquireUpload(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.uploads[key] >= l.cfg.MaxUploads {
+		return false
+	}
+	l.uploads[key]++
+	return true
+}
+
+func (l *KeyRateLimiter) releaseUpload(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.uploads[key]--; l.uploads[key] <= 0 {
+		delete(l.uploads, key)
+	}
+}
+
+// Middleware rejects requests of a client over its rate or concurrent upload limit with 429 and
+// Retry-After. A nil limiter lets all requests through.
+func (l *KeyRateLimiter) Middleware(group string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, identified := l.clientKey(r)
+			if ok, wait := l.allow(key, identified, time.Now()); !ok {
+				RecordKeyRateLimited(group, "rate")
+				utils.Info("RATE_LIMIT", "Rejected %s %s, client=%s, group=%s, reason=rate", r.Method, r.URL.Path, key, group)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+			if l.cfg.MaxUploads > 0 && slices.Contains(uploadRoutes, r.Pattern) {
+				if !l.acquireUpload(key) {
+					RecordKeyRateLimited(group, "uploads")
+					utils.Info("RATE_LIMIT", "Rejected %s %s, client=%s, group=%s, reason=uploads", r.Method, r.URL.Path, key, group)
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, fmt.Sprintf("Too Many Requests: at most %d concurrent uploads per client", l.cfg.MaxUploads), http.StatusTooManyRequests)
+					return
+				}
+				defer l.releaseUpload(key)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
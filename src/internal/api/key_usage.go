@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// keyUsageTracker collects per-client monthly counters between flushes to the database
+type keyUsageTracker struct {
+	mu      sync.Mutex
+	pending map[keyUsageKey]*storage.KeyUsage
+}
+
+type keyUsageKey struct {
+	month  string
+	client string
+}
+
+var globalKeyUsage = &keyUsageTracker{pending: make(map[keyUsageKey]*storage.KeyUsage)}
+
+// usageMonth formats the accounting month of a request (UTC)
+func usageMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+func (t *keyUsageTracker) add(now time.Time, client string, bytesIn, bytesOut int64, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := keyUsageKey{month: usageMonth(now), client: client}
+	u, ok := t.pending[key]
+	if !ok {
+		u = &storage.KeyUsage{Month: key.month, Client: client}
+		t.pending[key] = u
+	}
+	u.Requests++
+	u.BytesIn += bytesIn
+	u.BytesOut += bytesOut
+	if failed {
+		u.Errors++
+	}
+}
+
+// take returns the pending counters and resets them
+func (t *keyUsageTracker) take() []storage.KeyUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]storage.KeyUsage, 0, len(t.pending))
+	for _, u := range t.pending {
+		list = append(list, *u)
+	}
+	t.pending = make(map[keyUsageKey]*storage.KeyUsage)
+	return list
+}
+
+// restore puts back counters whose flush failed, so they are retried with the next flush
+func (t *keyUsageTracker) restore(list []storage.KeyUsage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, d := range list {
+		key := keyUsageKey{month: d.Month, client: d.Client}
+		u, ok := t.pending[key]
+		if !ok {
+			u = &storage.KeyUsage{Month: d.Month, Client: d.Client}
+			t.pending[key] = u
+		}
+		addKeyUsage(u, d)
+	}
+}
+
+func addKeyUsage(dst *storage.KeyUsage, d storage.KeyUsage) {
+	dst.Requests += d.Requests
+	dst.BytesIn += d.BytesIn
+	dst.BytesOut += d.BytesOut
+	dst.Errors += d.Errors
+}
+
+// snapshot returns a copy of the not yet flushed counters
+func (t *keyUsageTracker) snapshot() []storage.KeyUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]storage.KeyUsage, 0, len(t.pending))
+	for _, u := range t.pending {
+		list = append(list, *u)
+	}
+	return list
+}
+
+// FlushKeyUsage persists the collected per-client counters
+func FlushKeyUsage(meta *storage.MetadataSQL) error {
+	deltas := globalKeyUsage.take()
+	if err := meta.AddKeyUsage(deltas); err != nil {
+		globalKeyUsage.restore(deltas)
+		return err
+	}
+	return nil
+}
+
+// StartKeyUsageFlusher periodically persists per-client usage counters
+func StartKeyUsageFlusher(meta *storage.MetadataSQL, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := FlushKeyUsage(meta); err != nil {
+				utils.Error("USAGE", "Failed to persist API key usage: %v", err)
+			}
+		}
+	}()
+}
+
+var monthPattern = regexp.MustCompile(`^\d{4}-(0[1-9]|1[0-2])$`)
+
+// HandleSystemUsageKeys returns persisted monthly usage per API key
+// @Summary Get usage per API key
+// @Description Returns requests, request/response body bytes and error responses (4xx/5xx) per client (API key name) and calendar month (UTC). Counters survive restarts; the last few seconds may still be in memory and are included. Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Param month query string false "Month (YYYY-MM), default all months"
+// @Param client query string false "Client (API key name) filter"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {string} string "Invalid month"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /system/usage/keys [get]
+func (s *Server) HandleSystemUsageKeys(w http.ResponseWriter, r *http.Request) {
+	month := r.URL.Query().Get("month")
+	if month != "" && !monthPattern.MatchString(month) {
+		http.Error(w, "Invalid month, expected YYYY-MM", http.StatusBadRequest)
+		return
+	}
+	client := r.URL.Query().Get("client")
+
+	persisted, err := s.FileService.MetaStore.GetKeyUsage(month, client)
+	if err != nil {
+		utils.Error("USAGE", "Failed to read API key usage: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Sloučení s ještě neuloženými čítači
+	merged := make(map[keyUsageKey]*storage.KeyUsage)
+	for i := range persisted {
+		u := persisted[i]
+		merged[keyUsageKey{month: u.Month, client: u.Client}] = &u
+	}
+	for _, d := range globalKeyUsage.snapshot() {
+		if (month != "" && d.Month != month) || (client != "" && d.Client != client) {
+			continue
+		}
+		key := keyUsageKey{month: d.Month, client: d.Client}
+		u, ok := merged[key]
+		if !ok {
+			u = &storage.KeyUsage{Month: d.Month, Client: d.Client}
+			merged[key] = u
+		}
+		addKeyUsage(u, d)
+	}
+
+	keys := make([]storage.KeyUsage, 0, len(merged))
+	for _, u := range merged {
+		keys = append(keys, *u)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Month != keys[j].Month {
+			return keys[i].Month > keys[j].Month
+		}
+		return keys[i].Client < keys[j].Client
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"month": month,
+		"keys":  keys,
+	})
+}
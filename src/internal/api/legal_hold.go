@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+const (
+	defaultLegalHoldListLimit = 100
+	maxLegalHoldListLimit     = 10000
+)
+
+// LegalHoldRequest is the optional body of POST /system/files/{id}/hold
+type LegalHoldRequest struct {
+	Reason string `json:"reason" example:"case 2026-117"`
+}
+
+// LegalHoldListResponse is the body of GET /system/files/held
+type LegalHoldListResponse struct {
+	Count int                 `json:"count" example:"1"`
+	Holds []storage.LegalHold `json:"holds"`
+}
+
+// HandleSystemFileHold places a file under legal hold
+// @Summary Place a file under legal hold
+// @Description Deleting the file (DELETE /base/files/delete/{uuid}), purging its tag (/system/purge) and removing it by expiry cleanup fail with 423 Locked until the hold is released; an expired held file is kept. Holding a held file again updates a non-empty reason and keeps held_at. Requires admin Basic auth.
+// @Tags 04 - System
+// @Accept json
+// @Produce json
+// @Param id path string true "File UUID"
+// @Param request body LegalHoldRequest false "Reason of the hold"
+// @Success 200 {object} storage.LegalHold
+// @Failure 400 {string} string "Invalid request"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 404 {string} string "File not found"
+// @Router /system/files/{id}/hold [post]
+func (s *Server) HandleSystemFileHold(w http.ResponseWriter, r *http.Request) {
+	fileID := r.PathValue("id")
+	var req LegalHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	hold, err := s.FileService.SetLegalHold(fileID, req.Reason)
+	if err == nil {
+		utils.Info("ADMIN", "Legal hold placed: file_id=%s, reason=%q, remote=%s", fileID, req.Reason, r.RemoteAddr)
+	}
+	writeFileOpResult(w, "hold", "file_id="+fileID, hold, err)
+}
+
+// HandleSystemFileRelease lifts a legal hold
+// @Summary Release a legal hold
+// @Description Lifts the legal hold of the file and returns the released hold; the file can be deleted again and an expired file is removed by the next cleanup. Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Param id path string true "File UUID"
+// @Success 200 {object} storage.LegalHold
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 404 {string} string "File not held"
+// @Router /system/files/{id}/release [post]
+func (s *Server) HandleSystemFileRelease(w http.ResponseWriter, r *http.Request) {
+	fileID := r.PathValue("id")
+	hold, err := s.FileService.ReleaseLegalHold(fileID)
+	if err == nil {
+		utils.Info("ADMIN", "Legal hold released: file_id=%s, remote=%s", fileID, r.RemoteAddr)
+	}
+	writeFileOpResult(w, "release", "file_id="+fileID, hold, err)
+}
+
+// HandleSystemLegalHolds lists legal holds
+// @Summary List legal holds
+// @Description Lists the files under legal hold, most recent hold first. Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Param limit query int false "Max number of holds (default 100, max 10000)"
+// @Success 200 {object} LegalHoldListResponse
+// @Failure 400 {string} string "Invalid limit"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /system/files/held [get]
+func (s *Server) HandleSystemLegalHolds(w http.ResponseWriter, r *http.Request) {
+	limit := defaultLegalHoldListLimit
+	if val := r.URL.Query().Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxLegalHoldListLimit)
+	}
+
+	holds, err := s.FileService.MetaStore.ListLegalHolds(limit)
+	if err != nil {
+		utils.Error("ADMIN", "Failed to list legal holds: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LegalHoldListResponse{Count: len(holds), Holds: holds})
+}
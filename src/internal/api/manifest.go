@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// HandleSystemVolumeManifest returns the checksum manifest of a volume
+// @Summary Get volume checksum manifest
+// @Description Lists the live blobs of the volume with offset, stored size, compression, CRC32 footer and content hash, and a SHA-256 digest of the entries that doesn't depend on the creation time, so manifests of replicas or backups can be compared. With MANIFEST_SIGNING_KEY the digest is signed (HMAC-SHA256). A copy of the volume file is checked against the manifest with "compact-tool volumes verify-manifest" without transferring blob data. Blobs whose header doesn't match the database are listed in errors.
+// @Tags 04 - System
+// @Produce json
+// @Param id path int true "Volume ID"
+// @Success 200 {object} storage.VolumeManifest
+// @Failure 400 {string} string "Invalid volume ID"
+// @Failure 404 {string} string "Volume not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /system/volumes/{id}/manifest [get]
+func (s *Server) HandleSystemVolumeManifest(w http.ResponseWriter, r *http.Request) {
+	volumeID, err := pathInt64(r, "id")
+	if err != nil || volumeID <= 0 {
+		http.Error(w, "Invalid volume ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.FileService.Store.VolumePath(volumeID); err != nil {
+		http.Error(w, "Volume not found", http.StatusNotFound)
+		return
+	}
+
+	manifest, err := s.FileService.Store.BuildVolumeManifest(volumeID, s.FileService.MetaStore)
+	if err != nil {
+		utils.Error("MANIFEST", "Failed to build manifest of volume %d: %v", volumeID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	manifest.Sign(s.ManifestSigningKey)
+	if len(manifest.Errors) > 0 {
+		utils.Warn("MANIFEST", "Volume %d: %d blobs don't match the database: %s", volumeID, len(manifest.Errors), strings.Join(manifest.Errors, "; "))
+	}
+	utils.Info("MANIFEST", "Volume %d: blobs=%d, bytes=%d, digest=%s", volumeID, manifest.Blobs, manifest.Bytes, manifest.Digest)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
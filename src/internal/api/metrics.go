@@ -7,6 +7,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -29,6 +31,14 @@ var (
 		[]string{"method", "path"},
 	)
 
+	httpRequestBodyBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_request_body_bytes_total",
+			Help: "Total request body bytes received, by endpoint and client.",
+		},
+		[]string{"path", "client"},
+	)
+
 	httpRequestsInFlight = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "http_requests_in_flight",
@@ -87,12 +97,215 @@ var (
 			Help: "Total bytes read from BLOB storage.",
 		},
 	)
+
+	accelRedirectsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "download_accel_redirects_total",
+			Help: "Total number of downloads handed off to the web server (X-Accel-Redirect / X-Sendfile2).",
+		},
+		[]string{"mode"},
+	)
+
+	downloadNotModifiedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "download_not_modified_total",
+			Help: "Total number of downloads answered with 304 Not Modified (If-None-Match with the content hash).",
+		},
+	)
+
+	// Image metriky
+	imageRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "image_requests_total",
+			Help: "Total number of image/variant requests by variant, source type and result.",
+		},
+		[]string{"variant", "source", "status"},
+	)
+
+	imageProcessingDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "image_processing_duration_seconds",
+			Help:    "Duration of variant generation (resize or PDF render) in seconds.",
+			Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+		[]string{"variant", "source"},
+	)
+
+	imageInputBytes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "image_input_bytes",
+			Help:    "Size of source files processed into variants.",
+			Buckets: prometheus.ExponentialBuckets(16<<10, 4, 8), // 16KB .. 256MB
+		},
+		[]string{"source"},
+	)
+
+	imageOutputBytes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "image_output_bytes",
+			Help:    "Size of generated variants.",
+			Buckets: prometheus.ExponentialBuckets(1<<10, 4, 8), // 1KB .. 16MB
+		},
+		[]string{"variant"},
+	)
+
+	pdftoppmFailuresTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "pdftoppm_failures_total",
+			Help: "Total number of failed pdftoppm invocations.",
+		},
+	)
+
+	expiredAccessTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "file_expired_access_total",
+			Help: "Total number of reads of files past their expiry, by endpoint kind and result (served or denied).",
+		},
+		[]string{"kind", "result"},
+	)
+
+	// Databázové metriky: dlouhé zápisové transakce drží zámek SQLite a brzdí uploady
+	dbTxDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "db_tx_duration_seconds",
+			Help:    "Duration of metadata write transactions (delete_file, compact_volume) from begin to commit or rollback.",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"op", "result"},
+	)
+
+	dbTxRows = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "db_tx_rows",
+			Help:    "Number of rows changed by metadata write transactions.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 10), // 1 .. 262144
+		},
+		[]string{"op"},
+	)
+
+	admissionRejectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "admission_rejected_total",
+			Help: "Total number of requests shed by admission control, by route group, client priority and the limit that was reached.",
+		},
+		[]string{"group", "priority", "reason"},
+	)
+
+	admissionPressure = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "admission_pressure",
+			Help: "Highest ratio of a measured load (in-flight requests, temp dir usage, DB wait) to its admission limit; low priority is shed from 0.75, normal from 1.",
+		},
+	)
+
+	clusterForwardedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cluster_forwarded_total",
+			Help: "Total number of GET requests for files not in the local metadata, by owning node and result (redirect, proxy, not_found).",
+		},
+		[]string{"node", "result"},
+	)
+
+	replicationDivergence = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "replication_divergence",
+			Help: "Divergence from the replica found by the last verification: relative difference of file and blob counts, share of sampled files missing or different.",
+		},
+		[]string{"check"},
+	)
+
+	replicationDiverged = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "replication_diverged",
+			Help: "1 when the last replica verification exceeded a divergence threshold or failed, 0 otherwise.",
+		},
+	)
+
+	replicationVerifyRunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "replication_verify_runs_total",
+			Help: "Total number of replica verifications, by result (ok, diverged, error).",
+		},
+		[]string{"status"},
+	)
+
+	replicationVerifyLastSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "replication_verify_last_success_timestamp_seconds",
+			Help: "Unix time of the last replica verification that compared the replica (ok or diverged), 0 before the first one.",
+		},
+	)
+
+	backupRunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "backup_runs_total",
+			Help: "Total number of metadata backups pushed to S3 (BACKUP_S3_BUCKET), by result (ok, error).",
+		},
+		[]string{"status"},
+	)
+
+	backupLastSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "backup_last_success_timestamp_seconds",
+			Help: "Unix time of the last metadata backup pushed to S3 completely, 0 before the first one.",
+		},
+	)
+
+	backupUploadedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "backup_uploaded_bytes_total",
+			Help: "Total bytes uploaded by metadata backups, by object kind (database, log).",
+		},
+		[]string{"kind"},
+	)
+
+	uploadHookDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "upload_hook_duration_seconds",
+			Help:    "Duration of upload hook calls (UPLOAD_HOOK_URL), by result (accepted, rejected, error).",
+			Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+		[]string{"result"},
+	)
+
+	uploadSessionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "upload_sessions_total",
+			Help: "Total number of resumable upload sessions, by event (created, completed, aborted, expired).",
+		},
+		[]string{"event"},
+	)
+
+	uploadRejectedOversizeTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "upload_rejected_oversize_total",
+			Help: "Total number of uploads refused with 413 for exceeding MAX_UPLOAD_FILE_SIZE, by kind (multipart, raw, session).",
+		},
+		[]string{"kind"},
+	)
+
+	httpRateLimitedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_rate_limited_total",
+			Help: "Total number of requests rejected by the rate limiter, by route group.",
+		},
+		[]string{"group"},
+	)
+
+	keyRateLimitedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_key_rate_limited_total",
+			Help: "Total number of requests rejected by the per-client limiter (keylimit), by route group and reason (rate, uploads).",
+		},
+		[]string{"group", "reason"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(httpRequestsTotal)
 	prometheus.MustRegister(httpRequestDuration)
 	prometheus.MustRegister(httpRequestsInFlight)
+	prometheus.MustRegister(httpRequestBodyBytes)
 	prometheus.MustRegister(uploadOpsTotal)
 	prometheus.MustRegister(uploadDuration)
 	prometheus.MustRegister(dedupHitsTotal)
@@ -100,6 +313,118 @@ func init() {
 	prometheus.MustRegister(storageTotalBytes)
 	prometheus.MustRegister(blobBytesWritten)
 	prometheus.MustRegister(blobBytesRead)
+	prometheus.MustRegister(accelRedirectsTotal)
+	prometheus.MustRegister(downloadNotModifiedTotal)
+	prometheus.MustRegister(imageRequestsTotal)
+	prometheus.MustRegister(imageProcessingDuration)
+	prometheus.MustRegister(imageInputBytes)
+	prometheus.MustRegister(imageOutputBytes)
+	prometheus.MustRegister(pdftoppmFailuresTotal)
+	prometheus.MustRegister(httpRateLimitedTotal)
+	prometheus.MustRegister(keyRateLimitedTotal)
+	prometheus.MustRegister(expiredAccessTotal)
+	prometheus.MustRegister(dbTxDuration)
+	prometheus.MustRegister(dbTxRows)
+	prometheus.MustRegister(admissionRejectedTotal)
+	prometheus.MustRegister(admissionPressure)
+	prometheus.MustRegister(clusterForwardedTotal)
+	prometheus.MustRegister(replicationDivergence)
+	prometheus.MustRegister(replicationDiverged)
+	prometheus.MustRegister(replicationVerifyRunsTotal)
+	prometheus.MustRegister(uploadHookDuration)
+	prometheus.MustRegister(replicationVerifyLastSuccess)
+	prometheus.MustRegister(backupRunsTotal)
+	prometheus.MustRegister(backupLastSuccess)
+	prometheus.MustRegister(backupUploadedBytes)
+	prometheus.MustRegister(uploadSessionsTotal)
+	prometheus.MustRegister(uploadRejectedOversizeTotal)
+}
+
+// RegisterVolumeLockMetrics exports contention counters of the per-volume locks
+func RegisterVolumeLockMetrics(stats func() storage.VolumeLockStats) {
+	prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "volume_lock_acquisitions_total",
+		Help: "Total number of acquired volume locks (reads, writes, compaction).",
+	}, func() float64 { return float64(stats().Acquired) }))
+	prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "volume_lock_contended_total",
+		Help: "Total number of volume lock acquisitions that had to wait.",
+	}, func() float64 { return float64(stats().Contended) }))
+	prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "volume_lock_wait_seconds_total",
+		Help: "Total time spent waiting for contended volume locks.",
+	}, func() float64 { return stats().Wait.Seconds() }))
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "volume_locks_active",
+		Help: "Number of volumes with a lock currently held or awaited.",
+	}, func() float64 { return float64(stats().Active) }))
+}
+
+// detectorCollector exports the per-detector counters of the file type detector registry
+type detectorCollector struct {
+	stats                           func() []utils.DetectorStats
+	calls, matches, errors, seconds *prometheus.Desc
+}
+
+// RegisterDetectorMetrics exports the counters of the file type detectors
+func RegisterDetectorMetrics(stats func() []utils.DetectorStats) {
+	labels := []string{"detector"}
+	prometheus.MustRegister(&detectorCollector{
+		stats:   stats,
+		calls:   prometheus.NewDesc("file_detector_calls_total", "Total number of file type detector runs.", labels, nil),
+		matches: prometheus.NewDesc("file_detector_matches_total", "Total number of files recognized by a file type detector.", labels, nil),
+		errors:  prometheus.NewDesc("file_detector_errors_total", "Total number of failed file type detector runs.", labels, nil),
+		seconds: prometheus.NewDesc("file_detector_seconds_total", "Total time spent in a file type detector.", labels, nil),
+	})
+}
+
+func (c *detectorCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.calls
+	ch <- c.matches
+	ch <- c.errors
+	ch <- c.seconds
+}
+
+func (c *detectorCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, st := range c.stats() {
+		ch <- prometheus.MustNewConstMetric(c.calls, prometheus.CounterValue, float64(st.Calls), st.Name)
+		ch <- prometheus.MustNewConstMetric(c.matches, prometheus.CounterValue, float64(st.Matches), st.Name)
+		ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(st.Errors), st.Name)
+		ch <- prometheus.MustNewConstMetric(c.seconds, prometheus.CounterValue, st.Duration.Seconds(), st.Name)
+	}
+}
+
+// diskCollector exports the size and free space of the filesystems holding the data directories,
+// read on every scrape
+type diskCollector struct {
+	dirs       map[string]string // name -> path
+	size, free *prometheus.Desc
+}
+
+// RegisterDiskMetrics exports disk_size_bytes and disk_free_bytes of the named directories
+func RegisterDiskMetrics(dirs map[string]string) {
+	labels := []string{"dir"}
+	prometheus.MustRegister(&diskCollector{
+		dirs: dirs,
+		size: prometheus.NewDesc("disk_size_bytes", "Size of the filesystem holding a data directory.", labels, nil),
+		free: prometheus.NewDesc("disk_free_bytes", "Space available to the server on the filesystem holding a data directory.", labels, nil),
+	})
+}
+
+func (c *diskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.size
+	ch <- c.free
+}
+
+func (c *diskCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, path := range c.dirs {
+		total, free, err := storage.DiskUsage(path)
+		if err != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.size, prometheus.GaugeValue, float64(total), name)
+		ch <- prometheus.MustNewConstMetric(c.free, prometheus.GaugeValue, float64(free), name)
+	}
 }
 
 // UpdateStorageMetrics updates the storage size metrics
@@ -118,10 +443,126 @@ func RecordBlobBytesRead(bytes int) {
 	blobBytesRead.Add(float64(bytes))
 }
 
+// RecordAccelRedirect records a download served by the fronting web server
+func RecordAccelRedirect(mode string) {
+	accelRedirectsTotal.WithLabelValues(mode).Inc()
+}
+
+// RecordDownloadNotModified records a download revalidated by If-None-Match without sending content
+func RecordDownloadNotModified() {
+	downloadNotModifiedTotal.Inc()
+}
+
+// RecordExpiredAccess counts a read of an expired file; kind is download, image or info
+func RecordExpiredAccess(kind string, denied bool) {
+	result := "served"
+	if denied {
+		result = "denied"
+	}
+	expiredAccessTotal.WithLabelValues(kind, result).Inc()
+}
+
+// RecordRateLimited counts a request rejected by the rate limiter
+func RecordRateLimited(group string) {
+	httpRateLimitedTotal.WithLabelValues(group).Inc()
+}
+
+// RecordKeyRateLimited counts a request rejected by the per-client limiter
+func RecordKeyRateLimited(group, reason string) {
+	keyRateLimitedTotal.WithLabelValues(group, reason).Inc()
+}
+
+// RecordAdmissionRejected counts a request shed by admission control
+func RecordAdmissionRejected(group, priority, reason string) {
+	admissionRejectedTotal.WithLabelValues(group, priority, reason).Inc()
+}
+
+// RecordClusterForward counts a request forwarded to the owning node (node is empty when no node has the file)
+func RecordClusterForward(node, result string) {
+	clusterForwardedTotal.WithLabelValues(node, result).Inc()
+}
+
+// RecordUploadHook records an upload hook call; it is the service.UploadHook observer of the server
+func RecordUploadHook(result string, d time.Duration) {
+	uploadHookDuration.WithLabelValues(result).Observe(d.Seconds())
+}
+
+// RecordUploadSessions counts n resumable upload sessions reaching event
+func RecordUploadSessions(event string, n int) {
+	uploadSessionsTotal.WithLabelValues(event).Add(float64(n))
+}
+
+// RecordUploadTooLarge counts an upload refused for exceeding the upload size limit
+func RecordUploadTooLarge(kind string) {
+	uploadRejectedOversizeTotal.WithLabelValues(kind).Inc()
+}
+
+// RecordReplicaVerify exports the result of a replica verification; a failed run keeps the
+// divergences of the last successful one
+func RecordReplicaVerify(report *ReplicaVerifyReport) {
+	replicationVerifyRunsTotal.WithLabelValues(report.Status).Inc()
+	if report.Status == ReplicaStatusOK {
+		replicationDiverged.Set(0)
+	} else {
+		replicationDiverged.Set(1)
+	}
+	if report.Status == ReplicaStatusError {
+		return
+	}
+	replicationVerifyLastSuccess.SetToCurrentTime()
+	replicationDivergence.WithLabelValues("files").Set(report.Files.Divergence)
+	replicationDivergence.WithLabelValues("blobs").Set(report.Blobs.Divergence)
+	replicationDivergence.WithLabelValues("sample").Set(report.Sample.Divergence)
+}
+
+// RecordBackup exports the result of a metadata backup run
+func RecordBackup(report *BackupReport) {
+	backupRunsTotal.WithLabelValues(report.Status).Inc()
+	backupUploadedBytes.WithLabelValues("database").Add(float64(report.DatabaseBytes))
+	backupUploadedBytes.WithLabelValues("log").Add(float64(report.LogBytes))
+	if report.Status == BackupStatusOK {
+		backupLastSuccess.SetToCurrentTime()
+	}
+}
+
+// RecordDBTx records a metadata write transaction; it is the storage.TxObserver of the server
+func RecordDBTx(op string, d time.Duration, rows int64, committed bool) {
+	result := "commit"
+	if !committed {
+		result = "rollback"
+	}
+	dbTxDuration.WithLabelValues(op, result).Observe(d.Seconds())
+	dbTxRows.WithLabelValues(op).Observe(float64(rows))
+}
+
+// variantLabel returns the metric label for an image variant ("original" for no variant)
+func variantLabel(variant string) string {
+	switch variant {
+	case "":
+		return "original"
+	case "thumb", "sm", "md", "lg", "invalid":
+		return variant
+	}
+	return "custom" // signed {width}x{height}, one label to keep the cardinality bounded
+}
+
+// RecordImageRequest records the result of an image request
+func RecordImageRequest(variant, source, status string) {
+	imageRequestsTotal.WithLabelValues(variantLabel(variant), source, status).Inc()
+}
+
+// RecordImageProcessing records a generated variant
+func RecordImageProcessing(variant, source string, seconds float64, inputBytes, outputBytes int) {
+	imageProcessingDuration.WithLabelValues(variantLabel(variant), source).Observe(seconds)
+	imageInputBytes.WithLabelValues(source).Observe(float64(inputBytes))
+	imageOutputBytes.WithLabelValues(variantLabel(variant)).Observe(float64(outputBytes))
+}
+
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	written    int64 // response body bytes (egress accounting)
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -129,6 +570,12 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(p)
+	rw.written += int64(n)
+	return n, err
+}
+
 var uuidPattern = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
 
 // normalizePath replaces UUIDs and numeric path segments with placeholder tokens
@@ -173,6 +620,11 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 		// Wrap response writer to capture status code
 		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
+		// Count request body bytes (ingress accounting)
+		body := &countingReadCloser{ReadCloser: r.Body}
+		r.Body = body
+		r, usage := withRequestUsage(r)
+
 		next.ServeHTTP(rw, r)
 
 		duration := time.Since(start).Seconds()
@@ -181,5 +633,14 @@ func MetricsMiddleware(next http.Handler) http.Handler {
 		// Record metrics with normalized path
 		httpRequestsTotal.WithLabelValues(r.Method, normalizedPath, strconv.Itoa(rw.statusCode)).Inc()
 		httpRequestDuration.WithLabelValues(r.Method, normalizedPath).Observe(duration)
+		globalSLO.record(start, r.Method, normalizedPath, duration, rw.statusCode)
+		client := usage.clientName()
+		usagePath := normalizedPath
+		if r.Pattern == "" {
+			usagePath = usageUnmatched
+		}
+		recordRequestIngress(usagePath, client, body.n.Load())
+		globalKeyUsage.add(time.Now(), client, body.n.Load(), rw.written, rw.statusCode >= 400)
+		globalActivity.record(start, r, client, rw.statusCode, rw.Header().Get("Content-Type"), usage.uploadedFiles(), body.n.Load(), rw.written)
 	})
 }
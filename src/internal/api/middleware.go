@@ -0,0 +1,308 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// Middleware wraps a handler, e.g. with authentication or rate limiting
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middlewares into one; the first is the outermost
+func Chain(mws ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+		return h
+	}
+}
+
+// Route groups, each has its own middleware chain
+const (
+	RouteGroupPublic = "public" // /health, /metrics, /docs/, /openapi.json, /admin/icons/
+	RouteGroupFiles  = "files"  // /base/files/*, /v2/files/*, /v2/tags
+	RouteGroupImages = "images" // /v2/images/*
+	RouteGroupSystem = "system" // /system/* kromě operací adminu
+	RouteGroupAdmin  = "admin"  // admin UI a operace nad soubory, Basic auth vždy
+)
+
+// Optional middlewares of a route group, applied in this order: log, cors, ratelimit, auth,
+// apikey/jwt, keylimit, admission (CORS preflight must not hit auth, rate limiting also throttles
+// password and key guessing, keylimit and admission control need the authenticated client)
+const (
+	MiddlewareLog       = "log"       // access log
+	MiddlewareCORS      = "cors"      // CORS_ALLOWED_ORIGINS
+	MiddlewareRateLimit = "ratelimit" // RATE_LIMIT per client IP
+	MiddlewareAuth      = "auth"      // admin Basic auth
+	MiddlewareAPIKey    = "apikey"    // X-API-Key from the api_keys table (or admin Basic auth)
+	MiddlewareJWT       = "jwt"       // Authorization: Bearer token of JWT_SECRET / JWT_PUBLIC_KEY_FILE
+	MiddlewareKeyLimit  = "keylimit"  // KEY_RATE_LIMIT and KEY_MAX_CONCURRENT_UPLOADS per API key / token client / IP
+	MiddlewareAdmission = "admission" // ADMISSION_* load shedding by client priority
+)
+
+var (
+	routeGroups        = []string{RouteGroupPublic, RouteGroupFiles, RouteGroupImages, RouteGroupSystem, RouteGroupAdmin}
+	optionalMiddleware = []string{MiddlewareLog, MiddlewareCORS, MiddlewareRateLimit, MiddlewareAuth, MiddlewareAPIKey, MiddlewareJWT, MiddlewareKeyLimit, MiddlewareAdmission}
+)
+
+// RouteMiddleware are the optional middlewares enabled per route group (ROUTE_MIDDLEWARE).
+// Metrics and panic recovery wrap all routes, the admin group always has auth.
+type RouteMiddleware map[string][]string
+
+// ParseRouteMiddleware parses groups separated by ";", each "group=middleware,...", e.g.
+//
+//	files=log,cors,ratelimit; images=cors,ratelimit; system=auth
+func ParseRouteMiddleware(value string) (RouteMiddleware, error) {
+	config := RouteMiddleware{}
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		group, list, ok := strings.Cut(part, "=")
+		group = strings.ToLower(strings.TrimSpace(group))
+		if !ok {
+			return nil, fmt.Errorf("group %q: expected group=middleware,...", part)
+		}
+		if !slices.Contains(routeGroups, group) {
+			return nil, fmt.Errorf("unknown route group %q (use %s)", group, strings.Join(routeGroups, ", "))
+		}
+		for _, name := range strings.Split(list, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if !slices.Contains(optionalMiddleware, name) {
+				return nil, fmt.Errorf("group %s: unknown middleware %q (use %s)", group, name, strings.Join(optionalMiddleware, ", "))
+			}
+			if !slices.Contains(config[group], name) {
+				config[group] = append(config[group], name)
+			}
+		}
+	}
+	return config, nil
+}
+
+// Uses reports whether any route group enables the middleware
+func (m RouteMiddleware) Uses(name string) bool {
+	for _, names := range m {
+		if slices.Contains(names, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m RouteMiddleware) enabled(group, name string) bool {
+	if group == RouteGroupAdmin && name == MiddlewareAuth {
+		return true
+	}
+	return slices.Contains(m[group], name)
+}
+
+// groupChain builds the middleware chain of a route group
+func (s *Server) groupChain(group string) Middleware {
+	var mws []Middleware
+	for _, name := range optionalMiddleware {
+		if name == MiddlewareAuth {
+			mws = append(mws, s.accessMiddleware(group))
+			continue
+		}
+		if name == MiddlewareAPIKey || name == MiddlewareJWT || !s.RouteMiddleware.enabled(group, name) {
+			continue
+		}
+		switch name {
+		case MiddlewareLog:
+			mws = append(mws, AccessLogMiddleware)
+		case MiddlewareCORS:
+			mws = append(mws, CORSMiddleware(s.CORSAllowedOrigins))
+		case MiddlewareRateLimit:
+			mws = append(mws, s.RateLimiter.Middleware(group))
+		case MiddlewareKeyLimit:
+			mws = append(mws, s.KeyRateLimiter.Middleware(group))
+		case MiddlewareAdmission:
+			mws = append(mws, s.Admission.Middleware(group))
+		}
+	}
+	return Chain(mws...)
+}
+
+// accessMiddleware is the access control of a route group: auth, apikey and/or jwt when enabled,
+// apikey also by default in the files and images groups (see apiKeyDefaultGroups).
+// apikey and jwt are alternatives: requests without a bearer token go to apikey. In the files group
+// presigned download URLs (presign.go) are checked in front of it and skip it.
+func (s *Server) accessMiddleware(group string) Middleware {
+	var mws []Middleware
+	if s.RouteMiddleware.enabled(group, MiddlewareAuth) {
+		username, password := GetAdminCredentials()
+		mws = append(mws, func(next http.Handler) http.Handler {
+			return AdminAuthMiddleware(username, password, next)
+		})
+	}
+	var apiKey Middleware
+	switch {
+	case s.RouteMiddleware.enabled(group, MiddlewareAPIKey):
+		apiKey = s.APIKeys.Middleware()
+	case s.defaultAPIKey(group):
+		apiKey = s.APIKeys.DefaultMiddleware()
+	}
+	switch {
+	case s.RouteMiddleware.enabled(group, MiddlewareJWT):
+		mws = append(mws, s.JWT.Middleware(apiKey))
+	case apiKey != nil:
+		mws = append(mws, apiKey)
+	}
+	if group == RouteGroupFiles {
+		return s.presignedDownloadMiddleware(Chain(mws...))
+	}
+	return Chain(mws...)
+}
+
+// defaultAPIKey reports whether the group gets the apikey middleware without ROUTE_MIDDLEWARE
+// (see apiKeyDefaultGroups)
+func (s *Server) defaultAPIKey(group string) bool {
+	return s.APIKeys != nil && !s.APIKeysOptional && slices.Contains(apiKeyDefaultGroups, group) &&
+		!s.RouteMiddleware.enabled(group, MiddlewareAuth) && !s.RouteMiddleware.enabled(group, MiddlewareJWT)
+}
+
+// routeTable is the mux of Routes with the state shared by its route groups
+type routeTable struct {
+	mux       *http.ServeMux
+	readOnly  readMethods             // READ_ONLY: mutating routes answer 405 (see read_only.go), nil otherwise
+	preflight map[string]http.Handler // route pattern -> preflight handler of its group, CORS groups only
+}
+
+func newRouteTable(readOnly bool) *routeTable {
+	t := &routeTable{mux: http.NewServeMux(), preflight: map[string]http.Handler{}}
+	if readOnly {
+		t.readOnly = readMethods{}
+	}
+	return t
+}
+
+// ServeHTTP passes a CORS preflight to the group of the route it asks for (by path and
+// Access-Control-Request-Method). OPTIONS routes per path can't be registered: paths of different
+// routes overlap (/v2/files/info/{uuid} and /v2/files/{uuid}/presign) and the mux refuses them as
+// conflicting, without them it answers preflight requests with 405 before the CORS middleware runs.
+func (t *routeTable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if method := r.Header.Get("Access-Control-Request-Method"); r.Method == http.MethodOptions && method != "" {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := t.mux.Handler(probe); pattern != "" {
+			if h, ok := t.preflight[pattern]; ok {
+				_, path, _ := strings.Cut(pattern, " ")
+				r.Pattern = http.MethodOptions + " " + path
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+	}
+	t.mux.ServeHTTP(w, r)
+}
+
+// routeGroup registers routes with the middleware chain of one group
+type routeGroup struct {
+	routes    *routeTable
+	chain     Middleware
+	preflight http.Handler // answers preflight requests for the routes of the group, nil without CORS
+}
+
+func (s *Server) newRouteGroup(routes *routeTable, group string) *routeGroup {
+	g := &routeGroup{routes: routes, chain: s.groupChain(group)}
+	if s.RouteMiddleware.enabled(group, MiddlewareCORS) {
+		g.preflight = g.chain(http.NotFoundHandler())
+	}
+	return g
+}
+
+// handle registers "METHOD /path"; with CORS the route gets the preflight handler of the group
+func (g *routeGroup) handle(pattern string, h http.Handler) {
+	if readOnly := g.routes.readOnly; readOnly != nil {
+		if isReadOnlyRoute(pattern) {
+			readOnly.add(pattern)
+		} else {
+			_, path, _ := strings.Cut(pattern, " ")
+			h = readOnly.readOnlyHandler(path)
+		}
+	}
+	g.routes.mux.Handle(pattern, g.chain(h))
+	if g.preflight != nil {
+		g.routes.preflight[pattern] = g.preflight
+	}
+}
+
+func (g *routeGroup) handleFunc(pattern string, h http.HandlerFunc) {
+	g.handle(pattern, h)
+}
+
+// RecoveryMiddleware turns a handler panic into a 500 response and an error log entry
+func RecoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				utils.Error("PANIC", "%s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AccessLogMiddleware logs method, path, status, response size and duration of each request
+func AccessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r)
+		utils.Info("ACCESS", "%s %s status=%d, bytes=%d, duration=%s, remote=%s", r.Method, r.URL.RequestURI(), rw.statusCode, rw.written, time.Since(start).Round(time.Millisecond), r.RemoteAddr)
+	})
+}
+
+// CORSMiddleware allows cross-origin requests from the given origins ("*" for any) and answers
+// preflight requests. Requests from other origins pass without CORS headers, so the browser
+// blocks them.
+func CORSMiddleware(origins []string) Middleware {
+	anyOrigin := slices.Contains(origins, "*")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			if !anyOrigin {
+				h.Add("Vary", "Origin") // odpověď se liší podle Origin, cache ji nesmí sdílet
+			}
+			origin := r.Header.Get("Origin")
+			if origin == "" || (!anyOrigin && !slices.Contains(origins, origin)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			h.Set("Access-Control-Expose-Headers", "Content-Disposition, Content-Length, ETag, Location, Retry-After, X-Cumulus-Dedup, X-Cumulus-Blob-Id, "+
+				"Accept-Ranges, Content-Range, Upload-Offset, Upload-Length")
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE")
+				if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+					h.Set("Access-Control-Allow-Headers", reqHeaders)
+				}
+				h.Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
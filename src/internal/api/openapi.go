@@ -0,0 +1,323 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+	"github.com/swaggo/swag"
+)
+
+// OpenAPI 3 dokument se generuje ze swaggo (Swagger 2.0) anotací – zdroj pravdy zůstávají
+// anotace u handlerů, /openapi.json je jen jiný formát pro generátory klientů.
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+	openAPIErr  error
+)
+
+// errorSchemaName is the shared schema of error responses: http.Error writes a plain-text message
+const errorSchemaName = "Error"
+
+// HandleOpenAPI serves the OpenAPI 3 document
+// @Summary OpenAPI 3 document
+// @Description Complete OpenAPI 3.0 document of the API converted from the Swagger 2.0 annotations (also served at /docs/doc.json), with the admin Basic auth scheme and the plain-text error responses, for client code generators.
+// @Tags 04 - System
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /openapi.json [get]
+func (s *Server) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		var doc string
+		doc, openAPIErr = swag.ReadDoc()
+		if openAPIErr == nil {
+			openAPIDoc, openAPIErr = convertSwaggerToOpenAPI([]byte(doc))
+		}
+	})
+	if openAPIErr != nil {
+		utils.Error("OPENAPI", "Failed to build OpenAPI document: %v", openAPIErr)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDoc)
+}
+
+// convertSwaggerToOpenAPI converts a Swagger 2.0 document to OpenAPI 3.0.3
+func convertSwaggerToOpenAPI(data []byte) ([]byte, error) {
+	var src map[string]interface{}
+	if err := json.Unmarshal(data, &src); err != nil {
+		return nil, fmt.Errorf("invalid swagger document: %w", err)
+	}
+	if v, _ := src["swagger"].(string); v != "2.0" {
+		return nil, fmt.Errorf("unsupported swagger version %q", v)
+	}
+
+	schemas := map[string]interface{}{
+		errorSchemaName: map[string]interface{}{
+			"type":        "string",
+			"description": "Plain-text error message (Content-Type text/plain)",
+			"example":     "File not found",
+		},
+	}
+	if defs, ok := src["definitions"].(map[string]interface{}); ok {
+		for name, def := range defs {
+			schemas[name] = rewriteRefs(def)
+		}
+	}
+
+	components := map[string]interface{}{"schemas": schemas}
+	if secDefs, ok := src["securityDefinitions"].(map[string]interface{}); ok {
+		components["securitySchemes"] = convertSecuritySchemes(secDefs)
+	}
+
+	paths := map[string]interface{}{}
+	if srcPaths, ok := src["paths"].(map[string]interface{}); ok {
+		for path, item := range srcPaths {
+			ops, _ := item.(map[string]interface{})
+			converted := map[string]interface{}{}
+			for method, op := range ops {
+				if opMap, ok := op.(map[string]interface{}); ok {
+					converted[method] = convertOperation(path, opMap)
+				}
+			}
+			paths[path] = converted
+		}
+	}
+
+	server := "/"
+	if basePath, _ := src["basePath"].(string); basePath != "" {
+		server = basePath
+	}
+	if host, _ := src["host"].(string); host != "" {
+		scheme := "http"
+		if schemes, ok := src["schemes"].([]interface{}); ok && len(schemes) > 0 {
+			scheme, _ = schemes[0].(string)
+		}
+		server = scheme + "://" + host + strings.TrimSuffix(server, "/")
+	}
+
+	dst := map[string]interface{}{
+		"openapi":    "3.0.3",
+		"info":       src["info"],
+		"servers":    []interface{}{map[string]interface{}{"url": server}},
+		"paths":      paths,
+		"components": components,
+	}
+	if tags, ok := src["tags"]; ok {
+		dst["tags"] = tags
+	}
+	return json.MarshalIndent(dst, "", "  ")
+}
+
+// convertOperation moves body/formData parameters into requestBody, parameter types into
+// schemas and response schemas into content by the produced media types. Path parameters not
+// in the path template (a handler annotated with several @Router paths) are left out.
+func convertOperation(path string, op map[string]interface{}) map[string]interface{} {
+	consumes := stringList(op["consumes"], "application/json")
+	produces := stringList(op["produces"], "application/json")
+
+	out := map[string]interface{}{}
+	for _, key := range []string{"summary", "description", "operationId", "tags", "deprecated", "security"} {
+		if v, ok := op[key]; ok {
+			out[key] = v
+		}
+	}
+
+	var params []interface{}
+	formProps := map[string]interface{}{}
+	var formRequired []string
+	formType := "application/x-www-form-urlencoded"
+	srcParams, _ := op["parameters"].([]interface{})
+	for _, p := range srcParams {
+		param, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch param["in"] {
+		case "body":
+			content := map[string]interface{}{}
+			for _, mt := range consumes {
+				content[mt] = map[string]interface{}{"schema": rewriteRefs(param["schema"])}
+			}
+			body := map[string]interface{}{"content": content}
+			copyKeys(body, param, "description", "required")
+			out["requestBody"] = body
+		case "formData":
+			prop := paramSchema(param)
+			if param["type"] == "file" {
+				formType = "multipart/form-data"
+			}
+			copyKeys(prop, param, "description")
+			formProps[param["name"].(string)] = prop
+			if req, _ := param["required"].(bool); req {
+				formRequired = append(formRequired, param["name"].(string))
+			}
+		case "path":
+			name, _ := param["name"].(string)
+			if !strings.Contains(path, "{"+name+"}") {
+				continue
+			}
+			converted := map[string]interface{}{"schema": paramSchema(param), "required": true}
+			copyKeys(converted, param, "name", "in", "description")
+			params = append(params, converted)
+		default:
+			converted := map[string]interface{}{"schema": paramSchema(param)}
+			copyKeys(converted, param, "name", "in", "description", "required")
+			params = append(params, converted)
+		}
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+	if len(formProps) > 0 {
+		if slices.Contains(consumes, "multipart/form-data") {
+			formType = "multipart/form-data"
+		}
+		schema := map[string]interface{}{"type": "object", "properties": formProps}
+		if len(formRequired) > 0 {
+			sort.Strings(formRequired)
+			schema["required"] = formRequired
+		}
+		out["requestBody"] = map[string]interface{}{
+			"required": len(formRequired) > 0,
+			"content":  map[string]interface{}{formType: map[string]interface{}{"schema": schema}},
+		}
+	}
+
+	responses := map[string]interface{}{}
+	srcResponses, _ := op["responses"].(map[string]interface{})
+	for code, r := range srcResponses {
+		resp, _ := r.(map[string]interface{})
+		converted := map[string]interface{}{"description": resp["description"]}
+		if converted["description"] == nil {
+			status, _ := strconv.Atoi(code)
+			converted["description"] = http.StatusText(status)
+		}
+		if headers, ok := resp["headers"].(map[string]interface{}); ok {
+			h := map[string]interface{}{}
+			for name, hv := range headers {
+				hm, _ := hv.(map[string]interface{})
+				entry := map[string]interface{}{"schema": paramSchema(hm)}
+				copyKeys(entry, hm, "description")
+				h[name] = entry
+			}
+			converted["headers"] = h
+		}
+		if schema, ok := resp["schema"].(map[string]interface{}); ok {
+			converted["content"] = responseContent(code, schema, produces)
+		}
+		responses[code] = converted
+	}
+	out["responses"] = responses
+	return out
+}
+
+// responseContent maps a Swagger 2.0 response schema to OpenAPI 3 content
+func responseContent(code string, schema map[string]interface{}, produces []string) map[string]interface{} {
+	content := map[string]interface{}{}
+	switch {
+	case schema["type"] == "file":
+		for _, mt := range produces {
+			content[mt] = map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}}
+		}
+	case schema["type"] == "string" && (strings.HasPrefix(code, "4") || strings.HasPrefix(code, "5")):
+		content["text/plain"] = map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/" + errorSchemaName}}
+	case schema["type"] == "string":
+		content["text/plain"] = map[string]interface{}{"schema": schema}
+	default:
+		mt := "application/json"
+		if !slices.Contains(produces, mt) && len(produces) > 0 {
+			mt = produces[0]
+		}
+		content[mt] = map[string]interface{}{"schema": rewriteRefs(schema)}
+	}
+	return content
+}
+
+// paramSchema builds the schema of a non-body parameter from its type fields
+func paramSchema(param map[string]interface{}) map[string]interface{} {
+	schema := map[string]interface{}{}
+	copyKeys(schema, param, "type", "format", "enum", "default", "minimum", "maximum", "example")
+	if items, ok := param["items"]; ok {
+		schema["items"] = rewriteRefs(items)
+	}
+	if schema["type"] == "file" {
+		schema["type"] = "string"
+		schema["format"] = "binary"
+	}
+	return schema
+}
+
+// convertSecuritySchemes maps Swagger 2.0 security definitions to OpenAPI 3 security schemes
+func convertSecuritySchemes(defs map[string]interface{}) map[string]interface{} {
+	schemes := map[string]interface{}{}
+	for name, d := range defs {
+		def, _ := d.(map[string]interface{})
+		switch def["type"] {
+		case "basic":
+			scheme := map[string]interface{}{"type": "http", "scheme": "basic"}
+			copyKeys(scheme, def, "description")
+			schemes[name] = scheme
+		case "apiKey":
+			scheme := map[string]interface{}{"type": "apiKey"}
+			copyKeys(scheme, def, "name", "in", "description")
+			schemes[name] = scheme
+		}
+	}
+	return schemes
+}
+
+// rewriteRefs returns a copy of v with #/definitions/ references pointing to #/components/schemas/
+func rewriteRefs(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			if ref, ok := val.(string); ok && k == "$ref" {
+				out[k] = strings.Replace(ref, "#/definitions/", "#/components/schemas/", 1)
+				continue
+			}
+			out[k] = rewriteRefs(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = rewriteRefs(val)
+		}
+		return out
+	}
+	return v
+}
+
+func copyKeys(dst, src map[string]interface{}, keys ...string) {
+	for _, k := range keys {
+		if v, ok := src[k]; ok {
+			dst[k] = v
+		}
+	}
+}
+
+func stringList(v interface{}, def string) []string {
+	list, _ := v.([]interface{})
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, def)
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+const (
+	defaultPinnedListLimit = 100
+	maxPinnedListLimit     = 10000
+)
+
+// PinnedListResponse is the body of GET /system/files/pinned
+type PinnedListResponse struct {
+	Count int            `json:"count" example:"1"`
+	Files []storage.File `json:"files"`
+}
+
+// HandleSystemFilePin pins a file
+// @Summary Pin a file
+// @Description Exempts the file from expiry: the cleanup job keeps it and reads never answer 410 (EXPIRED_ACCESS=deny). expires_at stays stored and applies again after unpinning. Pinning a pinned file keeps the original pinned_at. Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Param id path string true "File UUID"
+// @Success 200 {object} storage.File
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 404 {string} string "File not found"
+// @Router /system/files/{id}/pin [post]
+func (s *Server) HandleSystemFilePin(w http.ResponseWriter, r *http.Request) {
+	s.setFilePinned(w, r, true)
+}
+
+// HandleSystemFileUnpin unpins a file
+// @Summary Unpin a file
+// @Description Removes the pin; the file expires by its expires_at again (an expiry in the past means the next cleanup removes it). Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Param id path string true "File UUID"
+// @Success 200 {object} storage.File
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 404 {string} string "File not found"
+// @Router /system/files/{id}/unpin [post]
+func (s *Server) HandleSystemFileUnpin(w http.ResponseWriter, r *http.Request) {
+	s.setFilePinned(w, r, false)
+}
+
+func (s *Server) setFilePinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	fileID := r.PathValue("id")
+	op := "unpin"
+	if pinned {
+		op = "pin"
+	}
+	file, err := s.FileService.SetFilePinned(fileID, pinned)
+	if err == nil {
+		utils.Info("ADMIN", "File pin changed: file_id=%s, pinned=%v, remote=%s", fileID, pinned, r.RemoteAddr)
+	}
+	writeFileOpResult(w, op, "file_id="+fileID, file, err)
+}
+
+// HandleSystemPinnedFiles lists pinned files
+// @Summary List pinned files
+// @Description Lists pinned files, most recently pinned first. Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Param limit query int false "Max number of files (default 100, max 10000)"
+// @Success 200 {object} PinnedListResponse
+// @Failure 400 {string} string "Invalid limit"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /system/files/pinned [get]
+func (s *Server) HandleSystemPinnedFiles(w http.ResponseWriter, r *http.Request) {
+	limit := defaultPinnedListLimit
+	if val := r.URL.Query().Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxPinnedListLimit)
+	}
+
+	files, err := s.FileService.MetaStore.ListPinnedFiles(limit)
+	if err != nil {
+		utils.Error("ADMIN", "Failed to list pinned files: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PinnedListResponse{Count: len(files), Files: files})
+}
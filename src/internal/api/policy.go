@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pmalasek/cumulus3/src/internal/images"
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// ValidityLimits are the accepted values of validity and expires_at
+type ValidityLimits struct {
+	Min         string   `json:"min" example:"1 day"`
+	Max         string   `json:"max" example:"1 year"`
+	MinSeconds  int64    `json:"minSeconds" example:"86400"`
+	MaxSeconds  int64    `json:"maxSeconds" example:"31536000"`
+	Units       []string `json:"units" example:"hour,day,week,month,year"` // units accepted in validity ("2 weeks")
+	TempDefault string   `json:"tempDefault" example:"1 day"`              // validity of /v2/files/tmp uploads without one
+}
+
+// CompressionPolicy describes how uploaded content is compressed
+type CompressionPolicy struct {
+	Mode            string   `json:"mode" example:"auto" enums:"auto,zstd,gzip,none"` // USE_COMPRESS
+	MinSavedPercent float64  `json:"minSavedPercent" example:"10"`                    // auto: compressed only when saving at least this
+	SampleSize      int64    `json:"sampleSize" example:"1048576"`                    // auto: bytes compressed on trial
+	StoredAsIs      []string `json:"storedAsIs"`                                      // auto: already compressed types, "*" ends a prefix
+}
+
+// ImageLimits bound the images the image endpoints process
+type ImageLimits struct {
+	MaxInputSize int64 `json:"maxInputSize" example:"52428800"` // 0 = unlimited
+	MaxPixels    int64 `json:"maxPixels" example:"50000000"`    // 0 = unlimited
+}
+
+// UploadPolicy is the body of GET /v2/policy
+type UploadPolicy struct {
+	MaxSize      int64    `json:"maxSize" example:"52428800"` // bytes of one upload request
+	AllowedTypes []string `json:"allowedTypes" example:"*/*"`
+	// UploadHook: uploads are also checked by an external policy service, which may refuse them with 422
+	UploadHook   bool              `json:"uploadHook"`
+	Dispositions []string          `json:"dispositions" example:"inline,attachment"` // without one it follows the MIME type
+	OnConflict   []string          `json:"onConflict" example:"reject,supersede"`    // the first one is the default
+	Validity     ValidityLimits    `json:"validity"`
+	Compression  CompressionPolicy `json:"compression"`
+	Images       ImageLimits       `json:"images"`
+}
+
+// uploadPolicy collects the limits uploads are checked against
+func (s *Server) uploadPolicy() UploadPolicy {
+	validity := s.validityPolicy()
+	tempValidity := s.TempFileValidity
+	if tempValidity == "" {
+		tempValidity = DefaultTempFileValidity
+	}
+	mode := strings.ToLower(s.FileService.CompressionMode)
+	switch mode {
+	case "auto", "zstd", "gzip":
+	default:
+		mode = "none"
+	}
+	policy := UploadPolicy{
+		MaxSize:      s.MaxUploadSize,
+		AllowedTypes: []string{"*/*"},
+		UploadHook:   s.FileService.UploadHook != nil,
+		Dispositions: []string{service.DispositionInline, service.DispositionAttachment},
+		OnConflict:   []string{string(service.OldIDConflictReject), string(service.OldIDConflictSupersede)},
+		Validity: ValidityLimits{
+			Min:         utils.FormatValidity(validity.Min),
+			Max:         utils.FormatValidity(validity.Max),
+			MinSeconds:  int64(validity.Min.Seconds()),
+			MaxSeconds:  int64(validity.Max.Seconds()),
+			Units:       validity.AllowedUnits(),
+			TempDefault: tempValidity,
+		},
+		Compression: CompressionPolicy{Mode: mode, StoredAsIs: []string{}},
+		Images:      ImageLimits{MaxInputSize: images.MaxImageInputSize, MaxPixels: images.MaxImagePixels},
+	}
+	if mode == "auto" {
+		policy.Compression.MinSavedPercent = s.FileService.MinCompressionRatio
+		policy.Compression.SampleSize = min(s.FileService.CompressionSampleSize, service.MaxCompressionSampleSize)
+		policy.Compression.StoredAsIs = service.UncompressedFormats()
+	}
+	return policy
+}
+
+// HandleV2Policy returns the upload policy
+// @Summary Upload policy
+// @Description Returns the limits uploads are checked against, so clients can validate files and build upload forms without hardcoding them: maximum size (MAX_UPLOAD_FILE_SIZE), accepted content types, disposition and on_conflict values, validity bounds and units (VALIDITY_MIN, VALIDITY_MAX, VALIDITY_UNITS, TEMP_FILE_VALIDITY), compression behavior (USE_COMPRESS, MINIMAL_COMPRESSION) and image processing limits. uploadHook tells that an external service (UPLOAD_HOOK_URL) may refuse uploads beyond these limits. The policy changes only with a restart. Use POST /v2/files/validate to check a concrete upload.
+// @Tags 02 - Files
+// @Produce json
+// @Success 200 {object} UploadPolicy
+// @Router /v2/policy [get]
+func (s *Server) HandleV2Policy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(s.uploadPolicy())
+}
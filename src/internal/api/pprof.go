@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// PprofHandler serves the runtime profiles of net/http/pprof under /debug/pprof/ behind admin
+// Basic auth. It runs on its own listener (PPROF_ADDR), never on the API port.
+func PprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	username, password := GetAdminCredentials()
+	return AdminAuthMiddleware(username, password, mux)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// maxPrefetchRequest is the largest number of file IDs of one prefetch request
+const maxPrefetchRequest = 10000
+
+// prefetchConcurrency is the number of files of one prefetch job read in parallel
+const prefetchConcurrency = 4
+
+// prefetchJobType is the type of prefetch jobs in /system/jobs
+const prefetchJobType = "prefetch"
+
+// maxActivePrefetchJobs is the number of prefetch jobs running at a time; more are rejected with 429
+const maxActivePrefetchJobs = 4
+
+// prefetchRetryAfter is the Retry-After of a prefetch rejected because of maxActivePrefetchJobs
+const prefetchRetryAfter = 30 * time.Second
+
+// activePrefetchJobs counts the prefetch jobs that have not finished yet
+var activePrefetchJobs atomic.Int64
+
+// PrefetchRequest is the body of POST /v2/files/prefetch
+type PrefetchRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// PrefetchResponse is the body of an accepted prefetch
+type PrefetchResponse struct {
+	JobID   string `json:"jobId" example:"xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"`
+	Message string `json:"message" example:"Prefetch of 250 files started"`
+}
+
+// HandleV2Prefetch starts warming the files of a batch before they are downloaded
+// @Summary Prefetch files
+// @Description Starts an asynchronous job that prepares up to 10000 files for a batch of downloads (ML, reporting pipelines), so the pipeline doesn't pay cold latency per file: the stored content of each file is read once into the page cache, and a file whose volume file is missing is copied from the cold tier (READ_FALLBACK_DIR) into a local volume, which also heals it. Files sharing content are read once. On a writable node a bearer token needs the write scope, since rehydration writes volumes. At most 4 jobs run at a time, another request gets 429 with Retry-After. The job is polled with GET /v2/files/prefetch/{id}; its summary (warmed, rehydrated, notFound, failed, bytesRead) is the progress of the finished job. On a read-only replica files are only read, a file with a missing volume file counts as failed.
+// @Tags 02 - Files
+// @Accept json
+// @Produce json
+// @Param body body PrefetchRequest true "File IDs"
+// @Success 202 {object} PrefetchResponse
+// @Failure 400 {string} string "Invalid request body, no IDs or more than 10000 IDs"
+// @Failure 403 {string} string "Bearer token without the write scope on a writable node"
+// @Failure 429 {string} string "Too many prefetch jobs running"
+// @Router /v2/files/prefetch [post]
+func (s *Server) HandleV2Prefetch(w http.ResponseWriter, r *http.Request) {
+	// Route je read-only kvůli replikám; na zapisovatelném uzlu rehydratace zapisuje do volumů
+	if claims := requestJWTClaims(r); claims != nil && !s.ReadOnly && !claims.HasScope(JWTScopeWrite) {
+		writeBearerError(w, http.StatusForbidden, `error="insufficient_scope", scope="`+JWTScopeWrite+`"`, "Token lacks scope "+JWTScopeWrite)
+		return
+	}
+	var req PrefetchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) == 0 {
+		http.Error(w, "ids is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > maxPrefetchRequest {
+		http.Error(w, fmt.Sprintf("Too many IDs, at most %d", maxPrefetchRequest), http.StatusBadRequest)
+		return
+	}
+
+	if activePrefetchJobs.Add(1) > maxActivePrefetchJobs {
+		activePrefetchJobs.Add(-1)
+		w.Header().Set("Retry-After", strconv.Itoa(int(prefetchRetryAfter.Seconds())))
+		http.Error(w, fmt.Sprintf("Too Many Requests: at most %d prefetch jobs at a time", maxActivePrefetchJobs), http.StatusTooManyRequests)
+		return
+	}
+
+	job := globalJobManager.CreateJob(prefetchJobType, nil)
+	go func() {
+		defer activePrefetchJobs.Add(-1)
+		globalJobManager.UpdateJob(job.ID, JobStatusRunning, fmt.Sprintf("Prefetching %d files", len(req.IDs)), nil)
+		summary := s.FileService.PrefetchFiles(req.IDs, prefetchConcurrency, !s.ReadOnly, func(p service.PrefetchSummary) {
+			globalJobManager.UpdateJob(job.ID, JobStatusRunning,
+				fmt.Sprintf("Prefetched %d of %d files, %d rehydrated, %d failed", p.Done, p.Requested, p.Rehydrated, p.Failed), nil)
+		})
+		utils.Info("PREFETCH", "Prefetch finished: job=%s, requested=%d, warmed=%d, rehydrated=%d, not_found=%d, failed=%d, bytes=%d",
+			job.ID, summary.Requested, summary.Warmed, summary.Rehydrated, summary.NotFound, summary.Failed, summary.BytesRead)
+		data, _ := json.Marshal(summary)
+		globalJobManager.UpdateJob(job.ID, JobStatusCompleted, string(data), nil)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(PrefetchResponse{
+		JobID:   job.ID,
+		Message: fmt.Sprintf("Prefetch of %d files started", len(req.IDs)),
+	})
+}
+
+// HandleV2PrefetchJob returns the state of a prefetch job
+// @Summary Get prefetch job
+// @Description Returns a prefetch job started by POST /v2/files/prefetch, like /system/jobs but limited to prefetch jobs so pipelines need no access to /system. progress holds the summary JSON once the job is completed. Jobs are kept for an hour after they finish.
+// @Tags 02 - Files
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} Job
+// @Failure 404 {string} string "Job not found"
+// @Router /v2/files/prefetch/{id} [get]
+func (s *Server) HandleV2PrefetchJob(w http.ResponseWriter, r *http.Request) {
+	job := globalJobManager.GetJob(r.PathValue("id"))
+	if job == nil || job.Type != prefetchJobType {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrefetchActiveJobsLimit(t *testing.T) {
+	activePrefetchJobs.Store(maxActivePrefetchJobs)
+	t.Cleanup(func() { activePrefetchJobs.Store(0) })
+	s := &Server{}
+
+	r := httptest.NewRequest(http.MethodPost, "/v2/files/prefetch", strings.NewReader(`{"ids": ["550e8400-e29b-41d4-a716-446655440000"]}`))
+	w := httptest.NewRecorder()
+	s.HandleV2Prefetch(w, r)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("prefetch with %d jobs running: status %d, Retry-After %q, want 429 with Retry-After",
+			maxActivePrefetchJobs, w.Code, w.Header().Get("Retry-After"))
+	}
+	if n := activePrefetchJobs.Load(); n != maxActivePrefetchJobs {
+		t.Errorf("active prefetch jobs after a rejected request: %d, want %d", n, maxActivePrefetchJobs)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/v2/files/prefetch", strings.NewReader(`{"ids": []}`))
+	w = httptest.NewRecorder()
+	s.HandleV2Prefetch(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("prefetch without IDs: status %d, want 400", w.Code)
+	}
+}
+
+func TestPrefetchWriteScope(t *testing.T) {
+	// Plný limit úloh: 429 znamená, že request prošel kontrolou scope
+	activePrefetchJobs.Store(maxActivePrefetchJobs)
+	t.Cleanup(func() { activePrefetchJobs.Store(0) })
+
+	tests := []struct {
+		name     string
+		readOnly bool
+		claims   *JWTClaims
+		want     int
+	}{
+		{"read scope on a writable node", false, &JWTClaims{Scope: "read"}, http.StatusForbidden},
+		{"write scope on a writable node", false, &JWTClaims{Scope: "read write"}, http.StatusTooManyRequests},
+		{"read scope on a replica", true, &JWTClaims{Scope: "read"}, http.StatusTooManyRequests},
+		{"no bearer token", false, nil, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		s := &Server{ReadOnly: tt.readOnly}
+		r := httptest.NewRequest(http.MethodPost, "/v2/files/prefetch", strings.NewReader(`{"ids": ["550e8400-e29b-41d4-a716-446655440000"]}`))
+		if tt.claims != nil {
+			r = r.WithContext(context.WithValue(r.Context(), jwtClaimsKey{}, tt.claims))
+		}
+		w := httptest.NewRecorder()
+		s.HandleV2Prefetch(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// Podepsané URL downloadu pro externí uživatele: /v2/files/{uuid}?expires=<unix>&sig=<HMAC>.
+// Middleware skupiny files je ověří na místě auth/apikey middlewaru – platný podpis je přeskočí
+// (jen GET/HEAD té jedné cesty), neplatný nebo prošlý skončí 403 i bez auth.
+
+const (
+	DefaultPresignTTL    = 24 * time.Hour
+	DefaultPresignMaxTTL = 7 * 24 * time.Hour
+)
+
+// PresignResponse is a signed download URL
+type PresignResponse struct {
+	URL       string    `json:"url" example:"/v2/files/550e8400-e29b-41d4-a716-446655440000?expires=1767225600&sig=Qm9n..."`
+	ExpiresAt time.Time `json:"expiresAt" example:"2026-01-01T00:00:00Z"`
+}
+
+// SignDownloadPath returns the signature of a download URL path valid until expires (unix
+// seconds): HMAC-SHA256 of "<path>\n<expires>" with the DOWNLOAD_SIGNING_KEY, base64url without
+// padding. It is passed as ?sig= next to ?expires=.
+func SignDownloadPath(key []byte, path string, expires int64) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// presignMaxTTL is the longest lifetime of a signed URL
+func (s *Server) presignMaxTTL() time.Duration {
+	if s.PresignMaxTTL > 0 {
+		return s.PresignMaxTTL
+	}
+	return DefaultPresignMaxTTL
+}
+
+// HandleV2Presign creates a signed download URL of a file
+// @Summary Create a signed download URL
+// @Description Returns a download URL of the file signed with DOWNLOAD_SIGNING_KEY that works without credentials (also when the files routes require auth) until it expires, so the file can be shared with external users. Only GET/HEAD of the signed path is allowed; an invalid or expired signature gets 403. The URL is relative, prefix it with the public address of the server.
+// @Tags 02 - Files
+// @Produce json
+// @Param uuid path string true "File UUID"
+// @Param ttl query string false "Lifetime of the URL as a Go duration (e.g. 1h, 72h), default 24h, at most PRESIGNED_URL_MAX_TTL"
+// @Success 200 {object} PresignResponse "Signed URL"
+// @Failure 400 {string} string "Invalid ttl"
+// @Failure 404 {string} string "File not found or presigned URLs disabled (DOWNLOAD_SIGNING_KEY not set)"
+// @Failure 410 {string} string "File expired (EXPIRED_ACCESS=deny)"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v2/files/{uuid}/presign [post]
+func (s *Server) HandleV2Presign(w http.ResponseWriter, r *http.Request) {
+	if len(s.DownloadSigningKey) == 0 {
+		http.Error(w, "Presigned URLs are disabled", http.StatusNotFound)
+		return
+	}
+	id := r.PathValue("uuid")
+	ttl := DefaultPresignTTL
+	if val := r.URL.Query().Get("ttl"); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid ttl: expected a positive duration such as 1h or 72h", http.StatusBadRequest)
+			return
+		}
+		if d > s.presignMaxTTL() {
+			http.Error(w, fmt.Sprintf("Invalid ttl: at most %s", s.presignMaxTTL()), http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+
+	if _, err := s.FileService.LocateFile(id); err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrExpired) {
+			utils.Info("PRESIGN", "File expired: file_id=%s, remote=%s", id, r.RemoteAddr)
+			writeExpired(w, "download")
+			return
+		}
+		utils.Error("PRESIGN", "File lookup failed: file_id=%s, error=%v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second).UTC()
+	// Podpis je nad dekódovanou cestou, tak jak ji middleware uvidí v r.URL.Path
+	path := "/v2/files/" + id
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("sig", SignDownloadPath(s.DownloadSigningKey, path, expiresAt.Unix()))
+	utils.Info("PRESIGN", "Signed URL created: file_id=%s, expires_at=%s, remote=%s", id, expiresAt.Format(time.RFC3339), r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PresignResponse{URL: "/v2/files/" + url.PathEscape(id) + "?" + query.Encode(), ExpiresAt: expiresAt})
+}
+
+// presignedDownloadMiddleware wraps the access control of the files group (auth, apikey): a request
+// with ?sig= is let through without it when it is a GET/HEAD with a valid unexpired signature of
+// its path and refused with 403 otherwise, other requests go to access
+func (s *Server) presignedDownloadMiddleware(access Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		authed := access(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			if !query.Has("sig") {
+				authed.ServeHTTP(w, r)
+				return
+			}
+			expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+			switch {
+			case len(s.DownloadSigningKey) == 0 || err != nil || (r.Method != http.MethodGet && r.Method != http.MethodHead):
+				http.Error(w, "Invalid signature", http.StatusForbidden)
+				return
+			case !hmac.Equal([]byte(query.Get("sig")), []byte(SignDownloadPath(s.DownloadSigningKey, r.URL.Path, expires))):
+				utils.Info("PRESIGN", "Invalid signature: path=%s, remote=%s", r.URL.Path, r.RemoteAddr)
+				http.Error(w, "Invalid signature", http.StatusForbidden)
+				return
+			case time.Now().Unix() > expires:
+				http.Error(w, "Signed URL expired", http.StatusForbidden)
+				return
+			}
+			// Sdílená cache nesmí odkaz obsluhovat po expiraci
+			next.ServeHTTP(&presignedResponseWriter{ResponseWriter: w, expires: expires}, r)
+		})
+	}
+}
+
+// presignedResponseWriter caps the Cache-Control of a signed download to the lifetime of the URL
+type presignedResponseWriter struct {
+	http.ResponseWriter
+	expires     int64
+	wroteHeader bool
+}
+
+func (pw *presignedResponseWriter) WriteHeader(code int) {
+	if !pw.wroteHeader {
+		pw.wroteHeader = true
+		maxAge := max(pw.expires-time.Now().Unix(), 0)
+		pw.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(maxAge, 10))
+	}
+	pw.ResponseWriter.WriteHeader(code)
+}
+
+func (pw *presignedResponseWriter) Write(p []byte) (int, error) {
+	if !pw.wroteHeader {
+		pw.WriteHeader(http.StatusOK)
+	}
+	return pw.ResponseWriter.Write(p)
+}
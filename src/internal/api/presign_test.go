@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+)
+
+func TestPresignExpiredFile(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blobID, err := m.CreateBlob("hash")
+	if err != nil {
+		t.Fatalf("CreateBlob: %v", err)
+	}
+	expired := time.Now().Add(-time.Hour)
+	if err := m.SaveFile(storage.File{ID: "expired", Name: "a.txt", BlobID: blobID, CreatedAt: expired, ExpiresAt: &expired}); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	s := &Server{
+		FileService:        &service.FileService{MetaStore: m, ExpiredAccess: service.ExpiredAccessDeny},
+		DownloadSigningKey: []byte("key"),
+	}
+
+	for id, want := range map[string]int{"expired": http.StatusGone, "missing": http.StatusNotFound} {
+		r := httptest.NewRequest(http.MethodPost, "/v2/files/"+id+"/presign", nil)
+		r.SetPathValue("uuid", id)
+		w := httptest.NewRecorder()
+		s.HandleV2Presign(w, r)
+		if w.Code != want {
+			t.Errorf("presign of the %s file: status %d, want %d", id, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// purgeJobType is the type of purge jobs in /system/jobs
+const purgeJobType = "purge"
+
+// PurgeRequest is the body of POST /system/purge
+type PurgeRequest struct {
+	Tag         string `json:"tag" example:"customer-42"`                   // all files carrying the tag are deleted
+	AuditPolicy string `json:"auditPolicy,omitempty" enums:"redact,remove"` // metadata log records of the files, default PURGE_AUDIT_POLICY
+	DryRun      bool   `json:"dryRun,omitempty"`                            // only list the files
+}
+
+// HandleSystemPurge starts a right-to-be-forgotten purge of a tag
+// @Summary Purge files by tag (right to be forgotten)
+// @Description Starts a job that hard-deletes all files carrying the tag, including pinned and expired ones. Blobs left without a file are freed and their volumes compacted, so the content is removed from disk; blobs shared by deduplication with files outside the purge stay. The records of the files in the metadata log are redacted (name and tags removed) or removed by auditPolicy. The job in /system/jobs?id= completes with a deletion report signed with PURGE_REPORT_SIGNING_KEY (HMAC-SHA256 of its SHA-256 digest), stored as a file tagged purge-report (reportFileId); the report is shown only with admin Basic auth. A file under legal hold fails the purge with 423 before anything is deleted (or the job, when the hold was placed meanwhile). A job with errors fails, its report lists them. With dryRun the files are only listed.
+// @Tags 04 - System
+// @Accept json
+// @Produce json
+// @Param request body PurgeRequest true "Tag to purge"
+// @Success 202 {object} map[string]interface{} "jobId of the purge"
+// @Failure 400 {string} string "Missing tag or unknown audit policy"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 423 {string} string "A file carrying the tag is under legal hold"
+// @Security BasicAuth
+// @Router /system/purge [post]
+func (s *Server) HandleSystemPurge(w http.ResponseWriter, r *http.Request) {
+	var req PurgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	tag := strings.TrimSpace(req.Tag)
+	if tag == "" {
+		http.Error(w, "Missing tag", http.StatusBadRequest)
+		return
+	}
+	policy := req.AuditPolicy
+	if policy == "" {
+		policy = s.PurgeAuditPolicy
+	}
+	if policy == "" {
+		policy = service.PurgeAuditRedact
+	}
+	if policy != service.PurgeAuditRedact && policy != service.PurgeAuditRemove {
+		http.Error(w, fmt.Sprintf("Unknown audit policy %q (redact, remove)", policy), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.FileService.CheckPurgeLegalHolds(tag); err != nil {
+		if errors.Is(err, storage.ErrLegalHold) {
+			http.Error(w, err.Error(), http.StatusLocked)
+			return
+		}
+		utils.Error("PURGE", "Failed to check legal holds: tag=%s, error=%v", tag, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	job := globalJobManager.CreateJob(purgeJobType, nil)
+	go func() {
+		globalJobManager.UpdateJob(job.ID, JobStatusRunning, "Collecting files", nil)
+		report, err := s.FileService.PurgeByTag(tag, policy, req.DryRun, s.PurgeReportSigningKey, func(progress string) {
+			globalJobManager.UpdateJob(job.ID, JobStatusRunning, progress, nil)
+		})
+		if err != nil {
+			utils.Error("PURGE", "Purge failed: tag=%s, error=%v", tag, err)
+			globalJobManager.UpdateJob(job.ID, JobStatusFailed, "", err)
+			return
+		}
+		if !req.DryRun {
+			if err := s.FileService.StorePurgeReport(report); err != nil {
+				utils.Error("PURGE", "Failed to store purge report: tag=%s, error=%v", tag, err)
+				report.Errors = append(report.Errors, fmt.Sprintf("store report: %v", err))
+			}
+			utils.Info("PURGE", "Purged tag=%s: files=%d, blobs_purged=%d, blobs_retained=%d, volumes=%v, audit_records=%d, errors=%d, digest=%s, report=%s",
+				tag, report.FilesDeleted, report.BlobsPurged, report.BlobsRetained, report.CompactedVolumes, report.AuditRecords, len(report.Errors), report.Digest, report.ReportFileID)
+		}
+
+		data, _ := json.Marshal(report)
+		if len(report.Errors) > 0 {
+			globalJobManager.UpdateJob(job.ID, JobStatusFailed, string(data), fmt.Errorf("purge incomplete: %s", strings.Join(report.Errors, "; ")))
+			return
+		}
+		globalJobManager.UpdateJob(job.ID, JobStatusCompleted, string(data), nil)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobId":   job.ID,
+		"message": "Purge started",
+	})
+}
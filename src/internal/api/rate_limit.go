@@ -0,0 +1,120 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// rateLimitIdle is how long an unused client bucket is kept
+const rateLimitIdle = 5 * time.Minute
+
+// RateLimiter is a token bucket per client IP (RATE_LIMIT, RATE_LIMIT_BURST). The buckets are
+// shared by all route groups with the ratelimit middleware.
+type RateLimiter struct {
+	rate           float64 // tokens per second
+	burst          float64
+	trustForwarded bool // client IP from X-Forwarded-For / X-Real-IP (behind a proxy)
+
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	lastSweep time.Time
+}
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter of rate requests per second with bursts up to burst
+func NewRateLimiter(rate float64, burst int, trustForwarded bool) *RateLimiter {
+	if burst < 1 {
+		burst = max(1, int(math.Ceil(rate)))
+	}
+	return &RateLimiter{
+		rate:           rate,
+		burst:          float64(burst),
+		trustForwarded: trustForwarded,
+		buckets:        make(map[string]*rateBucket),
+		lastSweep:      time.Now(),
+	}
+}
+
+// allow takes a token of the client; otherwise it returns how long to wait for one
+func (l *RateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > rateLimitIdle {
+		for key, b := range l.buckets {
+			if now.Sub(b.last) > rateLimitIdle {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &rateBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// clientIP returns the IP the request is accounted to
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	return requestIP(r, l.trustForwarded)
+}
+
+// requestIP returns the client IP of a request, with trustForwarded taken from X-Forwarded-For /
+// X-Real-IP (behind a proxy)
+func requestIP(r *http.Request, trustForwarded bool) string {
+	if trustForwarded {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(first)
+		}
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			return strings.TrimSpace(realIP)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Middleware rejects requests over the limit with 429 and Retry-After. A nil limiter
+// (RATE_LIMIT not set) lets all requests through.
+func (l *RateLimiter) Middleware(group string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := l.clientIP(r)
+			if ok, wait := l.allow(client, time.Now()); !ok {
+				RecordRateLimited(group)
+				utils.Info("RATE_LIMIT", "Rejected %s %s, client=%s, group=%s", r.Method, r.URL.Path, client, group)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
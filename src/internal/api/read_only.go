@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// Read-only replika (READ_ONLY): uzel nad replikovanou kopií volumů a snapshotem DB obsluhuje jen
+// čtení. Zapisující cesty se zaregistrují s handlerem, který vrací 405, takže auth a CORS skupiny
+// fungují stejně jako na primárním uzlu a klient dostane jednoznačnou odpověď místo 404.
+
+// readOnlyPOSTRoutes are POST routes that only read (a body too large for a query string); they stay
+// available on read-only replicas and need the read scope of a bearer token. Prefetch is one of them:
+// it only warms the page cache on a replica; on a writable node, where it rehydrates blobs into local
+// volumes, HandleV2Prefetch requires the write scope itself.
+var readOnlyPOSTRoutes = []string{
+	"POST /base/files/old/exists",
+	"POST /v2/files/validate",
+	"POST /v2/blobs/lookup",
+	"POST /v2/files/{uuid}/presign",
+	"POST /v2/files/prefetch",
+	"POST /system/files/hashes",
+}
+
+// isReadOnlyRoute reports whether the route pattern ("METHOD /path") only reads
+func isReadOnlyRoute(pattern string) bool {
+	method, _, _ := strings.Cut(pattern, " ")
+	return method == http.MethodGet || method == http.MethodHead || slices.Contains(readOnlyPOSTRoutes, pattern)
+}
+
+// readMethods are the read methods registered per path, for the Allow header of a read-only replica
+type readMethods map[string][]string
+
+func (m readMethods) add(pattern string) {
+	method, path, _ := strings.Cut(pattern, " ")
+	if method == http.MethodGet {
+		m[path] = append(m[path], http.MethodGet, http.MethodHead)
+	} else {
+		m[path] = append(m[path], method)
+	}
+}
+
+// readOnlyHandler answers requests to a mutating route of a read-only replica with 405; Allow lists
+// the read methods of the path (looked up per request, routes of one path register in any order)
+func (m readMethods) readOnlyHandler(path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.Info("READ_ONLY", "Rejected %s %s on a read-only replica", r.Method, r.URL.Path)
+		w.Header().Set("Allow", strings.Join(m[path], ", "))
+		http.Error(w, "Method Not Allowed: read-only replica", http.StatusMethodNotAllowed)
+	})
+}
@@ -0,0 +1,323 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// Statuses of a replica verification
+const (
+	ReplicaStatusOK       = "ok"
+	ReplicaStatusDiverged = "diverged" // a divergence exceeds its threshold
+	ReplicaStatusError    = "error"    // the replica could not be compared
+)
+
+// Defaults of the replica verification
+const (
+	DefaultReplicaVerifySample = 100
+	DefaultReplicaVerifyMinAge = 10 * time.Minute
+	maxFileHashesRequest       = 1000
+)
+
+// ReplicaVerifyConfig configures the scheduled comparison with the replica (READ_FALLBACK_URL)
+type ReplicaVerifyConfig struct {
+	ReplicaURL          string        // base URL of the replica, with user:password@ of its admin for POST /system/files/hashes
+	Interval            time.Duration // time between runs
+	SampleSize          int           // files whose hashes are compared per run
+	MinAge              time.Duration // newer files are not sampled, they may not be replicated yet
+	MaxCountDivergence  float64       // allowed relative difference of file and blob counts (0.01 = 1 %)
+	MaxSampleDivergence float64       // allowed share of sampled files missing or different on the replica
+	WebhookURL          string        // optional, diverged and failed reports are POSTed here as JSON
+}
+
+// ReplicaCountCheck compares a count with the replica; divergence is |local-replica| / local
+type ReplicaCountCheck struct {
+	Local      int64   `json:"local" example:"120000"`
+	Replica    int64   `json:"replica" example:"119990"`
+	Divergence float64 `json:"divergence" example:"0.0001"`
+}
+
+// ReplicaSampleCheck compares the content hashes of randomly sampled files with the replica
+type ReplicaSampleCheck struct {
+	Size       int      `json:"size" example:"100"`
+	Missing    []string `json:"missing"`    // files the replica does not have
+	Mismatched []string `json:"mismatched"` // files with different content on the replica
+	Divergence float64  `json:"divergence" example:"0.01"`
+}
+
+// ReplicaVerifyReport is the result of one comparison with the replica
+type ReplicaVerifyReport struct {
+	CreatedAt  time.Time          `json:"createdAt"`
+	DurationMs int64              `json:"durationMs" example:"840"`
+	Status     string             `json:"status" example:"ok"` // ok, diverged, error
+	Replica    string             `json:"replica" example:"http://replica:8800"`
+	Files      ReplicaCountCheck  `json:"files"`
+	Blobs      ReplicaCountCheck  `json:"blobs"`
+	Sample     ReplicaSampleCheck `json:"sample"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// ReplicaVerifier periodically compares file and blob counts and sampled content hashes with
+// the replica through its bulk endpoints (/system/stats, POST /system/files/hashes) and alerts
+// (replication_diverged metric, webhook) when they diverge more than the thresholds allow
+type ReplicaVerifier struct {
+	cfg    ReplicaVerifyConfig
+	meta   *storage.MetadataSQL
+	client *http.Client
+
+	mu     sync.Mutex
+	latest *ReplicaVerifyReport
+}
+
+// NewReplicaVerifier creates a verifier; Start schedules the runs
+func NewReplicaVerifier(meta *storage.MetadataSQL, cfg ReplicaVerifyConfig) *ReplicaVerifier {
+	if cfg.SampleSize <= 0 {
+		cfg.SampleSize = DefaultReplicaVerifySample
+	}
+	cfg.SampleSize = min(cfg.SampleSize, maxFileHashesRequest)
+	cfg.ReplicaURL = strings.TrimSuffix(cfg.ReplicaURL, "/")
+	return &ReplicaVerifier{cfg: cfg, meta: meta, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Start runs the verification every interval; each run is visible in /system/jobs
+func (v *ReplicaVerifier) Start() {
+	go func() {
+		ticker := time.NewTicker(v.cfg.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			v.Run()
+		}
+	}()
+}
+
+// Latest returns the report of the last run, nil before the first one
+func (v *ReplicaVerifier) Latest() *ReplicaVerifyReport {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.latest
+}
+
+// Run compares the node with the replica once, records the metrics and alerts on divergence
+func (v *ReplicaVerifier) Run() *ReplicaVerifyReport {
+	job := globalJobManager.CreateJob("replica-verify", nil)
+	globalJobManager.UpdateJob(job.ID, JobStatusRunning, "Comparing with the replica", nil)
+
+	start := time.Now()
+	report := &ReplicaVerifyReport{CreatedAt: start.UTC(), Replica: redactURL(v.cfg.ReplicaURL)}
+	if err := v.compare(report, start); err != nil {
+		report.Status = ReplicaStatusError
+		report.Error = err.Error()
+	} else if report.Files.Divergence > v.cfg.MaxCountDivergence ||
+		report.Blobs.Divergence > v.cfg.MaxCountDivergence ||
+		report.Sample.Divergence > v.cfg.MaxSampleDivergence {
+		report.Status = ReplicaStatusDiverged
+	} else {
+		report.Status = ReplicaStatusOK
+	}
+	report.DurationMs = time.Since(start).Milliseconds()
+
+	v.mu.Lock()
+	v.latest = report
+	v.mu.Unlock()
+	RecordReplicaVerify(report)
+
+	data, _ := json.Marshal(report)
+	switch report.Status {
+	case ReplicaStatusOK:
+		utils.Info("REPLICA", "Replica verification: status=ok, files=%d/%d, blobs=%d/%d, sample=%d",
+			report.Files.Local, report.Files.Replica, report.Blobs.Local, report.Blobs.Replica, report.Sample.Size)
+	default:
+		utils.Warn("REPLICA", "Replica verification: status=%s, files=%d/%d, blobs=%d/%d, sample=%d, missing=%d, mismatched=%d, error=%s",
+			report.Status, report.Files.Local, report.Files.Replica, report.Blobs.Local, report.Blobs.Replica,
+			report.Sample.Size, len(report.Sample.Missing), len(report.Sample.Mismatched), report.Error)
+		if v.cfg.WebhookURL != "" {
+			if err := postReportWebhook(v.cfg.WebhookURL, data); err != nil {
+				utils.Error("REPLICA", "Replica verification webhook failed: %v", err)
+			}
+		}
+	}
+
+	if report.Status == ReplicaStatusError {
+		globalJobManager.UpdateJob(job.ID, JobStatusFailed, string(data), fmt.Errorf("%s", report.Error))
+	} else {
+		globalJobManager.UpdateJob(job.ID, JobStatusCompleted, string(data), nil)
+	}
+	return report
+}
+
+func (v *ReplicaVerifier) compare(report *ReplicaVerifyReport, now time.Time) error {
+	local, err := v.meta.GetBlobStats()
+	if err != nil {
+		return fmt.Errorf("local stats: %w", err)
+	}
+	var remote struct {
+		Files struct {
+			Count int64 `json:"count"`
+		} `json:"files"`
+		Blobs struct {
+			Count int64 `json:"count"`
+		} `json:"blobs"`
+	}
+	if err := v.call(http.MethodGet, "/system/stats", nil, &remote); err != nil {
+		return err
+	}
+	report.Files = newReplicaCountCheck(local.FileCount, remote.Files.Count)
+	report.Blobs = newReplicaCountCheck(local.BlobCount, remote.Blobs.Count)
+
+	ids, err := v.meta.SampleFileIDs(v.cfg.SampleSize, now.Add(-v.cfg.MinAge))
+	if err != nil {
+		return fmt.Errorf("sample files: %w", err)
+	}
+	report.Sample = ReplicaSampleCheck{Size: len(ids), Missing: []string{}, Mismatched: []string{}}
+	if len(ids) == 0 {
+		return nil
+	}
+	hashes, err := v.meta.GetFileHashes(ids)
+	if err != nil {
+		return fmt.Errorf("local hashes: %w", err)
+	}
+	var replica FileHashesResponse
+	if err := v.call(http.MethodPost, "/system/files/hashes", FileHashesRequest{IDs: ids}, &replica); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		hash, ok := hashes[id]
+		if !ok {
+			continue // deleted meanwhile
+		}
+		switch replicaHash, found := replica.Hashes[id]; {
+		case !found:
+			report.Sample.Missing = append(report.Sample.Missing, id)
+		case replicaHash != hash:
+			report.Sample.Mismatched = append(report.Sample.Mismatched, id)
+		}
+	}
+	report.Sample.Divergence = float64(len(report.Sample.Missing)+len(report.Sample.Mismatched)) / float64(len(ids))
+	return nil
+}
+
+func newReplicaCountCheck(local, replica int64) ReplicaCountCheck {
+	c := ReplicaCountCheck{Local: local, Replica: replica}
+	if local != replica {
+		c.Divergence = math.Abs(float64(local-replica)) / float64(max(local, 1))
+	}
+	return c
+}
+
+// call sends a request to the replica and decodes the JSON response into out
+func (v *ReplicaVerifier) call(method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, v.cfg.ReplicaURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("replica %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("replica %s: status %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("replica %s: invalid response: %w", path, err)
+	}
+	return nil
+}
+
+func redactURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		return u.Redacted()
+	}
+	return raw
+}
+
+// FileHashesRequest is the body of POST /system/files/hashes
+type FileHashesRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// FileHashesResponse maps file IDs to the BLAKE2b-256 hash of their content; unknown IDs are left out
+type FileHashesResponse struct {
+	Hashes map[string]string `json:"hashes"`
+}
+
+// HandleSystemFileHashes returns content hashes of many files at once
+// @Summary Get hashes of many files
+// @Description Returns the stored BLAKE2b-256 content hashes of up to 1000 files in one call; IDs without a file are left out. Used to compare a replica without downloading the content. Requires admin Basic auth.
+// @Tags 04 - System
+// @Accept json
+// @Produce json
+// @Param request body FileHashesRequest true "File IDs"
+// @Success 200 {object} FileHashesResponse
+// @Failure 400 {string} string "Invalid request body or more than 1000 IDs"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /system/files/hashes [post]
+func (s *Server) HandleSystemFileHashes(w http.ResponseWriter, r *http.Request) {
+	var req FileHashesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > maxFileHashesRequest {
+		http.Error(w, fmt.Sprintf("Too many IDs, at most %d", maxFileHashesRequest), http.StatusBadRequest)
+		return
+	}
+
+	hashes, err := s.FileService.MetaStore.GetFileHashes(req.IDs)
+	if err != nil {
+		utils.Error("SYSTEM", "Failed to get file hashes: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FileHashesResponse{Hashes: hashes})
+}
+
+// HandleSystemReplication returns the latest comparison with the replica
+// @Summary Get latest replica verification
+// @Description Returns the result of the last scheduled comparison with the replica (READ_FALLBACK_URL, every REPLICA_VERIFY_INTERVAL): file and blob counts and the content hashes of randomly sampled files older than REPLICA_VERIFY_MIN_AGE. Status is diverged when a divergence exceeds REPLICA_VERIFY_MAX_COUNT_DIVERGENCE or REPLICA_VERIFY_MAX_SAMPLE_DIVERGENCE, error when the replica could not be compared. Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Success 200 {object} ReplicaVerifyReport
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 404 {string} string "Replica verification is not enabled or has not run yet"
+// @Router /system/replication [get]
+func (s *Server) HandleSystemReplication(w http.ResponseWriter, r *http.Request) {
+	var report *ReplicaVerifyReport
+	if s.ReplicaVerifier != nil {
+		report = s.ReplicaVerifier.Latest()
+	}
+	if report == nil {
+		http.Error(w, "Replica verification is not enabled or has not run yet", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// ConsistencyReportConfig configures the scheduled consistency report
+type ConsistencyReportConfig struct {
+	Hour, Minute  int           // local time of the daily run
+	PendingMaxAge time.Duration // pending blobs older than this count as zombies
+	WebhookURL    string        // optional, the report is POSTed here as JSON
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// StartConsistencyReporter runs the consistency report once a day at the configured time.
+// Each run is visible in /system/jobs, stored in the database and optionally sent to the webhook.
+func StartConsistencyReporter(fs *service.FileService, cfg ConsistencyReportConfig) {
+	go func() {
+		for {
+			next := nextDailyRun(time.Now(), cfg.Hour, cfg.Minute)
+			utils.Info("REPORT", "Next consistency report at %s", next.Format(time.RFC3339))
+			time.Sleep(time.Until(next))
+			RunConsistencyReport(fs, cfg)
+		}
+	}()
+}
+
+// nextDailyRun returns the next time after now at hour:minute local time
+func nextDailyRun(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// RunConsistencyReport builds, stores and delivers one consistency report
+func RunConsistencyReport(fs *service.FileService, cfg ConsistencyReportConfig) {
+	job := globalJobManager.CreateJob("consistency-report", nil)
+	globalJobManager.UpdateJob(job.ID, JobStatusRunning, "Building consistency report", nil)
+
+	report := fs.BuildConsistencyReport(cfg.PendingMaxAge)
+	data, err := json.Marshal(report)
+	if err != nil {
+		globalJobManager.UpdateJob(job.ID, JobStatusFailed, "", err)
+		return
+	}
+	if err := fs.MetaStore.SaveConsistencyReport(report.CreatedAt, report.Status, string(data)); err != nil {
+		utils.Error("REPORT", "Failed to store consistency report: %v", err)
+		globalJobManager.UpdateJob(job.ID, JobStatusFailed, "", err)
+		return
+	}
+
+	if report.Status == service.ReportStatusOK {
+		utils.Info("REPORT", "Consistency report: status=ok, volumes=%d, duration=%dms", report.Volumes.Volumes, report.DurationMs)
+	} else {
+		utils.Warn("REPORT", "Consistency report: status=%s, orphaned=%d, missing=%d, zombies=%d, missing_volumes=%v, truncated=%v, untracked=%v, errors=%v",
+			report.Status, report.Integrity.OrphanedBlobs, report.Integrity.MissingBlobs, report.Integrity.ZombieBlobs,
+			report.Volumes.MissingFiles, report.Volumes.TruncatedFiles, report.Volumes.UntrackedFiles, report.Errors)
+	}
+
+	if cfg.WebhookURL != "" {
+		if err := postReportWebhook(cfg.WebhookURL, data); err != nil {
+			utils.Error("REPORT", "Consistency report webhook failed: %v", err)
+		}
+	}
+	globalJobManager.UpdateJob(job.ID, JobStatusCompleted, string(data), nil)
+}
+
+func postReportWebhook(url string, data []byte) error {
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// HandleSystemReportsLatest returns the latest consistency report
+// @Summary Get latest consistency report
+// @Description Returns the most recent stored consistency report: quick integrity counts (orphaned, missing and zombie blobs), volume reconciliation (missing, truncated and untracked volume files) and the overall status (ok, warning, error).
+// @Tags 04 - System
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {string} string "No report yet"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /system/reports/latest [get]
+func (s *Server) HandleSystemReportsLatest(w http.ResponseWriter, r *http.Request) {
+	report, err := s.FileService.MetaStore.GetLatestConsistencyReport()
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "No report yet", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.Error("SYSTEM", "Failed to get consistency report: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(report))
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestRoutes builds the routes with ROUTE_MIDDLEWARE config; a route conflict panics in the mux
+func newTestRoutes(t *testing.T, config string) http.Handler {
+	t.Helper()
+	rm, err := ParseRouteMiddleware(config)
+	if err != nil {
+		t.Fatalf("ParseRouteMiddleware(%q): %v", config, err)
+	}
+	s := &Server{RouteMiddleware: rm, CORSAllowedOrigins: []string{"https://app.example.com"}}
+	defer func() {
+		if err := recover(); err != nil {
+			t.Fatalf("Routes with %q: %v", config, err)
+		}
+	}()
+	return s.Routes()
+}
+
+func TestRoutesWithCORS(t *testing.T) {
+	newTestRoutes(t, "public=cors; images=cors; system=cors; admin=cors")
+	h := newTestRoutes(t, "files=cors")
+
+	tests := []struct {
+		method, path, requestMethod string
+		want                        int
+	}{
+		{http.MethodOptions, "/v2/files/report.pdf", http.MethodPut, http.StatusNoContent},
+		{http.MethodOptions, "/v2/files/550e8400-e29b-41d4-a716-446655440000", http.MethodGet, http.StatusNoContent},
+		{http.MethodOptions, "/v2/files/550e8400-e29b-41d4-a716-446655440000/presign", http.MethodPost, http.StatusNoContent},
+		{http.MethodOptions, "/v2/uploads/abc", http.MethodPatch, http.StatusNoContent},
+		{http.MethodOptions, "/v2/files/info/550e8400-e29b-41d4-a716-446655440000", http.MethodDelete, http.StatusMethodNotAllowed},
+		{http.MethodOptions, "/system/stats", http.MethodGet, http.StatusMethodNotAllowed}, // system group without cors
+		{http.MethodOptions, "/v2/files/report.pdf", "", http.StatusMethodNotAllowed},      // not a preflight
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		r.Header.Set("Origin", "https://app.example.com")
+		if tt.requestMethod != "" {
+			r.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s %s (%s): status %d, want %d", tt.method, tt.path, tt.requestMethod, w.Code, tt.want)
+		}
+		if tt.want == http.StatusNoContent && w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+			t.Errorf("%s %s (%s): no Access-Control-Allow-Origin", tt.method, tt.path, tt.requestMethod)
+		}
+	}
+}
+
+func TestAdminOnlySystemRoutes(t *testing.T) {
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "admin")
+	h := newTestRoutes(t, "")
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/system/files/hashes"},
+		{http.MethodGet, "/system/replication"},
+		{http.MethodPost, "/system/volumes/state"},
+		{http.MethodGet, "/system/usage/keys"},
+		{http.MethodGet, "/system/files/type-mismatches"},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(route.method, route.path, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without admin auth: status %d, want 401", route.method, route.path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Interní histogramy latencí pro /system/slo – nasazení bez Prometheu tak mají přehled
+// o p50/p95/p99 a chybovosti jednotlivých endpointů za posledních SLO_WINDOW.
+
+// sloBounds are the upper bounds of the latency buckets in seconds
+var sloBounds = [...]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+const (
+	maxSLOEndpoints    = 200 // further endpoints (e.g. random 404 paths) are counted as "other"
+	sloMinAlertSamples = 20  // fewer requests in the window never raise an alert
+)
+
+// SLO targets and window (SLO_WINDOW, SLO_LATENCY_P99, SLO_ERROR_RATE)
+var (
+	DefaultSLOWindow     = 15 * time.Minute
+	DefaultSLOLatencyP99 = 2 * time.Second
+	DefaultSLOErrorRate  = 0.01
+)
+
+type sloKey struct {
+	method string
+	path   string
+}
+
+// sloMinute are the counters of one endpoint in one minute
+type sloMinute struct {
+	minute   int64
+	counts   [len(sloBounds) + 1]uint64 // last bucket is +Inf
+	requests uint64
+	errors   uint64 // 5xx
+}
+
+// sloTracker keeps a ring of per-minute histograms per endpoint
+type sloTracker struct {
+	mu         sync.Mutex
+	window     time.Duration
+	latencyP99 time.Duration
+	errorRate  float64
+	since      time.Time
+	endpoints  map[sloKey][]sloMinute
+}
+
+var globalSLO = newSLOTracker(DefaultSLOWindow, DefaultSLOLatencyP99, DefaultSLOErrorRate)
+
+func newSLOTracker(window, latencyP99 time.Duration, errorRate float64) *sloTracker {
+	return &sloTracker{
+		window:     window.Truncate(time.Minute),
+		latencyP99: latencyP99,
+		errorRate:  errorRate,
+		since:      time.Now(),
+		endpoints:  make(map[sloKey][]sloMinute),
+	}
+}
+
+// ConfigureSLO sets the rolling window (whole minutes, at least one) and the alert targets;
+// collected data is discarded
+func ConfigureSLO(window, latencyP99 time.Duration, errorRate float64) {
+	if window < time.Minute {
+		window = time.Minute
+	}
+	globalSLO = newSLOTracker(window, latencyP99, errorRate)
+}
+
+func (t *sloTracker) record(now time.Time, method, path string, seconds float64, status int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := sloKey{method: method, path: path}
+	ring, ok := t.endpoints[key]
+	if !ok {
+		if len(t.endpoints) >= maxSLOEndpoints {
+			key.path = "other"
+			ring, ok = t.endpoints[key]
+		}
+		if !ok {
+			ring = make([]sloMinute, int(t.window/time.Minute))
+			t.endpoints[key] = ring
+		}
+	}
+
+	minute := now.Unix() / 60
+	m := &ring[minute%int64(len(ring))]
+	if m.minute != minute {
+		*m = sloMinute{minute: minute}
+	}
+	i := sort.SearchFloat64s(sloBounds[:], seconds)
+	m.counts[i]++
+	m.requests++
+	if status >= 500 {
+		m.errors++
+	}
+}
+
+// SLOEndpoint are the latencies and error rate of one endpoint in the window
+type SLOEndpoint struct {
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Requests  uint64  `json:"requests"`
+	Errors    uint64  `json:"errors"`
+	ErrorRate float64 `json:"errorRate"`
+	P50Ms     float64 `json:"p50Ms"`
+	P95Ms     float64 `json:"p95Ms"`
+	P99Ms     float64 `json:"p99Ms"`
+}
+
+// SLOAlert is an endpoint over the latency or error rate target
+type SLOAlert struct {
+	Method string  `json:"method"`
+	Path   string  `json:"path"`
+	Kind   string  `json:"kind"` // latency | errors
+	Value  float64 `json:"value"`
+	Target float64 `json:"target"`
+}
+
+// SLOReport is the response of /system/slo
+type SLOReport struct {
+	Status             string        `json:"status"` // ok | alert
+	Window             string        `json:"window"`
+	Since              time.Time     `json:"since"` // start of data collection (server start)
+	LatencyTargetP99Ms float64       `json:"latencyTargetP99Ms"`
+	ErrorRateTarget    float64       `json:"errorRateTarget"`
+	Endpoints          []SLOEndpoint `json:"endpoints"`
+	Alerts             []SLOAlert    `json:"alerts"`
+}
+
+// report aggregates the minutes within window (capped to the tracker window)
+func (t *sloTracker) report(now time.Time, window time.Duration) SLOReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if window <= 0 || window > t.window {
+		window = t.window
+	}
+	minutes := int64(window / time.Minute)
+	nowMinute := now.Unix() / 60
+
+	report := SLOReport{
+		Status:             "ok",
+		Window:             window.String(),
+		Since:              t.since,
+		LatencyTargetP99Ms: float64(t.latencyP99.Milliseconds()),
+		ErrorRateTarget:    t.errorRate,
+		Endpoints:          []SLOEndpoint{},
+		Alerts:             []SLOAlert{},
+	}
+	for key, ring := range t.endpoints {
+		var counts [len(sloBounds) + 1]uint64
+		e := SLOEndpoint{Method: key.method, Path: key.path}
+		for _, m := range ring {
+			if m.requests == 0 || m.minute <= nowMinute-minutes || m.minute > nowMinute {
+				continue
+			}
+			for i, c := range m.counts {
+				counts[i] += c
+			}
+			e.Requests += m.requests
+			e.Errors += m.errors
+		}
+		if e.Requests == 0 {
+			continue
+		}
+		e.ErrorRate = float64(e.Errors) / float64(e.Requests)
+		e.P50Ms = sloQuantile(counts, e.Requests, 0.50) * 1000
+		e.P95Ms = sloQuantile(counts, e.Requests, 0.95) * 1000
+		e.P99Ms = sloQuantile(counts, e.Requests, 0.99) * 1000
+		report.Endpoints = append(report.Endpoints, e)
+
+		if e.Requests < sloMinAlertSamples {
+			continue
+		}
+		if t.latencyP99 > 0 && e.P99Ms > report.LatencyTargetP99Ms {
+			report.Alerts = append(report.Alerts, SLOAlert{Method: e.Method, Path: e.Path, Kind: "latency", Value: e.P99Ms, Target: report.LatencyTargetP99Ms})
+		}
+		if t.errorRate > 0 && e.ErrorRate > t.errorRate {
+			report.Alerts = append(report.Alerts, SLOAlert{Method: e.Method, Path: e.Path, Kind: "errors", Value: e.ErrorRate, Target: t.errorRate})
+		}
+	}
+
+	sort.Slice(report.Endpoints, func(i, j int) bool {
+		if report.Endpoints[i].Path != report.Endpoints[j].Path {
+			return report.Endpoints[i].Path < report.Endpoints[j].Path
+		}
+		return report.Endpoints[i].Method < report.Endpoints[j].Method
+	})
+	sort.Slice(report.Alerts, func(i, j int) bool {
+		if report.Alerts[i].Path != report.Alerts[j].Path {
+			return report.Alerts[i].Path < report.Alerts[j].Path
+		}
+		return report.Alerts[i].Kind < report.Alerts[j].Kind
+	})
+	if len(report.Alerts) > 0 {
+		report.Status = "alert"
+	}
+	return report
+}
+
+// sloQuantile estimates a quantile in seconds by linear interpolation within the bucket
+// (like Prometheus histogram_quantile); the +Inf bucket reports the highest bound
+func sloQuantile(counts [len(sloBounds) + 1]uint64, total uint64, q float64) float64 {
+	rank := q * float64(total)
+	var cumulative uint64
+	for i, c := range counts {
+		if c == 0 || float64(cumulative+c) < rank {
+			cumulative += c
+			continue
+		}
+		if i == len(sloBounds) {
+			return sloBounds[len(sloBounds)-1]
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = sloBounds[i-1]
+		}
+		return lower + (sloBounds[i]-lower)*(rank-float64(cumulative))/float64(c)
+	}
+	return sloBounds[len(sloBounds)-1]
+}
+
+// HandleSystemSLO returns latency percentiles and error rates per endpoint
+// @Summary Get latency and error rate SLO report
+// @Description Returns p50/p95/p99 latencies and the 5xx error rate per endpoint over a rolling window (SLO_WINDOW, default 15m) from internal histograms, so deployments without Prometheus get health insight. Endpoints over SLO_LATENCY_P99 or SLO_ERROR_RATE (with at least 20 requests) are listed in alerts and the status is "alert". Data is kept in memory since server start.
+// @Tags 04 - System
+// @Produce json
+// @Param window query string false "Shorter window, e.g. 5m (at most SLO_WINDOW)"
+// @Success 200 {object} SLOReport
+// @Failure 400 {string} string "Invalid window"
+// @Router /system/slo [get]
+func (s *Server) HandleSystemSLO(w http.ResponseWriter, r *http.Request) {
+	var window time.Duration
+	if val := r.URL.Query().Get("window"); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil || d < time.Minute {
+			http.Error(w, "Invalid window, use e.g. 5m (at least 1m)", http.StatusBadRequest)
+			return
+		}
+		window = d.Truncate(time.Minute)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(globalSLO.report(time.Now(), window))
+}
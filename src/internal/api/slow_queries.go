@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+)
+
+// SlowQueriesResponse is the response of /system/slow-queries
+type SlowQueriesResponse struct {
+	ThresholdMs float64             `json:"thresholdMs"`
+	Total       uint64              `json:"total"` // slow queries since start, including those dropped from the buffer
+	Queries     []storage.SlowQuery `json:"queries"`
+}
+
+// HandleSystemSlowQueries lists the last slow metadata queries
+// @Summary List slow metadata queries
+// @Description Returns the last SLOW_QUERY_LOG_SIZE metadata queries that took at least SLOW_QUERY_THRESHOLD, most recent first, with the normalized SQL text and the number of arguments (their values are not kept). The duration includes waiting for a free database connection, so SQLite stalls behind a long write show up here; statements inside write transactions are reported as a whole in db_tx_duration_seconds instead. The buffer is kept in memory and starts empty on restart.
+// @Tags 04 - System
+// @Produce json
+// @Success 200 {object} SlowQueriesResponse
+// @Failure 404 {string} string "Slow query log is not enabled"
+// @Router /system/slow-queries [get]
+func (s *Server) HandleSystemSlowQueries(w http.ResponseWriter, r *http.Request) {
+	if s.SlowQueries == nil {
+		http.Error(w, "Slow query log is not enabled", http.StatusNotFound)
+		return
+	}
+
+	queries, total := s.SlowQueries.Entries()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SlowQueriesResponse{
+		ThresholdMs: float64(s.SlowQueries.Threshold().Microseconds()) / 1000,
+		Total:       total,
+		Queries:     queries,
+	})
+}
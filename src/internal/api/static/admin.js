@@ -8,6 +8,12 @@ function formatBytes(bytes) {
     return parseFloat((bytes / Math.pow(k, i)).toFixed(2)) + ' ' + sizes[i];
 }
 
+function escapeHTML(value) {
+    const div = document.createElement('div');
+    div.textContent = value;
+    return div.innerHTML;
+}
+
 function showAlert(message, type = 'info') {
     const alerts = document.getElementById('alerts');
     const alert = document.createElement('div');
@@ -41,6 +47,77 @@ async function loadStats() {
     }
 }
 
+async function loadForecast() {
+    try {
+        const response = await fetch('/system/forecast');
+        const report = await response.json();
+
+        const list = document.getElementById('forecast-list');
+        list.className = '';
+        if (report.dirs.length === 0) {
+            list.innerHTML = '<p>No disk usage history yet</p>';
+        } else {
+            list.innerHTML = report.dirs.map(dir => {
+                let until = 'not growing';
+                if (dir.status === 'unknown') {
+                    until = 'collecting history';
+                } else if (dir.daysUntilFull !== undefined) {
+                    until = dir.daysUntilFull.toFixed(1) + ' days';
+                }
+                const growth = dir.growthBytesPerDay > 0 ? '+' + formatBytes(dir.growthBytesPerDay) + '/day' : '-';
+                return `
+                    <div class="stat">
+                        <span class="stat-label">${dir.dir} full in:</span>
+                        <span class="stat-value">${until}</span>
+                    </div>
+                    <div class="stat">
+                        <span class="stat-label">${dir.dir} growth:</span>
+                        <span class="stat-value">${growth} (${dir.usedPercent.toFixed(1)}% used)</span>
+                    </div>
+                `;
+            }).join('');
+        }
+
+        // Upozornění zůstává zobrazené, dokud odhad neklesne pod práh
+        const alert = document.getElementById('forecast-alert');
+        const failing = report.dirs.filter(dir => dir.status === 'warning' || dir.status === 'critical');
+        if (failing.length === 0) {
+            alert.innerHTML = '';
+            return;
+        }
+        alert.innerHTML = `<div class="alert alert-${report.status === 'critical' ? 'error' : 'warning'}">` +
+            failing.map(dir => `⚠️ ${dir.dir} (${dir.path}) will be full in ${dir.daysUntilFull.toFixed(1)} days`).join('<br>') +
+            '</div>';
+    } catch (error) {
+        console.error('Failed to load forecast:', error);
+    }
+}
+
+async function loadActivity() {
+    try {
+        const response = await fetch('/admin/api/activity?limit=3');
+        const report = await response.json();
+
+        const stat = (label, value) => `
+            <div class="stat">
+                <span class="stat-label">${label}</span>
+                <span class="stat-value">${value}</span>
+            </div>
+        `;
+        const ops = c => c.uploads + c.downloads + c.deletes;
+        const list = document.getElementById('activity-list');
+        list.className = '';
+        list.innerHTML =
+            stat('Uploads:', `${report.total.uploads.toLocaleString()} (${formatBytes(report.total.uploadBytes)})`) +
+            stat('Downloads:', `${report.total.downloads.toLocaleString()} (${formatBytes(report.total.downloadBytes)})`) +
+            stat('Deletes:', report.total.deletes.toLocaleString()) +
+            report.topClients.map(c => stat(`Client ${escapeHTML(c.client)}:`, ops(c).toLocaleString() + ' ops')).join('') +
+            report.topFileTypes.map(t => stat(`${escapeHTML(t.mimeType)}:`, ops(t).toLocaleString() + ' ops')).join('');
+    } catch (error) {
+        console.error('Failed to load activity:', error);
+    }
+}
+
 async function loadVolumes() {
     try {
         const response = await fetch('/system/volumes');
@@ -56,9 +133,16 @@ async function loadVolumes() {
             <div class="volume-item">
                 <div class="volume-header">
                     <span class="volume-id">Volume ${vol.id}</span>
-                    <button class="button" onclick="compactVolume(${vol.id})">🔧 Compact</button>
+                    <div>
+                        <button class="button" onclick="compactVolume(${vol.id})">🔧 Compact</button>
+                        <button class="button button-warning" onclick="drainVolume(${vol.id})">🚚 Drain</button>
+                    </div>
                 </div>
                 <div class="volume-stats">
+                    <div class="stat">
+                        <span class="stat-label">State:</span>
+                        <span class="stat-value">${vol.state}</span>
+                    </div>
                     <div class="stat">
                         <span class="stat-label">Total:</span>
                         <span class="stat-value">${formatBytes(vol.totalSize)}</span>
@@ -182,6 +266,33 @@ async function compactVolume(volumeId) {
     }
 }
 
+async function drainVolume(volumeId) {
+    if (!confirm('Move all blobs of volume ' + volumeId + ' to other volumes and remove it?')) {
+        return;
+    }
+
+    try {
+        const response = await fetch('/system/volumes/' + volumeId + '/drain', {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({compact: true})
+        });
+        if (!response.ok) {
+            showAlert('Failed to start drain: ' + await response.text(), 'error');
+            return;
+        }
+        const data = await response.json();
+        showAlert('Drain started: ' + data.jobId, 'success');
+        setTimeout(() => {
+            loadJobs();
+            startAutoRefresh();
+        }, 1000);
+    } catch (error) {
+        console.error('Failed to drain volume:', error);
+        showAlert('Failed to start drain', 'error');
+    }
+}
+
 async function compactAll() {
     if (!confirm('Are you sure you want to compact all volumes?')) {
         return;
@@ -222,6 +333,80 @@ async function checkIntegrity(deep = false) {
     }
 }
 
+async function loadPinned() {
+    try {
+        const response = await fetch('/system/files/pinned');
+        const data = await response.json();
+
+        const list = document.getElementById('pinned-list');
+        if (data.files.length === 0) {
+            list.innerHTML = '<p>No pinned files</p>';
+            return;
+        }
+
+        list.innerHTML = data.files.map(file => `
+            <div class="volume-item">
+                <div class="volume-header">
+                    <span class="volume-id">${escapeHTML(file.name)}</span>
+                    <button class="button button-danger" onclick="unpinFile('${file.id}')">Unpin</button>
+                </div>
+                <div class="volume-stats">
+                    <div class="stat">
+                        <span class="stat-label">UUID:</span>
+                        <span class="stat-value">${file.id}</span>
+                    </div>
+                    <div class="stat">
+                        <span class="stat-label">Pinned:</span>
+                        <span class="stat-value">${new Date(file.pinned_at).toLocaleString('en-US')}</span>
+                    </div>
+                    <div class="stat">
+                        <span class="stat-label">Expires:</span>
+                        <span class="stat-value">${file.expires_at ? new Date(file.expires_at).toLocaleString('en-US') : 'never'}</span>
+                    </div>
+                </div>
+            </div>
+        `).join('');
+    } catch (error) {
+        console.error('Failed to load pinned files:', error);
+        showAlert('Failed to load pinned files', 'error');
+    }
+}
+
+async function setPinned(fileId, pinned) {
+    try {
+        const response = await fetch('/system/files/' + encodeURIComponent(fileId) + (pinned ? '/pin' : '/unpin'), {
+            method: 'POST'
+        });
+        if (!response.ok) {
+            showAlert('Failed to ' + (pinned ? 'pin' : 'unpin') + ' file: ' + await response.text(), 'error');
+            return;
+        }
+        showAlert('File ' + fileId + (pinned ? ' pinned' : ' unpinned'), 'success');
+        loadPinned();
+    } catch (error) {
+        console.error('Failed to change pin:', error);
+        showAlert('Failed to change pin', 'error');
+    }
+}
+
+function pinFile() {
+    const input = document.getElementById('pin-file-id');
+    const fileId = input.value.trim();
+    if (!fileId) {
+        showAlert('Enter a file UUID', 'warning');
+        return;
+    }
+    setPinned(fileId, true);
+    input.value = '';
+}
+
+function unpinFile(fileId) {
+    if (!confirm('Unpin file ' + fileId + '? It will expire by its expires_at again.')) {
+        return;
+    }
+    setPinned(fileId, false);
+}
+
 function refreshVolumes() {
     loadVolumes();
     loadStats();
@@ -252,9 +437,15 @@ function stopAutoRefresh() {
 loadStats();
 loadVolumes();
 loadJobs();
+loadForecast();
+loadActivity();
+loadPinned();
 
 setInterval(() => {
     if (!refreshInterval) {
         loadStats();
     }
 }, 10000);
+
+setInterval(loadForecast, 60000);
+setInterval(loadActivity, 60000);
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/pmalasek/cumulus3/src/internal/storage"
 	"github.com/pmalasek/cumulus3/src/internal/utils"
 )
 
@@ -84,6 +86,20 @@ func (jm *JobManager) ListJobs() []*Job {
 	return jobs
 }
 
+// adminJobTypes are jobs of admin operations whose progress and error (the takeout archive URL, the
+// purge report) /system/jobs shows only with admin Basic auth
+var adminJobTypes = []string{takeoutJobType, purgeJobType}
+
+// jobView returns the job as shown by /system/jobs; without admin the result of an admin job is redacted
+func jobView(job *Job, admin bool) *Job {
+	if admin || !slices.Contains(adminJobTypes, job.Type) {
+		return job
+	}
+	view := *job
+	view.Progress, view.Error = "", ""
+	return &view
+}
+
 func (jm *JobManager) UpdateJob(id string, status JobStatus, progress string, err error) {
 	jm.mu.Lock()
 	defer jm.mu.Unlock()
@@ -114,11 +130,6 @@ func (jm *JobManager) UpdateJob(id string, status JobStatus, progress string, er
 // @Success 200 {object} map[string]interface{}
 // @Router /system/stats [get]
 func (s *Server) HandleSystemStats(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	storageStats, err := s.FileService.MetaStore.GetBlobStats()
 	if err != nil {
 		utils.Error("SYSTEM", "Failed to get stats: %v", err)
@@ -142,7 +153,16 @@ func (s *Server) HandleSystemStats(w http.ResponseWriter, r *http.Request) {
 		fragmentationRatio = float64(storageStats.DeletedBlobsSize) / float64(storageStats.BlobTotalSize) * 100
 	}
 
+	// Top tagy – do zavedení namespaces jediné dostupné členění souborů
+	var topTags interface{} = []interface{}{}
+	if tagStats, err := s.FileService.MetaStore.GetTagCounts("", 10); err == nil {
+		topTags = tagStats.Tags
+	} else {
+		utils.Warn("SYSTEM", "Failed to get top tags: %v", err)
+	}
+
 	stats := map[string]interface{}{
+		"topTags": topTags,
 		"blobs": map[string]interface{}{
 			"count":            storageStats.BlobCount,
 			"totalSize":        storageStats.BlobTotalSize,
@@ -168,14 +188,16 @@ func (s *Server) HandleSystemStats(w http.ResponseWriter, r *http.Request) {
 
 // HandleSystemVolumes returns list of volumes
 // @Summary Get volume list
-// @Description Returns list of all volumes with their statistics
+// @Description Returns list of all volumes with their statistics and state (open, sealed, compacting, read-only, missing)
 // @Tags 04 - System
 // @Produce json
+// @Param state query string false "Only volumes in this state (e.g. 'sealed' for erasure coding/tiering candidates)"
 // @Success 200 {array} map[string]interface{}
 // @Router /system/volumes [get]
 func (s *Server) HandleSystemVolumes(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	stateFilter := r.URL.Query().Get("state")
+	if stateFilter != "" && !storage.IsValidVolumeState(stateFilter) {
+		http.Error(w, "Invalid state", http.StatusBadRequest)
 		return
 	}
 
@@ -186,26 +208,84 @@ func (s *Server) HandleSystemVolumes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result := make([]map[string]interface{}, len(volumes))
-	for i, vol := range volumes {
+	result := make([]map[string]interface{}, 0, len(volumes))
+	for _, vol := range volumes {
+		if stateFilter != "" && vol.State != stateFilter {
+			continue
+		}
 		fragmentation := 0.0
 		if vol.SizeTotal > 0 {
 			fragmentation = float64(vol.SizeDeleted) / float64(vol.SizeTotal) * 100
 		}
 
-		result[i] = map[string]interface{}{
+		result = append(result, map[string]interface{}{
 			"id":            vol.ID,
+			"state":         vol.State,
 			"totalSize":     vol.SizeTotal,
 			"deletedSize":   vol.SizeDeleted,
 			"usedSize":      vol.SizeTotal - vol.SizeDeleted,
 			"fragmentation": fragmentation,
-		}
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
 
+// VolumeStateRequest changes the state of a volume
+type VolumeStateRequest struct {
+	VolumeID int64  `json:"volumeId" example:"3"`
+	State    string `json:"state" example:"sealed"`
+}
+
+// HandleSystemVolumeState sets the state of a volume
+// @Summary Set volume state
+// @Description Seals, reopens or marks a volume read-only. Sealed and read-only volumes never receive new blobs; sealed volumes can still be compacted. The states compacting and missing are managed by the server. Requires admin Basic auth.
+// @Tags 04 - System
+// @Accept json
+// @Produce json
+// @Param body body VolumeStateRequest true "Volume and new state (open, sealed, read-only)"
+// @Success 200 {object} VolumeStateRequest
+// @Failure 400 {string} string "Invalid request"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 409 {string} string "Volume is compacting or missing"
+// @Router /system/volumes/state [post]
+func (s *Server) HandleSystemVolumeState(w http.ResponseWriter, r *http.Request) {
+	var req VolumeStateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.VolumeID <= 0 {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	switch req.State {
+	case storage.VolumeStateOpen, storage.VolumeStateSealed, storage.VolumeStateReadOnly:
+	default:
+		http.Error(w, "state must be open, sealed or read-only", http.StatusBadRequest)
+		return
+	}
+
+	current, err := s.FileService.MetaStore.GetVolumeState(req.VolumeID)
+	if err != nil {
+		utils.Error("SYSTEM", "Failed to get state of volume %d: %v", req.VolumeID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if current == storage.VolumeStateCompacting || current == storage.VolumeStateMissing {
+		http.Error(w, fmt.Sprintf("Volume %d is %s", req.VolumeID, current), http.StatusConflict)
+		return
+	}
+
+	if err := s.FileService.MetaStore.SetVolumeState(req.VolumeID, req.State); err != nil {
+		utils.Error("SYSTEM", "Failed to set state of volume %d: %v", req.VolumeID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	utils.Info("SYSTEM", "Volume %d state changed: %s -> %s", req.VolumeID, current, req.State)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
 // HandleSystemCompact triggers volume compaction
 // @Summary Compact volume
 // @Description Starts asynchronous compaction of a specific volume or all volumes
@@ -216,11 +296,6 @@ func (s *Server) HandleSystemVolumes(w http.ResponseWriter, r *http.Request) {
 // @Success 202 {object} map[string]interface{}
 // @Router /system/compact [post]
 func (s *Server) HandleSystemCompact(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var req map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
@@ -246,6 +321,10 @@ func (s *Server) HandleSystemCompact(w http.ResponseWriter, r *http.Request) {
 			}
 
 			for i, vol := range volumes {
+				if !storage.VolumeStateCompactable(vol.State) {
+					utils.Info("COMPACT", "Skipping volume %d in state %s", vol.ID, vol.State)
+					continue
+				}
 				progress := fmt.Sprintf("Compacting volume %d (%d/%d)", vol.ID, i+1, len(volumes))
 				globalJobManager.UpdateJob(job.ID, JobStatusRunning, progress, nil)
 
@@ -299,18 +378,13 @@ func (s *Server) HandleSystemCompact(w http.ResponseWriter, r *http.Request) {
 
 // HandleSystemJobs returns list of jobs or specific job status
 // @Summary Get jobs status
-// @Description Returns list of all jobs or specific job details
+// @Description Returns list of all jobs or specific job details. progress and error of takeout and purge jobs are shown only with admin Basic auth.
 // @Tags 04 - System
 // @Produce json
 // @Param id query string false "Job ID"
 // @Success 200 {object} map[string]interface{}
 // @Router /system/jobs [get]
 func (s *Server) HandleSystemJobs(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	jobID := r.URL.Query().Get("id")
 	if jobID != "" {
 		job := globalJobManager.GetJob(jobID)
@@ -319,11 +393,15 @@ func (s *Server) HandleSystemJobs(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(job)
+		json.NewEncoder(w).Encode(jobView(job, isAdminRequest(r)))
 		return
 	}
 
+	admin := isAdminRequest(r)
 	jobs := globalJobManager.ListJobs()
+	for i, job := range jobs {
+		jobs[i] = jobView(job, admin)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(jobs)
 }
@@ -337,11 +415,6 @@ func (s *Server) HandleSystemJobs(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} map[string]interface{}
 // @Router /system/integrity [get]
 func (s *Server) HandleSystemIntegrity(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	deepCheck := r.URL.Query().Get("deep") == "true"
 	jobType := "integrity-check"
 	if deepCheck {
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSystemJobsRedactsAdminJobs(t *testing.T) {
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "admin")
+	takeout := globalJobManager.CreateJob(takeoutJobType, nil)
+	globalJobManager.UpdateJob(takeout.ID, JobStatusCompleted, `{"download_url": "/v2/files/x"}`, nil)
+	compact := globalJobManager.CreateJob("compact", nil)
+	globalJobManager.UpdateJob(compact.ID, JobStatusRunning, "Compacting volume 1", nil)
+	s := &Server{}
+
+	tests := []struct {
+		id    string
+		admin bool
+		want  string
+	}{
+		{takeout.ID, false, ""},
+		{takeout.ID, true, `{"download_url": "/v2/files/x"}`},
+		{compact.ID, false, "Compacting volume 1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/system/jobs?id="+tt.id, nil)
+		if tt.admin {
+			r.SetBasicAuth("admin", "admin")
+		}
+		w := httptest.NewRecorder()
+		s.HandleSystemJobs(w, r)
+		var job Job
+		if err := json.NewDecoder(w.Body).Decode(&job); err != nil {
+			t.Fatalf("decode job: %v", err)
+		}
+		if job.Progress != tt.want {
+			t.Errorf("job %s, admin %v: progress %q, want %q", job.Type, tt.admin, job.Progress, tt.want)
+		}
+	}
+
+	// Redakce výpisu nesmí změnit uloženou úlohu
+	w := httptest.NewRecorder()
+	s.HandleSystemJobs(w, httptest.NewRequest(http.MethodGet, "/system/jobs", nil))
+	if globalJobManager.GetJob(takeout.ID).Progress == "" {
+		t.Error("listing jobs without admin cleared the stored takeout progress")
+	}
+}
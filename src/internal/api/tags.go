@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+const (
+	defaultTagListLimit = 50
+	maxTagListLimit     = 1000
+)
+
+// HandleV2Tags lists tags with file counts
+// @Summary List tags
+// @Description Returns tags with the number of files carrying them, ordered by count. Tags are not indexed, every call scans all files - cache the result for autocomplete.
+// @Tags 02 - Files
+// @Produce json
+// @Param prefix query string false "Case-insensitive tag prefix"
+// @Param limit query int false "Max number of tags (default 50, max 1000)"
+// @Success 200 {object} map[string]interface{} "tags: [{tag, count}], totalTags, taggedFiles"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v2/tags [get]
+func (s *Server) HandleV2Tags(w http.ResponseWriter, r *http.Request) {
+	limit := defaultTagListLimit
+	if val := r.URL.Query().Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxTagListLimit)
+	}
+	prefix := r.URL.Query().Get("prefix")
+
+	stats, err := s.FileService.MetaStore.GetTagCounts(prefix, limit)
+	if err != nil {
+		utils.Error("TAGS", "Failed to list tags: prefix=%s, error=%v", prefix, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+const maxBatchTagFileIDs = 10000
+
+// BatchTagFilter selects files for a batch tag operation (used when fileIds is empty)
+type BatchTagFilter struct {
+	Tag       string `json:"tag,omitempty" example:"migrated"`
+	OldIDFrom *int64 `json:"oldIdFrom,omitempty" example:"1"`
+	OldIDTo   *int64 `json:"oldIdTo,omitempty" example:"100000"`
+}
+
+// BatchTagRequest is the body of POST /v2/files/tags
+type BatchTagRequest struct {
+	FileIDs []string        `json:"fileIds,omitempty"`
+	Filter  *BatchTagFilter `json:"filter,omitempty"`
+	Add     []string        `json:"add,omitempty" example:"archive"`
+	Remove  []string        `json:"remove,omitempty" example:"inbox"`
+}
+
+// BatchTagResponse summarizes a batch tag operation
+type BatchTagResponse struct {
+	Matched   int      `json:"matched" example:"1000"`
+	Updated   int      `json:"updated" example:"990"`
+	Unchanged int      `json:"unchanged" example:"10"`
+	NotFound  []string `json:"notFound,omitempty"`
+}
+
+// HandleV2BatchTags adds/removes tags on many files at once
+// @Summary Batch tag files
+// @Description Adds and/or removes tags on a list of files (fileIds) or on all files matching a filter, in one transaction.
+// @Tags 02 - Files
+// @Accept json
+// @Produce json
+// @Param body body BatchTagRequest true "Files (fileIds or filter) and tags to add/remove"
+// @Success 200 {object} BatchTagResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v2/files/tags [post]
+func (s *Server) HandleV2BatchTags(w http.ResponseWriter, r *http.Request) {
+	var req BatchTagRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	add := parseTagValues(req.Add)
+	remove := parseTagValues(req.Remove)
+	if len(add) == 0 && len(remove) == 0 {
+		http.Error(w, "Nothing to do: add or remove must contain at least one tag", http.StatusBadRequest)
+		return
+	}
+	if len(req.FileIDs) > maxBatchTagFileIDs {
+		http.Error(w, fmt.Sprintf("Too many fileIds (max %d)", maxBatchTagFileIDs), http.StatusBadRequest)
+		return
+	}
+
+	var filter storage.TagFilter
+	if req.Filter != nil {
+		filter = storage.TagFilter{Tag: req.Filter.Tag, OldIDFrom: req.Filter.OldIDFrom, OldIDTo: req.Filter.OldIDTo}
+	}
+	if len(req.FileIDs) == 0 && filter.IsEmpty() {
+		http.Error(w, "Either fileIds or filter is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.FileIDs) > 0 && !filter.IsEmpty() {
+		http.Error(w, "Use either fileIds or filter, not both", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.FileService.MetaStore.BatchUpdateTags(req.FileIDs, filter, add, remove)
+	if err != nil {
+		utils.Error("TAGS", "Batch tag update failed: files=%d, filter=%+v, error=%v", len(req.FileIDs), filter, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	utils.Info("TAGS", "Batch tag update: matched=%d, updated=%d, unchanged=%d, not_found=%d, add=%v, remove=%v, remote=%s",
+		result.Matched, result.Updated, result.Unchanged, len(result.NotFound), add, remove, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BatchTagResponse{
+		Matched:   result.Matched,
+		Updated:   result.Updated,
+		Unchanged: result.Unchanged,
+		NotFound:  result.NotFound,
+	})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// TakeoutRequest is the body of POST /system/takeout
+type TakeoutRequest struct {
+	Tags      []string `json:"tags" example:"tenant-42"`            // files carrying any of the tags are exported
+	Validity  string   `json:"validity,omitempty" example:"7 days"` // how long the archive stays downloadable, default TEMP_FILE_VALIDITY
+	ExpiresAt string   `json:"expires_at,omitempty"`                // absolute expiry (RFC 3339), instead of validity
+}
+
+// takeoutJobType is the type of takeout jobs in /system/jobs
+const takeoutJobType = "takeout"
+
+// TakeoutJobResult is the progress of a completed takeout job in /system/jobs
+type TakeoutJobResult struct {
+	service.TakeoutResult
+	DownloadURL string `json:"download_url" example:"/v2/files/xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"`
+}
+
+// HandleSystemTakeout starts a data export of a tag set
+// @Summary Export files by tags (takeout)
+// @Description Starts a job that exports all unexpired files carrying any of the tags as one tar archive, for data portability requests: metadata.json (file info of every file and its path in the archive) followed by the content as files/<file ID>_<name>. The archive is stored as a temporary file (tags 'takeout' and 'temporary', validity TEMP_FILE_VALIDITY unless given); when the job in /system/jobs?id= completes, its progress holds file_id and download_url (shown only with admin Basic auth).
+// @Tags 04 - System
+// @Accept json
+// @Produce json
+// @Param request body TakeoutRequest true "Tags to export"
+// @Success 202 {object} map[string]interface{} "jobId of the export"
+// @Failure 400 {string} string "Missing tags or invalid validity"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Router /system/takeout [post]
+func (s *Server) HandleSystemTakeout(w http.ResponseWriter, r *http.Request) {
+	var req TakeoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	var tags []string
+	for _, tag := range req.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		http.Error(w, "Missing tags", http.StatusBadRequest)
+		return
+	}
+	expiresAt, err := s.parseExpiry(req.Validity, req.ExpiresAt, s.tempUploadScope())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	job := globalJobManager.CreateJob(takeoutJobType, nil)
+	go func() {
+		globalJobManager.UpdateJob(job.ID, JobStatusRunning, "Collecting file metadata", nil)
+		result, err := s.FileService.CreateTakeout(tags, expiresAt, []string{TempFileTag}, func(p service.ArchiveResult) {
+			globalJobManager.UpdateJob(job.ID, JobStatusRunning, fmt.Sprintf("Exported %d files, %d bytes", p.Files, p.Bytes), nil)
+		})
+		if err != nil {
+			utils.Error("TAKEOUT", "Takeout failed: tags=%v, error=%v", tags, err)
+			globalJobManager.UpdateJob(job.ID, JobStatusFailed, "", err)
+			return
+		}
+		utils.Info("TAKEOUT", "Takeout stored: tags=%v, file_id=%s, files=%d, bytes=%d", tags, result.FileID, result.Files, result.Bytes)
+		data, _ := json.Marshal(TakeoutJobResult{TakeoutResult: result, DownloadURL: "/v2/files/" + result.FileID})
+		globalJobManager.UpdateJob(job.ID, JobStatusCompleted, string(data), nil)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobId":   job.ID,
+		"message": "Takeout started",
+	})
+}
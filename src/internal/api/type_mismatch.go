@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+const (
+	defaultTypeMismatchListLimit = 100
+	maxTypeMismatchListLimit     = 10000
+)
+
+// TypeMismatchListResponse is the body of GET /system/files/type-mismatches
+type TypeMismatchListResponse struct {
+	Total      int64                  `json:"total" example:"3"` // all flagged files
+	Count      int                    `json:"count" example:"1"`
+	Mismatches []storage.TypeMismatch `json:"mismatches"`
+}
+
+// HandleSystemTypeMismatches lists files whose content conflicts with the filename extension
+// @Summary List files with an extension/content type mismatch
+// @Description Lists the files whose content type detected by signature at upload conflicts with the filename extension (e.g. a .pdf that is a ZIP), newest first – typically broken exports of a partner. expected is the MIME type of the extension, detected that of the content. Generic binary content, unknown extensions and formats sharing a container (ZIP and Office documents, OLE, MP4 and QuickTime) are not flagged. The flag is also in the file info as type_mismatch. Requires admin Basic auth.
+// @Tags 04 - System
+// @Produce json
+// @Param limit query int false "Max number of files (default 100, max 10000)"
+// @Success 200 {object} TypeMismatchListResponse
+// @Failure 400 {string} string "Invalid limit"
+// @Failure 401 {string} string "Unauthorized"
+// @Security BasicAuth
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /system/files/type-mismatches [get]
+func (s *Server) HandleSystemTypeMismatches(w http.ResponseWriter, r *http.Request) {
+	limit := defaultTypeMismatchListLimit
+	if val := r.URL.Query().Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxTypeMismatchListLimit)
+	}
+
+	mismatches, total, err := s.FileService.MetaStore.ListTypeMismatches(limit)
+	if err != nil {
+		utils.Error("SYSTEM", "Failed to list type mismatches: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TypeMismatchListResponse{Total: total, Count: len(mismatches), Mismatches: mismatches})
+}
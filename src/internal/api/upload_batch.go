@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// DefaultBatchUploadConcurrency is the number of files of one request stored in parallel
+const DefaultBatchUploadConcurrency = 4
+
+// BatchUploadResult is the outcome of one file of a multi-file upload, in the order of the parts
+type BatchUploadResult struct {
+	Filename string `json:"filename" example:"photo.jpg"`
+	Status   int    `json:"status" example:"201"` // HTTP status the file would get as a single upload
+	Error    string `json:"error,omitempty"`
+	*UploadResponse
+}
+
+// batchUploadItem are the per-file form values of a multi-file upload
+type batchUploadItem struct {
+	header       *multipart.FileHeader
+	oldCumulusID *int64
+	contentType  string
+}
+
+// parseBatchUploadItems pairs the file parts with old_cumulus_id and content_type. Each of them
+// is given either once per file (in the order of the files) or not at all; content_type may
+// also be given once for all files.
+func parseBatchUploadItems(r *http.Request, parts []*multipart.FileHeader) ([]batchUploadItem, error) {
+	oldIDs := r.MultipartForm.Value["old_cumulus_id"]
+	if len(oldIDs) != 0 && len(oldIDs) != len(parts) {
+		return nil, fmt.Errorf("old_cumulus_id must be given once per file (%d files, %d values)", len(parts), len(oldIDs))
+	}
+	contentTypes := r.MultipartForm.Value["content_type"]
+	if len(contentTypes) > 1 && len(contentTypes) != len(parts) {
+		return nil, fmt.Errorf("content_type must be given once or once per file (%d files, %d values)", len(parts), len(contentTypes))
+	}
+
+	items := make([]batchUploadItem, len(parts))
+	for i, header := range parts {
+		items[i] = batchUploadItem{header: header, contentType: header.Header.Get("Content-Type")}
+		if len(oldIDs) > 0 && oldIDs[i] != "" {
+			id, err := strconv.ParseInt(oldIDs[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid old_cumulus_id %q", oldIDs[i])
+			}
+			items[i].oldCumulusID = &id
+		}
+
+		val := ""
+		switch len(contentTypes) {
+		case 1:
+			val = contentTypes[0]
+		case len(parts):
+			val = contentTypes[i]
+		}
+		if val != "" {
+			mediaType, err := parseContentTypeField(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid content_type %q", val)
+			}
+			items[i].contentType = mediaType
+		}
+	}
+	return items, nil
+}
+
+// handleBatchUpload stores all file parts of one request concurrently (BATCH_UPLOAD_CONCURRENCY)
+// and returns the results as an array: 201 if all files were stored, 207 otherwise.
+// A failed file doesn't stop the others.
+func (s *Server) handleBatchUpload(w http.ResponseWriter, r *http.Request, parts []*multipart.FileHeader, opts uploadOptions, verbose bool) {
+	items, err := parseBatchUploadItems(r, parts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	concurrency := s.BatchUploadConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchUploadConcurrency
+	}
+	utils.Info("UPLOAD", "Starting batch upload: files=%d, concurrency=%d, remote=%s", len(items), concurrency, r.RemoteAddr)
+
+	results := make([]BatchUploadResult, len(items))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, item batchUploadItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			res := BatchUploadResult{Filename: filepath.Base(item.header.Filename), Status: http.StatusCreated}
+			stored, err := s.storeUploadedPart(r, item.header, item.oldCumulusID, item.contentType, opts)
+			switch {
+			case errors.Is(err, service.ErrOldCumulusIDConflict):
+				res.Status = http.StatusConflict
+				res.Error = "old_cumulus_id already assigned to a different file"
+			case err != nil:
+				res.Status, res.Error = uploadErrorStatus(err)
+			default:
+				resp := s.newUploadResponse(stored, verbose)
+				res.UploadResponse = &resp
+			}
+			results[i] = res
+		}(i, item)
+	}
+	wg.Wait()
+
+	status := http.StatusCreated
+	failed := 0
+	for _, res := range results {
+		if res.Status != http.StatusCreated {
+			failed++
+		}
+	}
+	if failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	utils.Info("UPLOAD", "Batch upload finished: files=%d, failed=%d, remote=%s", len(results), failed, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// ExistingBlobResponse is returned by a conditional upload when the content is already stored
+type ExistingBlobResponse struct {
+	Error    string `json:"error" example:"content already stored"`
+	Hash     string `json:"hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Size     int64  `json:"size" example:"1048576"`
+	MimeType string `json:"mimeType" example:"image/jpeg"`
+	Hint     string `json:"hint" example:"repeat the request with ?filename=<name> to link the stored content"`
+}
+
+// parseHashPrecondition extracts a BLAKE2b-256 hex hash from an If-None-Match header.
+// Accepts `"<hash>"`, `W/"<hash>"` and a bare hash; returns "" for anything else (including `*`).
+func parseHashPrecondition(header string) string {
+	value := strings.TrimSpace(header)
+	value = strings.TrimPrefix(value, "W/")
+	value = strings.Trim(value, `"`)
+	value = strings.ToLower(value)
+	if len(value) != 64 {
+		return ""
+	}
+	for _, c := range value {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return ""
+		}
+	}
+	return value
+}
+
+// handleConditionalUpload answers an upload carrying If-None-Match: "<hash>" without reading the body
+// when a committed blob with that hash exists and the caller may link it (mayLinkBlob):
+//   - with ?filename=<name> a new file record is linked to the stored blob (200 + UploadResponse),
+//     optional old_cumulus_id, on_conflict, disposition, validity, expires_at and tags are taken from the query string as well,
+//   - otherwise 409 Conflict with a description of the stored blob.
+//
+// Returns false when the blob is unknown or out of the caller's scope and the upload has to proceed
+// normally, so the answer tells nothing about content the caller may not read.
+func (s *Server) handleConditionalUpload(w http.ResponseWriter, r *http.Request, hash string, verbose bool, scope uploadScope) bool {
+	query := r.URL.Query()
+	if !s.mayLinkBlob(r, hash, parseTagValues(query["tags"])) {
+		return false
+	}
+	blob, err := s.FileService.FindCommittedBlob(hash)
+	if err != nil {
+		if !errors.Is(err, service.ErrNotFound) {
+			utils.Warn("UPLOAD", "Conditional upload lookup failed: hash=%s, error=%v", hash, err)
+		}
+		return false
+	}
+
+	filename := filepath.Base(query.Get("filename"))
+	if filename == "." || filename == ".." || filename == "/" {
+		mimeType := ""
+		if fileType, err := s.FileService.MetaStore.GetFileType(blob.FileTypeID); err == nil {
+			mimeType = fileType.MimeType
+		}
+		utils.Info("UPLOAD", "Conditional upload: content exists, body skipped: hash=%s, blob_id=%d, remote=%s", hash, blob.ID, r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", fmt.Sprintf("\"%s\"", hash))
+		setDedupHeaders(w, service.UploadResult{BlobID: blob.ID, Dedup: true})
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(ExistingBlobResponse{
+			Error:    "content already stored",
+			Hash:     hash,
+			Size:     blob.SizeRaw,
+			MimeType: mimeType,
+			Hint:     "repeat the request with ?filename=<name> to link the stored content",
+		})
+		return true
+	}
+
+	var oldCumulusID *int64
+	if val := query.Get("old_cumulus_id"); val != "" {
+		id, err := strconv.ParseInt(val, 10, 64)
+		if err == nil {
+			oldCumulusID = &id
+		}
+	}
+
+	onConflict, err := service.ParseOldIDConflictMode(query.Get("on_conflict"))
+	if err != nil {
+		http.Error(w, "Invalid on_conflict: "+err.Error(), http.StatusBadRequest)
+		return true
+	}
+	disposition, err := service.ParseDisposition(query.Get("disposition"))
+	if err != nil {
+		http.Error(w, "Invalid disposition: "+err.Error(), http.StatusBadRequest)
+		return true
+	}
+
+	expiresAt, err := s.parseExpiry(query.Get("validity"), query.Get("expires_at"), scope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return true
+	}
+	tagsStr := storage.TagsToJSON(scope.withTags(parseTagValues(query["tags"])))
+
+	linked, err := s.FileService.LinkExistingBlob(hash, filename, oldCumulusID, expiresAt, nil, tagsStr, disposition, onConflict)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			// Blob zmizel mezi dotazy (cleanup) – klient musí poslat obsah
+			return false
+		}
+		utils.Info("UPLOAD", "ERROR: conditional link filename=%s, hash=%s, remote=%s, error=%v", filename, hash, r.RemoteAddr, err)
+		status, msg := uploadErrorStatus(err)
+		http.Error(w, msg, status)
+		return true
+	}
+
+	uploadOpsTotal.WithLabelValues("linked", "unknown").Inc()
+	dedupHitsTotal.Inc()
+	recordUploadedFile(r, linked.MimeType)
+	utils.Info("UPLOAD", "SUCCESS: conditional link filename=%s, file_id=%s, hash=%s, remote=%s", filename, linked.FileID, hash, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", hash))
+	setDedupHeaders(w, linked)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.newUploadResponse(linked, verbose))
+	return true
+}
+
+// mayLinkBlob reports whether the caller may skip the body of content it names only by hash. A hash
+// proves nothing about having the content, so only admin Basic auth links any content; other callers
+// need tags of which a live file of the content carries one, the namespace of the client as in
+// POST /v2/blobs/lookup.
+func (s *Server) mayLinkBlob(r *http.Request, hash string, tags []string) bool {
+	if isAdminRequest(r) {
+		return true
+	}
+	if len(tags) == 0 {
+		return false
+	}
+	lookups, err := s.FileService.MetaStore.LookupBlobsByHash([]string{hash}, tags, true)
+	if err != nil {
+		utils.Warn("UPLOAD", "Conditional upload lookup failed: hash=%s, error=%v", hash, err)
+		return false
+	}
+	return len(lookups) == 1 && len(lookups[0].FileIDs) > 0
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+)
+
+func TestMayLinkBlob(t *testing.T) {
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "admin")
+	m := newTestMetadataSQL(t)
+	const hash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	blobID, err := m.CreateBlob(hash)
+	if err != nil {
+		t.Fatalf("CreateBlob: %v", err)
+	}
+	if err := m.UpdateBlobLocation(blobID, 1, 0, 100, 100, "none", 0); err != nil {
+		t.Fatalf("UpdateBlobLocation: %v", err)
+	}
+	file := storage.File{ID: "a1", Name: "a1.txt", BlobID: blobID, CreatedAt: time.Now(), Tags: storage.TagsToJSON([]string{"invoices"})}
+	if err := m.SaveFile(file); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	s := &Server{FileService: &service.FileService{MetaStore: m}}
+
+	tests := []struct {
+		name  string
+		tags  []string
+		admin bool
+		want  bool
+	}{
+		{"no scope", nil, false, false},
+		{"tag of the file", []string{"invoices"}, false, true},
+		{"other tag", []string{"reports"}, false, false},
+		{"admin", nil, true, true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/v2/files/upload", nil)
+		if tt.admin {
+			r.SetBasicAuth("admin", "admin")
+		}
+		if got := s.mayLinkBlob(r, hash, tt.tags); got != tt.want {
+			t.Errorf("%s: mayLinkBlob = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// validityPolicy returns the configured bounds of upload validity
+func (s *Server) validityPolicy() utils.ValidityPolicy {
+	if s.ValidityPolicy.Max == 0 {
+		return utils.DefaultValidityPolicy
+	}
+	return s.ValidityPolicy
+}
+
+// parseExpiry resolves the validity period and the absolute expires_at (RFC 3339) of an upload
+// into its expiry time; without either the scope default applies, nil = the file never expires.
+// The error text is meant for the 400 response.
+func (s *Server) parseExpiry(validity, expiresAt string, scope uploadScope) (*time.Time, error) {
+	policy := s.validityPolicy()
+	if expiresAt != "" {
+		if validity != "" {
+			return nil, fmt.Errorf("Use either validity or expires_at, not both")
+		}
+		exp, err := policy.ParseExpiry(expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid expires_at: %v", err)
+		}
+		return &exp, nil
+	}
+	if val := scope.validity(validity); val != "" {
+		exp, err := policy.Parse(val)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid validity format: %v", err)
+		}
+		return &exp, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+)
+
+// uploadErrorStatus maps an error of storing an upload to the response status and message
+func uploadErrorStatus(err error) (int, string) {
+	switch {
+	case isUploadTooLarge(err):
+		return http.StatusRequestEntityTooLarge, "File too large"
+	case errors.Is(err, service.ErrOldCumulusIDConflict):
+		return http.StatusConflict, "Conflict: old_cumulus_id already assigned to a different file"
+	case errors.Is(err, service.ErrUploadRejected):
+		return http.StatusUnprocessableEntity, err.Error()
+	case errors.Is(err, service.ErrUploadHookUnavailable):
+		return http.StatusServiceUnavailable, service.ErrUploadHookUnavailable.Error()
+	}
+	return http.StatusInternalServerError, "Internal Server Error"
+}
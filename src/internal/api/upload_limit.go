@@ -0,0 +1,33 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// UploadTooLargeResponse is the body of 413 for an upload over MAX_UPLOAD_FILE_SIZE
+type UploadTooLargeResponse struct {
+	Error   string `json:"error" example:"File too large"`
+	MaxSize int64  `json:"maxSize" example:"52428800"`
+}
+
+// isUploadTooLarge reports whether err comes from a request body cut off by http.MaxBytesReader
+func isUploadTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
+
+// writeUploadTooLarge answers an upload over the configured limit with 413 and the limit; kind is
+// the upload path (multipart, raw, session) in the log and the upload_rejected_oversize_total metric
+func (s *Server) writeUploadTooLarge(w http.ResponseWriter, r *http.Request, kind string) {
+	RecordUploadTooLarge(kind)
+	utils.Info("UPLOAD", "Upload over the limit refused: kind=%s, content_length=%d, limit=%d, remote=%s",
+		kind, r.ContentLength, s.MaxUploadSize, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	json.NewEncoder(w).Encode(UploadTooLargeResponse{Error: "File too large", MaxSize: s.MaxUploadSize})
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Raw upload: tělo požadavku je přímo obsah souboru, volby jsou v query stringu (u PUT
+// /v2/files/{name} v hlavičkách X-*). Délka nemusí být předem známá (Transfer-Encoding: chunked) –
+// obsah teče rovnou do zpracování a limit MAX_UPLOAD_FILE_SIZE hlídá MaxBytesReader průběžně,
+// jak data přicházejí.
+
+// useQueryForm makes the form values of r (FormValue, parseUploadOptions) the query string only,
+// so the body – file content – is never parsed as a form
+func useQueryForm(r *http.Request) url.Values {
+	query := r.URL.Query()
+	r.Form = query
+	r.PostForm = url.Values{}
+	r.MultipartForm = &multipart.Form{}
+	return query
+}
+
+// HandleV2RawUpload stores the request body as one file
+// @Summary Upload a file as the raw request body
+// @Description Stores the request body as one file, without multipart encoding. The length does not have to be known in advance: with Transfer-Encoding: chunked the content is processed as it arrives and the upload fails with 413 as soon as it exceeds MAX_UPLOAD_FILE_SIZE; a Content-Length above the limit is refused before the body is read (also before 100 Continue). The filename and the options of the multipart upload are query parameters; the Content-Type header plays the role of the part Content-Type. If-None-Match works as with POST.
+// @Tags 02 - Files
+// @Accept octet-stream
+// @Produce json
+// @Param filename query string true "Filename of the stored file"
+// @Param tags query string false "Tags like array of string or coma separated strings"
+// @Param old_cumulus_id query int false "Legacy ID"
+// @Param on_conflict query string false "When old_cumulus_id belongs to another file: 'reject' (409, default) or 'supersede' (move the ID to the new file)"
+// @Param disposition query string false "Content-Disposition of downloads: 'inline' or 'attachment' (default: by MIME type)"
+// @Param validity query string false "Validity period (e.g. '1 day', '2 weeks', '1 year'), within VALIDITY_MIN..VALIDITY_MAX"
+// @Param expires_at query string false "Absolute expiry time (RFC 3339), instead of validity"
+// @Param content_type query string false "Original MIME type, used when content detection yields generic binary (wins over the Content-Type header)"
+// @Param created_at query string false "Original creation time (RFC 3339, 'YYYY-MM-DD HH:MM:SS' or unix seconds); requires admin Basic auth"
+// @Param If-None-Match header string false "Quoted BLAKE2b-256 hash of the content; if already stored and in scope (admin Basic auth, or tags a live file of the content carries), the body is not read"
+// @Param verbose query boolean false "Include hash, sizes, dedup flag, detected MIME type and expiry in the response"
+// @Param file body string true "File content"
+// @Success 201 {object} UploadResponse "File uploaded successfully, returns file UUID"
+// @Success 200 {object} UploadResponse "Content already stored, file linked without upload (If-None-Match + filename)"
+// @Header 200,201,409 {string} X-Cumulus-Dedup "hit when the content was already stored, miss otherwise"
+// @Header 200,201,409 {string} X-Cumulus-Blob-Id "ID of the blob holding the content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "created_at without admin credentials"
+// @Failure 409 {object} ExistingBlobResponse "Content already stored (If-None-Match without filename)"
+// @Failure 413 {object} UploadTooLargeResponse "File larger than MAX_UPLOAD_FILE_SIZE, maxSize is the limit"
+// @Failure 422 {string} string "Upload rejected by the upload hook (UPLOAD_HOOK_URL), body is its reason"
+// @Failure 500 {string} string "Internal Server Error"
+// @Failure 503 {string} string "Upload hook unavailable (UPLOAD_HOOK_FAIL_OPEN off)"
+// @Router /v2/files/upload [put]
+func (s *Server) HandleV2RawUpload(w http.ResponseWriter, r *http.Request) {
+	timer := prometheus.NewTimer(uploadDuration)
+	defer timer.ObserveDuration()
+
+	query := useQueryForm(r)
+
+	verbose, err := parseVerbose(r)
+	if err != nil {
+		http.Error(w, "Invalid verbose parameter", http.StatusBadRequest)
+		return
+	}
+
+	if hash := parseHashPrecondition(r.Header.Get("If-None-Match")); hash != "" {
+		if s.handleConditionalUpload(w, r, hash, verbose, uploadScope{}) {
+			return
+		}
+	}
+
+	filename := filepath.Base(query.Get("filename"))
+	if filename == "." || filename == ".." || filename == "/" {
+		http.Error(w, "Missing filename", http.StatusBadRequest)
+		return
+	}
+	if r.ContentLength > s.MaxUploadSize {
+		s.writeUploadTooLarge(w, r, "raw")
+		return
+	}
+
+	opts, ok := s.parseUploadOptions(w, r, uploadScope{})
+	if !ok {
+		return
+	}
+
+	var oldCumulusID *int64
+	if val := query.Get("old_cumulus_id"); val != "" {
+		id, err := strconv.ParseInt(val, 10, 64)
+		if err == nil {
+			oldCumulusID = &id
+		}
+	}
+
+	contentType := query.Get("content_type")
+	if contentType == "" {
+		contentType = r.Header.Get("Content-Type")
+	}
+	if contentType != "" {
+		mediaType, err := parseContentTypeField(contentType)
+		if err != nil {
+			http.Error(w, "Invalid content_type", http.StatusBadRequest)
+			return
+		}
+		contentType = mediaType
+	}
+
+	body := http.MaxBytesReader(w, r.Body, s.MaxUploadSize)
+	res, err := s.storeUploadedFile(r, body, filename, r.ContentLength, oldCumulusID, contentType, opts)
+	if isUploadTooLarge(err) {
+		s.writeUploadTooLarge(w, r, "raw")
+		return
+	}
+	if err != nil {
+		status, msg := uploadErrorStatus(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setDedupHeaders(w, res)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s.newUploadResponse(res, verbose))
+}
+
+// rawUploadHeaders maps the metadata headers of PUT /v2/files/{name} to the upload fields
+var rawUploadHeaders = map[string]string{
+	"X-Tags":           "tags",
+	"X-Validity":       "validity",
+	"X-Expires-At":     "expires_at",
+	"X-Old-Cumulus-Id": "old_cumulus_id",
+	"X-On-Conflict":    "on_conflict",
+	"X-Disposition":    "disposition",
+	"X-Created-At":     "created_at",
+}
+
+// HandleV2RawUploadNamed stores the request body as the file name, with metadata in headers
+// @Summary Upload a file as the raw request body, metadata in headers
+// @Description Stores the request body under the filename in the path, like PUT /v2/files/upload but with the options as headers: X-Tags, X-Validity, X-Expires-At, X-Old-Cumulus-Id, X-On-Conflict, X-Disposition and X-Created-At (query parameters of the same meaning are accepted too, a header wins). The length does not have to be known in advance (Transfer-Encoding: chunked). The filename is the last path segment, percent-encoded; a file named "upload" has to use PUT /v2/files/upload?filename=upload.
+// @Tags 02 - Files
+// @Accept octet-stream
+// @Produce json
+// @Param name path string true "Filename of the stored file"
+// @Param X-Tags header string false "Tags like array of string or coma separated strings"
+// @Param X-Validity header string false "Validity period (e.g. '1 day', '2 weeks', '1 year'), within VALIDITY_MIN..VALIDITY_MAX"
+// @Param X-Expires-At header string false "Absolute expiry time (RFC 3339), instead of X-Validity"
+// @Param X-Old-Cumulus-Id header int false "Legacy ID"
+// @Param X-On-Conflict header string false "When X-Old-Cumulus-Id belongs to another file: 'reject' (409, default) or 'supersede' (move the ID to the new file)"
+// @Param X-Disposition header string false "Content-Disposition of downloads: 'inline' or 'attachment' (default: by MIME type)"
+// @Param X-Created-At header string false "Original creation time (RFC 3339, 'YYYY-MM-DD HH:MM:SS' or unix seconds); requires admin Basic auth"
+// @Param Content-Type header string false "Original MIME type, used when content detection yields generic binary"
+// @Param If-None-Match header string false "Quoted BLAKE2b-256 hash of the content; if already stored and in scope (admin Basic auth, or tags a live file of the content carries), the body is not read and the file is linked"
+// @Param verbose query boolean false "Include hash, sizes, dedup flag, detected MIME type and expiry in the response"
+// @Param file body string true "File content"
+// @Success 201 {object} UploadResponse "File uploaded successfully, returns file UUID"
+// @Success 200 {object} UploadResponse "Content already stored, file linked without upload (If-None-Match)"
+// @Header 200,201 {string} X-Cumulus-Dedup "hit when the content was already stored, miss otherwise"
+// @Header 200,201 {string} X-Cumulus-Blob-Id "ID of the blob holding the content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "X-Created-At without admin credentials"
+// @Failure 409 {string} string "X-Old-Cumulus-Id belongs to another file (X-On-Conflict: reject)"
+// @Failure 413 {object} UploadTooLargeResponse "File larger than MAX_UPLOAD_FILE_SIZE, maxSize is the limit"
+// @Failure 422 {string} string "Upload rejected by the upload hook (UPLOAD_HOOK_URL), body is its reason"
+// @Failure 500 {string} string "Internal Server Error"
+// @Failure 503 {string} string "Upload hook unavailable (UPLOAD_HOOK_FAIL_OPEN off)"
+// @Router /v2/files/{name} [put]
+func (s *Server) HandleV2RawUploadNamed(w http.ResponseWriter, r *http.Request) {
+	// Hlavičky a jméno z cesty se převedou na query string, dál stejná cesta jako PUT /v2/files/upload
+	query := r.URL.Query()
+	for header, field := range rawUploadHeaders {
+		if val := r.Header.Get(header); val != "" {
+			query.Set(field, val)
+		}
+	}
+	query.Set("filename", r.PathValue("name"))
+	r.URL.RawQuery = query.Encode()
+	s.HandleV2RawUpload(w, r)
+}
@@ -0,0 +1,538 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// Navazující upload (po vzoru tus): POST založí session, PATCH přidávají části od Upload-Offset.
+// Přijatý obsah leží v <DATA_DIR>/uploads/<id>.part, session v <id>.json – přežije i restart
+// serveru. Po poslední části jde soubor stejnou cestou jako běžný upload (processStream, saveBlob).
+
+// DefaultUploadSessionTTL is how long an upload session is kept after its last chunk
+const DefaultUploadSessionTTL = 24 * time.Hour
+
+// UploadSession is the state of a resumable upload
+type UploadSession struct {
+	ID          string          `json:"id" example:"0b9d3f52-7a43-4d7e-9c1e-2f6f0c8f4a11"`
+	Filename    string          `json:"filename" example:"scan.tiff"`
+	Length      int64           `json:"length" example:"734003200"`
+	Offset      int64           `json:"offset" example:"268435456"` // bytes received
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+	ExpiresAt   time.Time       `json:"expiresAt"`        // removed when not continued until then
+	Result      *UploadResponse `json:"result,omitempty"` // the stored file once the upload is complete
+	uploadState `json:"-"`
+}
+
+// uploadState is what the session keeps for storing the file: the options of the create request
+type uploadState struct {
+	ContentType   string                    `json:"contentType,omitempty"`
+	OldCumulusID  *int64                    `json:"oldCumulusId,omitempty"`
+	OnConflict    service.OldIDConflictMode `json:"onConflict"`
+	Disposition   string                    `json:"disposition,omitempty"`
+	FileExpiresAt *time.Time                `json:"fileExpiresAt,omitempty"`
+	FileCreatedAt *time.Time                `json:"fileCreatedAt,omitempty"`
+	Tags          string                    `json:"tags,omitempty"`
+	Verbose       bool                      `json:"verbose,omitempty"`
+}
+
+// uploadSessionFile is the stored form of a session
+type uploadSessionFile struct {
+	UploadSession
+	State uploadState `json:"state"`
+}
+
+// errUploadSessionBusy is returned while another request writes to the session
+var errUploadSessionBusy = errors.New("upload session is busy")
+
+// UploadSessions stores the resumable upload sessions in a directory
+type UploadSessions struct {
+	dir string
+	ttl time.Duration
+
+	mu   sync.Mutex
+	busy map[string]bool
+}
+
+// NewUploadSessions creates the session directory
+func NewUploadSessions(dir string, ttl time.Duration) (*UploadSessions, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &UploadSessions{dir: dir, ttl: ttl, busy: map[string]bool{}}, nil
+}
+
+// Start removes expired sessions periodically in the background
+func (u *UploadSessions) Start() {
+	go func() {
+		ticker := time.NewTicker(min(u.ttl/4, time.Hour))
+		defer ticker.Stop()
+		for range ticker.C {
+			if n := u.RemoveExpired(); n > 0 {
+				utils.Info("UPLOAD_SESSION", "Removed %d expired upload sessions", n)
+			}
+		}
+	}()
+}
+
+func (u *UploadSessions) path(id, ext string) string {
+	return filepath.Join(u.dir, id+ext)
+}
+
+// lock reserves the session for one request; the caller calls unlock
+func (u *UploadSessions) lock(id string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.busy[id] {
+		return errUploadSessionBusy
+	}
+	u.busy[id] = true
+	return nil
+}
+
+func (u *UploadSessions) unlock(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.busy, id)
+}
+
+// create stores a new session with an empty part file
+func (u *UploadSessions) create(filename string, length int64, state uploadState) (*UploadSession, error) {
+	now := time.Now().UTC()
+	sess := &UploadSession{
+		ID:          uuid.New().String(),
+		Filename:    filename,
+		Length:      length,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ExpiresAt:   now.Add(u.ttl),
+		uploadState: state,
+	}
+	part, err := os.OpenFile(u.path(sess.ID, ".part"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	part.Close()
+	if err := u.save(sess); err != nil {
+		os.Remove(u.path(sess.ID, ".part"))
+		return nil, err
+	}
+	return sess, nil
+}
+
+// load reads a session; the offset is the size of its part file. Returns os.ErrNotExist for an
+// unknown or expired session.
+func (u *UploadSessions) load(id string) (*UploadSession, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(u.path(id, ".json"))
+	if err != nil {
+		return nil, err
+	}
+	var stored uploadSessionFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid upload session %s: %w", id, err)
+	}
+	sess := stored.UploadSession
+	sess.uploadState = stored.State
+	if time.Now().After(sess.ExpiresAt) {
+		return nil, os.ErrNotExist
+	}
+	if sess.Result != nil {
+		sess.Offset = sess.Length
+		return &sess, nil
+	}
+	info, err := os.Stat(u.path(id, ".part"))
+	if err != nil {
+		return nil, err
+	}
+	sess.Offset = info.Size()
+	return &sess, nil
+}
+
+// save writes the session atomically
+func (u *UploadSessions) save(sess *UploadSession) error {
+	data, err := json.Marshal(uploadSessionFile{UploadSession: *sess, State: sess.uploadState})
+	if err != nil {
+		return err
+	}
+	tmp := u.path(sess.ID, ".json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, u.path(sess.ID, ".json"))
+}
+
+// remove deletes the session and its content
+func (u *UploadSessions) remove(id string) {
+	os.Remove(u.path(id, ".part"))
+	os.Remove(u.path(id, ".json"))
+}
+
+// RemoveExpired deletes the sessions not continued within the TTL (and parts without a session
+// left by a crash); returns the number of removed sessions
+func (u *UploadSessions) RemoveExpired() int {
+	entries, err := os.ReadDir(u.dir)
+	if err != nil {
+		utils.Warn("UPLOAD_SESSION", "Failed to list upload sessions: %v", err)
+		return 0
+	}
+	removed := 0
+	now := time.Now()
+	for _, e := range entries {
+		id, ext, _ := strings.Cut(e.Name(), ".")
+		info, err := e.Info()
+		if err != nil || u.lock(id) != nil {
+			continue
+		}
+		switch ext {
+		case "json":
+			var stored uploadSessionFile
+			data, err := os.ReadFile(u.path(id, ".json"))
+			if err == nil {
+				err = json.Unmarshal(data, &stored)
+			}
+			if err != nil || now.After(stored.ExpiresAt) {
+				u.remove(id)
+				removed++
+			}
+		case "part", "json.tmp":
+			if _, err := os.Stat(u.path(id, ".json")); errors.Is(err, os.ErrNotExist) && now.Sub(info.ModTime()) > u.ttl {
+				os.Remove(u.path(id, "."+ext))
+			}
+		}
+		u.unlock(id)
+	}
+	if removed > 0 {
+		RecordUploadSessions("expired", removed)
+	}
+	return removed
+}
+
+// setUploadOffsetHeaders sets the tus-style progress headers of a session
+func setUploadOffsetHeaders(w http.ResponseWriter, sess *UploadSession) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(sess.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(sess.Length, 10))
+	w.Header().Set("Upload-Expires", sess.ExpiresAt.Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// writeUploadSessionError answers a failed lookup or lock of a session
+func writeUploadSessionError(w http.ResponseWriter, id string, err error) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		http.Error(w, "Upload session not found", http.StatusNotFound)
+	case errors.Is(err, errUploadSessionBusy):
+		http.Error(w, "Another request is writing to the upload session", http.StatusLocked)
+	default:
+		utils.Error("UPLOAD_SESSION", "Session %s failed: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// HandleV2UploadSessionCreate starts a resumable upload
+// @Summary Start a resumable upload
+// @Description Creates an upload session for a file of Upload-Length bytes (at most MAX_UPLOAD_FILE_SIZE). The content is then sent in any number of PATCH requests to the Location, each continuing at the Upload-Offset the server reports; after a broken connection the client asks HEAD for the offset and continues from there instead of restarting. The filename and the options of the upload are query parameters, checked now and applied when the last chunk arrives (validity counts from the creation of the session). Sessions are kept UPLOAD_SESSION_TTL after their last chunk.
+// @Tags 02 - Files
+// @Produce json
+// @Param Upload-Length header int true "Size of the file in bytes"
+// @Param filename query string true "Filename of the stored file"
+// @Param tags query string false "Tags like array of string or coma separated strings"
+// @Param old_cumulus_id query int false "Legacy ID"
+// @Param on_conflict query string false "When old_cumulus_id belongs to another file: 'reject' (409, default) or 'supersede' (move the ID to the new file)"
+// @Param disposition query string false "Content-Disposition of downloads: 'inline' or 'attachment' (default: by MIME type)"
+// @Param validity query string false "Validity period (e.g. '1 day', '2 weeks', '1 year'), within VALIDITY_MIN..VALIDITY_MAX"
+// @Param expires_at query string false "Absolute expiry time (RFC 3339), instead of validity"
+// @Param content_type query string false "Original MIME type, used when content detection yields generic binary"
+// @Param created_at query string false "Original creation time (RFC 3339, 'YYYY-MM-DD HH:MM:SS' or unix seconds); requires admin Basic auth"
+// @Param verbose query boolean false "Include hash, sizes, dedup flag, detected MIME type and expiry in the final response"
+// @Success 201 {object} UploadSession "Session created, Location is its URL"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "created_at without admin credentials"
+// @Failure 404 {string} string "Resumable uploads are disabled (UPLOAD_SESSION_TTL=0)"
+// @Failure 413 {object} UploadTooLargeResponse "File larger than MAX_UPLOAD_FILE_SIZE, maxSize is the limit"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v2/uploads [post]
+func (s *Server) HandleV2UploadSessionCreate(w http.ResponseWriter, r *http.Request) {
+	if s.UploadSessions == nil {
+		http.Error(w, "Resumable uploads are disabled", http.StatusNotFound)
+		return
+	}
+	query := useQueryForm(r)
+
+	verbose, err := parseVerbose(r)
+	if err != nil {
+		http.Error(w, "Invalid verbose parameter", http.StatusBadRequest)
+		return
+	}
+	filename := filepath.Base(query.Get("filename"))
+	if filename == "." || filename == ".." || filename == "/" {
+		http.Error(w, "Missing filename", http.StatusBadRequest)
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "Missing or invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if length > s.MaxUploadSize {
+		s.writeUploadTooLarge(w, r, "session")
+		return
+	}
+
+	opts, ok := s.parseUploadOptions(w, r, uploadScope{})
+	if !ok {
+		return
+	}
+	state := uploadState{
+		OnConflict:    opts.onConflict,
+		Disposition:   opts.disposition,
+		FileExpiresAt: opts.expiresAt,
+		FileCreatedAt: opts.createdAt,
+		Tags:          opts.tags,
+		Verbose:       verbose,
+	}
+	if val := query.Get("old_cumulus_id"); val != "" {
+		if id, err := strconv.ParseInt(val, 10, 64); err == nil {
+			state.OldCumulusID = &id
+		}
+	}
+	if val := query.Get("content_type"); val != "" {
+		mediaType, err := parseContentTypeField(val)
+		if err != nil {
+			http.Error(w, "Invalid content_type", http.StatusBadRequest)
+			return
+		}
+		state.ContentType = mediaType
+	}
+
+	sess, err := s.UploadSessions.create(filename, length, state)
+	if err != nil {
+		writeUploadSessionError(w, "", err)
+		return
+	}
+	RecordUploadSessions("created", 1)
+	utils.Info("UPLOAD_SESSION", "Created: id=%s, filename=%s, length=%d, remote=%s", sess.ID, filename, length, r.RemoteAddr)
+
+	setUploadOffsetHeaders(w, sess)
+	w.Header().Set("Location", "/v2/uploads/"+sess.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sess)
+}
+
+// HandleV2UploadSessionPatch appends a chunk to a resumable upload
+// @Summary Upload a chunk of a resumable upload
+// @Description Appends the request body to the upload session. Upload-Offset must equal the number of bytes the server has (HEAD), otherwise 409 reports the current offset in Upload-Offset. Bytes received before a connection broke are kept. Answers 204 with the new Upload-Offset; the chunk that completes the file stores it like a normal upload and answers 201 with the upload response, which also stays in the session. If storing fails (e.g. 422 from the upload hook), an empty PATCH at the full offset retries it.
+// @Tags 02 - Files
+// @Accept octet-stream
+// @Produce json
+// @Param id path string true "Upload session ID"
+// @Param Upload-Offset header int true "Offset the chunk starts at"
+// @Param chunk body string true "Chunk content"
+// @Success 201 {object} UploadResponse "Last chunk received, file stored"
+// @Success 204 {string} string "Chunk stored, Upload-Offset is the new offset"
+// @Header 201 {string} X-Cumulus-Dedup "hit when the content was already stored, miss otherwise"
+// @Header 201 {string} X-Cumulus-Blob-Id "ID of the blob holding the content"
+// @Failure 400 {string} string "Missing Upload-Offset or chunk interrupted"
+// @Failure 404 {string} string "Upload session not found or expired"
+// @Failure 409 {string} string "Upload-Offset does not match or the upload is already complete"
+// @Failure 413 {string} string "Chunk exceeds Upload-Length"
+// @Failure 422 {string} string "Upload rejected by the upload hook (UPLOAD_HOOK_URL), body is its reason"
+// @Failure 423 {string} string "Another request is writing to the session"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v2/uploads/{id} [patch]
+func (s *Server) HandleV2UploadSessionPatch(w http.ResponseWriter, r *http.Request) {
+	if s.UploadSessions == nil {
+		http.Error(w, "Resumable uploads are disabled", http.StatusNotFound)
+		return
+	}
+	id := r.PathValue("id")
+	if err := s.UploadSessions.lock(id); err != nil {
+		writeUploadSessionError(w, id, err)
+		return
+	}
+	defer s.UploadSessions.unlock(id)
+
+	sess, err := s.UploadSessions.load(id)
+	if err != nil {
+		writeUploadSessionError(w, id, err)
+		return
+	}
+	setUploadOffsetHeaders(w, sess)
+	if sess.Result != nil {
+		http.Error(w, "Upload already complete", http.StatusConflict)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		http.Error(w, "Missing or invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+	if offset != sess.Offset {
+		http.Error(w, fmt.Sprintf("Upload-Offset %d does not match the received %d bytes", offset, sess.Offset), http.StatusConflict)
+		return
+	}
+
+	n, err := s.appendUploadChunk(w, r, sess)
+	sess.Offset += n
+	sess.UpdatedAt = time.Now().UTC()
+	sess.ExpiresAt = sess.UpdatedAt.Add(s.UploadSessions.ttl)
+	if saveErr := s.UploadSessions.save(sess); saveErr != nil {
+		writeUploadSessionError(w, id, saveErr)
+		return
+	}
+	setUploadOffsetHeaders(w, sess)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Chunk exceeds Upload-Length", http.StatusRequestEntityTooLarge)
+			return
+		}
+		utils.Info("UPLOAD_SESSION", "Chunk interrupted: id=%s, offset=%d, remote=%s, error=%v", id, sess.Offset, r.RemoteAddr, err)
+		http.Error(w, "Chunk interrupted", http.StatusBadRequest)
+		return
+	}
+	if sess.Offset < sess.Length {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.completeUploadSession(w, r, sess)
+}
+
+// appendUploadChunk appends the request body to the part file of the session; returns the bytes
+// written, which stay even when the body breaks off. A chunk running past the length is discarded
+// whole.
+func (s *Server) appendUploadChunk(w http.ResponseWriter, r *http.Request, sess *UploadSession) (int64, error) {
+	part, err := os.OpenFile(s.UploadSessions.path(sess.ID, ".part"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer part.Close()
+	n, err := io.Copy(part, http.MaxBytesReader(w, r.Body, sess.Length-sess.Offset))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		if truncErr := part.Truncate(sess.Offset); truncErr != nil {
+			return n, truncErr
+		}
+		return 0, err
+	}
+	if syncErr := part.Sync(); err == nil {
+		err = syncErr
+	}
+	return n, err
+}
+
+// completeUploadSession stores the received file and keeps the upload response in the session
+func (s *Server) completeUploadSession(w http.ResponseWriter, r *http.Request, sess *UploadSession) {
+	part, err := os.Open(s.UploadSessions.path(sess.ID, ".part"))
+	if err != nil {
+		writeUploadSessionError(w, sess.ID, err)
+		return
+	}
+	defer part.Close()
+
+	opts := uploadOptions{
+		onConflict:  sess.OnConflict,
+		disposition: sess.Disposition,
+		expiresAt:   sess.FileExpiresAt,
+		createdAt:   sess.FileCreatedAt,
+		tags:        sess.Tags,
+	}
+	stored, err := s.storeUploadedFile(r, part, sess.Filename, sess.Length, sess.OldCumulusID, sess.ContentType, opts)
+	if err != nil {
+		status, msg := uploadErrorStatus(err)
+		http.Error(w, msg, status)
+		return
+	}
+	resp := s.newUploadResponse(stored, sess.Verbose)
+	sess.Result = &resp
+	if err := s.UploadSessions.save(sess); err != nil {
+		utils.Warn("UPLOAD_SESSION", "Failed to keep the result of session %s: %v", sess.ID, err)
+	}
+	os.Remove(s.UploadSessions.path(sess.ID, ".part"))
+	RecordUploadSessions("completed", 1)
+	utils.Info("UPLOAD_SESSION", "Completed: id=%s, file_id=%s, remote=%s", sess.ID, stored.FileID, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	setDedupHeaders(w, stored)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleV2UploadSession returns the state of a resumable upload
+// @Summary Get the state of a resumable upload
+// @Description Returns the received offset and, once complete, the upload response. HEAD returns only the Upload-Offset, Upload-Length and Upload-Expires headers – the way to find where to continue after a broken connection.
+// @Tags 02 - Files
+// @Produce json
+// @Param id path string true "Upload session ID"
+// @Success 200 {object} UploadSession "Upload-Offset, Upload-Length and Upload-Expires headers; body only with GET"
+// @Failure 404 {string} string "Upload session not found or expired"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v2/uploads/{id} [get]
+// @Router /v2/uploads/{id} [head]
+func (s *Server) HandleV2UploadSession(w http.ResponseWriter, r *http.Request) {
+	if s.UploadSessions == nil {
+		http.Error(w, "Resumable uploads are disabled", http.StatusNotFound)
+		return
+	}
+	id := r.PathValue("id")
+	sess, err := s.UploadSessions.load(id)
+	if err != nil {
+		writeUploadSessionError(w, id, err)
+		return
+	}
+	setUploadOffsetHeaders(w, sess)
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sess)
+}
+
+// HandleV2UploadSessionDelete aborts a resumable upload
+// @Summary Abort a resumable upload
+// @Description Deletes the upload session and the content received so far. A file already stored by the session is not affected.
+// @Tags 02 - Files
+// @Param id path string true "Upload session ID"
+// @Success 204 {string} string "Session deleted"
+// @Failure 404 {string} string "Upload session not found or expired"
+// @Failure 423 {string} string "Another request is writing to the session"
+// @Router /v2/uploads/{id} [delete]
+func (s *Server) HandleV2UploadSessionDelete(w http.ResponseWriter, r *http.Request) {
+	if s.UploadSessions == nil {
+		http.Error(w, "Resumable uploads are disabled", http.StatusNotFound)
+		return
+	}
+	id := r.PathValue("id")
+	if err := s.UploadSessions.lock(id); err != nil {
+		writeUploadSessionError(w, id, err)
+		return
+	}
+	defer s.UploadSessions.unlock(id)
+	if _, err := s.UploadSessions.load(id); err != nil {
+		writeUploadSessionError(w, id, err)
+		return
+	}
+	s.UploadSessions.remove(id)
+	RecordUploadSessions("aborted", 1)
+	utils.Info("UPLOAD_SESSION", "Aborted: id=%s, remote=%s", id, r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
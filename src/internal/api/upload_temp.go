@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+)
+
+const (
+	// TempFileTag is added to every file uploaded through /v2/files/tmp
+	TempFileTag = "temporary"
+	// DefaultTempFileValidity is the validity of temporary uploads without TEMP_FILE_VALIDITY
+	DefaultTempFileValidity = "1 day"
+)
+
+// uploadScope holds the defaults a dedicated upload endpoint applies on top of the request;
+// the zero value changes nothing (plain /v2/files/upload)
+type uploadScope struct {
+	defaultValidity string   // used when the request has no validity
+	tags            []string // always added to the request tags
+}
+
+// validity returns the requested validity or the scope default
+func (sc uploadScope) validity(requested string) string {
+	if requested != "" {
+		return requested
+	}
+	return sc.defaultValidity
+}
+
+// withTags appends the scope tags missing from tags
+func (sc uploadScope) withTags(tags []string) []string {
+	for _, tag := range sc.tags {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// tempUploadScope is the scope of /v2/files/tmp uploads
+func (s *Server) tempUploadScope() uploadScope {
+	validity := s.TempFileValidity
+	if validity == "" {
+		validity = DefaultTempFileValidity
+	}
+	return uploadScope{defaultValidity: validity, tags: []string{TempFileTag}}
+}
+
+// HandleV2TempUpload uploads a temporary file
+// @Summary Upload a temporary file
+// @Description Uploads a file like /v2/files/upload, for transient content such as exports. Without validity the file expires after TEMP_FILE_VALIDITY (default '1 day'); the tag 'temporary' is always added. Accepts the same fields, batch uploads and If-None-Match as /v2/files/upload.
+// @Tags 02 - Files
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "File to upload"
+// @Param tags formData string false "Additional tags like array of string or coma separated strings"
+// @Param validity formData string false "Validity period (e.g. '1 day', '2 weeks'); default TEMP_FILE_VALIDITY"
+// @Param expires_at formData string false "Absolute expiry time (RFC 3339), instead of validity"
+// @Param old_cumulus_id formData int false "Legacy ID"
+// @Param disposition formData string false "Content-Disposition of downloads: 'inline' or 'attachment' (default: by MIME type)"
+// @Param If-None-Match header string false "Quoted BLAKE2b-256 hash of the content; if already stored and in scope (admin Basic auth, or tags a live file of the content carries), the body is not read"
+// @Param filename query string false "With If-None-Match: link the stored content under this filename"
+// @Param verbose query boolean false "Include hash, sizes, dedup flag, detected MIME type and expiry in the response"
+// @Success 201 {object} UploadResponse "File uploaded successfully, returns file UUID"
+// @Success 200 {object} UploadResponse "Content already stored, file linked without upload (If-None-Match + filename)"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 409 {object} ExistingBlobResponse "Content already stored (If-None-Match without filename)"
+// @Failure 413 {object} UploadTooLargeResponse "File larger than MAX_UPLOAD_FILE_SIZE, maxSize is the limit"
+// @Failure 422 {string} string "Upload rejected by the upload hook (UPLOAD_HOOK_URL), body is its reason"
+// @Failure 500 {string} string "Internal Server Error"
+// @Failure 503 {string} string "Upload hook unavailable (UPLOAD_HOOK_FAIL_OPEN off)"
+// @Router /v2/files/tmp [post]
+func (s *Server) HandleV2TempUpload(w http.ResponseWriter, r *http.Request) {
+	s.handleUpload(w, r, s.tempUploadScope())
+}
+
+// HandleV2TempFiles lists temporary files
+// @Summary List temporary files
+// @Description Lists unexpired files tagged 'temporary' (uploaded through POST /v2/files/tmp or tagged by hand), ordered by ID. Page through the list by passing next_after as ?after=.
+// @Tags 02 - Files
+// @Produce json
+// @Param after query string false "Return files with ID greater than this (next_after of the previous page)"
+// @Param limit query int false "Max number of files (default 100, max 1000)"
+// @Success 200 {object} FileListResponse
+// @Failure 400 {string} string "Invalid limit"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v2/files/tmp [get]
+func (s *Server) HandleV2TempFiles(w http.ResponseWriter, r *http.Request) {
+	s.writeTagFileList(w, r, TempFileTag)
+}
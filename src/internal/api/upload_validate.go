@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/pmalasek/cumulus3/src/internal/service"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// UploadValidationRequest describes a planned upload (POST /v2/files/validate)
+type UploadValidationRequest struct {
+	Name         string `json:"name" example:"invoice.pdf"`
+	Size         int64  `json:"size" example:"1048576"`
+	ContentType  string `json:"contentType,omitempty" example:"application/pdf"`
+	Hash         string `json:"hash,omitempty" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"` // BLAKE2b-256 hex
+	OldCumulusID *int64 `json:"oldCumulusId,omitempty" example:"123456"`
+	OnConflict   string `json:"onConflict,omitempty" example:"reject"`
+	Disposition  string `json:"disposition,omitempty" example:"inline"`
+	Validity     string `json:"validity,omitempty" example:"1 month"`
+	ExpiresAt    string `json:"expiresAt,omitempty" example:"2026-01-31T12:00:00Z"` // RFC 3339, instead of validity
+}
+
+// UploadValidationProblem is a reason the upload would be refused, with the status it would get
+type UploadValidationProblem struct {
+	Field   string `json:"field" example:"size"`
+	Status  int    `json:"status" example:"413"`
+	Message string `json:"message" example:"file is larger than the upload limit"`
+}
+
+// UploadValidationResponse says whether the upload would be accepted
+type UploadValidationResponse struct {
+	Accepted bool                      `json:"accepted"`
+	Problems []UploadValidationProblem `json:"problems"`
+	MaxSize  int64                     `json:"maxSize" example:"52428800"`
+	// Dedup: the content is already stored, an upload with If-None-Match: "<hash>" and
+	// ?filename= links it without sending the body (the size limit does not apply then)
+	Dedup    bool   `json:"dedup"`
+	MimeType string `json:"mimeType,omitempty" example:"application/pdf"`
+	Hint     string `json:"hint,omitempty"`
+}
+
+// HandleV2ValidateUpload checks a planned upload without sending its content
+// @Summary Validate an upload
+// @Description Returns whether an upload with the given name, size, content type, hash and upload options would be accepted, so clients can skip large uploads that would fail. Checks the upload size limit (413), the content_type, disposition, validity and expiresAt values (400) and an old_cumulus_id taken by other content with on_conflict=reject (409). With a hash of already stored content, dedup is true and the upload can be replaced by If-None-Match. Nothing is stored or reserved.
+// @Tags 02 - Files
+// @Accept json
+// @Produce json
+// @Param body body UploadValidationRequest true "Planned upload"
+// @Success 200 {object} UploadValidationResponse
+// @Failure 400 {string} string "Bad Request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v2/files/validate [post]
+func (s *Server) HandleV2ValidateUpload(w http.ResponseWriter, r *http.Request) {
+	var req UploadValidationRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	name := filepath.Base(req.Name)
+	if req.Name == "" || name == "." || name == ".." || name == "/" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if req.Size < 0 {
+		http.Error(w, "size must not be negative", http.StatusBadRequest)
+		return
+	}
+	hash := ""
+	if req.Hash != "" {
+		if hash = parseHashPrecondition(req.Hash); hash == "" {
+			http.Error(w, "hash must be a hex BLAKE2b-256 digest", http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp := UploadValidationResponse{Problems: []UploadValidationProblem{}, MaxSize: s.MaxUploadSize}
+	problem := func(field string, status int, format string, args ...any) {
+		resp.Problems = append(resp.Problems, UploadValidationProblem{Field: field, Status: status, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if hash != "" {
+		blob, err := s.FileService.FindCommittedBlob(hash)
+		switch {
+		case err == nil:
+			resp.Dedup = true
+			if fileType, err := s.FileService.MetaStore.GetFileType(blob.FileTypeID); err == nil {
+				resp.MimeType = fileType.MimeType
+			}
+		case !errors.Is(err, service.ErrNotFound):
+			utils.Error("UPLOAD_VALIDATE", "Blob lookup failed: hash=%s, error=%v", hash, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !resp.Dedup && req.Size > s.MaxUploadSize {
+		problem("size", http.StatusRequestEntityTooLarge, "file is larger than the upload limit of %d bytes", s.MaxUploadSize)
+	}
+
+	if req.ContentType != "" {
+		if _, err := parseContentTypeField(req.ContentType); err != nil {
+			problem("contentType", http.StatusBadRequest, "invalid content type: %v", err)
+		}
+	}
+	if _, err := service.ParseDisposition(req.Disposition); err != nil {
+		problem("disposition", http.StatusBadRequest, "%v", err)
+	}
+	if _, err := s.parseExpiry(req.Validity, req.ExpiresAt, uploadScope{}); err != nil {
+		field := "validity"
+		if req.ExpiresAt != "" {
+			field = "expiresAt"
+		}
+		problem(field, http.StatusBadRequest, "%v", err)
+	}
+	onConflict, err := service.ParseOldIDConflictMode(req.OnConflict)
+	if err != nil {
+		problem("onConflict", http.StatusBadRequest, "%v", err)
+	}
+	if req.OldCumulusID != nil && onConflict == service.OldIDConflictReject {
+		if err := s.FileService.CheckOldIDAvailable(*req.OldCumulusID, hash); err != nil {
+			if !errors.Is(err, service.ErrOldCumulusIDConflict) {
+				utils.Error("UPLOAD_VALIDATE", "Old ID check failed: old_id=%d, error=%v", *req.OldCumulusID, err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			problem("oldCumulusId", http.StatusConflict, "old_cumulus_id already assigned to a different file (use onConflict=supersede to move it)")
+		}
+	}
+
+	resp.Accepted = len(resp.Problems) == 0
+	if resp.Accepted && resp.Dedup {
+		resp.Hint = `content already stored: upload with If-None-Match: "<hash>" and ?filename=<name> to skip sending it`
+	}
+	utils.Info("UPLOAD_VALIDATE", "name=%s, size=%d, hash=%s, accepted=%v, dedup=%v, problems=%d, remote=%s",
+		name, req.Size, hash, resp.Accepted, resp.Dedup, len(resp.Problems), r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// anonymousClient is the usage label for requests without an identified client
+const anonymousClient = "anonymous"
+
+type usageCtxKey struct{}
+
+// requestUsage is attached to each request context by MetricsMiddleware.
+// Authentication middleware records the client identity into it via setRequestClient.
+type requestUsage struct {
+	mu      sync.Mutex
+	client  string
+	uploads []string // MIME types of the files stored by the request (activity.go)
+}
+
+// setRequestClient records the identity (e.g. API key name) used for usage accounting.
+func setRequestClient(r *http.Request, client string) {
+	if u, ok := r.Context().Value(usageCtxKey{}).(*requestUsage); ok {
+		u.mu.Lock()
+		u.client = client
+		u.mu.Unlock()
+	}
+}
+
+// recordUploadedFile records a file stored by the request for the activity report
+func recordUploadedFile(r *http.Request, mimeType string) {
+	if u, ok := r.Context().Value(usageCtxKey{}).(*requestUsage); ok {
+		u.mu.Lock()
+		u.uploads = append(u.uploads, mimeType)
+		u.mu.Unlock()
+	}
+}
+
+func withRequestUsage(r *http.Request) (*http.Request, *requestUsage) {
+	u := &requestUsage{client: anonymousClient}
+	return r.WithContext(context.WithValue(r.Context(), usageCtxKey{}, u)), u
+}
+
+func (u *requestUsage) clientName() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.client
+}
+
+func (u *requestUsage) uploadedFiles() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.uploads
+}
+
+// countingReadCloser counts bytes read from the request body
+type countingReadCloser struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// UsageEntry is the ingress accounting of one endpoint and client
+type UsageEntry struct {
+	Path         string `json:"path" example:"/v2/files/upload"`
+	Client       string `json:"client" example:"anonymous"`
+	Requests     int64  `json:"requests" example:"1200"`
+	IngressBytes int64  `json:"ingressBytes" example:"734003200"`
+}
+
+const (
+	maxUsagePaths   = 200         // further paths (e.g. labels of by-label lookups) are counted as "other"
+	usageUnmatched  = "unmatched" // requests no route matched (random 404 paths)
+	usageOtherPaths = "other"
+)
+
+type usageKey struct {
+	path   string
+	client string
+}
+
+// usageTracker aggregates ingress bytes since process start
+type usageTracker struct {
+	mu      sync.Mutex
+	since   time.Time
+	paths   map[string]bool
+	entries map[usageKey]*UsageEntry
+}
+
+var globalUsage = &usageTracker{
+	since:   time.Now(),
+	paths:   make(map[string]bool),
+	entries: make(map[usageKey]*UsageEntry),
+}
+
+// add accounts the request and returns the path it was counted under: "other" once maxUsagePaths
+// paths are tracked
+func (t *usageTracker) add(path, client string, bytes int64) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.paths[path] {
+		if len(t.paths) >= maxUsagePaths {
+			path = usageOtherPaths
+		} else {
+			t.paths[path] = true
+		}
+	}
+	key := usageKey{path: path, client: client}
+	e, ok := t.entries[key]
+	if !ok {
+		e = &UsageEntry{Path: path, Client: client}
+		t.entries[key] = e
+	}
+	e.Requests++
+	e.IngressBytes += bytes
+	return path
+}
+
+func (t *usageTracker) snapshot() []UsageEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]UsageEntry, 0, len(t.entries))
+	for _, e := range t.entries {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].IngressBytes != list[j].IngressBytes {
+			return list[i].IngressBytes > list[j].IngressBytes
+		}
+		return list[i].Path < list[j].Path
+	})
+	return list
+}
+
+// recordRequestIngress accounts the request body bytes to the usage tracker and Prometheus, under
+// the same bounded set of paths
+func recordRequestIngress(path, client string, bytes int64) {
+	path = globalUsage.add(path, client, bytes)
+	httpRequestBodyBytes.WithLabelValues(path, client).Add(float64(bytes))
+}
+
+// HandleSystemUsage returns ingress bytes per endpoint and client
+// @Summary Get ingress usage
+// @Description Returns request body bytes and request counts per endpoint and client since server start (for chargeback). Use ?client= to filter.
+// @Tags 04 - System
+// @Produce json
+// @Param client query string false "Client (API key name) filter"
+// @Success 200 {object} map[string]interface{}
+// @Router /system/usage [get]
+func (s *Server) HandleSystemUsage(w http.ResponseWriter, r *http.Request) {
+	clientFilter := r.URL.Query().Get("client")
+	entries := make([]UsageEntry, 0)
+	totals := make(map[string]int64)
+	var totalBytes int64
+	for _, e := range globalUsage.snapshot() {
+		if clientFilter != "" && e.Client != clientFilter {
+			continue
+		}
+		entries = append(entries, e)
+		totals[e.Client] += e.IngressBytes
+		totalBytes += e.IngressBytes
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"since":             globalUsage.since.UTC().Format(time.RFC3339),
+		"totalIngressBytes": totalBytes,
+		"clients":           totals,
+		"endpoints":         entries,
+	})
+}
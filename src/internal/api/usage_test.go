@@ -0,0 +1,45 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUsageTrackerPathLimit(t *testing.T) {
+	tracker := &usageTracker{since: time.Now(), paths: make(map[string]bool), entries: make(map[usageKey]*UsageEntry)}
+	for i := range maxUsagePaths {
+		if got := tracker.add(fmt.Sprintf("/v2/files/old/by-label/l%d", i), anonymousClient, 1); got == usageOtherPaths {
+			t.Fatalf("path %d counted as other below the limit", i)
+		}
+	}
+	if got := tracker.add("/v2/files/old/by-label/more", anonymousClient, 1); got != usageOtherPaths {
+		t.Errorf("path over the limit counted as %q, want %q", got, usageOtherPaths)
+	}
+	if got := tracker.add("/v2/files/old/by-label/l0", "billing", 1); got != "/v2/files/old/by-label/l0" {
+		t.Errorf("tracked path for another client counted as %q", got)
+	}
+	if n := len(tracker.snapshot()); n != maxUsagePaths+2 {
+		t.Errorf("%d usage entries, want %d", n, maxUsagePaths+2)
+	}
+}
+
+func TestUsageUnmatchedPath(t *testing.T) {
+	h := newTestRoutes(t, "")
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/no/such/route-x7", nil))
+
+	for _, e := range globalUsage.snapshot() {
+		if e.Path == "/no/such/route-x7" {
+			t.Fatalf("unmatched request tracked under its path")
+		}
+	}
+	found := false
+	for _, e := range globalUsage.snapshot() {
+		found = found || e.Path == usageUnmatched
+	}
+	if !found {
+		t.Errorf("unmatched request not tracked under %q", usageUnmatched)
+	}
+}
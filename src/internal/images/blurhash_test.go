@@ -0,0 +1,42 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestEncodeBlurHash(t *testing.T) {
+	solid := image.NewRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			solid.Set(x, y, color.RGBA{R: 0x33, G: 0x66, B: 0x99, A: 0xFF})
+		}
+	}
+	hash, avg := EncodeBlurHash(solid, BlurHashComponentsX, BlurHashComponentsY)
+	if avg != "#336699" {
+		t.Errorf("average color = %s, want #336699", avg)
+	}
+	// 4×3 komponent → 'L', znaky 2–5 nesou DC složku (průměrnou barvu)
+	if len(hash) != 28 || hash[0] != 'L' || hash[2:6] != encode83(0x336699, 4) {
+		t.Errorf("blurhash = %s, want 28 chars with DC %s", hash, encode83(0x336699, 4))
+	}
+	solidHash := hash
+
+	gradient := image.NewGray(image.Rect(0, 0, 30, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 30; x++ {
+			gradient.SetGray(x, y, color.Gray{Y: uint8(x * 8)})
+		}
+	}
+	hash, _ = EncodeBlurHash(gradient, BlurHashComponentsX, BlurHashComponentsY)
+	if len(hash) != 28 || hash == solidHash {
+		t.Errorf("gradient blurhash = %q", hash)
+	}
+	for _, c := range hash {
+		if !strings.ContainsRune(base83Chars, c) {
+			t.Fatalf("blurhash %q contains %q outside base83", hash, c)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Ochrana proti DoS: limity vstupu se kontrolují před dekódováním (rozměry z hlavičky),
+// zpracování běží s časovým limitem (IMAGE_MAX_INPUT_SIZE, IMAGE_MAX_PIXELS, IMAGE_PROCESS_TIMEOUT)
+var (
+	MaxImageInputSize   int64 = 50 << 20   // bajtů
+	MaxImagePixels      int64 = 50_000_000 // šířka × výška
+	ImageProcessTimeout       = 30 * time.Second
+)
+
+var (
+	// ErrImageTooLarge je vrácena, když vstup překročí MaxImageInputSize nebo MaxImagePixels.
+	ErrImageTooLarge = errors.New("image too large")
+	// ErrProcessingTimeout je vrácena, když zpracování nestihne časový limit.
+	ErrProcessingTimeout = errors.New("image processing timed out")
+)
+
+// checkInputSize odmítne příliš velký vstup ještě před předáním libvips
+func checkInputSize(data []byte) error {
+	if MaxImageInputSize > 0 && int64(len(data)) > MaxImageInputSize {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrImageTooLarge, len(data), MaxImageInputSize)
+	}
+	return nil
+}
+
+// checkPixels odmítne obrázek podle rozměrů z hlavičky, dřív než se dekóduje
+func checkPixels(width, height int) error {
+	if MaxImagePixels > 0 && int64(width)*int64(height) > MaxImagePixels {
+		return fmt.Errorf("%w: %dx%d pixels, limit %d", ErrImageTooLarge, width, height, MaxImagePixels)
+	}
+	return nil
+}
+
+// withTimeout spustí zpracování s ImageProcessTimeout. libvips nelze přerušit, po vypršení
+// limitu se požadavek vrátí hned a výsledek doběhnutého zpracování se zahodí.
+func withTimeout(fn func() ([]byte, error)) ([]byte, error) {
+	if ImageProcessTimeout <= 0 {
+		return fn()
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := fn()
+		done <- result{data, err}
+	}()
+
+	timer := time.NewTimer(ImageProcessTimeout)
+	defer timer.Stop()
+	select {
+	case res := <-done:
+		return res.data, res.err
+	case <-timer.C:
+		return nil, fmt.Errorf("%w after %s", ErrProcessingTimeout, ImageProcessTimeout)
+	}
+}
@@ -0,0 +1,27 @@
+package images
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestImageLimits(t *testing.T) {
+	if err := checkPixels(30000, 30000); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("checkPixels(30000, 30000) = %v, want ErrImageTooLarge", err)
+	}
+	if err := checkPixels(4000, 3000); err != nil {
+		t.Errorf("checkPixels(4000, 3000) = %v, want nil", err)
+	}
+
+	saved := ImageProcessTimeout
+	defer func() { ImageProcessTimeout = saved }()
+	ImageProcessTimeout = 10 * time.Millisecond
+	_, err := withTimeout(func() ([]byte, error) {
+		time.Sleep(200 * time.Millisecond)
+		return nil, nil
+	})
+	if !errors.Is(err, ErrProcessingTimeout) {
+		t.Errorf("withTimeout() = %v, want ErrProcessingTimeout", err)
+	}
+}
@@ -2,17 +2,57 @@ package images
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/h2non/bimg"
 )
 
+// ErrPdftoppm je vrácena, když selže externí pdftoppm (chybějící binárka, poškozené PDF, ...).
+var ErrPdftoppm = errors.New("pdftoppm failed")
+
+// ErrPDFPage je vrácena, když požadovaná stránka v PDF není.
+var ErrPDFPage = errors.New("page out of range")
+
+// Rozsah ?dpi= pro náhledy PDF
+const (
+	MinPDFDPI = 36
+	MaxPDFDPI = 300
+)
+
+// Limity pdftoppm, aby škodlivé PDF nezablokovalo worker (PDF_RENDER_TIMEOUT, PDF_RENDER_MAX_MEMORY)
+var (
+	PDFRenderTimeout         = 30 * time.Second
+	PDFRenderMaxMemory int64 = 512 << 20 // virtuální paměť procesu, 0 = bez limitu
+)
+
+// PDFOptions řídí render stránky PDF; nulové hodnoty = první stránka, rozlišení podle velikosti náhledu
+type PDFOptions struct {
+	Page int // číslováno od 1
+	DPI  int
+}
+
 // GeneratePDFThumbnail vygeneruje náhled první stránky PDF jako JPEG.
-// pdftoppm vyrenderuje stránku jako PNG, bimg ji přeškáluje stejnou cestou jako obrázky.
 func GeneratePDFThumbnail(pdfData []byte, size ImageSize) ([]byte, error) {
+	return GeneratePDFPreview(pdfData, size, PDFOptions{})
+}
+
+// GeneratePDFPreview vygeneruje náhled stránky PDF jako JPEG.
+// pdftoppm vyrenderuje stránku jako PNG, bimg ji přeškáluje stejnou cestou jako obrázky.
+// Limity jsou stejné jako u obrázků (MaxImageInputSize pro PDF, MaxImagePixels pro vyrenderovanou stránku).
+func GeneratePDFPreview(pdfData []byte, size ImageSize, opts PDFOptions) ([]byte, error) {
+	if err := checkInputSize(pdfData); err != nil {
+		return nil, err
+	}
+	page := max(opts.Page, 1)
+
 	tmpDir, err := os.MkdirTemp("", "pdf-thumb-*")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp dir: %w", err)
@@ -29,21 +69,17 @@ func GeneratePDFThumbnail(pdfData []byte, size ImageSize) ([]byte, error) {
 		maxDim = size.Height
 	}
 
-	// pdftoppm renders the first page to <tmpDir>/output.png
-	cmd := exec.Command("pdftoppm",
-		"-png",
-		"-f", "1",
-		"-l", "1",
-		"-singlefile",
-		"-scale-to", fmt.Sprintf("%d", maxDim*2), // 2× for better quality before downscale
-		pdfPath,
-		filepath.Join(tmpDir, "output"),
-	)
+	// pdftoppm renders the page to <tmpDir>/output.png
+	args := []string{"-png", "-f", strconv.Itoa(page), "-l", strconv.Itoa(page), "-singlefile"}
+	if opts.DPI > 0 {
+		args = append(args, "-r", strconv.Itoa(opts.DPI))
+	} else {
+		args = append(args, "-scale-to", strconv.Itoa(maxDim*2)) // 2× for better quality before downscale
+	}
+	args = append(args, pdfPath, filepath.Join(tmpDir, "output"))
 
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("pdftoppm failed: %w, stderr: %s", err, stderr.String())
+	if err := runPdftoppm(args); err != nil {
+		return nil, err
 	}
 
 	imgData, err := os.ReadFile(filepath.Join(tmpDir, "output.png"))
@@ -56,6 +92,39 @@ func GeneratePDFThumbnail(pdfData []byte, size ImageSize) ([]byte, error) {
 	return resizeToPNG(imgData, size)
 }
 
+// runPdftoppm spustí pdftoppm s časovým limitem a s limitem paměti (ulimit -v přes sh).
+// Po vypršení timeoutu je proces zabit.
+func runPdftoppm(args []string) error {
+	ctx := context.Background()
+	if PDFRenderTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, PDFRenderTimeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	if PDFRenderMaxMemory > 0 {
+		script := fmt.Sprintf(`ulimit -v %d && exec pdftoppm "$@"`, PDFRenderMaxMemory/1024)
+		cmd = exec.CommandContext(ctx, "sh", append([]string{"-c", script, "pdftoppm"}, args...)...)
+	} else {
+		cmd = exec.CommandContext(ctx, "pdftoppm", args...)
+	}
+	cmd.WaitDelay = time.Second
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("%w: %w after %s", ErrPdftoppm, ErrProcessingTimeout, PDFRenderTimeout)
+	case strings.Contains(stderr.String(), "Wrong page range"):
+		return fmt.Errorf("%w: %s", ErrPDFPage, strings.TrimSpace(stderr.String()))
+	}
+	return fmt.Errorf("%w: %v, stderr: %s", ErrPdftoppm, err, stderr.String())
+}
+
 // resizeToPNG applies aspect-ratio-preserving resize via bimg and returns JPEG bytes.
 func resizeToPNG(imgData []byte, size ImageSize) ([]byte, error) {
 	img := bimg.NewImage(imgData)
@@ -64,6 +133,9 @@ func resizeToPNG(imgData []byte, size ImageSize) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read image metadata: %w", err)
 	}
+	if err := checkPixels(metadata.Size.Width, metadata.Size.Height); err != nil {
+		return nil, err
+	}
 
 	newWidth, newHeight := calculateAspectRatioFit(
 		metadata.Size.Width, metadata.Size.Height,
@@ -79,7 +151,10 @@ func resizeToPNG(imgData []byte, size ImageSize) ([]byte, error) {
 		Enlarge: false,
 	}
 
-	result, err := img.Process(options)
+	result, err := withTimeout(func() ([]byte, error) { return img.Process(options) })
+	if errors.Is(err, ErrProcessingTimeout) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to resize PDF thumbnail: %w", err)
 	}
@@ -0,0 +1,54 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/png"
+
+	"github.com/h2non/bimg"
+)
+
+// placeholderSize je velikost zmenšeniny, ze které se počítá BlurHash – víc detailů
+// 4×3 komponent stejně nezachytí
+var placeholderSize = ImageSize{Width: 32, Height: 32}
+
+// Placeholder vrátí BlurHash a průměrnou barvu ("#rrggbb") obrázku pro progresivní načítání.
+// Obrázek se nejdřív zmenší libvips (včetně otočení podle EXIF), platí stejné limity jako u ResizeImage.
+func Placeholder(data []byte) (blurhash, color string, err error) {
+	if err := checkInputSize(data); err != nil {
+		return "", "", err
+	}
+
+	img := bimg.NewImage(data)
+	metadata, err := img.Metadata()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read image metadata: %w", err)
+	}
+	if err := checkPixels(metadata.Size.Width, metadata.Size.Height); err != nil {
+		return "", "", err
+	}
+
+	width, height := calculateAspectRatioFit(metadata.Size.Width, metadata.Size.Height, placeholderSize.Width, placeholderSize.Height)
+	options := bimg.Options{
+		Width:  max(width, 1),
+		Height: max(height, 1),
+		Force:  true,
+		Type:   bimg.PNG,
+	}
+	small, err := withTimeout(func() ([]byte, error) { return img.Process(options) })
+	if errors.Is(err, ErrProcessingTimeout) {
+		return "", "", err
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resize image for placeholder: %w", err)
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(small))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode placeholder image: %w", err)
+	}
+	blurhash, color = EncodeBlurHash(decoded, BlurHashComponentsX, BlurHashComponentsY)
+	return blurhash, color, nil
+}
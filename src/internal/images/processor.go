@@ -1,6 +1,7 @@
 package images
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -23,15 +24,23 @@ var (
 // ResizeImage změní velikost obrázku při zachování aspect ratio pomocí libvips
 // Obrázek se vejde do zadaného rozměru (fit inside) - nikdy se nenatahuje nebo neořezává
 // Výsledný obrázek může být menší než zadaná velikost, pokud má jiný aspect ratio
+// Příliš velký vstup vrací ErrImageTooLarge, překročení ImageProcessTimeout ErrProcessingTimeout.
 func ResizeImage(data []byte, mimeType string, size ImageSize) ([]byte, error) {
+	if err := checkInputSize(data); err != nil {
+		return nil, err
+	}
+
 	// Vytvoření bimg image
 	image := bimg.NewImage(data)
 
-	// Získání metadat (rozměry, formát)
+	// Získání metadat (rozměry, formát) – libvips čte jen hlavičku
 	metadata, err := image.Metadata()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read image metadata: %w", err)
 	}
+	if err := checkPixels(metadata.Size.Width, metadata.Size.Height); err != nil {
+		return nil, err
+	}
 
 	// Kontrola, zda je potřeba resize (nesnažíme se zvětšovat)
 	if metadata.Size.Width <= size.Width && metadata.Size.Height <= size.Height {
@@ -77,7 +86,10 @@ func ResizeImage(data []byte, mimeType string, size ImageSize) ([]byte, error) {
 	}
 
 	// Provedení resize
-	resized, err := image.Process(options)
+	resized, err := withTimeout(func() ([]byte, error) { return image.Process(options) })
+	if errors.Is(err, ErrProcessingTimeout) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to resize image: %w", err)
 	}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"archive/tar"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+)
+
+// archivePageSize is the number of file records loaded at once while writing an archive
+const archivePageSize = 500
+
+// ArchiveResult summarizes a written archive
+type ArchiveResult struct {
+	Files int
+	Bytes int64 // uncompressed content bytes
+}
+
+// WriteTagArchive writes all unexpired files carrying tag into w as a tar archive. File records
+// are loaded page by page and blobs are streamed from the volumes, so memory use doesn't depend
+// on the number or size of the files. Entries are named "<file ID>_<name>" to stay unique.
+//
+// An error after the first entry leaves w with a truncated archive (without the end-of-archive
+// marker), which tar readers report as unexpected EOF.
+func (s *FileService) WriteTagArchive(w io.Writer, tag string) (ArchiveResult, error) {
+	var result ArchiveResult
+	tw := tar.NewWriter(w)
+
+	afterID := ""
+	for {
+		files, err := s.MetaStore.ListFilesByTag(tag, afterID, archivePageSize)
+		if err != nil {
+			return result, fmt.Errorf("list files: %w", err)
+		}
+		for _, file := range files {
+			n, err := s.writeArchiveEntry(tw, file, archiveEntryName(file))
+			if err != nil {
+				return result, fmt.Errorf("file %s: %w", file.ID, err)
+			}
+			result.Files++
+			result.Bytes += n
+		}
+		if len(files) < archivePageSize {
+			break
+		}
+		afterID = files[len(files)-1].ID
+	}
+
+	return result, tw.Close()
+}
+
+// writeArchiveEntry streams one file into the archive under name and checks the blob CRC on the way
+func (s *FileService) writeArchiveEntry(tw *tar.Writer, file storage.File, name string) (int64, error) {
+	blob, err := s.MetaStore.GetBlob(file.BlobID)
+	if err != nil {
+		return 0, fmt.Errorf("blob not found: %w", err)
+	}
+	section, err := s.Store.OpenBlobSection(blob.VolumeID, blob.Offset, blob.SizeCompressed)
+	if err != nil {
+		return 0, err
+	}
+	defer section.Close()
+
+	crc := crc32.NewIEEE()
+	rc, err := decompressReader(io.TeeReader(section.Data, crc), blob.CompressionAlg)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     blob.SizeRaw,
+		Mode:     0644,
+		ModTime:  file.CreatedAt,
+		Format:   tar.FormatPAX, // non-ASCII names
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tw, rc)
+	if err != nil {
+		return n, err
+	}
+	if n != blob.SizeRaw {
+		return n, fmt.Errorf("decompressed size %d, metadata says %d", n, blob.SizeRaw)
+	}
+	// CRC covers the whole stored blob, read what the decompressor left
+	if _, err := io.Copy(io.Discard, io.TeeReader(section.Data, crc)); err != nil {
+		return n, err
+	}
+	if actual := crc.Sum32(); actual != section.FooterCRC {
+		return n, fmt.Errorf("CRC mismatch: 0x%08X, footer says 0x%08X", actual, section.FooterCRC)
+	}
+	return n, nil
+}
+
+// archiveEntryName returns a flat, unique entry name for the file
+func archiveEntryName(file storage.File) string {
+	name := path.Base(strings.ReplaceAll(file.Name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		name = "file"
+	}
+	return file.ID + "_" + name
+}
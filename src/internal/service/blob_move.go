@@ -0,0 +1,189 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// drainBatchSize is the number of blobs loaded per query by DrainVolume
+const drainBatchSize = 200
+
+// drainErrorsKept limits the errors listed in a VolumeDrainSummary
+const drainErrorsKept = 20
+
+// VolumeDrainSummary is the result of DrainVolume
+type VolumeDrainSummary struct {
+	VolumeID   int64    `json:"volumeId"`
+	ToVolumeID int64    `json:"toVolumeId,omitempty"` // 0 = any open volume with space
+	Sealed     bool     `json:"sealed"`               // the volume was open and got sealed by the drain
+	Scanned    int64    `json:"scanned"`
+	Moved      int64    `json:"moved"`
+	BytesMoved int64    `json:"bytesMoved"` // stored (compressed) size
+	Skipped    int64    `json:"skipped"`    // deleted or moved concurrently
+	Failed     int64    `json:"failed"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// MoveBlob copies a committed blob unchanged into the given volume, like MoveFileBlob but
+// addressed by blob ID (e.g. a blob reported by /system/blobs/{id}/verify on a failing disk).
+func (s *FileService) MoveBlob(blobID, volumeID int64) (*BlobRewriteResult, error) {
+	blob, err := s.MetaStore.GetBlob(blobID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: blob_id=%d", ErrNotFound, blobID)
+		}
+		return nil, err
+	}
+	if blob.VolumeID == 0 {
+		return nil, fmt.Errorf("blob %d has no location (state %s)", blob.ID, blob.State)
+	}
+	claimed, err := s.MetaStore.ClaimBlobRef(blob.ID)
+	if err != nil {
+		return nil, fmt.Errorf("database error claiming blob: %w", err)
+	}
+	if !claimed {
+		return nil, fmt.Errorf("%w: blob_id=%d", ErrNotFound, blob.ID)
+	}
+	defer s.releaseBlobRef(blob.ID)
+	return s.moveClaimedBlob("", blob, volumeID)
+}
+
+// DrainVolume moves all committed blobs out of a volume, e.g. off a failing disk, without
+// compacting other volumes. An open volume is sealed first so it gets no new blobs; it stays
+// sealed afterwards and compaction then removes it. toVolumeID 0 spreads the blobs over open
+// volumes with space (a new volume is created when none has space). A blob that fails is
+// counted and the drain goes on; a full or closed explicit target stops it. progress is called
+// after each batch.
+func (s *FileService) DrainVolume(volumeID, toVolumeID int64, progress func(VolumeDrainSummary)) (VolumeDrainSummary, error) {
+	summary := VolumeDrainSummary{VolumeID: volumeID, ToVolumeID: toVolumeID}
+	if volumeID == toVolumeID {
+		return summary, fmt.Errorf("%w: volume %d", ErrBlobAlreadyInVolume, volumeID)
+	}
+	if _, err := s.Store.VolumePath(volumeID); err != nil {
+		return summary, fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	if toVolumeID > 0 {
+		if _, err := s.Store.VolumePath(toVolumeID); err != nil {
+			return summary, fmt.Errorf("%w: target %v", ErrNotFound, err)
+		}
+	}
+	state, err := s.MetaStore.GetVolumeState(volumeID)
+	if err != nil {
+		return summary, err
+	}
+	switch state {
+	case storage.VolumeStateOpen:
+		if err := s.MetaStore.SetVolumeState(volumeID, storage.VolumeStateSealed); err != nil {
+			return summary, fmt.Errorf("failed to seal volume %d: %w", volumeID, err)
+		}
+		summary.Sealed = true
+		utils.Info("ADMIN", "Volume %d sealed for draining", volumeID)
+	case storage.VolumeStateCompacting, storage.VolumeStateMissing:
+		return summary, fmt.Errorf("%w: volume %d is %s", storage.ErrVolumeNotWritable, volumeID, state)
+	}
+
+	afterID := int64(0)
+	for {
+		blobs, err := s.MetaStore.ListVolumeBlobs(volumeID, afterID, drainBatchSize)
+		if err != nil {
+			return summary, err
+		}
+		if len(blobs) == 0 {
+			return summary, nil
+		}
+
+		for _, blob := range blobs {
+			afterID = blob.ID
+			summary.Scanned++
+			claimed, err := s.MetaStore.ClaimBlobRef(blob.ID)
+			if err != nil {
+				return summary, fmt.Errorf("database error claiming blob: %w", err)
+			}
+			if !claimed {
+				summary.Skipped++
+				continue
+			}
+			_, err = s.moveClaimedBlob("", blob, toVolumeID)
+			s.releaseBlobRef(blob.ID)
+			switch {
+			case err == nil:
+				summary.Moved++
+				summary.BytesMoved += blob.SizeCompressed
+			case errors.Is(err, storage.ErrBlobMoved):
+				summary.Skipped++
+			case toVolumeID > 0 && (errors.Is(err, storage.ErrVolumeFull) || errors.Is(err, storage.ErrVolumeNotWritable)):
+				return summary, err
+			default:
+				summary.Failed++
+				if len(summary.Errors) < drainErrorsKept {
+					summary.Errors = append(summary.Errors, fmt.Sprintf("blob %d: %v", blob.ID, err))
+				}
+				utils.Warn("ADMIN", "Failed to move blob out of volume %d: blob_id=%d, error=%v", volumeID, blob.ID, err)
+			}
+		}
+		if progress != nil {
+			progress(summary)
+		}
+	}
+}
+
+// moveClaimedBlob copies the stored blob unchanged into the volume (0 = any open volume with
+// space). The source is verified against its CRC footer first, the copy against the source CRC;
+// the old copy becomes deleted space.
+func (s *FileService) moveClaimedBlob(fileID string, blob storage.Blob, volumeID int64) (*BlobRewriteResult, error) {
+	if blob.VolumeID == volumeID {
+		return nil, fmt.Errorf("%w: volume %d", ErrBlobAlreadyInVolume, volumeID)
+	}
+
+	// Přes dočasný soubor: zdrojový volume nesmí zůstat zamčený při zápisu do cílového
+	tmp, err := os.CreateTemp("", "move-blob-*")
+	if err != nil {
+		return nil, fmt.Errorf("internal error creating temp file: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	crc, err := s.copyStoredBlob(blob, tmp)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	alg := format.CompressionCode(blob.CompressionAlg)
+	var offset int64
+	var written uint32
+	if volumeID > 0 {
+		offset, written, err = s.Store.WriteBlobToVolume(volumeID, blob.ID, tmp, blob.SizeCompressed, alg, s.MetaStore)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		volumeID, offset, _, err = s.Store.WriteBlobWithMetadata(blob.ID, tmp, blob.SizeCompressed, alg, s.MetaStore)
+		if err != nil {
+			return nil, fmt.Errorf("storage error: %w", err)
+		}
+		section, err := s.Store.OpenBlobSection(volumeID, offset, blob.SizeCompressed)
+		if err != nil {
+			return nil, err
+		}
+		written = section.FooterCRC
+		section.Close()
+	}
+	if written != crc {
+		if derr := s.MetaStore.IncrementDeletedSize(volumeID, format.BlobTotalSize(blob.SizeCompressed)); derr != nil {
+			utils.Warn("ADMIN", "Failed to account unused blob copy: volume=%d, offset=%d, error=%v", volumeID, offset, derr)
+		}
+		return nil, fmt.Errorf("CRC of the written copy 0x%08X differs from the source 0x%08X", written, crc)
+	}
+	return s.finishRewrite(fileID, blob, volumeID, offset, blob.SizeCompressed, blob.CompressionAlg)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+)
+
+// blobStream is the decompressed content of a blob read straight from its volume file. It is an
+// io.ReadSeeker over the raw content, so downloads can answer Range requests: an uncompressed
+// blob is read from the requested offset, a compressed one is decompressed from the start and
+// the bytes before the offset are skipped. The CRC is checked when the content is read from the
+// start (see storage.BlobSection.VerifiedData); reads from other offsets can't be verified.
+type blobStream struct {
+	section      *storage.BlobSection
+	alg          string
+	size         int64 // raw (decompressed) size
+	r            io.Reader
+	decompressor io.Closer
+	pos          int64 // offset of r in the raw content
+	want         int64 // offset requested by Seek, reached on the next Read
+}
+
+// openBlobStream opens the blob in its volume and returns a reader of the decompressed content.
+// The volume lock is released once the blob is opened (see storage.BlobSection.Detach), so a slow
+// client doesn't hold off compaction of the volume; the stream keeps reading the file it opened.
+func (s *FileService) openBlobStream(blob storage.Blob) (*blobStream, error) {
+	section, err := s.Store.OpenBlobSection(blob.VolumeID, blob.Offset, blob.SizeCompressed)
+	if err != nil {
+		return nil, err
+	}
+	if section.Header.Size != blob.SizeCompressed {
+		section.Close()
+		return nil, fmt.Errorf("size mismatch at offset %d: header says %d, metadata says %d (blobID: %d)",
+			blob.Offset, section.Header.Size, blob.SizeCompressed, blob.ID)
+	}
+	section.Detach()
+	b := &blobStream{section: section, alg: blob.CompressionAlg, size: blob.SizeRaw}
+	if err := b.rewind(); err != nil {
+		section.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// compressed reports whether the raw content has to be decompressed from the start
+func (b *blobStream) compressed() bool {
+	return b.alg != "none" && b.alg != ""
+}
+
+// rewind starts reading the content from the beginning with CRC verification
+func (b *blobStream) rewind() error {
+	if b.decompressor != nil {
+		b.decompressor.Close()
+		b.decompressor = nil
+	}
+	if _, err := b.section.Data.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	rc, err := decompressReader(b.section.VerifiedData(), b.alg)
+	if err != nil {
+		return err
+	}
+	b.r, b.decompressor, b.pos = rc, rc, 0
+	return nil
+}
+
+// moveTo positions the reader at off of the raw content
+func (b *blobStream) moveTo(off int64) error {
+	if off == 0 || (b.compressed() && off < b.pos) {
+		if err := b.rewind(); err != nil {
+			return err
+		}
+	}
+	if off == b.pos {
+		return nil
+	}
+	if !b.compressed() {
+		if _, err := b.section.Data.Seek(off, io.SeekStart); err != nil {
+			return err
+		}
+		b.r, b.pos = b.section.Data, off
+		return nil
+	}
+	n, err := io.CopyN(io.Discard, b.r, off-b.pos)
+	b.pos += n
+	if errors.Is(err, io.EOF) {
+		return nil // past the end, the next Read returns EOF
+	}
+	return err
+}
+
+func (b *blobStream) Read(p []byte) (int, error) {
+	if b.want != b.pos {
+		if err := b.moveTo(b.want); err != nil {
+			return 0, err
+		}
+	}
+	n, err := b.r.Read(p)
+	b.pos += int64(n)
+	b.want = b.pos
+	return n, err
+}
+
+// Seek only records the offset, the stream moves there on the next Read. Seeking to the end
+// therefore reads nothing, which is how http.ServeContent learns the size.
+func (b *blobStream) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.want
+	case io.SeekEnd:
+		offset += b.size
+	default:
+		return 0, errors.New("blobStream.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("blobStream.Seek: negative position")
+	}
+	b.want = offset
+	return offset, nil
+}
+
+// Close releases the decompressor and the volume file
+func (b *blobStream) Close() error {
+	if b.decompressor != nil {
+		b.decompressor.Close()
+	}
+	return b.section.Close()
+}
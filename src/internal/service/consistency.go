@@ -0,0 +1,67 @@
+package service
+
+import (
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+)
+
+// Overall status of a consistency report
+const (
+	ReportStatusOK      = "ok"
+	ReportStatusWarning = "warning" // garbage that cleanup or compaction will remove
+	ReportStatusError   = "error"   // data is missing or damaged
+)
+
+// ConsistencyIntegrity holds the DB-only integrity counts of a report
+type ConsistencyIntegrity struct {
+	OrphanedBlobs int64 `json:"orphanedBlobs"` // blobs no file references (zombies included)
+	MissingBlobs  int64 `json:"missingBlobs"`  // files referencing a non-existent blob
+	ZombieBlobs   int64 `json:"zombieBlobs"`   // pending blobs older than the pending max age
+}
+
+// ConsistencyReport is a health snapshot of the metadata and the volume files
+type ConsistencyReport struct {
+	CreatedAt  time.Time                    `json:"createdAt"`
+	DurationMs int64                        `json:"durationMs"`
+	Status     string                       `json:"status"`
+	Integrity  ConsistencyIntegrity         `json:"integrity"`
+	Volumes    storage.VolumeReconciliation `json:"volumes"`
+	Errors     []string                     `json:"errors,omitempty"` // checks that could not run
+}
+
+// BuildConsistencyReport runs the quick integrity check, the volume reconciliation and the
+// zombie blob count. A failing check is recorded in Errors and makes the status "error";
+// the remaining checks still run.
+func (s *FileService) BuildConsistencyReport(pendingMaxAge time.Duration) *ConsistencyReport {
+	start := time.Now()
+	report := &ConsistencyReport{CreatedAt: start, Status: ReportStatusOK}
+
+	if quick, err := s.MetaStore.GetIntegrityQuick(); err != nil {
+		report.Errors = append(report.Errors, "integrity: "+err.Error())
+	} else {
+		report.Integrity.OrphanedBlobs = quick.OrphanedBlobs
+		report.Integrity.MissingBlobs = quick.MissingBlobs
+	}
+	if zombies, err := s.MetaStore.CountStalePendingBlobs(pendingMaxAge); err != nil {
+		report.Errors = append(report.Errors, "zombie blobs: "+err.Error())
+	} else {
+		report.Integrity.ZombieBlobs = zombies
+	}
+	volumes, err := s.Store.ReconcileVolumes(s.MetaStore)
+	if err != nil {
+		report.Errors = append(report.Errors, "volumes: "+err.Error())
+	}
+	report.Volumes = volumes
+
+	switch {
+	case len(report.Errors) > 0 || report.Integrity.MissingBlobs > 0 ||
+		len(report.Volumes.MissingFiles) > 0 || len(report.Volumes.TruncatedFiles) > 0:
+		report.Status = ReportStatusError
+	case report.Integrity.OrphanedBlobs > 0 || report.Integrity.ZombieBlobs > 0 ||
+		len(report.Volumes.UntrackedFiles) > 0:
+		report.Status = ReportStatusWarning
+	}
+	report.DurationMs = time.Since(start).Milliseconds()
+	return report
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+)
+
+// ErrExpired is returned by reads of a file whose expires_at (plus the grace period) has passed
+// when ExpiredAccess is ExpiredAccessDeny.
+var ErrExpired = errors.New("file expired")
+
+// ExpiredAccessPolicy selects what reads of a file do between its expiry and the cleanup that removes it.
+type ExpiredAccessPolicy string
+
+const (
+	// ExpiredAccessAllow serves expired files until the cleanup removes them (default)
+	ExpiredAccessAllow ExpiredAccessPolicy = "allow"
+	// ExpiredAccessDeny fails reads with ErrExpired once the grace period after expiry is over
+	ExpiredAccessDeny ExpiredAccessPolicy = "deny"
+)
+
+// ParseExpiredAccessPolicy parses EXPIRED_ACCESS; empty means allow.
+func ParseExpiredAccessPolicy(val string) (ExpiredAccessPolicy, error) {
+	switch ExpiredAccessPolicy(strings.ToLower(strings.TrimSpace(val))) {
+	case "", ExpiredAccessAllow:
+		return ExpiredAccessAllow, nil
+	case ExpiredAccessDeny:
+		return ExpiredAccessDeny, nil
+	default:
+		return "", fmt.Errorf("unknown expired access policy %q (expected allow or deny)", val)
+	}
+}
+
+// IsExpired reports whether expiresAt is set and has passed
+func IsExpired(expiresAt *time.Time) bool {
+	return expiresAt != nil && time.Now().After(*expiresAt)
+}
+
+// effectiveExpiry returns the expiry that applies to the file: none while it is pinned
+func effectiveExpiry(file storage.File) *time.Time {
+	if file.PinnedAt != nil {
+		return nil
+	}
+	return file.ExpiresAt
+}
+
+// checkExpiry returns ErrExpired when the policy denies reading the file
+func (s *FileService) checkExpiry(file storage.File) error {
+	if s.ExpiredAccess != ExpiredAccessDeny || !IsExpired(effectiveExpiry(file)) {
+		return nil
+	}
+	if time.Since(*file.ExpiresAt) <= s.ExpiredGracePeriod {
+		return nil
+	}
+	return fmt.Errorf("%w: file_id=%s, expired at %s", ErrExpired, file.ID, file.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+// SetFilePinned pins or unpins a file and returns the updated record. A pinned file is exempt from
+// expiry: cleanup keeps it and reads never fail with ErrExpired; expires_at stays stored and applies
+// again after unpinning.
+func (s *FileService) SetFilePinned(fileID string, pinned bool) (storage.File, error) {
+	if err := s.MetaStore.SetFilePinned(fileID, pinned); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.File{}, fmt.Errorf("%w: file_id=%s", ErrNotFound, fileID)
+		}
+		return storage.File{}, err
+	}
+	return s.MetaStore.GetFile(fileID)
+}
+
+// SetLegalHold places a file under legal hold (see storage.LegalHold) and returns the hold
+func (s *FileService) SetLegalHold(fileID, reason string) (*storage.LegalHold, error) {
+	if err := s.MetaStore.SetLegalHold(fileID, reason); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: file_id=%s", ErrNotFound, fileID)
+		}
+		return nil, err
+	}
+	return s.MetaStore.GetLegalHold(fileID)
+}
+
+// ReleaseLegalHold lifts the legal hold of a file; the file is deletable and expires again
+func (s *FileService) ReleaseLegalHold(fileID string) (*storage.LegalHold, error) {
+	hold, err := s.MetaStore.GetLegalHold(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if hold == nil {
+		return nil, fmt.Errorf("%w: no legal hold on file_id=%s", ErrNotFound, fileID)
+	}
+	if _, err := s.MetaStore.ReleaseLegalHold(fileID); err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
@@ -0,0 +1,334 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// ErrBlobAlreadyInVolume is returned by MoveFileBlob and MoveBlob when the blob already is in the target volume
+var ErrBlobAlreadyInVolume = errors.New("blob is already in the target volume")
+
+// ErrFileTypeChanged is returned when the type of a blob was changed during its re-detection
+var ErrFileTypeChanged = errors.New("file type changed concurrently")
+
+// FileTypeInfo is a file type as stored in file_types
+type FileTypeInfo struct {
+	MimeType string `json:"mimeType"`
+	Category string `json:"category"`
+	Subtype  string `json:"subtype"`
+}
+
+// FileTypeChange is the result of RedetectFileType
+type FileTypeChange struct {
+	FileID  string       `json:"fileId"`
+	BlobID  int64        `json:"blobId"`
+	Before  FileTypeInfo `json:"before"`
+	After   FileTypeInfo `json:"after"`
+	Changed bool         `json:"changed"`
+}
+
+// BlobRewriteResult is the result of RecompressFile, MoveFileBlob and MoveBlob
+type BlobRewriteResult struct {
+	FileID            string `json:"fileId,omitempty"` // empty when moved by blob ID`
+	BlobID            int64  `json:"blobId"`
+	FromVolumeID      int64  `json:"fromVolumeId"`
+	ToVolumeID        int64  `json:"toVolumeId"`
+	CompressionBefore string `json:"compressionBefore"`
+	CompressionAfter  string `json:"compressionAfter"`
+	SizeBefore        int64  `json:"sizeBefore"` // stored (compressed) size
+	SizeAfter         int64  `json:"sizeAfter"`
+}
+
+// loadFileBlob returns the file and its committed blob
+func (s *FileService) loadFileBlob(fileID string) (storage.File, storage.Blob, error) {
+	file, err := s.MetaStore.GetFile(fileID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return file, storage.Blob{}, fmt.Errorf("%w: file_id=%s", ErrNotFound, fileID)
+		}
+		return file, storage.Blob{}, err
+	}
+	blob, err := s.MetaStore.GetBlob(file.BlobID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return file, blob, fmt.Errorf("%w: blob_id=%d", ErrNotFound, file.BlobID)
+		}
+		return file, blob, err
+	}
+	if blob.VolumeID == 0 {
+		return file, blob, fmt.Errorf("blob %d has no location (state %s)", blob.ID, blob.State)
+	}
+	return file, blob, nil
+}
+
+// blobContent is the decompressed content of a blob; Close releases the volume
+type blobContent struct {
+	io.ReadCloser
+	section *storage.BlobSection
+}
+
+func (c *blobContent) Close() error {
+	c.ReadCloser.Close()
+	return c.section.Close()
+}
+
+// openBlobContent streams the decompressed content of a committed blob
+func (s *FileService) openBlobContent(blob storage.Blob) (io.ReadCloser, error) {
+	section, err := s.Store.OpenBlobSection(blob.VolumeID, blob.Offset, blob.SizeCompressed)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := decompressReader(section.Data, blob.CompressionAlg)
+	if err != nil {
+		section.Close()
+		return nil, err
+	}
+	return &blobContent{ReadCloser: rc, section: section}, nil
+}
+
+// RedetectFileType runs file type detection on the stored content again and updates the type
+// of the blob (shared by all files with the same content). A generic binary result never
+// replaces a more specific type, e.g. one taken from the Content-Type of the upload.
+func (s *FileService) RedetectFileType(fileID string) (*FileTypeChange, error) {
+	file, blob, err := s.loadFileBlob(fileID)
+	if err != nil {
+		return nil, err
+	}
+	before, err := s.MetaStore.GetFileType(blob.FileTypeID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	change := &FileTypeChange{
+		FileID: file.ID,
+		BlobID: blob.ID,
+		Before: FileTypeInfo{MimeType: before.MimeType, Category: before.Category, Subtype: before.Subtype},
+	}
+	change.After, change.Changed, err = s.redetectBlobType(blob, file.Name, change.Before, false)
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// redetectBlobType detects the type of the blob content and stores it (unless dryRun). Returns
+// the resulting type and whether it differs from before. A generic binary result never replaces
+// a more specific type; ErrFileTypeChanged means the type was changed by someone else meanwhile.
+func (s *FileService) redetectBlobType(blob storage.Blob, name string, before FileTypeInfo, dryRun bool) (FileTypeInfo, bool, error) {
+	content, err := s.openBlobContent(blob)
+	if err != nil {
+		return before, false, err
+	}
+	detected := s.detectFileType(content, name, "")
+	content.Close()
+
+	isGeneric := detected.Type == "binary" && detected.Subtype == ""
+	if isGeneric && before.Category != "" && !(before.Category == "binary" && before.Subtype == "") {
+		return before, false, nil
+	}
+	after := FileTypeInfo{MimeType: detected.ContentType, Category: detected.Type, Subtype: detected.Subtype}
+	if after == before || dryRun {
+		return after, after != before, nil
+	}
+
+	typeID, err := s.MetaStore.GetOrCreateFileType(detected.ContentType, detected.Type, detected.Subtype)
+	if err != nil {
+		return before, false, err
+	}
+	if typeID == blob.FileTypeID {
+		return after, false, nil
+	}
+	swapped, err := s.MetaStore.SwapBlobFileType(blob.ID, blob.FileTypeID, typeID)
+	if err != nil {
+		return before, false, err
+	}
+	if !swapped {
+		return before, false, fmt.Errorf("%w: blob_id=%d", ErrFileTypeChanged, blob.ID)
+	}
+	utils.Info("ADMIN", "File type re-detected: blob_id=%d, %s -> %s", blob.ID, before.MimeType, after.MimeType)
+	return after, true, nil
+}
+
+// redetectBatchSize is the number of blobs loaded per query by RedetectFileTypes
+const redetectBatchSize = 500
+
+// redetectErrorsKept limits the errors listed in a RedetectSummary
+const redetectErrorsKept = 20
+
+// RedetectSummary is the result of RedetectFileTypes
+type RedetectSummary struct {
+	DryRun  bool             `json:"dryRun"`
+	Scanned int64            `json:"scanned"`
+	Changed int64            `json:"changed"` // with dryRun: would change
+	Skipped int64            `json:"skipped"` // type changed concurrently, left as is
+	Failed  int64            `json:"failed"`
+	Changes map[string]int64 `json:"changes"` // "old mime -> new mime": number of blobs
+	Errors  []string         `json:"errors,omitempty"`
+}
+
+// RedetectFileTypes re-runs file type detection for all committed blobs whose current type
+// matches the filter, e.g. blobs typed as binary from compressed samples by rebuild-db.
+// A failing blob is counted and the run goes on. progress is called after each batch.
+func (s *FileService) RedetectFileTypes(filter storage.FileTypeFilter, dryRun bool, progress func(RedetectSummary)) (RedetectSummary, error) {
+	summary := RedetectSummary{DryRun: dryRun, Changes: map[string]int64{}}
+
+	afterID := int64(0)
+	for {
+		blobs, err := s.MetaStore.ListBlobsByFileType(filter, afterID, redetectBatchSize)
+		if err != nil {
+			return summary, err
+		}
+		if len(blobs) == 0 {
+			return summary, nil
+		}
+
+		for _, b := range blobs {
+			afterID = b.ID
+			summary.Scanned++
+			before := FileTypeInfo{MimeType: b.Type.MimeType, Category: b.Type.Category, Subtype: b.Type.Subtype}
+			after, changed, err := s.redetectBlobType(b.Blob, b.FileName, before, dryRun)
+			switch {
+			case errors.Is(err, ErrFileTypeChanged):
+				summary.Skipped++
+			case err != nil:
+				summary.Failed++
+				if len(summary.Errors) < redetectErrorsKept {
+					summary.Errors = append(summary.Errors, fmt.Sprintf("blob %d: %v", b.ID, err))
+				}
+				utils.Warn("ADMIN", "File type re-detection failed: blob_id=%d, error=%v", b.ID, err)
+			case changed:
+				summary.Changed++
+				summary.Changes[before.MimeType+" -> "+after.MimeType]++
+			}
+		}
+		if progress != nil {
+			progress(summary)
+		}
+	}
+}
+
+// RecompressFile rewrites the blob of the file with the given compression mode (Auto, zstd, gzip,
+// none; Auto applies MINIMAL_COMPRESSION). The content hash is checked before the new copy is
+// written; the old copy becomes deleted space reclaimed by compaction.
+func (s *FileService) RecompressFile(fileID, mode string) (*BlobRewriteResult, error) {
+	file, blob, err := s.claimFileBlob(fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseBlobRef(blob.ID)
+
+	content, err := s.openBlobContent(blob)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.processStream(content, mode)
+	content.Close()
+	if err != nil {
+		return nil, err
+	}
+	defer res.cleanup()
+	if blob.Hash != "" && res.hash != blob.Hash {
+		return nil, fmt.Errorf("content hash %s does not match blob hash %s, blob is damaged", res.hash, blob.Hash)
+	}
+
+	volumeID, offset, _, err := s.Store.WriteBlobWithMetadata(blob.ID, res.tempFile, res.sizeStored, format.CompressionCode(res.alg), s.MetaStore)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	return s.finishRewrite(file.ID, blob, volumeID, offset, res.sizeStored, res.alg)
+}
+
+// MoveFileBlob copies the stored blob of the file unchanged into the given volume. The copy is
+// verified against the CRC footer first; the old copy becomes deleted space.
+func (s *FileService) MoveFileBlob(fileID string, volumeID int64) (*BlobRewriteResult, error) {
+	file, blob, err := s.claimFileBlob(fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseBlobRef(blob.ID)
+	return s.moveClaimedBlob(file.ID, blob, volumeID)
+}
+
+// claimFileBlob is loadFileBlob with the blob claimed (ClaimBlobRef), so it can't be freed by a
+// concurrent delete; the caller must call releaseBlobRef.
+func (s *FileService) claimFileBlob(fileID string) (storage.File, storage.Blob, error) {
+	file, blob, err := s.loadFileBlob(fileID)
+	if err != nil {
+		return file, blob, err
+	}
+	claimed, err := s.MetaStore.ClaimBlobRef(blob.ID)
+	if err != nil {
+		return file, blob, fmt.Errorf("database error claiming blob: %w", err)
+	}
+	if !claimed {
+		return file, blob, fmt.Errorf("%w: blob_id=%d", ErrNotFound, blob.ID)
+	}
+	return file, blob, nil
+}
+
+// copyStoredBlob copies the stored data of the blob to w and checks it against the CRC footer
+func (s *FileService) copyStoredBlob(blob storage.Blob, w io.Writer) (uint32, error) {
+	section, err := s.Store.OpenBlobSection(blob.VolumeID, blob.Offset, blob.SizeCompressed)
+	if errors.Is(err, storage.ErrVolumeMissing) && s.ReadFallback != nil && s.ReadFallback.VolumeDir != "" {
+		// Ztracený volume: blob se čte z kopie v READ_FALLBACK_DIR, přesun ho tak vyléčí
+		var ferr error
+		if section, ferr = s.ReadFallback.openBlobSection(blob); ferr != nil {
+			return 0, fmt.Errorf("%w (fallback: %v)", err, ferr)
+		}
+		err = nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer section.Close()
+
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(io.MultiWriter(w, h), section.Data); err != nil {
+		return 0, err
+	}
+	if crc := h.Sum32(); crc != section.FooterCRC {
+		return 0, fmt.Errorf("CRC 0x%08X, footer says 0x%08X, blob is damaged", crc, section.FooterCRC)
+	}
+	return section.FooterCRC, nil
+}
+
+// finishRewrite points the blob to its new copy. When that fails, the new copy is accounted
+// as deleted space so compaction reclaims it.
+func (s *FileService) finishRewrite(fileID string, blob storage.Blob, volumeID, offset, size int64, alg string) (*BlobRewriteResult, error) {
+	err := s.MetaStore.RelocateBlob(storage.BlobRelocation{
+		BlobID:         blob.ID,
+		FromVolumeID:   blob.VolumeID,
+		FromOffset:     blob.Offset,
+		FromSize:       blob.SizeCompressed,
+		ToVolumeID:     volumeID,
+		ToOffset:       offset,
+		ToSize:         size,
+		CompressionAlg: alg,
+	})
+	if err != nil {
+		if derr := s.MetaStore.IncrementDeletedSize(volumeID, format.BlobTotalSize(size)); derr != nil {
+			utils.Warn("ADMIN", "Failed to account unused blob copy: volume=%d, offset=%d, error=%v", volumeID, offset, derr)
+		}
+		return nil, err
+	}
+
+	utils.Info("ADMIN", "Blob rewritten: file_id=%s, blob_id=%d, volume %d -> %d, compression %s -> %s, size %d -> %d",
+		fileID, blob.ID, blob.VolumeID, volumeID, blob.CompressionAlg, alg, blob.SizeCompressed, size)
+	return &BlobRewriteResult{
+		FileID:            fileID,
+		BlobID:            blob.ID,
+		FromVolumeID:      blob.VolumeID,
+		ToVolumeID:        volumeID,
+		CompressionBefore: blob.CompressionAlg,
+		CompressionAfter:  alg,
+		SizeBefore:        blob.SizeCompressed,
+		SizeAfter:         size,
+	}, nil
+}
@@ -1,8 +1,9 @@
 package service
 
 import (
-	"bytes"
+	"bufio"
 	"compress/gzip"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/base64"
 	"encoding/hex"
@@ -12,12 +13,14 @@ import (
 	"mime"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/klauspost/compress/zstd"
 	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
 	"github.com/pmalasek/cumulus3/src/internal/utils"
 	"golang.org/x/crypto/blake2b"
 )
@@ -28,12 +31,81 @@ var ErrNotFound = errors.New("not found")
 // ErrOldCumulusIDConflict is returned when the provided old_cumulus_id is already assigned to a different file.
 var ErrOldCumulusIDConflict = errors.New("old_cumulus_id already assigned to a different file")
 
+// OldIDConflictMode selects what an upload does when its old_cumulus_id is already mapped to another file.
+type OldIDConflictMode string
+
+const (
+	// OldIDConflictReject fails the upload with ErrOldCumulusIDConflict (default)
+	OldIDConflictReject OldIDConflictMode = "reject"
+	// OldIDConflictSupersede moves the old_cumulus_id to the new file; the previous file keeps
+	// its UUID but is no longer reachable by the legacy ID
+	OldIDConflictSupersede OldIDConflictMode = "supersede"
+)
+
+// ParseOldIDConflictMode parses the on_conflict upload parameter; empty means reject.
+func ParseOldIDConflictMode(val string) (OldIDConflictMode, error) {
+	switch OldIDConflictMode(strings.ToLower(strings.TrimSpace(val))) {
+	case "", OldIDConflictReject:
+		return OldIDConflictReject, nil
+	case OldIDConflictSupersede:
+		return OldIDConflictSupersede, nil
+	default:
+		return "", fmt.Errorf("unknown conflict mode %q (expected reject or supersede)", val)
+	}
+}
+
+// Content-Disposition types stored per file
+const (
+	DispositionAuto       = ""           // inline for types browsers can display, attachment otherwise
+	DispositionInline     = "inline"     // always shown in the browser
+	DispositionAttachment = "attachment" // always downloaded
+)
+
+// ParseDisposition parses the disposition upload parameter; empty means DispositionAuto.
+func ParseDisposition(val string) (string, error) {
+	switch d := strings.ToLower(strings.TrimSpace(val)); d {
+	case DispositionAuto, DispositionInline, DispositionAttachment:
+		return d, nil
+	default:
+		return "", fmt.Errorf("invalid disposition %q (use inline or attachment)", val)
+	}
+}
+
+// ErrContentTooLarge is returned when extended file info would embed content above ExtendedInfoMaxSize.
+var ErrContentTooLarge = errors.New("content too large for extended info")
+
+// DefaultExtendedInfoMaxSize caps the raw size of content embedded as base64 in extended file info.
+const DefaultExtendedInfoMaxSize int64 = 10 << 20
+
+// DefaultCompressionSampleSize is the start of the content compressed in memory in Auto mode
+const DefaultCompressionSampleSize int64 = 1 << 20
+
+// MaxCompressionSampleSize bounds CompressionSampleSize; the sample is held in memory per upload
+const MaxCompressionSampleSize int64 = 64 << 20
+
 type FileService struct {
 	Store               *storage.Store
 	MetaStore           *storage.MetadataSQL
 	Logger              *storage.MetadataLogger
 	CompressionMode     string
 	MinCompressionRatio float64
+	// ExtendedInfoMaxSize limits extended (base64) file info; 0 disables the extended mode
+	ExtendedInfoMaxSize int64
+	// CompressionSampleSize is the start of the content compressed in memory in Auto mode to
+	// decide whether the whole content is compressed
+	CompressionSampleSize int64
+	// DetectionSampleSize is the start of the (uncompressed) content passed to type detection,
+	// at most utils.MaxDetectionSampleSize; 0 = utils.DefaultDetectionSampleSize
+	DetectionSampleSize int64
+	// ReadFallback serves downloads of blobs whose volume file is missing; nil disables it
+	ReadFallback *ReadFallback
+	// ExpiredAccess decides whether files past expires_at (plus ExpiredGracePeriod) can be read
+	ExpiredAccess      ExpiredAccessPolicy
+	ExpiredGracePeriod time.Duration
+	// ImagePlaceholders enables computing a BlurHash and average color of uploaded images
+	ImagePlaceholders bool
+	// UploadHook validates uploads with an external service before they are stored; nil disables it
+	UploadHook *UploadHook
 }
 
 // NewFileService creates a new instance of FileService
@@ -44,74 +116,180 @@ func NewFileService(store *storage.Store, metaStore *storage.MetadataSQL, logger
 		Logger:              logger,
 		CompressionMode:     compressionMode,
 		MinCompressionRatio: minCompressionRatio,
+		ExtendedInfoMaxSize: DefaultExtendedInfoMaxSize,
+		ExpiredAccess:       ExpiredAccessAllow,
+
+		CompressionSampleSize: DefaultCompressionSampleSize,
+		DetectionSampleSize:   utils.DefaultDetectionSampleSize,
 	}
 }
 
 // UploadFile handles the entire file upload process: streaming, compression, deduplication, and metadata storage
 func (s *FileService) UploadFile(file io.Reader, filename string, contentType string, oldCumulusID *int64, expiresAt *time.Time, tags string) (string, error) {
-	id, _, _, err := s.UploadFileWithDedup(file, filename, contentType, oldCumulusID, expiresAt, tags)
+	id, _, _, err := s.UploadFileWithDedup(file, filename, contentType, oldCumulusID, expiresAt, nil, tags, DispositionAuto, OldIDConflictReject)
 	return id, err
 }
 
+// UploadResult is the outcome of storing an upload
+type UploadResult struct {
+	FileID       string
+	OldCumulusID int64 // assigned old_cumulus_id
+	BlobID       int64 // blob holding the content, shared with other files on a dedup hit
+	Dedup        bool  // the content was already stored
+	MimeType     string
+}
+
 // UploadFileWithDedup handles the entire file upload process and returns deduplication status.
 // If oldCumulusID is nil, the highest existing old_cumulus_id is found in the database, incremented by 1,
 // and used as the new value. The assigned old_cumulus_id is returned as the second return value.
-func (s *FileService) UploadFileWithDedup(file io.Reader, filename string, contentType string, oldCumulusID *int64, expiresAt *time.Time, tags string) (string, int64, bool, error) {
-	result, err := s.processStream(file)
+// createdAt overrides the creation time of a new file record (migration); nil means now.
+// disposition (DispositionInline/Attachment) overrides the MIME-based Content-Disposition of downloads.
+// onConflict decides what happens when oldCumulusID already belongs to another file.
+// The upload hook, when set, is asked after the content is received and before it is stored.
+func (s *FileService) UploadFileWithDedup(file io.Reader, filename string, contentType string, oldCumulusID *int64, expiresAt *time.Time, createdAt *time.Time, tags string, disposition string, onConflict OldIDConflictMode) (string, int64, bool, error) {
+	res, err := s.UploadFileWithResult(file, filename, contentType, oldCumulusID, expiresAt, createdAt, tags, disposition, onConflict)
+	return res.FileID, res.OldCumulusID, res.Dedup, err
+}
+
+// UploadFileWithResult is UploadFileWithDedup returning also the blob ID
+func (s *FileService) UploadFileWithResult(file io.Reader, filename string, contentType string, oldCumulusID *int64, expiresAt *time.Time, createdAt *time.Time, tags string, disposition string, onConflict OldIDConflictMode) (UploadResult, error) {
+	result, err := s.processStream(file, s.CompressionMode)
 	if err != nil {
-		return "", 0, false, err
+		return UploadResult{}, err
 	}
 	defer result.cleanup()
 
 	// Detect file type
-	// Read first 12KB for detection
-	detectBuffer := make([]byte, 12000)
-	result.tempFile.Seek(0, 0)
-	n, _ := io.ReadFull(result.tempFile, detectBuffer)
-	fileType := utils.DetectFileType(detectBuffer[:n])
+	fileType := s.refineFileType(result.detected, filename, contentType)
 	utils.Info("SERVICE", "File type detected: type=%s, subtype=%s, mime=%s, hash=%s",
 		fileType.Type, fileType.Subtype, fileType.ContentType, result.hash)
 
-	// If detection returned generic binary, try to use provided content type or extension
-	if fileType.Type == "binary" && fileType.Subtype == "" {
-		mimeType := s.determineMimeType(filename, contentType)
-		if mimeType != "application/octet-stream" {
-			fileType.ContentType = mimeType
-			// Try to guess category/subtype from mimeType
-			parts := strings.Split(mimeType, "/")
-			if len(parts) == 2 {
-				fileType.Type = parts[0]
-				fileType.Subtype = parts[1]
-			}
-		}
-	}
+	utils.Info("SERVICE", "Compression decision: raw_size=%d, compressed_size=%d, algorithm=%s, decided_by=%s, hash=%s",
+		result.sizeRaw, result.sizeStored, result.alg, result.decision, result.hash)
 
-	finalFile, sizeCompressed, alg := s.decideCompression(result)
-	utils.Info("SERVICE", "Compression decision: raw_size=%d, compressed_size=%d, algorithm=%s, hash=%s",
-		result.sizeRaw, sizeCompressed, alg, result.hash)
+	if err := s.UploadHook.Check(newUploadHookRequest(filename, fileType, result.hash, result.sizeRaw, tags, oldCumulusID)); err != nil {
+		return UploadResult{}, err
+	}
 
-	blobID, isDedup, err := s.saveBlob(result.hash, finalFile, result.sizeRaw, sizeCompressed, alg, fileType)
+	blobID, isDedup, err := s.saveBlob(result.hash, result.tempFile, result.sizeRaw, result.sizeStored, result.alg, fileType)
 	if err != nil {
 		utils.Info("SERVICE", "ERROR saving blob: hash=%s, error=%v", result.hash, err)
-		return "", 0, false, err
+		return UploadResult{}, err
 	}
 
+	defer s.releaseBlobRef(blobID)
+
 	if isDedup {
 		utils.Info("SERVICE", "Deduplication hit: hash=%s, blob_id=%d", result.hash, blobID)
 	}
+	s.saveImageSize(blobID, result.image)
+	if !isDedup {
+		s.saveImagePlaceholder(blobID, result)
+	}
+
+	fileID, assignedOldID, err := s.registerFile(blobID, filename, oldCumulusID, expiresAt, createdAt, tags, disposition, onConflict)
+	if err != nil {
+		return UploadResult{}, err
+	}
+	s.flagTypeMismatch(fileID, filename, result.detected)
+	return UploadResult{FileID: fileID, OldCumulusID: assignedOldID, BlobID: blobID, Dedup: isDedup, MimeType: fileType.ContentType}, nil
+}
+
+// flagTypeMismatch records a conflict of the content detected by signature with the filename
+// extension (e.g. a .pdf that is a ZIP); a failure only logs, the upload itself succeeded
+func (s *FileService) flagTypeMismatch(fileID, filename string, detected utils.FileTypeResult) {
+	expected := utils.ExtensionMismatch(detected, filename)
+	if expected == "" {
+		return
+	}
+	utils.Warn("SERVICE", "Type mismatch: file_id=%s, filename=%s, extension=%s, detected=%s", fileID, filename, expected, detected.ContentType)
+	if err := s.MetaStore.SetFileTypeMismatch(fileID, expected); err != nil {
+		utils.Warn("SERVICE", "Failed to record type mismatch for file_id=%s: %v", fileID, err)
+	}
+}
+
+// LinkExistingBlob creates a file record for an already stored blob identified by its hash,
+// without receiving the content again. Returns ErrNotFound when no committed blob has the hash.
+// The upload hook is asked like for an upload of the content. The result is always a dedup hit.
+func (s *FileService) LinkExistingBlob(hash string, filename string, oldCumulusID *int64, expiresAt *time.Time, createdAt *time.Time, tags string, disposition string, onConflict OldIDConflictMode) (UploadResult, error) {
+	blob, err := s.FindCommittedBlob(hash)
+	if err != nil {
+		return UploadResult{}, err
+	}
+	fileType, err := s.MetaStore.GetFileType(blob.FileTypeID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return UploadResult{}, err
+	}
+	if s.UploadHook != nil {
+		detected := utils.FileTypeResult{Type: fileType.Category, Subtype: fileType.Subtype, ContentType: fileType.MimeType}
+		if err := s.UploadHook.Check(newUploadHookRequest(filename, detected, hash, blob.SizeRaw, tags, oldCumulusID)); err != nil {
+			return UploadResult{}, err
+		}
+	}
+	claimed, err := s.MetaStore.ClaimBlobRef(blob.ID)
+	if err != nil {
+		return UploadResult{}, fmt.Errorf("database error claiming blob: %w", err)
+	}
+	if !claimed {
+		return UploadResult{}, fmt.Errorf("%w: hash=%s (blob freed)", ErrNotFound, hash)
+	}
+	defer s.releaseBlobRef(blob.ID)
+
+	utils.Info("SERVICE", "Linking existing blob: hash=%s, blob_id=%d, filename=%s", hash, blob.ID, filename)
+	fileID, assignedOldID, err := s.registerFile(blob.ID, filename, oldCumulusID, expiresAt, createdAt, tags, disposition, onConflict)
+	if err != nil {
+		return UploadResult{}, err
+	}
+	return UploadResult{FileID: fileID, OldCumulusID: assignedOldID, BlobID: blob.ID, Dedup: true, MimeType: fileType.MimeType}, nil
+}
+
+// releaseBlobRef drops the reference claim taken while storing or linking a blob
+func (s *FileService) releaseBlobRef(blobID int64) {
+	if err := s.MetaStore.ReleaseBlobRef(blobID); err != nil {
+		utils.Warn("SERVICE", "Failed to release blob claim: blob_id=%d, error=%v", blobID, err)
+	}
+}
+
+// FindCommittedBlob returns the committed blob with the given content hash.
+func (s *FileService) FindCommittedBlob(hash string) (*storage.Blob, error) {
+	blob, err := s.MetaStore.GetBlobByHash(hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: hash=%s", ErrNotFound, hash)
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if blob.State != "committed" {
+		return nil, fmt.Errorf("%w: hash=%s (blob not committed)", ErrNotFound, hash)
+	}
+	return &blob, nil
+}
 
+// registerFile links a stored blob to a file record, resolving old_cumulus_id
+// conflicts and duplicates. Returns the file ID and the assigned old ID.
+func (s *FileService) registerFile(blobID int64, filename string, oldCumulusID *int64, expiresAt *time.Time, createdAt *time.Time, tags string, disposition string, onConflict OldIDConflictMode) (string, int64, error) {
 	// If old_cumulus_id was explicitly provided, verify it is not already used by a different blob.
 	if oldCumulusID != nil {
 		existing, err := s.MetaStore.GetFileByOldID(*oldCumulusID)
-		if err == nil {
+		if err == nil && onConflict == OldIDConflictSupersede && !isSameFileRecord(existing, blobID, filename, expiresAt) {
+			// Explicitní přemapování: původní soubor zůstává dostupný přes UUID
+			previousID, err := s.MetaStore.ClearOldCumulusID(*oldCumulusID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return "", 0, fmt.Errorf("database error releasing old_cumulus_id: %w", err)
+			}
+			if err == nil {
+				utils.Info("SERVICE", "SUPERSEDE: old_cumulus_id=%d moved from file_id=%s to new file (blob_id=%d, filename=%s)",
+					*oldCumulusID, previousID, blobID, filename)
+			}
+		} else if err == nil {
 			// Record exists – conflict only if it belongs to a different blob.
 			if existing.BlobID != blobID {
 				utils.Info("SERVICE", "CONFLICT: old_cumulus_id=%d already assigned to file_id=%s (different blob), new blob_id=%d",
 					*oldCumulusID, existing.ID, blobID)
-				return "", 0, false, ErrOldCumulusIDConflict
+				return "", 0, ErrOldCumulusIDConflict
 			}
 		} else if !errors.Is(err, sql.ErrNoRows) {
-			return "", 0, false, fmt.Errorf("database error checking old_cumulus_id: %w", err)
+			return "", 0, fmt.Errorf("database error checking old_cumulus_id: %w", err)
 		}
 	}
 
@@ -122,7 +300,7 @@ func (s *FileService) UploadFileWithDedup(file io.Reader, filename string, conte
 		existingFile, err := s.MetaStore.FindFileByBlobNameAndExpiry(blobID, filename, expiresAt)
 		if err != nil {
 			utils.Info("SERVICE", "ERROR checking existing file: blob_id=%d, error=%v", blobID, err)
-			return "", 0, false, err
+			return "", 0, err
 		}
 		if existingFile != nil {
 			// File already exists – merge tags if needed and return the existing record.
@@ -134,137 +312,411 @@ func (s *FileService) UploadFileWithDedup(file io.Reader, filename string, conte
 					}
 				}
 			}
+			s.updateDisposition(existingFile, disposition)
 			utils.Info("SERVICE", "Duplicate file detected (auto-id path): returning existing file_id=%s, filename=%s", existingFile.ID, filename)
 			var existingOldID int64
 			if existingFile.OldCumulusID != nil {
 				existingOldID = *existingFile.OldCumulusID
 			}
-			return existingFile.ID, existingOldID, true, nil
+			return existingFile.ID, existingOldID, nil
 		}
 
 		// No existing file found – auto-assign the next old_cumulus_id atomically.
 		autoID, err := s.MetaStore.AllocateNextOldCumulusID()
 		if err != nil {
 			utils.Info("SERVICE", "ERROR allocating old_cumulus_id: %v", err)
-			return "", 0, false, err
+			return "", 0, err
 		}
 		oldCumulusID = &autoID
 		utils.Info("SERVICE", "Auto-assigned old_cumulus_id=%d for filename=%s", autoID, filename)
 	} else {
 		// Keep counter ahead of explicitly provided legacy IDs (migration/import path).
 		if err := s.MetaStore.EnsureOldCumulusIDAtLeast(*oldCumulusID); err != nil {
-			return "", 0, false, fmt.Errorf("failed to advance old_id counter: %w", err)
+			return "", 0, fmt.Errorf("failed to advance old_id counter: %w", err)
 		}
 	}
 
-	fileID, err := s.saveFile(filename, blobID, oldCumulusID, expiresAt, tags)
+	fileID, err := s.saveFile(filename, blobID, oldCumulusID, expiresAt, createdAt, tags, disposition)
 	if err != nil {
 		if oldCumulusID != nil {
 			errText := strings.ToLower(err.Error())
 			if strings.Contains(errText, "old_cumulus_id") && (strings.Contains(errText, "unique") || strings.Contains(errText, "duplicate")) {
-				return "", 0, false, ErrOldCumulusIDConflict
+				return "", 0, ErrOldCumulusIDConflict
 			}
 		}
 		utils.Info("SERVICE", "ERROR saving file metadata: filename=%s, blob_id=%d, error=%v", filename, blobID, err)
-		return "", 0, false, err
+		return "", 0, err
 	}
-	return fileID, *oldCumulusID, isDedup, err
+	return fileID, *oldCumulusID, nil
 }
 
-// decompressBlob returns a streaming reader that decompresses data according to alg.
+// isSameFileRecord reports whether an existing file is the record an upload would create
+// (same blob, name and expiry), i.e. a repeated upload rather than a new mapping.
+func isSameFileRecord(f storage.File, blobID int64, filename string, expiresAt *time.Time) bool {
+	if f.BlobID != blobID || f.Name != filename {
+		return false
+	}
+	if f.ExpiresAt == nil || expiresAt == nil {
+		return f.ExpiresAt == nil && expiresAt == nil
+	}
+	return f.ExpiresAt.Equal(*expiresAt)
+}
+
+// decompressReader wraps r (stored blob data) in a decompressor according to alg.
 // The caller must close the returned ReadCloser.
-func decompressBlob(data []byte, alg string) (io.ReadCloser, error) {
+func decompressReader(r io.Reader, alg string) (io.ReadCloser, error) {
 	switch alg {
 	case "gzip":
-		r, err := gzip.NewReader(bytes.NewReader(data))
+		gr, err := gzip.NewReader(r)
 		if err != nil {
 			return nil, fmt.Errorf("gzip error: %w", err)
 		}
-		return r, nil
+		return gr, nil
 	case "zstd":
-		d, err := zstd.NewReader(bytes.NewReader(data))
+		d, err := zstd.NewReader(r)
 		if err != nil {
 			return nil, fmt.Errorf("zstd error: %w", err)
 		}
-		// *zstd.Decoder.Close() has no return value, so wrap in NopCloser
-		return io.NopCloser(d), nil
+		// Close releases the decoder goroutines of a streamed blob
+		return d.IOReadCloser(), nil
 	case "none", "":
-		return io.NopCloser(bytes.NewReader(data)), nil
+		return io.NopCloser(r), nil
 	default:
 		return nil, fmt.Errorf("unknown compression algorithm: %s", alg)
 	}
 }
 
-// downloadFileRecord fetches the blob for an already-resolved File record, reads and
-// decompresses it, and returns a streaming reader together with the raw size, filename and MIME type.
-// The caller must close the returned ReadCloser.
-func (s *FileService) downloadFileRecord(file storage.File) (io.ReadCloser, int64, string, string, error) {
+// FileDownload describes the content returned by DownloadFile
+type FileDownload struct {
+	Filename    string
+	MimeType    string
+	Disposition string // stored disposition, DispositionAuto = decide by MIME type
+	SizeRaw     int64
+	Hash        string // BLAKE2b-256 of the content
+	ExpiresAt   *time.Time
+	Ranges      bool // content comes from a local volume and answers Range requests, not from the read fallback
+}
+
+// fileDownloadInfo resolves the blob and the download metadata of an already-resolved File record
+func (s *FileService) fileDownloadInfo(file storage.File) (storage.Blob, *FileDownload, error) {
 	blob, err := s.MetaStore.GetBlob(file.BlobID)
 	if err != nil {
-		return nil, 0, "", "", fmt.Errorf("blob not found: %w", err)
+		return blob, nil, fmt.Errorf("blob not found: %w", err)
 	}
 
 	fileType, err := s.MetaStore.GetFileType(blob.FileTypeID)
 	if err != nil {
-		return nil, 0, "", "", fmt.Errorf("file type not found: %w", err)
+		return blob, nil, fmt.Errorf("file type not found: %w", err)
 	}
 
 	utils.Info("SERVICE", "FileType from DB: file_id=%s, mime=%s, category=%s, subtype=%s",
 		file.ID, fileType.MimeType, fileType.Category, fileType.Subtype)
+
+	mimeType := fileType.MimeType
+	if mimeType == "" {
+		mimeType = s.determineMimeType(file.Name, "")
+		utils.Info("SERVICE", "Empty mime type from DB, using fallback: file_id=%s, fallback_mime=%s", file.ID, mimeType)
+	}
+
+	return blob, &FileDownload{Filename: file.Name, MimeType: mimeType, Disposition: file.Disposition, SizeRaw: blob.SizeRaw, Hash: blob.Hash, ExpiresAt: effectiveExpiry(file)}, nil
+}
+
+// downloadFileRecord fetches the blob for an already-resolved File record and returns a reader
+// streaming its decompressed content together with the file's download metadata. The content is
+// never held in memory as a whole; a CRC mismatch fails the last read. A blob in a local volume
+// is returned as an io.ReadSeeker (see blobStream), one served by the read fallback is not.
+// The caller must close the returned ReadCloser.
+func (s *FileService) downloadFileRecord(file storage.File) (io.ReadCloser, *FileDownload, error) {
+	blob, dl, err := s.fileDownloadInfo(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	utils.Info("SERVICE", "Reading blob: file_id=%s, blob_id=%d, volume_id=%d, offset=%d, size=%d, compression=%s",
 		file.ID, file.BlobID, blob.VolumeID, blob.Offset, blob.SizeCompressed, blob.CompressionAlg)
 
-	data, err := s.Store.ReadBlob(blob.VolumeID, blob.Offset, blob.SizeCompressed)
+	var rc io.ReadCloser
+	stream, err := s.openBlobStream(blob)
+	if err == nil {
+		rc, dl.Ranges = stream, true
+	} else if errors.Is(err, storage.ErrVolumeMissing) {
+		utils.Warn("SERVICE", "Volume file missing, trying read fallback: file_id=%s, blob_id=%d, volume=%d",
+			file.ID, file.BlobID, blob.VolumeID)
+		rc, err = s.readMissingBlob(file, blob, err)
+	}
 	if err != nil {
 		utils.Info("SERVICE", "ERROR reading blob from storage: file_id=%s, blob_id=%d, volume=%d, offset=%d, size=%d, error=%v",
 			file.ID, file.BlobID, blob.VolumeID, blob.Offset, blob.SizeCompressed, err)
-		return nil, 0, "", "", fmt.Errorf("error reading blob: %w", err)
+		return nil, nil, fmt.Errorf("error reading blob: %w", err)
+	}
+	return rc, dl, nil
+}
+
+// DownloadFile retrieves a file by its ID, handling decompression if necessary.
+// The caller must close the returned ReadCloser.
+func (s *FileService) DownloadFile(fileID string) (io.ReadCloser, *FileDownload, error) {
+	file, err := s.MetaStore.GetFile(fileID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("%w: file_id=%s", ErrNotFound, fileID)
+		}
+		utils.Info("SERVICE", "File not found in metadata: file_id=%s, error=%v", fileID, err)
+		return nil, nil, fmt.Errorf("file not found: %w", err)
+	}
+	if err := s.checkExpiry(file); err != nil {
+		return nil, nil, err
+	}
+	return s.downloadFileRecord(file)
+}
+
+// DownloadFileByOldID retrieves a file by its old Cumulus ID.
+// The caller must close the returned ReadCloser.
+func (s *FileService) DownloadFileByOldID(oldID int64) (io.ReadCloser, *FileDownload, error) {
+	file, err := s.MetaStore.GetFileByOldID(oldID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("%w: old_id=%d", ErrNotFound, oldID)
+		}
+		return nil, nil, fmt.Errorf("file not found: %w", err)
+	}
+	if err := s.checkExpiry(file); err != nil {
+		return nil, nil, err
+	}
+	return s.downloadFileRecord(file)
+}
+
+// StatFile returns the download metadata of a file without reading its blob (HEAD requests).
+func (s *FileService) StatFile(fileID string) (*FileDownload, error) {
+	file, err := s.MetaStore.GetFile(fileID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: file_id=%s", ErrNotFound, fileID)
+		}
+		return nil, fmt.Errorf("file not found: %w", err)
+	}
+	if err := s.checkExpiry(file); err != nil {
+		return nil, err
+	}
+	return s.statFileRecord(file)
+}
+
+// StatFileByOldID returns the download metadata of a file by its old Cumulus ID without reading its blob.
+func (s *FileService) StatFileByOldID(oldID int64) (*FileDownload, error) {
+	file, err := s.MetaStore.GetFileByOldID(oldID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: old_id=%d", ErrNotFound, oldID)
+		}
+		return nil, fmt.Errorf("file not found: %w", err)
+	}
+	if err := s.checkExpiry(file); err != nil {
+		return nil, err
+	}
+	return s.statFileRecord(file)
+}
+
+// statFileRecord returns the download metadata of a resolved File record. Ranges is decided like
+// downloadFileRecord does: only a blob whose volume file is present is served with Range support.
+func (s *FileService) statFileRecord(file storage.File) (*FileDownload, error) {
+	blob, dl, err := s.fileDownloadInfo(file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Store.VolumePath(blob.VolumeID); err == nil {
+		dl.Ranges = true
+	}
+	return dl, nil
+}
+
+// FileHash holds the content hashes of a file
+type FileHash struct {
+	ID      string `json:"id"`
+	BLAKE2b string `json:"blake2b"`
+	SHA256  string `json:"sha256,omitempty"`
+	Size    int64  `json:"size"`
+}
+
+// GetFileHash returns the stored BLAKE2b-256 hash of the file content.
+// With withSHA256 the content is read and hashed with SHA-256 as well.
+func (s *FileService) GetFileHash(fileID string, withSHA256 bool) (*FileHash, error) {
+	file, err := s.MetaStore.GetFile(fileID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: file_id=%s", ErrNotFound, fileID)
+		}
+		return nil, fmt.Errorf("file not found: %w", err)
+	}
+
+	blob, err := s.MetaStore.GetBlob(file.BlobID)
+	if err != nil {
+		return nil, fmt.Errorf("blob not found: %w", err)
+	}
+
+	result := &FileHash{ID: file.ID, BLAKE2b: blob.Hash, Size: blob.SizeRaw}
+	if withSHA256 {
+		rc, _, err := s.downloadFileRecord(file)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, rc); err != nil {
+			return nil, fmt.Errorf("error hashing content: %w", err)
+		}
+		result.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	}
+	return result, nil
+}
+
+// BlobLocation describes where the stored bytes of a file live on disk.
+// It is used to hand a download off to the fronting web server.
+type BlobLocation struct {
+	FileID         string
+	Filename       string
+	MimeType       string
+	Disposition    string
+	VolumePath     string
+	DataOffset     int64 // first byte of blob data (header already skipped)
+	SizeCompressed int64
+	SizeRaw        int64
+	CompressionAlg string
+	Hash           string // BLAKE2b-256 of the content
+	ExpiresAt      *time.Time
+}
+
+// locateFileRecord resolves the volume file and data offset of an already-resolved File record.
+func (s *FileService) locateFileRecord(file storage.File) (*BlobLocation, error) {
+	if err := s.checkExpiry(file); err != nil {
+		return nil, err
+	}
+	blob, err := s.MetaStore.GetBlob(file.BlobID)
+	if err != nil {
+		return nil, fmt.Errorf("blob not found: %w", err)
 	}
 
-	rc, err := decompressBlob(data, blob.CompressionAlg)
+	fileType, err := s.MetaStore.GetFileType(blob.FileTypeID)
 	if err != nil {
-		return nil, 0, "", "", err
+		return nil, fmt.Errorf("file type not found: %w", err)
+	}
+
+	volumePath, err := s.Store.VolumePath(blob.VolumeID)
+	if err != nil {
+		return nil, err
 	}
 
 	mimeType := fileType.MimeType
 	if mimeType == "" {
 		mimeType = s.determineMimeType(file.Name, "")
-		utils.Info("SERVICE", "Empty mime type from DB, using fallback: file_id=%s, fallback_mime=%s", file.ID, mimeType)
 	}
 
-	return rc, blob.SizeRaw, file.Name, mimeType, nil
+	return &BlobLocation{
+		FileID:         file.ID,
+		Filename:       file.Name,
+		MimeType:       mimeType,
+		Disposition:    file.Disposition,
+		VolumePath:     volumePath,
+		DataOffset:     blob.Offset + storage.HeaderSize,
+		SizeCompressed: blob.SizeCompressed,
+		SizeRaw:        blob.SizeRaw,
+		CompressionAlg: blob.CompressionAlg,
+		Hash:           blob.Hash,
+		ExpiresAt:      effectiveExpiry(file),
+	}, nil
 }
 
-// DownloadFile retrieves a file by its ID, handling decompression if necessary.
-// The caller must close the returned ReadCloser.
-func (s *FileService) DownloadFile(fileID string) (io.ReadCloser, int64, string, string, error) {
+// LocateFile returns the on-disk location of the file's blob.
+func (s *FileService) LocateFile(fileID string) (*BlobLocation, error) {
 	file, err := s.MetaStore.GetFile(fileID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, 0, "", "", fmt.Errorf("%w: file_id=%s", ErrNotFound, fileID)
+			return nil, fmt.Errorf("%w: file_id=%s", ErrNotFound, fileID)
 		}
-		utils.Info("SERVICE", "File not found in metadata: file_id=%s, error=%v", fileID, err)
-		return nil, 0, "", "", fmt.Errorf("file not found: %w", err)
+		return nil, fmt.Errorf("file not found: %w", err)
 	}
-	return s.downloadFileRecord(file)
+	return s.locateFileRecord(file)
 }
 
-// DownloadFileByOldID retrieves a file by its old Cumulus ID.
-// The caller must close the returned ReadCloser.
-func (s *FileService) DownloadFileByOldID(oldID int64) (io.ReadCloser, int64, string, string, error) {
+// LocateFileByOldID returns the on-disk location of the file's blob by its old Cumulus ID.
+func (s *FileService) LocateFileByOldID(oldID int64) (*BlobLocation, error) {
 	file, err := s.MetaStore.GetFileByOldID(oldID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, 0, "", "", fmt.Errorf("%w: old_id=%d", ErrNotFound, oldID)
+			return nil, fmt.Errorf("%w: old_id=%d", ErrNotFound, oldID)
 		}
-		return nil, 0, "", "", fmt.Errorf("file not found: %w", err)
+		return nil, fmt.Errorf("file not found: %w", err)
 	}
-	return s.downloadFileRecord(file)
+	return s.locateFileRecord(file)
+}
+
+// ResolveOldID returns the UUID of the file currently holding the old Cumulus ID.
+func (s *FileService) ResolveOldID(oldID int64) (string, error) {
+	file, err := s.MetaStore.GetFileByOldID(oldID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%w: old_id=%d", ErrNotFound, oldID)
+		}
+		return "", fmt.Errorf("file not found: %w", err)
+	}
+	return file.ID, nil
+}
+
+// CheckOldIDAvailable reports whether an upload with old_cumulus_id would be rejected with
+// ErrOldCumulusIDConflict (on_conflict=reject): the ID belongs to a file with other content.
+// An unknown hash ("" or not stored yet) counts as other content.
+func (s *FileService) CheckOldIDAvailable(oldID int64, hash string) error {
+	existing, err := s.MetaStore.GetFileByOldID(oldID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("database error checking old_cumulus_id: %w", err)
+	}
+	if hash != "" {
+		if blob, err := s.FindCommittedBlob(hash); err == nil && blob.ID == existing.BlobID {
+			return nil
+		}
+	}
+	return ErrOldCumulusIDConflict
+}
+
+// detectionSampleSize returns how much of the (uncompressed) content is read for file type detection
+func (s *FileService) detectionSampleSize() int {
+	if s.DetectionSampleSize <= 0 {
+		return int(utils.DefaultDetectionSampleSize)
+	}
+	return int(min(s.DetectionSampleSize, utils.MaxDetectionSampleSize))
+}
+
+// detectFileType detects the type from the first DetectionSampleSize bytes of content. When
+// detection returns generic binary, the provided content type or the file extension is used.
+func (s *FileService) detectFileType(content io.Reader, filename, contentType string) utils.FileTypeResult {
+	sample := make([]byte, s.detectionSampleSize())
+	n, _ := io.ReadFull(content, sample)
+	return s.refineFileType(utils.DetectFileType(sample[:n]), filename, contentType)
+}
+
+// refineFileType replaces a generic binary detection result with the provided content type or
+// the type given by the file extension
+func (s *FileService) refineFileType(fileType utils.FileTypeResult, filename, contentType string) utils.FileTypeResult {
+	if fileType.Type == "binary" && fileType.Subtype == "" {
+		mimeType := s.determineMimeType(filename, contentType)
+		if mimeType != "application/octet-stream" {
+			fileType.ContentType = mimeType
+			// Try to guess category/subtype from mimeType
+			parts := strings.Split(mimeType, "/")
+			if len(parts) == 2 {
+				fileType.Type = parts[0]
+				fileType.Subtype = parts[1]
+			}
+		}
+	}
+	return fileType
 }
 
 // determineMimeType tries to detect the MIME type from Content-Type header or filename extension
 func (s *FileService) determineMimeType(filename, contentType string) string {
-	if contentType != "" {
+	// application/octet-stream is the default of most multipart clients and carries no information
+	if contentType != "" && contentType != "application/octet-stream" {
 		return contentType
 	}
 	mimeType := mime.TypeByExtension(filepath.Ext(filename))
@@ -274,13 +726,18 @@ func (s *FileService) determineMimeType(filename, contentType string) string {
 	return mimeType
 }
 
+// pipelineBufferSize is the size of the copy and temp file write buffers of processStream
+const pipelineBufferSize = 256 << 10
+
 type streamResult struct {
-	tempFile           *os.File
-	tempCompressedFile *os.File
-	hash               string
-	sizeRaw            int64
-	autoCompress       bool
-	forcedAlg          string
+	tempFile   *os.File // the stored representation (raw or compressed), positioned at the start
+	hash       string
+	sizeRaw    int64
+	sizeStored int64
+	alg        string               // none, zstd or gzip
+	decision   string               // how Auto mode decided, see the compressionDecision constants
+	detected   utils.FileTypeResult // content detection result of the first DetectionSampleSize bytes
+	image      *utils.ImageSize     // size read from the headers of an image, nil otherwise
 }
 
 // cleanup removes temporary files created during the upload process
@@ -289,36 +746,101 @@ func (r *streamResult) cleanup() {
 		r.tempFile.Close()
 		os.Remove(r.tempFile.Name())
 	}
-	if r.tempCompressedFile != nil {
-		r.tempCompressedFile.Close()
-		os.Remove(r.tempCompressedFile.Name())
-	}
 }
 
-// processStream reads the input stream, calculates hash, and creates temporary files (raw and optionally compressed)
-func (s *FileService) processStream(file io.Reader) (*streamResult, error) {
-	res := &streamResult{}
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
 
-	// Decide compression strategy
-	shouldCompress := false
-	compressionAlg := "none"
+// imageProbeSize is how much of an image is kept to read its size; JPEG headers with EXIF
+// thumbnails easily exceed the detection sample
+const imageProbeSize = 256 << 10
 
-	switch strings.ToLower(s.CompressionMode) {
-	case "gzip":
-		shouldCompress = true
-		compressionAlg = "gzip"
-	case "zstd":
-		shouldCompress = true
-		compressionAlg = "zstd"
+// prefixBuffer keeps the first limit bytes written to it
+type prefixBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (p *prefixBuffer) Write(b []byte) (int, error) {
+	if room := p.limit - len(p.buf); room > 0 {
+		p.buf = append(p.buf, b[:min(room, len(b))]...)
+	}
+	return len(b), nil
+}
+
+// How Auto mode decided the compression of an upload
+const (
+	compressionDecisionExact  = "exact"  // the whole content fit into the sample
+	compressionDecisionSample = "sample" // estimated from the sample
+	compressionDecisionFormat = "format" // already compressed format (JPEG, ZIP, video, ...), no trial
+)
+
+// processStream reads the input stream, calculates the hash and writes only the stored
+// representation to a temporary file. mode is a compression mode as in USE_COMPRESS (Auto, zstd,
+// gzip, anything else = none). In Auto mode already compressed formats are stored as they are;
+// otherwise the first CompressionSampleSize bytes are compressed in memory and the whole content
+// is compressed only if the sample saves MinCompressionRatio.
+func (s *FileService) processStream(file io.Reader, mode string) (*streamResult, error) {
+	res := &streamResult{alg: "none"}
+	auto := false
+	switch m := strings.ToLower(mode); m {
+	case "gzip", "zstd":
+		res.alg = m
 	case "auto":
-		res.autoCompress = true
-		compressionAlg = "zstd"
+		auto = true
+	}
+
+	hasher, _ := blake2b.New256(nil)
+	src := io.TeeReader(file, hasher)
+
+	// Začátek obsahu: vzorek pro detekci typu a v Auto režimu i zkušební komprese
+	detectSize := s.detectionSampleSize()
+	headSize := detectSize
+	if auto {
+		headSize = max(int(min(s.CompressionSampleSize, MaxCompressionSampleSize)), detectSize)
+	}
+	head := make([]byte, headSize)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("error processing file: %w", err)
+	}
+	head = head[:n]
+	complete := n < headSize
+	res.detected = utils.DetectFileType(head[:min(n, detectSize)])
+
+	var trial []byte
+	switch {
+	case !auto:
+	case isCompressedFormat(res.detected):
+		res.decision = compressionDecisionFormat
+	default:
+		trial = compressTrial(head)
+		if n > 0 && savedPercent(int64(n), int64(len(trial))) >= s.MinCompressionRatio {
+			res.alg = "zstd"
+		}
+		res.decision = compressionDecisionExact
+		if !complete {
+			res.decision = compressionDecisionSample
+		}
+	}
+
+	// U obrázků si necháme i pokračování hlavičky za vzorkem (rozměry, EXIF orientace)
+	var probe *prefixBuffer
+	if res.detected.Type == "image" && !complete && n < imageProbeSize {
+		probe = &prefixBuffer{limit: imageProbeSize - n}
+		src = io.TeeReader(src, probe)
 	}
-	res.forcedAlg = compressionAlg
 
-	// Create temp files
-	var err error
-	res.tempFile, err = os.CreateTemp("", "upload-raw-*")
+	res.tempFile, err = os.CreateTemp("", "upload-*")
 	if err != nil {
 		return nil, fmt.Errorf("internal error creating temp file: %w", err)
 	}
@@ -331,93 +853,192 @@ func (s *FileService) processStream(file io.Reader) (*streamResult, error) {
 		}
 	}()
 
-	if res.autoCompress {
-		res.tempCompressedFile, err = os.CreateTemp("", "upload-comp-*")
-		if err != nil {
-			return nil, fmt.Errorf("internal error creating temp compressed file: %w", err)
-		}
-	}
+	buffered := bufio.NewWriterSize(res.tempFile, pipelineBufferSize)
+	counter := &countingWriter{w: buffered}
 
-	// Setup writers
-	hasher, _ := blake2b.New256(nil)
-	var writers []io.Writer
-	writers = append(writers, hasher)
-
-	var zstdEncoder *zstd.Encoder
-	var gzipWriter *gzip.Writer
-
-	if res.autoCompress {
-		writers = append(writers, res.tempFile)
-		zstdEncoder, _ = zstd.NewWriter(res.tempCompressedFile)
-		writers = append(writers, zstdEncoder)
-	} else if shouldCompress {
-		switch compressionAlg {
-		case "gzip":
-			gzipWriter = gzip.NewWriter(res.tempFile)
-			writers = append(writers, gzipWriter)
-		case "zstd":
-			zstdEncoder, _ = zstd.NewWriter(res.tempFile)
-			writers = append(writers, zstdEncoder)
-		}
+	if res.decision == compressionDecisionExact && res.alg == "zstd" {
+		// Celý obsah je už zkomprimovaný ve zkušebním bufferu
+		_, err = counter.Write(trial)
+		res.sizeRaw = int64(n)
 	} else {
-		writers = append(writers, res.tempFile)
+		res.sizeRaw, err = copyCompressed(counter, head, src, res.alg)
+	}
+	if err == nil {
+		err = buffered.Flush()
 	}
-
-	// Copy
-	multiW := io.MultiWriter(writers...)
-	res.sizeRaw, err = io.Copy(multiW, file)
 	if err != nil {
 		return nil, fmt.Errorf("error processing file: %w", err)
 	}
-
-	// Close compressors
-	if zstdEncoder != nil {
-		zstdEncoder.Close()
-	}
-	if gzipWriter != nil {
-		gzipWriter.Close()
+	res.sizeStored = counter.n
+	res.hash = hex.EncodeToString(hasher.Sum(nil))
+	if res.detected.Type == "image" {
+		data := head
+		if probe != nil {
+			data = append(head[:n:n], probe.buf...)
+		}
+		if size, ok := utils.DetectImageSize(data); ok {
+			res.image = &size
+		}
 	}
 
-	// Sync
-	res.tempFile.Sync()
-	if res.tempCompressedFile != nil {
-		res.tempCompressedFile.Sync()
+	// Odhad ze začátku obsahu nevyšel, zbytek se komprimoval hůř: uložit raw
+	if res.decision == compressionDecisionSample && res.sizeStored >= res.sizeRaw {
+		utils.Info("SERVICE", "Compression estimate missed: raw_size=%d, compressed_size=%d, storing raw", res.sizeRaw, res.sizeStored)
+		if err := s.decompressTempFile(res); err != nil {
+			return nil, err
+		}
 	}
 
-	res.hash = hex.EncodeToString(hasher.Sum(nil))
+	if _, err := res.tempFile.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
 	success = true
 	return res, nil
 }
 
-// decideCompression chooses between the raw and compressed file based on the compression ratio (in Auto mode)
-func (s *FileService) decideCompression(res *streamResult) (*os.File, int64, string) {
-	if res.autoCompress {
-		statRaw, _ := res.tempFile.Stat()
-		statComp, _ := res.tempCompressedFile.Stat()
+// compressedFormats are content types that don't compress further
+var compressedFormats = map[string]bool{
+	"image/jpeg":                  true,
+	"image/png":                   true,
+	"image/gif":                   true,
+	"image/webp":                  true,
+	"image/heic":                  true,
+	"image/avif":                  true,
+	"application/zip":             true,
+	"application/gzip":            true,
+	"application/zstd":            true,
+	"application/x-7z-compressed": true,
+	"application/vnd.rar":         true,
+	"application/x-bzip2":         true,
+	"application/x-xz":            true,
+	"application/java-archive":    true,
+	"application/epub+zip":        true,
+	"audio/mpeg":                  true,
+	"audio/ogg":                   true,
+	"audio/flac":                  true,
+	"audio/mp4":                   true,
+}
+
+// UncompressedFormats lists the content types Auto mode stores without trying to compress them
+// ("*" ends a prefix), sorted
+func UncompressedFormats() []string {
+	formats := []string{"video/*", "application/vnd.openxmlformats-officedocument.*", "application/vnd.oasis.opendocument.*"}
+	for format := range compressedFormats {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// isCompressedFormat reports whether the detected type is already compressed: the formats above,
+// video and the ZIP based office documents
+func isCompressedFormat(ft utils.FileTypeResult) bool {
+	return compressedFormats[ft.ContentType] || ft.Type == "video" ||
+		strings.HasPrefix(ft.ContentType, "application/vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(ft.ContentType, "application/vnd.oasis.opendocument.")
+}
+
+// compressTrial compresses data with zstd in memory
+func compressTrial(data []byte) []byte {
+	enc, _ := zstd.NewWriter(nil)
+	defer enc.Close()
+	return enc.EncodeAll(data, nil)
+}
 
-		sizeRaw := statRaw.Size()
-		sizeCompressed := statComp.Size()
+// savedPercent returns the space saved by compression in percent
+func savedPercent(sizeRaw, sizeCompressed int64) float64 {
+	return float64(sizeRaw-sizeCompressed) / float64(sizeRaw) * 100
+}
 
-		savedPercent := (float64(sizeRaw-sizeCompressed) / float64(sizeRaw)) * 100
+// copyCompressed writes head followed by the rest of src to w, compressed with alg.
+// Returns the raw size.
+func copyCompressed(w io.Writer, head []byte, src io.Reader, alg string) (int64, error) {
+	var enc io.WriteCloser
+	switch alg {
+	case "zstd":
+		enc, _ = zstd.NewWriter(w)
+	case "gzip":
+		enc = gzip.NewWriter(w)
+	}
+	out := w
+	if enc != nil {
+		out = enc
+	}
 
-		if savedPercent >= s.MinCompressionRatio {
-			return res.tempCompressedFile, sizeCompressed, "zstd"
+	if _, err := out.Write(head); err != nil {
+		return 0, err
+	}
+	rest, err := io.CopyBuffer(out, src, make([]byte, pipelineBufferSize))
+	if err != nil {
+		return 0, err
+	}
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			return 0, err
 		}
-		return res.tempFile, sizeRaw, "none"
 	}
+	return int64(len(head)) + rest, nil
+}
 
-	// Not auto
-	stat, _ := res.tempFile.Stat()
-	sizeCompressed := stat.Size()
+// decompressTempFile replaces the compressed temp file of res with the raw content
+func (s *FileService) decompressTempFile(res *streamResult) error {
+	if _, err := res.tempFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	rc, err := decompressReader(res.tempFile, res.alg)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
 
-	if res.forcedAlg != "none" {
-		return res.tempFile, sizeCompressed, res.forcedAlg
+	raw, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return fmt.Errorf("internal error creating temp file: %w", err)
+	}
+	buffered := bufio.NewWriterSize(raw, pipelineBufferSize)
+	_, err = io.CopyBuffer(buffered, rc, make([]byte, pipelineBufferSize))
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		raw.Close()
+		os.Remove(raw.Name())
+		return fmt.Errorf("error processing file: %w", err)
 	}
-	return res.tempFile, sizeCompressed, "none"
+
+	res.cleanup()
+	res.tempFile = raw
+	res.sizeStored = res.sizeRaw
+	res.alg = "none"
+	return nil
 }
 
 // saveBlob stores the file content in the volume storage if it doesn't exist yet (deduplication)
+// saveBlob stores the content or finds an existing blob with the same hash. The returned blob
+// is claimed (ClaimBlobRef), so it cannot be garbage collected before the caller links a file
+// to it; the caller must call releaseBlobRef afterwards.
 func (s *FileService) saveBlob(hash string, file *os.File, sizeRaw, sizeCompressed int64, alg string, fileType utils.FileTypeResult) (int64, bool, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		blobID, isDedup, err := s.storeBlob(hash, file, sizeRaw, sizeCompressed, alg, fileType)
+		if err != nil || !isDedup {
+			return blobID, isDedup, err // new blob is claimed inside storeBlob
+		}
+		claimed, err := s.MetaStore.ClaimBlobRef(blobID)
+		if err != nil {
+			return 0, false, fmt.Errorf("database error claiming blob: %w", err)
+		}
+		if claimed {
+			return blobID, true, nil
+		}
+		// Poslední soubor s tímto blobem byl mezitím smazán a blob uvolněn – uložit znovu
+		utils.Warn("SERVICE", "Deduplicated blob was freed before it could be claimed, retrying: hash=%s, blob_id=%d", hash, blobID)
+	}
+	return 0, false, fmt.Errorf("blob for hash %s was repeatedly freed during upload", hash)
+}
+
+// storeBlob returns an existing committed blob (isDedup, not claimed) or writes a new one
+// (claimed before it becomes visible as committed).
+func (s *FileService) storeBlob(hash string, file *os.File, sizeRaw, sizeCompressed int64, alg string, fileType utils.FileTypeResult) (blobID int64, isDedup bool, err error) {
 	// 1) Fast path: use already committed blob if it exists.
 	if committedID, exists, err := s.MetaStore.GetCommittedBlobIDByHash(hash); err == nil && exists {
 		currentBlob, err := s.MetaStore.GetBlob(committedID)
@@ -435,7 +1056,7 @@ func (s *FileService) saveBlob(hash string, file *os.File, sizeRaw, sizeCompress
 
 	// 2) Get or create pending blob row.
 	var blob storage.Blob
-	blob, err := s.MetaStore.GetBlobByHash(hash)
+	blob, err = s.MetaStore.GetBlobByHash(hash)
 	if err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
 			return 0, false, fmt.Errorf("database error loading blob by hash: %w", err)
@@ -476,18 +1097,22 @@ func (s *FileService) saveBlob(hash string, file *os.File, sizeRaw, sizeCompress
 		}
 	}()
 
+	// Claim the reference before the blob is committed, so no concurrent dedup+delete can free it
+	if _, err = s.MetaStore.ClaimBlobRef(blob.ID); err != nil {
+		return 0, false, fmt.Errorf("database error claiming blob: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			s.releaseBlobRef(blob.ID)
+		}
+	}()
+
 	// 3. Write to storage
 	if _, err := file.Seek(0, 0); err != nil {
 		return 0, false, fmt.Errorf("error seeking file for storage: %w", err)
 	}
 
-	compAlgCode := uint8(0)
-	switch alg {
-	case "gzip":
-		compAlgCode = 1
-	case "zstd":
-		compAlgCode = 2
-	}
+	compAlgCode := format.CompressionCode(alg)
 
 	// Use WriteBlobWithMetadata to check DB values for free space
 	volID, offset, actualSize, err := s.Store.WriteBlobWithMetadata(blob.ID, file, sizeCompressed, compAlgCode, s.MetaStore)
@@ -520,7 +1145,7 @@ func (s *FileService) saveBlob(hash string, file *os.File, sizeRaw, sizeCompress
 }
 
 // saveFile creates a new file record in the metadata database linked to the blob
-func (s *FileService) saveFile(filename string, blobID int64, oldCumulusID *int64, expiresAt *time.Time, tags string) (string, error) {
+func (s *FileService) saveFile(filename string, blobID int64, oldCumulusID *int64, expiresAt *time.Time, createdAt *time.Time, tags string, disposition string) (string, error) {
 	// Check if file with same blob_id, filename, old_cumulus_id, and expiresAt already exists
 	existingFile, err := s.MetaStore.FindFileByBlobAndName(blobID, filename, oldCumulusID, expiresAt)
 	if err != nil {
@@ -541,6 +1166,7 @@ func (s *FileService) saveFile(filename string, blobID int64, oldCumulusID *int6
 				}
 			}
 		}
+		s.updateDisposition(existingFile, disposition)
 		utils.Info("SERVICE", "Duplicate file detected: returning existing file_id=%s, filename=%s, blob_id=%d",
 			existingFile.ID, filename, blobID)
 		return existingFile.ID, nil
@@ -548,14 +1174,19 @@ func (s *FileService) saveFile(filename string, blobID int64, oldCumulusID *int6
 
 	// No duplicate found, create new file record
 	fileID := uuid.New().String()
+	created := time.Now()
+	if createdAt != nil {
+		created = *createdAt
+	}
 	fileMeta := storage.File{
 		ID:           fileID,
 		Name:         filename,
 		BlobID:       blobID,
 		OldCumulusID: oldCumulusID,
 		ExpiresAt:    expiresAt,
-		CreatedAt:    time.Now(),
+		CreatedAt:    created,
 		Tags:         tags,
+		Disposition:  disposition,
 	}
 
 	if err := s.MetaStore.SaveFile(fileMeta); err != nil {
@@ -574,6 +1205,19 @@ func (s *FileService) saveFile(filename string, blobID int64, oldCumulusID *int6
 	return fileID, nil
 }
 
+// updateDisposition stores an explicitly requested disposition on an existing (duplicate) file record.
+// DispositionAuto keeps the stored value.
+func (s *FileService) updateDisposition(existingFile *storage.File, disposition string) {
+	if disposition == DispositionAuto || disposition == existingFile.Disposition {
+		return
+	}
+	if err := s.MetaStore.UpdateFileDisposition(existingFile.ID, disposition); err != nil {
+		utils.Warn("SERVICE", "Failed to update disposition for file_id=%s: %v", existingFile.ID, err)
+		return
+	}
+	utils.Info("SERVICE", "Disposition updated for file_id=%s: %q -> %q", existingFile.ID, existingFile.Disposition, disposition)
+}
+
 // mergeTags merges two JSON-encoded tag strings, deduplicating entries.
 func mergeTags(existingTags, newTags string) string {
 	if existingTags == "" {
@@ -610,13 +1254,20 @@ type FileInfo struct {
 	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
 	CreatedAt      time.Time  `json:"created_at"`
 	Tags           []string   `json:"tags,omitempty"`
+	Disposition    string     `json:"disposition,omitempty"`
+	PinnedAt       *time.Time `json:"pinned_at,omitempty"`
+	LegalHold      bool       `json:"legal_hold,omitempty"`    // deletes and expiry fail until released by an admin
+	TypeMismatch   string     `json:"type_mismatch,omitempty"` // MIME type of the filename extension when the content is of another type
 	Hash           string     `json:"hash"`
 	SizeRaw        int64      `json:"size_raw"`
 	SizeCompressed int64      `json:"size_compressed"`
 	CompressionAlg string     `json:"compression_alg"`
+	RefCount       int64      `json:"ref_count"`       // file records sharing the blob (deduplication)
+	AttributedSize int64      `json:"attributed_size"` // this file's share of the stored blob: size_compressed / ref_count
 	MimeType       string     `json:"mime_type"`
 	Category       string     `json:"category"`
 	Subtype        string     `json:"subtype"`
+	Image          *ImageInfo `json:"image,omitempty"`   // images only
 	Content        string     `json:"content,omitempty"` // Base64 encoded
 }
 
@@ -637,6 +1288,27 @@ func (s *FileService) buildFileInfo(file storage.File, extended bool) (*FileInfo
 		tags = storage.TagsFromJSON(file.Tags)
 	}
 
+	refCount, err := s.MetaStore.CountFilesByBlob(blob.ID)
+	if err != nil {
+		return nil, err
+	}
+	attributedSize := blob.SizeCompressed
+	if refCount > 1 {
+		attributedSize = blob.SizeCompressed / refCount
+	}
+	image, err := s.imageInfo(blob.ID)
+	if err != nil {
+		return nil, err
+	}
+	hold, err := s.MetaStore.GetLegalHold(file.ID)
+	if err != nil {
+		return nil, err
+	}
+	typeMismatch, err := s.MetaStore.GetFileTypeMismatch(file.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	info := &FileInfo{
 		ID:             file.ID,
 		Name:           file.Name,
@@ -645,17 +1317,31 @@ func (s *FileService) buildFileInfo(file storage.File, extended bool) (*FileInfo
 		ExpiresAt:      file.ExpiresAt,
 		CreatedAt:      file.CreatedAt,
 		Tags:           tags,
+		Disposition:    file.Disposition,
+		PinnedAt:       file.PinnedAt,
+		LegalHold:      hold != nil,
+		TypeMismatch:   typeMismatch,
 		Hash:           blob.Hash,
 		SizeRaw:        blob.SizeRaw,
 		SizeCompressed: blob.SizeCompressed,
 		CompressionAlg: blob.CompressionAlg,
+		RefCount:       refCount,
+		AttributedSize: attributedSize,
 		MimeType:       fileType.MimeType,
 		Category:       fileType.Category,
 		Subtype:        fileType.Subtype,
+		Image:          image,
 	}
 
 	if extended {
-		rc, _, _, _, err := s.downloadFileRecord(file)
+		// Celý obsah se drží v paměti (a base64 ho ještě zvětší) – velké soubory jen přes download
+		if s.ExtendedInfoMaxSize <= 0 || blob.SizeRaw > s.ExtendedInfoMaxSize {
+			return nil, fmt.Errorf("%w: file_id=%s, size=%d, limit=%d", ErrContentTooLarge, file.ID, blob.SizeRaw, s.ExtendedInfoMaxSize)
+		}
+		if err := s.checkExpiry(file); err != nil {
+			return nil, err
+		}
+		rc, _, err := s.downloadFileRecord(file)
 		if err != nil {
 			return nil, err
 		}
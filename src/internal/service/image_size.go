@@ -0,0 +1,71 @@
+package service
+
+import (
+	"io"
+
+	"github.com/pmalasek/cumulus3/src/internal/images"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// ImageInfo is the pixel size of an image file, detected on upload, and its placeholder
+type ImageInfo struct {
+	Width         int    `json:"width" example:"4032"`
+	Height        int    `json:"height" example:"3024"`
+	Orientation   int    `json:"orientation,omitempty" example:"6"` // EXIF orientation 1-8
+	DisplayWidth  int    `json:"display_width" example:"3024"`      // width after applying the orientation
+	DisplayHeight int    `json:"display_height" example:"4032"`
+	Blurhash      string `json:"blurhash,omitempty" example:"LEHV6nWB2yk8pyo0adR*.7kCMdnj"` // https://blurha.sh, of the displayed image
+	Color         string `json:"color,omitempty" example:"#7a8b9c"`                         // average color
+}
+
+// saveImageSize stores the size of an uploaded image with its blob. A failure only logs, the
+// upload itself succeeded.
+func (s *FileService) saveImageSize(blobID int64, size *utils.ImageSize) {
+	if size == nil {
+		return
+	}
+	err := s.MetaStore.SetBlobImageSize(blobID, size.Width, size.Height, size.Orientation)
+	if err != nil {
+		utils.Warn("SERVICE", "Failed to store image size: blob_id=%d, error=%v", blobID, err)
+	}
+}
+
+// imageInfo returns the image size and placeholder of a blob, nil when no size is known
+func (s *FileService) imageInfo(blobID int64) (*ImageInfo, error) {
+	stored, ok, err := s.MetaStore.GetBlobImage(blobID)
+	if err != nil || !ok {
+		return nil, err
+	}
+	size := utils.ImageSize{Width: stored.Width, Height: stored.Height, Orientation: stored.Orientation}
+	info := &ImageInfo{Width: size.Width, Height: size.Height, Orientation: size.Orientation, Blurhash: stored.Blurhash, Color: stored.Color}
+	info.DisplayWidth, info.DisplayHeight = size.DisplaySize()
+	return info, nil
+}
+
+// saveImagePlaceholder computes the BlurHash and average color of a newly stored image and
+// stores them with its blob. Failures (unsupported format, limits) only log.
+func (s *FileService) saveImagePlaceholder(blobID int64, res *streamResult) {
+	if !s.ImagePlaceholders || res.image == nil || res.sizeRaw > images.MaxImageInputSize {
+		return
+	}
+	rc, err := decompressReader(io.NewSectionReader(res.tempFile, 0, res.sizeStored), res.alg)
+	if err != nil {
+		utils.Warn("SERVICE", "Failed to read image for placeholder: blob_id=%d, error=%v", blobID, err)
+		return
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		utils.Warn("SERVICE", "Failed to read image for placeholder: blob_id=%d, error=%v", blobID, err)
+		return
+	}
+
+	blurhash, color, err := images.Placeholder(data)
+	if err != nil {
+		utils.Warn("SERVICE", "Failed to compute image placeholder: blob_id=%d, error=%v", blobID, err)
+		return
+	}
+	if err := s.MetaStore.SetBlobImagePlaceholder(blobID, blurhash, color); err != nil {
+		utils.Warn("SERVICE", "Failed to store image placeholder: blob_id=%d, error=%v", blobID, err)
+	}
+}
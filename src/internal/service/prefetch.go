@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// prefetchErrorsKept limits the errors listed in a PrefetchSummary
+const prefetchErrorsKept = 20
+
+// prefetchProgressEvery is the number of files between progress reports of PrefetchFiles
+const prefetchProgressEvery = 100
+
+// PrefetchSummary is the result of PrefetchFiles
+type PrefetchSummary struct {
+	Requested  int      `json:"requested"`
+	Done       int      `json:"done"`
+	Warmed     int      `json:"warmed"`     // read from the local volume into the page cache
+	Rehydrated int      `json:"rehydrated"` // volume file missing, copied from READ_FALLBACK_DIR into a local volume
+	NotFound   int      `json:"notFound"`
+	Failed     int      `json:"failed"`
+	BytesRead  int64    `json:"bytesRead"` // stored (compressed) size
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// PrefetchFiles prepares files for a batch of downloads: the stored blob of each file is read once so
+// the following downloads are served from the page cache, a blob whose volume file is missing is
+// copied from the cold tier (READ_FALLBACK_DIR) into a local volume like a blob move, which also heals
+// it. Without rehydrate (read-only replica) such a blob only counts as failed. Files sharing a blob are
+// read once. progress is called every prefetchProgressEvery files.
+func (s *FileService) PrefetchFiles(ids []string, concurrency int, rehydrate bool, progress func(PrefetchSummary)) PrefetchSummary {
+	summary := PrefetchSummary{Requested: len(ids)}
+	var mu sync.Mutex
+	seen := make(map[int64]bool) // blob IDs already prefetched
+
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for range max(concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range work {
+				result, bytes, err := s.prefetchFile(id, rehydrate, func(blobID int64) bool {
+					mu.Lock()
+					defer mu.Unlock()
+					first := !seen[blobID]
+					seen[blobID] = true
+					return first
+				})
+
+				mu.Lock()
+				summary.Done++
+				summary.BytesRead += bytes
+				switch {
+				case errors.Is(err, ErrNotFound):
+					summary.NotFound++
+				case err != nil:
+					summary.Failed++
+					if len(summary.Errors) < prefetchErrorsKept {
+						summary.Errors = append(summary.Errors, fmt.Sprintf("file %s: %v", id, err))
+					}
+					utils.Warn("PREFETCH", "Failed to prefetch file: file_id=%s, error=%v", id, err)
+				case result == prefetchRehydrated:
+					summary.Rehydrated++
+				default:
+					summary.Warmed++
+				}
+				if progress != nil && summary.Done%prefetchProgressEvery == 0 {
+					progress(summary)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, id := range ids {
+		work <- id
+	}
+	close(work)
+	wg.Wait()
+	return summary
+}
+
+const (
+	prefetchWarmed     = "warmed"
+	prefetchRehydrated = "rehydrated"
+)
+
+// prefetchFile reads the blob of the file, or rehydrates it when its volume file is missing and
+// rehydrate is set. first reports whether the blob is not prefetched yet; a repeated blob only counts
+// as warmed.
+func (s *FileService) prefetchFile(fileID string, rehydrate bool, first func(blobID int64) bool) (string, int64, error) {
+	file, blob, err := s.loadFileBlob(fileID)
+	if err != nil {
+		return "", 0, err
+	}
+	if !first(blob.ID) {
+		return prefetchWarmed, 0, nil
+	}
+
+	section, err := s.Store.OpenBlobSection(blob.VolumeID, blob.Offset, blob.SizeCompressed)
+	if err == nil {
+		n, err := io.Copy(io.Discard, section.Data)
+		section.Close()
+		return prefetchWarmed, n, err
+	}
+	if !errors.Is(err, storage.ErrVolumeMissing) {
+		return "", 0, err
+	}
+	if !rehydrate {
+		return "", 0, fmt.Errorf("%w, not rehydrated on a read-only replica", err)
+	}
+	if s.ReadFallback == nil || s.ReadFallback.VolumeDir == "" {
+		return "", 0, fmt.Errorf("%w, no READ_FALLBACK_DIR to rehydrate from", err)
+	}
+
+	// Ztracený volume: přesun do lokálního volumu přečte blob z kopie v READ_FALLBACK_DIR
+	claimed, err := s.MetaStore.ClaimBlobRef(blob.ID)
+	if err != nil {
+		return "", 0, fmt.Errorf("database error claiming blob: %w", err)
+	}
+	if !claimed {
+		return "", 0, fmt.Errorf("%w: blob_id=%d", ErrNotFound, blob.ID)
+	}
+	defer s.releaseBlobRef(blob.ID)
+	if _, err := s.moveClaimedBlob(file.ID, blob, 0); err != nil && !errors.Is(err, storage.ErrBlobMoved) {
+		return "", 0, err
+	}
+	utils.Info("PREFETCH", "Blob rehydrated from the cold tier: file_id=%s, blob_id=%d, volume=%d", file.ID, blob.ID, blob.VolumeID)
+	return prefetchRehydrated, blob.SizeCompressed, nil
+}
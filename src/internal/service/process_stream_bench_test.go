@@ -0,0 +1,45 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// benchContent returns size bytes of half-compressible content (random runs and repeated text)
+func benchContent(size int) []byte {
+	rng := rand.New(rand.NewSource(int64(size)))
+	text := []byte("Lorem ipsum dolor sit amet, consectetur adipiscing elit. ")
+	var buf bytes.Buffer
+	for buf.Len() < size {
+		if rng.Intn(2) == 0 {
+			chunk := make([]byte, 512)
+			rng.Read(chunk)
+			buf.Write(chunk)
+		} else {
+			buf.Write(bytes.Repeat(text, 8))
+		}
+	}
+	return buf.Bytes()[:size]
+}
+
+// go test ./internal/service -run '^$' -bench ProcessStream -benchmem
+func BenchmarkProcessStream(b *testing.B) {
+	s := &FileService{MinCompressionRatio: 10, CompressionSampleSize: DefaultCompressionSampleSize}
+	for _, size := range []int{4 << 10, 256 << 10, 4 << 20, 32 << 20} {
+		data := benchContent(size)
+		for _, mode := range []string{"none", "zstd", "auto"} {
+			b.Run(fmt.Sprintf("size=%dKB/mode=%s", size>>10, mode), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				for i := 0; i < b.N; i++ {
+					res, err := s.processStream(bytes.NewReader(data), mode)
+					if err != nil {
+						b.Fatalf("processStream: %v", err)
+					}
+					res.cleanup()
+				}
+			})
+		}
+	}
+}
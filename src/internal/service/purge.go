@@ -0,0 +1,271 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+)
+
+// Audit policies of a purge: what happens to the records of the purged files in the metadata log
+const (
+	PurgeAuditRedact = "redact" // keep the records without name and tags
+	PurgeAuditRemove = "remove" // remove the records
+)
+
+// PurgeReportTag is added to every stored purge report
+const PurgeReportTag = "purge-report"
+
+// PurgeReportVersion is the version of the purge report format (part of the digest)
+const PurgeReportVersion = 1
+
+// ErrPurgeReportSignature is returned when a report signature is missing or doesn't match
+var ErrPurgeReportSignature = errors.New("purge report signature mismatch")
+
+// PurgedFile is one hard-deleted file of a purge
+type PurgedFile struct {
+	ID         string `json:"id"`
+	BlobID     int64  `json:"blobId"`
+	BlobPurged bool   `json:"blobPurged"` // false = the content is still referenced by a file outside the purge
+}
+
+// PurgeReport is the signed record of a right-to-be-forgotten purge
+type PurgeReport struct {
+	Version          int          `json:"version"`
+	Tag              string       `json:"tag"`
+	DryRun           bool         `json:"dryRun,omitempty"`
+	StartedAt        time.Time    `json:"startedAt"`
+	CompletedAt      time.Time    `json:"completedAt"`
+	AuditPolicy      string       `json:"auditPolicy"`
+	FilesDeleted     int          `json:"filesDeleted"`
+	BlobsPurged      int          `json:"blobsPurged"`
+	BlobsRetained    int          `json:"blobsRetained"`          // shared with files outside the purge
+	CompactedVolumes []int64      `json:"compactedVolumes"`       // volumes rewritten without the purged blobs
+	AuditRecords     int          `json:"auditRecords"`           // scrubbed metadata log records
+	Errors           []string     `json:"errors,omitempty"`       // the purge is incomplete when not empty
+	Files            []PurgedFile `json:"files"`                  // in file ID order
+	Digest           string       `json:"digest"`                 // SHA-256 of the report without digest and signature
+	Signature        string       `json:"signature,omitempty"`    // HMAC-SHA256 of the digest with PURGE_REPORT_SIGNING_KEY
+	ReportFileID     string       `json:"reportFileId,omitempty"` // the stored report, not part of the digest
+}
+
+// ComputeDigest returns the SHA-256 (hex) of the JSON form of the report with empty digest,
+// signature and report file ID
+func (r *PurgeReport) ComputeDigest() string {
+	c := *r
+	c.Digest, c.Signature, c.ReportFileID = "", "", ""
+	data, _ := json.Marshal(c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign sets the digest and, with a key, the signature
+func (r *PurgeReport) Sign(key []byte) {
+	r.Digest = r.ComputeDigest()
+	r.Signature = ""
+	if len(key) > 0 {
+		r.Signature = purgeReportSignature(key, r.Digest)
+	}
+}
+
+// VerifyIntegrity checks that the digest matches the report and, with a key, the signature
+func (r *PurgeReport) VerifyIntegrity(key []byte) error {
+	if digest := r.ComputeDigest(); digest != r.Digest {
+		return fmt.Errorf("purge report digest %s does not match its content (%s)", r.Digest, digest)
+	}
+	if len(key) == 0 {
+		return nil
+	}
+	if r.Signature == "" || !hmac.Equal([]byte(r.Signature), []byte(purgeReportSignature(key, r.Digest))) {
+		return ErrPurgeReportSignature
+	}
+	return nil
+}
+
+func purgeReportSignature(key []byte, digest string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(digest))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PurgeByTag hard-deletes all files carrying tag, including pinned and expired ones. Blobs left
+// without a file are freed and the volumes holding them are compacted, so the content is gone
+// from disk; blobs shared with files outside the purge stay. The records of the purged files in
+// the metadata log are scrubbed by auditPolicy. A file under legal hold fails the whole purge
+// before anything is deleted (storage.ErrLegalHold). Failures of single steps are collected in
+// report.Errors, the returned report is signed with key. With dryRun only the files are listed.
+func (s *FileService) PurgeByTag(tag, auditPolicy string, dryRun bool, key []byte, progress func(string)) (*PurgeReport, error) {
+	if auditPolicy != PurgeAuditRedact && auditPolicy != PurgeAuditRemove {
+		return nil, fmt.Errorf("unknown audit policy %q", auditPolicy)
+	}
+	report := &PurgeReport{
+		Version:          PurgeReportVersion,
+		Tag:              tag,
+		DryRun:           dryRun,
+		StartedAt:        time.Now().UTC(),
+		AuditPolicy:      auditPolicy,
+		CompactedVolumes: []int64{},
+		Files:            []PurgedFile{},
+	}
+	if progress == nil {
+		progress = func(string) {}
+	}
+
+	files, err := s.listAllFilesByTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkLegalHolds(files); err != nil {
+		return nil, err
+	}
+
+	volumes := make(map[int64]bool)
+	purged := make(map[string]bool, len(files))
+	for i, file := range files {
+		entry := PurgedFile{ID: file.ID, BlobID: file.BlobID}
+		if dryRun {
+			report.Files = append(report.Files, entry)
+			continue
+		}
+		blob, blobErr := s.MetaStore.GetBlob(file.BlobID)
+		if err := s.MetaStore.DeleteFile(file.ID); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("delete file %s: %v", file.ID, err))
+			continue
+		}
+		report.FilesDeleted++
+		purged[file.ID] = true
+
+		if _, err := s.MetaStore.GetBlob(file.BlobID); errors.Is(err, sql.ErrNoRows) {
+			entry.BlobPurged = true
+			if blobErr == nil && blob.VolumeID != 0 {
+				volumes[blob.VolumeID] = true
+			}
+		} else if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("blob %d of file %s: %v", file.BlobID, file.ID, err))
+		}
+		report.Files = append(report.Files, entry)
+		if (i+1)%100 == 0 {
+			progress(fmt.Sprintf("Deleted %d/%d files", i+1, len(files)))
+		}
+	}
+
+	// Sdílený blob uvolní až poslední smazaný soubor, stav se proto určuje až po smazání všech
+	if !dryRun {
+		blobs := make(map[int64]bool)
+		for _, f := range report.Files {
+			blobs[f.BlobID] = blobs[f.BlobID] || f.BlobPurged
+		}
+		for i := range report.Files {
+			report.Files[i].BlobPurged = blobs[report.Files[i].BlobID]
+		}
+		for _, blobPurged := range blobs {
+			if blobPurged {
+				report.BlobsPurged++
+			} else {
+				report.BlobsRetained++
+			}
+		}
+
+		volumeIDs := make([]int64, 0, len(volumes))
+		for id := range volumes {
+			volumeIDs = append(volumeIDs, id)
+		}
+		sort.Slice(volumeIDs, func(i, j int) bool { return volumeIDs[i] < volumeIDs[j] })
+		for _, volumeID := range volumeIDs {
+			progress(fmt.Sprintf("Compacting volume %d", volumeID))
+			if err := s.Store.CompactVolume(volumeID, s.MetaStore); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("compact volume %d: %v", volumeID, err))
+				continue
+			}
+			report.CompactedVolumes = append(report.CompactedVolumes, volumeID)
+		}
+
+		if s.Logger != nil && len(purged) > 0 {
+			progress("Scrubbing metadata log")
+			n, err := s.Logger.ScrubFiles(purged, auditPolicy == PurgeAuditRedact, report.StartedAt)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("scrub metadata log: %v", err))
+			}
+			report.AuditRecords = n
+		}
+	}
+
+	report.CompletedAt = time.Now().UTC()
+	report.Sign(key)
+	return report, nil
+}
+
+// listAllFilesByTag returns all files carrying tag, expired ones included
+func (s *FileService) listAllFilesByTag(tag string) ([]storage.File, error) {
+	var files []storage.File
+	afterID := ""
+	for {
+		page, err := s.MetaStore.ListAllFilesByTag(tag, afterID, archivePageSize)
+		if err != nil {
+			return nil, fmt.Errorf("list files: %w", err)
+		}
+		files = append(files, page...)
+		if len(page) < archivePageSize {
+			return files, nil
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
+
+// CheckPurgeLegalHolds returns an error wrapping storage.ErrLegalHold when any file carrying tag
+// is under legal hold, so a purge of the tag would fail
+func (s *FileService) CheckPurgeLegalHolds(tag string) error {
+	files, err := s.listAllFilesByTag(tag)
+	if err != nil {
+		return err
+	}
+	return s.checkLegalHolds(files)
+}
+
+// checkLegalHolds fails when any of the files is under legal hold; a purge deletes all or nothing
+func (s *FileService) checkLegalHolds(files []storage.File) error {
+	var held []string
+	for _, file := range files {
+		hold, err := s.MetaStore.GetLegalHold(file.ID)
+		if err != nil {
+			return err
+		}
+		if hold != nil {
+			held = append(held, file.ID)
+		}
+	}
+	count := len(held)
+	if count == 0 {
+		return nil
+	}
+	if count > 10 {
+		held = append(held[:10], "...")
+	}
+	return fmt.Errorf("%w: %d files (%s)", storage.ErrLegalHold, count, strings.Join(held, ", "))
+}
+
+// StorePurgeReport stores the report as a file tagged "purge-report" without expiry and sets
+// report.ReportFileID
+func (s *FileService) StorePurgeReport(report *PurgeReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	filename := fmt.Sprintf("purge-report-%s-%s.json", report.Tag, report.StartedAt.Format("20060102-150405"))
+	tags := storage.TagsToJSON([]string{PurgeReportTag})
+	fileID, _, _, err := s.UploadFileWithDedup(bytes.NewReader(data), filename, "application/json", nil, nil, nil, tags, DispositionAttachment, OldIDConflictReject)
+	if err != nil {
+		return err
+	}
+	report.ReportFileID = fileID
+	return nil
+}
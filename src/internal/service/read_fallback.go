@@ -0,0 +1,208 @@
+package service
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+	"golang.org/x/crypto/blake2b"
+)
+
+// DefaultReadFallbackTimeout bounds one fetch from the replica server
+const DefaultReadFallbackTimeout = 30 * time.Second
+
+// ReadFallback lists where downloads look for a blob whose volume file is missing. The volume
+// directory is tried first, then the replica.
+type ReadFallback struct {
+	// VolumeDir holds copies of the volume files (cold tier, replica mount, restored backup)
+	// with the same names and offsets as DATA_DIR
+	VolumeDir string
+	// ReplicaURL is the base URL of a replica Cumulus3 server that has the same files; the file
+	// is downloaded by ID from /v2/files/{id}. Credentials can be given as user:password@ in the URL.
+	ReplicaURL string
+	Client     *http.Client
+}
+
+// NewReadFallback returns nil when neither source is set (fallback disabled)
+func NewReadFallback(volumeDir, replicaURL string, timeout time.Duration) *ReadFallback {
+	if volumeDir == "" && replicaURL == "" {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = DefaultReadFallbackTimeout
+	}
+	return &ReadFallback{
+		VolumeDir:  volumeDir,
+		ReplicaURL: strings.TrimSuffix(replicaURL, "/"),
+		Client:     &http.Client{Timeout: timeout},
+	}
+}
+
+// readMissingBlob serves a blob whose volume file is missing from the fallback sources and tags
+// it for heal whatever the outcome. Returns the decompressed content, streamed from the source and
+// verified on the way (see readFromVolumeDir and readFromReplica).
+func (s *FileService) readMissingBlob(file storage.File, blob storage.Blob, readErr error) (io.ReadCloser, error) {
+	source := storage.HealSourceNone
+	var rc io.ReadCloser
+	err := readErr
+	if s.ReadFallback != nil {
+		source, rc, err = s.ReadFallback.fetch(file, blob)
+		if err != nil {
+			source = storage.HealSourceNone
+			err = fmt.Errorf("%w (fallback: %v)", readErr, err)
+		}
+	}
+
+	if herr := s.MetaStore.MarkBlobForHeal(blob.ID, blob.VolumeID, source, readErr.Error()); herr != nil {
+		utils.Warn("SERVICE", "Failed to tag blob for heal: blob_id=%d, volume=%d, error=%v", blob.ID, blob.VolumeID, herr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	utils.Warn("SERVICE", "Blob served from read fallback: file_id=%s, blob_id=%d, volume=%d, source=%s",
+		file.ID, blob.ID, blob.VolumeID, source)
+	return rc, nil
+}
+
+// fetch tries the volume directory, then the replica; returns the source that served the blob
+func (f *ReadFallback) fetch(file storage.File, blob storage.Blob) (string, io.ReadCloser, error) {
+	var errs []error
+	if f.VolumeDir != "" {
+		rc, err := f.readFromVolumeDir(blob)
+		if err == nil {
+			return storage.HealSourceVolumeDir, rc, nil
+		}
+		errs = append(errs, fmt.Errorf("volume dir: %w", err))
+	}
+	if f.ReplicaURL != "" {
+		rc, err := f.readFromReplica(file, blob)
+		if err == nil {
+			return storage.HealSourceReplica, rc, nil
+		}
+		errs = append(errs, fmt.Errorf("replica: %w", err))
+	}
+	return "", nil, errors.Join(errs...)
+}
+
+// readFromVolumeDir streams the stored blob from the copy of its volume file, verified by the CRC
+// footer: the read reaching the end fails on a mismatch
+func (f *ReadFallback) readFromVolumeDir(blob storage.Blob) (io.ReadCloser, error) {
+	section, err := f.openBlobSection(blob)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := decompressReader(section.VerifiedData(), blob.CompressionAlg)
+	if err != nil {
+		section.Close()
+		return nil, err
+	}
+	return &sectionReadCloser{ReadCloser: rc, section: section}, nil
+}
+
+// openBlobSection opens the blob in the copy of its volume file
+func (f *ReadFallback) openBlobSection(blob storage.Blob) (*storage.BlobSection, error) {
+	section, err := storage.OpenBlobSectionFile(f.volumePath(blob.VolumeID), blob.Offset, blob.SizeCompressed)
+	if err != nil {
+		return nil, err
+	}
+	if section.Header.Size != blob.SizeCompressed {
+		section.Close()
+		return nil, fmt.Errorf("size mismatch at offset %d: header says %d, metadata says %d (blobID: %d)",
+			blob.Offset, section.Header.Size, blob.SizeCompressed, blob.ID)
+	}
+	return section, nil
+}
+
+// sectionReadCloser closes the decompressor, then the volume file of the section
+type sectionReadCloser struct {
+	io.ReadCloser
+	section *storage.BlobSection
+}
+
+func (s *sectionReadCloser) Close() error {
+	s.ReadCloser.Close()
+	return s.section.Close()
+}
+
+// volumePath returns the path of the volume copy in VolumeDir, 8-digit or legacy name
+func (f *ReadFallback) volumePath(volumeID int64) string {
+	path := filepath.Join(f.VolumeDir, fmt.Sprintf("volume_%08d.dat", volumeID))
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return filepath.Join(f.VolumeDir, fmt.Sprintf("volume_%d.dat", volumeID))
+	}
+	return path
+}
+
+// readFromReplica streams the file content from the replica, checked against the size and hash of
+// the blob on the way (see hashVerifiedReader)
+func (f *ReadFallback) readFromReplica(file storage.File, blob storage.Blob) (io.ReadCloser, error) {
+	resp, err := f.Client.Get(f.ReplicaURL + "/v2/files/" + file.ID)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	if resp.ContentLength >= 0 && resp.ContentLength != blob.SizeRaw {
+		resp.Body.Close()
+		return nil, fmt.Errorf("size mismatch: replica sends %d bytes, expected %d", resp.ContentLength, blob.SizeRaw)
+	}
+	return newHashVerifiedReader(resp.Body, blob), nil
+}
+
+// hashVerifiedReader streams content while computing its BLAKE2b-256 hash. A read past the expected
+// size, or the read reaching the end with a wrong size or hash, fails instead of returning the bytes
+// or EOF, so content cut short or altered by the replica ends the download with an error rather
+// than being delivered as a complete file.
+type hashVerifiedReader struct {
+	body   io.ReadCloser
+	r      io.Reader // body limited to one byte past the expected size
+	hash   hash.Hash
+	n      int64
+	size   int64
+	want   string
+	blobID int64
+	err    error // sticky mismatch
+}
+
+func newHashVerifiedReader(body io.ReadCloser, blob storage.Blob) *hashVerifiedReader {
+	h, _ := blake2b.New256(nil)
+	return &hashVerifiedReader{
+		body: body, r: io.LimitReader(body, blob.SizeRaw+1), hash: h,
+		size: blob.SizeRaw, want: blob.Hash, blobID: blob.ID,
+	}
+}
+
+func (v *hashVerifiedReader) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	n, err := v.r.Read(p)
+	v.hash.Write(p[:n])
+	v.n += int64(n)
+	switch {
+	case v.n > v.size:
+		v.err = fmt.Errorf("size mismatch: more than the expected %d bytes", v.size)
+	case err == io.EOF && v.n != v.size:
+		v.err = fmt.Errorf("size mismatch: got %d bytes, expected %d", v.n, v.size)
+	case err == io.EOF && hex.EncodeToString(v.hash.Sum(nil)) != v.want:
+		v.err = fmt.Errorf("hash mismatch for blob %d", v.blobID)
+	default:
+		return n, err
+	}
+	return 0, v.err
+}
+
+func (v *hashVerifiedReader) Close() error {
+	return v.body.Close()
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestHashVerifiedReader(t *testing.T) {
+	content := strings.Repeat("replica content ", 1000)
+	sum := blake2b.Sum256([]byte(content))
+	blob := storage.Blob{ID: 7, Hash: hex.EncodeToString(sum[:]), SizeRaw: int64(len(content))}
+
+	tests := []struct {
+		name string
+		body string
+		ok   bool
+	}{
+		{"same content", content, true},
+		{"altered content", strings.Replace(content, "r", "R", 1), false},
+		{"cut short", content[:len(content)-1], false},
+		{"longer", content + "x", false},
+	}
+	for _, tt := range tests {
+		r := newHashVerifiedReader(io.NopCloser(strings.NewReader(tt.body)), blob)
+		data, err := io.ReadAll(r)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v, want ok %v", tt.name, err, tt.ok)
+		}
+		if err == nil && string(data) != content {
+			t.Errorf("%s: read %d bytes of other content", tt.name, len(data))
+		}
+		if _, again := r.Read(make([]byte, 1)); err != nil && again == nil {
+			t.Errorf("%s: read after a mismatch succeeded", tt.name)
+		}
+	}
+}
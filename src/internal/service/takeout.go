@@ -0,0 +1,132 @@
+package service
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+)
+
+// TakeoutTag is added to every stored takeout archive
+const TakeoutTag = "takeout"
+
+// TakeoutManifest is metadata.json of a takeout archive
+type TakeoutManifest struct {
+	CreatedAt time.Time     `json:"created_at"`
+	Tags      []string      `json:"tags"` // files carrying any of these tags are included
+	Files     []TakeoutFile `json:"files"`
+}
+
+// TakeoutFile is the metadata of one exported file and where its content is in the archive
+type TakeoutFile struct {
+	FileInfo
+	Path string `json:"path" example:"files/xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx_report.pdf"`
+}
+
+// TakeoutResult describes a stored takeout archive
+type TakeoutResult struct {
+	FileID    string     `json:"file_id"`
+	Files     int        `json:"files"`
+	Bytes     int64      `json:"bytes"` // uncompressed content bytes of the exported files
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// WriteTakeout writes a complete export of the unexpired files carrying any of tags into w as a
+// tar archive: metadata.json (TakeoutManifest) first, then the content of each file as
+// files/<file ID>_<name>. A file with several of the tags is exported once. The metadata of all
+// files is held in memory while the archive is written; the content is streamed.
+func (s *FileService) WriteTakeout(w io.Writer, tags []string, progress func(ArchiveResult)) (ArchiveResult, error) {
+	var result ArchiveResult
+	manifest := TakeoutManifest{CreatedAt: time.Now().UTC(), Tags: tags, Files: []TakeoutFile{}}
+	var files []storage.File
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		afterID := ""
+		for {
+			page, err := s.MetaStore.ListFilesByTag(tag, afterID, archivePageSize)
+			if err != nil {
+				return result, fmt.Errorf("list files: %w", err)
+			}
+			for _, file := range page {
+				if seen[file.ID] {
+					continue
+				}
+				seen[file.ID] = true
+				info, err := s.buildFileInfo(file, false)
+				if err != nil {
+					return result, fmt.Errorf("file %s: %w", file.ID, err)
+				}
+				files = append(files, file)
+				manifest.Files = append(manifest.Files, TakeoutFile{FileInfo: *info, Path: "files/" + archiveEntryName(file)})
+			}
+			if len(page) < archivePageSize {
+				break
+			}
+			afterID = page[len(page)-1].ID
+		}
+	}
+
+	tw := tar.NewWriter(w)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return result, err
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "metadata.json",
+		Size:     int64(len(data)),
+		Mode:     0644,
+		ModTime:  manifest.CreatedAt,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return result, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return result, err
+	}
+
+	for i, file := range files {
+		n, err := s.writeArchiveEntry(tw, file, manifest.Files[i].Path)
+		if err != nil {
+			return result, fmt.Errorf("file %s: %w", file.ID, err)
+		}
+		result.Files++
+		result.Bytes += n
+		if progress != nil {
+			progress(result)
+		}
+	}
+	return result, tw.Close()
+}
+
+// CreateTakeout exports the files carrying any of tags (see WriteTakeout) and stores the archive
+// as a file itself, tagged "takeout" plus extraTags, downloadable until expiresAt
+func (s *FileService) CreateTakeout(tags []string, expiresAt *time.Time, extraTags []string, progress func(ArchiveResult)) (TakeoutResult, error) {
+	tmp, err := os.CreateTemp("", "takeout-*")
+	if err != nil {
+		return TakeoutResult{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	archive, err := s.WriteTakeout(tmp, tags, progress)
+	if err != nil {
+		return TakeoutResult{}, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return TakeoutResult{}, err
+	}
+
+	filename := fmt.Sprintf("takeout-%s-%s.tar", strings.Join(tags, "+"), time.Now().UTC().Format("20060102-150405"))
+	fileTags := storage.TagsToJSON(append([]string{TakeoutTag}, extraTags...))
+	fileID, _, _, err := s.UploadFileWithDedup(tmp, filename, "application/x-tar", nil, expiresAt, nil, fileTags, DispositionAttachment, OldIDConflictReject)
+	if err != nil {
+		return TakeoutResult{}, fmt.Errorf("store archive: %w", err)
+	}
+	return TakeoutResult{FileID: fileID, Files: archive.Files, Bytes: archive.Bytes, ExpiresAt: expiresAt}, nil
+}
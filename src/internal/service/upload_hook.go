@@ -0,0 +1,125 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage"
+	"github.com/pmalasek/cumulus3/src/internal/utils"
+)
+
+// DefaultUploadHookTimeout bounds one call of the upload hook
+const DefaultUploadHookTimeout = 5 * time.Second
+
+// Results of an upload hook call, see UploadHook.Observer
+const (
+	UploadHookAccepted = "accepted"
+	UploadHookRejected = "rejected"
+	UploadHookError    = "error" // the hook did not answer; the upload fails unless FailOpen
+)
+
+// ErrUploadRejected is returned when the upload hook answers other than 200
+var ErrUploadRejected = errors.New("upload rejected by policy")
+
+// ErrUploadHookUnavailable is returned when the upload hook can't be called (FailOpen off)
+var ErrUploadHookUnavailable = errors.New("upload policy service unavailable")
+
+// UploadHookRequest is the JSON body posted to the upload hook
+type UploadHookRequest struct {
+	Name         string   `json:"name" example:"invoice.pdf"`
+	MimeType     string   `json:"mimeType" example:"application/pdf"` // detected type
+	Category     string   `json:"category" example:"document"`
+	Hash         string   `json:"hash"` // BLAKE2b-256 hex of the content
+	Size         int64    `json:"size" example:"1048576"`
+	Tags         []string `json:"tags,omitempty"`
+	OldCumulusID *int64   `json:"oldCumulusId,omitempty"`
+}
+
+// UploadHook calls an external service synchronously before an upload is committed. Any
+// answer other than 200 rejects the upload; the response body is passed to the client.
+type UploadHook struct {
+	// URL receives a POST with UploadHookRequest; credentials can be given as user:password@
+	URL string
+	// FailOpen accepts uploads when the hook can't be reached or times out
+	FailOpen bool
+	Client   *http.Client
+	// Observer, when set, is called with the result and duration of every call (metrics)
+	Observer func(result string, d time.Duration)
+}
+
+// NewUploadHook returns nil when url is empty (hook disabled)
+func NewUploadHook(url string, timeout time.Duration, failOpen bool) *UploadHook {
+	if url == "" {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = DefaultUploadHookTimeout
+	}
+	return &UploadHook{URL: url, FailOpen: failOpen, Client: &http.Client{Timeout: timeout}}
+}
+
+// Check asks the hook whether the upload may be stored. Returns an error wrapping
+// ErrUploadRejected or ErrUploadHookUnavailable; nil for a nil hook.
+func (h *UploadHook) Check(req UploadHookRequest) error {
+	if h == nil {
+		return nil
+	}
+	start := time.Now()
+	result, err := h.call(req)
+	if h.Observer != nil {
+		h.Observer(result, time.Since(start))
+	}
+	if result == UploadHookError {
+		if h.FailOpen {
+			utils.Warn("UPLOAD", "Upload hook failed, accepting upload (fail open): name=%s, hash=%s, error=%v", req.Name, req.Hash, err)
+			return nil
+		}
+		return fmt.Errorf("%w: %v", ErrUploadHookUnavailable, err)
+	}
+	return err
+}
+
+func (h *UploadHook) call(req UploadHookRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return UploadHookError, err
+	}
+	resp, err := h.Client.Post(h.URL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return UploadHookError, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return UploadHookAccepted, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	reason := strings.TrimSpace(string(body))
+	if reason == "" {
+		reason = resp.Status
+	}
+	utils.Info("UPLOAD", "Upload rejected by hook: name=%s, hash=%s, status=%d, reason=%s", req.Name, req.Hash, resp.StatusCode, reason)
+	return UploadHookRejected, fmt.Errorf("%w: %s", ErrUploadRejected, reason)
+}
+
+// newUploadHookRequest describes an upload for the hook
+func newUploadHookRequest(filename string, fileType utils.FileTypeResult, hash string, size int64, tags string, oldCumulusID *int64) UploadHookRequest {
+	req := UploadHookRequest{
+		Name:         filename,
+		MimeType:     fileType.ContentType,
+		Category:     fileType.Type,
+		Hash:         hash,
+		Size:         size,
+		OldCumulusID: oldCumulusID,
+	}
+	if tags != "" {
+		req.Tags = storage.TagsFromJSON(tags)
+	}
+	return req
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrAPIKeyNameTaken is returned by CreateAPIKey when a key (also a revoked one) has the name
+var ErrAPIKeyNameTaken = errors.New("api key name already used")
+
+// APIKey identifies a client of the file API. Only the SHA-256 of the key is stored, the key
+// itself is shown once when it is created; Prefix (its first characters) tells keys apart.
+type APIKey struct {
+	ID        int64      `json:"id" example:"1"`
+	Name      string     `json:"name" example:"billing-app"`
+	Prefix    string     `json:"prefix" example:"ck_3fA9x"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// CreateAPIKey stores a new key by its hash. The name stays unique also after the key is revoked,
+// usage statistics are kept under it.
+func (m *MetadataSQL) CreateAPIKey(name, keyHash, prefix string) (*APIKey, error) {
+	var taken int
+	if err := m.db.QueryRow(m.buildQuery(`SELECT count(*) FROM api_keys WHERE name = ?`), name).Scan(&taken); err != nil {
+		return nil, err
+	}
+	if taken > 0 {
+		return nil, ErrAPIKeyNameTaken
+	}
+	key := &APIKey{Name: name, Prefix: prefix, CreatedAt: time.Now().UTC()}
+	id, err := m.insertAndReturnID(`INSERT INTO api_keys (name, key_hash, prefix, created_at) VALUES (?, ?, ?, ?)`,
+		name, keyHash, prefix, key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	key.ID = id
+	return key, nil
+}
+
+// GetActiveAPIKey returns the unrevoked key with the hash, nil when there is none
+func (m *MetadataSQL) GetActiveAPIKey(keyHash string) (*APIKey, error) {
+	var key APIKey
+	err := m.db.QueryRow(m.buildQuery(`
+		SELECT id, name, COALESCE(prefix, ''), created_at FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL
+	`), keyHash).Scan(&key.ID, &key.Name, &key.Prefix, &key.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// HasActiveAPIKeys reports whether any unrevoked key exists
+func (m *MetadataSQL) HasActiveAPIKeys() (bool, error) {
+	var n int
+	err := m.db.QueryRow(`SELECT count(*) FROM (SELECT 1 FROM api_keys WHERE revoked_at IS NULL LIMIT 1) k`).Scan(&n)
+	return n > 0, err
+}
+
+// ListAPIKeys returns all keys including the revoked ones, ordered by ID
+func (m *MetadataSQL) ListAPIKeys() ([]APIKey, error) {
+	rows, err := m.db.Query(`SELECT id, name, COALESCE(prefix, ''), created_at, revoked_at FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.CreatedAt, &key.RevokedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey revokes a key and returns it; revoking a revoked key keeps the first revoked_at.
+// Returns sql.ErrNoRows when the key does not exist.
+func (m *MetadataSQL) RevokeAPIKey(id int64) (*APIKey, error) {
+	if _, err := m.db.Exec(m.buildQuery(`UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`), time.Now().UTC(), id); err != nil {
+		return nil, err
+	}
+	var key APIKey
+	err := m.db.QueryRow(m.buildQuery(`
+		SELECT id, name, COALESCE(prefix, ''), created_at, revoked_at FROM api_keys WHERE id = ?
+	`), id).Scan(&key.ID, &key.Name, &key.Prefix, &key.CreatedAt, &key.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
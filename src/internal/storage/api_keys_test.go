@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestAPIKeys(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	if active, err := m.HasActiveAPIKeys(); err != nil || active {
+		t.Fatalf("HasActiveAPIKeys without keys = %v, %v", active, err)
+	}
+
+	key, err := m.CreateAPIKey("billing", "hash1", "ck_abc")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if active, err := m.HasActiveAPIKeys(); err != nil || !active {
+		t.Fatalf("HasActiveAPIKeys = %v, %v, want true", active, err)
+	}
+	if _, err := m.CreateAPIKey("billing", "hash2", "ck_def"); !errors.Is(err, ErrAPIKeyNameTaken) {
+		t.Fatalf("duplicate name: %v, want ErrAPIKeyNameTaken", err)
+	}
+
+	got, err := m.GetActiveAPIKey("hash1")
+	if err != nil || got == nil || got.ID != key.ID || got.Name != "billing" || got.Prefix != "ck_abc" {
+		t.Fatalf("GetActiveAPIKey = %+v, %v", got, err)
+	}
+	if got, err := m.GetActiveAPIKey("unknown"); err != nil || got != nil {
+		t.Fatalf("GetActiveAPIKey(unknown) = %+v, %v, want nil", got, err)
+	}
+
+	revoked, err := m.RevokeAPIKey(key.ID)
+	if err != nil || revoked.RevokedAt == nil {
+		t.Fatalf("RevokeAPIKey = %+v, %v", revoked, err)
+	}
+	if again, err := m.RevokeAPIKey(key.ID); err != nil || !again.RevokedAt.Equal(*revoked.RevokedAt) {
+		t.Errorf("revoking again = %+v, %v, want revoked_at kept", again, err)
+	}
+	if got, err := m.GetActiveAPIKey("hash1"); err != nil || got != nil {
+		t.Errorf("revoked key still active: %+v, %v", got, err)
+	}
+	if active, err := m.HasActiveAPIKeys(); err != nil || active {
+		t.Errorf("HasActiveAPIKeys with only a revoked key = %v, %v", active, err)
+	}
+	if _, err := m.RevokeAPIKey(999); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RevokeAPIKey(missing) = %v, want sql.ErrNoRows", err)
+	}
+	// Jméno zůstává obsazené i po revokaci (statistiky využití jsou pod ním)
+	if _, err := m.CreateAPIKey("billing", "hash3", "ck_ghi"); !errors.Is(err, ErrAPIKeyNameTaken) {
+		t.Errorf("name of a revoked key: %v, want ErrAPIKeyNameTaken", err)
+	}
+
+	keys, err := m.ListAPIKeys()
+	if err != nil || len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("ListAPIKeys = %+v, %v", keys, err)
+	}
+}
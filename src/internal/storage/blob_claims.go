@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+)
+
+// BlobClaimTTL bounds how long a reference claim protects a blob from garbage collection.
+// Claims older than this are considered leftovers of a crashed upload and ignored.
+const BlobClaimTTL = time.Hour
+
+// Reference claims close the window between finding a blob (dedup hit) and inserting the file
+// row that references it. Without a claim, DeleteFile of the last referencing file could free
+// the blob in between and the new file row would point to a deleted blob.
+//
+// Protocol: ClaimBlobRef -> SaveFile -> ReleaseBlobRef. DeleteFile locks the blob row before
+// counting references and keeps the blob while it has live claims.
+
+// ClaimBlobRef registers an in-flight reference to the blob. Returns false when the blob no
+// longer exists (it was garbage collected and the content has to be stored again).
+func (m *MetadataSQL) ClaimBlobRef(blobID int64) (bool, error) {
+	query := m.buildQuery(`
+		UPDATE blobs
+		SET ref_claims = COALESCE(ref_claims, 0) + 1, ref_claimed_at = ?
+		WHERE id = ?
+	`)
+	res, err := m.db.Exec(query, time.Now().UTC(), blobID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// ReleaseBlobRef drops a claim taken by ClaimBlobRef. When it was the last claim and no file
+// references the committed blob (e.g. the file insert failed), the blob is freed.
+func (m *MetadataSQL) ReleaseBlobRef(blobID int64) (err error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := m.buildQuery(`UPDATE blobs SET ref_claims = COALESCE(ref_claims, 0) - 1 WHERE id = ? AND COALESCE(ref_claims, 0) > 0`)
+	if _, err = tx.Exec(query, blobID); err != nil {
+		return err
+	}
+
+	var claimed bool
+	claimed, err = m.lockBlobClaimsTx(tx, blobID)
+	if err != nil {
+		return err
+	}
+
+	var state string
+	var count int
+	stateQuery := m.buildQuery(`SELECT COALESCE(state, 'pending') FROM blobs WHERE id = ?`)
+	err = tx.QueryRow(stateQuery, blobID).Scan(&state)
+	if err == sql.ErrNoRows {
+		err = nil
+		return tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+	countQuery := m.buildQuery(`SELECT count(*) FROM files WHERE blob_id = ?`)
+	if err = tx.QueryRow(countQuery, blobID).Scan(&count); err != nil {
+		return err
+	}
+
+	// Pending bloby řeší CleanupStalePendingBlobs
+	if count == 0 && !claimed && state == "committed" {
+		if err = m.freeBlobTx(tx, blobID); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// lockBlobClaimsTx locks the blob row (PostgreSQL; SQLite transactions already serialize
+// writers) and reports whether it has live reference claims. A missing blob has no claims.
+func (m *MetadataSQL) lockBlobClaimsTx(tx *sql.Tx, blobID int64) (bool, error) {
+	query := `SELECT COALESCE(ref_claims, 0), ref_claimed_at FROM blobs WHERE id = ?`
+	if m.dbType == "postgresql" {
+		query += " FOR UPDATE"
+	}
+	var claims int64
+	var claimedAt sql.NullTime
+	err := tx.QueryRow(m.buildQuery(query), blobID).Scan(&claims, &claimedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if claims <= 0 {
+		return false, nil
+	}
+	return !claimedAt.Valid || time.Since(claimedAt.Time) < BlobClaimTTL, nil
+}
+
+// freeBlobTx deletes an unreferenced blob row and accounts its space as deleted in its volume.
+func (m *MetadataSQL) freeBlobTx(tx *sql.Tx, blobID int64) error {
+	// Get blob info to know volume and size
+	var volumeID, sizeCompressed int64
+	blobQuery := m.buildQuery("SELECT volume_id, size_compressed FROM blobs WHERE id = ?")
+	if err := tx.QueryRow(blobQuery, blobID).Scan(&volumeID, &sizeCompressed); err != nil {
+		return err
+	}
+
+	// Calculate total size (Header + Compressed + Footer)
+	totalSize := format.BlobTotalSize(sizeCompressed)
+
+	// Update volumes table
+	var volQuery string
+	var volArgs []any
+	if m.dbType == "postgresql" {
+		volQuery = `
+INSERT INTO volumes (id, size_total, size_deleted) VALUES ($1, 0, $2)
+ON CONFLICT(id) DO UPDATE SET size_deleted = volumes.size_deleted + EXCLUDED.size_deleted
+`
+		volArgs = []any{volumeID, totalSize}
+	} else {
+		volQuery = m.buildQuery(`
+INSERT INTO volumes (id, size_total, size_deleted) VALUES (?, 0, ?)
+ON CONFLICT(id) DO UPDATE SET size_deleted = size_deleted + ?
+`)
+		volArgs = []any{volumeID, totalSize, totalSize}
+	}
+	if _, err := tx.Exec(volQuery, volArgs...); err != nil {
+		return err
+	}
+
+	// Delete the blob record so it's not copied during compaction
+	deleteBlobQuery := m.buildQuery("DELETE FROM blobs WHERE id = ?")
+	_, err := tx.Exec(deleteBlobQuery, blobID)
+	return err
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestMetadataSQL(t testing.TB) *MetadataSQL {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000", filepath.Join(t.TempDir(), "test.db"))
+	m, err := NewMetadataSQL("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("NewMetadataSQL: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func createCommittedBlob(t *testing.T, m *MetadataSQL, hash string) int64 {
+	t.Helper()
+	id, err := m.CreateBlob(hash)
+	if err != nil {
+		t.Fatalf("CreateBlob: %v", err)
+	}
+	if err := m.UpdateBlobLocation(id, 1, 0, 100, 100, "none", 0); err != nil {
+		t.Fatalf("UpdateBlobLocation: %v", err)
+	}
+	return id
+}
+
+func saveTestFile(t *testing.T, m *MetadataSQL, id string, blobID int64) {
+	t.Helper()
+	if err := m.SaveFile(File{ID: id, Name: id + ".txt", BlobID: blobID, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveFile(%s): %v", id, err)
+	}
+}
+
+func blobExists(t *testing.T, m *MetadataSQL, blobID int64) bool {
+	t.Helper()
+	_, err := m.GetBlob(blobID)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	return true
+}
+
+// Dedup hit is claimed, then the last existing reference is deleted before the new file row
+// is inserted: the blob must survive.
+func TestClaimBlobRefProtectsAgainstDelete(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blobID := createCommittedBlob(t, m, "hash-a")
+	saveTestFile(t, m, "old", blobID)
+
+	claimed, err := m.ClaimBlobRef(blobID)
+	if err != nil || !claimed {
+		t.Fatalf("ClaimBlobRef = (%v, %v), want (true, nil)", claimed, err)
+	}
+
+	if err := m.DeleteFile("old"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if !blobExists(t, m, blobID) {
+		t.Fatal("claimed blob was freed by DeleteFile")
+	}
+
+	saveTestFile(t, m, "new", blobID)
+	if err := m.ReleaseBlobRef(blobID); err != nil {
+		t.Fatalf("ReleaseBlobRef: %v", err)
+	}
+	if !blobExists(t, m, blobID) {
+		t.Fatal("referenced blob was freed by ReleaseBlobRef")
+	}
+
+	if err := m.DeleteFile("new"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if blobExists(t, m, blobID) {
+		t.Fatal("unreferenced, unclaimed blob was not freed")
+	}
+}
+
+// The blob was freed between the hash lookup and the claim: the claim must fail so the
+// uploader stores the content again instead of referencing a deleted blob.
+func TestClaimBlobRefAfterFree(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blobID := createCommittedBlob(t, m, "hash-b")
+	saveTestFile(t, m, "only", blobID)
+
+	if err := m.DeleteFile("only"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	claimed, err := m.ClaimBlobRef(blobID)
+	if err != nil {
+		t.Fatalf("ClaimBlobRef: %v", err)
+	}
+	if claimed {
+		t.Fatal("ClaimBlobRef succeeded on a freed blob")
+	}
+}
+
+// A claim released without inserting a file (failed upload) frees a blob kept alive only by it.
+func TestReleaseBlobRefFreesUnreferencedBlob(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blobID := createCommittedBlob(t, m, "hash-c")
+	saveTestFile(t, m, "old", blobID)
+
+	if _, err := m.ClaimBlobRef(blobID); err != nil {
+		t.Fatalf("ClaimBlobRef: %v", err)
+	}
+	if err := m.DeleteFile("old"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if err := m.ReleaseBlobRef(blobID); err != nil {
+		t.Fatalf("ReleaseBlobRef: %v", err)
+	}
+	if blobExists(t, m, blobID) {
+		t.Fatal("blob kept alive after the last claim was released")
+	}
+}
+
+// Concurrent uploaders (claim -> insert -> release) race with deleters of the previous file.
+// No file row may ever point to a freed blob.
+func TestClaimBlobRefConcurrentDelete(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blobID := createCommittedBlob(t, m, "hash-d")
+	saveTestFile(t, m, "seed", blobID)
+
+	const rounds = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*rounds)
+
+	for i := 0; i < rounds; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			claimed, err := m.ClaimBlobRef(blobID)
+			if err != nil {
+				errs <- fmt.Errorf("claim: %w", err)
+				return
+			}
+			if !claimed {
+				return // blob already freed, uploader would store the content again
+			}
+			defer func() {
+				if err := m.ReleaseBlobRef(blobID); err != nil {
+					errs <- fmt.Errorf("release: %w", err)
+				}
+			}()
+			if err := m.SaveFile(File{ID: fmt.Sprintf("f%d", i), Name: "x", BlobID: blobID, CreatedAt: time.Now()}); err != nil {
+				errs <- fmt.Errorf("save: %w", err)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			id := "seed"
+			if i > 0 {
+				id = fmt.Sprintf("f%d", i-1)
+			}
+			if err := m.DeleteFile(id); err != nil {
+				errs <- fmt.Errorf("delete: %w", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	var dangling int
+	err := m.GetDB().QueryRow(`SELECT COUNT(*) FROM files f WHERE NOT EXISTS (SELECT 1 FROM blobs b WHERE b.id = f.blob_id)`).Scan(&dangling)
+	if err != nil {
+		t.Fatalf("count dangling files: %v", err)
+	}
+	if dangling > 0 {
+		t.Fatalf("%d file rows reference a freed blob", dangling)
+	}
+}
+
+func TestCountFilesByBlob(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blobID := createCommittedBlob(t, m, "shared")
+	saveTestFile(t, m, "a", blobID)
+	saveTestFile(t, m, "b", blobID)
+
+	count, err := m.CountFilesByBlob(blobID)
+	if err != nil {
+		t.Fatalf("CountFilesByBlob: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("CountFilesByBlob = %d, want 2", count)
+	}
+}
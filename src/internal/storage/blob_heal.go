@@ -0,0 +1,67 @@
+package storage
+
+import "time"
+
+// Sources of a blob read served despite a missing volume file, see BlobHeal.Source
+const (
+	HealSourceNone      = "none"       // no fallback configured or every fallback failed
+	HealSourceVolumeDir = "volume_dir" // copy of the volume file in READ_FALLBACK_DIR
+	HealSourceReplica   = "replica"    // replica server at READ_FALLBACK_URL
+)
+
+// BlobHeal is a blob whose volume file was missing on read. The blob has to be restored
+// (volume file copied back or the blob moved to another volume); an entry disappears from
+// ListBlobHeal once the blob is in another volume or deleted.
+type BlobHeal struct {
+	BlobID      int64     `json:"blobId" example:"912"`
+	VolumeID    int64     `json:"volumeId" example:"3"`
+	Source      string    `json:"source" example:"volume_dir"` // where the last read was served from, see HealSource*
+	Error       string    `json:"error,omitempty"`
+	Hits        int64     `json:"hits" example:"4"` // failed reads from the volume since first seen
+	FirstSeenAt time.Time `json:"firstSeenAt"`
+	LastSeenAt  time.Time `json:"lastSeenAt"`
+}
+
+// MarkBlobForHeal records a failed read of the blob from its volume; repeated reads only bump
+// the counter and the last source
+func (m *MetadataSQL) MarkBlobForHeal(blobID, volumeID int64, source, errMsg string) error {
+	now := time.Now().UTC()
+	query := m.buildQuery(`
+		INSERT INTO blob_heal (blob_id, volume_id, source, error, hits, first_seen_at, last_seen_at)
+		VALUES (?, ?, ?, ?, 1, ?, ?)
+		ON CONFLICT(blob_id) DO UPDATE SET
+			volume_id = EXCLUDED.volume_id,
+			source = EXCLUDED.source,
+			error = EXCLUDED.error,
+			hits = blob_heal.hits + 1,
+			last_seen_at = EXCLUDED.last_seen_at
+	`)
+	_, err := m.db.Exec(query, blobID, volumeID, source, errMsg, now, now)
+	return err
+}
+
+// ListBlobHeal returns blobs waiting for heal, most recently seen first. Blobs that were moved
+// out of the recorded volume or deleted meanwhile are left out.
+func (m *MetadataSQL) ListBlobHeal(limit int) ([]BlobHeal, error) {
+	rows, err := m.db.Query(m.buildQuery(`
+		SELECT h.blob_id, h.volume_id, COALESCE(h.source, ''), COALESCE(h.error, ''), h.hits, h.first_seen_at, h.last_seen_at
+		FROM blob_heal h
+		JOIN blobs b ON b.id = h.blob_id AND b.volume_id = h.volume_id
+		ORDER BY h.last_seen_at DESC, h.blob_id
+		LIMIT ?
+	`), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []BlobHeal{}
+	for rows.Next() {
+		var h BlobHeal
+		if err := rows.Scan(&h.BlobID, &h.VolumeID, &h.Source, &h.Error, &h.Hits, &h.FirstSeenAt, &h.LastSeenAt); err != nil {
+			return nil, err
+		}
+		items = append(items, h)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadBlobMissingVolumeAndCopy(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	store := newManifestTestVolume(t, m, 2)
+	blob, err := m.GetBlob(2)
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+
+	path, err := store.VolumePath(blob.VolumeID)
+	if err != nil {
+		t.Fatalf("VolumePath: %v", err)
+	}
+	copyPath := filepath.Join(t.TempDir(), filepath.Base(path))
+	if err := os.Rename(path, copyPath); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	if _, err := store.ReadBlob(blob.VolumeID, blob.Offset, blob.SizeCompressed); !errors.Is(err, ErrVolumeMissing) {
+		t.Fatalf("ReadBlob of a missing volume: got %v, want ErrVolumeMissing", err)
+	}
+	if _, err := store.VolumePath(blob.VolumeID); !errors.Is(err, ErrVolumeMissing) {
+		t.Fatalf("VolumePath of a missing volume: got %v, want ErrVolumeMissing", err)
+	}
+
+	section, err := OpenBlobSectionFile(copyPath, blob.Offset, blob.SizeCompressed)
+	if err != nil {
+		t.Fatalf("OpenBlobSectionFile: %v", err)
+	}
+	data, err := io.ReadAll(section.VerifiedData())
+	section.Close()
+	if err != nil {
+		t.Fatalf("read the volume copy: %v", err)
+	}
+	if int64(len(data)) != blob.SizeCompressed || data[0] != 'b' {
+		t.Fatalf("volume copy returned %d bytes starting with %q", len(data), data[0])
+	}
+	if section, err := OpenBlobSectionFile(copyPath, blob.Offset+1, blob.SizeCompressed); err == nil {
+		section.Close()
+		t.Fatal("OpenBlobSectionFile at a wrong offset succeeded")
+	}
+}
+
+func TestMarkBlobForHeal(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	first := createCommittedBlob(t, m, "heal1")
+	second := createCommittedBlob(t, m, "heal2")
+
+	if err := m.MarkBlobForHeal(first, 1, HealSourceNone, "volume file missing"); err != nil {
+		t.Fatalf("MarkBlobForHeal: %v", err)
+	}
+	if err := m.MarkBlobForHeal(first, 1, HealSourceVolumeDir, "volume file missing"); err != nil {
+		t.Fatalf("MarkBlobForHeal again: %v", err)
+	}
+	if err := m.MarkBlobForHeal(second, 1, HealSourceReplica, "volume file missing"); err != nil {
+		t.Fatalf("MarkBlobForHeal: %v", err)
+	}
+
+	items, err := m.ListBlobHeal(10)
+	if err != nil {
+		t.Fatalf("ListBlobHeal: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("ListBlobHeal returned %d blobs, want 2", len(items))
+	}
+	for _, h := range items {
+		if h.BlobID == first && (h.Hits != 2 || h.Source != HealSourceVolumeDir) {
+			t.Errorf("blob %d: hits=%d source=%s, want 2 and %s", first, h.Hits, h.Source, HealSourceVolumeDir)
+		}
+	}
+
+	// Blob moved to another volume is healed
+	if err := m.UpdateBlobLocation(second, 2, 0, 100, 100, "none", 0); err != nil {
+		t.Fatalf("UpdateBlobLocation: %v", err)
+	}
+	items, err = m.ListBlobHeal(10)
+	if err != nil {
+		t.Fatalf("ListBlobHeal: %v", err)
+	}
+	if len(items) != 1 || items[0].BlobID != first {
+		t.Fatalf("ListBlobHeal after move = %+v, want only blob %d", items, first)
+	}
+}
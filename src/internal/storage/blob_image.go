@@ -0,0 +1,47 @@
+package storage
+
+import "database/sql"
+
+// BlobImage holds what is known about an image blob: the pixel size and EXIF orientation
+// detected on upload and the placeholder for progressive loading
+type BlobImage struct {
+	Width       int
+	Height      int
+	Orientation int    // EXIF orientation 1-8, 0 = none
+	Blurhash    string // empty when no placeholder was computed
+	Color       string // average color "#rrggbb"
+}
+
+// SetBlobImageSize stores the image size of a blob; it is a property of the content, so all
+// files sharing the blob report it
+func (m *MetadataSQL) SetBlobImageSize(blobID int64, width, height, orientation int) error {
+	_, err := m.db.Exec(m.buildQuery(`UPDATE blobs SET image_width = ?, image_height = ?, image_orientation = ? WHERE id = ?`),
+		width, height, orientation, blobID)
+	return err
+}
+
+// SetBlobImagePlaceholder stores the BlurHash and average color of an image blob
+func (m *MetadataSQL) SetBlobImagePlaceholder(blobID int64, blurhash, color string) error {
+	_, err := m.db.Exec(m.buildQuery(`UPDATE blobs SET image_blurhash = ?, image_color = ? WHERE id = ?`),
+		blurhash, color, blobID)
+	return err
+}
+
+// GetBlobImage returns the image details of a blob; ok is false when no size was detected (not
+// an image, unsupported format or a blob stored before detection existed)
+func (m *MetadataSQL) GetBlobImage(blobID int64) (img BlobImage, ok bool, err error) {
+	var width, height, orientation sql.NullInt64
+	var blurhash, color sql.NullString
+	err = m.db.QueryRow(m.buildQuery(`SELECT image_width, image_height, image_orientation, image_blurhash, image_color FROM blobs WHERE id = ?`), blobID).
+		Scan(&width, &height, &orientation, &blurhash, &color)
+	if err != nil || !width.Valid || !height.Valid {
+		return BlobImage{}, false, err
+	}
+	return BlobImage{
+		Width:       int(width.Int64),
+		Height:      int(height.Int64),
+		Orientation: int(orientation.Int64),
+		Blurhash:    blurhash.String,
+		Color:       color.String,
+	}, true, nil
+}
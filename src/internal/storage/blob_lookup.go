@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"slices"
+	"strings"
+)
+
+// BlobLookup tells whether content with a hash is stored and which files reference it
+type BlobLookup struct {
+	Hash    string   `json:"hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Stored  bool     `json:"stored" example:"true"`
+	Size    int64    `json:"size,omitempty" example:"1048576"` // raw size of the stored content
+	FileIDs []string `json:"fileIds,omitempty"`                // only in a lookup with files
+}
+
+// LookupBlobsByHash returns a lookup for every hash, in the order of hashes. Stored means a
+// committed blob holds the content. With withFiles, FileIDs are the live (unexpired or pinned)
+// files referencing it, oldest first, and with tags only those carrying any of the tags; without
+// it FileIDs stay nil.
+func (m *MetadataSQL) LookupBlobsByHash(hashes []string, tags []string, withFiles bool) ([]BlobLookup, error) {
+	const chunkSize = 500
+	found := make(map[string]*BlobLookup, len(hashes))
+
+	for start := 0; start < len(hashes); start += chunkSize {
+		chunk := hashes[start:min(start+chunkSize, len(hashes))]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+		args := make([]any, 0, len(chunk))
+		for _, hash := range chunk {
+			args = append(args, hash)
+		}
+
+		query := `
+			SELECT b.hash, COALESCE(b.size_raw, 0), '', ''
+			FROM blobs b
+			WHERE b.state = 'committed' AND b.hash IN (` + placeholders + `)
+		`
+		if withFiles {
+			query = `
+			SELECT b.hash, COALESCE(b.size_raw, 0), COALESCE(f.id, ''), COALESCE(f.tags, '')
+			FROM blobs b
+			LEFT JOIN files f ON f.blob_id = b.id
+				AND (f.expires_at IS NULL OR f.pinned_at IS NOT NULL OR f.expires_at >= ` + m.currentTimeSQL() + `)
+			WHERE b.state = 'committed' AND b.hash IN (` + placeholders + `)
+			ORDER BY b.hash, f.created_at, f.id
+		`
+		}
+		rows, err := m.db.Query(m.buildQuery(query), args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var hash, fileID, fileTags string
+			var size int64
+			if err := rows.Scan(&hash, &size, &fileID, &fileTags); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			lookup := found[hash]
+			if lookup == nil {
+				lookup = &BlobLookup{Hash: hash, Stored: true, Size: size}
+				if withFiles {
+					lookup.FileIDs = []string{}
+				}
+				found[hash] = lookup
+			}
+			if fileID != "" && (len(tags) == 0 || hasAnyTag(tagsFromJSON(fileTags), tags)) {
+				lookup.FileIDs = append(lookup.FileIDs, fileID)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]BlobLookup, len(hashes))
+	for i, hash := range hashes {
+		if lookup := found[hash]; lookup != nil {
+			result[i] = *lookup
+		} else {
+			result[i] = BlobLookup{Hash: hash}
+			if withFiles {
+				result[i].FileIDs = []string{}
+			}
+		}
+	}
+	return result, nil
+}
+
+// hasAnyTag reports whether tags contain any of want
+func hasAnyTag(tags, want []string) bool {
+	for _, tag := range want {
+		if slices.Contains(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
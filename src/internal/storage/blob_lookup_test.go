@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLookupBlobsByHash(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blobA := createCommittedBlob(t, m, "hashA")
+	blobB := createCommittedBlob(t, m, "hashB")
+	if _, err := m.CreateBlob("hashPending"); err != nil {
+		t.Fatalf("CreateBlob: %v", err)
+	}
+
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	files := []File{
+		{ID: "a1", Name: "a1.txt", BlobID: blobA, CreatedAt: now.Add(-2 * time.Minute), Tags: TagsToJSON([]string{"invoices"})},
+		{ID: "a2", Name: "a2.txt", BlobID: blobA, CreatedAt: now.Add(-time.Minute)},
+		{ID: "a3", Name: "a3.txt", BlobID: blobA, CreatedAt: now, ExpiresAt: &expired, Tags: TagsToJSON([]string{"invoices"})},
+		{ID: "b1", Name: "b1.txt", BlobID: blobB, CreatedAt: now, ExpiresAt: &expired},
+	}
+	for _, f := range files {
+		if err := m.SaveFile(f); err != nil {
+			t.Fatalf("SaveFile(%s): %v", f.ID, err)
+		}
+	}
+
+	hashes := []string{"hashMissing", "hashA", "hashPending", "hashB"}
+	got, err := m.LookupBlobsByHash(hashes, nil, true)
+	if err != nil {
+		t.Fatalf("LookupBlobsByHash: %v", err)
+	}
+	want := []struct {
+		stored  bool
+		fileIDs []string
+	}{
+		{false, []string{}},
+		{true, []string{"a1", "a2"}},
+		{false, []string{}},
+		{true, []string{}}, // jen expirovaný soubor
+	}
+	for i, w := range want {
+		if got[i].Hash != hashes[i] || got[i].Stored != w.stored || !slices.Equal(got[i].FileIDs, w.fileIDs) {
+			t.Errorf("lookup %d = %+v, want stored %v files %v", i, got[i], w.stored, w.fileIDs)
+		}
+	}
+	if got[1].Size != 100 {
+		t.Errorf("size = %d, want 100", got[1].Size)
+	}
+
+	got, err = m.LookupBlobsByHash([]string{"hashA"}, []string{"invoices", "other"}, true)
+	if err != nil {
+		t.Fatalf("LookupBlobsByHash with tags: %v", err)
+	}
+	if !got[0].Stored || !slices.Equal(got[0].FileIDs, []string{"a1"}) {
+		t.Errorf("lookup with tags = %+v, want files [a1]", got[0])
+	}
+
+	// Bez souborů jen stav obsahu, ID souborů jiných klientů se nevydají
+	got, err = m.LookupBlobsByHash([]string{"hashA", "hashMissing"}, nil, false)
+	if err != nil {
+		t.Fatalf("LookupBlobsByHash without files: %v", err)
+	}
+	if !got[0].Stored || got[0].Size != 100 || got[0].FileIDs != nil || got[1].Stored || got[1].FileIDs != nil {
+		t.Errorf("lookup without files = %+v", got)
+	}
+}
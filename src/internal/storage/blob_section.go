@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+)
+
+// BlobSection gives streaming access to a stored blob without loading it into memory.
+// The volume stays read-locked (compaction can't move the blob) until Close or Detach.
+type BlobSection struct {
+	Header    format.Header
+	Data      *io.SectionReader // stored (compressed) data, size taken from the metadata
+	FooterCRC uint32
+	file      *os.File
+	unlock    func()
+}
+
+// Close releases the volume file and its lock
+func (b *BlobSection) Close() error {
+	err := b.file.Close()
+	b.unlock()
+	return err
+}
+
+// Detach releases the volume lock before Close, for readers that may take long (downloads to slow
+// clients). The open file keeps the blob readable: compaction and upgrades replace a volume file by
+// rename or remove it, they never rewrite it in place, so a detached section reads the old file
+// until Close and its disk space is freed only then.
+func (b *BlobSection) Detach() {
+	b.unlock()
+	b.unlock = func() {}
+}
+
+// OpenBlobSection opens the blob at offset with size bytes of stored data. Header and footer are
+// decoded but not compared with the metadata, that is up to the caller (see FileService.VerifyBlob).
+func (s *Store) OpenBlobSection(volumeID, offset, size int64) (*BlobSection, error) {
+	unlock := s.volumeLocks.RLock(volumeID)
+
+	path, err := s.VolumePath(volumeID)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	return openBlobSection(path, offset, size, unlock)
+}
+
+// OpenBlobSectionFile opens the blob in a volume file outside the store, e.g. a copy of the volume
+// on a replica mount or a cold tier. No volume lock is taken.
+func OpenBlobSectionFile(path string, offset, size int64) (*BlobSection, error) {
+	return openBlobSection(path, offset, size, func() {})
+}
+
+func openBlobSection(path string, offset, size int64, unlock func()) (*BlobSection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		unlock()
+		return nil, fmt.Errorf("cannot open volume file %s: %w", path, err)
+	}
+	section := &BlobSection{file: f, unlock: unlock}
+
+	stat, err := f.Stat()
+	if err != nil {
+		section.Close()
+		return nil, fmt.Errorf("cannot stat volume file: %w", err)
+	}
+	end := offset + format.BlobTotalSize(size)
+	if offset < 0 || end > stat.Size() {
+		section.Close()
+		return nil, fmt.Errorf("blob extends beyond file end (offset: %d, size: %d, file size: %d)", offset, size, stat.Size())
+	}
+
+	header := make([]byte, HeaderSize)
+	if _, err := f.ReadAt(header, offset); err != nil {
+		section.Close()
+		return nil, fmt.Errorf("cannot read header at offset %d: %w", offset, err)
+	}
+	if section.Header, err = format.DecodeHeader(header); err != nil {
+		section.Close()
+		return nil, fmt.Errorf("invalid header at offset %d: %w", offset, err)
+	}
+
+	footer := make([]byte, FooterSize)
+	if _, err := f.ReadAt(footer, offset+HeaderSize+size); err != nil {
+		section.Close()
+		return nil, fmt.Errorf("cannot read footer at offset %d: %w", offset+HeaderSize+size, err)
+	}
+	section.FooterCRC, _ = format.DecodeFooter(footer)
+	section.Data = io.NewSectionReader(f, offset+HeaderSize, size)
+	return section, nil
+}
+
+// VerifiedData returns a reader of the stored data that checks the CRC from the footer on the
+// way. The read reaching the end of the data fails instead of returning its bytes when the CRC
+// doesn't match, so a corrupted blob is never delivered whole.
+func (b *BlobSection) VerifiedData() io.Reader {
+	return &crcReader{r: b.Data, remaining: b.Data.Size(), crc: crc32.NewIEEE(), expected: b.FooterCRC}
+}
+
+type crcReader struct {
+	r         io.Reader
+	remaining int64
+	crc       hash.Hash32
+	expected  uint32
+	err       error // sticky CRC mismatch
+}
+
+func (c *crcReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.r.Read(p)
+	c.crc.Write(p[:n])
+	c.remaining -= int64(n)
+	if c.remaining == 0 {
+		if actual := c.crc.Sum32(); actual != c.expected {
+			c.err = fmt.Errorf("CRC mismatch: 0x%08X, footer says 0x%08X", actual, c.expected)
+			return 0, c.err
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+)
+
+func TestBlobSectionVerifiedData(t *testing.T) {
+	store := NewStore(t.TempDir(), 1<<30)
+	data := bytes.Repeat([]byte("cumulus3 "), 10000)
+	volumeID, offset, _, err := store.WriteBlob(1, bytes.NewReader(data), int64(len(data)), format.CompNone)
+	if err != nil {
+		t.Fatalf("WriteBlob: %v", err)
+	}
+
+	section, err := store.OpenBlobSection(volumeID, offset, int64(len(data)))
+	if err != nil {
+		t.Fatalf("OpenBlobSection: %v", err)
+	}
+	got, err := io.ReadAll(section.VerifiedData())
+	section.Close()
+	if err != nil {
+		t.Fatalf("read intact blob: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("read %d bytes, want the %d written", len(got), len(data))
+	}
+
+	// Poškozený poslední bajt dat: čtení selže a poslední blok se nevydá
+	path, err := store.VolumePath(volumeID)
+	if err != nil {
+		t.Fatalf("VolumePath: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("open volume: %v", err)
+	}
+	if _, err := f.WriteAt([]byte{'X'}, offset+HeaderSize+int64(len(data))-1); err != nil {
+		t.Fatalf("corrupt volume: %v", err)
+	}
+	f.Close()
+
+	section, err = store.OpenBlobSection(volumeID, offset, int64(len(data)))
+	if err != nil {
+		t.Fatalf("OpenBlobSection: %v", err)
+	}
+	defer section.Close()
+	got, err = io.ReadAll(section.VerifiedData())
+	if err == nil || !strings.Contains(err.Error(), "CRC mismatch") {
+		t.Fatalf("read corrupted blob: err = %v, want CRC mismatch", err)
+	}
+	if len(got) >= len(data) {
+		t.Errorf("corrupted blob delivered whole (%d bytes)", len(got))
+	}
+}
+
+func TestBlobSectionDetachDuringCompaction(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	store := newManifestTestVolume(t, m, 2)
+	blob, err := m.GetBlob(2)
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	section, err := store.OpenBlobSection(blob.VolumeID, blob.Offset, blob.SizeCompressed)
+	if err != nil {
+		t.Fatalf("OpenBlobSection: %v", err)
+	}
+	defer section.Close()
+	section.Detach()
+
+	// Kompaktace nečeká na odpojenou sekci a přesune blob na začátek volumu
+	if _, err := m.db.Exec(m.buildQuery(`DELETE FROM blobs WHERE hash = ?`), "hash0"); err != nil {
+		t.Fatalf("delete blob: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- store.CompactVolume(blob.VolumeID, m) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("CompactVolume: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CompactVolume waits for a detached section")
+	}
+	if moved, err := m.GetBlob(2); err != nil || moved.Offset != 0 {
+		t.Fatalf("blob after compaction: %+v, %v", moved, err)
+	}
+
+	got, err := io.ReadAll(section.VerifiedData())
+	if err != nil {
+		t.Fatalf("read detached section: %v", err)
+	}
+	if !bytes.Equal(got, bytes.Repeat([]byte{'b'}, 101)) {
+		t.Fatalf("detached section read %q", got)
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ClusterMember is the last known state of another node of the cluster, learned from its own
+// heartbeat or from another node (gossip)
+type ClusterMember struct {
+	Name        string     `json:"name" example:"node-b"`
+	URL         string     `json:"url,omitempty" example:"http://10.0.0.2:8800"`
+	Volumes     string     `json:"volumes" example:"1-4,7"` // volume IDs the node holds blobs in
+	StateAt     time.Time  `json:"stateAt"`                 // when the node produced the state (its clock)
+	HeartbeatAt *time.Time `json:"heartbeatAt,omitempty"`   // last heartbeat answered by the node itself
+	SeenAt      time.Time  `json:"seenAt"`                  // last time this node received a newer state
+	Via         string     `json:"via,omitempty"`           // node the state came through, empty = directly
+	LastError   string     `json:"lastError,omitempty"`     // error of the last heartbeat, empty after success
+}
+
+// SaveClusterMember inserts or replaces the state of a cluster node
+func (m *MetadataSQL) SaveClusterMember(c ClusterMember) error {
+	var heartbeatAt any
+	if c.HeartbeatAt != nil {
+		heartbeatAt = c.HeartbeatAt.UTC()
+	}
+	query := m.buildQuery(`
+		INSERT INTO cluster_nodes (name, url, volumes, state_at, heartbeat_at, seen_at, via, last_error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			url = EXCLUDED.url,
+			volumes = EXCLUDED.volumes,
+			state_at = EXCLUDED.state_at,
+			heartbeat_at = EXCLUDED.heartbeat_at,
+			seen_at = EXCLUDED.seen_at,
+			via = EXCLUDED.via,
+			last_error = EXCLUDED.last_error
+	`)
+	_, err := m.db.Exec(query, c.Name, c.URL, c.Volumes, c.StateAt.UTC(), heartbeatAt, c.SeenAt.UTC(), c.Via, c.LastError)
+	return err
+}
+
+// ListClusterMembers returns the known cluster nodes ordered by name
+func (m *MetadataSQL) ListClusterMembers() ([]ClusterMember, error) {
+	rows, err := m.db.Query(`
+		SELECT name, COALESCE(url, ''), COALESCE(volumes, ''), state_at, heartbeat_at, seen_at, COALESCE(via, ''), COALESCE(last_error, '')
+		FROM cluster_nodes
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []ClusterMember{}
+	for rows.Next() {
+		var c ClusterMember
+		var heartbeatAt sql.NullTime
+		if err := rows.Scan(&c.Name, &c.URL, &c.Volumes, &c.StateAt, &heartbeatAt, &c.SeenAt, &c.Via, &c.LastError); err != nil {
+			return nil, err
+		}
+		if heartbeatAt.Valid {
+			c.HeartbeatAt = &heartbeatAt.Time
+		}
+		members = append(members, c)
+	}
+	return members, rows.Err()
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSaveClusterMember(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	if err := m.SaveClusterMember(ClusterMember{Name: "node-c", Volumes: "1-2", StateAt: now, SeenAt: now, Via: "node-b"}); err != nil {
+		t.Fatalf("SaveClusterMember: %v", err)
+	}
+	if err := m.SaveClusterMember(ClusterMember{Name: "node-b", URL: "http://b:8800", Volumes: "1", StateAt: now, SeenAt: now}); err != nil {
+		t.Fatalf("SaveClusterMember: %v", err)
+	}
+	heartbeat := now.Add(time.Second)
+	if err := m.SaveClusterMember(ClusterMember{Name: "node-b", URL: "http://b:8800", Volumes: "1-3", StateAt: heartbeat, HeartbeatAt: &heartbeat, SeenAt: heartbeat}); err != nil {
+		t.Fatalf("SaveClusterMember update: %v", err)
+	}
+
+	members, err := m.ListClusterMembers()
+	if err != nil {
+		t.Fatalf("ListClusterMembers: %v", err)
+	}
+	if len(members) != 2 || members[0].Name != "node-b" || members[1].Name != "node-c" {
+		t.Fatalf("ListClusterMembers = %+v, want node-b and node-c", members)
+	}
+	b := members[0]
+	if b.Volumes != "1-3" || b.HeartbeatAt == nil || !b.HeartbeatAt.Equal(heartbeat) || !b.StateAt.Equal(heartbeat) {
+		t.Fatalf("node-b = %+v, want the updated state", b)
+	}
+	if c := members[1]; c.HeartbeatAt != nil || c.Via != "node-b" || c.URL != "" {
+		t.Fatalf("node-c = %+v, want a gossiped state without heartbeat", c)
+	}
+}
@@ -3,8 +3,12 @@ package storage
 import (
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
 )
 
 func (s *Store) CompactVolume(volumeID int64, meta *MetadataSQL) error {
@@ -19,9 +23,33 @@ func (s *Store) CompactVolume(volumeID int64, meta *MetadataSQL) error {
 	}
 
 	// Lock the volume exclusively (write lock) - blocks all reads and writes
-	lock := s.getVolumeLock(volumeID)
-	lock.Lock()
-	defer lock.Unlock()
+	unlock := s.volumeLocks.Lock(volumeID)
+	defer unlock()
+
+	// Compaction overwrites size_total and append_offset, batched appends must be written first
+	if err := meta.FlushVolumeAppends(); err != nil {
+		return fmt.Errorf("failed to flush volume appends: %w", err)
+	}
+
+	prevState, err := meta.GetVolumeState(volumeID)
+	if err != nil {
+		return fmt.Errorf("failed to read volume state: %w", err)
+	}
+	if !VolumeStateCompactable(prevState) {
+		return fmt.Errorf("volume %d is %s, compaction not allowed", volumeID, prevState)
+	}
+	if err := meta.SetVolumeState(volumeID, VolumeStateCompacting); err != nil {
+		return fmt.Errorf("failed to mark volume as compacting: %w", err)
+	}
+	removed := false
+	defer func() {
+		if removed {
+			return // volumes row is gone, the ID is free for a new volume
+		}
+		if err := meta.SetVolumeState(volumeID, prevState); err != nil {
+			log.Printf("WARNING: failed to restore state %s of volume %d after compaction: %v", prevState, volumeID, err)
+		}
+	}()
 
 	// 1. Create temporary file
 	filename := fmt.Sprintf("volume_%08d.dat", volumeID)
@@ -79,7 +107,7 @@ func (s *Store) CompactVolume(volumeID int64, meta *MetadataSQL) error {
 
 		// Read blob data
 		// Calculate total size including header/footer
-		blobTotalSize := int64(HeaderSize) + sizeCompressed + int64(FooterSize)
+		blobTotalSize := format.BlobTotalSize(sizeCompressed)
 
 		// Grow buffer if needed
 		if blobTotalSize > int64(len(buffer)) {
@@ -117,6 +145,23 @@ func (s *Store) CompactVolume(volumeID int64, meta *MetadataSQL) error {
 		}
 	}
 
+	// A volume without live blobs is removed entirely (files and volumes row) instead of
+	// being kept as a zero-length file. The current volume stays, the next write goes there.
+	if len(updates) == 0 && !isCurrent {
+		if err := compactionTx.DeleteVolume(volumeID); err != nil {
+			return err
+		}
+		originalFile.Close()
+		compactFile.Close()
+		os.Remove(compactPath)
+		if err := compactionTx.Commit(); err != nil {
+			return err
+		}
+		removed = true
+		s.removeVolumeFiles(volumeID, fullPath)
+		return nil
+	}
+
 	// Update volumes table
 	// set size_deleted = 0, size_total = new_size
 	if err := compactionTx.UpdateVolumeSize(volumeID, currentOffset); err != nil {
@@ -168,3 +213,15 @@ func (s *Store) CompactVolume(volumeID int64, meta *MetadataSQL) error {
 
 	return nil
 }
+
+// removeVolumeFiles deletes the .dat and .meta files of a volume removed from the database.
+// Failures are only logged: a leftover file holds no referenced data.
+func (s *Store) removeVolumeFiles(volumeID int64, datPath string) {
+	metaPath := strings.TrimSuffix(datPath, ".dat") + ".meta"
+	for _, path := range []string{datPath, metaPath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("WARNING: failed to remove %s of empty volume %d: %v", filepath.Base(path), volumeID, err)
+		}
+	}
+	log.Printf("Compaction: volume %d has no blobs left, removed", volumeID)
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+)
+
+func volumeRowExists(t *testing.T, m *MetadataSQL, volumeID int64) bool {
+	t.Helper()
+	var n int
+	if err := m.db.QueryRow(m.buildQuery(`SELECT count(*) FROM volumes WHERE id = ?`), volumeID).Scan(&n); err != nil {
+		t.Fatalf("count volumes: %v", err)
+	}
+	return n > 0
+}
+
+func TestCompactRemovesEmptyVolume(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	// One blob per volume: volumes 1 and 3 lose their blob, volume 2 keeps it, volume 3 is current
+	store := NewStore(t.TempDir(), format.BlobTotalSize(100))
+	paths := map[int64]string{}
+	for i := 1; i <= 3; i++ {
+		data := bytes.Repeat([]byte{byte('a' + i)}, 100)
+		id, err := m.CreateBlob(fmt.Sprintf("hash%d", i))
+		if err != nil {
+			t.Fatalf("CreateBlob: %v", err)
+		}
+		volumeID, offset, _, err := store.WriteBlobWithMetadata(id, bytes.NewReader(data), int64(len(data)), format.CompNone, m)
+		if err != nil {
+			t.Fatalf("WriteBlobWithMetadata: %v", err)
+		}
+		if volumeID != int64(i) {
+			t.Fatalf("blob %d written to volume %d, want %d", i, volumeID, i)
+		}
+		if err := m.UpdateBlobLocation(id, volumeID, offset, int64(len(data)), int64(len(data)), "none", 0); err != nil {
+			t.Fatalf("UpdateBlobLocation: %v", err)
+		}
+		if paths[volumeID], err = store.VolumePath(volumeID); err != nil {
+			t.Fatalf("VolumePath: %v", err)
+		}
+		if _, err := os.Stat(strings.TrimSuffix(paths[volumeID], ".dat") + ".meta"); err != nil {
+			t.Fatalf(".meta of volume %d: %v", volumeID, err)
+		}
+	}
+	if store.CurrentVolumeID != 3 {
+		t.Fatalf("current volume %d, want 3", store.CurrentVolumeID)
+	}
+	for _, hash := range []string{"hash1", "hash3"} {
+		if _, err := m.db.Exec(m.buildQuery(`DELETE FROM blobs WHERE hash = ?`), hash); err != nil {
+			t.Fatalf("delete blob: %v", err)
+		}
+	}
+
+	for volumeID := int64(1); volumeID <= 3; volumeID++ {
+		if err := store.CompactVolume(volumeID, m); err != nil {
+			t.Fatalf("CompactVolume(%d): %v", volumeID, err)
+		}
+	}
+
+	// Volume 1: no live blob, not current – row, .dat and .meta are gone
+	if volumeRowExists(t, m, 1) {
+		t.Error("volumes row of the empty volume 1 kept")
+	}
+	metaPath := strings.TrimSuffix(paths[1], ".dat") + ".meta"
+	for _, path := range []string{paths[1], metaPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s of the empty volume 1 kept: %v", path, err)
+		}
+	}
+
+	// Volume 2: live blob stays readable
+	if !volumeRowExists(t, m, 2) {
+		t.Error("volumes row of volume 2 with a live blob removed")
+	}
+	blob, err := m.GetBlob(2)
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	data, err := store.ReadBlob(blob.VolumeID, blob.Offset, blob.SizeCompressed)
+	if err != nil || !bytes.Equal(data, bytes.Repeat([]byte{'c'}, 100)) {
+		t.Fatalf("ReadBlob after compaction: %q, %v", data, err)
+	}
+
+	// Volume 3: empty but current – kept as a zero-length file for the next write
+	if !volumeRowExists(t, m, 3) {
+		t.Error("volumes row of the current volume 3 removed")
+	}
+	if info, err := os.Stat(paths[3]); err != nil || info.Size() != 0 {
+		t.Errorf("current volume 3 file: %v, %v", info, err)
+	}
+}
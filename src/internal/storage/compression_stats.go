@@ -0,0 +1,41 @@
+package storage
+
+// CompressionCategoryStats sums the committed blobs of one file type category
+type CompressionCategoryStats struct {
+	Category          string
+	Blobs             int64
+	RawBytes          int64
+	StoredBytes       int64
+	UncompressedBlobs int64 // stored with compression "none" (incompressible or below MINIMAL_COMPRESSION)
+}
+
+// GetCompressionStats returns raw and stored sizes of committed blobs per file type category,
+// largest raw size first. Blobs without a file type are reported under "unknown".
+func (m *MetadataSQL) GetCompressionStats() ([]CompressionCategoryStats, error) {
+	rows, err := m.db.Query(`
+		SELECT COALESCE(NULLIF(ft.category, ''), 'unknown') AS category,
+			COUNT(*),
+			COALESCE(SUM(b.size_raw), 0),
+			COALESCE(SUM(b.size_compressed), 0),
+			SUM(CASE WHEN COALESCE(b.compression_alg, '') IN ('', 'none') THEN 1 ELSE 0 END)
+		FROM blobs b
+		LEFT JOIN file_types ft ON ft.id = b.file_type_id
+		WHERE b.state = 'committed'
+		GROUP BY COALESCE(NULLIF(ft.category, ''), 'unknown')
+		ORDER BY COALESCE(SUM(b.size_raw), 0) DESC, category
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []CompressionCategoryStats{}
+	for rows.Next() {
+		var c CompressionCategoryStats
+		if err := rows.Scan(&c.Category, &c.Blobs, &c.RawBytes, &c.StoredBytes, &c.UncompressedBlobs); err != nil {
+			return nil, err
+		}
+		stats = append(stats, c)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,56 @@
+package storage
+
+import "testing"
+
+func TestGetCompressionStats(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	imageType, err := m.GetOrCreateFileType("image/jpeg", "image", "jpeg")
+	if err != nil {
+		t.Fatalf("GetOrCreateFileType: %v", err)
+	}
+	textType, err := m.GetOrCreateFileType("text/plain", "text", "plain")
+	if err != nil {
+		t.Fatalf("GetOrCreateFileType: %v", err)
+	}
+	blobs := []struct {
+		hash        string
+		raw, stored int64
+		alg         string
+		fileTypeID  int64
+	}{
+		{"jpeg1", 1000, 1000, "none", imageType},
+		{"jpeg2", 3000, 2900, "zstd", imageType},
+		{"text1", 500, 100, "zstd", textType},
+		{"untyped", 10, 10, "none", 0},
+	}
+	for _, b := range blobs {
+		id, err := m.CreateBlob(b.hash)
+		if err != nil {
+			t.Fatalf("CreateBlob: %v", err)
+		}
+		if err := m.UpdateBlobLocation(id, 1, 0, b.raw, b.stored, b.alg, b.fileTypeID); err != nil {
+			t.Fatalf("UpdateBlobLocation: %v", err)
+		}
+	}
+	if _, err := m.CreateBlob("pending"); err != nil {
+		t.Fatalf("CreateBlob: %v", err)
+	}
+
+	stats, err := m.GetCompressionStats()
+	if err != nil {
+		t.Fatalf("GetCompressionStats: %v", err)
+	}
+	want := []CompressionCategoryStats{
+		{Category: "image", Blobs: 2, RawBytes: 4000, StoredBytes: 3900, UncompressedBlobs: 1},
+		{Category: "text", Blobs: 1, RawBytes: 500, StoredBytes: 100},
+		{Category: "unknown", Blobs: 1, RawBytes: 10, StoredBytes: 10, UncompressedBlobs: 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("GetCompressionStats = %+v, want %+v", stats, want)
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("category %d = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+// consistencyReportsKept is the number of stored consistency reports; older ones are pruned
+const consistencyReportsKept = 30
+
+// VolumeReconciliation compares the volumes known to the database with the volume files on disk
+type VolumeReconciliation struct {
+	Volumes        int     `json:"volumes"`        // volumes known to the database (volumes table or blobs)
+	Files          int     `json:"files"`          // volume files on disk
+	MissingFiles   []int64 `json:"missingFiles"`   // known volumes without a .dat file
+	TruncatedFiles []int64 `json:"truncatedFiles"` // .dat file shorter than the end of its last committed blob
+	UntrackedFiles []int64 `json:"untrackedFiles"` // .dat files unknown to the database
+}
+
+// ReconcileVolumes compares the database with the volume files on disk. Unlike SyncVolumeStates
+// it only reports, no volume state is changed, so it is safe to run while the server is busy.
+func (s *Store) ReconcileVolumes(meta *MetadataSQL) (VolumeReconciliation, error) {
+	r := VolumeReconciliation{MissingFiles: []int64{}, TruncatedFiles: []int64{}, UntrackedFiles: []int64{}}
+
+	volumes, err := meta.GetVolumesToCompact(0)
+	if err != nil {
+		return r, err
+	}
+	ends, err := meta.GetVolumeDataEnds()
+	if err != nil {
+		return r, err
+	}
+	files, err := scanVolumeFiles(s.BaseDir)
+	if err != nil {
+		return r, err
+	}
+	r.Files = len(files)
+
+	known := make(map[int64]bool, len(volumes))
+	for _, vol := range volumes {
+		known[int64(vol.ID)] = true
+	}
+	for id := range ends {
+		known[id] = true
+	}
+	r.Volumes = len(known)
+
+	for id := range known {
+		path, exists := files[id]
+		if !exists {
+			r.MissingFiles = append(r.MissingFiles, id)
+			continue
+		}
+		end, hasBlobs := ends[id]
+		if !hasBlobs {
+			continue
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			return r, err
+		}
+		if stat.Size() < end {
+			r.TruncatedFiles = append(r.TruncatedFiles, id)
+		}
+	}
+	for id := range files {
+		if !known[id] {
+			r.UntrackedFiles = append(r.UntrackedFiles, id)
+		}
+	}
+
+	for _, ids := range [][]int64{r.MissingFiles, r.TruncatedFiles, r.UntrackedFiles} {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	return r, nil
+}
+
+// GetVolumeDataEnds returns, per volume, the offset where its last committed blob (with footer) ends
+func (m *MetadataSQL) GetVolumeDataEnds() (map[int64]int64, error) {
+	rows, err := m.db.Query(`
+		SELECT volume_id, MAX(blob_offset + size_compressed)
+		FROM blobs
+		WHERE state = 'committed' AND volume_id > 0
+		GROUP BY volume_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ends := make(map[int64]int64)
+	for rows.Next() {
+		var id, end int64
+		if err := rows.Scan(&id, &end); err != nil {
+			return nil, err
+		}
+		ends[id] = end + HeaderSize + FooterSize
+	}
+	return ends, rows.Err()
+}
+
+// CountStalePendingBlobs counts pending blobs older than maxAge that no file references
+// (zombies left by crashed uploads, see CleanupStalePendingBlobs)
+func (m *MetadataSQL) CountStalePendingBlobs(maxAge time.Duration) (int64, error) {
+	var count int64
+	query := m.buildQuery(`
+		SELECT COUNT(*)
+		FROM blobs b
+		WHERE b.state = 'pending'
+		  AND (b.write_started_at IS NULL OR b.write_started_at < ?)
+		  AND NOT EXISTS (SELECT 1 FROM files f WHERE f.blob_id = b.id)
+	`)
+	err := m.db.QueryRow(query, time.Now().UTC().Add(-maxAge)).Scan(&count)
+	return count, err
+}
+
+// SaveConsistencyReport stores a consistency report (JSON) and prunes old reports
+func (m *MetadataSQL) SaveConsistencyReport(createdAt time.Time, status, report string) error {
+	query := m.buildQuery(`INSERT INTO consistency_reports (created_at, status, report) VALUES (?, ?, ?)`)
+	if _, err := m.db.Exec(query, createdAt, status, report); err != nil {
+		return err
+	}
+	query = m.buildQuery(`
+		DELETE FROM consistency_reports
+		WHERE id NOT IN (SELECT id FROM consistency_reports ORDER BY id DESC LIMIT ?)
+	`)
+	_, err := m.db.Exec(query, consistencyReportsKept)
+	return err
+}
+
+// GetLatestConsistencyReport returns the most recent stored report (JSON), sql.ErrNoRows if none
+func (m *MetadataSQL) GetLatestConsistencyReport() (string, error) {
+	var report string
+	err := m.db.QueryRow(`SELECT report FROM consistency_reports ORDER BY id DESC LIMIT 1`).Scan(&report)
+	return report, err
+}
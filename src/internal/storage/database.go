@@ -3,12 +3,15 @@ package storage
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
 )
 
 type File struct {
@@ -19,6 +22,8 @@ type File struct {
 	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
 	CreatedAt    time.Time  `json:"created_at"`
 	Tags         string     `json:"tags,omitempty"`
+	Disposition  string     `json:"disposition,omitempty"` // inline/attachment, "" = by MIME type
+	PinnedAt     *time.Time `json:"pinned_at,omitempty"`   // pinned files are never removed by expiry cleanup
 }
 
 type Blob struct {
@@ -45,17 +50,48 @@ type VolumeInfo struct {
 	ID          int
 	SizeTotal   int64
 	SizeDeleted int64
+	State       string // see VolumeState* constants
 }
 
 type MetadataSQL struct {
-	db     *sql.DB
-	dbType string // "sqlite" or "postgresql"
+	db         *queryDB   // connection pool, see SetSlowQueryLog
+	dbType     string     // "sqlite" or "postgresql"
+	txObserver TxObserver // see SetTxObserver
+
+	appendBatch *volumeAppendBatch // nil = volume appends are written immediately
 }
 
 // NewMetadataSQL initializes database connection based on type
 // dbType: "sqlite" or "postgresql"
 // dsn: connection string (DSN for SQLite, connection URL for PostgreSQL)
 func NewMetadataSQL(dbType, dsn string) (*MetadataSQL, error) {
+	metaSQL, err := openMetadataSQL(dbType, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := metaSQL.initSchema(); err != nil {
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+	return metaSQL, nil
+}
+
+// NewMetadataSQLReadOnly opens an existing database without creating or migrating the schema, for
+// read-only replicas serving a snapshot (READ_ONLY). The database must already contain the schema;
+// the caller makes the connection itself read-only (SQLite _query_only, PostgreSQL hot standby).
+func NewMetadataSQLReadOnly(dbType, dsn string) (*MetadataSQL, error) {
+	metaSQL, err := openMetadataSQL(dbType, dsn)
+	if err != nil {
+		return nil, err
+	}
+	var files int64
+	if err := metaSQL.db.QueryRow("SELECT COUNT(*) FROM files WHERE 1 = 0").Scan(&files); err != nil {
+		metaSQL.Close()
+		return nil, fmt.Errorf("database has no Cumulus3 schema: %w", err)
+	}
+	return metaSQL, nil
+}
+
+func openMetadataSQL(dbType, dsn string) (*MetadataSQL, error) {
 	var db *sql.DB
 	var err error
 
@@ -84,13 +120,7 @@ func NewMetadataSQL(dbType, dsn string) (*MetadataSQL, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	metaSQL := &MetadataSQL{db: db, dbType: dbType}
-
-	if err := metaSQL.initSchema(); err != nil {
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
-	}
-
-	return metaSQL, nil
+	return &MetadataSQL{db: &queryDB{DB: db}, dbType: dbType}, nil
 }
 
 func (m *MetadataSQL) initSchema() error {
@@ -142,12 +172,19 @@ func (m *MetadataSQL) initSQLiteSchema() error {
 			state TEXT DEFAULT 'pending',
 			write_owner TEXT,
 			write_started_at DATETIME,
+			ref_claims INTEGER DEFAULT 0,
+			ref_claimed_at DATETIME,
 			volume_id INTEGER,
 			blob_offset INTEGER,
 			size_raw INTEGER,
 			size_compressed INTEGER,
 			compression_alg TEXT,
 			file_type_id INTEGER,
+			image_width INTEGER,
+			image_height INTEGER,
+			image_orientation INTEGER,
+			image_blurhash TEXT,
+			image_color TEXT,
 			FOREIGN KEY(file_type_id) REFERENCES file_types(id)
 		);`,
 		`CREATE TABLE IF NOT EXISTS files (
@@ -158,17 +195,78 @@ func (m *MetadataSQL) initSQLiteSchema() error {
 			expires_at DATETIME,
 			created_at DATETIME,
 			tags TEXT,
+			disposition TEXT,
+			pinned_at DATETIME,
+			type_mismatch TEXT,
 			FOREIGN KEY(blob_id) REFERENCES blobs(id)
 		);`,
 		`CREATE TABLE IF NOT EXISTS volumes (
 			id INTEGER PRIMARY KEY,
 			size_total INTEGER DEFAULT 0,
-			size_deleted INTEGER DEFAULT 0
+			size_deleted INTEGER DEFAULT 0,
+			state TEXT DEFAULT 'open',
+			append_offset INTEGER
 		);`,
 		`CREATE TABLE IF NOT EXISTS old_id_counter (
 			id INTEGER PRIMARY KEY CHECK (id = 1),
 			next_id INTEGER NOT NULL
 		);`,
+		`CREATE TABLE IF NOT EXISTS api_key_usage (
+			month TEXT NOT NULL,
+			client TEXT NOT NULL,
+			requests INTEGER DEFAULT 0,
+			bytes_in INTEGER DEFAULT 0,
+			bytes_out INTEGER DEFAULT 0,
+			errors INTEGER DEFAULT 0,
+			PRIMARY KEY(month, client)
+		);`,
+		`CREATE TABLE IF NOT EXISTS consistency_reports (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			created_at DATETIME,
+			status TEXT,
+			report TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS storage_stats_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			created_at DATETIME,
+			dir TEXT,
+			path TEXT,
+			total_bytes INTEGER,
+			free_bytes INTEGER
+		);`,
+		`CREATE TABLE IF NOT EXISTS blob_heal (
+			blob_id INTEGER PRIMARY KEY,
+			volume_id INTEGER,
+			source TEXT,
+			error TEXT,
+			hits INTEGER DEFAULT 0,
+			first_seen_at DATETIME,
+			last_seen_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS cluster_nodes (
+			name TEXT PRIMARY KEY,
+			url TEXT,
+			volumes TEXT,
+			state_at DATETIME,
+			heartbeat_at DATETIME,
+			seen_at DATETIME,
+			via TEXT,
+			last_error TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS legal_holds (
+			file_id TEXT PRIMARY KEY,
+			reason TEXT,
+			held_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			key_hash TEXT NOT NULL UNIQUE,
+			prefix TEXT,
+			created_at DATETIME NOT NULL,
+			revoked_at DATETIME
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_storage_stats_history_created_at ON storage_stats_history(created_at);`,
 		`CREATE INDEX IF NOT EXISTS idx_files_expires_at ON files(expires_at);`,
 		`CREATE INDEX IF NOT EXISTS idx_files_old_cumulus_id ON files(old_cumulus_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_files_blob_id ON files(blob_id);`,
@@ -186,9 +284,21 @@ func (m *MetadataSQL) initSQLiteSchema() error {
 
 	// Migration: Add tags column if not exists
 	_, _ = m.db.Exec("ALTER TABLE files ADD COLUMN tags TEXT")
+	_, _ = m.db.Exec("ALTER TABLE files ADD COLUMN disposition TEXT")
+	_, _ = m.db.Exec("ALTER TABLE files ADD COLUMN pinned_at DATETIME")
+	_, _ = m.db.Exec("ALTER TABLE files ADD COLUMN type_mismatch TEXT")
 	_, _ = m.db.Exec("ALTER TABLE blobs ADD COLUMN state TEXT")
 	_, _ = m.db.Exec("ALTER TABLE blobs ADD COLUMN write_owner TEXT")
 	_, _ = m.db.Exec("ALTER TABLE blobs ADD COLUMN write_started_at DATETIME")
+	_, _ = m.db.Exec("ALTER TABLE blobs ADD COLUMN ref_claims INTEGER DEFAULT 0")
+	_, _ = m.db.Exec("ALTER TABLE blobs ADD COLUMN ref_claimed_at DATETIME")
+	_, _ = m.db.Exec("ALTER TABLE blobs ADD COLUMN image_width INTEGER")
+	_, _ = m.db.Exec("ALTER TABLE blobs ADD COLUMN image_height INTEGER")
+	_, _ = m.db.Exec("ALTER TABLE blobs ADD COLUMN image_orientation INTEGER")
+	_, _ = m.db.Exec("ALTER TABLE blobs ADD COLUMN image_blurhash TEXT")
+	_, _ = m.db.Exec("ALTER TABLE blobs ADD COLUMN image_color TEXT")
+	_, _ = m.db.Exec("ALTER TABLE volumes ADD COLUMN state TEXT DEFAULT 'open'")
+	_, _ = m.db.Exec("ALTER TABLE volumes ADD COLUMN append_offset INTEGER")
 	_, _ = m.db.Exec("UPDATE blobs SET state = CASE WHEN COALESCE(volume_id, 0) > 0 THEN 'committed' ELSE 'pending' END WHERE state IS NULL OR state = ''")
 
 	// Migration: ensure blob_offset column exists on legacy databases
@@ -275,12 +385,19 @@ func (m *MetadataSQL) initPostgreSQLSchema() error {
 			state VARCHAR(20) DEFAULT 'pending',
 			write_owner VARCHAR(64),
 			write_started_at TIMESTAMP,
+			ref_claims INTEGER DEFAULT 0,
+			ref_claimed_at TIMESTAMP,
 			volume_id BIGINT,
 			blob_offset BIGINT,
 			size_raw BIGINT,
 			size_compressed BIGINT,
 			compression_alg VARCHAR(50),
 			file_type_id BIGINT,
+			image_width INTEGER,
+			image_height INTEGER,
+			image_orientation SMALLINT,
+			image_blurhash VARCHAR(64),
+			image_color VARCHAR(7),
 			FOREIGN KEY(file_type_id) REFERENCES file_types(id)
 		);`,
 		`CREATE TABLE IF NOT EXISTS files (
@@ -291,17 +408,78 @@ func (m *MetadataSQL) initPostgreSQLSchema() error {
 			expires_at TIMESTAMP,
 			created_at TIMESTAMP,
 			tags TEXT,
+			disposition VARCHAR(20),
+			pinned_at TIMESTAMP,
+			type_mismatch TEXT,
 			FOREIGN KEY(blob_id) REFERENCES blobs(id)
 		);`,
 		`CREATE TABLE IF NOT EXISTS volumes (
 			id BIGSERIAL PRIMARY KEY,
 			size_total BIGINT DEFAULT 0,
-			size_deleted BIGINT DEFAULT 0
+			size_deleted BIGINT DEFAULT 0,
+			state VARCHAR(20) DEFAULT 'open',
+			append_offset BIGINT
 		);`,
 		`CREATE TABLE IF NOT EXISTS old_id_counter (
 			id SMALLINT PRIMARY KEY,
 			next_id BIGINT NOT NULL
 		);`,
+		`CREATE TABLE IF NOT EXISTS api_key_usage (
+			month VARCHAR(7) NOT NULL,
+			client VARCHAR(255) NOT NULL,
+			requests BIGINT DEFAULT 0,
+			bytes_in BIGINT DEFAULT 0,
+			bytes_out BIGINT DEFAULT 0,
+			errors BIGINT DEFAULT 0,
+			PRIMARY KEY(month, client)
+		);`,
+		`CREATE TABLE IF NOT EXISTS consistency_reports (
+			id BIGSERIAL PRIMARY KEY,
+			created_at TIMESTAMP,
+			status VARCHAR(20),
+			report TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS storage_stats_history (
+			id BIGSERIAL PRIMARY KEY,
+			created_at TIMESTAMP,
+			dir VARCHAR(64),
+			path TEXT,
+			total_bytes BIGINT,
+			free_bytes BIGINT
+		);`,
+		`CREATE TABLE IF NOT EXISTS blob_heal (
+			blob_id BIGINT PRIMARY KEY,
+			volume_id BIGINT,
+			source VARCHAR(20),
+			error TEXT,
+			hits BIGINT DEFAULT 0,
+			first_seen_at TIMESTAMP,
+			last_seen_at TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS cluster_nodes (
+			name VARCHAR(255) PRIMARY KEY,
+			url TEXT,
+			volumes TEXT,
+			state_at TIMESTAMP,
+			heartbeat_at TIMESTAMP,
+			seen_at TIMESTAMP,
+			via VARCHAR(255),
+			last_error TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS legal_holds (
+			file_id VARCHAR(255) PRIMARY KEY,
+			reason TEXT,
+			held_at TIMESTAMP NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id BIGSERIAL PRIMARY KEY,
+			name VARCHAR(64) NOT NULL UNIQUE,
+			key_hash VARCHAR(64) NOT NULL UNIQUE,
+			prefix VARCHAR(16),
+			created_at TIMESTAMP NOT NULL,
+			revoked_at TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_storage_stats_history_created_at ON storage_stats_history(created_at);`,
 		`CREATE INDEX IF NOT EXISTS idx_files_expires_at ON files(expires_at);`,
 		`CREATE INDEX IF NOT EXISTS idx_files_old_cumulus_id ON files(old_cumulus_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_files_blob_id ON files(blob_id);`,
@@ -310,6 +488,23 @@ func (m *MetadataSQL) initPostgreSQLSchema() error {
 		`CREATE INDEX IF NOT EXISTS idx_blobs_volume_id ON blobs(volume_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_blobs_volume_offset ON blobs(volume_id, blob_offset);`,
 		`CREATE INDEX IF NOT EXISTS idx_blobs_id ON blobs(id);`,
+		// files.tags jako jsonb pole; neplatný JSON (legacy CSV, poškozená hodnota) dá prázdné pole místo chyby dotazu
+		`CREATE OR REPLACE FUNCTION cumulus_tags_array(value TEXT) RETURNS jsonb AS $$
+		DECLARE
+			parsed jsonb;
+		BEGIN
+			IF value NOT LIKE '[%' THEN
+				RETURN '[]'::jsonb;
+			END IF;
+			parsed := value::jsonb;
+			IF jsonb_typeof(parsed) <> 'array' THEN
+				RETURN '[]'::jsonb;
+			END IF;
+			RETURN parsed;
+		EXCEPTION WHEN others THEN
+			RETURN '[]'::jsonb;
+		END;
+		$$ LANGUAGE plpgsql IMMUTABLE STRICT;`,
 	}
 
 	for _, query := range queries {
@@ -330,9 +525,21 @@ func (m *MetadataSQL) initPostgreSQLSchema() error {
 			END IF;
 		END $$;
 	`)
+	_, _ = m.db.Exec(`ALTER TABLE files ADD COLUMN IF NOT EXISTS disposition VARCHAR(20)`)
+	_, _ = m.db.Exec(`ALTER TABLE files ADD COLUMN IF NOT EXISTS pinned_at TIMESTAMP`)
+	_, _ = m.db.Exec(`ALTER TABLE files ADD COLUMN IF NOT EXISTS type_mismatch TEXT`)
 	_, _ = m.db.Exec(`ALTER TABLE blobs ADD COLUMN IF NOT EXISTS state VARCHAR(20)`)
 	_, _ = m.db.Exec(`ALTER TABLE blobs ADD COLUMN IF NOT EXISTS write_owner VARCHAR(64)`)
 	_, _ = m.db.Exec(`ALTER TABLE blobs ADD COLUMN IF NOT EXISTS write_started_at TIMESTAMP`)
+	_, _ = m.db.Exec(`ALTER TABLE blobs ADD COLUMN IF NOT EXISTS ref_claims INTEGER DEFAULT 0`)
+	_, _ = m.db.Exec(`ALTER TABLE blobs ADD COLUMN IF NOT EXISTS ref_claimed_at TIMESTAMP`)
+	_, _ = m.db.Exec(`ALTER TABLE blobs ADD COLUMN IF NOT EXISTS image_width INTEGER`)
+	_, _ = m.db.Exec(`ALTER TABLE blobs ADD COLUMN IF NOT EXISTS image_height INTEGER`)
+	_, _ = m.db.Exec(`ALTER TABLE blobs ADD COLUMN IF NOT EXISTS image_orientation SMALLINT`)
+	_, _ = m.db.Exec(`ALTER TABLE blobs ADD COLUMN IF NOT EXISTS image_blurhash VARCHAR(64)`)
+	_, _ = m.db.Exec(`ALTER TABLE blobs ADD COLUMN IF NOT EXISTS image_color VARCHAR(7)`)
+	_, _ = m.db.Exec(`ALTER TABLE volumes ADD COLUMN IF NOT EXISTS state VARCHAR(20) DEFAULT 'open'`)
+	_, _ = m.db.Exec(`ALTER TABLE volumes ADD COLUMN IF NOT EXISTS append_offset BIGINT`)
 	_, _ = m.db.Exec(`UPDATE blobs SET state = CASE WHEN COALESCE(volume_id, 0) > 0 THEN 'committed' ELSE 'pending' END WHERE state IS NULL OR state = ''`)
 	// Migration: rename reserved column name offset -> blob_offset if needed
 	_, _ = m.db.Exec(`
@@ -413,10 +620,30 @@ func (m *MetadataSQL) ensureUniqueOldCumulusIDIndex() error {
 	return nil
 }
 
+// PoolStats returns the statistics of the database connection pool; with SQLite (one
+// connection) WaitDuration is the time queries spent waiting for the database
+func (m *MetadataSQL) PoolStats() sql.DBStats {
+	return m.db.Stats()
+}
+
 func (m *MetadataSQL) Close() error {
+	if err := m.FlushVolumeAppends(); err != nil {
+		log.Printf("WARNING: failed to flush volume appends on close: %v", err)
+	}
 	return m.db.Close()
 }
 
+// BackupSQLite writes a consistent copy of the SQLite database to path (VACUUM INTO, concurrent
+// writes wait only for the copy of their pages). path must not exist. PostgreSQL is backed up
+// with its own tools.
+func (m *MetadataSQL) BackupSQLite(path string) error {
+	if m.dbType != "sqlite" {
+		return fmt.Errorf("database backup is only available for SQLite")
+	}
+	_, err := m.db.Exec("VACUUM INTO ?", path)
+	return err
+}
+
 // currentTimeSQL returns the appropriate SQL expression for current time based on database type
 func (m *MetadataSQL) currentTimeSQL() string {
 	if m.dbType == "postgresql" {
@@ -505,15 +732,15 @@ func TagsFromJSON(raw string) []string { return tagsFromJSON(raw) }
 
 func (m *MetadataSQL) SaveFile(file File) error {
 	query := m.buildQuery(`
-		INSERT INTO files (id, name, blob_id, old_cumulus_id, expires_at, created_at, tags)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO files (id, name, blob_id, old_cumulus_id, expires_at, created_at, tags, disposition)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`)
-	_, err := m.db.Exec(query, file.ID, file.Name, file.BlobID, file.OldCumulusID, file.ExpiresAt, file.CreatedAt, file.Tags)
+	_, err := m.db.Exec(query, file.ID, file.Name, file.BlobID, file.OldCumulusID, file.ExpiresAt, file.CreatedAt, file.Tags, file.Disposition)
 	return err
 }
 
 func (m *MetadataSQL) CleanupExpiredFiles() (int64, error) {
-	query := fmt.Sprintf("DELETE FROM files WHERE expires_at < %s", m.currentTimeSQL())
+	query := fmt.Sprintf("DELETE FROM files WHERE expires_at < %s AND pinned_at IS NULL AND id NOT IN (SELECT file_id FROM legal_holds)", m.currentTimeSQL())
 	res, err := m.db.Exec(query)
 	if err != nil {
 		return 0, err
@@ -530,6 +757,8 @@ func (m *MetadataSQL) GetExpiredTemporaryFiles() ([]string, int, error) {
 		FROM files
 		WHERE expires_at IS NOT NULL
 			AND expires_at < %s
+			AND pinned_at IS NULL
+			AND id NOT IN (SELECT file_id FROM legal_holds)
 	`, m.currentTimeSQL())
 
 	rows, err := m.db.Query(query)
@@ -655,13 +884,13 @@ func (m *MetadataSQL) CreateBlobWithID(id int64, hash string) error {
 
 // GetDB returns the underlying database connection (for advanced operations)
 func (m *MetadataSQL) GetDB() *sql.DB {
-	return m.db
+	return m.db.DB
 }
 
 func (m *MetadataSQL) GetFile(id string) (File, error) {
 	var f File
-	query := m.buildQuery(`SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags FROM files WHERE id = ?`)
-	err := m.db.QueryRow(query, id).Scan(&f.ID, &f.Name, &f.BlobID, &f.OldCumulusID, &f.ExpiresAt, &f.CreatedAt, &f.Tags)
+	query := m.buildQuery(`SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags, COALESCE(disposition, ''), pinned_at FROM files WHERE id = ?`)
+	err := m.db.QueryRow(query, id).Scan(&f.ID, &f.Name, &f.BlobID, &f.OldCumulusID, &f.ExpiresAt, &f.CreatedAt, &f.Tags, &f.Disposition, &f.PinnedAt)
 	if err != nil {
 		return File{}, err
 	}
@@ -678,6 +907,40 @@ func (m *MetadataSQL) GetBlob(id int64) (Blob, error) {
 	return b, nil
 }
 
+// CountFilesByBlob returns the number of file records referencing the blob (deduplicated copies)
+func (m *MetadataSQL) CountFilesByBlob(blobID int64) (int64, error) {
+	var count int64
+	err := m.db.QueryRow(m.buildQuery(`SELECT count(*) FROM files WHERE blob_id = ?`), blobID).Scan(&count)
+	return count, err
+}
+
+// ListVolumeBlobs returns committed blobs stored in the volume with ID greater than afterID,
+// ordered by ID (paging for DrainVolume)
+func (m *MetadataSQL) ListVolumeBlobs(volumeID, afterID int64, limit int) ([]Blob, error) {
+	query := m.buildQuery(`
+		SELECT id, hash, state, COALESCE(write_owner, ''), volume_id, blob_offset, COALESCE(size_raw, 0), COALESCE(size_compressed, 0), COALESCE(compression_alg, ''), COALESCE(file_type_id, 0)
+		FROM blobs
+		WHERE volume_id = ? AND state = 'committed' AND id > ?
+		ORDER BY id
+		LIMIT ?
+	`)
+	rows, err := m.db.Query(query, volumeID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blobs []Blob
+	for rows.Next() {
+		var b Blob
+		if err := rows.Scan(&b.ID, &b.Hash, &b.State, &b.WriteOwner, &b.VolumeID, &b.Offset, &b.SizeRaw, &b.SizeCompressed, &b.CompressionAlg, &b.FileTypeID); err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, b)
+	}
+	return blobs, rows.Err()
+}
+
 func (m *MetadataSQL) GetFileType(id int64) (FileType, error) {
 	var ft FileType
 	query := m.buildQuery(`SELECT id, mime_type, category, subtype FROM file_types WHERE id = ?`)
@@ -710,6 +973,50 @@ func (m *MetadataSQL) UpdateBlobLocation(id int64, volumeID, offset, sizeRaw, si
 	return tx.Commit()
 }
 
+// ErrBlobMoved is returned by RelocateBlob when the blob is no longer at the expected location
+// (moved by compaction or freed in the meantime)
+var ErrBlobMoved = errors.New("blob location changed concurrently")
+
+// BlobRelocation describes a copy of a committed blob written to a new location
+type BlobRelocation struct {
+	BlobID                   int64
+	FromVolumeID, FromOffset int64
+	FromSize                 int64 // stored data size of the old copy
+	ToVolumeID, ToOffset     int64
+	ToSize                   int64
+	CompressionAlg           string
+}
+
+// RelocateBlob points the blob to its new copy and accounts the old copy as deleted space,
+// in one transaction. The update only applies while the blob is still at the old location.
+func (m *MetadataSQL) RelocateBlob(r BlobRelocation) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := m.buildQuery(`
+	UPDATE blobs SET volume_id = ?, blob_offset = ?, size_compressed = ?, compression_alg = ?
+	WHERE id = ? AND volume_id = ? AND blob_offset = ? AND state = 'committed'
+	`)
+	res, err := tx.Exec(query, r.ToVolumeID, r.ToOffset, r.ToSize, r.CompressionAlg, r.BlobID, r.FromVolumeID, r.FromOffset)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrBlobMoved
+	}
+
+	query = m.buildQuery(`UPDATE volumes SET size_deleted = size_deleted + ? WHERE id = ?`)
+	if _, err := tx.Exec(query, format.BlobTotalSize(r.FromSize), r.FromVolumeID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (m *MetadataSQL) EnsureOldCumulusIDAtLeast(oldID int64) error {
 	if oldID <= 0 {
 		return nil
@@ -797,10 +1104,29 @@ func (m *MetadataSQL) FileExistsByOldID(oldID int64) (bool, error) {
 	return count > 0, nil
 }
 
+// ClearOldCumulusID removes old_cumulus_id from the file currently holding it, so the ID can be
+// mapped to another file. Returns the ID of that file, sql.ErrNoRows when the ID is not assigned.
+func (m *MetadataSQL) ClearOldCumulusID(oldID int64) (string, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var fileID string
+	if err := tx.QueryRow(m.buildQuery(`SELECT id FROM files WHERE old_cumulus_id = ?`), oldID).Scan(&fileID); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(m.buildQuery(`UPDATE files SET old_cumulus_id = NULL WHERE id = ?`), fileID); err != nil {
+		return "", err
+	}
+	return fileID, tx.Commit()
+}
+
 func (m *MetadataSQL) GetFileByOldID(oldID int64) (File, error) {
 	var f File
-	query := m.buildQuery(`SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags FROM files WHERE old_cumulus_id = ?`)
-	err := m.db.QueryRow(query, oldID).Scan(&f.ID, &f.Name, &f.BlobID, &f.OldCumulusID, &f.ExpiresAt, &f.CreatedAt, &f.Tags)
+	query := m.buildQuery(`SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags, COALESCE(disposition, ''), pinned_at FROM files WHERE old_cumulus_id = ?`)
+	err := m.db.QueryRow(query, oldID).Scan(&f.ID, &f.Name, &f.BlobID, &f.OldCumulusID, &f.ExpiresAt, &f.CreatedAt, &f.Tags, &f.Disposition, &f.PinnedAt)
 	if err != nil {
 		return File{}, err
 	}
@@ -808,6 +1134,54 @@ func (m *MetadataSQL) GetFileByOldID(oldID int64) (File, error) {
 }
 
 // GetMaxOldCumulusID returns the current maximum old_cumulus_id from the files table, or 0 if no rows exist.
+// OldIDEntry is the result of a bulk old-ID lookup.
+type OldIDEntry struct {
+	FileID string
+	Hash   string
+	Size   int64
+}
+
+// GetFilesByOldIDs returns the existing files for the given old Cumulus IDs, keyed by old ID.
+// IDs without a file are simply missing from the result.
+func (m *MetadataSQL) GetFilesByOldIDs(oldIDs []int64) (map[int64]OldIDEntry, error) {
+	const chunkSize = 500
+	result := make(map[int64]OldIDEntry, len(oldIDs))
+
+	for start := 0; start < len(oldIDs); start += chunkSize {
+		chunk := oldIDs[start:min(start+chunkSize, len(oldIDs))]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+		args := make([]any, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+
+		query := m.buildQuery(`
+			SELECT f.old_cumulus_id, f.id, COALESCE(b.hash, ''), COALESCE(b.size_raw, 0)
+			FROM files f
+			LEFT JOIN blobs b ON b.id = f.blob_id
+			WHERE f.old_cumulus_id IN (` + placeholders + `)
+		`)
+		rows, err := m.db.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var oldID int64
+			var e OldIDEntry
+			if err := rows.Scan(&oldID, &e.FileID, &e.Hash, &e.Size); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			result[oldID] = e
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
 func (m *MetadataSQL) GetMaxOldCumulusID() (int64, error) {
 	var maxID int64
 	err := m.db.QueryRow("SELECT COALESCE(MAX(old_cumulus_id), 0) FROM files").Scan(&maxID)
@@ -822,12 +1196,12 @@ func (m *MetadataSQL) FindFileByBlobNameAndExpiry(blobID int64, filename string,
 		expAt = *expiresAt
 	}
 
-	query := m.buildQuery(`SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags
+	query := m.buildQuery(`SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags, COALESCE(disposition, ''), pinned_at
 					FROM files
 					WHERE blob_id = ? AND name = ? AND expires_at IS ?
 					LIMIT 1`)
 	if m.dbType == "postgresql" {
-		query = m.buildQuery(`SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags
+		query = m.buildQuery(`SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags, COALESCE(disposition, ''), pinned_at
 					FROM files
 					WHERE blob_id = ? AND name = ? AND expires_at IS NOT DISTINCT FROM ?
 					LIMIT 1`)
@@ -835,7 +1209,7 @@ func (m *MetadataSQL) FindFileByBlobNameAndExpiry(blobID int64, filename string,
 
 	var f File
 	err := m.db.QueryRow(query, blobID, filename, expAt).Scan(
-		&f.ID, &f.Name, &f.BlobID, &f.OldCumulusID, &f.ExpiresAt, &f.CreatedAt, &f.Tags)
+		&f.ID, &f.Name, &f.BlobID, &f.OldCumulusID, &f.ExpiresAt, &f.CreatedAt, &f.Tags, &f.Disposition, &f.PinnedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -857,12 +1231,12 @@ func (m *MetadataSQL) FindFileByBlobAndName(blobID int64, filename string, oldCu
 		expAt = *expiresAt
 	}
 
-	query := m.buildQuery(`SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags
+	query := m.buildQuery(`SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags, COALESCE(disposition, ''), pinned_at
 					FROM files
 					WHERE blob_id = ? AND name = ? AND old_cumulus_id IS ? AND expires_at IS ?
 					LIMIT 1`)
 	if m.dbType == "postgresql" {
-		query = m.buildQuery(`SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags
+		query = m.buildQuery(`SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags, COALESCE(disposition, ''), pinned_at
 					FROM files
 					WHERE blob_id = ? AND name = ?
 					  AND old_cumulus_id IS NOT DISTINCT FROM ?
@@ -872,7 +1246,7 @@ func (m *MetadataSQL) FindFileByBlobAndName(blobID int64, filename string, oldCu
 
 	var f File
 	err := m.db.QueryRow(query, blobID, filename, oldID, expAt).Scan(
-		&f.ID, &f.Name, &f.BlobID, &f.OldCumulusID, &f.ExpiresAt, &f.CreatedAt, &f.Tags)
+		&f.ID, &f.Name, &f.BlobID, &f.OldCumulusID, &f.ExpiresAt, &f.CreatedAt, &f.Tags, &f.Disposition, &f.PinnedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -882,6 +1256,13 @@ func (m *MetadataSQL) FindFileByBlobAndName(blobID int64, filename string, oldCu
 	return &f, nil
 }
 
+// UpdateFileDisposition sets the stored Content-Disposition type of a file ("" = by MIME type)
+func (m *MetadataSQL) UpdateFileDisposition(fileID string, disposition string) error {
+	query := m.buildQuery(`UPDATE files SET disposition = ? WHERE id = ?`)
+	_, err := m.db.Exec(query, disposition, fileID)
+	return err
+}
+
 // UpdateFileTags updates the tags for a file.
 // tags must be a JSON-encoded array produced by TagsToJSON.
 func (m *MetadataSQL) UpdateFileTags(fileID string, tags string) error {
@@ -1001,6 +1382,9 @@ type VolumeCompactionTx struct {
 	m          *MetadataSQL
 	tx         *sql.Tx
 	updateStmt *sql.Stmt
+	start      time.Time
+	rows       int64
+	done       bool // observed by Commit or Rollback
 }
 
 func (m *MetadataSQL) GetBlobsInRange(limit, offset int64) ([]BlobLocation, error) {
@@ -1061,6 +1445,10 @@ func (m *MetadataSQL) SubtractWrittenBytesFromVolume(volumeID int64, bytes int64
 	if bytes <= 0 {
 		return nil
 	}
+	// Odečítá se od size_total v DB, přičtení ještě nesmí čekat v dávce
+	if err := m.FlushVolumeAppends(); err != nil {
+		return err
+	}
 	query := m.buildQuery(`
 		UPDATE volumes
 		SET size_total = CASE WHEN size_total >= ? THEN size_total - ? ELSE 0 END
@@ -1114,6 +1502,7 @@ func (m *MetadataSQL) GetBlobsForMetaRegeneration(volumeID int64) ([]BlobMetaRec
 }
 
 func (m *MetadataSQL) BeginVolumeCompactionTx() (*VolumeCompactionTx, error) {
+	start := time.Now()
 	tx, err := m.db.Begin()
 	if err != nil {
 		return nil, err
@@ -1126,18 +1515,35 @@ func (m *MetadataSQL) BeginVolumeCompactionTx() (*VolumeCompactionTx, error) {
 		return nil, err
 	}
 
-	return &VolumeCompactionTx{m: m, tx: tx, updateStmt: updateStmt}, nil
+	return &VolumeCompactionTx{m: m, tx: tx, updateStmt: updateStmt, start: start}, nil
 }
 
 func (c *VolumeCompactionTx) UpdateBlobOffset(blobID, newOffset int64) error {
-	_, err := c.updateStmt.Exec(newOffset, blobID)
-	return err
+	if _, err := c.updateStmt.Exec(newOffset, blobID); err != nil {
+		return err
+	}
+	c.rows++
+	return nil
 }
 
 func (c *VolumeCompactionTx) UpdateVolumeSize(volumeID, sizeTotal int64) error {
-	query := c.m.buildQuery("UPDATE volumes SET size_total = ?, size_deleted = 0 WHERE id = ?")
-	_, err := c.tx.Exec(query, sizeTotal, volumeID)
-	return err
+	// Zkompaktovaný soubor obsahuje jen živé bloby, další zápis jde hned za ně
+	query := c.m.buildQuery("UPDATE volumes SET size_total = ?, size_deleted = 0, append_offset = ? WHERE id = ?")
+	if _, err := c.tx.Exec(query, sizeTotal, sizeTotal, volumeID); err != nil {
+		return err
+	}
+	c.rows++
+	return nil
+}
+
+// DeleteVolume removes the volumes row of a volume left without blobs
+func (c *VolumeCompactionTx) DeleteVolume(volumeID int64) error {
+	query := c.m.buildQuery("DELETE FROM volumes WHERE id = ?")
+	if _, err := c.tx.Exec(query, volumeID); err != nil {
+		return err
+	}
+	c.rows++
+	return nil
 }
 
 func (c *VolumeCompactionTx) Commit() error {
@@ -1145,7 +1551,9 @@ func (c *VolumeCompactionTx) Commit() error {
 		_ = c.updateStmt.Close()
 		c.updateStmt = nil
 	}
-	return c.tx.Commit()
+	err := c.tx.Commit()
+	c.observe(err == nil)
+	return err
 }
 
 func (c *VolumeCompactionTx) Rollback() error {
@@ -1153,7 +1561,18 @@ func (c *VolumeCompactionTx) Rollback() error {
 		_ = c.updateStmt.Close()
 		c.updateStmt = nil
 	}
-	return c.tx.Rollback()
+	err := c.tx.Rollback()
+	c.observe(false)
+	return err
+}
+
+// observe reports the transaction once; Rollback is deferred after a successful Commit
+func (c *VolumeCompactionTx) observe(committed bool) {
+	if c.done {
+		return
+	}
+	c.done = true
+	c.m.observeTx(TxOpCompactVolume, c.start, c.rows, committed)
 }
 
 func (m *MetadataSQL) IncrementDeletedSize(volumeID int64, bytes int64) error {
@@ -1181,7 +1600,7 @@ func (m *MetadataSQL) GetVolumesToCompact(threshold float64) ([]VolumeInfo, erro
 	if threshold <= 0 {
 		// threshold=0 means get all volumes
 		query = `
-SELECT id, size_total, size_deleted
+SELECT id, size_total, size_deleted, COALESCE(state, 'open')
 FROM volumes
 WHERE size_total > 0
 ORDER BY id`
@@ -1191,7 +1610,7 @@ ORDER BY id`
 		thresholdRatio := threshold / 100.0
 
 		query = `
-SELECT id, size_total, size_deleted
+SELECT id, size_total, size_deleted, COALESCE(state, 'open')
 FROM volumes
 WHERE size_total > 0 AND CAST(size_deleted AS FLOAT) / CAST(size_total AS FLOAT) > ?
 ORDER BY id`
@@ -1206,7 +1625,7 @@ ORDER BY id`
 	var volumes []VolumeInfo
 	for rows.Next() {
 		var v VolumeInfo
-		if err := rows.Scan(&v.ID, &v.SizeTotal, &v.SizeDeleted); err != nil {
+		if err := rows.Scan(&v.ID, &v.SizeTotal, &v.SizeDeleted, &v.State); err != nil {
 			return nil, err
 		}
 		volumes = append(volumes, v)
@@ -1215,14 +1634,18 @@ ORDER BY id`
 }
 
 func (m *MetadataSQL) DeleteFile(fileID string) error {
+	start := time.Now()
 	tx, err := m.db.Begin()
 	if err != nil {
 		return err
 	}
+	var rows int64
+	committed := false
 	defer func() {
 		if err != nil {
 			tx.Rollback()
 		}
+		m.observeTx(TxOpDeleteFile, start, rows, committed)
 	}()
 
 	// Get blob ID before deleting
@@ -1235,10 +1658,28 @@ func (m *MetadataSQL) DeleteFile(fileID string) error {
 	if err != nil {
 		return err
 	}
+	var held int
+	if err = tx.QueryRow(m.buildQuery("SELECT count(*) FROM legal_holds WHERE file_id = ?"), fileID).Scan(&held); err != nil {
+		return err
+	}
+	if held > 0 {
+		err = fmt.Errorf("%w: file_id=%s", ErrLegalHold, fileID)
+		return err
+	}
 
 	// Delete file
 	deleteQuery := m.buildQuery("DELETE FROM files WHERE id = ?")
-	if _, err = tx.Exec(deleteQuery, fileID); err != nil {
+	var res sql.Result
+	if res, err = tx.Exec(deleteQuery, fileID); err != nil {
+		return err
+	}
+	rows, _ = res.RowsAffected()
+
+	// Lock the blob row before counting references, so a concurrent ClaimBlobRef either
+	// commits first (and is seen below) or waits until the blob is gone.
+	var claimed bool
+	claimed, err = m.lockBlobClaimsTx(tx, blobID)
+	if err != nil {
 		return err
 	}
 
@@ -1250,47 +1691,16 @@ func (m *MetadataSQL) DeleteFile(fileID string) error {
 		return err
 	}
 
-	if count == 0 {
+	if count == 0 && !claimed {
 		// Blob is no longer referenced.
-		// Get blob info to know volume and size
-		var volumeID, sizeCompressed int64
-		blobQuery := m.buildQuery("SELECT volume_id, size_compressed FROM blobs WHERE id = ?")
-		err = tx.QueryRow(blobQuery, blobID).Scan(&volumeID, &sizeCompressed)
-		if err != nil {
-			return err
-		}
-
-		// Calculate total size (Header + Compressed + Footer)
-		totalSize := int64(HeaderSize) + sizeCompressed + int64(FooterSize)
-
-		// Update volumes table
-		var volQuery string
-		var volArgs []any
-		if m.dbType == "postgresql" {
-			volQuery = `
-INSERT INTO volumes (id, size_total, size_deleted) VALUES ($1, 0, $2)
-ON CONFLICT(id) DO UPDATE SET size_deleted = volumes.size_deleted + EXCLUDED.size_deleted
-`
-			volArgs = []any{volumeID, totalSize}
-		} else {
-			volQuery = m.buildQuery(`
-INSERT INTO volumes (id, size_total, size_deleted) VALUES (?, 0, ?)
-ON CONFLICT(id) DO UPDATE SET size_deleted = size_deleted + ?
-`)
-			volArgs = []any{volumeID, totalSize, totalSize}
-		}
-		if _, err = tx.Exec(volQuery, volArgs...); err != nil {
-			return err
-		}
-
-		// Delete the blob record so it's not copied during compaction
-		deleteBlobQuery := m.buildQuery("DELETE FROM blobs WHERE id = ?")
-		if _, err = tx.Exec(deleteBlobQuery, blobID); err != nil {
+		if err = m.freeBlobTx(tx, blobID); err != nil {
 			return err
 		}
+		rows++
 	}
 
 	err = tx.Commit()
+	committed = err == nil
 	return err
 }
 
@@ -1423,7 +1833,7 @@ func (m *MetadataSQL) CleanupStalePendingBlobs(maxAge time.Duration) (deletedCou
 
 	for _, b := range stale {
 		if b.volumeID > 0 && b.sizeCompressed > 0 {
-			totalSize := int64(HeaderSize) + b.sizeCompressed + int64(FooterSize)
+			totalSize := format.BlobTotalSize(b.sizeCompressed)
 			var execErr error
 			if m.dbType == "postgresql" {
 				_, execErr = tx.Exec(incDeletedQuery, b.volumeID, totalSize)
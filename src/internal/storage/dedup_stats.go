@@ -0,0 +1,82 @@
+package storage
+
+// DedupStats sums the files of one tag (or all files) and the distinct blobs they reference
+type DedupStats struct {
+	Tag          string
+	Files        int64
+	Blobs        int64 // distinct blobs referenced by the files
+	LogicalBytes int64 // raw size of every file, as if nothing was deduplicated
+	UniqueBytes  int64 // raw size of the distinct blobs
+	StoredBytes  int64 // stored (compressed) size of the distinct blobs
+}
+
+// GetDedupStatsByTag returns dedup statistics of tags starting with prefix (case-insensitive),
+// largest dedup saving (logical - unique bytes) first. A blob shared by files of several tags
+// is counted in each of them.
+func (m *MetadataSQL) GetDedupStatsByTag(prefix string, limit int) ([]DedupStats, error) {
+	pattern := escapeLike(prefix) + "%"
+	tagCol := m.tagColumnSQL()
+	source := m.tagsSourceSQL()
+	match := tagCol + ` ` + m.likeOperator() + ` ? ESCAPE '\'`
+
+	// DISTINCT: tag uvedený u souboru dvakrát se nepočítá dvakrát
+	query := m.buildQuery(`
+		SELECT fs.tag, fs.files, bs.blobs, fs.logical_bytes, bs.unique_bytes, bs.stored_bytes
+		FROM (
+			SELECT tag, COUNT(*) AS files, COALESCE(SUM(size_raw), 0) AS logical_bytes
+			FROM (
+				SELECT DISTINCT ` + tagCol + ` AS tag, f.id, b.size_raw
+				FROM ` + source + `
+				JOIN blobs b ON b.id = f.blob_id
+				WHERE ` + match + `
+			) tf
+			GROUP BY tag
+		) fs
+		JOIN (
+			SELECT tag, COUNT(*) AS blobs, COALESCE(SUM(size_raw), 0) AS unique_bytes, COALESCE(SUM(size_compressed), 0) AS stored_bytes
+			FROM (
+				SELECT DISTINCT ` + tagCol + ` AS tag, b.id, b.size_raw, b.size_compressed
+				FROM ` + source + `
+				JOIN blobs b ON b.id = f.blob_id
+				WHERE ` + match + `
+			) tb
+			GROUP BY tag
+		) bs ON bs.tag = fs.tag
+		ORDER BY fs.logical_bytes - bs.unique_bytes DESC, fs.tag
+		LIMIT ?
+	`)
+	rows, err := m.db.Query(query, pattern, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []DedupStats{}
+	for rows.Next() {
+		var d DedupStats
+		if err := rows.Scan(&d.Tag, &d.Files, &d.Blobs, &d.LogicalBytes, &d.UniqueBytes, &d.StoredBytes); err != nil {
+			return nil, err
+		}
+		stats = append(stats, d)
+	}
+	return stats, rows.Err()
+}
+
+// GetDedupTotals returns dedup statistics of all files, tagged or not
+func (m *MetadataSQL) GetDedupTotals() (DedupStats, error) {
+	d := DedupStats{Tag: "total"}
+	err := m.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(b.size_raw), 0)
+		FROM files f
+		JOIN blobs b ON b.id = f.blob_id
+	`).Scan(&d.Files, &d.LogicalBytes)
+	if err != nil {
+		return d, err
+	}
+	err = m.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(b.size_raw), 0), COALESCE(SUM(b.size_compressed), 0)
+		FROM blobs b
+		WHERE b.id IN (SELECT blob_id FROM files)
+	`).Scan(&d.Blobs, &d.UniqueBytes, &d.StoredBytes)
+	return d, err
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetDedupStatsByTag(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blobs := map[string]int64{}
+	for _, b := range []struct {
+		hash        string
+		raw, stored int64
+	}{
+		{"shared", 1000, 400},
+		{"single", 300, 300},
+		{"other", 50, 50},
+	} {
+		id, err := m.CreateBlob(b.hash)
+		if err != nil {
+			t.Fatalf("CreateBlob: %v", err)
+		}
+		if err := m.UpdateBlobLocation(id, 1, 0, b.raw, b.stored, "zstd", 0); err != nil {
+			t.Fatalf("UpdateBlobLocation: %v", err)
+		}
+		blobs[b.hash] = id
+	}
+	for _, f := range []struct {
+		id, blob, tags string
+	}{
+		{"a", "shared", `["invoice"]`},
+		{"b", "shared", `["invoice","invoice"]`},
+		{"c", "shared", `["invoice","scan"]`},
+		{"d", "single", `["scan"]`},
+		{"e", "other", ``},
+	} {
+		if err := m.SaveFile(File{ID: f.id, Name: f.id, BlobID: blobs[f.blob], CreatedAt: time.Now(), Tags: f.tags}); err != nil {
+			t.Fatalf("SaveFile(%s): %v", f.id, err)
+		}
+	}
+
+	stats, err := m.GetDedupStatsByTag("", 10)
+	if err != nil {
+		t.Fatalf("GetDedupStatsByTag: %v", err)
+	}
+	want := []DedupStats{
+		{Tag: "invoice", Files: 3, Blobs: 1, LogicalBytes: 3000, UniqueBytes: 1000, StoredBytes: 400},
+		{Tag: "scan", Files: 2, Blobs: 2, LogicalBytes: 1300, UniqueBytes: 1300, StoredBytes: 700},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("GetDedupStatsByTag = %+v, want %+v", stats, want)
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Fatalf("GetDedupStatsByTag[%d] = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+
+	if stats, err := m.GetDedupStatsByTag("SC", 10); err != nil || len(stats) != 1 || stats[0].Tag != "scan" {
+		t.Fatalf("GetDedupStatsByTag(SC) = %+v, %v, want scan only", stats, err)
+	}
+
+	total, err := m.GetDedupTotals()
+	if err != nil {
+		t.Fatalf("GetDedupTotals: %v", err)
+	}
+	wantTotal := DedupStats{Tag: "total", Files: 5, Blobs: 3, LogicalBytes: 3350, UniqueBytes: 1350, StoredBytes: 750}
+	if total != wantTotal {
+		t.Fatalf("GetDedupTotals = %+v, want %+v", total, wantTotal)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build linux
+
+package storage
+
+import "syscall"
+
+// DiskUsage returns the size and the space available to unprivileged users of the filesystem holding path
+func DiskUsage(path string) (total, free int64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	bsize := int64(st.Bsize) // int32 on some 32-bit platforms
+	return int64(st.Blocks) * bsize, int64(st.Bavail) * bsize, nil
+}
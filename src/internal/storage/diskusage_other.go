@@ -0,0 +1,10 @@
+//go:build !linux
+
+package storage
+
+import "errors"
+
+// DiskUsage is not implemented outside Linux
+func DiskUsage(path string) (total, free int64, err error) {
+	return 0, 0, errors.New("disk usage not supported on this platform")
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"strings"
+	"time"
+)
+
+// SampleFileIDs returns up to n random IDs of files created before createdBefore
+func (m *MetadataSQL) SampleFileIDs(n int, createdBefore time.Time) ([]string, error) {
+	rows, err := m.db.Query(m.buildQuery(`
+		SELECT id FROM files
+		WHERE created_at < ?
+		ORDER BY RANDOM()
+		LIMIT ?
+	`), createdBefore.UTC(), n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetFileHashes returns the content hashes of the given files, keyed by file ID.
+// IDs without a file are simply missing from the result.
+func (m *MetadataSQL) GetFileHashes(ids []string) (map[string]string, error) {
+	const chunkSize = 500
+	result := make(map[string]string, len(ids))
+
+	for start := 0; start < len(ids); start += chunkSize {
+		chunk := ids[start:min(start+chunkSize, len(ids))]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(chunk)), ",")
+		args := make([]any, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+
+		query := m.buildQuery(`
+			SELECT f.id, COALESCE(b.hash, '')
+			FROM files f
+			LEFT JOIN blobs b ON b.id = f.blob_id
+			WHERE f.id IN (` + placeholders + `)
+		`)
+		rows, err := m.db.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id, hash string
+			if err := rows.Scan(&id, &hash); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			result[id] = hash
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSampleFileIDsAndHashes(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	first := createCommittedBlob(t, m, "hash1")
+	second := createCommittedBlob(t, m, "hash2")
+	saveTestFile(t, m, "a", first)
+	saveTestFile(t, m, "b", first)
+	saveTestFile(t, m, "c", second)
+
+	ids, err := m.SampleFileIDs(10, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("SampleFileIDs: %v", err)
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []string{"a", "b", "c"}) {
+		t.Fatalf("SampleFileIDs = %v, want all files", ids)
+	}
+	if ids, err := m.SampleFileIDs(2, time.Now().Add(time.Minute)); err != nil || len(ids) != 2 {
+		t.Fatalf("SampleFileIDs(2) = %v, %v, want 2 files", ids, err)
+	}
+	if ids, err := m.SampleFileIDs(10, time.Now().Add(-time.Hour)); err != nil || len(ids) != 0 {
+		t.Fatalf("SampleFileIDs of older files = %v, %v, want none", ids, err)
+	}
+
+	hashes, err := m.GetFileHashes([]string{"a", "c", "missing"})
+	if err != nil {
+		t.Fatalf("GetFileHashes: %v", err)
+	}
+	if len(hashes) != 2 || hashes["a"] != "hash1" || hashes["c"] != "hash2" {
+		t.Fatalf("GetFileHashes = %v, want a=hash1, c=hash2", hashes)
+	}
+}
@@ -0,0 +1,70 @@
+package storage
+
+// FileTypeFilter selects blobs by their current file type; empty fields match everything
+type FileTypeFilter struct {
+	Category string // e.g. "binary"
+	MimeType string // e.g. "application/octet-stream"
+}
+
+// TypedBlob is a committed blob with its current file type and the name of one of its files
+type TypedBlob struct {
+	Blob
+	Type     FileType
+	FileName string
+}
+
+// ListBlobsByFileType returns committed blobs referenced by at least one file whose file type
+// matches the filter, ordered by ID, starting after afterID (keyset pagination). Blobs without
+// a file type only match an empty filter.
+func (m *MetadataSQL) ListBlobsByFileType(filter FileTypeFilter, afterID int64, limit int) ([]TypedBlob, error) {
+	query := `SELECT b.id, b.hash, b.volume_id, b.blob_offset, COALESCE(b.size_raw, 0), b.size_compressed,
+			COALESCE(b.compression_alg, ''), COALESCE(b.file_type_id, 0),
+			COALESCE(ft.mime_type, ''), COALESCE(ft.category, ''), COALESCE(ft.subtype, ''),
+			(SELECT MIN(f.name) FROM files f WHERE f.blob_id = b.id)
+		FROM blobs b
+		LEFT JOIN file_types ft ON ft.id = b.file_type_id
+		WHERE b.state = 'committed' AND b.volume_id > 0 AND b.id > ?
+		  AND EXISTS (SELECT 1 FROM files f WHERE f.blob_id = b.id)`
+	args := []interface{}{afterID}
+	if filter.Category != "" {
+		query += ` AND ft.category = ?`
+		args = append(args, filter.Category)
+	}
+	if filter.MimeType != "" {
+		query += ` AND ft.mime_type = ?`
+		args = append(args, filter.MimeType)
+	}
+	query += ` ORDER BY b.id LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := m.db.Query(m.buildQuery(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blobs []TypedBlob
+	for rows.Next() {
+		var b TypedBlob
+		if err := rows.Scan(&b.ID, &b.Hash, &b.VolumeID, &b.Offset, &b.SizeRaw, &b.SizeCompressed,
+			&b.CompressionAlg, &b.FileTypeID, &b.Type.MimeType, &b.Type.Category, &b.Type.Subtype, &b.FileName); err != nil {
+			return nil, err
+		}
+		b.State = "committed"
+		b.Type.ID = b.FileTypeID
+		blobs = append(blobs, b)
+	}
+	return blobs, rows.Err()
+}
+
+// SwapBlobFileType sets the file type of the blob only if it still is oldTypeID, so a concurrent
+// change (e.g. a re-upload with a better Content-Type) is not overwritten. Returns false if not.
+func (m *MetadataSQL) SwapBlobFileType(blobID, oldTypeID, newTypeID int64) (bool, error) {
+	query := m.buildQuery(`UPDATE blobs SET file_type_id = ? WHERE id = ? AND COALESCE(file_type_id, 0) = ?`)
+	res, err := m.db.Exec(query, newTypeID, blobID, oldTypeID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
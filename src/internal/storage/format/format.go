@@ -0,0 +1,158 @@
+// Package format defines the on-disk layouts shared by the server and the offline tools:
+// blob header/footer in volume_*.dat, records of volume_*.meta and records of files_metadata.bin.
+// All integers are big endian.
+package format
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	MagicBytes = 0x43554D55
+	Version    = 1
+	// Header: Magic(4) + Ver(1) + Comp(1) + Size(8) + BlobID(8)
+	HeaderSize = 4 + 1 + 1 + 8 + 8
+	// Footer: CRC32(4) of the stored (compressed) data
+	FooterSize = 4
+	// Meta record: BlobID(8) + Offset(8) + Size(8) + Comp(1) + CRC(4)
+	MetaRecordSize = 8 + 8 + 8 + 1 + 4
+)
+
+// Compression algorithm codes stored in the header and meta records
+const (
+	CompNone uint8 = 0
+	CompGzip uint8 = 1
+	CompZstd uint8 = 2
+)
+
+var (
+	// ErrShortBuffer is returned when a buffer is smaller than the decoded structure
+	ErrShortBuffer = errors.New("buffer too short")
+	// ErrBadMagic is returned for a blob header without the Cumulus magic bytes
+	ErrBadMagic = errors.New("bad magic bytes")
+)
+
+// CompressionCode converts a compression name ("none", "gzip", "zstd") to its on-disk code.
+// Unknown names map to CompNone.
+func CompressionCode(name string) uint8 {
+	switch name {
+	case "gzip":
+		return CompGzip
+	case "zstd":
+		return CompZstd
+	default:
+		return CompNone
+	}
+}
+
+// CompressionName converts an on-disk compression code to its name
+func CompressionName(code uint8) string {
+	switch code {
+	case CompGzip:
+		return "gzip"
+	case CompZstd:
+		return "zstd"
+	default:
+		return "none"
+	}
+}
+
+// BlobTotalSize returns the space taken in a volume by a blob with sizeCompressed bytes of data
+func BlobTotalSize(sizeCompressed int64) int64 {
+	return HeaderSize + sizeCompressed + FooterSize
+}
+
+// Header is the blob header preceding the data in a volume file
+type Header struct {
+	Version uint8
+	CompAlg uint8
+	Size    int64 // size of the stored (compressed) data
+	BlobID  int64
+}
+
+// EncodeHeader serializes a blob header; Version 0 is written as the current Version
+func EncodeHeader(h Header) []byte {
+	if h.Version == 0 {
+		h.Version = Version
+	}
+	buf := make([]byte, HeaderSize)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(MagicBytes))
+	buf[4] = h.Version
+	buf[5] = h.CompAlg
+	binary.BigEndian.PutUint64(buf[6:14], uint64(h.Size))
+	binary.BigEndian.PutUint64(buf[14:22], uint64(h.BlobID))
+	return buf
+}
+
+// DecodeHeader parses a blob header and checks the magic bytes
+func DecodeHeader(buf []byte) (Header, error) {
+	if len(buf) < HeaderSize {
+		return Header{}, fmt.Errorf("%w: header needs %d bytes, got %d", ErrShortBuffer, HeaderSize, len(buf))
+	}
+	if magic := binary.BigEndian.Uint32(buf[0:4]); magic != uint32(MagicBytes) {
+		return Header{}, fmt.Errorf("%w: got 0x%X, expected 0x%X", ErrBadMagic, magic, MagicBytes)
+	}
+	return Header{
+		Version: buf[4],
+		CompAlg: buf[5],
+		Size:    int64(binary.BigEndian.Uint64(buf[6:14])),
+		BlobID:  int64(binary.BigEndian.Uint64(buf[14:22])),
+	}, nil
+}
+
+// EncodeFooter serializes the CRC32 footer following the blob data
+func EncodeFooter(crc uint32) []byte {
+	buf := make([]byte, FooterSize)
+	binary.BigEndian.PutUint32(buf, crc)
+	return buf
+}
+
+// DecodeFooter parses the CRC32 footer
+func DecodeFooter(buf []byte) (uint32, error) {
+	if len(buf) < FooterSize {
+		return 0, fmt.Errorf("%w: footer needs %d bytes, got %d", ErrShortBuffer, FooterSize, len(buf))
+	}
+	return binary.BigEndian.Uint32(buf[0:4]), nil
+}
+
+// MetaRecord is one record of a volume_*.meta file.
+// Offset points to the start of the blob header in the .dat file.
+type MetaRecord struct {
+	BlobID  int64
+	Offset  int64
+	Size    int64 // size of the stored (compressed) data
+	CompAlg uint8
+	CRC     uint32
+}
+
+// DataOffset returns the offset of the blob data (after the header)
+func (r MetaRecord) DataOffset() int64 {
+	return r.Offset + HeaderSize
+}
+
+// EncodeMetaRecord serializes a .meta record
+func EncodeMetaRecord(r MetaRecord) []byte {
+	buf := make([]byte, MetaRecordSize)
+	binary.BigEndian.PutUint64(buf[0:8], uint64(r.BlobID))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(r.Offset))
+	binary.BigEndian.PutUint64(buf[16:24], uint64(r.Size))
+	buf[24] = r.CompAlg
+	binary.BigEndian.PutUint32(buf[25:29], r.CRC)
+	return buf
+}
+
+// DecodeMetaRecord parses a .meta record
+func DecodeMetaRecord(buf []byte) (MetaRecord, error) {
+	if len(buf) < MetaRecordSize {
+		return MetaRecord{}, fmt.Errorf("%w: meta record needs %d bytes, got %d", ErrShortBuffer, MetaRecordSize, len(buf))
+	}
+	return MetaRecord{
+		BlobID:  int64(binary.BigEndian.Uint64(buf[0:8])),
+		Offset:  int64(binary.BigEndian.Uint64(buf[8:16])),
+		Size:    int64(binary.BigEndian.Uint64(buf[16:24])),
+		CompAlg: buf[24],
+		CRC:     binary.BigEndian.Uint32(buf[25:29]),
+	}, nil
+}
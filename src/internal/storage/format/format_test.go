@@ -0,0 +1,168 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	in := Header{Version: Version, CompAlg: CompZstd, Size: 1 << 40, BlobID: 123456789}
+	buf := EncodeHeader(in)
+	if len(buf) != HeaderSize {
+		t.Fatalf("EncodeHeader length = %d, want %d", len(buf), HeaderSize)
+	}
+
+	out, err := DecodeHeader(buf)
+	if err != nil {
+		t.Fatalf("DecodeHeader: %v", err)
+	}
+	if out != in {
+		t.Errorf("DecodeHeader = %+v, want %+v", out, in)
+	}
+
+	buf[0] ^= 0xFF
+	if _, err := DecodeHeader(buf); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("DecodeHeader with corrupted magic: err = %v, want ErrBadMagic", err)
+	}
+	if _, err := DecodeHeader(buf[:HeaderSize-1]); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("DecodeHeader of short buffer: err = %v, want ErrShortBuffer", err)
+	}
+}
+
+func TestFooterRoundTrip(t *testing.T) {
+	buf := EncodeFooter(0xDEADBEEF)
+	if len(buf) != FooterSize {
+		t.Fatalf("EncodeFooter length = %d, want %d", len(buf), FooterSize)
+	}
+	crc, err := DecodeFooter(buf)
+	if err != nil || crc != 0xDEADBEEF {
+		t.Errorf("DecodeFooter = (0x%X, %v), want (0xDEADBEEF, nil)", crc, err)
+	}
+}
+
+func TestMetaRecordRoundTrip(t *testing.T) {
+	in := MetaRecord{BlobID: 42, Offset: 1 << 33, Size: 1024, CompAlg: CompGzip, CRC: 0x01020304}
+	buf := EncodeMetaRecord(in)
+	if len(buf) != MetaRecordSize {
+		t.Fatalf("EncodeMetaRecord length = %d, want %d", len(buf), MetaRecordSize)
+	}
+
+	out, err := DecodeMetaRecord(buf)
+	if err != nil {
+		t.Fatalf("DecodeMetaRecord: %v", err)
+	}
+	if out != in {
+		t.Errorf("DecodeMetaRecord = %+v, want %+v", out, in)
+	}
+	if out.DataOffset() != in.Offset+HeaderSize {
+		t.Errorf("DataOffset = %d, want %d", out.DataOffset(), in.Offset+HeaderSize)
+	}
+}
+
+func TestCompressionCodes(t *testing.T) {
+	for _, name := range []string{"none", "gzip", "zstd"} {
+		if got := CompressionName(CompressionCode(name)); got != name {
+			t.Errorf("CompressionName(CompressionCode(%q)) = %q", name, got)
+		}
+	}
+	if CompressionCode("unknown") != CompNone {
+		t.Errorf("CompressionCode(unknown) should be CompNone")
+	}
+}
+
+func TestLogRecordRoundTrip(t *testing.T) {
+	oldID := int64(987654)
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 6, time.UTC)
+
+	tests := []struct {
+		name string
+		rec  LogRecord
+	}{
+		{
+			name: "required fields only",
+			rec: LogRecord{
+				ID:        "0b9a3c4e-7f51-4c55-9a5d-1f3e2d4c5b6a",
+				Name:      "report.pdf",
+				BlobID:    7,
+				CreatedAt: time.Date(2025, 12, 11, 10, 0, 0, 123, time.UTC),
+			},
+		},
+		{
+			name: "all optional fields",
+			rec: LogRecord{
+				ID:           "c1",
+				Name:         "fotka čtvrtek.jpg",
+				BlobID:       1 << 40,
+				OldCumulusID: &oldID,
+				ExpiresAt:    &expiresAt,
+				CreatedAt:    time.Date(2025, 12, 11, 10, 0, 0, 0, time.UTC),
+				Tags:         `["invoice","2025"]`,
+				Disposition:  "attachment",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := DecodeLogRecord(EncodeLogRecord(tt.rec))
+			if err != nil {
+				t.Fatalf("DecodeLogRecord: %v", err)
+			}
+			assertLogRecord(t, out, tt.rec)
+		})
+	}
+}
+
+func TestLogReader(t *testing.T) {
+	first := LogRecord{ID: "a", Name: "a.txt", BlobID: 1, CreatedAt: time.Unix(0, 1)}
+	second := LogRecord{ID: "b", Name: "b.txt", BlobID: 2, CreatedAt: time.Unix(0, 2), Tags: `["x"]`}
+
+	var buf []byte
+	buf = AppendLogFrame(buf, EncodeLogRecord(first))
+	buf = AppendLogFrame(buf, []byte{0, 10, 'x'}) // ID length beyond the record
+	buf = AppendLogFrame(buf, EncodeLogRecord(second))
+	buf = append(buf, 0, 0, 0, 99, 1, 2) // partially written last record
+
+	reader := NewLogReader(bytes.NewReader(buf))
+
+	rec, err := reader.Next()
+	if err != nil {
+		t.Fatalf("first record: %v", err)
+	}
+	assertLogRecord(t, rec, first)
+
+	if _, err := reader.Next(); !errors.Is(err, ErrCorruptLogRecord) {
+		t.Fatalf("corrupt record: err = %v, want ErrCorruptLogRecord", err)
+	}
+
+	rec, err = reader.Next()
+	if err != nil {
+		t.Fatalf("record after corrupt one: %v", err)
+	}
+	assertLogRecord(t, rec, second)
+
+	if _, err := reader.Next(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("truncated record: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func assertLogRecord(t *testing.T, got, want LogRecord) {
+	t.Helper()
+	if got.ID != want.ID || got.Name != want.Name || got.BlobID != want.BlobID || got.Tags != want.Tags || got.Disposition != want.Disposition {
+		t.Errorf("record = %+v, want %+v", got, want)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want %v", got.CreatedAt, want.CreatedAt)
+	}
+	if (got.OldCumulusID == nil) != (want.OldCumulusID == nil) ||
+		(got.OldCumulusID != nil && *got.OldCumulusID != *want.OldCumulusID) {
+		t.Errorf("OldCumulusID = %v, want %v", got.OldCumulusID, want.OldCumulusID)
+	}
+	if (got.ExpiresAt == nil) != (want.ExpiresAt == nil) ||
+		(got.ExpiresAt != nil && !got.ExpiresAt.Equal(*want.ExpiresAt)) {
+		t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, want.ExpiresAt)
+	}
+}
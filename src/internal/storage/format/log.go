@@ -0,0 +1,204 @@
+package format
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// MetadataLogName is the file name of the recovery log inside the data directory
+const MetadataLogName = "files_metadata.bin"
+
+// LogLengthSize is the size of the length prefix before each log record
+const LogLengthSize = 4
+
+// Flags of optional fields in a log record
+const (
+	logFlagOldCumulusID = 1 << 0
+	logFlagExpiresAt    = 1 << 1
+	logFlagTags         = 1 << 2
+	logFlagDisposition  = 1 << 3
+)
+
+// ErrCorruptLogRecord is returned for a record that cannot be decoded; the reader stays aligned
+// on the next record, so callers may skip it.
+var ErrCorruptLogRecord = errors.New("corrupt log record")
+
+// LogRecord is one record of files_metadata.bin (file metadata needed for disaster recovery)
+type LogRecord struct {
+	ID           string
+	Name         string
+	BlobID       int64
+	OldCumulusID *int64
+	ExpiresAt    *time.Time
+	CreatedAt    time.Time
+	Tags         string
+	Disposition  string
+}
+
+// EncodeLogRecord serializes file metadata into one log record (without the length prefix).
+//
+// Layout (big endian): IDLen(2) ID | BlobID(8) | CreatedAt(8, unix nano) | Flags(1) |
+// [OldCumulusID(8)] [ExpiresAt(8, unix nano)] [TagsLen(2) Tags] | NameLen(2) Name |
+// [DispositionLen(2) Disposition]
+//
+// Disposition follows the name so that readers unaware of it still decode the record.
+func EncodeLogRecord(f LogRecord) []byte {
+	// Odhad velikosti: ID(36) + BlobID(8) + Time(8) + Flags(1) + Opts(16) + NameLen(2) + Name(N)
+	buf := make([]byte, 0, 128)
+
+	// 1. ID (String length + String)
+	idBytes := []byte(f.ID)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(idBytes)))
+	buf = append(buf, idBytes...)
+
+	// 2. BlobID
+	buf = binary.BigEndian.AppendUint64(buf, uint64(f.BlobID))
+
+	// 3. CreatedAt (Unix Nano)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(f.CreatedAt.UnixNano()))
+
+	// 4. Flags & Optional fields
+	var flags uint8 = 0
+	if f.OldCumulusID != nil {
+		flags |= logFlagOldCumulusID
+	}
+	if f.ExpiresAt != nil {
+		flags |= logFlagExpiresAt
+	}
+	if f.Tags != "" {
+		flags |= logFlagTags
+	}
+	if f.Disposition != "" {
+		flags |= logFlagDisposition
+	}
+	buf = append(buf, flags)
+
+	if f.OldCumulusID != nil {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(*f.OldCumulusID))
+	}
+	if f.ExpiresAt != nil {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(f.ExpiresAt.UnixNano()))
+	}
+	if f.Tags != "" {
+		tagsBytes := []byte(f.Tags)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(tagsBytes)))
+		buf = append(buf, tagsBytes...)
+	}
+
+	// 5. Name
+	nameBytes := []byte(f.Name)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(nameBytes)))
+	buf = append(buf, nameBytes...)
+
+	// 6. Disposition
+	if f.Disposition != "" {
+		dispBytes := []byte(f.Disposition)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(dispBytes)))
+		buf = append(buf, dispBytes...)
+	}
+
+	return buf
+}
+
+// DecodeLogRecord parses one log record written by EncodeLogRecord
+func DecodeLogRecord(record []byte) (LogRecord, error) {
+	var f LogRecord
+	cursor := 0
+	take := func(n int) ([]byte, error) {
+		if cursor+n > len(record) {
+			return nil, fmt.Errorf("%w: truncated at byte %d of %d", ErrCorruptLogRecord, cursor, len(record))
+		}
+		b := record[cursor : cursor+n]
+		cursor += n
+		return b, nil
+	}
+	takeString := func() (string, error) {
+		b, err := take(2)
+		if err != nil {
+			return "", err
+		}
+		b, err = take(int(binary.BigEndian.Uint16(b)))
+		return string(b), err
+	}
+
+	var err error
+	if f.ID, err = takeString(); err != nil {
+		return f, err
+	}
+	fixed, err := take(8 + 8 + 1)
+	if err != nil {
+		return f, err
+	}
+	f.BlobID = int64(binary.BigEndian.Uint64(fixed[0:8]))
+	f.CreatedAt = time.Unix(0, int64(binary.BigEndian.Uint64(fixed[8:16])))
+	flags := fixed[16]
+
+	if flags&logFlagOldCumulusID != 0 {
+		b, err := take(8)
+		if err != nil {
+			return f, err
+		}
+		oldID := int64(binary.BigEndian.Uint64(b))
+		f.OldCumulusID = &oldID
+	}
+	if flags&logFlagExpiresAt != 0 {
+		b, err := take(8)
+		if err != nil {
+			return f, err
+		}
+		expiresAt := time.Unix(0, int64(binary.BigEndian.Uint64(b)))
+		f.ExpiresAt = &expiresAt
+	}
+	if flags&logFlagTags != 0 {
+		if f.Tags, err = takeString(); err != nil {
+			return f, err
+		}
+	}
+	if f.Name, err = takeString(); err != nil {
+		return f, err
+	}
+	if flags&logFlagDisposition != 0 {
+		if f.Disposition, err = takeString(); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+// LogReader reads records of a metadata log sequentially
+type LogReader struct {
+	r *bufio.Reader
+}
+
+// NewLogReader creates a reader over a metadata log stream
+func NewLogReader(r io.Reader) *LogReader {
+	return &LogReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next record. It returns io.EOF at the end of the log, io.ErrUnexpectedEOF
+// for a partially written last record and an ErrCorruptLogRecord error for a record that
+// cannot be decoded (reading may continue with the next record).
+func (lr *LogReader) Next() (LogRecord, error) {
+	lenBuf := make([]byte, LogLengthSize)
+	if _, err := io.ReadFull(lr.r, lenBuf); err != nil {
+		return LogRecord{}, err
+	}
+	record := make([]byte, binary.BigEndian.Uint32(lenBuf))
+	if _, err := io.ReadFull(lr.r, record); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return LogRecord{}, err
+	}
+	return DecodeLogRecord(record)
+}
+
+// AppendLogFrame appends a length-prefixed record to buf
+func AppendLogFrame(buf []byte, record []byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(record)))
+	return append(buf, record...)
+}
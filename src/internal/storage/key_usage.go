@@ -0,0 +1,83 @@
+package storage
+
+import "strings"
+
+// KeyUsage holds the traffic of one client (API key name) in one calendar month (YYYY-MM, UTC)
+type KeyUsage struct {
+	Month    string `json:"month" example:"2025-12"`
+	Client   string `json:"client" example:"anonymous"`
+	Requests int64  `json:"requests" example:"1200"`
+	BytesIn  int64  `json:"bytesIn" example:"734003200"`
+	BytesOut int64  `json:"bytesOut" example:"1468006400"`
+	Errors   int64  `json:"errors" example:"3"`
+}
+
+// AddKeyUsage adds usage deltas to the persisted monthly counters in one transaction
+func (m *MetadataSQL) AddKeyUsage(deltas []KeyUsage) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := m.buildQuery(`
+		INSERT INTO api_key_usage (month, client, requests, bytes_in, bytes_out, errors)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(month, client) DO UPDATE SET
+			requests = api_key_usage.requests + EXCLUDED.requests,
+			bytes_in = api_key_usage.bytes_in + EXCLUDED.bytes_in,
+			bytes_out = api_key_usage.bytes_out + EXCLUDED.bytes_out,
+			errors = api_key_usage.errors + EXCLUDED.errors
+	`)
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, d := range deltas {
+		if _, err := stmt.Exec(d.Month, d.Client, d.Requests, d.BytesIn, d.BytesOut, d.Errors); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetKeyUsage returns persisted usage counters ordered by month (newest first) and client.
+// Empty month or client means no filter.
+func (m *MetadataSQL) GetKeyUsage(month, client string) ([]KeyUsage, error) {
+	var where []string
+	var args []any
+	if month != "" {
+		where = append(where, "month = ?")
+		args = append(args, month)
+	}
+	if client != "" {
+		where = append(where, "client = ?")
+		args = append(args, client)
+	}
+	query := `SELECT month, client, requests, bytes_in, bytes_out, errors FROM api_key_usage`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY month DESC, client"
+
+	rows, err := m.db.Query(m.buildQuery(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]KeyUsage, 0)
+	for rows.Next() {
+		var u KeyUsage
+		if err := rows.Scan(&u.Month, &u.Client, &u.Requests, &u.BytesIn, &u.BytesOut, &u.Errors); err != nil {
+			return nil, err
+		}
+		list = append(list, u)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrLegalHold is returned by DeleteFile for a file under legal hold
+var ErrLegalHold = errors.New("file is under legal hold")
+
+// LegalHold keeps a file from being deleted – by a client, a purge or expiry cleanup – until
+// it is released. Unlike a pin it is not a lifecycle setting but an admin decision with a reason.
+type LegalHold struct {
+	FileID string    `json:"file_id"`
+	Reason string    `json:"reason,omitempty" example:"case 2026-117"`
+	HeldAt time.Time `json:"held_at"`
+}
+
+// SetLegalHold places a file under legal hold. Holding a held file updates a non-empty reason and
+// keeps the original held_at. Returns sql.ErrNoRows when the file does not exist.
+func (m *MetadataSQL) SetLegalHold(fileID, reason string) error {
+	var exists int
+	if err := m.db.QueryRow(m.buildQuery(`SELECT count(*) FROM files WHERE id = ?`), fileID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return sql.ErrNoRows
+	}
+	_, err := m.db.Exec(m.buildQuery(`
+		INSERT INTO legal_holds (file_id, reason, held_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(file_id) DO UPDATE SET reason = COALESCE(NULLIF(EXCLUDED.reason, ''), legal_holds.reason)
+	`), fileID, reason, time.Now().UTC())
+	return err
+}
+
+// ReleaseLegalHold lifts the legal hold of a file; returns false when the file was not held
+func (m *MetadataSQL) ReleaseLegalHold(fileID string) (bool, error) {
+	res, err := m.db.Exec(m.buildQuery(`DELETE FROM legal_holds WHERE file_id = ?`), fileID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetLegalHold returns the legal hold of a file, nil when the file is not held
+func (m *MetadataSQL) GetLegalHold(fileID string) (*LegalHold, error) {
+	h := LegalHold{FileID: fileID}
+	err := m.db.QueryRow(m.buildQuery(`SELECT COALESCE(reason, ''), held_at FROM legal_holds WHERE file_id = ?`), fileID).Scan(&h.Reason, &h.HeldAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// ListLegalHolds returns up to limit legal holds, most recent first
+func (m *MetadataSQL) ListLegalHolds(limit int) ([]LegalHold, error) {
+	rows, err := m.db.Query(m.buildQuery(`
+		SELECT file_id, COALESCE(reason, ''), held_at
+		FROM legal_holds
+		ORDER BY held_at DESC, file_id
+		LIMIT ?
+	`), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := []LegalHold{}
+	for rows.Next() {
+		var h LegalHold
+		if err := rows.Scan(&h.FileID, &h.Reason, &h.HeldAt); err != nil {
+			return nil, err
+		}
+		holds = append(holds, h)
+	}
+	return holds, rows.Err()
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLegalHoldBlocksDelete(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blobID := createCommittedBlob(t, m, "hash1")
+	saveTestFile(t, m, "held", blobID)
+
+	if err := m.SetLegalHold("missing", "case 1"); err != sql.ErrNoRows {
+		t.Fatalf("SetLegalHold of a missing file = %v, want sql.ErrNoRows", err)
+	}
+	if err := m.SetLegalHold("held", "case 1"); err != nil {
+		t.Fatalf("SetLegalHold: %v", err)
+	}
+	first, err := m.GetLegalHold("held")
+	if err != nil || first == nil || first.Reason != "case 1" {
+		t.Fatalf("GetLegalHold = %+v, %v", first, err)
+	}
+	if err := m.SetLegalHold("held", "case 2"); err != nil {
+		t.Fatalf("SetLegalHold again: %v", err)
+	}
+	if err := m.SetLegalHold("held", ""); err != nil {
+		t.Fatalf("SetLegalHold without reason: %v", err)
+	}
+	if h, _ := m.GetLegalHold("held"); h.Reason != "case 2" || !h.HeldAt.Equal(first.HeldAt) {
+		t.Fatalf("hold after update = %+v, want reason case 2 and the original held_at %v", h, first.HeldAt)
+	}
+
+	if err := m.DeleteFile("held"); !errors.Is(err, ErrLegalHold) {
+		t.Fatalf("DeleteFile of a held file = %v, want ErrLegalHold", err)
+	}
+	if _, err := m.GetFile("held"); err != nil {
+		t.Fatalf("held file gone after a failed delete: %v", err)
+	}
+	if !blobExists(t, m, blobID) {
+		t.Fatal("blob of the held file freed")
+	}
+
+	if released, err := m.ReleaseLegalHold("held"); err != nil || !released {
+		t.Fatalf("ReleaseLegalHold = %v, %v, want true", released, err)
+	}
+	if released, err := m.ReleaseLegalHold("held"); err != nil || released {
+		t.Fatalf("second ReleaseLegalHold = %v, %v, want false", released, err)
+	}
+	if err := m.DeleteFile("held"); err != nil {
+		t.Fatalf("DeleteFile after release: %v", err)
+	}
+}
+
+func TestLegalHoldSkipsExpiryCleanup(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blobID := createCommittedBlob(t, m, "hash1")
+	expired := time.Now().Add(-time.Hour)
+	for _, id := range []string{"held", "free"} {
+		if err := m.SaveFile(File{ID: id, Name: id + ".txt", BlobID: blobID, CreatedAt: time.Now(), ExpiresAt: &expired}); err != nil {
+			t.Fatalf("SaveFile(%s): %v", id, err)
+		}
+	}
+	if err := m.SetLegalHold("held", ""); err != nil {
+		t.Fatalf("SetLegalHold: %v", err)
+	}
+
+	ids, _, err := m.GetExpiredTemporaryFiles()
+	if err != nil || len(ids) != 1 || ids[0] != "free" {
+		t.Fatalf("GetExpiredTemporaryFiles = %v, %v, want only the free file", ids, err)
+	}
+	if n, err := m.CleanupExpiredFiles(); err != nil || n != 1 {
+		t.Fatalf("CleanupExpiredFiles = %d, %v, want 1", n, err)
+	}
+	if _, err := m.GetFile("held"); err != nil {
+		t.Fatalf("held file removed by expiry cleanup: %v", err)
+	}
+
+	holds, err := m.ListLegalHolds(10)
+	if err != nil || len(holds) != 1 || holds[0].FileID != "held" {
+		t.Fatalf("ListLegalHolds = %+v, %v", holds, err)
+	}
+}
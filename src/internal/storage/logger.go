@@ -1,18 +1,27 @@
 package storage
 
 import (
-	"encoding/binary"
+	"bufio"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
 )
 
+// MetadataLogName is the file name of the recovery log inside the data directory
+const MetadataLogName = format.MetadataLogName
+
 // MetadataLogger handles appending file metadata to a recovery log.
 // The underlying file is opened lazily and kept open to avoid repeated open/close overhead.
 type MetadataLogger struct {
-	LogPath string
-	mu      sync.Mutex
-	file    *os.File
+	LogPath  string
+	mu       sync.Mutex
+	file     *os.File
+	rewrites uint64 // ScrubFiles runs that rewrote the log, see Snapshot
 }
 
 // NewMetadataLogger creates a new logger instance
@@ -21,7 +30,7 @@ func NewMetadataLogger(baseDir string) *MetadataLogger {
 	_ = os.MkdirAll(baseDir, 0755)
 
 	return &MetadataLogger{
-		LogPath: filepath.Join(baseDir, "files_metadata.bin"),
+		LogPath: filepath.Join(baseDir, MetadataLogName),
 	}
 }
 
@@ -58,62 +67,153 @@ func (l *MetadataLogger) LogFile(f File) error {
 	}
 	file := l.file
 
-	// Příprava dat do bufferu
-	// Odhad velikosti: ID(36) + BlobID(8) + Time(8) + Flags(1) + Opts(16) + NameLen(2) + Name(N)
-	buf := make([]byte, 0, 128)
+	// Délka záznamu (4 bytes) + samotný záznam, jedním zápisem
+	_, err := file.Write(format.AppendLogFrame(nil, format.EncodeLogRecord(toLogRecord(f))))
+	return err
+}
+
+// toLogRecord converts file metadata to the recovery log record
+func toLogRecord(f File) format.LogRecord {
+	return format.LogRecord{
+		ID:           f.ID,
+		Name:         f.Name,
+		BlobID:       f.BlobID,
+		OldCumulusID: f.OldCumulusID,
+		ExpiresAt:    f.ExpiresAt,
+		CreatedAt:    f.CreatedAt,
+		Tags:         f.Tags,
+		Disposition:  f.Disposition,
+	}
+}
 
-	// 1. ID (String length + String)
-	idBytes := []byte(f.ID)
-	buf = binary.BigEndian.AppendUint16(buf, uint16(len(idBytes)))
-	buf = append(buf, idBytes...)
+// FileFromLogRecord converts a recovery log record to file metadata
+func FileFromLogRecord(r format.LogRecord) File {
+	return File{
+		ID:           r.ID,
+		Name:         r.Name,
+		BlobID:       r.BlobID,
+		OldCumulusID: r.OldCumulusID,
+		ExpiresAt:    r.ExpiresAt,
+		CreatedAt:    r.CreatedAt,
+		Tags:         r.Tags,
+		Disposition:  r.Disposition,
+	}
+}
 
-	// 2. BlobID
-	buf = binary.BigEndian.AppendUint64(buf, uint64(f.BlobID))
+// ReadLogFile reads all decodable records of a metadata log file.
+// Corrupt records are skipped and counted; a partially written last record ends the log.
+func ReadLogFile(path string) (files []File, corrupt int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	reader := format.NewLogReader(f)
+	for {
+		record, err := reader.Next()
+		switch {
+		case err == nil:
+			files = append(files, FileFromLogRecord(record))
+		case errors.Is(err, format.ErrCorruptLogRecord):
+			corrupt++
+		case err == io.EOF, err == io.ErrUnexpectedEOF:
+			if err == io.ErrUnexpectedEOF {
+				corrupt++
+			}
+			return files, corrupt, nil
+		default:
+			return files, corrupt, err
+		}
+	}
+}
 
-	// 3. CreatedAt (Unix Nano)
-	buf = binary.BigEndian.AppendUint64(buf, uint64(f.CreatedAt.UnixNano()))
+// ScrubFiles rewrites the log without the names and tags of the given files: with redact the
+// records stay (an empty name and tags, expired at redactedAt, so a replay never brings the files
+// back), otherwise they are removed. Undecodable records are dropped as well. Returns the number
+// of scrubbed records.
+func (l *MetadataLogger) ScrubFiles(ids map[string]bool, redact bool, redactedAt time.Time) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	// 4. Flags & Optional fields
-	var flags uint8 = 0
-	if f.OldCumulusID != nil {
-		flags |= 1 << 0 // Bit 0 set
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
 	}
-	if f.ExpiresAt != nil {
-		flags |= 1 << 1 // Bit 1 set
+	src, err := os.Open(l.LogPath)
+	if os.IsNotExist(err) {
+		return 0, nil
 	}
-	if f.Tags != "" {
-		flags |= 1 << 2 // Bit 2 set
+	if err != nil {
+		return 0, err
 	}
-	buf = append(buf, flags)
+	defer src.Close()
 
-	if f.OldCumulusID != nil {
-		buf = binary.BigEndian.AppendUint64(buf, uint64(*f.OldCumulusID))
+	tmpPath := l.LogPath + ".scrub"
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, err
 	}
-	if f.ExpiresAt != nil {
-		buf = binary.BigEndian.AppendUint64(buf, uint64(f.ExpiresAt.UnixNano()))
+	defer os.Remove(tmpPath) // no-op after the rename
+
+	scrubbed := 0
+	w := bufio.NewWriter(dst)
+	reader := format.NewLogReader(src)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if errors.Is(err, format.ErrCorruptLogRecord) {
+			continue
+		}
+		if err != nil {
+			dst.Close()
+			return 0, err
+		}
+		if ids[record.ID] {
+			scrubbed++
+			if !redact {
+				continue
+			}
+			expiresAt := redactedAt
+			record.Name, record.Tags, record.Disposition, record.ExpiresAt = "", "", "", &expiresAt
+		}
+		if _, err := w.Write(format.AppendLogFrame(nil, format.EncodeLogRecord(record))); err != nil {
+			dst.Close()
+			return 0, err
+		}
 	}
-	if f.Tags != "" {
-		tagsBytes := []byte(f.Tags)
-		buf = binary.BigEndian.AppendUint16(buf, uint16(len(tagsBytes)))
-		buf = append(buf, tagsBytes...)
+	if err := w.Flush(); err != nil {
+		dst.Close()
+		return 0, err
 	}
-
-	// 5. Name
-	nameBytes := []byte(f.Name)
-	buf = binary.BigEndian.AppendUint16(buf, uint16(len(nameBytes)))
-	buf = append(buf, nameBytes...)
-
-	// Zápis délky celého záznamu (4 bytes) + samotný záznam
-	totalLen := uint32(len(buf))
-	lenBuf := make([]byte, 4)
-	binary.BigEndian.PutUint32(lenBuf, totalLen)
-
-	if _, err := file.Write(lenBuf); err != nil {
-		return err
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return 0, err
 	}
-	if _, err := file.Write(buf); err != nil {
-		return err
+	if err := dst.Close(); err != nil {
+		return 0, err
 	}
+	if err := os.Rename(tmpPath, l.LogPath); err != nil {
+		return 0, err
+	}
+	l.rewrites++
+	return scrubbed, nil
+}
 
-	return nil
+// Snapshot returns the current size of the log and the number of its rewrites. The first size
+// bytes hold whole records and don't change until the next rewrite (ScrubFiles), so a copy made
+// up to size is consistent while rewrites stays the same.
+func (l *MetadataLogger) Snapshot() (size int64, rewrites uint64, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	info, err := os.Stat(l.LogPath)
+	if os.IsNotExist(err) {
+		return 0, l.rewrites, nil
+	}
+	if err != nil {
+		return 0, l.rewrites, err
+	}
+	return info.Size(), l.rewrites, nil
 }
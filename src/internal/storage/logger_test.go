@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetadataLoggerScrubFiles(t *testing.T) {
+	for _, redact := range []bool{false, true} {
+		l := NewMetadataLogger(t.TempDir())
+		created := time.Now().UTC().Truncate(time.Second)
+		for _, f := range []File{
+			{ID: "a", Name: "a.pdf", BlobID: 1, CreatedAt: created, Tags: `["customer-1"]`},
+			{ID: "b", Name: "b.pdf", BlobID: 2, CreatedAt: created, Tags: `["customer-2"]`},
+			{ID: "c", Name: "c.pdf", BlobID: 3, CreatedAt: created, Tags: `["customer-1"]`, Disposition: "attachment"},
+		} {
+			if err := l.LogFile(f); err != nil {
+				t.Fatalf("LogFile: %v", err)
+			}
+		}
+
+		redactedAt := created.Add(time.Hour)
+		n, err := l.ScrubFiles(map[string]bool{"a": true, "c": true, "missing": true}, redact, redactedAt)
+		if err != nil || n != 2 {
+			t.Fatalf("ScrubFiles(redact=%v) = %d, %v, want 2 records", redact, n, err)
+		}
+		// the logger keeps appending after the rewrite
+		if err := l.LogFile(File{ID: "d", Name: "d.pdf", BlobID: 4, CreatedAt: created}); err != nil {
+			t.Fatalf("LogFile after scrub: %v", err)
+		}
+		l.Close()
+
+		files, corrupt, err := ReadLogFile(l.LogPath)
+		if err != nil || corrupt != 0 {
+			t.Fatalf("ReadLogFile = %v, corrupt=%d", err, corrupt)
+		}
+		var ids []string
+		for _, f := range files {
+			ids = append(ids, f.ID)
+			if f.ID == "b" && f.Name != "b.pdf" {
+				t.Fatalf("untouched record changed: %+v", f)
+			}
+			if f.ID == "a" || f.ID == "c" {
+				if f.Name != "" || f.Tags != "" || f.Disposition != "" || f.ExpiresAt == nil || !f.ExpiresAt.Equal(redactedAt) {
+					t.Fatalf("record %s not redacted: %+v", f.ID, f)
+				}
+				if f.BlobID == 0 || f.CreatedAt.IsZero() {
+					t.Fatalf("redacted record %s lost its IDs: %+v", f.ID, f)
+				}
+			}
+		}
+		want := "b,d"
+		if redact {
+			want = "a,b,c,d"
+		}
+		if got := strings.Join(ids, ","); got != want {
+			t.Fatalf("records after ScrubFiles(redact=%v) = %s, want %s", redact, got, want)
+		}
+	}
+}
+
+func TestMetadataLoggerScrubFilesWithoutLog(t *testing.T) {
+	l := NewMetadataLogger(t.TempDir())
+	if n, err := l.ScrubFiles(map[string]bool{"a": true}, false, time.Now()); err != nil || n != 0 {
+		t.Fatalf("ScrubFiles without a log = %d, %v, want 0, nil", n, err)
+	}
+}
@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+)
+
+// Manifest svazku: seznam živých blobů s kontrolními součty. Digest nezávisí na čase vytvoření,
+// takže dvě kopie svazku se stejným obsahem mají stejný digest a off-site kopii lze ověřit
+// porovnáním manifestů nebo kontrolou lokálního souboru proti manifestu bez přenosu dat.
+
+// ManifestVersion is the version of the manifest format (part of the digest)
+const ManifestVersion = 1
+
+// manifestBatchSize is the number of blobs read per volume lock by BuildVolumeManifest
+const manifestBatchSize = 1000
+
+// ErrManifestSignature is returned when a manifest signature is missing or doesn't match
+var ErrManifestSignature = errors.New("manifest signature mismatch")
+
+// ManifestEntry is one live blob of a volume
+type ManifestEntry struct {
+	BlobID      int64  `json:"blobId"`
+	Offset      int64  `json:"offset"` // start of the blob header in the .dat file
+	Size        int64  `json:"size"`   // stored (compressed) size
+	Compression string `json:"compression"`
+	CRC         string `json:"crc"`  // CRC32 footer of the stored data, 8 hex digits
+	Hash        string `json:"hash"` // BLAKE2b-256 of the content (from the database)
+}
+
+// VolumeManifest lists the live blobs of a volume with their checksums
+type VolumeManifest struct {
+	Version   int             `json:"version"`
+	VolumeID  int64           `json:"volumeId"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Blobs     int             `json:"blobs"`
+	Bytes     int64           `json:"bytes"`               // stored size of all blobs
+	Digest    string          `json:"digest"`              // SHA-256 of the canonical entries, see ComputeDigest
+	Signature string          `json:"signature,omitempty"` // HMAC-SHA256 of the digest with the signing key
+	Errors    []string        `json:"errors,omitempty"`    // blobs whose header doesn't match the database
+	Entries   []ManifestEntry `json:"entries"`
+}
+
+// ComputeDigest returns the SHA-256 (hex) of the canonical form: a version line followed by one
+// line "blobId offset size compression crc hash" per entry in blob ID order
+func (m *VolumeManifest) ComputeDigest() string {
+	entries := append([]ManifestEntry(nil), m.Entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].BlobID < entries[j].BlobID })
+
+	h := sha256.New()
+	fmt.Fprintf(h, "cumulus3-volume-manifest v%d volume=%d\n", m.Version, m.VolumeID)
+	for _, e := range entries {
+		fmt.Fprintf(h, "%d %d %d %s %s %s\n", e.BlobID, e.Offset, e.Size, e.Compression, e.CRC, e.Hash)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Sign sets the digest and, with a key, the signature
+func (m *VolumeManifest) Sign(key []byte) {
+	m.Digest = m.ComputeDigest()
+	m.Signature = ""
+	if len(key) > 0 {
+		m.Signature = manifestSignature(key, m.Digest)
+	}
+}
+
+// VerifyIntegrity checks that the digest matches the entries and, with a key, the signature
+func (m *VolumeManifest) VerifyIntegrity(key []byte) error {
+	if digest := m.ComputeDigest(); digest != m.Digest {
+		return fmt.Errorf("manifest digest %s does not match its entries (%s)", m.Digest, digest)
+	}
+	if len(key) == 0 {
+		return nil
+	}
+	if m.Signature == "" || !hmac.Equal([]byte(m.Signature), []byte(manifestSignature(key, m.Digest))) {
+		return ErrManifestSignature
+	}
+	return nil
+}
+
+func manifestSignature(key []byte, digest string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(digest))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func formatCRC(crc uint32) string {
+	return fmt.Sprintf("%08x", crc)
+}
+
+// BuildVolumeManifest lists the committed blobs of the volume from the database with the CRC
+// read from their footers. The volume is read-locked per batch, so compaction can't move blobs
+// between the listing and the read. The manifest is not signed yet, see Sign.
+func (s *Store) BuildVolumeManifest(volumeID int64, meta *MetadataSQL) (*VolumeManifest, error) {
+	m := &VolumeManifest{Version: ManifestVersion, VolumeID: volumeID, CreatedAt: time.Now().UTC(), Entries: []ManifestEntry{}}
+	if _, err := s.VolumePath(volumeID); err != nil {
+		return nil, err
+	}
+
+	afterID := int64(0)
+	for {
+		done, err := s.appendManifestBatch(m, meta, &afterID)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			break
+		}
+	}
+	m.Blobs = len(m.Entries)
+	return m, nil
+}
+
+func (s *Store) appendManifestBatch(m *VolumeManifest, meta *MetadataSQL, afterID *int64) (bool, error) {
+	unlock := s.volumeLocks.RLock(m.VolumeID)
+	defer unlock()
+
+	blobs, err := meta.ListVolumeBlobs(m.VolumeID, *afterID, manifestBatchSize)
+	if err != nil || len(blobs) == 0 {
+		return true, err
+	}
+	path, err := s.VolumePath(m.VolumeID)
+	if err != nil {
+		return true, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return true, fmt.Errorf("cannot open volume file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	for _, b := range blobs {
+		*afterID = b.ID
+		h, crc, err := readBlobFrame(f, b.Offset, b.SizeCompressed)
+		switch {
+		case err != nil:
+			m.Errors = append(m.Errors, fmt.Sprintf("blob %d: %v", b.ID, err))
+			continue
+		case h.BlobID != b.ID || h.Size != b.SizeCompressed:
+			m.Errors = append(m.Errors, fmt.Sprintf("blob %d: header at offset %d says blob %d with %d bytes", b.ID, b.Offset, h.BlobID, h.Size))
+			continue
+		}
+		m.Entries = append(m.Entries, ManifestEntry{
+			BlobID:      b.ID,
+			Offset:      b.Offset,
+			Size:        b.SizeCompressed,
+			Compression: format.CompressionName(format.CompressionCode(b.CompressionAlg)),
+			CRC:         formatCRC(crc),
+			Hash:        b.Hash,
+		})
+		m.Bytes += b.SizeCompressed
+	}
+	return false, nil
+}
+
+// readBlobFrame reads the header and the CRC footer of the blob at offset
+func readBlobFrame(f *os.File, offset, size int64) (format.Header, uint32, error) {
+	header := make([]byte, format.HeaderSize)
+	if _, err := f.ReadAt(header, offset); err != nil {
+		return format.Header{}, 0, fmt.Errorf("cannot read header at offset %d: %w", offset, err)
+	}
+	h, err := format.DecodeHeader(header)
+	if err != nil {
+		return h, 0, fmt.Errorf("invalid header at offset %d: %w", offset, err)
+	}
+	footer := make([]byte, format.FooterSize)
+	if _, err := f.ReadAt(footer, offset+format.HeaderSize+size); err != nil {
+		return h, 0, fmt.Errorf("cannot read footer at offset %d: %w", offset+format.HeaderSize+size, err)
+	}
+	crc, _ := format.DecodeFooter(footer)
+	return h, crc, nil
+}
+
+// ManifestCheck is the result of VerifyVolumeFile
+type ManifestCheck struct {
+	Checked  int      `json:"checked"`
+	OK       int      `json:"ok"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// VerifyVolumeFile checks a copy of the volume file (e.g. an off-site backup) against the
+// manifest: the header and CRC footer of every entry, and with deep also the CRC of the data
+// read from the file. No database is needed.
+func VerifyVolumeFile(path string, m *VolumeManifest, deep bool) (ManifestCheck, error) {
+	var check ManifestCheck
+	f, err := os.Open(path)
+	if err != nil {
+		return check, err
+	}
+	defer f.Close()
+
+	for _, e := range m.Entries {
+		check.Checked++
+		h, crc, err := readBlobFrame(f, e.Offset, e.Size)
+		var problem string
+		switch {
+		case err != nil:
+			problem = err.Error()
+		case h.BlobID != e.BlobID || h.Size != e.Size:
+			problem = fmt.Sprintf("header says blob %d with %d bytes", h.BlobID, h.Size)
+		case format.CompressionName(h.CompAlg) != e.Compression:
+			problem = fmt.Sprintf("compression %s, manifest says %s", format.CompressionName(h.CompAlg), e.Compression)
+		case formatCRC(crc) != e.CRC:
+			problem = fmt.Sprintf("footer CRC %s, manifest says %s", formatCRC(crc), e.CRC)
+		case deep:
+			sum := crc32.NewIEEE()
+			if _, err := io.Copy(sum, io.NewSectionReader(f, e.Offset+format.HeaderSize, e.Size)); err != nil {
+				problem = fmt.Sprintf("cannot read data: %v", err)
+			} else if got := formatCRC(sum.Sum32()); got != e.CRC {
+				problem = fmt.Sprintf("data CRC %s, manifest says %s", got, e.CRC)
+			}
+		}
+		if problem != "" {
+			check.Problems = append(check.Problems, "blob "+strconv.FormatInt(e.BlobID, 10)+": "+problem)
+			continue
+		}
+		check.OK++
+	}
+	return check, nil
+}
+
+// DiffManifests compares two manifests of the same volume (e.g. from two replicas) by blob ID
+// and returns the differences; offsets are compared too, copies of one volume file share them
+func DiffManifests(a, b *VolumeManifest) []string {
+	var diffs []string
+	if a.VolumeID != b.VolumeID {
+		diffs = append(diffs, fmt.Sprintf("volume %d vs %d", a.VolumeID, b.VolumeID))
+	}
+	byID := make(map[int64]ManifestEntry, len(b.Entries))
+	for _, e := range b.Entries {
+		byID[e.BlobID] = e
+	}
+	for _, ea := range a.Entries {
+		eb, ok := byID[ea.BlobID]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("blob %d: only in the first manifest", ea.BlobID))
+			continue
+		}
+		delete(byID, ea.BlobID)
+		if ea != eb {
+			diffs = append(diffs, fmt.Sprintf("blob %d: %s %d %d %s %s vs %s %d %d %s %s", ea.BlobID,
+				ea.CRC, ea.Offset, ea.Size, ea.Compression, ea.Hash, eb.CRC, eb.Offset, eb.Size, eb.Compression, eb.Hash))
+		}
+	}
+	rest := make([]int64, 0, len(byID))
+	for id := range byID {
+		rest = append(rest, id)
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i] < rest[j] })
+	for _, id := range rest {
+		diffs = append(diffs, fmt.Sprintf("blob %d: only in the second manifest", id))
+	}
+	return diffs
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+)
+
+// newManifestTestVolume stores n blobs in volume 1 and returns the store
+func newManifestTestVolume(t *testing.T, m *MetadataSQL, n int) *Store {
+	t.Helper()
+	store := NewStore(t.TempDir(), 1<<20)
+	for i := 0; i < n; i++ {
+		data := bytes.Repeat([]byte{byte('a' + i)}, 100+i)
+		id, err := m.CreateBlob(fmt.Sprintf("hash%d", i))
+		if err != nil {
+			t.Fatalf("CreateBlob: %v", err)
+		}
+		volumeID, offset, _, err := store.WriteBlobWithMetadata(id, bytes.NewReader(data), int64(len(data)), format.CompNone, m)
+		if err != nil {
+			t.Fatalf("WriteBlobWithMetadata: %v", err)
+		}
+		if err := m.UpdateBlobLocation(id, volumeID, offset, int64(len(data)), int64(len(data)), "none", 0); err != nil {
+			t.Fatalf("UpdateBlobLocation: %v", err)
+		}
+	}
+	return store
+}
+
+func TestVolumeManifestSignAndVerifyFile(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	store := newManifestTestVolume(t, m, 3)
+
+	manifest, err := store.BuildVolumeManifest(1, m)
+	if err != nil {
+		t.Fatalf("BuildVolumeManifest: %v", err)
+	}
+	if manifest.Blobs != 3 || len(manifest.Errors) > 0 {
+		t.Fatalf("manifest has %d blobs, errors %v", manifest.Blobs, manifest.Errors)
+	}
+	key := []byte("secret")
+	manifest.Sign(key)
+	if err := manifest.VerifyIntegrity(key); err != nil {
+		t.Fatalf("VerifyIntegrity: %v", err)
+	}
+	if err := manifest.VerifyIntegrity([]byte("other")); !errors.Is(err, ErrManifestSignature) {
+		t.Fatalf("VerifyIntegrity with another key = %v, want ErrManifestSignature", err)
+	}
+
+	// Digest doesn't depend on the creation time, a second manifest of the same volume matches
+	again, err := store.BuildVolumeManifest(1, m)
+	if err != nil {
+		t.Fatalf("BuildVolumeManifest: %v", err)
+	}
+	again.Sign(key)
+	if again.Digest != manifest.Digest || len(DiffManifests(manifest, again)) > 0 {
+		t.Fatalf("manifests of an unchanged volume differ: %s vs %s", manifest.Digest, again.Digest)
+	}
+
+	path, _ := store.VolumePath(1)
+	check, err := VerifyVolumeFile(path, manifest, true)
+	if err != nil || check.OK != 3 || len(check.Problems) > 0 {
+		t.Fatalf("VerifyVolumeFile = %+v, %v", check, err)
+	}
+
+	// Poškozená data druhého blobu: patička sedí, odhalí je jen deep kontrola
+	second := manifest.Entries[1]
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{'X'}, second.Offset+format.HeaderSize)
+	f.Close()
+	if check, _ := VerifyVolumeFile(path, manifest, false); len(check.Problems) > 0 {
+		t.Fatalf("shallow check reported %v", check.Problems)
+	}
+	check, _ = VerifyVolumeFile(path, manifest, true)
+	if len(check.Problems) != 1 || !strings.HasPrefix(check.Problems[0], fmt.Sprintf("blob %d: data CRC", second.BlobID)) {
+		t.Fatalf("deep check problems = %v", check.Problems)
+	}
+}
+
+func TestVolumeManifestTamperedAndDiff(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	store := newManifestTestVolume(t, m, 2)
+
+	a, err := store.BuildVolumeManifest(1, m)
+	if err != nil {
+		t.Fatalf("BuildVolumeManifest: %v", err)
+	}
+	a.Sign(nil)
+
+	b := *a
+	b.Entries = append([]ManifestEntry(nil), a.Entries...)
+	b.Entries[0].CRC = "00000000"
+	if err := b.VerifyIntegrity(nil); err == nil {
+		t.Fatal("VerifyIntegrity accepted an entry changed after signing")
+	}
+	b.Entries = b.Entries[:1]
+	b.Sign(nil)
+
+	diffs := DiffManifests(a, &b)
+	if len(diffs) != 2 {
+		t.Fatalf("DiffManifests = %v, want a changed and a missing blob", diffs)
+	}
+	if !strings.Contains(diffs[1], "only in the first manifest") {
+		t.Fatalf("missing blob not reported: %v", diffs)
+	}
+}
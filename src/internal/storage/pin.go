@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SetFilePinned pins or unpins a file. Pinned files are skipped by expiry cleanup whatever their
+// expires_at; pinning an already pinned file keeps the original pinned_at.
+// Returns sql.ErrNoRows when the file does not exist.
+func (m *MetadataSQL) SetFilePinned(fileID string, pinned bool) error {
+	query := m.buildQuery(`UPDATE files SET pinned_at = NULL WHERE id = ?`)
+	args := []any{fileID}
+	if pinned {
+		query = m.buildQuery(`UPDATE files SET pinned_at = COALESCE(pinned_at, ?) WHERE id = ?`)
+		args = []any{time.Now().UTC(), fileID}
+	}
+	res, err := m.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListPinnedFiles returns up to limit pinned files, most recently pinned first
+func (m *MetadataSQL) ListPinnedFiles(limit int) ([]File, error) {
+	rows, err := m.db.Query(m.buildQuery(`
+		SELECT id, name, blob_id, old_cumulus_id, expires_at, created_at, tags, COALESCE(disposition, ''), pinned_at
+		FROM files
+		WHERE pinned_at IS NOT NULL
+		ORDER BY pinned_at DESC, id
+		LIMIT ?
+	`), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []File{}
+	for rows.Next() {
+		var f File
+		if err := rows.Scan(&f.ID, &f.Name, &f.BlobID, &f.OldCumulusID, &f.ExpiresAt, &f.CreatedAt, &f.Tags, &f.Disposition, &f.PinnedAt); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
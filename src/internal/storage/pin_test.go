@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestPinnedFileSurvivesExpiryCleanup(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blobID := createCommittedBlob(t, m, "pin")
+	expired := time.Now().Add(-time.Hour)
+	for _, id := range []string{"pinned", "unpinned"} {
+		if err := m.SaveFile(File{ID: id, Name: id + ".txt", BlobID: blobID, ExpiresAt: &expired, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("SaveFile(%s): %v", id, err)
+		}
+	}
+
+	if err := m.SetFilePinned("pinned", true); err != nil {
+		t.Fatalf("SetFilePinned: %v", err)
+	}
+	if err := m.SetFilePinned("missing", true); err != sql.ErrNoRows {
+		t.Fatalf("SetFilePinned of a missing file: got %v, want sql.ErrNoRows", err)
+	}
+
+	deleted, totalExpired, _, err := m.CleanupExpiredTemporaryFiles()
+	if err != nil {
+		t.Fatalf("CleanupExpiredTemporaryFiles: %v", err)
+	}
+	if deleted != 1 || totalExpired != 1 {
+		t.Fatalf("cleanup deleted %d of %d expired files, want 1 of 1", deleted, totalExpired)
+	}
+	f, err := m.GetFile("pinned")
+	if err != nil {
+		t.Fatalf("GetFile(pinned): %v", err)
+	}
+	if f.PinnedAt == nil {
+		t.Fatal("pinned file has no pinned_at")
+	}
+	if !blobExists(t, m, blobID) {
+		t.Fatal("blob of the pinned file was freed")
+	}
+
+	pinned, err := m.ListPinnedFiles(10)
+	if err != nil {
+		t.Fatalf("ListPinnedFiles: %v", err)
+	}
+	if len(pinned) != 1 || pinned[0].ID != "pinned" {
+		t.Fatalf("ListPinnedFiles = %+v, want only the pinned file", pinned)
+	}
+
+	// Unpinned file expires again
+	if err := m.SetFilePinned("pinned", false); err != nil {
+		t.Fatalf("SetFilePinned(false): %v", err)
+	}
+	if deleted, _, _, err := m.CleanupExpiredTemporaryFiles(); err != nil || deleted != 1 {
+		t.Fatalf("cleanup after unpin deleted %d files (err %v), want 1", deleted, err)
+	}
+}
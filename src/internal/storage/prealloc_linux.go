@@ -0,0 +1,17 @@
+//go:build linux
+
+package storage
+
+import (
+	"os"
+	"syscall"
+)
+
+// fallocFlKeepSize (FALLOC_FL_KEEP_SIZE) reserves blocks without changing the file size, so appends, readers
+// and recovery tools still see only the written data.
+const fallocFlKeepSize = 0x01
+
+// preallocate reserves size bytes for f on filesystems supporting fallocate (ext4, xfs)
+func preallocate(f *os.File, size int64) error {
+	return syscall.Fallocate(int(f.Fd()), fallocFlKeepSize, 0, size)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package storage
+
+import "os"
+
+// preallocate is a no-op where fallocate is not available
+func preallocate(f *os.File, size int64) error {
+	return nil
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewMetadataSQLReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	m, err := NewMetadataSQL("sqlite", fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000", path))
+	if err != nil {
+		t.Fatalf("NewMetadataSQL: %v", err)
+	}
+	blobID := createCommittedBlob(t, m, "hash1")
+	if err := m.SaveFile(File{ID: "file-1", Name: "a.txt", BlobID: blobID, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	m.Close()
+
+	ro, err := NewMetadataSQLReadOnly("sqlite", fmt.Sprintf("file:%s?_busy_timeout=5000&_query_only=1", path))
+	if err != nil {
+		t.Fatalf("NewMetadataSQLReadOnly: %v", err)
+	}
+	defer ro.Close()
+	if f, err := ro.GetFile("file-1"); err != nil || f.BlobID != blobID {
+		t.Fatalf("GetFile = %+v, %v", f, err)
+	}
+	if _, err := ro.CreateBlob("hash2"); err == nil {
+		t.Error("CreateBlob on a read-only database succeeded")
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.db")
+	if _, err := NewMetadataSQLReadOnly("sqlite", fmt.Sprintf("file:%s?_busy_timeout=5000", empty)); err == nil {
+		t.Error("NewMetadataSQLReadOnly without schema succeeded")
+	}
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+)
+
+// LogReplayResult summarizes a replay of the metadata log into the database
+type LogReplayResult struct {
+	Since       time.Time // records created after this time were considered (zero = whole log)
+	Scanned     int       // records read from the log
+	Candidates  int       // records newer than Since
+	Inserted    int       // file rows re-created
+	Existing    int       // already present in the database
+	Expired     int       // skipped, validity already passed
+	MissingBlob int       // skipped, blob unknown or not committed (needs rebuild-db)
+	Failed      int       // insert failed (e.g. old_cumulus_id conflict)
+	Corrupt     int       // undecodable or incomplete records
+}
+
+// LatestFileCreatedAt returns created_at of the newest file row; ok is false for an empty table.
+func (m *MetadataSQL) LatestFileCreatedAt() (latest time.Time, ok bool, err error) {
+	// ORDER BY místo MAX(): SQLite u agregací ztrácí typ sloupce a vrací text
+	err = m.db.QueryRow(`SELECT created_at FROM files WHERE created_at IS NOT NULL ORDER BY created_at DESC LIMIT 1`).Scan(&latest)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return latest, true, nil
+}
+
+// ReplayMetadataLog re-inserts file rows from the metadata log that are missing in the database.
+// Only records created after the newest file in the database (minus margin) are considered, so
+// a database restored from an older backup catches up with uploads it lost. Records whose blob
+// is not committed in the database are skipped – those need a full rebuild-db.
+func ReplayMetadataLog(logPath string, meta *MetadataSQL, margin time.Duration) (LogReplayResult, error) {
+	var result LogReplayResult
+
+	latest, ok, err := meta.LatestFileCreatedAt()
+	if err != nil {
+		return result, err
+	}
+	if ok {
+		result.Since = latest.Add(-margin)
+	}
+
+	f, err := os.Open(logPath)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	defer f.Close()
+
+	now := time.Now()
+	blobCommitted := make(map[int64]bool)
+	reader := format.NewLogReader(f)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, format.ErrCorruptLogRecord) {
+			result.Corrupt++
+			continue
+		}
+		if err == io.ErrUnexpectedEOF {
+			result.Corrupt++ // přerušený zápis posledního záznamu
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Scanned++
+
+		if !result.Since.IsZero() && !record.CreatedAt.After(result.Since) {
+			continue
+		}
+		result.Candidates++
+
+		if record.ExpiresAt != nil && record.ExpiresAt.Before(now) {
+			result.Expired++
+			continue
+		}
+
+		if _, err := meta.GetFile(record.ID); err == nil {
+			result.Existing++
+			continue
+		} else if err != sql.ErrNoRows {
+			return result, err
+		}
+
+		committed, known := blobCommitted[record.BlobID]
+		if !known {
+			blob, err := meta.GetBlob(record.BlobID)
+			if err != nil && err != sql.ErrNoRows {
+				return result, err
+			}
+			committed = err == nil && blob.State == "committed"
+			blobCommitted[record.BlobID] = committed
+		}
+		if !committed {
+			result.MissingBlob++
+			continue
+		}
+
+		file := FileFromLogRecord(record)
+		if err := meta.SaveFile(file); err != nil {
+			log.Printf("Log replay: failed to insert file %s (blob %d): %v", file.ID, file.BlobID, err)
+			result.Failed++
+			continue
+		}
+		if file.OldCumulusID != nil {
+			if err := meta.EnsureOldCumulusIDAtLeast(*file.OldCumulusID); err != nil {
+				log.Printf("Log replay: failed to advance old_cumulus_id counter to %d: %v", *file.OldCumulusID, err)
+			}
+		}
+		result.Inserted++
+	}
+
+	return result, nil
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Minimální S3 klient (path-style, AWS Signature V4) pro zálohy metadat – stačí PUT, GET, LIST
+// a DELETE objektů, proto bez SDK. Funguje s AWS S3 i s kompatibilními úložišti (MinIO, Ceph).
+
+// EmptyPayloadSHA256 is the SHA-256 of an empty request body
+const EmptyPayloadSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Client accesses one bucket of an S3-compatible object storage
+type S3Client struct {
+	Endpoint  string // e.g. https://s3.eu-central-1.amazonaws.com or http://minio:9000
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// S3Error is an error response of the object storage
+type S3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *S3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsS3NotFound reports whether err is a missing object or bucket
+func IsS3NotFound(err error) bool {
+	e, ok := err.(*S3Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// PutObject uploads size bytes of body under key. payloadSHA256 is the hex SHA-256 of the body;
+// it is signed, so the storage rejects a body damaged in transit.
+func (c *S3Client) PutObject(key string, body io.Reader, size int64, payloadSHA256 string) error {
+	req, err := c.newRequest(http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	resp, err := c.do(req, payloadSHA256)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetObject downloads the object under key; the caller closes the returned body
+func (c *S3Client) GetObject(key string) (io.ReadCloser, error) {
+	req, err := c.newRequest(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req, EmptyPayloadSHA256)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// DeleteObject removes the object under key; a missing object is not an error
+func (c *S3Client) DeleteObject(key string) error {
+	req, err := c.newRequest(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, EmptyPayloadSHA256)
+	if err != nil {
+		if IsS3NotFound(err) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// S3Listing is the content of a prefix: object keys and, with a delimiter, the common prefixes
+// ("directories") below it
+type S3Listing struct {
+	Keys     []string
+	Prefixes []string
+}
+
+// List lists the objects under prefix (all pages). With delimiter "/" keys deeper than one level
+// are grouped into Prefixes.
+func (c *S3Client) List(prefix, delimiter string) (S3Listing, error) {
+	var listing S3Listing
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := c.newRequest(http.MethodGet, "", query, nil)
+		if err != nil {
+			return listing, err
+		}
+		resp, err := c.do(req, EmptyPayloadSHA256)
+		if err != nil {
+			return listing, err
+		}
+		var page struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			CommonPrefixes []struct {
+				Prefix string `xml:"Prefix"`
+			} `xml:"CommonPrefixes"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return listing, fmt.Errorf("s3: invalid list response: %w", err)
+		}
+		for _, o := range page.Contents {
+			listing.Keys = append(listing.Keys, o.Key)
+		}
+		for _, p := range page.CommonPrefixes {
+			listing.Prefixes = append(listing.Prefixes, p.Prefix)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return listing, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (c *S3Client) newRequest(method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	u, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("s3: invalid endpoint: %w", err)
+	}
+	u.Path = "/" + c.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = s3EncodePath(u.Path)
+	if query != nil {
+		u.RawQuery = s3CanonicalQuery(query)
+	}
+	return http.NewRequest(method, u.String(), body)
+}
+
+func (c *S3Client) do(req *http.Request, payloadSHA256 string) (*http.Response, error) {
+	c.sign(req, payloadSHA256, time.Now())
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		e := &S3Error{StatusCode: resp.StatusCode}
+		xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(e)
+		return nil, e
+	}
+	return resp, nil
+}
+
+// sign adds the AWS Signature V4 Authorization header. Signed are the host and all headers
+// already set on the request.
+func (c *S3Client) sign(req *http.Request, payloadSHA256 string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadSHA256)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadSHA256,
+	}, "\n")
+	scope := day + "/" + c.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), day)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// s3Escape percent-encodes everything except the unreserved characters (RFC 3986), as SigV4 requires
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~', ch == '/' && keepSlash:
+			b.WriteByte(ch)
+		default:
+			b.WriteString("%" + strings.ToUpper(strconv.FormatUint(uint64(ch)|0x100, 16)[1:]))
+		}
+	}
+	return b.String()
+}
+
+func s3EncodePath(path string) string {
+	return s3Escape(path, true)
+}
+
+// s3CanonicalQuery encodes the query sorted by key, as both sent and signed
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
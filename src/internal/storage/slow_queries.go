@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSlowQueryText caps the SQL text kept per entry (batch inserts are long)
+const maxSlowQueryText = 2000
+
+// SlowQuery is a metadata query that took at least the slow query threshold
+type SlowQuery struct {
+	Time       time.Time `json:"time"`
+	DurationMs float64   `json:"durationMs"`
+	Op         string    `json:"op"` // exec | query | query_row
+	Query      string    `json:"query"`
+	Args       int       `json:"args"` // number of arguments; their values are not kept
+	Error      string    `json:"error,omitempty"`
+}
+
+// SlowQueryLog keeps the last slow metadata queries in a ring buffer
+type SlowQueryLog struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	entries   []SlowQuery
+	next      int
+	total     uint64
+}
+
+// NewSlowQueryLog creates a log of the last size queries slower than threshold
+func NewSlowQueryLog(threshold time.Duration, size int) *SlowQueryLog {
+	if size < 1 {
+		size = 1
+	}
+	return &SlowQueryLog{threshold: threshold, entries: make([]SlowQuery, 0, size)}
+}
+
+// Threshold returns the duration from which a query is recorded
+func (l *SlowQueryLog) Threshold() time.Duration {
+	return l.threshold
+}
+
+func (l *SlowQueryLog) observe(op, query string, args int, start time.Time, err error) {
+	d := time.Since(start)
+	if d < l.threshold {
+		return
+	}
+	q := SlowQuery{
+		Time:       start,
+		DurationMs: float64(d.Microseconds()) / 1000,
+		Op:         op,
+		Query:      strings.Join(strings.Fields(query), " "),
+		Args:       args,
+	}
+	if len(q.Query) > maxSlowQueryText {
+		q.Query = q.Query[:maxSlowQueryText] + "…"
+	}
+	if err != nil {
+		q.Error = err.Error()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) < cap(l.entries) {
+		l.entries = append(l.entries, q)
+	} else {
+		l.entries[l.next] = q
+	}
+	l.next = (l.next + 1) % cap(l.entries)
+	l.total++
+}
+
+// Entries returns the kept slow queries, most recent first, and the number recorded since start
+func (l *SlowQueryLog) Entries() ([]SlowQuery, uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]SlowQuery, 0, len(l.entries))
+	for i := 1; i <= len(l.entries); i++ {
+		out = append(out, l.entries[(l.next-i+cap(l.entries))%cap(l.entries)])
+	}
+	return out, l.total
+}
+
+// SetSlowQueryLog installs the log of slow queries; nil disables it. Call it before the store
+// is used concurrently. Statements inside write transactions are not timed one by one, the
+// transactions are reported to the TxObserver.
+func (m *MetadataSQL) SetSlowQueryLog(l *SlowQueryLog) {
+	m.db.slow = l
+}
+
+// queryDB times the statements run directly on the connection pool for the slow query log.
+// The time includes waiting for a free connection (SQLite has one) and, for Query, ends when
+// the first rows are available; iterating the rows is not counted.
+type queryDB struct {
+	*sql.DB
+	slow *SlowQueryLog
+}
+
+func (db *queryDB) Exec(query string, args ...any) (sql.Result, error) {
+	if db.slow == nil {
+		return db.DB.Exec(query, args...)
+	}
+	start := time.Now()
+	res, err := db.DB.Exec(query, args...)
+	db.slow.observe("exec", query, len(args), start, err)
+	return res, err
+}
+
+func (db *queryDB) Query(query string, args ...any) (*sql.Rows, error) {
+	if db.slow == nil {
+		return db.DB.Query(query, args...)
+	}
+	start := time.Now()
+	rows, err := db.DB.Query(query, args...)
+	db.slow.observe("query", query, len(args), start, err)
+	return rows, err
+}
+
+func (db *queryDB) QueryRow(query string, args ...any) *sql.Row {
+	if db.slow == nil {
+		return db.DB.QueryRow(query, args...)
+	}
+	start := time.Now()
+	row := db.DB.QueryRow(query, args...)
+	db.slow.observe("query_row", query, len(args), start, row.Err())
+	return row
+}
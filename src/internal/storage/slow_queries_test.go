@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowQueryLog(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	m.SetSlowQueryLog(NewSlowQueryLog(time.Nanosecond, 3))
+
+	blobID := createCommittedBlob(t, m, "hash-a")
+	saveTestFile(t, m, "file-1", blobID)
+	if _, err := m.GetFile("file-1"); err != nil {
+		t.Fatalf("GetFile: %v", err)
+	}
+
+	entries, total := m.db.slow.Entries()
+	if len(entries) != 3 || total < 3 {
+		t.Fatalf("kept %d of %d queries, want the last 3", len(entries), total)
+	}
+	// Nejnovější první: GetFile je poslední dotaz
+	last := entries[0]
+	if last.Op != "query_row" || !strings.HasPrefix(last.Query, "SELECT id, name, blob_id") || last.Args != 1 {
+		t.Errorf("latest entry = %+v, want the GetFile query with 1 arg", last)
+	}
+	if strings.Contains(last.Query, "\n") || strings.Contains(last.Query, "file-1") {
+		t.Errorf("query text %q not normalized or contains argument values", last.Query)
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Time.After(entries[i-1].Time) {
+			t.Errorf("entries not ordered most recent first: %v after %v", entries[i].Time, entries[i-1].Time)
+		}
+	}
+
+	m.SetSlowQueryLog(NewSlowQueryLog(time.Hour, 3))
+	if _, err := m.GetFile("file-1"); err != nil {
+		t.Fatalf("GetFile: %v", err)
+	}
+	if entries, total := m.db.slow.Entries(); len(entries) != 0 || total != 0 {
+		t.Errorf("query under the threshold recorded: %+v", entries)
+	}
+}
@@ -0,0 +1,49 @@
+package storage
+
+import "time"
+
+// StatsHistoryRetention is how long disk usage samples are kept
+const StatsHistoryRetention = 90 * 24 * time.Hour
+
+// DiskUsageSample is the disk usage of one data directory at one time
+type DiskUsageSample struct {
+	Time       time.Time `json:"time"`
+	Dir        string    `json:"dir"` // name of the directory, e.g. "data"
+	Path       string    `json:"path"`
+	TotalBytes int64     `json:"totalBytes"`
+	FreeBytes  int64     `json:"freeBytes"`
+}
+
+// SaveDiskUsageSample stores a sample into storage_stats_history and prunes samples past the retention
+func (m *MetadataSQL) SaveDiskUsageSample(s DiskUsageSample) error {
+	query := m.buildQuery(`INSERT INTO storage_stats_history (created_at, dir, path, total_bytes, free_bytes) VALUES (?, ?, ?, ?, ?)`)
+	if _, err := m.db.Exec(query, s.Time.UTC(), s.Dir, s.Path, s.TotalBytes, s.FreeBytes); err != nil {
+		return err
+	}
+	_, err := m.db.Exec(m.buildQuery(`DELETE FROM storage_stats_history WHERE created_at < ?`), s.Time.UTC().Add(-StatsHistoryRetention))
+	return err
+}
+
+// GetDiskUsageHistory returns the samples taken since the given time, oldest first
+func (m *MetadataSQL) GetDiskUsageHistory(since time.Time) ([]DiskUsageSample, error) {
+	rows, err := m.db.Query(m.buildQuery(`
+		SELECT created_at, dir, path, total_bytes, free_bytes
+		FROM storage_stats_history
+		WHERE created_at >= ?
+		ORDER BY created_at, id
+	`), since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []DiskUsageSample
+	for rows.Next() {
+		var s DiskUsageSample
+		if err := rows.Scan(&s.Time, &s.Dir, &s.Path, &s.TotalBytes, &s.FreeBytes); err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
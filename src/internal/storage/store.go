@@ -1,8 +1,7 @@
 package storage
 
 import (
-	"database/sql"
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"io"
@@ -12,36 +11,46 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
 )
 
+// On-disk layout constants, see package format
 const (
-	MagicBytes = 0x43554D55
-	Version    = 1
-	// Header: Magic(4) + Ver(1) + Comp(1) + Size(8) + BlobID(8)
-	HeaderSize = 4 + 1 + 1 + 8 + 8
-	FooterSize = 4
+	MagicBytes = format.MagicBytes
+	Version    = format.Version
+	HeaderSize = format.HeaderSize
+	FooterSize = format.FooterSize
+)
+
+// Errors of WriteBlobToVolume
+var (
+	ErrVolumeNotWritable = errors.New("volume is not open for writes")
+	ErrVolumeFull        = errors.New("volume has no space left")
 )
 
+// ErrVolumeMissing is returned by ReadBlob when the volume data file doesn't exist
+var ErrVolumeMissing = errors.New("volume file missing")
+
 // Store reprezentuje naše úložiště
 type Store struct {
 	BaseDir         string
 	MaxDataFileSize int64
+	Preallocate     bool // reserve MaxDataFileSize on disk when a volume is created (fallocate, Linux)
 	mu              sync.Mutex
 	CurrentVolumeID int64
-	volumeLocks     sync.Map // map[int64]*sync.RWMutex
+	volumeLocks     *volumeLocks
 }
 
 // NewStore vytvoří novou instanci a připraví složku
 func NewStore(dir string, maxDataFileSize int64) *Store {
 	_ = os.MkdirAll(dir, 0755)
 
-	// Find the highest volume ID from existing volume files using Glob (O(1) instead of O(N) stat loop)
+	// Highest volume ID from a single directory scan (no per-volume stat, slow on network filesystems)
 	currentVolumeID := int64(1)
-	if matches, err := filepath.Glob(filepath.Join(dir, "volume_*.dat")); err == nil {
-		for _, match := range matches {
-			base := filepath.Base(match)
-			numStr := strings.TrimSuffix(strings.TrimPrefix(base, "volume_"), ".dat")
-			if id, err := strconv.ParseInt(numStr, 10, 64); err == nil && id > currentVolumeID {
+	if volumes, err := scanVolumeFiles(dir); err == nil {
+		for id := range volumes {
+			if id > currentVolumeID {
 				currentVolumeID = id
 			}
 		}
@@ -51,12 +60,58 @@ func NewStore(dir string, maxDataFileSize int64) *Store {
 		BaseDir:         dir,
 		MaxDataFileSize: maxDataFileSize,
 		CurrentVolumeID: currentVolumeID,
+		volumeLocks:     newVolumeLocks(),
+	}
+}
+
+// scanVolumeFiles lists volume data files in dir with one directory read and returns their
+// paths by volume ID. The 8-digit name wins over the legacy one when both exist.
+func scanVolumeFiles(dir string) (map[int64]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	volumes := make(map[int64]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "volume_") || !strings.HasSuffix(name, ".dat") {
+			continue
+		}
+		numStr := strings.TrimSuffix(strings.TrimPrefix(name, "volume_"), ".dat")
+		id, err := strconv.ParseInt(numStr, 10, 64)
+		if err != nil || id <= 0 {
+			continue
+		}
+		if _, seen := volumes[id]; seen && len(numStr) != 8 {
+			continue
+		}
+		volumes[id] = filepath.Join(dir, name)
 	}
+	return volumes, nil
+}
+
+// VolumeLockStats returns contention counters of the per-volume locks
+func (s *Store) VolumeLockStats() VolumeLockStats {
+	return s.volumeLocks.Stats()
 }
 
-func (s *Store) getVolumeLock(volumeID int64) *sync.RWMutex {
-	v, _ := s.volumeLocks.LoadOrStore(volumeID, &sync.RWMutex{})
-	return v.(*sync.RWMutex)
+// VolumePath returns the absolute path of the volume file, preferring the
+// 8-digit name and falling back to the legacy one when only that exists.
+func (s *Store) VolumePath(volumeID int64) (string, error) {
+	fullPath := filepath.Join(s.BaseDir, fmt.Sprintf("volume_%08d.dat", volumeID))
+	if _, err := os.Stat(fullPath); err == nil {
+		return filepath.Abs(fullPath)
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	fullPathLegacy := filepath.Join(s.BaseDir, fmt.Sprintf("volume_%d.dat", volumeID))
+	if _, err := os.Stat(fullPathLegacy); os.IsNotExist(err) {
+		return "", fmt.Errorf("%w for volume %d: %w", ErrVolumeMissing, volumeID, err)
+	} else if err != nil {
+		return "", err
+	}
+	return filepath.Abs(fullPathLegacy)
 }
 
 // RecalculateCurrentVolume finds the first volume that has space available
@@ -102,12 +157,17 @@ func (s *Store) recalculateCurrentVolumeNoLock() {
 
 // findVolumeWithSpaceNoLock finds first volume (from 1 to current) that has enough space
 // Uses database metadata if available, otherwise falls back to file system
+// Volumes that are not open (sealed, read-only, ...) are never chosen when metadata is available.
 // skipLocked: if true, skips volumes that are currently locked (e.g., being compacted)
 // Returns volume ID to use. Call this when you already hold s.mu.Lock()
 func (s *Store) findVolumeWithSpaceNoLock(requiredSize int64, meta *MetadataSQL, skipLocked bool) int64 {
+	var closed map[int64]string
 	if meta != nil {
 		// Use database values (source of truth)
 		volumes, err := meta.GetVolumesToCompact(0) // Get all volumes
+		if err == nil {
+			closed, err = meta.GetNonWritableVolumes()
+		}
 		if err == nil {
 			// Build a map for quick lookup
 			volMap := make(map[int64]int64) // volumeID -> size_total
@@ -117,6 +177,9 @@ func (s *Store) findVolumeWithSpaceNoLock(requiredSize int64, meta *MetadataSQL,
 
 			// Check each volume from 1 to current
 			for volumeID := int64(1); volumeID <= s.CurrentVolumeID; volumeID++ {
+				if _, isClosed := closed[volumeID]; isClosed {
+					continue
+				}
 				// Check if volume exists in DB
 				sizeTotal, exists := volMap[volumeID]
 				if !exists {
@@ -136,13 +199,12 @@ func (s *Store) findVolumeWithSpaceNoLock(requiredSize int64, meta *MetadataSQL,
 	// Fallback to file system check (when no metadata available or volume not in DB yet)
 	// Try existing volumes first (from 1 to current)
 	for volumeID := int64(1); volumeID <= s.CurrentVolumeID; volumeID++ {
+		if _, isClosed := closed[volumeID]; isClosed {
+			continue
+		}
 		// Skip locked volumes if requested
-		if skipLocked {
-			lock := s.getVolumeLock(volumeID)
-			if !lock.TryLock() {
-				continue
-			}
-			lock.Unlock()
+		if skipLocked && s.volumeLocks.IsLocked(volumeID) {
+			continue
 		}
 
 		filename := fmt.Sprintf("volume_%08d.dat", volumeID)
@@ -202,7 +264,7 @@ func (s *Store) WriteBlob(blobID int64, r io.Reader, size int64, compressionAlg
 // WriteBlobWithMetadata zapíše data do volume souboru s využitím DB metadat pro nalezení volume s místem
 // Returns: volumeID, offset, totalBytesWritten (including header and footer), error
 func (s *Store) WriteBlobWithMetadata(blobID int64, r io.Reader, size int64, compressionAlg uint8, meta *MetadataSQL) (volumeID int64, offset int64, totalSize int64, err error) {
-	totalEntrySize := int64(HeaderSize) + size + int64(FooterSize)
+	totalEntrySize := format.BlobTotalSize(size)
 
 	// Find a volume with enough space (tries from volume 1 up to current)
 	// Skip locked volumes (e.g., being compacted) to avoid blocking
@@ -212,8 +274,6 @@ func (s *Store) WriteBlobWithMetadata(blobID int64, r io.Reader, size int64, com
 
 	// Try to write to selected volume, with retry if it's full
 	// This handles race condition where multiple goroutines pass the initial check
-	var f *os.File
-	var filename, fullPath string
 	triedVolumes := make(map[int64]bool) // Track which volumes we already tried
 	maxRetries := 100                    // Prevent infinite loop
 
@@ -234,28 +294,27 @@ func (s *Store) WriteBlobWithMetadata(blobID int64, r io.Reader, size int64, com
 		triedVolumes[targetVol] = true
 
 		// Lock this specific volume to allow parallel writes to different volumes
-		volLock := s.getVolumeLock(targetVol)
-		volLock.Lock()
+		unlockVol := s.volumeLocks.Lock(targetVol)
 
 		// Double-check if volume still has space after acquiring lock
 		// Another goroutine might have filled it while we were waiting
+		var volInfo VolumeWriteInfo
 		if meta != nil {
-			currentSize, err := meta.GetVolumeSize(targetVol)
-			if err != nil && err != sql.ErrNoRows {
-				// Database error (not just missing row)
-				volLock.Unlock()
+			volInfo, err = meta.GetVolumeWriteInfo(targetVol)
+			if err != nil {
+				unlockVol()
 				return 0, 0, 0, fmt.Errorf("failed to check volume size: %w", err)
 			}
-			// If err == sql.ErrNoRows, currentSize stays 0 (new volume)
+			currentSize, state := volInfo.SizeTotal, volInfo.State
 
-			if currentSize+totalEntrySize > s.MaxDataFileSize {
-				// Volume is full after all, unlock and try next one
-				volLock.Unlock()
+			if !VolumeStateWritable(state) || currentSize+totalEntrySize > s.MaxDataFileSize {
+				// Volume is full after all (or no longer open), unlock and try next one
+				unlockVol()
 
 				// Log if we've tried many volumes already
 				if len(triedVolumes) > 10 {
-					log.Printf("WARNING: Volume %d is full (size=%d, required=%d, max=%d), tried %d volumes so far",
-						targetVol, currentSize, totalEntrySize, s.MaxDataFileSize, len(triedVolumes))
+					log.Printf("WARNING: Volume %d is full or not open (state=%s, size=%d, required=%d, max=%d), tried %d volumes so far",
+						targetVol, state, currentSize, totalEntrySize, s.MaxDataFileSize, len(triedVolumes))
 				}
 
 				// Try next volume
@@ -272,81 +331,126 @@ func (s *Store) WriteBlobWithMetadata(blobID int64, r io.Reader, size int64, com
 		}
 
 		// Volume has space, proceed with write
+		offset, _, err = s.appendBlobNoLock(targetVol, volInfo, blobID, r, size, compressionAlg, meta)
+		unlockVol()
+		if err != nil {
+			return 0, 0, 0, err
+		}
 		volumeID = targetVol
-		filename = fmt.Sprintf("volume_%08d.dat", targetVol)
-		fullPath = filepath.Join(s.BaseDir, filename)
 
-		// If new format doesn't exist, check if legacy exists
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			filenameLegacy := fmt.Sprintf("volume_%d.dat", targetVol)
-			fullPathLegacy := filepath.Join(s.BaseDir, filenameLegacy)
-			if _, err := os.Stat(fullPathLegacy); err == nil {
-				filename = filenameLegacy
-				fullPath = fullPathLegacy
-			}
-		}
+		// Success, break out of retry loop
+		break
+	}
 
-		f, err = os.OpenFile(fullPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			volLock.Unlock()
-			return 0, 0, 0, err
+	// Check if we exited loop without success (reached max retries)
+	if volumeID == 0 {
+		return 0, 0, 0, fmt.Errorf("failed to write blob after trying %d volumes: all volumes are full or locked", len(triedVolumes))
+	}
+
+	// Return actual bytes written (header + data + footer)
+	totalBytesWritten := format.BlobTotalSize(size)
+	return volumeID, offset, totalBytesWritten, nil
+}
+
+// WriteBlobToVolume writes the blob into the given existing volume (e.g. an admin move). Fails with
+// ErrVolumeNotWritable when the volume is not open and ErrVolumeFull when the blob doesn't fit.
+// Returns the offset and the CRC32 of the written data.
+func (s *Store) WriteBlobToVolume(volumeID, blobID int64, r io.Reader, size int64, compressionAlg uint8, meta *MetadataSQL) (int64, uint32, error) {
+	if _, err := s.VolumePath(volumeID); err != nil {
+		return 0, 0, err
+	}
+
+	unlock := s.volumeLocks.Lock(volumeID)
+	defer unlock()
+
+	volInfo, err := meta.GetVolumeWriteInfo(volumeID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to check volume size: %w", err)
+	}
+	if !VolumeStateWritable(volInfo.State) {
+		return 0, 0, fmt.Errorf("%w: volume %d is %s", ErrVolumeNotWritable, volumeID, volInfo.State)
+	}
+	if volInfo.SizeTotal+format.BlobTotalSize(size) > s.MaxDataFileSize {
+		return 0, 0, fmt.Errorf("%w: volume %d (size %d, required %d, max %d)", ErrVolumeFull, volumeID, volInfo.SizeTotal, format.BlobTotalSize(size), s.MaxDataFileSize)
+	}
+	return s.appendBlobNoLock(volumeID, volInfo, blobID, r, size, compressionAlg, meta)
+}
+
+// appendBlobNoLock writes the blob at the append position of the volume, adds it to the .meta
+// index and accounts it in the volumes table (before the caller releases the volume write lock,
+// so concurrent writers never see a stale size_total).
+func (s *Store) appendBlobNoLock(volumeID int64, volInfo VolumeWriteInfo, blobID int64, r io.Reader, size int64, compressionAlg uint8, meta *MetadataSQL) (offset int64, crc uint32, err error) {
+	filename := fmt.Sprintf("volume_%08d.dat", volumeID)
+	fullPath := filepath.Join(s.BaseDir, filename)
+
+	// If new format doesn't exist, check if legacy exists
+	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		filenameLegacy := fmt.Sprintf("volume_%d.dat", volumeID)
+		fullPathLegacy := filepath.Join(s.BaseDir, filenameLegacy)
+		if _, err := os.Stat(fullPathLegacy); err == nil {
+			filename = filenameLegacy
+			fullPath = fullPathLegacy
 		}
-		defer f.Close()
-		defer volLock.Unlock()
+	}
+
+	// Bez O_APPEND: pozice zápisu je append_offset z DB (soubor může být předalokovaný)
+	f, err := os.OpenFile(fullPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
 
+	if volInfo.OffsetKnown {
+		offset = volInfo.AppendOffset
+	} else {
+		// Volume written before offsets were tracked (or no metadata): fall back to the file size
 		stat, err := f.Stat()
 		if err != nil {
-			return 0, 0, 0, err
+			return 0, 0, err
 		}
 		offset = stat.Size()
-
-		// Write blob to the end of file
-		crc, err := s.writeBlobData(f, blobID, r, size, compressionAlg)
-		if err != nil {
-			return 0, 0, 0, err
-		}
-
-		// Write to META file (Index)
-		metaFilename := strings.TrimSuffix(filename, ".dat") + ".meta"
-		metaPath := filepath.Join(s.BaseDir, metaFilename)
-		if err := s.writeMetaRecord(metaPath, blobID, offset, size, compressionAlg, crc); err != nil {
-			return 0, 0, 0, err
-		}
-
-		// Durability: ensure volume payload and metadata index hit disk before success.
-		if err := f.Sync(); err != nil {
-			return 0, 0, 0, fmt.Errorf("failed to sync volume file: %w", err)
+	}
+	if offset == 0 && s.Preallocate {
+		if err := preallocate(f, s.MaxDataFileSize); err != nil {
+			log.Printf("WARNING: failed to preallocate volume %d: %v", volumeID, err)
 		}
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
 
-		// Update volumes table BEFORE releasing lock to ensure atomic check + update
-		// This prevents race condition where multiple goroutines read old size_total
-		totalBytesWritten := int64(HeaderSize) + size + int64(FooterSize)
-		if meta != nil {
-			if err := meta.AddWrittenBytesToVolume(volumeID, totalBytesWritten); err != nil {
-				return 0, 0, 0, fmt.Errorf("failed to update volume size: %w", err)
-			}
-		}
+	// Write blob to the end of file
+	crc, err = s.writeBlobData(f, blobID, r, size, compressionAlg)
+	if err != nil {
+		return 0, 0, err
+	}
 
-		// Success, break out of retry loop
-		break
+	// Write to META file (Index)
+	metaFilename := strings.TrimSuffix(filename, ".dat") + ".meta"
+	metaPath := filepath.Join(s.BaseDir, metaFilename)
+	if err := s.writeMetaRecord(metaPath, blobID, offset, size, compressionAlg, crc); err != nil {
+		return 0, 0, err
 	}
 
-	// Check if we exited loop without success (reached max retries)
-	if volumeID == 0 {
-		return 0, 0, 0, fmt.Errorf("failed to write blob after trying %d volumes: all volumes are full or locked", len(triedVolumes))
+	// Durability: ensure volume payload and metadata index hit disk before success.
+	if err := f.Sync(); err != nil {
+		return 0, 0, fmt.Errorf("failed to sync volume file: %w", err)
 	}
 
-	// Return actual bytes written (header + data + footer)
-	totalBytesWritten := int64(HeaderSize) + size + int64(FooterSize)
-	return volumeID, offset, totalBytesWritten, nil
+	totalBytesWritten := format.BlobTotalSize(size)
+	if meta != nil {
+		if err := meta.RecordVolumeAppend(volumeID, totalBytesWritten, offset+totalBytesWritten); err != nil {
+			return 0, 0, fmt.Errorf("failed to update volume size: %w", err)
+		}
+	}
+	return offset, crc, nil
 }
 
 // ReadBlob přečte data z volume souboru
 func (s *Store) ReadBlob(volumeID int64, offset int64, size int64) ([]byte, error) {
 	// Use RLock to allow parallel reads, but block during compaction (which uses Lock)
-	lock := s.getVolumeLock(volumeID)
-	lock.RLock()
-	defer lock.RUnlock()
+	unlock := s.volumeLocks.RLock(volumeID)
+	defer unlock()
 
 	filename := fmt.Sprintf("volume_%08d.dat", volumeID)
 	fullPath := filepath.Join(s.BaseDir, filename)
@@ -357,8 +461,10 @@ func (s *Store) ReadBlob(volumeID int64, offset int64, size int64) ([]byte, erro
 		filenameLegacy := fmt.Sprintf("volume_%d.dat", volumeID)
 		fullPathLegacy := filepath.Join(s.BaseDir, filenameLegacy)
 		f, err = os.Open(fullPathLegacy)
-		if err != nil {
-			return nil, fmt.Errorf("volume file not found (tried %s and %s): %w", filename, filenameLegacy, err)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w (tried %s and %s): %w", ErrVolumeMissing, filename, filenameLegacy, err)
+		} else if err != nil {
+			return nil, fmt.Errorf("cannot open volume file %s: %w", fullPathLegacy, err)
 		}
 		fullPath = fullPathLegacy
 	} else if err != nil {
@@ -366,6 +472,11 @@ func (s *Store) ReadBlob(volumeID int64, offset int64, size int64) ([]byte, erro
 	}
 	defer f.Close()
 
+	return readBlobAt(f, fullPath, offset, size)
+}
+
+// readBlobAt reads the blob at offset from the open volume file and checks its header and CRC
+func readBlobAt(f *os.File, fullPath string, offset int64, size int64) ([]byte, error) {
 	// Get file size for validation
 	stat, err := f.Stat()
 	if err != nil {
@@ -395,18 +506,15 @@ func (s *Store) ReadBlob(volumeID int64, offset int64, size int64) ([]byte, erro
 		return nil, fmt.Errorf("cannot read header at offset %d: %w", offset, err)
 	}
 
-	magic := binary.BigEndian.Uint32(header[0:4])
-	ver := header[4]
-	comp := header[5]
-	storedSize := int64(binary.BigEndian.Uint64(header[6:14]))
-	blobID := int64(binary.BigEndian.Uint64(header[14:22]))
-
-	if magic != uint32(MagicBytes) {
-		return nil, fmt.Errorf("bad magic bytes at offset %d: got 0x%X, expected 0x%X", offset, magic, MagicBytes)
+	h, err := format.DecodeHeader(header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid header at offset %d: %w", offset, err)
 	}
+	storedSize := h.Size
+	blobID := h.BlobID
 	if storedSize != size {
 		return nil, fmt.Errorf("size mismatch at offset %d: header says %d, metadata says %d (blobID: %d, ver: %d, comp: %d)",
-			offset, storedSize, size, blobID, ver, comp)
+			offset, storedSize, size, blobID, h.Version, h.CompAlg)
 	}
 
 	// 2. Data
@@ -421,7 +529,7 @@ func (s *Store) ReadBlob(volumeID int64, offset int64, size int64) ([]byte, erro
 		return nil, fmt.Errorf("cannot read footer at offset %d: %w", offset+HeaderSize+storedSize, err)
 	}
 
-	expectedCrc := binary.BigEndian.Uint32(footer[0:4])
+	expectedCrc, _ := format.DecodeFooter(footer)
 	actualCrc := crc32.ChecksumIEEE(data)
 
 	if expectedCrc != actualCrc {
@@ -435,13 +543,7 @@ func (s *Store) ReadBlob(volumeID int64, offset int64, size int64) ([]byte, erro
 // Returns the CRC32 of the written data so the caller can pass it to writeMetaRecord.
 func (s *Store) writeBlobData(f *os.File, blobID int64, r io.Reader, size int64, compressionAlg uint8) (uint32, error) {
 	// 1. HLAVIČKA
-	header := make([]byte, HeaderSize)
-	binary.BigEndian.PutUint32(header[0:4], uint32(MagicBytes))
-	header[4] = Version
-	header[5] = compressionAlg
-	binary.BigEndian.PutUint64(header[6:14], uint64(size))
-	binary.BigEndian.PutUint64(header[14:22], uint64(blobID))
-
+	header := format.EncodeHeader(format.Header{CompAlg: compressionAlg, Size: size, BlobID: blobID})
 	if _, err := f.Write(header); err != nil {
 		return 0, err
 	}
@@ -458,10 +560,7 @@ func (s *Store) writeBlobData(f *os.File, blobID int64, r io.Reader, size int64,
 	crc := h.Sum32()
 
 	// 3. PATIČKA
-	footer := make([]byte, FooterSize)
-	binary.BigEndian.PutUint32(footer[0:4], crc)
-
-	if _, err := f.Write(footer); err != nil {
+	if _, err := f.Write(format.EncodeFooter(crc)); err != nil {
 		return 0, err
 	}
 
@@ -477,14 +576,9 @@ func (s *Store) writeMetaRecord(metaPath string, blobID int64, offset int64, siz
 	}
 	defer mf.Close()
 
-	// Formát: BlobID(8) + Offset(8) + Size(8) + Comp(1) + CRC(4) = 29 bytes
-	metaRecord := make([]byte, 29)
-	binary.BigEndian.PutUint64(metaRecord[0:8], uint64(blobID))
-	binary.BigEndian.PutUint64(metaRecord[8:16], uint64(offset))
-	binary.BigEndian.PutUint64(metaRecord[16:24], uint64(size))
-	metaRecord[24] = compressionAlg
-	binary.BigEndian.PutUint32(metaRecord[25:29], crc)
-
+	metaRecord := format.EncodeMetaRecord(format.MetaRecord{
+		BlobID: blobID, Offset: offset, Size: size, CompAlg: compressionAlg, CRC: crc,
+	})
 	_, err = mf.Write(metaRecord)
 	if err != nil {
 		return err
@@ -541,16 +635,7 @@ func (s *Store) regenerateMetaFile(volumeID int64, meta *MetadataSQL) error {
 		blobID := blob.ID
 		offset := blob.Offset
 		sizeCompressed := blob.SizeCompressed
-		compressionAlg := blob.CompressionAlg
-
-		// Convert compression alg string to byte code
-		var compAlgCode uint8 = 0
-		switch compressionAlg {
-		case "gzip":
-			compAlgCode = 1
-		case "zstd":
-			compAlgCode = 2
-		}
+		compAlgCode := format.CompressionCode(blob.CompressionAlg)
 
 		// Read compressed data to compute real CRC32
 		if int64(cap(dataBuf)) < sizeCompressed {
@@ -563,13 +648,9 @@ func (s *Store) regenerateMetaFile(volumeID int64, meta *MetadataSQL) error {
 		}
 		crc := crc32.ChecksumIEEE(dataBuf)
 
-		// Formát: BlobID(8) + Offset(8) + Size(8) + Comp(1) + CRC(4) = 29 bytes
-		metaRecord := make([]byte, 29)
-		binary.BigEndian.PutUint64(metaRecord[0:8], uint64(blobID))
-		binary.BigEndian.PutUint64(metaRecord[8:16], uint64(offset))
-		binary.BigEndian.PutUint64(metaRecord[16:24], uint64(sizeCompressed))
-		metaRecord[24] = compAlgCode
-		binary.BigEndian.PutUint32(metaRecord[25:29], crc)
+		metaRecord := format.EncodeMetaRecord(format.MetaRecord{
+			BlobID: blobID, Offset: offset, Size: sizeCompressed, CompAlg: compAlgCode, CRC: crc,
+		})
 
 		if _, err := mf.Write(metaRecord); err != nil {
 			return err
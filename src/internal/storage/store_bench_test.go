@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+)
+
+// benchBlobSizes are the blob sizes of the storage benchmarks
+var benchBlobSizes = []int{4 << 10, 256 << 10, 4 << 20}
+
+func benchBlobData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+// go test ./internal/storage -run '^$' -bench . -benchmem
+func BenchmarkWriteBlob(b *testing.B) {
+	for _, size := range benchBlobSizes {
+		for _, batched := range []bool{false, true} {
+			b.Run(fmt.Sprintf("size=%dKB/batched=%t", size>>10, batched), func(b *testing.B) {
+				m := newTestMetadataSQL(b)
+				if batched {
+					m.EnableVolumeAppendBatching()
+				}
+				store := NewStore(b.TempDir(), 1<<30)
+				data := benchBlobData(size)
+				b.SetBytes(int64(size))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, _, _, err := store.WriteBlobWithMetadata(int64(i+1), bytes.NewReader(data), int64(size), format.CompNone, m); err != nil {
+						b.Fatalf("WriteBlobWithMetadata: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkReadBlob(b *testing.B) {
+	for _, size := range benchBlobSizes {
+		b.Run(fmt.Sprintf("size=%dKB", size>>10), func(b *testing.B) {
+			store := NewStore(b.TempDir(), 1<<30)
+			data := benchBlobData(size)
+			volumeID, offset, _, err := store.WriteBlob(1, bytes.NewReader(data), int64(size), format.CompNone)
+			if err != nil {
+				b.Fatalf("WriteBlob: %v", err)
+			}
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.ReadBlob(volumeID, offset, int64(size)); err != nil {
+					b.Fatalf("ReadBlob: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// TagCount holds the number of files carrying a tag.
+type TagCount struct {
+	Tag   string `json:"tag" example:"invoice"`
+	Count int64  `json:"count" example:"42"`
+}
+
+// TagStats is the result of a tag listing.
+type TagStats struct {
+	Tags        []TagCount `json:"tags"`
+	TotalTags   int64      `json:"totalTags" example:"120"`    // distinct tags matching the prefix
+	TaggedFiles int64      `json:"taggedFiles" example:"5400"` // files with at least one tag
+}
+
+// tagsSourceSQL returns a FROM clause expanding files.tags (JSON array) to one row per (file, tag).
+// Values that are not valid JSON arrays (legacy CSV, corrupted values) are skipped. The tags are
+// not indexed, so every query over this source parses the tags of all files (a full table scan).
+func (m *MetadataSQL) tagsSourceSQL() string {
+	if m.dbType == "postgresql" {
+		// cumulus_tags_array (viz initPostgreSQLSchema) místo přímého ::jsonb - jedna neplatná hodnota by shodila celý dotaz
+		return `files f CROSS JOIN LATERAL jsonb_array_elements_text(cumulus_tags_array(f.tags)) AS t(tag)`
+	}
+	return `files f, json_each(CASE WHEN f.tags LIKE '[%' AND json_valid(f.tags) THEN f.tags ELSE '[]' END) t`
+}
+
+// tagColumnSQL returns the expression for the expanded tag value in tagsSourceSQL.
+func (m *MetadataSQL) tagColumnSQL() string {
+	if m.dbType == "postgresql" {
+		return "t.tag"
+	}
+	return "t.value"
+}
+
+// likeOperator returns a case-insensitive LIKE operator for the database.
+func (m *MetadataSQL) likeOperator() string {
+	if m.dbType == "postgresql" {
+		return "ILIKE"
+	}
+	return "LIKE" // SQLite LIKE is case-insensitive for ASCII
+}
+
+// escapeLike escapes LIKE wildcards so the value is matched literally (ESCAPE '\').
+func escapeLike(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(value)
+}
+
+// GetTagCounts returns tags starting with prefix (case-insensitive) ordered by file count.
+// Tags are aggregated from the JSON array stored in files.tags; each call scans the whole files
+// table three times, so the cost grows with the number of files, not with the number of tags.
+func (m *MetadataSQL) GetTagCounts(prefix string, limit int) (TagStats, error) {
+	stats := TagStats{Tags: []TagCount{}}
+	pattern := escapeLike(prefix) + "%"
+	tagCol := m.tagColumnSQL()
+	source := m.tagsSourceSQL()
+
+	query := m.buildQuery(`
+		SELECT ` + tagCol + `, COUNT(DISTINCT f.id)
+		FROM ` + source + `
+		WHERE ` + tagCol + ` ` + m.likeOperator() + ` ? ESCAPE '\'
+		GROUP BY ` + tagCol + `
+		ORDER BY COUNT(DISTINCT f.id) DESC, ` + tagCol + `
+		LIMIT ?
+	`)
+	rows, err := m.db.Query(query, pattern, limit)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tc TagCount
+		if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+			return stats, err
+		}
+		stats.Tags = append(stats.Tags, tc)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	query = m.buildQuery(`
+		SELECT COUNT(DISTINCT ` + tagCol + `)
+		FROM ` + source + `
+		WHERE ` + tagCol + ` ` + m.likeOperator() + ` ? ESCAPE '\'
+	`)
+	if err := m.db.QueryRow(query, pattern).Scan(&stats.TotalTags); err != nil {
+		return stats, err
+	}
+
+	query = `SELECT COUNT(DISTINCT f.id) FROM ` + source
+	if err := m.db.QueryRow(query).Scan(&stats.TaggedFiles); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// TagFilter selects files for a batch tag operation. Empty fields are ignored;
+// at least one field must be set.
+type TagFilter struct {
+	Tag       string `json:"tag,omitempty" example:"migrated"`
+	OldIDFrom *int64 `json:"oldIdFrom,omitempty" example:"1"`
+	OldIDTo   *int64 `json:"oldIdTo,omitempty" example:"100000"`
+}
+
+// IsEmpty reports whether the filter selects nothing.
+func (f TagFilter) IsEmpty() bool {
+	return f.Tag == "" && f.OldIDFrom == nil && f.OldIDTo == nil
+}
+
+// BatchTagResult summarizes a batch tag operation.
+type BatchTagResult struct {
+	Matched   int      `json:"matched" example:"1000"`
+	Updated   int      `json:"updated" example:"990"`
+	Unchanged int      `json:"unchanged" example:"10"`
+	NotFound  []string `json:"notFound,omitempty"`
+}
+
+// applyTagChanges adds and removes tags, keeping the original order and dropping duplicates.
+func applyTagChanges(tags, add, remove []string) []string {
+	removeSet := make(map[string]struct{}, len(remove))
+	for _, t := range remove {
+		removeSet[t] = struct{}{}
+	}
+
+	seen := make(map[string]struct{}, len(tags)+len(add))
+	result := make([]string, 0, len(tags)+len(add))
+	for _, t := range append(append([]string{}, tags...), add...) {
+		if t == "" {
+			continue
+		}
+		if _, drop := removeSet[t]; drop {
+			continue
+		}
+		if _, dup := seen[t]; dup {
+			continue
+		}
+		seen[t] = struct{}{}
+		result = append(result, t)
+	}
+	return result
+}
+
+// BatchUpdateTags adds/removes tags on the listed files, or on all files matching filter
+// when fileIDs is empty. All changes are applied in a single transaction.
+func (m *MetadataSQL) BatchUpdateTags(fileIDs []string, filter TagFilter, add, remove []string) (BatchTagResult, error) {
+	result := BatchTagResult{}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	type target struct {
+		id   string
+		tags string
+	}
+	var targets []target
+
+	if len(fileIDs) > 0 {
+		selectQuery := m.buildQuery("SELECT COALESCE(tags, '') FROM files WHERE id = ?")
+		for _, id := range fileIDs {
+			var tags string
+			err = tx.QueryRow(selectQuery, id).Scan(&tags)
+			if err == sql.ErrNoRows {
+				err = nil
+				result.NotFound = append(result.NotFound, id)
+				continue
+			}
+			if err != nil {
+				return result, err
+			}
+			targets = append(targets, target{id: id, tags: tags})
+		}
+	} else {
+		var conditions []string
+		var args []any
+		if filter.Tag != "" {
+			conditions = append(conditions, `f.id IN (SELECT f.id FROM `+m.tagsSourceSQL()+` WHERE `+m.tagColumnSQL()+` = ?)`)
+			args = append(args, filter.Tag)
+		}
+		if filter.OldIDFrom != nil {
+			conditions = append(conditions, "f.old_cumulus_id >= ?")
+			args = append(args, *filter.OldIDFrom)
+		}
+		if filter.OldIDTo != nil {
+			conditions = append(conditions, "f.old_cumulus_id <= ?")
+			args = append(args, *filter.OldIDTo)
+		}
+		if len(conditions) == 0 {
+			err = fmt.Errorf("empty filter")
+			return result, err
+		}
+
+		var rows *sql.Rows
+		query := m.buildQuery(`SELECT f.id, COALESCE(f.tags, '') FROM files f WHERE ` + strings.Join(conditions, " AND "))
+		rows, err = tx.Query(query, args...)
+		if err != nil {
+			return result, err
+		}
+		for rows.Next() {
+			var t target
+			if err = rows.Scan(&t.id, &t.tags); err != nil {
+				rows.Close()
+				return result, err
+			}
+			targets = append(targets, t)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return result, err
+		}
+	}
+
+	result.Matched = len(targets)
+	updateQuery := m.buildQuery("UPDATE files SET tags = ? WHERE id = ?")
+	for _, t := range targets {
+		newTags := tagsToJSON(applyTagChanges(tagsFromJSON(t.tags), add, remove))
+		if newTags == t.tags {
+			result.Unchanged++
+			continue
+		}
+		if _, err = tx.Exec(updateQuery, newTags, t.id); err != nil {
+			return result, err
+		}
+		result.Updated++
+	}
+
+	err = tx.Commit()
+	return result, err
+}
+
+// ListFilesByTag returns up to limit unexpired (or pinned) files carrying tag with ID greater than afterID,
+// ordered by ID. Callers page through all files by passing the last returned ID.
+func (m *MetadataSQL) ListFilesByTag(tag, afterID string, limit int) ([]File, error) {
+	return m.listFilesByTag(tag, afterID, limit, false)
+}
+
+// ListAllFilesByTag is ListFilesByTag including expired files not cleaned up yet
+func (m *MetadataSQL) ListAllFilesByTag(tag, afterID string, limit int) ([]File, error) {
+	return m.listFilesByTag(tag, afterID, limit, true)
+}
+
+func (m *MetadataSQL) listFilesByTag(tag, afterID string, limit int, includeExpired bool) ([]File, error) {
+	expiryFilter := ""
+	if !includeExpired {
+		expiryFilter = `AND (f.expires_at IS NULL OR f.pinned_at IS NOT NULL OR f.expires_at >= ` + m.currentTimeSQL() + `)`
+	}
+	query := m.buildQuery(`SELECT f.id, f.name, f.blob_id, f.old_cumulus_id, f.expires_at, f.created_at, f.tags, COALESCE(f.disposition, ''), f.pinned_at
+		FROM files f
+		WHERE f.id IN (SELECT f.id FROM ` + m.tagsSourceSQL() + ` WHERE ` + m.tagColumnSQL() + ` = ?)
+		  AND f.id > ?
+		  ` + expiryFilter + `
+		ORDER BY f.id
+		LIMIT ?`)
+	rows, err := m.db.Query(query, tag, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []File
+	for rows.Next() {
+		var f File
+		if err := rows.Scan(&f.ID, &f.Name, &f.BlobID, &f.OldCumulusID, &f.ExpiresAt, &f.CreatedAt, &f.Tags, &f.Disposition, &f.PinnedAt); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
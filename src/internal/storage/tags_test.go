@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGetTagCountsSkipsInvalidTags(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blob := createCommittedBlob(t, m, "hashTags")
+
+	now := time.Now()
+	files := []File{
+		{ID: "f1", Name: "f1.txt", BlobID: blob, CreatedAt: now, Tags: TagsToJSON([]string{"invoice", "2024"})},
+		{ID: "f2", Name: "f2.txt", BlobID: blob, CreatedAt: now, Tags: TagsToJSON([]string{"invoice"})},
+		{ID: "f3", Name: "f3.txt", BlobID: blob, CreatedAt: now, Tags: "legacy,csv"},
+		{ID: "f4", Name: "f4.txt", BlobID: blob, CreatedAt: now, Tags: `["broken`},
+		{ID: "f5", Name: "f5.txt", BlobID: blob, CreatedAt: now},
+	}
+	for _, f := range files {
+		if err := m.SaveFile(f); err != nil {
+			t.Fatalf("SaveFile(%s): %v", f.ID, err)
+		}
+	}
+
+	stats, err := m.GetTagCounts("", 10)
+	if err != nil {
+		t.Fatalf("GetTagCounts: %v", err)
+	}
+	want := []TagCount{{Tag: "invoice", Count: 2}, {Tag: "2024", Count: 1}}
+	if !slices.Equal(stats.Tags, want) || stats.TotalTags != 2 || stats.TaggedFiles != 2 {
+		t.Errorf("GetTagCounts = %+v, want tags %v, 2 tags, 2 files", stats, want)
+	}
+
+	stats, err = m.GetTagCounts("INV", 10)
+	if err != nil {
+		t.Fatalf("GetTagCounts with prefix: %v", err)
+	}
+	if len(stats.Tags) != 1 || stats.Tags[0].Tag != "invoice" || stats.TotalTags != 1 {
+		t.Errorf("GetTagCounts with prefix = %+v, want only invoice", stats)
+	}
+}
@@ -0,0 +1,26 @@
+package storage
+
+import "time"
+
+// Instrumented write transactions (op label of TxObserver)
+const (
+	TxOpDeleteFile    = "delete_file"
+	TxOpCompactVolume = "compact_volume"
+)
+
+// TxObserver receives the duration of a write transaction from Begin to Commit/Rollback, the
+// number of rows it changed and whether it committed. Long transactions hold the SQLite write
+// lock and stall concurrent uploads.
+type TxObserver func(op string, d time.Duration, rows int64, committed bool)
+
+// SetTxObserver installs the observer of write transactions; nil disables it. Call it before
+// the store is used concurrently.
+func (m *MetadataSQL) SetTxObserver(fn TxObserver) {
+	m.txObserver = fn
+}
+
+func (m *MetadataSQL) observeTx(op string, start time.Time, rows int64, committed bool) {
+	if m.txObserver != nil {
+		m.txObserver(op, time.Since(start), rows, committed)
+	}
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTxObserverDeleteFile(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	blobID := createCommittedBlob(t, m, "hash-a")
+	saveTestFile(t, m, "shared-1", blobID)
+	saveTestFile(t, m, "shared-2", blobID)
+
+	type observed struct {
+		op        string
+		rows      int64
+		committed bool
+	}
+	var got []observed
+	m.SetTxObserver(func(op string, d time.Duration, rows int64, committed bool) {
+		got = append(got, observed{op, rows, committed})
+	})
+
+	for _, id := range []string{"shared-1", "shared-2"} {
+		if err := m.DeleteFile(id); err != nil {
+			t.Fatalf("DeleteFile(%s): %v", id, err)
+		}
+	}
+	// První smazání odebere jen soubor, druhé i uvolněný blob
+	want := []observed{{TxOpDeleteFile, 1, true}, {TxOpDeleteFile, 2, true}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("observed %+v, want %+v", got, want)
+	}
+
+	got = nil
+	tx, err := m.BeginVolumeCompactionTx()
+	if err != nil {
+		t.Fatalf("BeginVolumeCompactionTx: %v", err)
+	}
+	if err := tx.UpdateVolumeSize(1, 0); err != nil {
+		t.Fatalf("UpdateVolumeSize: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	tx.Rollback() // deferred in CompactVolume, must not be reported again
+	if len(got) != 1 || got[0] != (observed{TxOpCompactVolume, 1, true}) {
+		t.Fatalf("observed %+v, want one committed compact_volume", got)
+	}
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TypeMismatch is a file whose detected content type conflicts with its filename extension
+type TypeMismatch struct {
+	FileID       string    `json:"file_id"`
+	Name         string    `json:"name" example:"invoice.pdf"`
+	OldCumulusID *int64    `json:"old_cumulus_id,omitempty"`
+	Expected     string    `json:"expected" example:"application/pdf"` // MIME type of the extension
+	Detected     string    `json:"detected" example:"application/zip"` // MIME type of the content
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SetFileTypeMismatch records the MIME type the extension of a file stands for when it conflicts
+// with the detected content type; "" clears the flag
+func (m *MetadataSQL) SetFileTypeMismatch(fileID, expected string) error {
+	var value any
+	if expected != "" {
+		value = expected
+	}
+	_, err := m.db.Exec(m.buildQuery(`UPDATE files SET type_mismatch = ? WHERE id = ?`), value, fileID)
+	return err
+}
+
+// GetFileTypeMismatch returns the recorded extension MIME type of a file, "" without a mismatch
+func (m *MetadataSQL) GetFileTypeMismatch(fileID string) (string, error) {
+	var expected sql.NullString
+	err := m.db.QueryRow(m.buildQuery(`SELECT type_mismatch FROM files WHERE id = ?`), fileID).Scan(&expected)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return expected.String, err
+}
+
+// ListTypeMismatches returns up to limit files with a type mismatch, newest first, and the number
+// of all of them
+func (m *MetadataSQL) ListTypeMismatches(limit int) ([]TypeMismatch, int64, error) {
+	var total int64
+	if err := m.db.QueryRow(`SELECT count(*) FROM files WHERE type_mismatch IS NOT NULL`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := m.db.Query(m.buildQuery(`
+		SELECT f.id, f.name, f.old_cumulus_id, f.type_mismatch, COALESCE(ft.mime_type, ''), f.created_at
+		FROM files f
+		JOIN blobs b ON b.id = f.blob_id
+		LEFT JOIN file_types ft ON ft.id = b.file_type_id
+		WHERE f.type_mismatch IS NOT NULL
+		ORDER BY f.created_at DESC, f.id
+		LIMIT ?
+	`), limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	mismatches := []TypeMismatch{}
+	for rows.Next() {
+		var tm TypeMismatch
+		if err := rows.Scan(&tm.FileID, &tm.Name, &tm.OldCumulusID, &tm.Expected, &tm.Detected, &tm.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		mismatches = append(mismatches, tm)
+	}
+	return mismatches, total, rows.Err()
+}
@@ -0,0 +1,45 @@
+package storage
+
+import "testing"
+
+func TestTypeMismatch(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	typeID, err := m.GetOrCreateFileType("application/zip", "binary", "ZIP")
+	if err != nil {
+		t.Fatalf("GetOrCreateFileType: %v", err)
+	}
+	blobID := createCommittedBlob(t, m, "hash1")
+	if err := m.UpdateBlobFileType(blobID, typeID); err != nil {
+		t.Fatalf("UpdateBlobFileType: %v", err)
+	}
+	saveTestFile(t, m, "bad", blobID)
+	saveTestFile(t, m, "good", blobID)
+
+	if err := m.SetFileTypeMismatch("bad", "application/pdf"); err != nil {
+		t.Fatalf("SetFileTypeMismatch: %v", err)
+	}
+	if got, err := m.GetFileTypeMismatch("bad"); err != nil || got != "application/pdf" {
+		t.Fatalf("GetFileTypeMismatch(bad) = %q, %v", got, err)
+	}
+	if got, err := m.GetFileTypeMismatch("good"); err != nil || got != "" {
+		t.Fatalf("GetFileTypeMismatch(good) = %q, %v, want no mismatch", got, err)
+	}
+	if got, err := m.GetFileTypeMismatch("missing"); err != nil || got != "" {
+		t.Fatalf("GetFileTypeMismatch(missing) = %q, %v", got, err)
+	}
+
+	list, total, err := m.ListTypeMismatches(10)
+	if err != nil {
+		t.Fatalf("ListTypeMismatches: %v", err)
+	}
+	if total != 1 || len(list) != 1 || list[0].FileID != "bad" || list[0].Expected != "application/pdf" || list[0].Detected != "application/zip" {
+		t.Fatalf("ListTypeMismatches = %+v, total %d", list, total)
+	}
+
+	if err := m.SetFileTypeMismatch("bad", ""); err != nil {
+		t.Fatalf("clearing the mismatch: %v", err)
+	}
+	if list, total, _ := m.ListTypeMismatches(10); total != 0 || len(list) != 0 {
+		t.Fatalf("ListTypeMismatches after clearing = %+v, total %d", list, total)
+	}
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"database/sql"
+	"log"
+	"os"
+)
+
+// VolumeWriteInfo is what a writer needs to know about a volume before appending a blob
+type VolumeWriteInfo struct {
+	SizeTotal    int64
+	State        string
+	AppendOffset int64 // valid only when OffsetKnown
+	OffsetKnown  bool  // false for volumes written before offsets were tracked (use the file size)
+}
+
+// GetVolumeWriteInfo returns size, state and append offset of a volume in one query.
+// A volume without a row is an empty open volume.
+// Appends still pending in the batch (EnableVolumeAppendBatching) are included.
+func (m *MetadataSQL) GetVolumeWriteInfo(volumeID int64) (VolumeWriteInfo, error) {
+	if b := m.appendBatch; b != nil {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		info, err := m.getVolumeWriteInfo(volumeID)
+		if err == nil {
+			b.overlay(volumeID, &info)
+		}
+		return info, err
+	}
+	return m.getVolumeWriteInfo(volumeID)
+}
+
+func (m *MetadataSQL) getVolumeWriteInfo(volumeID int64) (VolumeWriteInfo, error) {
+	info := VolumeWriteInfo{State: VolumeStateOpen}
+	var offset sql.NullInt64
+	query := m.buildQuery(`SELECT COALESCE(size_total, 0), COALESCE(state, 'open'), append_offset FROM volumes WHERE id = ?`)
+	err := m.db.QueryRow(query, volumeID).Scan(&info.SizeTotal, &info.State, &offset)
+	if err == sql.ErrNoRows {
+		return info, nil
+	}
+	if err != nil {
+		return info, err
+	}
+	info.AppendOffset = offset.Int64
+	info.OffsetKnown = offset.Valid
+	return info, nil
+}
+
+// RecordVolumeAppend accounts bytes appended to a volume and moves its append offset. With
+// batching enabled the update is only accumulated in memory.
+func (m *MetadataSQL) RecordVolumeAppend(volumeID, bytes, newOffset int64) error {
+	if m.appendBatch.add(volumeID, bytes, newOffset) {
+		return nil
+	}
+	_, err := m.db.Exec(m.volumeAppendQuery(), volumeID, bytes, newOffset)
+	return err
+}
+
+func (m *MetadataSQL) volumeAppendQuery() string {
+	return m.buildQuery(`
+		INSERT INTO volumes (id, size_total, size_deleted, append_offset) VALUES (?, ?, 0, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			size_total = volumes.size_total + EXCLUDED.size_total,
+			append_offset = EXCLUDED.append_offset
+	`)
+}
+
+// SetVolumeAppendOffset overwrites the append offset of a volume
+func (m *MetadataSQL) SetVolumeAppendOffset(volumeID, offset int64) error {
+	query := m.buildQuery(`
+		INSERT INTO volumes (id, size_total, size_deleted, append_offset) VALUES (?, 0, 0, ?)
+		ON CONFLICT(id) DO UPDATE SET append_offset = EXCLUDED.append_offset
+	`)
+	_, err := m.db.Exec(query, volumeID, offset)
+	return err
+}
+
+// SyncAppendOffsets moves append offsets forward to the volume file size where the file is longer
+// (a crash between writing a blob and updating the database, or before batched appends were
+// flushed) or the offset is not tracked yet. The bytes past a tracked offset are added to
+// size_total as well. Offsets are never moved backwards, so existing data is never overwritten.
+// Call at startup, before batching is enabled.
+func (s *Store) SyncAppendOffsets(meta *MetadataSQL) error {
+	files, err := scanVolumeFiles(s.BaseDir)
+	if err != nil {
+		return err
+	}
+	for volumeID, path := range files {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		info, err := meta.GetVolumeWriteInfo(volumeID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !info.OffsetKnown:
+			if err := meta.SetVolumeAppendOffset(volumeID, stat.Size()); err != nil {
+				return err
+			}
+		case stat.Size() > info.AppendOffset:
+			missing := stat.Size() - info.AppendOffset
+			log.Printf("Volume %d: file is %d bytes past the recorded append offset, adding them to its size", volumeID, missing)
+			if _, err := meta.db.Exec(meta.volumeAppendQuery(), volumeID, missing, stat.Size()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"sync"
+)
+
+// volumeAppendBatch accumulates volume appends (size_total growth and the new append offset) in
+// memory, so a blob write does not need its own UPDATE of the volumes table. The accumulated
+// values are written in one transaction by FlushVolumeAppends.
+type volumeAppendBatch struct {
+	// mu guards pending and is held while a flush writes it, so GetVolumeWriteInfo never sees
+	// the database without the appends that are no longer pending
+	mu      sync.Mutex
+	pending map[int64]pendingAppend
+}
+
+type pendingAppend struct {
+	bytes  int64 // added to size_total
+	offset int64 // new append_offset (appends to one volume are serialized by its lock)
+}
+
+// EnableVolumeAppendBatching makes RecordVolumeAppend accumulate appends in memory until the
+// next FlushVolumeAppends (or Close). Appends lost in a crash are restored at startup by
+// SyncAppendOffsets from the volume file sizes. Call it before the store is used concurrently.
+func (m *MetadataSQL) EnableVolumeAppendBatching() {
+	m.appendBatch = &volumeAppendBatch{pending: make(map[int64]pendingAppend)}
+}
+
+// FlushVolumeAppends writes the accumulated volume appends in one transaction. On failure they
+// stay pending for the next flush.
+func (m *MetadataSQL) FlushVolumeAppends() error {
+	b := m.appendBatch
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending) == 0 {
+		return nil
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for volumeID, p := range b.pending {
+		if _, err := tx.Exec(m.volumeAppendQuery(), volumeID, p.bytes, p.offset); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	clear(b.pending)
+	return nil
+}
+
+// add accumulates an append; false when batching is disabled
+func (b *volumeAppendBatch) add(volumeID, bytes, newOffset int64) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := b.pending[volumeID]
+	b.pending[volumeID] = pendingAppend{bytes: p.bytes + bytes, offset: newOffset}
+	return true
+}
+
+// overlay applies the pending appends of a volume to what the database returned
+func (b *volumeAppendBatch) overlay(volumeID int64, info *VolumeWriteInfo) {
+	if p, ok := b.pending[volumeID]; ok {
+		info.SizeTotal += p.bytes
+		info.AppendOffset = p.offset
+		info.OffsetKnown = true
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+)
+
+func TestVolumeAppendBatching(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	store := NewStore(t.TempDir(), 1<<20)
+	m.EnableVolumeAppendBatching()
+
+	var volumeID, end int64
+	for i := 0; i < 3; i++ {
+		data := bytes.Repeat([]byte{'x'}, 100)
+		id, offset, _, err := store.WriteBlobWithMetadata(int64(i+1), bytes.NewReader(data), int64(len(data)), format.CompNone, m)
+		if err != nil {
+			t.Fatalf("WriteBlobWithMetadata: %v", err)
+		}
+		// Další zápis musí jít za předchozí blob, i když velikost ještě není v DB
+		if offset != end {
+			t.Fatalf("blob %d written at %d, want %d", i, offset, end)
+		}
+		volumeID, end = id, offset+format.BlobTotalSize(int64(len(data)))
+	}
+
+	if size, _ := m.GetVolumeSize(volumeID); size != 0 {
+		t.Errorf("size_total before flush = %d, want 0 (batched)", size)
+	}
+	if info, _ := m.GetVolumeWriteInfo(volumeID); info.SizeTotal != end || info.AppendOffset != end {
+		t.Errorf("GetVolumeWriteInfo = %+v, want size and offset %d", info, end)
+	}
+	if err := m.FlushVolumeAppends(); err != nil {
+		t.Fatalf("FlushVolumeAppends: %v", err)
+	}
+	if size, _ := m.GetVolumeSize(volumeID); size != end {
+		t.Errorf("size_total after flush = %d, want %d", size, end)
+	}
+
+	// Pád před flushem: nezapsané přírůstky obnoví SyncAppendOffsets z velikosti souboru
+	data := bytes.Repeat([]byte{'y'}, 200)
+	if _, _, _, err := store.WriteBlobWithMetadata(4, bytes.NewReader(data), int64(len(data)), format.CompNone, m); err != nil {
+		t.Fatalf("WriteBlobWithMetadata: %v", err)
+	}
+	m.appendBatch = nil
+	end += format.BlobTotalSize(int64(len(data)))
+	if err := store.SyncAppendOffsets(m); err != nil {
+		t.Fatalf("SyncAppendOffsets: %v", err)
+	}
+	info, err := m.GetVolumeWriteInfo(volumeID)
+	if err != nil || info.SizeTotal != end || info.AppendOffset != end {
+		t.Errorf("after reconciliation %+v, %v, want size and offset %d", info, err, end)
+	}
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// VolumeLockStats are cumulative counters of volume lock usage
+type VolumeLockStats struct {
+	Acquired  int64         // successful Lock/RLock calls
+	Contended int64         // acquisitions that had to wait for another holder
+	Wait      time.Duration // total time spent waiting in contended acquisitions
+	Active    int           // volumes with a lock currently held or awaited
+}
+
+// volumeLocks hands out per-volume RW locks. Entries are reference counted and removed when the
+// last holder releases them, so the map only contains volumes in use (deleted or idle volumes
+// don't keep a lock forever).
+type volumeLocks struct {
+	mu    sync.Mutex
+	locks map[int64]*volumeLock
+
+	acquired  atomic.Int64
+	contended atomic.Int64
+	waitNanos atomic.Int64
+}
+
+type volumeLock struct {
+	sync.RWMutex
+	refs int // holders and waiters, guarded by volumeLocks.mu
+}
+
+func newVolumeLocks() *volumeLocks {
+	return &volumeLocks{locks: make(map[int64]*volumeLock)}
+}
+
+func (v *volumeLocks) ref(volumeID int64) *volumeLock {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	l, ok := v.locks[volumeID]
+	if !ok {
+		l = &volumeLock{}
+		v.locks[volumeID] = l
+	}
+	l.refs++
+	return l
+}
+
+func (v *volumeLocks) unref(volumeID int64, l *volumeLock) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(v.locks, volumeID)
+	}
+}
+
+// Lock locks the volume exclusively (writes, compaction) and returns the unlock function
+func (v *volumeLocks) Lock(volumeID int64) (unlock func()) {
+	l := v.ref(volumeID)
+	if !l.TryLock() {
+		start := time.Now()
+		l.Lock()
+		v.contended.Add(1)
+		v.waitNanos.Add(int64(time.Since(start)))
+	}
+	v.acquired.Add(1)
+	return func() {
+		l.Unlock()
+		v.unref(volumeID, l)
+	}
+}
+
+// RLock locks the volume for reading and returns the unlock function
+func (v *volumeLocks) RLock(volumeID int64) (unlock func()) {
+	l := v.ref(volumeID)
+	if !l.TryRLock() {
+		start := time.Now()
+		l.RLock()
+		v.contended.Add(1)
+		v.waitNanos.Add(int64(time.Since(start)))
+	}
+	v.acquired.Add(1)
+	return func() {
+		l.RUnlock()
+		v.unref(volumeID, l)
+	}
+}
+
+// IsLocked reports whether the volume is currently locked exclusively (e.g. being compacted)
+func (v *volumeLocks) IsLocked(volumeID int64) bool {
+	v.mu.Lock()
+	l, ok := v.locks[volumeID]
+	v.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if !l.TryLock() {
+		return true
+	}
+	l.Unlock()
+	return false
+}
+
+// Stats returns the cumulative lock counters
+func (v *volumeLocks) Stats() VolumeLockStats {
+	v.mu.Lock()
+	active := len(v.locks)
+	v.mu.Unlock()
+	return VolumeLockStats{
+		Acquired:  v.acquired.Load(),
+		Contended: v.contended.Load(),
+		Wait:      time.Duration(v.waitNanos.Load()),
+		Active:    active,
+	}
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Volume states persisted in volumes.state
+const (
+	VolumeStateOpen       = "open"       // accepts new blobs
+	VolumeStateSealed     = "sealed"     // immutable, never chosen for writes; candidate for erasure coding/tiering
+	VolumeStateCompacting = "compacting" // compaction in progress (set by CompactVolume)
+	VolumeStateReadOnly   = "read-only"  // no writes and no compaction (e.g. volume on a read-only mount)
+	VolumeStateMissing    = "missing"    // known to the database but the .dat file is gone
+)
+
+// IsValidVolumeState reports whether state is one of the known volume states
+func IsValidVolumeState(state string) bool {
+	switch state {
+	case VolumeStateOpen, VolumeStateSealed, VolumeStateCompacting, VolumeStateReadOnly, VolumeStateMissing:
+		return true
+	}
+	return false
+}
+
+// VolumeStateWritable reports whether new blobs may be appended to a volume in the state
+func VolumeStateWritable(state string) bool {
+	return state == "" || state == VolumeStateOpen
+}
+
+// VolumeStateCompactable reports whether a volume in the state may be compacted
+func VolumeStateCompactable(state string) bool {
+	return state == "" || state == VolumeStateOpen || state == VolumeStateSealed
+}
+
+// SetVolumeState persists the state of a volume (creating the volumes row if needed)
+func (m *MetadataSQL) SetVolumeState(volumeID int64, state string) error {
+	if !IsValidVolumeState(state) {
+		return fmt.Errorf("invalid volume state %q", state)
+	}
+	query := m.buildQuery(`
+		INSERT INTO volumes (id, size_total, size_deleted, state) VALUES (?, 0, 0, ?)
+		ON CONFLICT(id) DO UPDATE SET state = EXCLUDED.state
+	`)
+	_, err := m.db.Exec(query, volumeID, state)
+	return err
+}
+
+// GetVolumeState returns the state of a volume; volumes without a row are open
+func (m *MetadataSQL) GetVolumeState(volumeID int64) (string, error) {
+	var state string
+	query := m.buildQuery(`SELECT COALESCE(state, 'open') FROM volumes WHERE id = ?`)
+	err := m.db.QueryRow(query, volumeID).Scan(&state)
+	if err == sql.ErrNoRows {
+		return VolumeStateOpen, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return state, nil
+}
+
+// GetNonWritableVolumes returns IDs and states of volumes that must not receive new blobs
+func (m *MetadataSQL) GetNonWritableVolumes() (map[int64]string, error) {
+	rows, err := m.db.Query(`SELECT id, state FROM volumes WHERE state IS NOT NULL AND state <> 'open'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var state string
+		if err := rows.Scan(&id, &state); err != nil {
+			return nil, err
+		}
+		states[id] = state
+	}
+	return states, rows.Err()
+}
+
+// SyncVolumeStates marks volumes whose .dat file is gone as missing and reopens missing volumes
+// whose file is back. A compacting state left over by a crash is reset to open (compaction
+// writes to a separate .compact file, the original volume is intact). Call at startup.
+// Returns the IDs of volumes newly marked missing.
+func (s *Store) SyncVolumeStates(meta *MetadataSQL) ([]int64, error) {
+	volumes, err := meta.GetVolumesToCompact(0)
+	if err != nil {
+		return nil, err
+	}
+	files, err := scanVolumeFiles(s.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+	var missing []int64
+	for _, vol := range volumes {
+		id := int64(vol.ID)
+		_, exists := files[id]
+		switch {
+		case !exists && vol.State != VolumeStateMissing:
+			if err := meta.SetVolumeState(id, VolumeStateMissing); err != nil {
+				return missing, err
+			}
+			missing = append(missing, id)
+		case exists && (vol.State == VolumeStateMissing || vol.State == VolumeStateCompacting):
+			if err := meta.SetVolumeState(id, VolumeStateOpen); err != nil {
+				return missing, err
+			}
+		}
+	}
+	return missing, nil
+}
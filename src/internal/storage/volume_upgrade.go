@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pmalasek/cumulus3/src/internal/storage/format"
+)
+
+// Upgrade svazků se starým pojmenováním volume_N.dat na volume_%08d.dat. Živé bloby se
+// přepíšou do nového souboru (ověřené podle hlavičky a CRC), offsety v DB se změní v jedné
+// transakci a .meta se vygeneruje znovu. Po upgradu všech svazků lze fallback na staré jméno
+// odstranit z čtecí a zápisové cesty.
+
+// ErrVolumeNotLegacy is returned by UpgradeLegacyVolume for a volume without a legacy data file
+var ErrVolumeNotLegacy = errors.New("volume has no legacy data file")
+
+// UpgradeResult describes an upgraded volume
+type UpgradeResult struct {
+	VolumeID   int64
+	Blobs      int
+	OldSize    int64 // size of the legacy file
+	NewSize    int64
+	Completed  bool // an interrupted upgrade was finished (the new file was already committed)
+	LegacyPath string
+	NewPath    string
+}
+
+func legacyVolumeName(volumeID int64) string {
+	return fmt.Sprintf("volume_%d.dat", volumeID)
+}
+
+func paddedVolumeName(volumeID int64) string {
+	return fmt.Sprintf("volume_%08d.dat", volumeID)
+}
+
+// LegacyVolumeIDs returns the IDs of volumes whose data file still has the legacy name, ascending
+func (s *Store) LegacyVolumeIDs() ([]int64, error) {
+	entries, err := os.ReadDir(s.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "volume_") || !strings.HasSuffix(name, ".dat") {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, "volume_"), ".dat"), 10, 64)
+		if err != nil || id <= 0 || name != legacyVolumeName(id) || name == paddedVolumeName(id) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// UpgradeLegacyVolume rewrites the legacy volume_N.dat of a volume into volume_%08d.dat. The live
+// blobs are copied in offset order and verified (header blob ID, CRC); their new offsets and the
+// volume size are committed in one transaction before the legacy .dat and .meta are removed and
+// the .meta is regenerated. When both files exist (an upgrade interrupted after the commit), the
+// new file is checked against the database and the legacy files are removed.
+func (s *Store) UpgradeLegacyVolume(volumeID int64, meta *MetadataSQL) (*UpgradeResult, error) {
+	// Same lock order as CompactVolume and WriteBlob: s.mu, then the volume lock
+	s.mu.Lock()
+	isCurrent := volumeID == s.CurrentVolumeID
+	if !isCurrent {
+		s.mu.Unlock()
+	} else {
+		defer s.mu.Unlock()
+	}
+	unlock := s.volumeLocks.Lock(volumeID)
+	defer unlock()
+
+	res := &UpgradeResult{
+		VolumeID:   volumeID,
+		LegacyPath: filepath.Join(s.BaseDir, legacyVolumeName(volumeID)),
+		NewPath:    filepath.Join(s.BaseDir, paddedVolumeName(volumeID)),
+	}
+	if res.LegacyPath == res.NewPath {
+		return nil, fmt.Errorf("%w: volume %d", ErrVolumeNotLegacy, volumeID)
+	}
+	legacyStat, err := os.Stat(res.LegacyPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: volume %d", ErrVolumeNotLegacy, volumeID)
+	} else if err != nil {
+		return nil, err
+	}
+	res.OldSize = legacyStat.Size()
+
+	if err := meta.FlushVolumeAppends(); err != nil {
+		return nil, fmt.Errorf("failed to flush volume appends: %w", err)
+	}
+	blobs, err := meta.GetBlobsForCompaction(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	res.Blobs = len(blobs)
+
+	if newStat, err := os.Stat(res.NewPath); err == nil {
+		if err := verifyVolumeBlobs(res.NewPath, blobs); err != nil {
+			return nil, fmt.Errorf("both %s and %s exist and the new file does not match the database: %w",
+				filepath.Base(res.LegacyPath), filepath.Base(res.NewPath), err)
+		}
+		res.NewSize = newStat.Size()
+		res.Completed = true
+		s.removeLegacyVolumeFiles(res.LegacyPath)
+		return res, s.regenerateMetaFile(volumeID, meta)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	prevState, err := meta.GetVolumeState(volumeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read volume state: %w", err)
+	}
+	if !VolumeStateCompactable(prevState) {
+		return nil, fmt.Errorf("volume %d is %s, upgrade not allowed", volumeID, prevState)
+	}
+	if err := meta.SetVolumeState(volumeID, VolumeStateCompacting); err != nil {
+		return nil, fmt.Errorf("failed to mark volume as compacting: %w", err)
+	}
+	defer func() {
+		if err := meta.SetVolumeState(volumeID, prevState); err != nil {
+			log.Printf("WARNING: failed to restore state %s of volume %d after upgrade: %v", prevState, volumeID, err)
+		}
+	}()
+
+	tmpPath := res.NewPath + ".upgrade"
+	newOffsets, size, err := copyVolumeBlobs(res.LegacyPath, tmpPath, blobs)
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	res.NewSize = size
+
+	tx, err := meta.BeginVolumeCompactionTx()
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	defer tx.Rollback()
+	for i, blob := range blobs {
+		if err := tx.UpdateBlobOffset(blob.ID, newOffsets[i]); err != nil {
+			os.Remove(tmpPath)
+			return nil, err
+		}
+	}
+	if err := tx.UpdateVolumeSize(volumeID, size); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	// The new name wins over the legacy one from here on, as in CompactVolume the swap comes
+	// before the commit and is undone when the commit fails
+	if err := os.Rename(tmpPath, res.NewPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		os.Remove(res.NewPath)
+		return nil, fmt.Errorf("failed to commit transaction after writing %s: %w", filepath.Base(res.NewPath), err)
+	}
+
+	s.removeLegacyVolumeFiles(res.LegacyPath)
+	if err := s.regenerateMetaFile(volumeID, meta); err != nil {
+		return res, fmt.Errorf("warning: failed to regenerate .meta file: %w", err)
+	}
+	if isCurrent {
+		s.recalculateCurrentVolumeNoLock()
+	}
+	return res, nil
+}
+
+// copyVolumeBlobs copies the blobs from src to a new file dst back to back and returns their new
+// offsets and the size of dst. Every blob is checked against its header and CRC footer.
+func copyVolumeBlobs(src, dst string, blobs []BlobCompactionRecord) ([]int64, int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer out.Close()
+
+	offsets := make([]int64, len(blobs))
+	var offset int64
+	buf := make([]byte, 0, 1<<20)
+	for i, blob := range blobs {
+		total := format.BlobTotalSize(blob.SizeCompressed)
+		if int64(cap(buf)) < total {
+			buf = make([]byte, total)
+		}
+		buf = buf[:total]
+		if _, err := in.ReadAt(buf, blob.Offset); err != nil {
+			return nil, 0, fmt.Errorf("failed to read blob %d at offset %d: %w", blob.ID, blob.Offset, err)
+		}
+		if err := checkBlobRecord(buf, blob); err != nil {
+			return nil, 0, err
+		}
+		if n, err := out.Write(buf); err != nil {
+			return nil, 0, err
+		} else if int64(n) != total {
+			return nil, 0, io.ErrShortWrite
+		}
+		offsets[i] = offset
+		offset += total
+	}
+	if err := out.Sync(); err != nil {
+		return nil, 0, fmt.Errorf("failed to sync %s: %w", filepath.Base(dst), err)
+	}
+	return offsets, offset, out.Close()
+}
+
+// verifyVolumeBlobs checks that every blob is at its database offset in the volume file
+func verifyVolumeBlobs(path string, blobs []BlobCompactionRecord) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var buf []byte
+	for _, blob := range blobs {
+		total := format.BlobTotalSize(blob.SizeCompressed)
+		if int64(cap(buf)) < total {
+			buf = make([]byte, total)
+		}
+		buf = buf[:total]
+		if _, err := f.ReadAt(buf, blob.Offset); err != nil {
+			return fmt.Errorf("failed to read blob %d at offset %d: %w", blob.ID, blob.Offset, err)
+		}
+		if err := checkBlobRecord(buf, blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBlobRecord verifies a blob read with its header and footer against its database record
+func checkBlobRecord(buf []byte, blob BlobCompactionRecord) error {
+	h, err := format.DecodeHeader(buf)
+	if err != nil {
+		return fmt.Errorf("blob %d at offset %d: %w", blob.ID, blob.Offset, err)
+	}
+	if h.BlobID != blob.ID || h.Size != blob.SizeCompressed {
+		return fmt.Errorf("blob %d at offset %d: header has blob %d of %d bytes, database %d bytes",
+			blob.ID, blob.Offset, h.BlobID, h.Size, blob.SizeCompressed)
+	}
+	data := buf[HeaderSize : HeaderSize+blob.SizeCompressed]
+	crc, err := format.DecodeFooter(buf[HeaderSize+blob.SizeCompressed:])
+	if err != nil {
+		return err
+	}
+	if crc32.ChecksumIEEE(data) != crc {
+		return fmt.Errorf("blob %d at offset %d: CRC mismatch", blob.ID, blob.Offset)
+	}
+	return nil
+}
+
+// removeLegacyVolumeFiles deletes the legacy .dat and .meta of an upgraded volume
+func (s *Store) removeLegacyVolumeFiles(datPath string) {
+	metaPath := strings.TrimSuffix(datPath, ".dat") + ".meta"
+	for _, path := range []string{datPath, metaPath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("WARNING: failed to remove %s of upgraded volume: %v", filepath.Base(path), err)
+		}
+	}
+}
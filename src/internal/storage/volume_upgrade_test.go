@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpgradeLegacyVolume(t *testing.T) {
+	m := newTestMetadataSQL(t)
+	store := newManifestTestVolume(t, m, 3)
+	// Starý název svazku a bez prostředního blobu (smazaný, místo se při upgradu uvolní)
+	for _, ext := range []string{".dat", ".meta"} {
+		if err := os.Rename(filepath.Join(store.BaseDir, "volume_00000001"+ext), filepath.Join(store.BaseDir, "volume_1"+ext)); err != nil {
+			t.Fatalf("rename to legacy name: %v", err)
+		}
+	}
+	if _, err := m.db.Exec(m.buildQuery(`DELETE FROM blobs WHERE hash = ?`), "hash1"); err != nil {
+		t.Fatalf("deleting blob: %v", err)
+	}
+
+	if ids, err := store.LegacyVolumeIDs(); err != nil || len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("LegacyVolumeIDs = %v, %v, want [1]", ids, err)
+	}
+
+	res, err := store.UpgradeLegacyVolume(1, m)
+	if err != nil {
+		t.Fatalf("UpgradeLegacyVolume: %v", err)
+	}
+	if res.Blobs != 2 || res.NewSize >= res.OldSize || res.Completed {
+		t.Fatalf("UpgradeLegacyVolume = %+v", res)
+	}
+	for _, name := range []string{"volume_1.dat", "volume_1.meta"} {
+		if _, err := os.Stat(filepath.Join(store.BaseDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists (%v)", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(store.BaseDir, "volume_00000001.meta")); err != nil {
+		t.Errorf("regenerated .meta: %v", err)
+	}
+	if ids, _ := store.LegacyVolumeIDs(); len(ids) != 0 {
+		t.Errorf("LegacyVolumeIDs after upgrade = %v", ids)
+	}
+
+	blobs, err := m.GetBlobsForCompaction(1)
+	if err != nil || len(blobs) != 2 {
+		t.Fatalf("GetBlobsForCompaction = %v, %v", blobs, err)
+	}
+	for i, blob := range blobs {
+		data, err := store.ReadBlob(1, blob.Offset, blob.SizeCompressed)
+		if err != nil {
+			t.Fatalf("ReadBlob(%d): %v", blob.ID, err)
+		}
+		want := bytes.Repeat([]byte{byte('a' + 2*i)}, 100+2*i)
+		if !bytes.Equal(data, want) {
+			t.Errorf("blob %d has wrong content after upgrade", blob.ID)
+		}
+	}
+	if info, _ := m.GetVolumeWriteInfo(1); info.SizeTotal != res.NewSize || info.AppendOffset != res.NewSize {
+		t.Errorf("volume after upgrade %+v, want size and offset %d", info, res.NewSize)
+	}
+
+	// Přerušený upgrade po commitu: oba soubory existují, nový odpovídá DB
+	data, _ := os.ReadFile(res.NewPath)
+	if err := os.WriteFile(res.LegacyPath, data, 0644); err != nil {
+		t.Fatalf("restoring legacy file: %v", err)
+	}
+	res, err = store.UpgradeLegacyVolume(1, m)
+	if err != nil || !res.Completed {
+		t.Fatalf("finishing interrupted upgrade = %+v, %v", res, err)
+	}
+	if _, err := os.Stat(res.LegacyPath); !os.IsNotExist(err) {
+		t.Errorf("legacy file not removed (%v)", err)
+	}
+
+	if _, err := store.UpgradeLegacyVolume(1, m); !errors.Is(err, ErrVolumeNotLegacy) {
+		t.Errorf("upgrading an upgraded volume: %v, want ErrVolumeNotLegacy", err)
+	}
+}
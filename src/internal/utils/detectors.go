@@ -0,0 +1,337 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Detector recognizes a file type from the start of a file. ok=false means "not mine", the next
+// detector is tried; an error is logged and counted, and the next detector is tried as well.
+type Detector interface {
+	Name() string
+	Detect(data []byte) (result FileTypeResult, ok bool, err error)
+}
+
+// DefaultDetectors is the registry used by DetectFileType
+var DefaultDetectors = NewDetectorRegistry()
+
+func init() {
+	registerBuiltinDetectors(DefaultDetectors)
+}
+
+// RegisterDetector adds a detector to the default registry, see DetectorRegistry.Register
+func RegisterDetector(d Detector, priority int) {
+	DefaultDetectors.Register(d, priority)
+}
+
+// DetectorStats are the counters of one registered detector
+type DetectorStats struct {
+	Name     string
+	Priority int
+	Calls    int64
+	Matches  int64
+	Errors   int64
+	Duration time.Duration // total time spent in Detect
+}
+
+type detectorEntry struct {
+	detector Detector
+	priority int
+	seq      int // registration order, breaks priority ties
+
+	calls, matches, errors, nanos atomic.Int64
+}
+
+// DetectorRegistry runs detectors ordered by priority (higher first, ties in registration order)
+// and returns the result of the first one that matches
+type DetectorRegistry struct {
+	mu      sync.RWMutex
+	entries []*detectorEntry
+	seq     int
+}
+
+// NewDetectorRegistry returns an empty registry
+func NewDetectorRegistry() *DetectorRegistry {
+	return &DetectorRegistry{}
+}
+
+// Register adds a detector; a detector with the same name is replaced
+func (r *DetectorRegistry) Register(d Detector, priority int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeLocked(d.Name())
+	r.seq++
+	// Nový slice: Detect může právě procházet ten předchozí
+	entries := append(make([]*detectorEntry, 0, len(r.entries)+1), r.entries...)
+	entries = append(entries, &detectorEntry{detector: d, priority: priority, seq: r.seq})
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].priority != entries[j].priority {
+			return entries[i].priority > entries[j].priority
+		}
+		return entries[i].seq < entries[j].seq
+	})
+	r.entries = entries
+}
+
+// Remove removes the detector with the given name; returns false if there is none
+func (r *DetectorRegistry) Remove(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.removeLocked(name)
+}
+
+func (r *DetectorRegistry) removeLocked(name string) bool {
+	for i, e := range r.entries {
+		if e.detector.Name() == name {
+			entries := make([]*detectorEntry, 0, len(r.entries)-1)
+			r.entries = append(append(entries, r.entries[:i]...), r.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Detect returns the result of the first matching detector, generic binary if none matches
+func (r *DetectorRegistry) Detect(data []byte) FileTypeResult {
+	r.mu.RLock()
+	entries := r.entries
+	r.mu.RUnlock()
+
+	for _, e := range entries {
+		start := time.Now()
+		result, ok, err := e.detector.Detect(data)
+		e.nanos.Add(int64(time.Since(start)))
+		e.calls.Add(1)
+		if err != nil {
+			e.errors.Add(1)
+			Warn("DETECT", "Detector %s failed: %v", e.detector.Name(), err)
+			continue
+		}
+		if ok {
+			e.matches.Add(1)
+			return result
+		}
+	}
+	return binaryResult
+}
+
+// Stats returns the counters of all registered detectors in the order they run
+func (r *DetectorRegistry) Stats() []DetectorStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := make([]DetectorStats, 0, len(r.entries))
+	for _, e := range r.entries {
+		stats = append(stats, DetectorStats{
+			Name:     e.detector.Name(),
+			Priority: e.priority,
+			Calls:    e.calls.Load(),
+			Matches:  e.matches.Load(),
+			Errors:   e.errors.Load(),
+			Duration: time.Duration(e.nanos.Load()),
+		})
+	}
+	return stats
+}
+
+// sniffer is a Detector backed by a function
+type sniffer struct {
+	name string
+	fn   func(data []byte) (FileTypeResult, bool)
+}
+
+// NewSniffer returns a detector that inspects the content with fn
+func NewSniffer(name string, fn func(data []byte) (FileTypeResult, bool)) Detector {
+	return &sniffer{name: name, fn: fn}
+}
+
+func (s *sniffer) Name() string { return s.name }
+
+func (s *sniffer) Detect(data []byte) (FileTypeResult, bool, error) {
+	result, ok := s.fn(data)
+	return result, ok, nil
+}
+
+// magicDetector matches magic bytes, the first matching pattern wins
+type magicDetector struct {
+	name     string
+	patterns []PatternDefinition
+}
+
+// NewMagicDetector returns a detector matching the given magic byte patterns in order
+func NewMagicDetector(name string, patterns []PatternDefinition) Detector {
+	return &magicDetector{name: name, patterns: patterns}
+}
+
+func (m *magicDetector) Name() string { return m.name }
+
+func (m *magicDetector) Detect(data []byte) (FileTypeResult, bool, error) {
+	for _, def := range m.patterns {
+		if matchesPattern(data, def.Pattern, def.Offset) {
+			return def.Result, true, nil
+		}
+	}
+	return FileTypeResult{}, false, nil
+}
+
+// commandDetector runs an external command with the data on stdin; the first line of its
+// output is the MIME type (e.g. `file --mime-type -b -`). Generic types don't match.
+type commandDetector struct {
+	name    string
+	args    []string
+	timeout time.Duration
+}
+
+// NewCommandDetector returns a detector running an external command, see commandDetector
+func NewCommandDetector(name string, args []string, timeout time.Duration) Detector {
+	return &commandDetector{name: name, args: args, timeout: timeout}
+}
+
+func (c *commandDetector) Name() string { return c.name }
+
+func (c *commandDetector) Detect(data []byte) (FileTypeResult, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.args[0], c.args[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.Output()
+	if err != nil {
+		return FileTypeResult{}, false, err
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	return resultFromMimeType(strings.TrimSpace(line))
+}
+
+// resultFromMimeType turns a detected MIME type into a result. Generic types don't match, so
+// the upload Content-Type or the file extension (e.g. text/csv for .csv) is used instead.
+func resultFromMimeType(mimeType string) (FileTypeResult, bool, error) {
+	category, subtype, ok := strings.Cut(mimeType, "/")
+	if !ok || category == "" || subtype == "" {
+		return FileTypeResult{}, false, fmt.Errorf("unexpected output %q", mimeType)
+	}
+	if mimeType == "application/octet-stream" || mimeType == "text/plain" {
+		return FileTypeResult{}, false, nil
+	}
+	return FileTypeResult{Type: category, Subtype: subtype, ContentType: mimeType}, true, nil
+}
+
+// DetectorConfig is the file type detector configuration (JSON, FILE_DETECTORS_CONFIG)
+type DetectorConfig struct {
+	Disable   []string             `json:"disable"` // names of built-in detectors to remove
+	Detectors []DetectorDefinition `json:"detectors"`
+}
+
+// DetectorDefinition defines a magic byte or external command detector
+type DetectorDefinition struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+
+	// Magic byte detector: all patterns must match, then the result below is returned
+	Magic       []MagicPattern `json:"magic"`
+	Type        string         `json:"type"`
+	Subtype     string         `json:"subtype"`
+	ContentType string         `json:"contentType"`
+
+	// External command detector: prints the MIME type for the data on stdin
+	Command []string `json:"command"`
+	Timeout string   `json:"timeout"` // default 2s
+}
+
+// MagicPattern is a hex encoded byte pattern at an offset
+type MagicPattern struct {
+	Hex    string `json:"hex"`
+	Offset int    `json:"offset"`
+}
+
+// LoadDetectorConfig applies a detector configuration file to the default registry
+func LoadDetectorConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var cfg DetectorConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("invalid detector config %s: %w", path, err)
+	}
+	return cfg.Apply(DefaultDetectors)
+}
+
+// Apply removes the disabled detectors from r and registers the defined ones. Nothing is changed
+// when a definition is invalid.
+func (cfg DetectorConfig) Apply(r *DetectorRegistry) error {
+	detectors := make([]Detector, 0, len(cfg.Detectors))
+	for _, def := range cfg.Detectors {
+		d, err := def.detector()
+		if err != nil {
+			return fmt.Errorf("detector %q: %w", def.Name, err)
+		}
+		detectors = append(detectors, d)
+	}
+
+	for _, name := range cfg.Disable {
+		if !r.Remove(name) {
+			Warn("DETECT", "Detector %s to disable is not registered", name)
+		}
+	}
+	for i, d := range detectors {
+		r.Register(d, cfg.Detectors[i].Priority)
+		Info("DETECT", "Detector %s registered with priority %d", d.Name(), cfg.Detectors[i].Priority)
+	}
+	return nil
+}
+
+func (def DetectorDefinition) detector() (Detector, error) {
+	if def.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	switch {
+	case len(def.Magic) > 0 && len(def.Command) > 0:
+		return nil, fmt.Errorf("magic and command are exclusive")
+
+	case len(def.Command) > 0:
+		timeout := 2 * time.Second
+		if def.Timeout != "" {
+			t, err := time.ParseDuration(def.Timeout)
+			if err != nil || t <= 0 {
+				return nil, fmt.Errorf("invalid timeout %q", def.Timeout)
+			}
+			timeout = t
+		}
+		return NewCommandDetector(def.Name, def.Command, timeout), nil
+
+	case len(def.Magic) > 0:
+		if def.Type == "" || def.ContentType == "" {
+			return nil, fmt.Errorf("type and contentType are required")
+		}
+		patterns := make([]PatternDefinition, 0, len(def.Magic))
+		for _, m := range def.Magic {
+			pattern, err := hex.DecodeString(strings.ReplaceAll(m.Hex, " ", ""))
+			if err != nil || len(pattern) == 0 || m.Offset < 0 {
+				return nil, fmt.Errorf("invalid magic pattern %q at offset %d", m.Hex, m.Offset)
+			}
+			patterns = append(patterns, PatternDefinition{Pattern: pattern, Offset: m.Offset})
+		}
+		result := FileTypeResult{Type: def.Type, Subtype: def.Subtype, ContentType: def.ContentType}
+		return NewSniffer(def.Name, func(data []byte) (FileTypeResult, bool) {
+			for _, p := range patterns {
+				if !matchesPattern(data, p.Pattern, p.Offset) {
+					return FileTypeResult{}, false
+				}
+			}
+			return result, true
+		}), nil
+	}
+	return nil, fmt.Errorf("magic or command is required")
+}
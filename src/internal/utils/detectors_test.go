@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectFileTypeBuiltins(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected FileTypeResult
+	}{
+		{"pdf", []byte("%PDF-1.7\n"), FileTypeResult{Type: "pdf", ContentType: "application/pdf"}},
+		{"png", []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A, 0}, FileTypeResult{Type: "image", Subtype: "PNG", ContentType: "image/png"}},
+		{"ktag", []byte{0xA5, 0x3B, 0xFB, 0x19, 0xAC, 0x26, 0}, FileTypeResult{Type: "ecu", Subtype: "KTag", ContentType: "application/octet-stream"}},
+		{"webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), FileTypeResult{Type: "image", Subtype: "WebP", ContentType: "image/webp"}},
+		{"cummins before ident", []byte("sep=,\nService Tool INSITE\nECM Code ident"), FileTypeResult{Type: "text", Subtype: "Cummins", ContentType: "text/csv"}},
+		{"ident", []byte("ECU ident data"), FileTypeResult{Type: "binary", Subtype: "Ident", ContentType: "application/octet-stream"}},
+		{"unknown", []byte(strings.Repeat("x", 100)), FileTypeResult{Type: "binary", ContentType: "application/octet-stream"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFileType(tt.data); got != tt.expected {
+				t.Errorf("DetectFileType() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDetectorConfigApply(t *testing.T) {
+	r := NewDetectorRegistry()
+	registerBuiltinDetectors(r)
+
+	cfg := DetectorConfig{
+		Disable: []string{"ident"},
+		Detectors: []DetectorDefinition{{
+			Name:        "acme",
+			Priority:    200,
+			Magic:       []MagicPattern{{Hex: "25 50", Offset: 0}, {Hex: "41434d45", Offset: 4}},
+			Type:        "ecu",
+			Subtype:     "ACME",
+			ContentType: "application/octet-stream",
+		}},
+	}
+	if err := cfg.Apply(r); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if got := r.Detect([]byte("%PDFACME")); got.Subtype != "ACME" {
+		t.Errorf("higher priority detector did not run first, got %+v", got)
+	}
+	if got := r.Detect([]byte("%PDF-1.7")); got.Type != "pdf" {
+		t.Errorf("built-in detector not used when custom one does not match, got %+v", got)
+	}
+	if got := r.Detect([]byte("ident")); got != binaryResult {
+		t.Errorf("disabled detector still runs, got %+v", got)
+	}
+
+	stats := r.Stats()
+	if stats[0].Name != "acme" || stats[0].Calls != 3 || stats[0].Matches != 1 {
+		t.Errorf("unexpected stats of the custom detector: %+v", stats[0])
+	}
+
+	invalid := DetectorConfig{Detectors: []DetectorDefinition{{Name: "bad", Magic: []MagicPattern{{Hex: "zz"}}, Type: "x", ContentType: "x/y"}}}
+	if err := invalid.Apply(r); err == nil {
+		t.Error("Apply() accepted an invalid magic pattern")
+	}
+}
+
+func TestFallbackDetectors(t *testing.T) {
+	r := NewDetectorRegistry()
+	registerBuiltinDetectors(r)
+	r.Register(NewSniffer("containers", detectZipContainer), PriorityContainer)
+	r.Register(NewSniffer("signatures", detectSignature), PriorityFallback)
+
+	zipEntry := func(name, content string) []byte {
+		header := []byte{0x50, 0x4B, 0x03, 0x04}
+		header = append(header, make([]byte, 22)...)
+		header = append(header, byte(len(name)), 0, 0, 0)
+		return append(append(header, name...), content...)
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+	}{
+		{"odt", zipEntry("mimetype", "application/vnd.oasis.opendocument.text"), "application/vnd.oasis.opendocument.text"},
+		{"docx", append(zipEntry("[Content_Types].xml", "<Types/>"), zipEntry("word/document.xml", "")...), "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"plain zip", zipEntry("readme.txt", "hello"), "application/zip"},
+		{"doc", append(append([]byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, make([]byte, 100)...), utf16("WordDocument")...), "application/msword"},
+		{"mp3", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), "audio/mpeg"},
+		{"m4a", []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00"), "audio/mp4"},
+		{"mp4", []byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00"), "video/mp4"},
+		{"webm", []byte("\x1A\x45\xDF\xA3\x9F\x42\x86\x81\x01\x42\x82\x84webm"), "video/webm"},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), "audio/wav"},
+		{"text is left to the extension", []byte("just some text"), "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Detect(tt.data); got.ContentType != tt.contentType {
+				t.Errorf("Detect() = %+v, want %s", got, tt.contentType)
+			}
+		})
+	}
+}
@@ -18,6 +18,18 @@ type PatternDefinition struct {
 	Result  FileTypeResult
 }
 
+// Priorities of the built-in detectors; a higher priority runs first
+const (
+	PriorityMagic   = 100
+	PriorityECU     = 100
+	PriorityWebP    = 90
+	PrioritySVG     = 80
+	PriorityFake    = 70
+	PriorityCummins = 60
+	PriorityCAT     = 50
+	PriorityIdent   = 10 // must run after the text detections so Cummins/CAT files are never misclassified
+)
+
 var filePatterns = []PatternDefinition{
 	// PDF
 	{Pattern: []byte{0x25, 0x50, 0x44, 0x46}, Result: FileTypeResult{Type: "pdf", ContentType: "application/pdf"}},
@@ -31,7 +43,12 @@ var filePatterns = []PatternDefinition{
 	{Pattern: []byte{0x4D, 0x4D, 0x00, 0x2A}, Result: FileTypeResult{Type: "image", Subtype: "TIFF", ContentType: "image/tiff"}},
 	{Pattern: []byte{0x00, 0x00, 0x01, 0x00}, Result: FileTypeResult{Type: "image", Subtype: "ICO", ContentType: "image/x-icon"}},
 
-	// ECU Files
+	// Archive
+	{Pattern: []byte{0x50, 0x4B, 0x03, 0x04}, Result: FileTypeResult{Type: "binary", Subtype: "ZIP", ContentType: "application/zip"}},
+}
+
+// ECU Files
+var ecuPatterns = []PatternDefinition{
 	{Pattern: []byte{0x2E, 0x71, 0xD4, 0x12, 0x2F, 0x7D, 0xD6, 0x08, 0x49, 0x34}, Result: FileTypeResult{Type: "ecu", Subtype: "KESSv2", ContentType: "application/octet-stream"}},
 	{Pattern: []byte{0xEC, 0xBB, 0x56, 0x0C, 0x9C, 0x59, 0xE9, 0x42, 0x41, 0x4F, 0x00, 0x05, 0xF8, 0xE8, 0xBF, 0x8D}, Result: FileTypeResult{Type: "ecu", Subtype: "KESSv3", ContentType: "application/octet-stream"}},
 	{Pattern: []byte{0xEC, 0xBB, 0x56, 0x0C, 0x9C, 0x59, 0xE9, 0x42, 0x41, 0x4F, 0x80, 0x05, 0xF0, 0x6D, 0xBF, 0x8D}, Result: FileTypeResult{Type: "ecu", Subtype: "KESSv3", ContentType: "application/octet-stream"}},
@@ -41,11 +58,19 @@ var filePatterns = []PatternDefinition{
 	{Pattern: []byte{0x50, 0x4D, 0x03, 0x04}, Result: FileTypeResult{Type: "ecu", Subtype: "ECU", ContentType: "application/octet-stream"}},
 	{Pattern: []byte{0x58, 0x42, 0x03, 0x04}, Result: FileTypeResult{Type: "ecu", Subtype: "XPR", ContentType: "application/octet-stream"}},
 	{Pattern: []byte{0x58, 0x49, 0x03, 0x04}, Result: FileTypeResult{Type: "ecu", Subtype: "XP2", ContentType: "application/octet-stream"}},
-
-	// Archive
-	{Pattern: []byte{0x50, 0x4B, 0x03, 0x04}, Result: FileTypeResult{Type: "binary", Subtype: "ZIP", ContentType: "application/zip"}},
 }
 
+// Size of the content start passed to the detectors (FILE_DETECTION_SAMPLE_SIZE). Signatures sit in
+// the first bytes, but container formats (ZIP entries, ISO BMFF boxes) and text heuristics see more
+// with a larger sample.
+const (
+	DefaultDetectionSampleSize int64 = 12000
+	MaxDetectionSampleSize     int64 = 16 << 20
+)
+
+// binaryResult is the result when no detector matches
+var binaryResult = FileTypeResult{Type: "binary", ContentType: "application/octet-stream"}
+
 func matchesPattern(data []byte, pattern []byte, offset int) bool {
 	if len(data) < offset+len(pattern) {
 		return false
@@ -53,72 +78,86 @@ func matchesPattern(data []byte, pattern []byte, offset int) bool {
 	return bytes.Equal(data[offset:offset+len(pattern)], pattern)
 }
 
+// DetectFileType detects the type of data (the start of a file) with the default detector
+// registry. Generic binary is returned when no detector matches.
 func DetectFileType(data []byte) FileTypeResult {
-	// Kontrola magic bytes pomocí konfigurace
-	for _, def := range filePatterns {
-		if matchesPattern(data, def.Pattern, def.Offset) {
-			return def.Result
-		}
-	}
+	return DefaultDetectors.Detect(data)
+}
 
-	// WebP - speciální kontrola (RIFF na pozici 0, WEBP na pozici 8)
+// registerBuiltinDetectors adds the built-in detectors to r
+func registerBuiltinDetectors(r *DetectorRegistry) {
+	r.Register(NewMagicDetector("magic", filePatterns), PriorityMagic)
+	r.Register(NewMagicDetector("ecu", ecuPatterns), PriorityECU)
+	r.Register(NewSniffer("webp", detectWebP), PriorityWebP)
+	r.Register(NewSniffer("svg", detectSVG), PrioritySVG)
+	r.Register(NewSniffer("fake", detectFake), PriorityFake)
+	r.Register(NewSniffer("cummins", detectCummins), PriorityCummins)
+	r.Register(NewSniffer("cat", detectCAT), PriorityCAT)
+	r.Register(NewSniffer("ident", detectIdent), PriorityIdent)
+}
+
+// WebP - speciální kontrola (RIFF na pozici 0, WEBP na pozici 8)
+func detectWebP(data []byte) (FileTypeResult, bool) {
 	if len(data) >= 12 &&
 		matchesPattern(data, []byte{0x52, 0x49, 0x46, 0x46}, 0) &&
 		matchesPattern(data, []byte{0x57, 0x45, 0x42, 0x50}, 8) {
-		return FileTypeResult{Type: "image", Subtype: "WebP", ContentType: "image/webp"}
+		return FileTypeResult{Type: "image", Subtype: "WebP", ContentType: "image/webp"}, true
 	}
+	return FileTypeResult{}, false
+}
 
-	// SVG - kontrola XML hlavičky
-	// Check first 100 bytes
-	limit := 100
-	if len(data) < limit {
-		limit = len(data)
-	}
-	headerText := string(data[:limit])
+// SVG - kontrola XML hlavičky v prvních 100 bajtech
+func detectSVG(data []byte) (FileTypeResult, bool) {
+	headerText := string(data[:min(len(data), 100)])
 	if strings.Contains(headerText, "<svg") || strings.Contains(headerText, "<?xml") {
-		return FileTypeResult{Type: "image", Subtype: "SVG", ContentType: "image/svg+xml"}
+		return FileTypeResult{Type: "image", Subtype: "SVG", ContentType: "image/svg+xml"}, true
 	}
+	return FileTypeResult{}, false
+}
 
-	// Fake file detection
-	if len(data) < 120 {
-		text := string(data)
-		if strings.Contains(text, "gaia_fake_file") {
-			return FileTypeResult{Type: "binary", Subtype: "Fake", ContentType: "application/octet-stream"}
-		}
+// Fake file detection
+func detectFake(data []byte) (FileTypeResult, bool) {
+	if len(data) < 120 && strings.Contains(string(data), "gaia_fake_file") {
+		return FileTypeResult{Type: "binary", Subtype: "Fake", ContentType: "application/octet-stream"}, true
 	}
+	return FileTypeResult{}, false
+}
 
-	// Text-based file detection
-	limit = 1000
-	if len(data) < limit {
-		limit = len(data)
-	}
-	textSample := string(data[:limit])
-
-	// Cummins CSV
-	if strings.HasPrefix(textSample, "sep=,") &&
-		strings.Contains(textSample, "Service Tool") &&
-		strings.Contains(textSample, "INSITE") &&
-		strings.Contains(textSample, "ECM Code") {
-		return FileTypeResult{Type: "text", Subtype: "Cummins", ContentType: "text/csv"}
+// textSample returns the start of data used by the text-based detections
+func textSample(data []byte) string {
+	return string(data[:min(len(data), 1000)])
+}
+
+// Cummins CSV
+func detectCummins(data []byte) (FileTypeResult, bool) {
+	sample := textSample(data)
+	if strings.HasPrefix(sample, "sep=,") &&
+		strings.Contains(sample, "Service Tool") &&
+		strings.Contains(sample, "INSITE") &&
+		strings.Contains(sample, "ECM Code") {
+		return FileTypeResult{Type: "text", Subtype: "Cummins", ContentType: "text/csv"}, true
 	}
+	return FileTypeResult{}, false
+}
 
-	// CAT
-	if strings.Contains(textSample, "Software Group Part Number") {
-		matched, _ := regexp.MatchString(`C\d+(\.\d+)?`, textSample)
-		if matched {
-			return FileTypeResult{Type: "text", Subtype: "CAT", ContentType: "text/plain"}
-		}
+var catPartNumber = regexp.MustCompile(`C\d+(\.\d+)?`)
+
+// CAT
+func detectCAT(data []byte) (FileTypeResult, bool) {
+	sample := textSample(data)
+	if strings.Contains(sample, "Software Group Part Number") && catPartNumber.MatchString(sample) {
+		return FileTypeResult{Type: "text", Subtype: "CAT", ContentType: "text/plain"}, true
 	}
+	return FileTypeResult{}, false
+}
 
-	// Ident file: ECU identification files – small, contain the word "ident" and are not fake.
-	// This check must come AFTER text-based detections so Cummins/CAT files are never misclassified.
+// Ident file: ECU identification files – small, contain the word "ident" and are not fake.
+func detectIdent(data []byte) (FileTypeResult, bool) {
 	if len(data) < 12000 {
 		text := strings.ToLower(string(data))
 		if strings.Contains(text, "ident") && !strings.Contains(text, "fake") {
-			return FileTypeResult{Type: "binary", Subtype: "Ident", ContentType: "application/octet-stream"}
+			return FileTypeResult{Type: "binary", Subtype: "Ident", ContentType: "application/octet-stream"}, true
 		}
 	}
-
-	// Výchozí: binární soubor
-	return FileTypeResult{Type: "binary", ContentType: "application/octet-stream"}
+	return FileTypeResult{}, false
 }
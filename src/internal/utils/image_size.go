@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// ImageSize is the pixel size of an image as stored and its EXIF orientation (1-8, 0 = none)
+type ImageSize struct {
+	Width       int
+	Height      int
+	Orientation int
+}
+
+// DisplaySize returns the size after applying the EXIF orientation: orientations 5-8 rotate the
+// image by 90°, so width and height swap
+func (s ImageSize) DisplaySize() (int, int) {
+	if s.Orientation >= 5 && s.Orientation <= 8 {
+		return s.Height, s.Width
+	}
+	return s.Width, s.Height
+}
+
+// DetectImageSize reads the size of a JPEG, PNG, GIF or WebP image from the start of its content;
+// only headers are parsed, data just has to reach past them. ok is false for other formats and
+// truncated headers.
+func DetectImageSize(data []byte) (size ImageSize, ok bool) {
+	if s, ok := webpSize(data); ok {
+		return s, true
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return ImageSize{}, false
+	}
+	size = ImageSize{Width: cfg.Width, Height: cfg.Height}
+	if len(data) > 2 && data[0] == 0xFF && data[1] == 0xD8 {
+		size.Orientation = jpegOrientation(data)
+	}
+	return size, true
+}
+
+// webpSize parses the VP8, VP8L or VP8X header of a WebP image
+func webpSize(data []byte) (ImageSize, bool) {
+	if len(data) < 30 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return ImageSize{}, false
+	}
+	chunk := data[20:]
+	switch string(data[12:16]) {
+	case "VP8 ":
+		// Klíčový snímek: 3 B tag, 3 B start code, pak 14bitové rozměry
+		if chunk[3] != 0x9D || chunk[4] != 0x01 || chunk[5] != 0x2A {
+			return ImageSize{}, false
+		}
+		w := int(binary.LittleEndian.Uint16(chunk[6:8]) & 0x3FFF)
+		h := int(binary.LittleEndian.Uint16(chunk[8:10]) & 0x3FFF)
+		return ImageSize{Width: w, Height: h}, w > 0 && h > 0
+	case "VP8L":
+		if chunk[0] != 0x2F {
+			return ImageSize{}, false
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:5])
+		return ImageSize{Width: int(bits&0x3FFF) + 1, Height: int(bits>>14&0x3FFF) + 1}, true
+	case "VP8X":
+		w := int(chunk[4]) | int(chunk[5])<<8 | int(chunk[6])<<16
+		h := int(chunk[7]) | int(chunk[8])<<8 | int(chunk[9])<<16
+		return ImageSize{Width: w + 1, Height: h + 1}, true
+	}
+	return ImageSize{}, false
+}
+
+// jpegOrientation returns the EXIF orientation tag of a JPEG image, 0 without one
+func jpegOrientation(data []byte) int {
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 0
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // začátek obrazových dat, EXIF už nepřijde
+			return 0
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return 0
+		}
+		if marker == 0xE1 && bytes.HasPrefix(data[pos+4:end], []byte("Exif\x00\x00")) {
+			return exifOrientation(data[pos+10 : end])
+		}
+		pos = end
+	}
+	return 0
+}
+
+// exifOrientation reads tag 0x0112 from the first IFD of a TIFF structure
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8 : entry+10])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 0
+		}
+	}
+	return 0
+}
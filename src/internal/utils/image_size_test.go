@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// withExifOrientation inserts an APP1 EXIF segment with the orientation tag after the JPEG SOI
+func withExifOrientation(jpg []byte, orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM\x00\x2A")
+	binary.Write(&tiff, binary.BigEndian, uint32(8))      // first IFD
+	binary.Write(&tiff, binary.BigEndian, uint16(1))      // one entry
+	binary.Write(&tiff, binary.BigEndian, uint16(0x0112)) // orientation
+	binary.Write(&tiff, binary.BigEndian, uint16(3))      // SHORT
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, orientation)
+	binary.Write(&tiff, binary.BigEndian, uint16(0))
+	binary.Write(&tiff, binary.BigEndian, uint32(0)) // no next IFD
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(jpg[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(payload)+2))
+	out.Write(payload)
+	out.Write(jpg[2:])
+	return out.Bytes()
+}
+
+func TestDetectImageSize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	var pngBuf, jpgBuf bytes.Buffer
+	png.Encode(&pngBuf, img)
+	jpeg.Encode(&jpgBuf, img, nil)
+
+	// VP8L: signature 0x2F, 14 bits width-1, 14 bits height-1
+	vp8l := append([]byte("RIFF\x00\x00\x00\x00WEBPVP8L\x00\x00\x00\x00\x2F"), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(vp8l[21:25], uint32(40-1)|uint32(30-1)<<14)
+
+	tests := []struct {
+		name         string
+		data         []byte
+		want         ImageSize
+		displayWidth int
+	}{
+		{"png", pngBuf.Bytes(), ImageSize{Width: 40, Height: 30}, 40},
+		{"jpeg", jpgBuf.Bytes(), ImageSize{Width: 40, Height: 30}, 40},
+		{"jpeg rotated", withExifOrientation(jpgBuf.Bytes(), 6), ImageSize{Width: 40, Height: 30, Orientation: 6}, 30},
+		{"webp lossless", vp8l, ImageSize{Width: 40, Height: 30}, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DetectImageSize(tt.data)
+			if !ok || got != tt.want {
+				t.Fatalf("DetectImageSize = %+v, %v, want %+v", got, ok, tt.want)
+			}
+			if w, _ := got.DisplaySize(); w != tt.displayWidth {
+				t.Errorf("display width = %d, want %d", w, tt.displayWidth)
+			}
+		})
+	}
+
+	if _, ok := DetectImageSize([]byte("%PDF-1.7\n")); ok {
+		t.Error("DetectImageSize accepted a PDF")
+	}
+	if _, ok := DetectImageSize(pngBuf.Bytes()[:12]); ok {
+		t.Error("DetectImageSize accepted a truncated PNG header")
+	}
+}
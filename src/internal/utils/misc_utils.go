@@ -1,11 +1,9 @@
 package utils
 
 import (
-	"fmt"
 	"net"
 	"strconv"
 	"strings"
-	"time"
 )
 
 // GetOutboundIP gets the preferred outbound ip of this machine
@@ -54,35 +52,3 @@ func ParseBytes(s string) (int64, error) {
 	}
 	return val * mult, nil
 }
-
-// ParseValidity parses a validity string (e.g. "1 day", "2 months") into a time.Time
-func ParseValidity(val string) (time.Time, error) {
-	parts := strings.Fields(val)
-	if len(parts) != 2 {
-		return time.Time{}, fmt.Errorf("invalid format")
-	}
-	amount, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return time.Time{}, fmt.Errorf("invalid amount")
-	}
-	unit := strings.ToLower(parts[1])
-
-	var d time.Duration
-	switch {
-	case strings.HasPrefix(unit, "day"):
-		d = time.Duration(amount) * 24 * time.Hour
-	case strings.HasPrefix(unit, "month"):
-		d = time.Duration(amount) * 30 * 24 * time.Hour // Approx
-	default:
-		return time.Time{}, fmt.Errorf("unknown unit")
-	}
-
-	if d < 24*time.Hour {
-		return time.Time{}, fmt.Errorf("minimum validity is 1 day")
-	}
-	if d > 365*24*time.Hour {
-		return time.Time{}, fmt.Errorf("maximum validity is 1 year")
-	}
-
-	return time.Now().Add(d), nil
-}
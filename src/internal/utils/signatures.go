@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Priorities of the fallback detectors (FILE_DETECTION_FALLBACK)
+const (
+	PriorityContainer = 110 // before "magic", which types every ZIP as an archive
+	PriorityFallback  = 5   // after all custom patterns
+)
+
+// EnableFallbackDetection registers the fallback detectors in the default registry:
+//
+//	off      - none (default)
+//	builtin  - embedded signatures for office documents, audio, video and archives
+//	libmagic - `file --mime-type` (libmagic) for everything the custom patterns don't know
+//
+// Both builtin and libmagic also recognize office documents and e-books stored as ZIP.
+func EnableFallbackDetection(mode string) error {
+	switch strings.ToLower(mode) {
+	case "", "off":
+		return nil
+	case "builtin":
+		RegisterDetector(NewSniffer("containers", detectZipContainer), PriorityContainer)
+		RegisterDetector(NewSniffer("signatures", detectSignature), PriorityFallback)
+	case "libmagic":
+		RegisterDetector(NewSniffer("containers", detectZipContainer), PriorityContainer)
+		RegisterDetector(NewCommandDetector("libmagic", []string{"file", "--mime-type", "-b", "-"}, 2*time.Second), PriorityFallback)
+	default:
+		return fmt.Errorf("unknown file detection fallback %q (use off, builtin or libmagic)", mode)
+	}
+	Info("DETECT", "File detection fallback: %s", mode)
+	return nil
+}
+
+var zipLocalHeader = []byte{0x50, 0x4B, 0x03, 0x04}
+
+// detectZipContainer recognizes ZIP based formats from the local file headers in the sample:
+// OpenDocument and EPUB (a stored "mimetype" first entry), OOXML and JAR (entry names).
+// A plain ZIP doesn't match and is left to the "magic" detector.
+func detectZipContainer(data []byte) (FileTypeResult, bool) {
+	if !bytes.HasPrefix(data, zipLocalHeader) || len(data) < 30 {
+		return FileTypeResult{}, false
+	}
+
+	nameLen := int(binary.LittleEndian.Uint16(data[26:28]))
+	extraLen := int(binary.LittleEndian.Uint16(data[28:30]))
+	if 30+nameLen <= len(data) && string(data[30:30+nameLen]) == "mimetype" {
+		start := 30 + nameLen + extraLen
+		end := start
+		for end < len(data) && end-start < 80 && data[end] > 0x20 && data[end] < 0x7F {
+			end++
+		}
+		switch mimeType := string(data[start:end]); {
+		case strings.HasPrefix(mimeType, "application/vnd.oasis.opendocument.text"):
+			return FileTypeResult{Type: "document", Subtype: "ODT", ContentType: "application/vnd.oasis.opendocument.text"}, true
+		case strings.HasPrefix(mimeType, "application/vnd.oasis.opendocument.spreadsheet"):
+			return FileTypeResult{Type: "document", Subtype: "ODS", ContentType: "application/vnd.oasis.opendocument.spreadsheet"}, true
+		case strings.HasPrefix(mimeType, "application/vnd.oasis.opendocument.presentation"):
+			return FileTypeResult{Type: "document", Subtype: "ODP", ContentType: "application/vnd.oasis.opendocument.presentation"}, true
+		case strings.HasPrefix(mimeType, "application/epub+zip"):
+			return FileTypeResult{Type: "document", Subtype: "EPUB", ContentType: "application/epub+zip"}, true
+		}
+	}
+
+	if bytes.Contains(data, []byte("[Content_Types].xml")) {
+		switch {
+		case bytes.Contains(data, []byte("word/")):
+			return FileTypeResult{Type: "document", Subtype: "DOCX", ContentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document"}, true
+		case bytes.Contains(data, []byte("xl/")):
+			return FileTypeResult{Type: "document", Subtype: "XLSX", ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}, true
+		case bytes.Contains(data, []byte("ppt/")):
+			return FileTypeResult{Type: "document", Subtype: "PPTX", ContentType: "application/vnd.openxmlformats-officedocument.presentationml.presentation"}, true
+		}
+	}
+	if bytes.Contains(data, []byte("META-INF/MANIFEST.MF")) {
+		return FileTypeResult{Type: "binary", Subtype: "JAR", ContentType: "application/java-archive"}, true
+	}
+	return FileTypeResult{}, false
+}
+
+// signatures are simple magic bytes of formats not covered by the built-in patterns
+var signatures = []PatternDefinition{
+	// Documents
+	{Pattern: []byte(`{\rtf`), Result: FileTypeResult{Type: "document", Subtype: "RTF", ContentType: "application/rtf"}},
+	{Pattern: []byte("%!PS"), Result: FileTypeResult{Type: "document", Subtype: "PS", ContentType: "application/postscript"}},
+
+	// Audio
+	{Pattern: []byte("ID3"), Result: FileTypeResult{Type: "audio", Subtype: "MP3", ContentType: "audio/mpeg"}},
+	{Pattern: []byte("fLaC"), Result: FileTypeResult{Type: "audio", Subtype: "FLAC", ContentType: "audio/flac"}},
+	{Pattern: []byte("OggS"), Result: FileTypeResult{Type: "audio", Subtype: "OGG", ContentType: "audio/ogg"}},
+	{Pattern: []byte("MThd"), Result: FileTypeResult{Type: "audio", Subtype: "MIDI", ContentType: "audio/midi"}},
+	{Pattern: []byte("#!AMR"), Result: FileTypeResult{Type: "audio", Subtype: "AMR", ContentType: "audio/amr"}},
+
+	// Archives
+	{Pattern: []byte{0x1F, 0x8B}, Result: FileTypeResult{Type: "binary", Subtype: "GZIP", ContentType: "application/gzip"}},
+	{Pattern: []byte{0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C}, Result: FileTypeResult{Type: "binary", Subtype: "7Z", ContentType: "application/x-7z-compressed"}},
+	{Pattern: []byte("Rar!\x1A\x07"), Result: FileTypeResult{Type: "binary", Subtype: "RAR", ContentType: "application/vnd.rar"}},
+	{Pattern: []byte("BZh"), Result: FileTypeResult{Type: "binary", Subtype: "BZIP2", ContentType: "application/x-bzip2"}},
+	{Pattern: []byte{0xFD, 0x37, 0x7A, 0x58, 0x5A, 0x00}, Result: FileTypeResult{Type: "binary", Subtype: "XZ", ContentType: "application/x-xz"}},
+	{Pattern: []byte{0x28, 0xB5, 0x2F, 0xFD}, Result: FileTypeResult{Type: "binary", Subtype: "ZSTD", ContentType: "application/zstd"}},
+	{Pattern: []byte("ustar"), Offset: 257, Result: FileTypeResult{Type: "binary", Subtype: "TAR", ContentType: "application/x-tar"}},
+}
+
+var oleHeader = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// utf16 encodes an ASCII string as UTF-16LE (OLE2 directory entry names)
+func utf16(s string) []byte {
+	b := make([]byte, 0, 2*len(s))
+	for i := 0; i < len(s); i++ {
+		b = append(b, s[i], 0)
+	}
+	return b
+}
+
+// detectSignature is the embedded signature database used as the builtin fallback
+func detectSignature(data []byte) (FileTypeResult, bool) {
+	for _, def := range signatures {
+		if matchesPattern(data, def.Pattern, def.Offset) {
+			return def.Result, true
+		}
+	}
+
+	// Legacy MS Office (OLE2): the type is given by the stream names in the directory
+	if bytes.HasPrefix(data, oleHeader) {
+		switch {
+		case bytes.Contains(data, utf16("WordDocument")):
+			return FileTypeResult{Type: "document", Subtype: "DOC", ContentType: "application/msword"}, true
+		case bytes.Contains(data, utf16("Workbook")), bytes.Contains(data, utf16("Book")):
+			return FileTypeResult{Type: "document", Subtype: "XLS", ContentType: "application/vnd.ms-excel"}, true
+		case bytes.Contains(data, utf16("PowerPoint Document")):
+			return FileTypeResult{Type: "document", Subtype: "PPT", ContentType: "application/vnd.ms-powerpoint"}, true
+		}
+		return FileTypeResult{Type: "document", Subtype: "OLE", ContentType: "application/x-ole-storage"}, true
+	}
+
+	// ISO base media (MP4, QuickTime, HEIF, AVIF): "ftyp" box with the major brand
+	if matchesPattern(data, []byte("ftyp"), 4) && len(data) >= 12 {
+		switch brand := string(data[8:12]); {
+		case brand == "M4A " || brand == "M4B ":
+			return FileTypeResult{Type: "audio", Subtype: "M4A", ContentType: "audio/mp4"}, true
+		case brand == "qt  ":
+			return FileTypeResult{Type: "video", Subtype: "MOV", ContentType: "video/quicktime"}, true
+		case brand == "heic" || brand == "heix" || brand == "mif1":
+			return FileTypeResult{Type: "image", Subtype: "HEIC", ContentType: "image/heic"}, true
+		case brand == "avif":
+			return FileTypeResult{Type: "image", Subtype: "AVIF", ContentType: "image/avif"}, true
+		case strings.HasPrefix(brand, "3g"):
+			return FileTypeResult{Type: "video", Subtype: "3GP", ContentType: "video/3gpp"}, true
+		}
+		return FileTypeResult{Type: "video", Subtype: "MP4", ContentType: "video/mp4"}, true
+	}
+
+	// Matroska / WebM (EBML header with the document type)
+	if matchesPattern(data, []byte{0x1A, 0x45, 0xDF, 0xA3}, 0) {
+		if bytes.Contains(data[:min(len(data), 64)], []byte("webm")) {
+			return FileTypeResult{Type: "video", Subtype: "WebM", ContentType: "video/webm"}, true
+		}
+		return FileTypeResult{Type: "video", Subtype: "MKV", ContentType: "video/x-matroska"}, true
+	}
+
+	// RIFF containers (WebP is a built-in detector)
+	if matchesPattern(data, []byte("RIFF"), 0) {
+		switch {
+		case matchesPattern(data, []byte("WAVE"), 8):
+			return FileTypeResult{Type: "audio", Subtype: "WAV", ContentType: "audio/wav"}, true
+		case matchesPattern(data, []byte("AVI "), 8):
+			return FileTypeResult{Type: "video", Subtype: "AVI", ContentType: "video/x-msvideo"}, true
+		}
+	}
+	if matchesPattern(data, []byte("FORM"), 0) && matchesPattern(data, []byte("AIF"), 8) {
+		return FileTypeResult{Type: "audio", Subtype: "AIFF", ContentType: "audio/aiff"}, true
+	}
+
+	// MP3 without ID3 tag: MPEG audio frame sync
+	if len(data) >= 2 && data[0] == 0xFF && (data[1] == 0xFB || data[1] == 0xF3 || data[1] == 0xF2) {
+		return FileTypeResult{Type: "audio", Subtype: "MP3", ContentType: "audio/mpeg"}, true
+	}
+
+	// Rest of the WHATWG sniffing table (fonts, wasm, ...). Text is left to the extension.
+	mimeType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	if mimeType == "application/octet-stream" || strings.HasPrefix(mimeType, "text/") {
+		return FileTypeResult{}, false
+	}
+	result, ok, _ := resultFromMimeType(mimeType)
+	return result, ok
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// Nesoulad přípony a obsahu: .pdf, který je ve skutečnosti ZIP, typicky znamená chybu
+// v exportu partnera. Porovnává se jen obsah rozpoznaný podle signatury s typem přípony.
+
+// mimeAliases maps alternative names of a MIME type to the name the detectors use
+var mimeAliases = map[string]string{
+	"image/jpg":                    "image/jpeg",
+	"image/pjpeg":                  "image/jpeg",
+	"image/x-ms-bmp":               "image/bmp",
+	"image/vnd.microsoft.icon":     "image/x-icon",
+	"audio/x-wav":                  "audio/wav",
+	"audio/wave":                   "audio/wav",
+	"audio/vnd.wave":               "audio/wav",
+	"audio/x-aiff":                 "audio/aiff",
+	"audio/mp3":                    "audio/mpeg",
+	"audio/x-flac":                 "audio/flac",
+	"audio/x-midi":                 "audio/midi",
+	"video/avi":                    "video/x-msvideo",
+	"video/x-matroska-3d":          "video/x-matroska",
+	"application/x-gzip":           "application/gzip",
+	"application/x-rar-compressed": "application/vnd.rar",
+	"application/x-zip-compressed": "application/zip",
+	"application/x-pdf":            "application/pdf",
+}
+
+// mimeFamilies groups types sharing one container format; the signature finds the container,
+// the extension tells the application format inside
+var mimeFamilies = map[string]string{
+	"application/zip":          "zip",
+	"application/java-archive": "zip",
+	"application/epub+zip":     "zip",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   "zip",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         "zip",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": "zip",
+	"application/vnd.oasis.opendocument.text":                                   "zip",
+	"application/vnd.oasis.opendocument.spreadsheet":                            "zip",
+	"application/vnd.oasis.opendocument.presentation":                           "zip",
+	"application/vnd.android.package-archive":                                   "zip",
+	"application/x-ole-storage":                                                 "ole",
+	"application/msword":                                                        "ole",
+	"application/vnd.ms-excel":                                                  "ole",
+	"application/vnd.ms-powerpoint":                                             "ole",
+	"application/vnd.ms-outlook":                                                "ole",
+	"video/mp4":                                                                 "isobmff",
+	"audio/mp4":                                                                 "isobmff",
+	"video/quicktime":                                                           "isobmff",
+	"video/3gpp":                                                                "isobmff",
+	"image/heic":                                                                "isobmff",
+	"image/avif":                                                                "isobmff",
+}
+
+// ExtensionMismatch returns the MIME type the extension of filename stands for when it conflicts
+// with the detected content type, "" otherwise. Generic binary content, unknown extensions and
+// formats sharing a container (ZIP/Office, OLE, MP4/QuickTime) are never reported.
+func ExtensionMismatch(detected FileTypeResult, filename string) string {
+	if detected.ContentType == "" || detected.ContentType == "application/octet-stream" {
+		return ""
+	}
+	ext := filepath.Ext(filename)
+	if ext == "" {
+		return ""
+	}
+	expected, _, err := mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(ext)))
+	if err != nil || expected == "" || expected == "application/octet-stream" {
+		return ""
+	}
+
+	got, want := canonicalMIME(detected.ContentType), canonicalMIME(expected)
+	switch {
+	case got == want:
+		return ""
+	case mimeFamilies[got] != "" && mimeFamilies[got] == mimeFamilies[want]:
+		return ""
+	case strings.HasPrefix(got, "text/") && strings.HasPrefix(want, "text/"):
+		return ""
+	case got == "image/svg+xml" && strings.HasSuffix(want, "xml"):
+		// SVG detektor bere každé XML s hlavičkou <?xml
+		return ""
+	}
+	return expected
+}
+
+func canonicalMIME(mimeType string) string {
+	mimeType = strings.ToLower(mimeType)
+	if alias, ok := mimeAliases[mimeType]; ok {
+		return alias
+	}
+	return mimeType
+}
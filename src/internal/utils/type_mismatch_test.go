@@ -0,0 +1,35 @@
+package utils
+
+import "testing"
+
+func TestExtensionMismatch(t *testing.T) {
+	pdf := FileTypeResult{Type: "pdf", ContentType: "application/pdf"}
+	zip := FileTypeResult{Type: "binary", Subtype: "ZIP", ContentType: "application/zip"}
+	png := FileTypeResult{Type: "image", Subtype: "PNG", ContentType: "image/png"}
+	svg := FileTypeResult{Type: "image", Subtype: "SVG", ContentType: "image/svg+xml"}
+
+	tests := []struct {
+		name     string
+		detected FileTypeResult
+		filename string
+		want     string
+	}{
+		{"zip named pdf", zip, "invoice.pdf", "application/pdf"},
+		{"pdf named png", pdf, "scan.PNG", "image/png"},
+		{"png named jpg", png, "photo.jpg", "image/jpeg"},
+		{"matching", pdf, "invoice.pdf", ""},
+		{"matching upper case", png, "IMG.PNG", ""},
+		{"no extension", zip, "invoice", ""},
+		{"unknown extension", zip, "data.cumulus-unknown", ""},
+		{"generic binary", FileTypeResult{Type: "binary", ContentType: "application/octet-stream"}, "invoice.pdf", ""},
+		{"xml detected as svg", svg, "feed.xml", ""},
+		{"svg named pdf", svg, "logo.pdf", "application/pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtensionMismatch(tt.detected, tt.filename); got != tt.want {
+				t.Errorf("ExtensionMismatch(%s, %q) = %q, want %q", tt.detected.ContentType, tt.filename, got, tt.want)
+			}
+		})
+	}
+}
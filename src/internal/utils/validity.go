@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type validityUnit struct {
+	name string
+	d    time.Duration
+}
+
+// validityUnits are the units of a validity period; a unit matches words starting with its name
+// ("2 days", "1 month"). Months and years are approximate (30 and 365 days).
+var validityUnits = []validityUnit{
+	{"hour", time.Hour},
+	{"day", 24 * time.Hour},
+	{"week", 7 * 24 * time.Hour},
+	{"month", 30 * 24 * time.Hour},
+	{"year", 365 * 24 * time.Hour},
+}
+
+// ValidityPolicy bounds the validity of uploaded files, given as a period ("2 days") or as an
+// absolute expiry time
+type ValidityPolicy struct {
+	Min   time.Duration
+	Max   time.Duration
+	Units []string // units accepted in periods, nil = all
+}
+
+// DefaultValidityPolicy is used without VALIDITY_MIN, VALIDITY_MAX and VALIDITY_UNITS
+var DefaultValidityPolicy = ValidityPolicy{Min: 24 * time.Hour, Max: 365 * 24 * time.Hour}
+
+// NewValidityPolicy builds a policy from periods like "1 day" and a comma-separated unit list;
+// empty values keep the defaults
+func NewValidityPolicy(minVal, maxVal, units string) (ValidityPolicy, error) {
+	p := DefaultValidityPolicy
+	if minVal != "" {
+		d, err := parseValidityPeriod(minVal, nil)
+		if err != nil {
+			return p, fmt.Errorf("invalid minimum %q: %w", minVal, err)
+		}
+		p.Min = d
+	}
+	if maxVal != "" {
+		d, err := parseValidityPeriod(maxVal, nil)
+		if err != nil {
+			return p, fmt.Errorf("invalid maximum %q: %w", maxVal, err)
+		}
+		p.Max = d
+	}
+	if p.Min > p.Max {
+		return p, fmt.Errorf("minimum %s is greater than maximum %s", FormatValidity(p.Min), FormatValidity(p.Max))
+	}
+	for _, unit := range strings.Split(units, ",") {
+		unit = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(unit)), "s")
+		if unit == "" {
+			continue
+		}
+		if !slices.ContainsFunc(validityUnits, func(u validityUnit) bool { return u.name == unit }) {
+			return p, fmt.Errorf("unknown unit %q (use hour, day, week, month, year)", unit)
+		}
+		p.Units = append(p.Units, unit)
+	}
+	return p, nil
+}
+
+// Parse parses a validity period (e.g. "1 day", "2 months") into the expiry time
+func (p ValidityPolicy) Parse(val string) (time.Time, error) {
+	d, err := parseValidityPeriod(val, p.Units)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := p.check(d); err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(d), nil
+}
+
+// ParseExpiry parses an absolute expiry time (RFC 3339); it must lie within the bounds as well
+func (p ValidityPolicy) ParseExpiry(val string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(val))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 time (e.g. 2026-01-31T12:00:00Z)")
+	}
+	if !t.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("expiry time is in the past")
+	}
+	if err := p.check(time.Until(t)); err != nil {
+		return time.Time{}, err
+	}
+	return t, nil
+}
+
+// AllowedUnits returns the units accepted in validity periods, in ascending order
+func (p ValidityPolicy) AllowedUnits() []string {
+	units := make([]string, 0, len(validityUnits))
+	for _, u := range validityUnits {
+		if p.Units == nil || slices.Contains(p.Units, u.name) {
+			units = append(units, u.name)
+		}
+	}
+	return units
+}
+
+func (p ValidityPolicy) check(d time.Duration) error {
+	if d < p.Min {
+		return fmt.Errorf("minimum validity is %s", FormatValidity(p.Min))
+	}
+	if d > p.Max {
+		return fmt.Errorf("maximum validity is %s", FormatValidity(p.Max))
+	}
+	return nil
+}
+
+// parseValidityPeriod parses "<amount> <unit>" with one of units (nil = all)
+func parseValidityPeriod(val string, units []string) (time.Duration, error) {
+	parts := strings.Fields(val)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid format")
+	}
+	amount, err := strconv.Atoi(parts[0])
+	if err != nil || amount < 0 {
+		return 0, fmt.Errorf("invalid amount")
+	}
+	unit := strings.ToLower(parts[1])
+	for _, u := range validityUnits {
+		if !strings.HasPrefix(unit, u.name) {
+			continue
+		}
+		if units != nil && !slices.Contains(units, u.name) {
+			return 0, fmt.Errorf("unit %s is not allowed (allowed: %s)", u.name, strings.Join(units, ", "))
+		}
+		return time.Duration(amount) * u.d, nil
+	}
+	return 0, fmt.Errorf("unknown unit")
+}
+
+// FormatValidity formats a duration in the largest unit that divides it ("1 year", "36 hours")
+func FormatValidity(d time.Duration) string {
+	for i := len(validityUnits) - 1; i >= 0; i-- {
+		u := validityUnits[i]
+		if d >= u.d && d%u.d == 0 {
+			n := int64(d / u.d)
+			if n == 1 {
+				return "1 " + u.name
+			}
+			return fmt.Sprintf("%d %ss", n, u.name)
+		}
+	}
+	return d.String()
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidityPolicyParse(t *testing.T) {
+	hourly, err := NewValidityPolicy("2 hours", "2 years", "hours, days, years")
+	if err != nil {
+		t.Fatalf("NewValidityPolicy: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		policy  ValidityPolicy
+		val     string
+		want    time.Duration
+		wantErr string
+	}{
+		{"default day", DefaultValidityPolicy, "1 day", 24 * time.Hour, ""},
+		{"default months", DefaultValidityPolicy, "2 months", 60 * 24 * time.Hour, ""},
+		{"default week", DefaultValidityPolicy, "1 week", 7 * 24 * time.Hour, ""},
+		{"default below minimum", DefaultValidityPolicy, "12 hours", 0, "minimum validity is 1 day"},
+		{"default above maximum", DefaultValidityPolicy, "2 years", 0, "maximum validity is 1 year"},
+		{"hours allowed", hourly, "3 hours", 3 * time.Hour, ""},
+		{"years allowed", hourly, "2 years", 2 * 365 * 24 * time.Hour, ""},
+		{"unit not allowed", hourly, "1 month", 0, "unit month is not allowed"},
+		{"unknown unit", DefaultValidityPolicy, "3 fortnights", 0, "unknown unit"},
+		{"invalid format", DefaultValidityPolicy, "forever", 0, "invalid format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			got, err := tt.policy.Parse(tt.val)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse(%q) error = %v, want %q", tt.val, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.val, err)
+			}
+			if d := got.Sub(before); d < tt.want || d > tt.want+time.Second {
+				t.Errorf("Parse(%q) expires in %s, want %s", tt.val, d, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidityPolicyParseExpiry(t *testing.T) {
+	p := DefaultValidityPolicy
+	inMonth := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	got, err := p.ParseExpiry(inMonth.Format(time.RFC3339))
+	if err != nil || !got.Equal(inMonth) {
+		t.Fatalf("ParseExpiry = %v, %v, want %v", got, err, inMonth)
+	}
+
+	for _, val := range []string{
+		time.Now().Add(-time.Hour).Format(time.RFC3339),               // past
+		time.Now().Add(time.Hour).Format(time.RFC3339),                // below minimum
+		time.Now().Add(2 * 365 * 24 * time.Hour).Format(time.RFC3339), // above maximum
+		"2026-01-31",
+	} {
+		if _, err := p.ParseExpiry(val); err == nil {
+			t.Errorf("ParseExpiry(%q) accepted", val)
+		}
+	}
+}
+
+func TestNewValidityPolicyRejectsInvalid(t *testing.T) {
+	for _, tt := range [][3]string{
+		{"1 year", "1 day", ""},
+		{"", "", "minutes"},
+		{"soon", "", ""},
+	} {
+		if _, err := NewValidityPolicy(tt[0], tt[1], tt[2]); err == nil {
+			t.Errorf("NewValidityPolicy(%q, %q, %q) accepted", tt[0], tt[1], tt[2])
+		}
+	}
+}
+
+func TestValidityPolicyAllowedUnits(t *testing.T) {
+	if got := DefaultValidityPolicy.AllowedUnits(); !slices.Equal(got, []string{"hour", "day", "week", "month", "year"}) {
+		t.Errorf("default units = %v", got)
+	}
+	p, err := NewValidityPolicy("", "", "years, days")
+	if err != nil {
+		t.Fatalf("NewValidityPolicy: %v", err)
+	}
+	if got := p.AllowedUnits(); !slices.Equal(got, []string{"day", "year"}) {
+		t.Errorf("units = %v, want [day year]", got)
+	}
+}